@@ -22,11 +22,16 @@ func (g *Goes) Builtins() map[string]func(...string) error {
 		g.cache.Lock()
 		defer g.cache.Unlock()
 		g.cache.builtins = map[string]func(...string) error{
-			"apropos":  g.apropos,
-			"complete": g.complete,
-			"help":     g.help,
-			"man":      g.man,
-			"usage":    g.usage,
+			"apropos":   g.apropos,
+			"bg":        g.bg,
+			"complete":  g.complete,
+			"fg":        g.fg,
+			"help":      g.help,
+			"jobs":      g.jobs,
+			"man":       g.man,
+			"stats":     g.stats,
+			"usage":     g.usage,
+			"verbosity": g.verbosity,
 		}
 	}
 	return g.cache.builtins
@@ -0,0 +1,101 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package client provides the reduced goes of remote management that builds
+// for darwin and windows, as well as linux, e.g. on an operator's laptop.
+// It has the cli, its blocks and helpers, and the redis and daemon clients
+// but none of the commands of a machine's hardware, kernel, or
+// filesystems.
+//
+// The redis commands reach the redisd of a switch with $GOES_REDIS, e.g.
+//
+//	GOES_REDIS=switch-1 goes-client hgetall
+package client
+
+import (
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/buildid"
+	"github.com/platinasystems/goes/cmd/buildinfo"
+	"github.com/platinasystems/goes/cmd/cat"
+	"github.com/platinasystems/goes/cmd/cd"
+	"github.com/platinasystems/goes/cmd/cli"
+	"github.com/platinasystems/goes/cmd/echo"
+	"github.com/platinasystems/goes/cmd/elsecmd"
+	"github.com/platinasystems/goes/cmd/env"
+	"github.com/platinasystems/goes/cmd/exit"
+	"github.com/platinasystems/goes/cmd/export"
+	"github.com/platinasystems/goes/cmd/falsecmd"
+	"github.com/platinasystems/goes/cmd/ficmd"
+	"github.com/platinasystems/goes/cmd/for"
+	"github.com/platinasystems/goes/cmd/function"
+	"github.com/platinasystems/goes/cmd/hdel"
+	"github.com/platinasystems/goes/cmd/hdelta"
+	"github.com/platinasystems/goes/cmd/hexists"
+	"github.com/platinasystems/goes/cmd/hget"
+	"github.com/platinasystems/goes/cmd/hgetall"
+	"github.com/platinasystems/goes/cmd/hkeys"
+	"github.com/platinasystems/goes/cmd/hset"
+	"github.com/platinasystems/goes/cmd/hwait"
+	"github.com/platinasystems/goes/cmd/ifcmd"
+	"github.com/platinasystems/goes/cmd/keys"
+	"github.com/platinasystems/goes/cmd/log"
+	"github.com/platinasystems/goes/cmd/pwd"
+	"github.com/platinasystems/goes/cmd/replay"
+	"github.com/platinasystems/goes/cmd/sleep"
+	"github.com/platinasystems/goes/cmd/source"
+	"github.com/platinasystems/goes/cmd/thencmd"
+	"github.com/platinasystems/goes/cmd/truecmd"
+	"github.com/platinasystems/goes/cmd/while"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Name of the client program.
+const Name = "goes-client"
+
+// New returns the goes of the client commands; a program may add others
+// before its Main.
+func New() *goes.Goes {
+	return &goes.Goes{
+		NAME: Name,
+		APROPOS: lang.Alt{
+			lang.EnUS: "goes remote management client",
+		},
+		ByName: map[string]cmd.Cmd{
+			"buildid":   buildid.Command{},
+			"buildinfo": buildinfo.Command{},
+			"cat":       cat.Command{},
+			"cd":        &cd.Command{},
+			"cli":       &cli.Command{},
+			"echo":      echo.Command{},
+			"else":      &elsecmd.Command{},
+			"env":       &env.Command{},
+			"exit":      exit.Command{},
+			"export":    export.Command{},
+			"false":     falsecmd.Command{},
+			"fi":        &ficmd.Command{},
+			"for":       &forcmd.Command{},
+			"function":  &function.Command{},
+			"hdel":      hdel.Command{},
+			"hdelta":    &hdelta.Command{},
+			"hexists":   hexists.Command{},
+			"hget":      hget.Command{},
+			"hgetall":   hgetall.Command{},
+			"hkeys":     hkeys.Command{},
+			"hset":      hset.Command{},
+			"hwait":     hwait.Command{},
+			"if":        &ifcmd.Command{},
+			"keys":      keys.Command{},
+			"log":       log.Command{},
+			"pwd":       pwd.Command{},
+			"replay":    replay.Command{},
+			"sleep":     sleep.Command{},
+			"source":    &source.Command{},
+			"then":      &thencmd.Command{},
+			"true":      truecmd.Command{},
+			"until":     &whilecmd.Command{IsUntil: true},
+			"while":     &whilecmd.Command{},
+		},
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// This is the remote management client of an operator's workstation, e.g.
+//
+//	GOOS=darwin go build ./client/goes-client
+//	GOOS=windows go build ./client/goes-client
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/platinasystems/goes/client"
+)
+
+func main() {
+	if err := client.New().Main(os.Args...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
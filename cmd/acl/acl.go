@@ -0,0 +1,303 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package acl provides the schema of the redis settable acl.* fields of the
+// match and action rules bound to ports, kept by acld and installed by
+// vnetd, along with a command to show and change these.
+package acl
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "acl" }
+
+func (Command) Usage() string {
+	return `acl [-json] [show] [NAME]...
+acl add NAME SEQ ACTION [MATCH VALUE]...
+acl del NAME [SEQ]...
+acl bind NAME ingress|egress PORT...
+acl unbind NAME ingress|egress [PORT]...`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or change access control lists",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	acl [-json] [show] [NAME]...
+		print the rules, hit counts, and bindings of all, or the
+		given, ACLs
+
+	acl add NAME SEQ ACTION [MATCH VALUE]...
+		add or replace the rule of the sequence number, 1 through
+		65535; the first matching rule by sequence applies
+
+	acl del NAME [SEQ]...
+		remove the given, or all, rules and bindings of the ACL
+
+	acl bind NAME ingress|egress PORT...
+		apply the ACL to the ingress or egress of the ports; a port
+		has at most one ACL of each direction
+
+	acl unbind NAME ingress|egress [PORT]...
+		remove the given, or all, ports of the direction
+
+ACTIONS
+	permit
+	deny
+	police RATE
+		rate limit to bits per second, with a k, m, or g suffix
+	mirror PORT
+		permit and copy to the port
+
+MATCHES
+	src-mac MAC, dst-mac MAC
+	ethertype NUMBER
+	vlan VID
+	src PREFIX, dst PREFIX
+	proto tcp|udp|icmp|icmp6|NUMBER
+	src-port PORT[-PORT], dst-port PORT[-PORT]
+		with proto tcp or udp
+	dscp NUMBER
+
+	These are wrappers of the redis settable fields kept by acld,
+		hset platina acl.NAME.rule.SEQ "ACTION [MATCH VALUE]..."
+		hset platina acl.NAME.ingress "PORT..."
+		hset platina acl.NAME.egress "PORT..."
+	that vnetd compiles into the switch TCAM, publishing the hits of
+	each rule as,
+		acl.NAME.rule.SEQ.hits: COUNT
+
+EXAMPLES
+	acl add mgmt 10 permit src 10.0.0.0/8 proto tcp dst-port 22
+	acl add mgmt 20 deny proto tcp dst-port 22
+	acl add mgmt 30 police 100m proto udp dst-port 1000-2000
+	acl bind mgmt ingress eth-1-1 eth-2-1
+
+SEE ALSO
+	acld`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return add(args[1:]...)
+		case "del", "delete":
+			return del(args[1:]...)
+		case "bind":
+			return bind(true, args[1:]...)
+		case "unbind":
+			return bind(false, args[1:]...)
+		}
+	}
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		selected := make(Config)
+		for _, name := range args {
+			a, found := c[name]
+			if !found {
+				return fmt.Errorf("%s: not found", name)
+			}
+			selected[name] = a
+		}
+		c = selected
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ACL\tSEQ\tRULE\tHITS")
+	for _, name := range c.Names() {
+		a := c[name]
+		for _, seq := range a.Seqs() {
+			r := a.Rules[seq]
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", name, seq, r, r.Hits)
+		}
+	}
+	fmt.Fprintln(w, "\nACL\tINGRESS\tEGRESS")
+	for _, name := range c.Names() {
+		a := c[name]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name,
+			strings.Join(a.Ingress, " "), strings.Join(a.Egress, " "))
+	}
+	return w.Flush()
+}
+
+// Get the ACLs kept by acld with the hits published by vnetd.
+func Get() (Config, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(fields)
+}
+
+func add(args ...string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("NAME SEQ ACTION: missing")
+	}
+	if !ValidName(args[0]) {
+		return fmt.Errorf("%s: invalid name", args[0])
+	}
+	seq, err := strconv.ParseUint(args[1], 10, 16)
+	if err != nil || seq == 0 {
+		return fmt.Errorf("%s: invalid sequence number", args[1])
+	}
+	r, err := ParseRule(args[2:]...)
+	if err != nil {
+		return err
+	}
+	return hset(fmt.Sprint(Prefix, args[0], rulePrefix, seq), r.String())
+}
+
+func del(args ...string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("NAME: missing")
+	}
+	name, seqs := args[0], args[1:]
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	a, found := c[name]
+	if !found {
+		return fmt.Errorf("%s: not found", name)
+	}
+	if len(seqs) == 0 {
+		for _, seq := range a.Seqs() {
+			seqs = append(seqs, strconv.Itoa(int(seq)))
+		}
+		if err = hset(Prefix+name+".ingress", ""); err != nil {
+			return err
+		}
+		if err = hset(Prefix+name+".egress", ""); err != nil {
+			return err
+		}
+	}
+	for _, seq := range seqs {
+		if err = hset(Prefix+name+rulePrefix+seq, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bind(add bool, args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("NAME ingress|egress: missing")
+	}
+	name, dir, ports := args[0], args[1], args[2:]
+	if !ValidName(name) {
+		return fmt.Errorf("%s: invalid name", name)
+	}
+	if dir != "ingress" && dir != "egress" {
+		return fmt.Errorf("%s: invalid direction", dir)
+	}
+	if add && len(ports) == 0 {
+		return fmt.Errorf("PORT: missing")
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	var bound []string
+	if a, found := c[name]; found {
+		bound = a.Ingress
+		if dir == "egress" {
+			bound = a.Egress
+		}
+	}
+	if add {
+		bound = append(without(bound, ports), ports...)
+	} else if len(ports) > 0 {
+		bound = without(bound, ports)
+	} else {
+		bound = nil
+	}
+	return hset(Prefix+name+"."+dir, strings.Join(bound, " "))
+}
+
+func hset(field, value string) error {
+	_, err := redis.Hset(redis.DefaultHash, field, value)
+	return err
+}
+
+// without returns the list less the ports.
+func without(list, ports []string) []string {
+	var ret []string
+	for _, s := range list {
+		found := false
+		for _, port := range ports {
+			if s == port {
+				found = true
+			}
+		}
+		if !found {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(args) <= 1 {
+		return complete.Prefixed(last, "-json", "show", "add", "del",
+			"bind", "unbind")
+	}
+	names := func() []string {
+		if c, err := Get(); err == nil {
+			return complete.Prefixed(last, c.Names()...)
+		}
+		return nil
+	}
+	switch args[0] {
+	case "add", "del", "delete":
+		if len(args) == 2 {
+			return names()
+		}
+		return nil
+	case "bind", "unbind":
+		switch len(args) {
+		case 2:
+			return names()
+		case 3:
+			return complete.Prefixed(last, "ingress", "egress")
+		}
+		return complete.IfName(last)
+	}
+	return names()
+}
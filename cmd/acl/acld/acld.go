@@ -0,0 +1,174 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package acld provides a daemon that validates and keeps the redis
+// settable acl.* fields for vnetd to install in the switch.
+package acld
+
+import (
+	"fmt"
+	"net/rpc"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/acl"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	hset     chan hset
+}
+
+// Acld is the RPC handler of the redis settable acl.* fields.
+type Acld struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+func (*Command) String() string { return "acld" }
+
+func (*Command) Usage() string { return "acld" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "access control list daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable fields,
+		acl.NAME.rule.SEQ: ACTION [MATCH VALUE]...
+		acl.NAME.ingress: PORT...
+		acl.NAME.egress: PORT...
+	in /etc/goes/persist/acl and publish these on start and on each
+	change for vnetd to compile into the switch TCAM.
+
+	acld rejects a rule that doesn't parse and a binding of a port to
+	more than one ACL of the same direction. The rule is saved in its
+	canonical form, e.g. with the police rate in bits per second.
+
+	The hit counters, acl.NAME.rule.SEQ.hits, are published by vnetd so
+	these aren't settable.
+
+FILES
+	/etc/goes/persist/acl
+
+SEE ALSO
+	acl`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if c.settings, err = persist.Load("acl"); err != nil {
+		return err
+	}
+	fields := c.fields()
+	if _, err = acl.Parse(fields); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	c.hset = make(chan hset)
+	rpc.Register(&Acld{c.hset})
+	srvr, err := atsock.NewRpcServer("acld")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", acl.Prefix)
+	if err = redis.Assign(key, "acld", "Acld"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		}
+	}
+}
+
+func (acld *Acld) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	acld.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+func (c *Command) fields() map[string]string {
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	return fields
+}
+
+// set validates the field with the others before saving and publishing it.
+func (c *Command) set(field, value string) error {
+	if !acl.Settable(field) {
+		return fmt.Errorf("%s: read only", field)
+	}
+	value = strings.Join(strings.Fields(value), " ")
+	isRule := strings.Contains(field, ".rule.")
+	if len(value) > 0 && isRule {
+		r, err := acl.ParseRule(strings.Fields(value)...)
+		if err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+		value = r.String()
+	}
+	fields := c.fields()
+	fields[field] = value
+	if _, err := acl.Parse(fields); err != nil {
+		return err
+	}
+	if err := c.settings.Set(field, value); err != nil {
+		return err
+	}
+	if len(value) > 0 {
+		c.pub.Print(field, ": ", value)
+	} else {
+		c.pub.Print("delete: ", field)
+		if isRule {
+			// also the hits published by vnetd
+			c.pub.Print("delete: ", field, ".hits")
+		}
+	}
+	return nil
+}
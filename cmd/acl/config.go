@@ -0,0 +1,409 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package acl
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the redis settable fields,
+//
+//	acl.NAME.rule.SEQ: ACTION [MATCH]...
+//	acl.NAME.ingress: PORT...
+//	acl.NAME.egress: PORT...
+//
+// and the hit counters published by vnetd,
+//
+//	acl.NAME.rule.SEQ.hits: COUNT
+const Prefix = "acl."
+
+const (
+	rulePrefix = ".rule."
+	hitsSuffix = ".hits"
+)
+
+// Config of the ACLs by name
+type Config map[string]*Acl
+
+// Acl is a list of rules, first match by sequence number, bound to the
+// ingress or egress of ports.
+type Acl struct {
+	Rules   map[uint16]*Rule `json:"rules"`
+	Ingress []string         `json:"ingress,omitempty"`
+	Egress  []string         `json:"egress,omitempty"`
+}
+
+// Rule of match fields, all of which must match, and an action.
+type Rule struct {
+	Action string `json:"action"`
+	// police rate in bits per second
+	Rate uint64 `json:"rate,omitempty"`
+	// mirror destination port
+	Mirror    string `json:"mirror,omitempty"`
+	SrcMac    string `json:"src-mac,omitempty"`
+	DstMac    string `json:"dst-mac,omitempty"`
+	EtherType uint16 `json:"ethertype,omitempty"`
+	Vlan      uint16 `json:"vlan,omitempty"`
+	Src       string `json:"src,omitempty"`
+	Dst       string `json:"dst,omitempty"`
+	Proto     uint8  `json:"proto,omitempty"`
+	SrcPort   string `json:"src-port,omitempty"`
+	DstPort   string `json:"dst-port,omitempty"`
+	// DSCP is one more than the codepoint so that 0 matches any
+	Dscp uint8 `json:"dscp,omitempty"`
+	// Hits published by vnetd
+	Hits uint64 `json:"hits"`
+}
+
+var protoByName = map[string]uint8{
+	"icmp":  1,
+	"tcp":   6,
+	"udp":   17,
+	"icmp6": 58,
+}
+
+// ParseRule of the action and match arguments, e.g.
+//
+//	deny src 10.0.0.0/8 proto tcp dst-port 22
+//	police 10m proto udp
+//	mirror eth-32-1 vlan 100
+func ParseRule(args ...string) (*Rule, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("ACTION: missing")
+	}
+	r := &Rule{Action: args[0]}
+	args = args[1:]
+	switch r.Action {
+	case "permit", "deny":
+	case "police":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("police RATE: missing")
+		}
+		rate, err := ParseRate(args[0])
+		if err != nil {
+			return nil, err
+		}
+		r.Rate, args = rate, args[1:]
+	case "mirror":
+		if len(args) == 0 {
+			return nil, fmt.Errorf("mirror PORT: missing")
+		}
+		r.Mirror, args = args[0], args[1:]
+	default:
+		return nil, fmt.Errorf("%s: unknown action", r.Action)
+	}
+	if len(args)%2 != 0 {
+		return nil, fmt.Errorf("%s: missing value", args[len(args)-1])
+	}
+	for ; len(args) > 0; args = args[2:] {
+		name, value := args[0], args[1]
+		invalid := fmt.Errorf("%s %q: invalid", name, value)
+		switch name {
+		case "src-mac", "dst-mac":
+			mac, err := net.ParseMAC(value)
+			if err != nil || len(mac) != 6 {
+				return nil, invalid
+			}
+			if name == "src-mac" {
+				r.SrcMac = mac.String()
+			} else {
+				r.DstMac = mac.String()
+			}
+		case "ethertype":
+			u, err := strconv.ParseUint(value, 0, 16)
+			if err != nil || u < 0x600 {
+				return nil, invalid
+			}
+			r.EtherType = uint16(u)
+		case "vlan":
+			u, err := strconv.ParseUint(value, 10, 16)
+			if err != nil || u < 1 || u > 4094 {
+				return nil, invalid
+			}
+			r.Vlan = uint16(u)
+		case "src", "dst":
+			_, ipnet, err := net.ParseCIDR(value)
+			if err != nil {
+				ip := net.ParseIP(value)
+				if ip == nil {
+					return nil, invalid
+				}
+				bits := 128
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				ipnet = &net.IPNet{IP: ip,
+					Mask: net.CIDRMask(bits, bits)}
+			}
+			if name == "src" {
+				r.Src = ipnet.String()
+			} else {
+				r.Dst = ipnet.String()
+			}
+		case "proto":
+			proto, found := protoByName[value]
+			if !found {
+				u, err := strconv.ParseUint(value, 0, 8)
+				if err != nil {
+					return nil, invalid
+				}
+				proto = uint8(u)
+			}
+			r.Proto = proto
+		case "src-port", "dst-port":
+			s, err := parsePortRange(value)
+			if err != nil {
+				return nil, invalid
+			}
+			if name == "src-port" {
+				r.SrcPort = s
+			} else {
+				r.DstPort = s
+			}
+		case "dscp":
+			u, err := strconv.ParseUint(value, 0, 8)
+			if err != nil || u > 63 {
+				return nil, invalid
+			}
+			r.Dscp = uint8(u) + 1
+		default:
+			return nil, fmt.Errorf("%s: unknown match", name)
+		}
+	}
+	if (len(r.SrcPort) > 0 || len(r.DstPort) > 0) &&
+		r.Proto != protoByName["tcp"] && r.Proto != protoByName["udp"] {
+		return nil, fmt.Errorf("port match without tcp or udp proto")
+	}
+	if len(r.Src) > 0 && len(r.Dst) > 0 &&
+		strings.Contains(r.Src, ":") != strings.Contains(r.Dst, ":") {
+		return nil, fmt.Errorf("src and dst of different families")
+	}
+	return r, nil
+}
+
+// ParseRate of bits per second with an optional k, m, or g suffix.
+func ParseRate(s string) (uint64, error) {
+	scale := uint64(1)
+	if n := len(s); n > 0 {
+		switch strings.ToLower(s[n-1:]) {
+		case "k":
+			scale = 1000
+		case "m":
+			scale = 1000000
+		case "g":
+			scale = 1000000000
+		}
+		if scale > 1 {
+			s = s[:n-1]
+		}
+	}
+	u, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || u == 0 {
+		return 0, fmt.Errorf("%q: invalid rate", s)
+	}
+	return u * scale, nil
+}
+
+// parsePortRange of PORT or LOW-HIGH
+func parsePortRange(s string) (string, error) {
+	lo, hi := s, s
+	if i := strings.Index(s, "-"); i > 0 {
+		lo, hi = s[:i], s[i+1:]
+	}
+	l, err := strconv.ParseUint(lo, 10, 16)
+	if err != nil {
+		return "", err
+	}
+	h, err := strconv.ParseUint(hi, 10, 16)
+	if err != nil || h < l {
+		return "", fmt.Errorf("invalid")
+	}
+	if l == h {
+		return strconv.FormatUint(l, 10), nil
+	}
+	return fmt.Sprint(l, "-", h), nil
+}
+
+// String of the rule in the canonical form of ParseRule.
+func (r *Rule) String() string {
+	s := []string{r.Action}
+	switch r.Action {
+	case "police":
+		s = append(s, strconv.FormatUint(r.Rate, 10))
+	case "mirror":
+		s = append(s, r.Mirror)
+	}
+	add := func(name, value string) {
+		if len(value) > 0 {
+			s = append(s, name, value)
+		}
+	}
+	add("src-mac", r.SrcMac)
+	add("dst-mac", r.DstMac)
+	if r.EtherType != 0 {
+		add("ethertype", fmt.Sprintf("0x%04x", r.EtherType))
+	}
+	if r.Vlan != 0 {
+		add("vlan", strconv.Itoa(int(r.Vlan)))
+	}
+	add("src", r.Src)
+	add("dst", r.Dst)
+	if r.Proto != 0 {
+		proto := strconv.Itoa(int(r.Proto))
+		for name, u := range protoByName {
+			if u == r.Proto {
+				proto = name
+			}
+		}
+		add("proto", proto)
+	}
+	add("src-port", r.SrcPort)
+	add("dst-port", r.DstPort)
+	if r.Dscp != 0 {
+		add("dscp", strconv.Itoa(int(r.Dscp)-1))
+	}
+	return strings.Join(s, " ")
+}
+
+// Settable returns true if the field is an ACL rule or binding rather
+// than the hit counter published by vnetd.
+func Settable(field string) bool {
+	return strings.HasPrefix(field, Prefix) &&
+		!strings.HasSuffix(field, hitsSuffix)
+}
+
+// Parse the acl.* fields, returning an error if any are invalid or if a
+// port is bound to more than one ACL of the same direction.
+func Parse(fields map[string]string) (Config, error) {
+	c := make(Config)
+	hits := make(map[string]uint64)
+	for field, value := range fields {
+		if !strings.HasPrefix(field, Prefix) {
+			return nil, fmt.Errorf("%s: not an acl field", field)
+		}
+		if !Settable(field) {
+			hits[strings.TrimSuffix(field, hitsSuffix)], _ =
+				strconv.ParseUint(value, 10, 64)
+			continue
+		}
+		if err := c.set(field, value); err != nil {
+			return nil, err
+		}
+	}
+	for field, n := range hits {
+		name, seq, err := parseRuleField(field)
+		if err != nil {
+			continue
+		}
+		if a, found := c[name]; found {
+			if r, found := a.Rules[seq]; found {
+				r.Hits = n
+			}
+		}
+	}
+	for _, dir := range []string{"ingress", "egress"} {
+		bound := make(map[string]string)
+		for _, name := range c.Names() {
+			ports := c[name].Ingress
+			if dir == "egress" {
+				ports = c[name].Egress
+			}
+			for _, port := range ports {
+				if other, found := bound[port]; found {
+					return nil, fmt.Errorf("%s: %s of %s and %s",
+						port, dir, other, name)
+				}
+				bound[port] = name
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c Config) set(field, value string) error {
+	args := strings.Fields(value)
+	if len(args) == 0 {
+		return nil
+	}
+	acl := func(name string) *Acl {
+		a, found := c[name]
+		if !found {
+			a = &Acl{Rules: make(map[uint16]*Rule)}
+			c[name] = a
+		}
+		return a
+	}
+	s := field[len(Prefix):]
+	switch {
+	case strings.HasSuffix(s, ".ingress") && ValidName(s[:len(s)-8]):
+		acl(s[:len(s)-8]).Ingress = args
+	case strings.HasSuffix(s, ".egress") && ValidName(s[:len(s)-7]):
+		acl(s[:len(s)-7]).Egress = args
+	default:
+		name, seq, err := parseRuleField(field)
+		if err != nil {
+			return err
+		}
+		r, err := ParseRule(args...)
+		if err != nil {
+			return fmt.Errorf("%s: %v", field, err)
+		}
+		acl(name).Rules[seq] = r
+	}
+	return nil
+}
+
+// parseRuleField returns the name and sequence number of an
+// acl.NAME.rule.SEQ field.
+func parseRuleField(field string) (string, uint16, error) {
+	s := field[len(Prefix):]
+	i := strings.LastIndex(s, rulePrefix)
+	if i <= 0 || !ValidName(s[:i]) {
+		return "", 0, fmt.Errorf("%s: invalid", field)
+	}
+	u, err := strconv.ParseUint(s[i+len(rulePrefix):], 10, 16)
+	if err != nil || u == 0 {
+		return "", 0, fmt.Errorf("%s: invalid sequence number", field)
+	}
+	return s[:i], uint16(u), nil
+}
+
+// ValidName returns true if the ACL name is alphanumeric, '-', or '_'.
+func ValidName(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Names returns the sorted ACL names.
+func (c Config) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Seqs returns the sorted sequence numbers of the ACL's rules.
+func (a *Acl) Seqs() []uint16 {
+	seqs := make([]uint16, 0, len(a.Rules))
+	for seq := range a.Rules {
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs
+}
@@ -0,0 +1,67 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package acl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRule(t *testing.T) {
+	for _, x := range []struct{ in, out string }{
+		{"permit", "permit"},
+		{"deny src 10.1.2.3/8 proto tcp dst-port 22",
+			"deny src 10.0.0.0/8 proto tcp dst-port 22"},
+		{"police 10m proto udp src-port 1000-2000",
+			"police 10000000 proto udp src-port 1000-2000"},
+		{"mirror eth-32-1 vlan 100 dst 2001:db8::1 dscp 0",
+			"mirror eth-32-1 vlan 100 dst 2001:db8::1/128 dscp 0"},
+		{"deny ethertype 0x88cc src-mac 02:00:00:00:00:01",
+			"deny src-mac 02:00:00:00:00:01 ethertype 0x88cc"},
+	} {
+		r, err := ParseRule(strings.Fields(x.in)...)
+		if err != nil {
+			t.Errorf("%q: %v", x.in, err)
+		} else if s := r.String(); s != x.out {
+			t.Errorf("%q: %q", x.in, s)
+		}
+	}
+	for _, in := range []string{"", "allow", "police", "police 0",
+		"deny dst-port 22", "deny vlan 4095", "deny dscp 64",
+		"deny src 10.0.0.1 dst ::1", "deny src-port 20-10 proto tcp",
+		"permit proto"} {
+		if _, err := ParseRule(strings.Fields(in)...); err == nil {
+			t.Errorf("%q: expected error", in)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"acl.mgmt.rule.20":      "deny proto tcp dst-port 22",
+		"acl.mgmt.rule.10":      "permit src 10.0.0.0/8",
+		"acl.mgmt.rule.10.hits": "42",
+		"acl.mgmt.ingress":      "eth-1-1 eth-2-1",
+		"acl.other.egress":      "eth-1-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := c["mgmt"]
+	if seqs := a.Seqs(); len(seqs) != 2 || seqs[0] != 10 ||
+		a.Rules[10].Hits != 42 || len(a.Ingress) != 2 {
+		t.Errorf("%+v", a)
+	}
+	for _, fields := range []map[string]string{
+		{"acl.x.rule.0": "permit"},
+		{"acl.x.y.rule.1": "permit"},
+		{"acl.x.rule.1": "drop"},
+		{"acl.x.ingress": "eth-1-1", "acl.y.ingress": "eth-1-1"},
+	} {
+		if _, err := Parse(fields); err == nil {
+			t.Errorf("%v: expected error", fields)
+		}
+	}
+}
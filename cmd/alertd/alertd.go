@@ -0,0 +1,210 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package alertd provides a daemon that evaluates threshold rules of the
+// published redis values, e.g. temperatures, drops, and CRC errors, then
+// publishes the alarm states and posts their changes to the event bus.
+package alertd
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/event"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+// DefaultInterval of rule evaluation
+const DefaultInterval = 10 * time.Second
+
+const alertPrefix = "alert."
+
+var logger = log.New("alertd")
+
+type Command struct {
+	Interval time.Duration
+	Rules    []*Rule
+
+	pub *publisher.Publisher
+	// published alert states by field
+	published map[string]string
+}
+
+func (*Command) String() string { return "alertd" }
+
+func (*Command) Usage() string { return "alertd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "threshold alerting daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Evaluate the configured rules of the published redis values at each
+	interval and publish the state of each rule and matching field as,
+		alert.RULE.FIELD: ok|pending|firing
+
+	A rule's KEY is a field or a glob of fields, e.g. "*.rx-crc-errors";
+	OP is one of: > >= < <= == !=. With "rate: true", the value compared
+	is the per second rate of the counter. An alert is pending while its
+	condition holds for less than the FOR duration, then it's firing.
+
+	The changes to and from firing are posted to the event bus as
+	alert.firing, with the rule's severity, and alert.cleared events;
+	eventd dispatches these to its webhooks.
+
+FILES
+	/etc/goes/machine.yaml
+		alertd:
+		  interval: 10s
+		  rules:
+		    - name: cpu-hot
+		      key: sensor.cpu
+		      op: ">"
+		      threshold: 90
+		      for: 30s
+		      severity: crit
+		    - name: crc
+		      key: "*.rx-crc-errors"
+		      op: ">"
+		      threshold: 0
+		      rate: true
+		      severity: warn
+
+SEE ALSO
+	eventd, events, sensorsd`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err := c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if err := redis.IsReady(); err != nil {
+		return err
+	}
+	pub, err := publisher.New()
+	if err != nil {
+		return err
+	}
+	defer pub.Close()
+	c.pub = pub
+	c.published = make(map[string]string)
+	// clear the states of a prior alertd
+	c.pub.Print("delete: ", alertPrefix)
+	if len(c.Rules) == 0 {
+		logger.Warn("no rules")
+		<-goes.Stop
+		return nil
+	}
+	e := NewEvaluator(c.Rules...)
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		c.eval(e)
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) error {
+	var err error
+	if c.Interval == 0 {
+		c.Interval = DefaultInterval
+	}
+	c.Interval, err = cfg.Duration("alertd.interval", c.Interval)
+	if err != nil {
+		return err
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("alertd.interval: %v: invalid", c.Interval)
+	}
+	for _, i := range cfg.Keys("alertd.rules") {
+		prefix := "alertd.rules." + i + "."
+		r := &Rule{
+			Name:     cfg.String(prefix+"name", ""),
+			Key:      cfg.String(prefix+"key", ""),
+			Op:       cfg.String(prefix+"op", ">"),
+			Severity: cfg.String(prefix+"severity", "warn"),
+		}
+		if r.Rate, err = cfg.Bool(prefix+"rate", false); err != nil {
+			return err
+		}
+		s := cfg.String(prefix+"threshold", "0")
+		if r.Threshold, err = strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("%sthreshold: %q isn't a number",
+				prefix, s)
+		}
+		if r.For, err = cfg.Duration(prefix+"for", 0); err != nil {
+			return err
+		}
+		if _, err = log.ParseLevel(r.Severity); err != nil {
+			return fmt.Errorf("%sseverity: %v", prefix, err)
+		}
+		if err = r.Validate(); err != nil {
+			return err
+		}
+		c.Rules = append(c.Rules, r)
+	}
+	return nil
+}
+
+func (c *Command) eval(e *Evaluator) {
+	fields, err := redis.Hgetall(redis.DefaultHash, "")
+	if err != nil {
+		logger.Err("hgetall", "err", err)
+		return
+	}
+	for _, t := range e.Eval(time.Now(), fields) {
+		r, value := t.Rule, strconv.FormatFloat(t.Value, 'g', -1, 64)
+		switch {
+		case t.To == Firing:
+			event.Post("alert.firing", r.Severity,
+				fmt.Sprint(r.Name, ": ", t.Field, " ", value, " ",
+					r.Op, " ", r.Threshold),
+				"rule", r.Name, "field", t.Field, "value", value)
+		case t.From == Firing:
+			event.Post("alert.cleared", "info",
+				fmt.Sprint(r.Name, ": ", t.Field, " cleared"),
+				"rule", r.Name, "field", t.Field, "value", value)
+		}
+	}
+	states := make(map[string]string)
+	for name, alerts := range e.Alerts {
+		for field, a := range alerts {
+			states[alertPrefix+name+"."+field] = string(a.State)
+		}
+	}
+	for k, v := range states {
+		if pv, found := c.published[k]; !found || pv != v {
+			c.pub.Print(k, ": ", v)
+			c.published[k] = v
+		}
+	}
+	for k := range c.published {
+		if _, found := states[k]; !found {
+			c.pub.Print("delete: ", k)
+			delete(c.published, k)
+		}
+	}
+}
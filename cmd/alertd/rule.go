@@ -0,0 +1,184 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package alertd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Rule of published values, e.g.
+//
+//	{Name: "cpu-hot", Key: "sensor.cpu", Op: ">", Threshold: 90,
+//		For: 30 * time.Second, Severity: "crit"}
+//	{Name: "crc", Key: "*.rx-crc-errors", Op: ">", Threshold: 0,
+//		Rate: true, Severity: "warn"}
+type Rule struct {
+	Name string
+	// Key is the field or a glob of fields, e.g. "eth-*.rx-packets"
+	Key string
+	// Op is one of: > >= < <= == !=
+	Op        string
+	Threshold float64
+	// With Rate, the value is the per second rate of the counter field.
+	Rate bool
+	// For is how long the condition holds before firing.
+	For time.Duration
+	// Severity of the firing event, a log level name.
+	Severity string
+}
+
+// State of an alert
+type State string
+
+const (
+	Ok      State = "ok"
+	Pending State = "pending"
+	Firing  State = "firing"
+)
+
+var ops = map[string]func(a, b float64) bool{
+	">":  func(a, b float64) bool { return a > b },
+	">=": func(a, b float64) bool { return a >= b },
+	"<":  func(a, b float64) bool { return a < b },
+	"<=": func(a, b float64) bool { return a <= b },
+	"==": func(a, b float64) bool { return a == b },
+	"!=": func(a, b float64) bool { return a != b },
+}
+
+// Validate the rule.
+func (r *Rule) Validate() error {
+	if len(r.Name) == 0 {
+		return fmt.Errorf("rule name: missing")
+	}
+	if len(r.Key) == 0 {
+		return fmt.Errorf("%s: key: missing", r.Name)
+	}
+	if _, err := path.Match(r.Key, ""); err != nil {
+		return fmt.Errorf("%s: key: %v", r.Name, err)
+	}
+	if _, found := ops[r.Op]; !found {
+		return fmt.Errorf("%s: %q: unknown op", r.Name, r.Op)
+	}
+	return nil
+}
+
+// Alert is the state of a rule for one matching field.
+type Alert struct {
+	Rule  *Rule
+	Field string
+	State State
+	Value float64
+	// Since is the time that the condition first held
+	Since time.Time
+
+	last     float64
+	lastTime time.Time
+}
+
+// Transition of an alert to or from Firing.
+type Transition struct {
+	*Alert
+	From, To State
+}
+
+// Evaluator has the alerts of each rule by field.
+type Evaluator struct {
+	Rules  []*Rule
+	Alerts map[string]map[string]*Alert
+}
+
+// NewEvaluator of the valid rules.
+func NewEvaluator(rules ...*Rule) *Evaluator {
+	return &Evaluator{
+		Rules:  rules,
+		Alerts: make(map[string]map[string]*Alert),
+	}
+}
+
+// Eval the rules of the published fields at the given time returning the
+// changed alerts; those of deleted fields return to Ok then are removed.
+func (e *Evaluator) Eval(now time.Time, fields map[string]string) []Transition {
+	var transitions []Transition
+	for _, r := range e.Rules {
+		alerts := e.Alerts[r.Name]
+		if alerts == nil {
+			alerts = make(map[string]*Alert)
+			e.Alerts[r.Name] = alerts
+		}
+		for field, s := range fields {
+			if matched, _ := path.Match(r.Key, field); !matched {
+				continue
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				continue
+			}
+			a, found := alerts[field]
+			if !found {
+				a = &Alert{Rule: r, Field: field, State: Ok}
+				alerts[field] = a
+			}
+			if t, changed := a.eval(now, v); changed {
+				transitions = append(transitions, t)
+			}
+		}
+		for field, a := range alerts {
+			if _, found := fields[field]; !found {
+				delete(alerts, field)
+				if a.State != Ok {
+					transitions = append(transitions,
+						Transition{a, a.State, Ok})
+					a.State = Ok
+				}
+			}
+		}
+	}
+	sort.Slice(transitions, func(i, j int) bool {
+		if transitions[i].Rule.Name != transitions[j].Rule.Name {
+			return transitions[i].Rule.Name <
+				transitions[j].Rule.Name
+		}
+		return transitions[i].Field < transitions[j].Field
+	})
+	return transitions
+}
+
+func (a *Alert) eval(now time.Time, v float64) (Transition, bool) {
+	value := v
+	if a.Rule.Rate {
+		first := a.lastTime.IsZero()
+		dt := now.Sub(a.lastTime).Seconds()
+		last := a.last
+		a.last, a.lastTime = v, now
+		if first || dt <= 0 || v < last {
+			// no rate of the first sample or a reset counter
+			return Transition{}, false
+		}
+		value = (v - last) / dt
+	}
+	a.Value = value
+	from := a.State
+	switch {
+	case !ops[a.Rule.Op](value, a.Rule.Threshold):
+		a.State = Ok
+		a.Since = time.Time{}
+	case a.State == Ok:
+		a.Since = now
+		a.State = Pending
+		if a.Rule.For <= 0 {
+			a.State = Firing
+		}
+	case a.State == Pending && now.Sub(a.Since) >= a.Rule.For:
+		a.State = Firing
+	}
+	if a.State == from {
+		return Transition{}, false
+	}
+	return Transition{a, from, a.State}, true
+}
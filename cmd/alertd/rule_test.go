@@ -0,0 +1,68 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package alertd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	hot := &Rule{Name: "hot", Key: "sensor.cpu", Op: ">", Threshold: 90,
+		For: 20 * time.Second, Severity: "crit"}
+	crc := &Rule{Name: "crc", Key: "*.rx-crc-errors", Op: ">",
+		Rate: true, Severity: "warn"}
+	for _, r := range []*Rule{hot, crc} {
+		if err := r.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := (&Rule{Name: "x", Key: "y", Op: "=>"}).Validate(); err == nil {
+		t.Error("invalid op")
+	}
+	e := NewEvaluator(hot, crc)
+	t0 := time.Unix(1000, 0)
+	for i, x := range []struct {
+		cpu, crc1, crc2 string
+		want            []string
+	}{
+		{"95", "10", "5", []string{"hot sensor.cpu ok pending"}},
+		{"95", "10", "15", []string{
+			"crc eth-2.rx-crc-errors ok firing"}},
+		{"96", "10", "", []string{
+			"crc eth-2.rx-crc-errors firing ok",
+			"hot sensor.cpu pending firing"}},
+		{"80", "10", "", []string{"hot sensor.cpu firing ok"}},
+	} {
+		fields := map[string]string{
+			"sensor.cpu":            x.cpu,
+			"eth-1.rx-crc-errors":   x.crc1,
+			"eth-1.link":            "up",
+			"sensor.cpu.alarm":      "ok",
+			"eth-1.rx-crc-errors.x": "not a number",
+		}
+		if len(x.crc2) > 0 {
+			fields["eth-2.rx-crc-errors"] = x.crc2
+		}
+		now := t0.Add(time.Duration(i) * 10 * time.Second)
+		var got []string
+		for _, tr := range e.Eval(now, fields) {
+			got = append(got, tr.Rule.Name+" "+tr.Field+" "+
+				string(tr.From)+" "+string(tr.To))
+		}
+		if len(got) != len(x.want) {
+			t.Fatalf("%d: got %q want %q", i, got, x.want)
+		}
+		for j := range got {
+			if got[j] != x.want[j] {
+				t.Errorf("%d: got %q want %q", i, got[j], x.want[j])
+			}
+		}
+	}
+	if a := e.Alerts["crc"]["eth-1.rx-crc-errors"]; a == nil ||
+		a.State != Ok {
+		t.Errorf("eth-1: %+v", a)
+	}
+}
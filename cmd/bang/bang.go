@@ -19,8 +19,8 @@ import (
 	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/external/flags"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/url"
 	"github.com/platinasystems/goes/lang"
-	"github.com/platinasystems/url"
 )
 
 var tmpDir string
@@ -13,6 +13,7 @@ import (
 	"strconv"
 
 	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/imgverify"
 	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/lang"
 )
@@ -22,7 +23,7 @@ type Command struct{}
 func (Command) String() string { return "biosupdate" }
 
 func (Command) Usage() string {
-	return "biosupdate [-h|-V|[-s <slot>][-E|(-r|-w|-v) <file>]"
+	return "biosupdate [-h|-V|[-s <slot>][-E|(-r|-w|-v) <file>] [-insecure]"
 }
 
 func (Command) Apropos() lang.Alt {
@@ -41,9 +42,14 @@ DESCRIPTION
 	  -v | --verify <file>               Verify BIOS image against <file>
 	  -E | --erase                       Erase BIOS image from flash memory
 	  -s | --spi <num>                   Select SPI 0 or 1
+	  -insecure                          Write an unverified image
 
 	You can specify one of -h, -V, -E, -r, -w, -v or no operation.
-	If no operation is specified, then the programmer will be tested.`,
+	If no operation is specified, then the programmer will be tested.
+
+	Before writing, the image must be verified by its signed sha256
+	manifest, as with "goes image verify", unless the audit logged
+	-insecure override of development builds.`,
 	}
 }
 
@@ -56,7 +62,7 @@ const (
 )
 
 func (Command) Main(args ...string) (err error) {
-	flag, args := flags.New(args, "-E")
+	flag, args := flags.New(args, "-E", "-insecure")
 	parm, args := parms.New(args, "-r", "-w", "-v", "-s")
 
 	var spinum uint64
@@ -98,7 +104,10 @@ func (Command) Main(args ...string) (err error) {
 	case op_read:
 		err = doRead(parm.ByName["-r"], uint(spinum))
 	case op_write:
-		err = doWrite(parm.ByName["-w"], uint(spinum))
+		err = imgverify.Check(parm.ByName["-w"], flag.ByName["-insecure"])
+		if err == nil {
+			err = doWrite(parm.ByName["-w"], uint(spinum))
+		}
 	case op_erase:
 		err = doErase(uint(spinum))
 	case op_verify:
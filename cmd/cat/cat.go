@@ -7,10 +7,9 @@ package cat
 import (
 	"io"
 	"os"
-	"syscall"
 
+	"github.com/platinasystems/goes/internal/url"
 	"github.com/platinasystems/goes/lang"
-	"github.com/platinasystems/url"
 )
 
 type Command struct{}
@@ -58,7 +57,7 @@ func (Command) Main(args ...string) error {
 			}
 			io.Copy(os.Stdout, f)
 			f.Close()
-			syscall.Fsync(int(os.Stdout.Fd()))
+			os.Stdout.Sync()
 			if err != nil {
 				return err
 			}
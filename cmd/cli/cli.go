@@ -5,11 +5,12 @@
 package cli
 
 import (
-	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/signal"
+	"os/user"
+	"strings"
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
@@ -17,9 +18,11 @@ import (
 	"github.com/platinasystems/goes/cmd/cli/internal/notliner"
 	"github.com/platinasystems/goes/cmd/resize"
 	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/internal/shellutils"
+	"github.com/platinasystems/goes/internal/url"
 	"github.com/platinasystems/goes/lang"
-	"github.com/platinasystems/url"
 )
 
 type parsedCommand struct {
@@ -28,21 +31,25 @@ type parsedCommand struct {
 }
 
 type Command struct {
-	Prompt       string
-	g            *goes.Goes
-	promptString string
-	prompter     interface {
-		Prompt(string) (string, error)
-		Close()
-	}
+	Prompt   string
+	g        *goes.Goes
+	prompter prompter
+	// that of the interactive cli that sourced a script, if any
+	interactive prompter
+	// interactive lines read since the last added to history
+	pending        []string
 	Stdin          io.Reader
 	Stdout, Stderr io.Writer
+
+	// name and number of the line last read, for syntax errors
+	name   string
+	lineno int
 }
 
 func (*Command) String() string { return "cli" }
 
 func (*Command) Usage() string {
-	return "cli [-x] [-p PROMPT] [URL]"
+	return "cli [-x] [-i] [-p PROMPT] [-remote [USER@]HOST[:PORT]] [URL]"
 }
 
 func (*Command) Apropos() lang.Alt {
@@ -72,6 +79,15 @@ DESCRIPTION
 	With 'URL', commands are sourced from the reference instead of prompted
 	tty input.
 
+SYNTAX ERRORS
+	A syntax error is reported with its script, line, and column followed
+	by the offending line, e.g.:
+		boot.goes:14:7: unexpected ')'
+			echo a)
+			      ^
+	This ends a script unless run with '-f', whereas the interactive cli
+	discards the rest of the line and prompts for the next.
+
 COMMENTS
 	Hash tag prefaced comments are ignored, e.g.:
 		mount -t tmpfs none /tmp # scratch
@@ -133,6 +149,47 @@ SPECIAL CHARACTERS
 	
 		ä 本 日本語
 
+BRACES
+	An argument with an unquoted brace group of comma separated words,
+	or a {X..Y[..INCR]} sequence of integers or letters, is replaced by
+	an argument of each before variable, arithmetic, and pathname
+	expansion, e.g.:
+		ip link del eth-{1..32}-{1..4}
+		echo {a,b}.{c,d} {08..10} {z..x}
+	prints,
+		a.c a.d b.c b.d 08 09 10 z y x
+	Quoted or escaped braces, and those of a group without a comma or
+	valid sequence, remain as is.
+
+HOME DIRECTORIES
+	An unquoted '~' at the start of an argument or assignment value, up
+	to the first '/', is replaced by $HOME or, with ~USER, the home
+	directory of that user, e.g.:
+		cat ~/.ssh/id_ed25519.pub
+		ls ~admin
+		PATH=~/bin:/usr/bin
+	It remains as is if quoted or USER isn't found.
+
+PATHNAMES
+	An argument with an unquoted '*', '?', or '[...]' is replaced by the
+	sorted pathnames that it matches, e.g.:
+		rm /var/log/*.old
+		ls /sys/class/net/eth-[0-9]*
+	'*' matches any, and '?' one, character other than '/'; '[...]'
+	matches one of the bracketed characters or ranges, or, with '[!...]',
+	one that isn't. Quoted or escaped characters match themselves, and
+	an argument without matches remains as is, e.g.:
+		echo '*' \* "*.none"
+
+ARITHMETIC
+	An argument may include the 64 bit integer value of an expression of
+	C operators, numbers, and variables, e.g.:
+		PORT=$((PORT + 1))
+		echo eth-$((PORT / 4 + 1))-$((PORT % 4))
+	A variable name, with or without '$', has its integer value or 0 if
+	unset. The expression can't assign variables; see "go doc
+	shellutils.Arith" for its operators.
+
 OPTIONS
 	These common options manipluate the CLI command context.
 
@@ -154,6 +211,58 @@ OPTIONS
 	Note: unlike other shells, there must be a space or equal ('=')
 	between the redirection symbols and URL or LABEL.
 
+URLS
+	A URL may be a file or one of these:
+		http://HOST/PATH
+		https://HOST/PATH
+		tftp://HOST/PATH
+		scp://[USER@]HOST[:PORT]/PATH
+		sftp://[USER@]HOST[:PORT]/PATH
+		s3://BUCKET/KEY
+
+	The scp and sftp URLs authenticate with keys of the ssh-agent at
+	$SSH_AUTH_SOCK or ~/.ssh/id_ed25519, id_ecdsa, and id_rsa; the host
+	must be in ~/.ssh/known_hosts or /etc/ssh/ssh_known_hosts. A PATH
+	beginning with /~/ is relative to the remote home.
+
+	The s3 URLs are signed with $AWS_ACCESS_KEY_ID,
+	$AWS_SECRET_ACCESS_KEY, and $AWS_SESSION_TOKEN, if set, for
+	$AWS_REGION, default us-east-1; $AWS_ENDPOINT_URL, if set, is that
+	of another object store, e.g. http://minio:9000.
+
+	Output can't append to tftp, scp, or s3 URLs, just replace these,
+	e.g.:
+		source scp://server/etc/goes/cfg
+		cat /etc/goes/start > tftp://server/start
+
+RECOVERY
+	A syntax or command error ends a script unless run with -f, to just
+	print the error and continue, or -i. With -i, the cli prints the
+	script name and line of the error then prompts commands from its
+	standard input, rather than the script, until "resume", to continue
+	with the script's next line, or "abort". These commands run with the
+	script's variables and functions so one may inspect or fix these
+	before it resumes, e.g.:
+		goes> source -i /etc/goes/cfg
+		/etc/goes/cfg:12: ip: link: eth-1-1: not found
+		recover: enter commands then "resume" or "abort"
+		recover> echo $PORT
+		recover> PORT=eth-1-0
+		recover> resume
+
+REMOTE
+	With '-remote HOST', or 'goes -remote HOST', the cli runs each
+	command line with the goes sshd of another machine. Editing, history,
+	and the completion of its commands are those of this cli so one may
+	hop between units without a shell on each, e.g.:
+		goes -remote admin@switch-1
+		switch-1> show version
+
+	The connection authenticates like an scp URL. Each command line runs
+	in a separate remote session, so variables and the working directory
+	don't persist from one to the next. The 'exit' command ends the remote
+	cli rather than that of the host.
+
 PIPES
 	The COMMAND output may be piped to the input of another COMMAND, e.g.:
 		ls -lR | more
@@ -165,29 +274,85 @@ PIPES
 
 		cat <<- EOF | wc -l > lines.txt
 			...
-		EOF`,
+		EOF
+
+JOBS
+	A command list ending with '&' runs in the background while the cli
+	prompts the next, e.g.:
+		goes> ping -c 100 10.0.0.1 > /tmp/ping.txt &
+		[1] ping -c 100 10.0.0.1 > /tmp/ping.txt
+	The job runs with a copy of the cli variables, so its assignments
+	don't change those of the cli. Its forked commands are in their own
+	process group, so these aren't interrupted with the foreground
+	commands, and they stop, rather than take the input of the cli, if
+	these read the terminal; so, redirect their input.
+
+	These builtins manage the jobs; %N, or N, selects that of the job
+	number, default the latest.
+		jobs [-l]	list jobs with their state and, with -l, pids
+		fg [%N]		continue the job, if stopped, and wait for it
+		bg [%N]		continue a stopped job in the background
+
+	The interactive cli reports the jobs that finished before its next
+	prompt, e.g.:
+		[1] Done	ping -c 100 10.0.0.1 > /tmp/ping.txt`,
 	}
 }
 
 func (c *Command) Goes(g *goes.Goes) { c.g = g }
 
-func (c *Command) Read(p []byte) (n int, err error) {
-	s, err := c.prompter.Prompt(c.promptString)
+// ReadLine prompts the next line of a script or interactive command.
+func (c *Command) ReadLine(prompt string) (string, error) {
+	s, err := c.prompter.Prompt(prompt)
 	if err != nil {
-		return
+		return "", err
+	}
+	c.lineno++
+	c.pending = append(c.pending, s)
+	return s, nil
+}
+
+type prompter interface {
+	Prompt(string) (string, error)
+	Close()
+}
+
+type historian interface {
+	AddHistory(string)
+}
+
+// AddHistory records the line for recall by an interactive prompter.
+func (c *Command) AddHistory(line string) {
+	if h, ok := c.prompter.(historian); ok {
+		h.AddHistory(line)
 	}
-	n = copy(p, s)
-	if len(s) > len(p) {
-		err = errors.New("input too long")
+}
+
+// SetCompleter replaces the line completion of an interactive prompter.
+func (c *Command) SetCompleter(f func(string) []string) {
+	if l, ok := c.prompter.(*liner.Liner); ok {
+		l.Completer = f
 	}
-	return
 }
 
-func (c *Command) Write(p []byte) (n int, err error) {
-	c.promptString = string(p)
-	return len(c.promptString), nil
+// history adds the lines read since the last command list to that of an
+// interactive prompter and returns these.
+func (c *Command) history() []string {
+	lines := append([]string(nil), c.pending...)
+	for _, s := range c.pending {
+		c.AddHistory(s)
+	}
+	c.pending = c.pending[:0]
+	return lines
 }
 
+// Raw returns the standard input and output of the cli.
+func (c *Command) Raw() (io.Reader, io.Writer) { return c.Stdin, c.Stdout }
+
+// Position returns the script name, if any, and number of the line last
+// read for the errors of shellutils.Parse.
+func (c *Command) Position() (string, int) { return c.name, c.lineno }
+
 func (c *Command) Main(args ...string) error {
 	var (
 		err      error
@@ -198,14 +363,19 @@ func (c *Command) Main(args ...string) error {
 		panic("cli's goes is nil")
 	}
 
+	// e.g. that of another goes embedded in the program
+	defer func(stdin io.Reader, stdout, stderr io.Writer) {
+		c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+	}(c.Stdin, c.Stdout, c.Stderr)
+	stdin, stdout, stderr := c.g.Stdio()
 	if c.Stdin == nil {
-		c.Stdin = os.Stdin
+		c.Stdin = stdin
 	}
 	if c.Stdout == nil {
-		c.Stdout = os.Stdout
+		c.Stdout = stdout
 	}
 	if c.Stderr == nil {
-		c.Stderr = os.Stderr
+		c.Stderr = stderr
 	}
 	csig := make(chan os.Signal, 1)
 	signal.Notify(csig, os.Interrupt)
@@ -225,20 +395,38 @@ func (c *Command) Main(args ...string) error {
 		}
 	}()
 
-	flag, args := flags.New(args, "-f", "-x", "-", "-no-liner")
+	// e.g. source SCRIPT from the interactive cli
+	defer func(name string, lineno int, prompter, interactive prompter) {
+		c.name, c.lineno = name, lineno
+		c.prompter, c.interactive = prompter, interactive
+	}(c.name, c.lineno, c.prompter, c.interactive)
+	c.name, c.lineno = "", 0
+
+	flag, args := flags.New(args, "-f", "-x", "-i", "-", "-no-liner")
+	parm, args := parms.New(args, "-remote")
+	var r *remote
+	if host := parm.ByName["-remote"]; len(host) > 0 {
+		if r, err = dialRemote(host); err != nil {
+			return err
+		}
+		defer r.Close()
+	}
 	switch len(args) {
 	case 0:
 		switch {
 		case flag.ByName["-"]:
 			c.prompter = notliner.New(c.Stdin, nil)
 			isScript = true
-		case flag.ByName["-no-liner"]:
+			c.name = "-"
+		case flag.ByName["-no-liner"], c.Stdin != os.Stdin:
 			c.prompter = notliner.New(c.Stdin, c.Stdout)
+			c.interactive = c.prompter
 		default:
 			if _, found := c.g.ByName["resize"]; !found {
 				c.g.ByName["resize"] = resize.Command{}
 			}
 			c.prompter = liner.New(c.g)
+			c.interactive = c.prompter
 			defer c.prompter.Close()
 		}
 	case 1:
@@ -250,15 +438,22 @@ func (c *Command) Main(args ...string) error {
 		c.prompter = notliner.New(script, nil)
 		defer c.prompter.Close()
 		isScript = true
+		c.name = args[0]
 	default:
 		return fmt.Errorf("%v: unexpected", args[1:])
 	}
 
+	// only a script file may recover with commands from stdin
+	recovers := flag.ByName["-i"] && len(args) == 1
+
 	if flag.ByName["-f"] && c.g.Verbosity < goes.VerboseVerify {
 		c.g.Verbosity = goes.VerboseVerify
 	}
-	if c.g.Catline == nil {
-		c.g.Catline = c
+	if c.g.Terminal == nil {
+		c.g.Terminal = c
+	}
+	if r != nil {
+		c.SetCompleter(r.complete)
 	}
 readCommandLoop:
 	for {
@@ -267,8 +462,13 @@ readCommandLoop:
 			fmt.Println("\nCommand interrupted")
 		default:
 		}
+		if !isScript {
+			c.g.ReportJobs(c.Stderr)
+		}
 		prompt := c.Prompt
-		if len(prompt) == 0 {
+		if len(prompt) == 0 && r != nil {
+			prompt = fmt.Sprint(r.host, "> ")
+		} else if len(prompt) == 0 {
 			prompt = fmt.Sprint(c.g, "> ")
 			if len(c.g.Path()) == 0 {
 				if hn, err := os.Hostname(); err == nil {
@@ -276,23 +476,54 @@ readCommandLoop:
 				}
 			}
 		}
-		cl, err := shellutils.Parse(prompt, c.g.Catline)
+		cl, err := shellutils.Parse(prompt, c.g)
+		lines := c.history()
 		if err != nil {
 			if err == io.EOF {
 				return nil
 			}
-			fmt.Fprintln(c.Stderr, err)
+			// a syntax error ends a script, unless forced or
+			// recovered, whereas the interactive cli continues with
+			// the next line
 			if isScript && !flag.ByName["-f"] {
-				return nil
+				if !recovers {
+					return err
+				}
+				if err = c.recoverFrom(err, flag); err != nil {
+					return err
+				}
+				continue readCommandLoop
 			}
+			fmt.Fprintln(c.Stderr, err)
 			continue readCommandLoop
 		}
-		err = c.runList(*cl, flag, isScript)
+		start := goes.GetRusage()
+		if r != nil {
+			if r.isExit(cl) {
+				return nil
+			} else if len(cl.Cmds) > 0 {
+				err = r.run(c, cl, lines)
+			}
+		} else {
+			err = c.runList(*cl, flag, isScript)
+		}
+		if len(cl.Cmds) > 0 {
+			c.g.ReportStats(c.Stderr, cl.String(), start)
+		}
+		c.history() // e.g. here documents
+		if !isScript {
+			audit(cl, err)
+		}
 		if err != nil {
 			if isScript && !flag.ByName["-f"] {
-				return err
+				if !recovers {
+					return err
+				}
+				if err = c.recoverFrom(err, flag); err != nil {
+					return err
+				}
 			} else {
-				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(c.Stderr, err)
 			}
 		}
 	}
@@ -316,3 +547,29 @@ func (c *Command) runList(ls shellutils.List, flag *flags.Flags, isScript bool)
 	}
 	return nil
 }
+
+// audit the interactive command list with its user, remote address, and
+// status.
+func audit(ls *shellutils.List, err error) {
+	if len(ls.Cmds) == 0 {
+		return
+	}
+	name := os.Getenv("USER")
+	if len(name) == 0 {
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		} else {
+			name = fmt.Sprint(os.Getuid())
+		}
+	}
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+	}
+	kv := []interface{}{"user", name, "command", ls.String(),
+		"status", status}
+	if remote := strings.Fields(os.Getenv("SSH_CLIENT")); len(remote) > 0 {
+		kv = append(kv, "remote", remote[0])
+	}
+	log.Audit.Note("command", kv...)
+}
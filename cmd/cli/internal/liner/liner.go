@@ -12,8 +12,6 @@ import (
 	"fmt"
 	"os"
 	"strings"
-	"syscall"
-	"unsafe"
 
 	"github.com/mattn/go-isatty"
 
@@ -28,6 +26,10 @@ import (
 const woliner = false
 
 type Liner struct {
+	// Completer returns the completions of the line; the default is
+	// that of the goes "complete" command.
+	Completer func(line string) []string
+
 	history struct {
 		buf   *bytes.Buffer
 		lines []string
@@ -46,6 +48,7 @@ func New(g *goes.Goes) *Liner {
 		l.fallback = notliner.New(os.Stdin, os.Stdout)
 	}
 	l.goes = g
+	l.Completer = l.complete
 	return l
 }
 
@@ -53,7 +56,32 @@ func (l *Liner) Close() {
 }
 
 // Returns all completions of the given command line.
-func (l *Liner) complete(line string) (lines []string) {
+func (l *Liner) complete(line string) []string {
+	return Completions(line, func(args ...string) (list []string) {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			return
+		}
+		go func() {
+			t := os.Stdout
+			defer func() { os.Stdout = t }()
+			os.Stdout = pw
+			l.goes.Main(append([]string{"complete"}, args...)...)
+			pw.Close()
+		}()
+		prs := bufio.NewScanner(pr)
+		for prs.Scan() {
+			list = append(list, prs.Text())
+		}
+		pr.Close()
+		return
+	})
+}
+
+// Completions returns the lines completed with those of the last argument
+// of its last pipeline returned by the complete function, e.g. that of a
+// remote goes.
+func Completions(line string, complete func(args ...string) []string) (lines []string) {
 	lsi := strings.LastIndex(line, " ")
 	pl := pizza.New("|")
 	defer pl.Reset()
@@ -62,26 +90,13 @@ func (l *Liner) complete(line string) (lines []string) {
 		return
 	}
 	args := pl.Slices[len(pl.Slices)-1]
-	pr, pw, err := os.Pipe()
-	if err != nil {
-		return
-	}
-	go func() {
-		t := os.Stdout
-		defer func() { os.Stdout = t }()
-		os.Stdout = pw
-		l.goes.Main(append([]string{"complete"}, args...)...)
-		pw.Close()
-	}()
-	prs := bufio.NewScanner(pr)
-	for prs.Scan() {
+	for _, s := range complete(args...) {
 		if lsi < 1 {
-			lines = append(lines, prs.Text())
+			lines = append(lines, s)
 		} else {
-			lines = append(lines, line[:lsi+1]+prs.Text())
+			lines = append(lines, line[:lsi+1]+s)
 		}
 	}
-	pr.Close()
 	if len(lines) == 1 {
 		lines[0] += " "
 	}
@@ -107,7 +122,7 @@ func (l *Liner) help(line string) {
 func (l *Liner) Prompt(prompt string) (string, error) {
 	if l.fallback == nil {
 		l.s = liner.NewLiner()
-		l.s.SetCompleter(l.complete)
+		l.s.SetCompleter(l.Completer)
 		l.s.SetHelper(l.help)
 		defer func() {
 			ll := l.s
@@ -117,47 +132,19 @@ func (l *Liner) Prompt(prompt string) (string, error) {
 	} else {
 		return l.fallback.Prompt(prompt)
 	}
-	var t syscall.Termios
-	if isatty.IsTerminal(uintptr(syscall.Stdin)) {
-		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
-			uintptr(syscall.Stdin),
-			uintptr(syscall.TCGETS),
-			uintptr(unsafe.Pointer(&t)))
-		if errno != 0 {
-			return "", fmt.Errorf("TCGETS: %v", errno)
-		}
-
-		it := t
-		defer func() {
-			it.Iflag &^= syscall.BRKINT
-			it.Iflag |= syscall.ICRNL | syscall.IXON
-			it.Lflag |= syscall.ISIG | syscall.IEXTEN |
-				syscall.ICANON | syscall.ECHO
-			syscall.Syscall(syscall.SYS_IOCTL,
-				uintptr(syscall.Stdin),
-				uintptr(syscall.TCSETS),
-				uintptr(unsafe.Pointer(&it)))
-		}()
-
-		t.Iflag |= syscall.BRKINT
-		t.Iflag |= syscall.IMAXBEL
-		t.Iflag |= syscall.IUTF8
-		t.Lflag &^= syscall.IEXTEN
-
-		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL,
-			uintptr(syscall.Stdin),
-			uintptr(syscall.TCSETS),
-			uintptr(unsafe.Pointer(&t)))
-		if errno != 0 {
-			return "", fmt.Errorf("TCSETS: %v", errno)
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		restore, err := l.raw()
+		if err != nil {
+			return "", err
 		}
+		defer restore()
 
-		status := l.goes.Status
-		err := l.goes.Main("resize")
+		status := l.goes.Status()
+		err = l.goes.Main("resize")
 		if err != nil {
 			return "", err
 		}
-		l.goes.Status = status
+		l.goes.SetStatus(status)
 	}
 
 	if len(l.history.lines) > 0 {
@@ -180,17 +167,23 @@ func (l *Liner) Prompt(prompt string) (string, error) {
 
 	line, err := l.s.Prompt(prompt)
 
-	if err == nil {
-		if len(l.history.lines) < cap(l.history.lines) {
-			l.history.lines = append(l.history.lines, line)
-		} else {
-			l.history.lines[l.history.i] = line
-		}
-		l.history.i++
-		l.history.i &= cap(l.history.lines) - 1
-	} else if err == liner.ErrNotTerminalOutput {
+	if err == liner.ErrNotTerminalOutput {
 		l.fallback = notliner.New(os.Stdin, os.Stdout)
 		line, err = l.fallback.Prompt(prompt)
 	}
 	return line, err
 }
+
+// AddHistory records the line for recall by subsequent prompts.
+func (l *Liner) AddHistory(line string) {
+	if len(line) == 0 {
+		return
+	}
+	if len(l.history.lines) < cap(l.history.lines) {
+		l.history.lines = append(l.history.lines, line)
+	} else {
+		l.history.lines[l.history.i] = line
+	}
+	l.history.i++
+	l.history.i &= cap(l.history.lines) - 1
+}
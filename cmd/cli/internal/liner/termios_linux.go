@@ -0,0 +1,51 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package liner
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// raw sets the stdin termios for the line editor and returns the function
+// that restores the cooked mode of the commands.
+func (l *Liner) raw() (func(), error) {
+	var t syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL,
+		uintptr(syscall.Stdin),
+		uintptr(syscall.TCGETS),
+		uintptr(unsafe.Pointer(&t)))
+	if errno != 0 {
+		return nil, fmt.Errorf("TCGETS: %v", errno)
+	}
+
+	it := t
+	restore := func() {
+		it.Iflag &^= syscall.BRKINT
+		it.Iflag |= syscall.ICRNL | syscall.IXON
+		it.Lflag |= syscall.ISIG | syscall.IEXTEN |
+			syscall.ICANON | syscall.ECHO
+		syscall.Syscall(syscall.SYS_IOCTL,
+			uintptr(syscall.Stdin),
+			uintptr(syscall.TCSETS),
+			uintptr(unsafe.Pointer(&it)))
+	}
+
+	t.Iflag |= syscall.BRKINT
+	t.Iflag |= syscall.IMAXBEL
+	t.Iflag |= syscall.IUTF8
+	t.Lflag &^= syscall.IEXTEN
+
+	_, _, errno = syscall.Syscall(syscall.SYS_IOCTL,
+		uintptr(syscall.Stdin),
+		uintptr(syscall.TCSETS),
+		uintptr(unsafe.Pointer(&t)))
+	if errno != 0 {
+		restore()
+		return nil, fmt.Errorf("TCSETS: %v", errno)
+	}
+	return restore, nil
+}
@@ -0,0 +1,10 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build !linux
+
+package liner
+
+// raw leaves the terminal modes to the line editor of other systems.
+func (l *Liner) raw() (func(), error) { return func() {}, nil }
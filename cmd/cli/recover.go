@@ -0,0 +1,64 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd/cli/internal/notliner"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/internal/shellutils"
+)
+
+// recoverFrom the error of a script run with -i by prompting commands from
+// the interactive cli that sourced it, or the standard input, rather than
+// the script, until "resume" or "abort". These run in the script's context so one may inspect or fix
+// its variables before it resumes with the line after the error. This
+// returns nil to resume or the error to abort.
+func (c *Command) recoverFrom(err error, flag *flags.Flags) error {
+	var syntax *shellutils.SyntaxError
+	if errors.As(err, &syntax) {
+		fmt.Fprintln(c.Stderr, err)
+	} else {
+		name, lineno := c.g.Position()
+		fmt.Fprintf(c.Stderr, "%s:%d: %v\n", name, lineno, err)
+	}
+	fmt.Fprintln(c.Stderr, `recover: enter commands then "resume" or "abort"`)
+
+	defer func(name string, lineno int, p prompter, t goes.Terminal) {
+		c.name, c.lineno, c.prompter, c.g.Terminal = name, lineno, p, t
+	}(c.name, c.lineno, c.prompter, c.g.Terminal)
+	c.name, c.lineno = "", 0
+	if c.interactive == nil {
+		// kept for the next error of the script
+		c.interactive = notliner.New(c.Stdin, c.Stdout)
+	}
+	c.prompter = c.interactive
+	c.g.Terminal = c
+	for {
+		ls, perr := shellutils.Parse("recover> ", c.g)
+		c.history()
+		if perr == io.EOF {
+			return err
+		} else if perr != nil {
+			fmt.Fprintln(c.Stderr, perr)
+			continue
+		}
+		if len(ls.Cmds) == 1 && len(ls.Cmds[0].Cmds) == 1 {
+			switch ls.Cmds[0].Cmds[0].String() {
+			case "resume":
+				return nil
+			case "abort":
+				return err
+			}
+		}
+		if rerr := c.runList(*ls, flag, false); rerr != nil {
+			fmt.Fprintln(c.Stderr, rerr)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	neturl "net/url"
+	"strings"
+
+	"github.com/platinasystems/goes/cmd/cli/internal/liner"
+	"github.com/platinasystems/goes/internal/shellutils"
+	"github.com/platinasystems/goes/internal/url"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// remote runs the command lines edited by this cli with the goes sshd of
+// another machine. Each runs in its own session of the one connection, so,
+// variables and the working directory don't persist from one line to the
+// next.
+type remote struct {
+	host   string
+	client *ssh.Client
+}
+
+func dialRemote(host string) (*remote, error) {
+	u, err := neturl.Parse("ssh://" + host)
+	if err != nil {
+		return nil, err
+	}
+	client, err := url.SSHDial(u)
+	if err != nil {
+		return nil, err
+	}
+	return &remote{host: u.Hostname(), client: client}, nil
+}
+
+func (r *remote) Close() error { return r.client.Close() }
+
+// isExit is true if the command list is just "exit".
+func (r *remote) isExit(ls *shellutils.List) bool {
+	return len(ls.Cmds) == 1 && len(ls.Cmds[0].Cmds) > 0 &&
+		ls.Cmds[0].Cmds[0].String() == "exit"
+}
+
+// run the parsed command list of the given lines with a remote "cli -";
+// an error exit is reported by the remote cli, so this just returns that
+// status.
+func (r *remote) run(c *Command, ls *shellutils.List, lines []string) error {
+	lines, err := r.heredocs(c, ls, lines)
+	if err != nil {
+		return err
+	}
+	session, err := r.client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	session.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+	session.Stdout = c.Stdout
+	session.Stderr = c.Stderr
+	err = session.Run("cli -")
+	if xerr, ok := err.(*ssh.ExitError); ok {
+		return fmt.Errorf("%s: exit status %d", r.host, xerr.ExitStatus())
+	}
+	return err
+}
+
+// heredocs appends the lines of each here document of the command list as
+// these are read by the cli rather than its parser.
+func (r *remote) heredocs(c *Command, ls *shellutils.List, lines []string) ([]string, error) {
+	for _, cl := range ls.Cmds {
+		for i, w := range cl.Cmds {
+			s := w.String()
+			if !strings.HasPrefix(s, "<<") {
+				continue
+			}
+			lbl := strings.TrimLeft(strings.TrimPrefix(s, "<<"), "-=")
+			if len(lbl) == 0 && i+1 < len(cl.Cmds) {
+				lbl = cl.Cmds[i+1].String()
+			}
+			if len(lbl) == 0 {
+				continue
+			}
+			for {
+				s, err := c.ReadLine("<<" + lbl + " ")
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, s)
+				if s == lbl {
+					break
+				}
+			}
+		}
+	}
+	return lines, nil
+}
+
+// complete the line with those of the remote goes.
+func (r *remote) complete(line string) []string {
+	return liner.Completions(line, func(args ...string) []string {
+		session, err := r.client.NewSession()
+		if err != nil {
+			return nil
+		}
+		defer session.Close()
+		quoted := make([]string, 0, 1+len(args))
+		quoted = append(quoted, "complete")
+		for _, arg := range args {
+			quoted = append(quoted,
+				"'"+strings.Replace(arg, "'", `'\''`, -1)+"'")
+		}
+		b, err := session.Output(strings.Join(quoted, " "))
+		if err != nil && len(b) == 0 {
+			return nil
+		}
+		var list []string
+		scan := bufio.NewScanner(bytes.NewReader(b))
+		for scan.Scan() {
+			list = append(list, scan.Text())
+		}
+		return list
+	})
+}
@@ -0,0 +1,188 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package clock provides an SNTP client and a command to show the clock
+// synchronization status published by clockd or to synchronize now.
+package clock
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	Config
+}
+
+// Config of clock and clockd
+type Config struct {
+	// Servers of SNTP queries, default: pool.ntp.org
+	Servers []string
+	// Interval between clockd queries, default: 64s
+	Interval time.Duration
+	// Step the clock if the offset exceeds this; otherwise slew,
+	// default: 128ms
+	Step time.Duration
+	// Timeout of each query, default: 2s
+	Timeout time.Duration
+	// Vrf device of the queries, default: none
+	Vrf string
+}
+
+// Fields published by clockd
+var Fields = []string{
+	"clock.synced",
+	"clock.server",
+	"clock.stratum",
+	"clock.refid",
+	"clock.offset",
+	"clock.delay",
+	"clock.last",
+}
+
+func (*Command) String() string { return "clock" }
+
+func (*Command) Usage() string {
+	return `clock [-json] [show]
+clock sync [-n] [-vrf NAME] [SERVER]...`
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or synchronize the system clock",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	clock [-json] [show]
+		print the system time and the synchronization status
+		published by clockd
+
+	clock sync [-n] [-vrf NAME] [SERVER]...
+		query the given, or configured, SNTP servers then step or
+		slew the system clock by the offset of the least delayed;
+		with -n, just print the offset; with -vrf, query through
+		the routing table of the VRF device
+
+FILES
+	/etc/goes/machine.yaml
+		clock:
+		  servers: [0.pool.ntp.org, 1.pool.ntp.org]
+		  interval: 64s
+		  step: 128ms
+		  timeout: 2s
+		  vrf: mgmt
+
+SEE ALSO
+	clockd`,
+	}
+}
+
+func (c *Command) Main(args ...string) error {
+	if err := c.Configure(machine.Default()); err != nil {
+		return err
+	}
+	if len(args) > 0 && args[0] == "sync" {
+		return c.sync(args[1:]...)
+	}
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	m := map[string]string{
+		"time": time.Now().Format(time.RFC3339Nano),
+	}
+	for _, field := range Fields {
+		if s, err := redis.Hget(redis.DefaultHash, field); err == nil &&
+			len(s) > 0 {
+			m[field[len("clock."):]] = s
+		}
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(m, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	fmt.Println("time:", m["time"])
+	for _, field := range Fields {
+		name := field[len("clock."):]
+		if s, found := m[name]; found {
+			fmt.Print(name, ": ", s, "\n")
+		}
+	}
+	return nil
+}
+
+func (c *Command) sync(args ...string) error {
+	flag, args := flags.New(args, "-n")
+	parm, args := parms.New(args, "-vrf")
+	if s := parm.ByName["-vrf"]; len(s) > 0 {
+		c.Vrf = s
+	}
+	if len(args) > 0 {
+		c.Servers = args
+	}
+	r, err := Best(c.Servers, c.Vrf, c.Timeout)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: stratum %d refid %s offset %.6fs delay %.6fs\n",
+		r.Server, r.Stratum, r.RefId, r.Offset.Seconds(),
+		r.Delay.Seconds())
+	if flag.ByName["-n"] {
+		return nil
+	}
+	stepped, err := Adjust(r.Offset, c.Step)
+	if err != nil {
+		return err
+	}
+	if stepped {
+		fmt.Println("stepped")
+	} else {
+		fmt.Println("slewed")
+	}
+	return nil
+}
+
+// Configure from clock.* of the machine configuration then apply defaults.
+func (c *Config) Configure(cfg *machine.Config) (err error) {
+	c.Servers = cfg.Strings("clock.servers", c.Servers)
+	c.Vrf = cfg.String("clock.vrf", c.Vrf)
+	if len(c.Servers) == 0 {
+		c.Servers = []string{"pool.ntp.org"}
+	}
+	for _, x := range []struct {
+		d    *time.Duration
+		name string
+		def  time.Duration
+	}{
+		{&c.Interval, "clock.interval", 64 * time.Second},
+		{&c.Step, "clock.step", 128 * time.Millisecond},
+		{&c.Timeout, "clock.timeout", 2 * time.Second},
+	} {
+		if *x.d, err = cfg.Duration(x.name, *x.d); err != nil {
+			return
+		}
+		if *x.d <= 0 {
+			*x.d = x.def
+		}
+	}
+	return
+}
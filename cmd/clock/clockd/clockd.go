@@ -0,0 +1,130 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package clockd provides an SNTP client daemon that disciplines the
+// system clock and publishes its synchronization status.
+package clockd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/clock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	clock.Config
+
+	pub *publisher.Publisher
+	// the last query failed
+	failed bool
+}
+
+func (*Command) String() string { return "clockd" }
+
+func (*Command) Usage() string { return "clockd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "SNTP client daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Periodically query the configured SNTP servers and adjust the system
+	clock by the offset of the least delayed, stepping if it exceeds
+	clock.step; otherwise, slewing. Then publish,
+		clock.synced: true|false
+		clock.server: SERVER
+		clock.stratum: STRATUM
+		clock.refid: ID
+		clock.offset: SECONDS
+		clock.delay: SECONDS
+		clock.last: RFC3339 time of the last adjustment
+
+	clockd logs each step and the loss and recovery of synchronization.
+
+	With clock.vrf, the queries use the routing table of that VRF
+	device, e.g. that of the management port.
+
+FILES
+	/etc/goes/machine.yaml
+		clock:
+		  servers: [0.pool.ntp.org, 1.pool.ntp.org]
+		  interval: 64s
+		  step: 128ms
+		  vrf: mgmt
+
+SEE ALSO
+	clock`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.Configure(machine.Default()); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("clock.synced: false")
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		c.sync()
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Command) sync() {
+	r, err := clock.Best(c.Servers, c.Vrf, c.Timeout)
+	if err == nil {
+		var stepped bool
+		if stepped, err = clock.Adjust(r.Offset, c.Step); stepped &&
+			err == nil {
+			log.Print("daemon", "info", "stepped ", r.Offset, " per ",
+				r.Server)
+		}
+	}
+	if err != nil {
+		if !c.failed {
+			log.Print("daemon", "err", err)
+			c.pub.Print("clock.synced: false")
+		}
+		c.failed = true
+		return
+	}
+	if c.failed {
+		log.Print("daemon", "info", "synchronized with ", r.Server)
+		c.failed = false
+	}
+	c.pub.Print("clock.synced: true")
+	c.pub.Print("clock.server: ", r.Server)
+	c.pub.Print("clock.stratum: ", r.Stratum)
+	c.pub.Print("clock.refid: ", r.RefId)
+	c.pub.Printf("clock.offset: %.6f", r.Offset.Seconds())
+	c.pub.Printf("clock.delay: %.6f", r.Delay.Seconds())
+	c.pub.Print("clock.last: ", time.Now().Format(time.RFC3339))
+}
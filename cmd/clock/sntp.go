@@ -0,0 +1,165 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package clock
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/platinasystems/goes/cmd/vrf"
+)
+
+// seconds from the NTP epoch, 1900, to the Unix epoch
+const ntpEpoch = 2208988800
+
+// Port of NTP servers
+var Port = "123"
+
+// Response of an SNTP server
+type Response struct {
+	Server  string
+	Stratum uint8
+	RefId   string
+	// Offset of the server from the local clock
+	Offset time.Duration
+	// Delay of the round trip less the server's processing time
+	Delay time.Duration
+}
+
+// Query the SNTPv4 server, within the named VRF if any, for the local
+// clock's offset and round trip delay.
+func Query(server, vrfName string, timeout time.Duration) (*Response,
+	error) {
+	addr := server
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, Port)
+	}
+	conn, err := vrf.Dialer(vrfName, timeout).Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := make([]byte, 48)
+	// leap indicator 0, version 4, client mode
+	req[0] = 0<<6 | 4<<3 | 3
+	t1 := time.Now()
+	binary.BigEndian.PutUint64(req[40:], toNtp(t1))
+	if _, err = conn.Write(req); err != nil {
+		return nil, err
+	}
+	rsp := make([]byte, 48)
+	for {
+		n, err := conn.Read(rsp)
+		t4 := time.Now()
+		if err != nil {
+			return nil, err
+		}
+		if n < 48 || rsp[0]&7 != 4 ||
+			binary.BigEndian.Uint64(rsp[24:]) != toNtp(t1) {
+			// not a server response to this request
+			continue
+		}
+		return decode(server, rsp, t1, t4)
+	}
+}
+
+func decode(server string, rsp []byte, t1, t4 time.Time) (*Response, error) {
+	r := &Response{
+		Server:  server,
+		Stratum: rsp[1],
+	}
+	if r.Stratum == 0 {
+		return nil, fmt.Errorf("%s: kiss of death: %s", server,
+			refString(rsp[12:16], 1))
+	}
+	if rsp[0]>>6 == 3 {
+		return nil, fmt.Errorf("%s: unsynchronized", server)
+	}
+	r.RefId = refString(rsp[12:16], r.Stratum)
+	t2 := fromNtp(binary.BigEndian.Uint64(rsp[32:]))
+	t3 := fromNtp(binary.BigEndian.Uint64(rsp[40:]))
+	r.Offset = (t2.Sub(t1) + t3.Sub(t4)) / 2
+	r.Delay = t4.Sub(t1) - t3.Sub(t2)
+	if r.Delay < 0 {
+		r.Delay = 0
+	}
+	return r, nil
+}
+
+// Best returns the least delayed response of the servers.
+func Best(servers []string, vrfName string, timeout time.Duration) (*Response,
+	error) {
+	var best *Response
+	var errs []error
+	for _, server := range servers {
+		r, err := Query(server, vrfName, timeout)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if best == nil || r.Delay < best.Delay {
+			best = r
+		}
+	}
+	if best == nil {
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no servers")
+		}
+		return nil, errs[0]
+	}
+	return best, nil
+}
+
+// Adjust the system clock by the offset; step if its magnitude exceeds
+// the threshold, otherwise slew.
+func Adjust(offset, threshold time.Duration) (stepped bool, err error) {
+	if offset > threshold || offset < -threshold {
+		tv := syscall.NsecToTimeval(time.Now().Add(offset).UnixNano())
+		return true, syscall.Settimeofday(&tv)
+	}
+	// ADJ_OFFSET_SINGLESHOT of linux/timex.h, like adjtime(3)
+	tx := syscall.Timex{Modes: 0x8001}
+	usec := offset.Microseconds()
+	// Offset is an int32 or int64 by arch
+	switch unsafe.Sizeof(tx.Offset) {
+	case 8:
+		*(*int64)(unsafe.Pointer(&tx.Offset)) = usec
+	case 4:
+		*(*int32)(unsafe.Pointer(&tx.Offset)) = int32(usec)
+	}
+	_, err = syscall.Adjtimex(&tx)
+	return false, err
+}
+
+func toNtp(t time.Time) uint64 {
+	sec := uint64(t.Unix() + ntpEpoch)
+	frac := uint64(t.Nanosecond()) << 32 / 1e9
+	return sec<<32 | frac
+}
+
+func fromNtp(u uint64) time.Time {
+	sec := int64(u>>32) - ntpEpoch
+	nsec := int64((u & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(sec, nsec)
+}
+
+// refString formats the ASCII reference identifier of stratum 0 and 1
+// servers; otherwise, that of the IPv4 upstream.
+func refString(b []byte, stratum uint8) string {
+	if stratum > 1 {
+		return net.IP(b).String()
+	}
+	n := 0
+	for n < len(b) && b[n] != 0 {
+		n++
+	}
+	return string(b[:n])
+}
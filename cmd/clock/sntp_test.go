@@ -0,0 +1,56 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package clock
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestQuery(t *testing.T) {
+	const ahead = 3 * time.Second
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+	go func() {
+		b := make([]byte, 48)
+		n, from, err := conn.ReadFrom(b)
+		if err != nil || n < 48 {
+			return
+		}
+		rsp := make([]byte, 48)
+		rsp[0] = 4<<3 | 4
+		rsp[1] = 1
+		copy(rsp[12:], "GPS")
+		copy(rsp[24:32], b[40:48])
+		now := toNtp(time.Now().Add(ahead))
+		binary.BigEndian.PutUint64(rsp[32:], now)
+		binary.BigEndian.PutUint64(rsp[40:], now)
+		conn.WriteTo(rsp, from)
+	}()
+	r, err := Query(conn.LocalAddr().String(), "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Stratum != 1 || r.RefId != "GPS" {
+		t.Error("stratum", r.Stratum, "refid", r.RefId)
+	}
+	if d := r.Offset - ahead; d > 100*time.Millisecond ||
+		d < -100*time.Millisecond {
+		t.Error("offset", r.Offset)
+	}
+}
+
+func TestNtp(t *testing.T) {
+	want := time.Date(2020, 2, 29, 12, 0, 0, 500000000, time.UTC)
+	if got := fromNtp(toNtp(want)); got.Sub(want) > time.Microsecond ||
+		want.Sub(got) > time.Microsecond {
+		t.Error(got, "vs.", want)
+	}
+}
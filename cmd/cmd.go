@@ -21,5 +21,6 @@ type Cmd interface {
 	Help(...string) string
 	Kind() Kind
 	Man() lang.Alt
+	Reload() error
 	*/
 }
@@ -0,0 +1,537 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/goes/cmd/daemons"
+	"github.com/platinasystems/goes/cmd/port"
+	"github.com/platinasystems/goes/cmd/route"
+	"github.com/platinasystems/goes/cmd/sensorsd"
+	"github.com/platinasystems/goes/cmd/vlan"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/url"
+)
+
+// Change of a redis field from its current to desired value; an empty To
+// deletes the field. Those of goes.daemon.NAME.state start or stop the
+// daemon.
+type Change struct {
+	Field, From, To string
+}
+
+func (c Change) String() string {
+	switch {
+	case len(c.From) == 0:
+		return fmt.Sprint("+ ", c.Field, ": ", c.To)
+	case len(c.To) == 0:
+		return fmt.Sprint("- ", c.Field, ": ", c.From)
+	}
+	return fmt.Sprint("~ ", c.Field, ": ", c.From, " -> ", c.To)
+}
+
+// Plan of the changes in the order they're applied.
+type Plan []Change
+
+// desired state sections of the apply file, in the order applied
+type section struct {
+	// Key of the file's section
+	Key string
+	// Prefix of the current state's redis fields
+	Prefix string
+	// Owns, if not nil, selects those of the current fields that are
+	// removed if not in the section; otherwise, the section just sets
+	// the listed fields.
+	Owns func(field string) bool
+	// Fields of the section's node
+	Fields func(node interface{}) (map[string]string, error)
+	// Validate the fields as a whole, if not nil
+	Validate func(fields map[string]string) error
+}
+
+var sections = []section{
+	{
+		Key:    "interfaces",
+		Prefix: port.Prefix,
+		Owns:   func(string) bool { return true },
+		Fields: interfaceFields,
+		Validate: func(fields map[string]string) error {
+			_, err := port.Parse(fields)
+			return err
+		},
+	},
+	{
+		Key:    "vlans",
+		Prefix: vlan.Prefix,
+		Owns: func(field string) bool {
+			return field != vlan.BridgeField &&
+				!strings.HasPrefix(field, vlan.Prefix+"stp.")
+		},
+		Fields: vlanFields,
+		Validate: func(fields map[string]string) error {
+			_, err := vlan.Parse(fields)
+			return err
+		},
+	},
+	{
+		Key:    "stp",
+		Prefix: vlan.Prefix + "stp.",
+		Owns:   func(string) bool { return true },
+		Fields: func(node interface{}) (map[string]string, error) {
+			return scalars(node, vlan.Prefix+"stp.")
+		},
+		Validate: func(fields map[string]string) error {
+			_, err := vlan.Parse(fields)
+			return err
+		},
+	},
+	{
+		Key:    "routes",
+		Prefix: route.Prefix,
+		Owns:   func(string) bool { return true },
+		Fields: routeFields,
+		Validate: func(fields map[string]string) error {
+			_, err := route.Parse(fields)
+			return err
+		},
+	},
+	{
+		Key:    "sensors",
+		Prefix: sensorsd.Prefix,
+		Fields: sensorFields,
+	},
+	{
+		Key: "fields",
+		Fields: func(node interface{}) (map[string]string, error) {
+			return scalars(node, "")
+		},
+		Validate: func(fields map[string]string) error {
+			for field := range fields {
+				if !Settable(field) {
+					return fmt.Errorf("%s: isn't settable", field)
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Key:    "daemons",
+		Prefix: "goes.daemon.",
+		Fields: daemonFields,
+	},
+}
+
+// Desired is a parsed apply file of the sections' fields.
+type Desired map[string]map[string]string
+
+// ParseDesired state of the apply file, e.g.
+//
+//	interfaces:
+//	  xeth1:
+//	    speed: 100g
+//	    mtu: 9000
+//	    description: spine1
+//	vlans:
+//	  10:
+//	    tagged: [xeth1, xeth2]
+//	    untagged: [xeth3]
+//	stp:
+//	  xeth1: forwarding
+//	routes:
+//	  default: via 10.0.0.1
+//	  10.2.0.0/16:
+//	  - via 10.0.0.2 metric 10
+//	  - via 10.0.0.3 metric 10
+//	sensors:
+//	  cpu:
+//	    max: 90
+//	fields:
+//	  lag.bond0.members: xeth1 xeth2
+//	daemons:
+//	  frrd: running
+//	  snmpd: stopped
+func ParseDesired(r io.Reader) (Desired, error) {
+	cfg, err := machine.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	d := make(Desired)
+	for _, s := range sections {
+		known[s.Key] = true
+		node, found := cfg.Lookup(s.Key)
+		if !found {
+			continue
+		}
+		fields, err := s.Fields(node)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", s.Key, err)
+		}
+		if s.Validate != nil {
+			if err = s.Validate(fields); err != nil {
+				return nil, fmt.Errorf("%s: %v", s.Key, err)
+			}
+		}
+		d[s.Key] = fields
+	}
+	for _, k := range cfg.Keys("") {
+		if !known[k] {
+			return nil, fmt.Errorf("%s: unknown section", k)
+		}
+	}
+	return d, nil
+}
+
+// Plan the changes from the current fields of each section's prefix to
+// those desired.
+func (d Desired) Plan(current func(prefix string) (map[string]string,
+	error)) (Plan, error) {
+	var plan Plan
+	for _, s := range sections {
+		desired, found := d[s.Key]
+		if !found {
+			continue
+		}
+		cur, err := current(s.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		if s.Key == "daemons" {
+			plan = append(plan, daemonChanges(desired, cur)...)
+			continue
+		}
+		var dels, mods, adds Plan
+		for field, to := range desired {
+			from := cur[field]
+			switch {
+			case from == to:
+			case len(from) == 0:
+				adds = append(adds, Change{field, from, to})
+			case len(to) == 0:
+				dels = append(dels, Change{field, from, to})
+			default:
+				mods = append(mods, Change{field, from, to})
+			}
+		}
+		if s.Owns != nil {
+			for field, from := range cur {
+				_, listed := desired[field]
+				if !listed && len(from) > 0 && s.Owns(field) {
+					dels = append(dels, Change{field, from, ""})
+				}
+			}
+		}
+		// removals first so that, e.g., an untagged port may move
+		// from one vlan to another
+		for _, changes := range []Plan{dels, mods, adds} {
+			sort.Slice(changes, func(i, j int) bool {
+				return changes[i].Field < changes[j].Field
+			})
+			plan = append(plan, changes...)
+		}
+	}
+	return plan, nil
+}
+
+// Apply the plan, stopping at the first error.
+func (plan Plan) Apply() error {
+	for _, c := range plan {
+		var err error
+		if name, ok := daemonOf(c.Field); ok {
+			err = daemonRPC(name, c.To)
+		} else {
+			_, err = redis.Hset(redis.DefaultHash, c.Field, c.To)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", c.Field, err)
+		}
+	}
+	return nil
+}
+
+func apply(u string, dryrun bool) error {
+	r, err := url.Open(u)
+	if err != nil {
+		return err
+	}
+	d, err := ParseDesired(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %v", u, err)
+	}
+	plan, err := d.Plan(currentState)
+	if err != nil {
+		return err
+	}
+	for _, c := range plan {
+		fmt.Println(c)
+	}
+	if dryrun || len(plan) == 0 {
+		return nil
+	}
+	log.Audit.Note("applying config", "url", u, "changes", len(plan))
+	return plan.Apply()
+}
+
+// currentState of the published fields with the prefix.
+func currentState(prefix string) (map[string]string, error) {
+	return redis.Hgetall(redis.DefaultHash, prefix)
+}
+
+func interfaceFields(node interface{}) (map[string]string, error) {
+	m, err := mapping(node)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for name, v := range m {
+		var args []string
+		switch t := v.(type) {
+		case string:
+			args = strings.Fields(t)
+		case map[string]interface{}:
+			for k := range t {
+				if !has(port.Attrs, k) {
+					return nil, fmt.Errorf("%s: %s: unknown",
+						name, k)
+				}
+			}
+			// description last as it's the remaining args
+			for _, attr := range port.Attrs {
+				if s, ok := t[attr].(string); ok {
+					args = append(args, attr, s)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%s: invalid", name)
+		}
+		p, err := port.ParsePort(args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		fields[port.Prefix+name] = p.String()
+	}
+	return fields, nil
+}
+
+func vlanFields(node interface{}) (map[string]string, error) {
+	m, err := mapping(node)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for k, v := range m {
+		vid, err := vlan.ParseVid(k)
+		if err != nil {
+			return nil, err
+		}
+		members, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%d: invalid", vid)
+		}
+		for kind, ports := range members {
+			if kind != "tagged" && kind != "untagged" {
+				return nil, fmt.Errorf("%d: %s: unknown", vid, kind)
+			}
+			l, err := list(ports)
+			if err != nil {
+				return nil, fmt.Errorf("%d: %s: %v", vid, kind, err)
+			}
+			if len(l) > 0 {
+				fields[fmt.Sprint(vlan.Prefix, vid, ".", kind)] =
+					strings.Join(l, " ")
+			}
+		}
+	}
+	return fields, nil
+}
+
+func routeFields(node interface{}) (map[string]string, error) {
+	m, err := mapping(node)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for k, v := range m {
+		var specs []string
+		if s, ok := v.(string); ok {
+			specs = strings.Split(s, ",")
+		} else if specs, err = list(v); err != nil {
+			return nil, fmt.Errorf("%s: %v", k, err)
+		}
+		var hops []route.Nexthop
+		for _, spec := range specs {
+			nh, err := route.ParseNexthop(strings.Fields(spec)...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", k, err)
+			}
+			hops = append(hops, nh)
+		}
+		inet6 := len(hops) > 0 && hops[0].Gateway != nil &&
+			hops[0].Gateway.To4() == nil
+		ipnet, err := route.ParsePrefix(k, inet6)
+		if err != nil {
+			return nil, err
+		}
+		fields[route.Prefix+ipnet.String()] = route.Format(hops)
+	}
+	return fields, nil
+}
+
+func sensorFields(node interface{}) (map[string]string, error) {
+	m, err := mapping(node)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for name, v := range m {
+		limits, err := scalars(v, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		for limit, s := range limits {
+			if limit != "min" && limit != "max" && limit != "crit" {
+				return nil, fmt.Errorf("%s: %s: unknown", name,
+					limit)
+			}
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %q isn't a number",
+					name, limit, s)
+			}
+			fields[sensorsd.Prefix+name+"."+limit] =
+				strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	}
+	return fields, nil
+}
+
+func daemonFields(node interface{}) (map[string]string, error) {
+	states, err := scalars(node, "")
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for name, state := range states {
+		if state != string(daemons.StateRunning) &&
+			state != string(daemons.StateStopped) {
+			return nil, fmt.Errorf("%s: %q: should be %s or %s",
+				name, state, daemons.StateRunning,
+				daemons.StateStopped)
+		}
+		fields[daemons.StateField(name)] = state
+	}
+	return fields, nil
+}
+
+// daemonChanges of those not already in, or headed to, the desired state.
+func daemonChanges(desired, cur map[string]string) Plan {
+	var plan Plan
+	for field, to := range desired {
+		from := daemons.State(cur[field])
+		switch from {
+		case daemons.StateRunning, daemons.StateWaiting,
+			daemons.StateBackoff:
+			if to == string(daemons.StateRunning) {
+				continue
+			}
+		default:
+			if to == string(daemons.StateStopped) {
+				continue
+			}
+			if len(from) == 0 {
+				from = daemons.StateStopped
+			}
+		}
+		plan = append(plan, Change{field, string(from), to})
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Field < plan[j].Field
+	})
+	return plan
+}
+
+func daemonOf(field string) (string, bool) {
+	if !strings.HasPrefix(field, "goes.daemon.") ||
+		!strings.HasSuffix(field, ".state") {
+		return "", false
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(field, "goes.daemon."),
+		".state"), true
+}
+
+func daemonRPC(name, state string) error {
+	cl, err := atsock.NewRpcClient(daemons.Sockname())
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	method := "Daemons.Start"
+	if state == string(daemons.StateStopped) {
+		method = "Daemons.Stop"
+	}
+	return cl.Call(method, []string{name}, &struct{}{})
+}
+
+func mapping(node interface{}) (map[string]interface{}, error) {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("should be a map")
+	}
+	return m, nil
+}
+
+// scalars of the node's map, with the prefixed keys
+func scalars(node interface{}, prefix string) (map[string]string, error) {
+	m, err := mapping(node)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for k, v := range m {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: should be a value", k)
+		}
+		fields[prefix+k] = s
+	}
+	return fields, nil
+}
+
+// list of a scalar, whitespace separated, or list node
+func list(node interface{}) ([]string, error) {
+	switch t := node.(type) {
+	case string:
+		return strings.Fields(t), nil
+	case []interface{}:
+		l := make([]string, 0, len(t))
+		for _, v := range t {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("should be a list of values")
+			}
+			l = append(l, s)
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("should be a list")
+}
+
+func has(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+const desired = `
+interfaces:
+  xeth1:
+    mtu: 9000
+    speed: 100G
+    description: to spine1
+vlans:
+  10:
+    tagged: [xeth1, xeth2]
+  20:
+    untagged: xeth3
+routes:
+  default: via 10.0.0.1
+  10.2.0.0/16:
+  - via 10.0.0.2 metric 10
+  - via 10.0.0.3 metric 10
+sensors:
+  cpu:
+    max: 90.0
+daemons:
+  frrd: running
+  snmpd: stopped
+`
+
+func TestPlan(t *testing.T) {
+	d, err := ParseDesired(strings.NewReader(desired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := map[string]string{
+		"port.xeth1":              "speed 100g mtu 9000",
+		"port.xeth2":              "mtu 1500",
+		"vlan.bridge":             "br0",
+		"vlan.10.untagged":        "xeth3",
+		"vlan.stp.xeth1":          "forwarding",
+		"route.0.0.0.0/0":         "via 10.0.0.1",
+		"sensor.cpu.max":          "95",
+		"sensor.cpu.crit":         "100",
+		"goes.daemon.frrd.state":  "backoff",
+		"goes.daemon.snmpd.state": "running",
+	}
+	get := func(prefix string) (map[string]string, error) {
+		m := make(map[string]string)
+		for k, v := range current {
+			if strings.HasPrefix(k, prefix) {
+				m[k] = v
+			}
+		}
+		return m, nil
+	}
+	plan, err := d.Plan(get)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range plan {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"- port.xeth2: mtu 1500",
+		"~ port.xeth1: speed 100g mtu 9000 -> " +
+			"speed 100g mtu 9000 description to spine1",
+		"- vlan.10.untagged: xeth3",
+		"+ vlan.10.tagged: xeth1 xeth2",
+		"+ vlan.20.untagged: xeth3",
+		"+ route.10.2.0.0/16: via 10.0.0.2 metric 10, " +
+			"via 10.0.0.3 metric 10",
+		"~ sensor.cpu.max: 95 -> 90",
+		"~ goes.daemon.snmpd.state: running -> stopped",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("plan:\n%s\nwant:\n%s", strings.Join(got, "\n"),
+			strings.Join(want, "\n"))
+	}
+
+	for k, v := range map[string]string{
+		"port.xeth1":       "speed 100g mtu 9000 description to spine1",
+		"port.xeth2":       "",
+		"vlan.10.untagged": "",
+		"vlan.10.tagged":   "xeth1 xeth2",
+		"vlan.20.untagged": "xeth3",
+		"route.10.2.0.0/16": "via 10.0.0.2 metric 10, " +
+			"via 10.0.0.3 metric 10",
+		"sensor.cpu.max":          "90",
+		"goes.daemon.snmpd.state": "stopped",
+	} {
+		current[k] = v
+	}
+	if plan, err = d.Plan(get); err != nil || len(plan) != 0 {
+		t.Error("reapplied:", plan, err)
+	}
+
+	for _, s := range []string{
+		"bogus:\n  x: y\n",
+		"interfaces:\n  xeth1:\n    mtu: 10\n",
+		"vlans:\n  10:\n    untagged: xeth1\n  20:\n    untagged: xeth1\n",
+		"routes:\n  10.0.0.0/8: via fe80::1\n",
+		"daemons:\n  frrd: paused\n",
+	} {
+		if _, err = ParseDesired(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: parsed", s)
+		}
+	}
+}
+
+func TestYAML(t *testing.T) {
+	fields := map[string]string{
+		"port.xeth1":                 "speed 100g mtu 9000 description to: spine1",
+		"vlan.10.tagged":             "xeth1 xeth2",
+		"vlan.20.untagged":           "xeth3",
+		"vlan.stp.xeth1":             "forwarding",
+		"route.::/0":                 "via fe80::1 dev xeth1",
+		"route.10.2.0.0/16":          "via 10.0.0.2, via 10.0.0.3",
+		"sensor.coretemp.core-0.max": "85.5",
+		"lag.bond0.members":          "xeth4 xeth5",
+	}
+	buf := new(strings.Builder)
+	if err := YAML(buf, fields); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ParseDesired(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err, "\n", buf)
+	}
+	got := make(map[string]string)
+	for _, m := range d {
+		for k, v := range m {
+			got[k] = v
+		}
+	}
+	if plan := Diff(fields, got); len(plan) > 0 {
+		t.Errorf("%v\n%s", plan, buf)
+	}
+}
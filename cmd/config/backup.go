@@ -0,0 +1,364 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package config
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes/external/imgverify"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/internal/buildinfo"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/internal/url"
+)
+
+// ManifestFormat is the version of the archive manifest written by save;
+// restore refuses archives of a later format.
+const ManifestFormat = 1
+
+// ManifestName is the first entry of the archive.
+const ManifestName = "MANIFEST"
+
+// BackupPaths are the files and directories saved to an archive, more may be
+// added by the machine or its configuration,
+//
+//	config.backup.paths:
+//	- /etc/platina/license
+var BackupPaths = []string{"/etc/goes"}
+
+// Section is a named set of path prefixes that may be selectively restored;
+// files that aren't within any are of the "etc" section.
+type Section struct {
+	Name     string
+	Prefixes []string
+}
+
+// Sections of the archive, matched in order
+var Sections = []Section{
+	{"persist", []string{persist.Dir}},
+	{"keys", []string{imgverify.KeysDir, "/etc/goes/sshd"}},
+	{"machine", []string{machine.EtcGoesMachine, "/etc/goes/start",
+		"/etc/goes/stop", "/etc/goes/init"}},
+}
+
+// Manifest of the archive
+type Manifest struct {
+	Format   int       `json:"format"`
+	Created  time.Time `json:"created"`
+	Hostname string    `json:"hostname"`
+	Version  string    `json:"version"`
+	Files    []File    `json:"files"`
+}
+
+// File of the archive; Path is absolute.
+type File struct {
+	Path    string      `json:"path"`
+	Section string      `json:"section"`
+	Size    int64       `json:"size"`
+	Mode    os.FileMode `json:"mode"`
+	Sha256  string      `json:"sha256"`
+}
+
+func sectionOf(fn string) string {
+	for _, s := range Sections {
+		for _, prefix := range s.Prefixes {
+			if within(fn, prefix) {
+				return s.Name
+			}
+		}
+	}
+	return "etc"
+}
+
+// within returns true if fn is, or is in the directory, prefix.
+func within(fn, prefix string) bool {
+	return fn == prefix || strings.HasPrefix(fn, prefix+"/")
+}
+
+func backupPaths() []string {
+	return append(append([]string{}, BackupPaths...),
+		machine.Default().Strings("config.backup.paths", nil)...)
+}
+
+// collect the regular files of the paths, sorted and without duplicates.
+func collect(paths []string) ([]File, error) {
+	seen := make(map[string]bool)
+	var files []File
+	for _, path := range paths {
+		err := filepath.Walk(filepath.Clean(path),
+			func(fn string, fi os.FileInfo, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) && fn == path {
+						return nil
+					}
+					return err
+				}
+				if !fi.Mode().IsRegular() || seen[fn] ||
+					strings.HasSuffix(fn, ".tmp") {
+					return nil
+				}
+				seen[fn] = true
+				sum, err := sha256File(fn)
+				if err != nil {
+					return err
+				}
+				files = append(files, File{
+					Path:    fn,
+					Section: sectionOf(fn),
+					Size:    fi.Size(),
+					Mode:    fi.Mode().Perm(),
+					Sha256:  sum,
+				})
+				return nil
+			})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+func sha256File(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// save a gzip'd tar archive of the paths to the url.
+func save(u string, paths []string) (*Manifest, error) {
+	files, err := collect(paths)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		Format:  ManifestFormat,
+		Created: time.Now().UTC(),
+		Version: buildinfo.New().Version(),
+		Files:   files,
+	}
+	m.Hostname, _ = os.Hostname()
+	w, err := url.Create(u)
+	if err != nil {
+		return nil, err
+	}
+	if err = writeArchive(w, m); err != nil {
+		w.Close()
+		return nil, err
+	}
+	return m, w.Close()
+}
+
+func writeArchive(w io.Writer, m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "\t")
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    ManifestName,
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: m.Created,
+	}); err != nil {
+		return err
+	}
+	if _, err = tw.Write(b); err != nil {
+		return err
+	}
+	for _, file := range m.Files {
+		if err = writeFile(tw, file); err != nil {
+			return err
+		}
+	}
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func writeFile(tw *tar.Writer, file File) error {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    strings.TrimPrefix(file.Path, "/"),
+		Mode:    int64(file.Mode),
+		Size:    file.Size,
+		ModTime: fi.ModTime(),
+	}); err != nil {
+		return err
+	}
+	// the file may have changed since its sum; restore would then
+	// reject it, so fail here instead.
+	_, err = io.CopyN(tw, f, file.Size)
+	return err
+}
+
+// restore the files of the selected sections or paths, all by default, from
+// the url archive, returning those restored or, if dryrun, those that would
+// be.
+func restore(u string, dryrun bool, selected ...string) ([]File, error) {
+	r, err := url.Open(u)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readArchive(r, backupPaths(), dryrun, selected...)
+}
+
+func readArchive(r io.Reader, paths []string, dryrun bool,
+	selected ...string) ([]File, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(zr)
+	h, err := tr.Next()
+	if err != nil {
+		return nil, err
+	}
+	if h.Name != ManifestName {
+		return nil, fmt.Errorf("%s: missing", ManifestName)
+	}
+	var m Manifest
+	if err = json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, fmt.Errorf("%s: %v", ManifestName, err)
+	}
+	if m.Format < 1 || m.Format > ManifestFormat {
+		return nil, fmt.Errorf("%s: format %d: unsupported",
+			ManifestName, m.Format)
+	}
+	byPath := make(map[string]File)
+	var files []File
+	for _, file := range m.Files {
+		if file.Path != filepath.Clean(file.Path) ||
+			!filepath.IsAbs(file.Path) || !inPaths(file.Path, paths) {
+			return nil, fmt.Errorf("%s: outside of the backup paths",
+				file.Path)
+		}
+		if isSelected(file, selected) {
+			byPath[file.Path] = file
+			files = append(files, file)
+		}
+	}
+	if dryrun {
+		return files, nil
+	}
+	// verify all of the selected files before replacing any
+	var tmps []string
+	defer func() {
+		for _, tmp := range tmps {
+			os.Remove(tmp)
+		}
+	}()
+	var restored []File
+	for {
+		h, err = tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		file, found := byPath["/"+h.Name]
+		if !found {
+			continue
+		}
+		tmp, err := extract(tr, file)
+		if tmp != "" {
+			tmps = append(tmps, tmp)
+		}
+		if err != nil {
+			return nil, err
+		}
+		delete(byPath, file.Path)
+		restored = append(restored, file)
+	}
+	for fn := range byPath {
+		return nil, fmt.Errorf("%s: missing from archive", fn)
+	}
+	for i, file := range restored {
+		if err = os.Rename(tmps[i], file.Path); err != nil {
+			return restored[:i], err
+		}
+	}
+	return restored, nil
+}
+
+func inPaths(fn string, paths []string) bool {
+	for _, path := range paths {
+		if within(fn, filepath.Clean(path)) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSelected(file File, selected []string) bool {
+	if len(selected) == 0 {
+		return true
+	}
+	for _, s := range selected {
+		if s == file.Section || within(file.Path, filepath.Clean(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// extract the file to a verified temporary file in its directory, returning
+// the name of the temporary file to rename in place.
+func extract(r io.Reader, file File) (string, error) {
+	dir := filepath.Dir(file.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(file.Path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return tmp.Name(), err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != file.Sha256 {
+		return tmp.Name(), fmt.Errorf("%s: sha256 %s mismatch of %s",
+			file.Path, sum, ManifestName)
+	}
+	return tmp.Name(), os.Chmod(tmp.Name(), file.Mode)
+}
@@ -0,0 +1,80 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	etc := filepath.Join(dir, "etc")
+	write := func(fn, s string) {
+		fn = filepath.Join(etc, fn)
+		os.MkdirAll(filepath.Dir(fn), 0755)
+		if err := ioutil.WriteFile(fn, []byte(s), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(fn string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(etc, fn))
+		return string(b)
+	}
+	write("persist/vlan", "vlan.10: xeth1\n")
+	write("machine.yaml", "redisd:\n  port: 6379\n")
+	defer func(sections []Section) { Sections = sections }(Sections)
+	Sections = append(Sections, Section{"persist",
+		[]string{filepath.Join(etc, "persist")}})
+
+	archive := filepath.Join(dir, "backup.tgz")
+	m, err := save(archive, []string{etc, filepath.Join(dir, "missing")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || m.Files[1].Section != "persist" ||
+		m.Files[1].Mode != 0600 {
+		t.Fatalf("files: %+v", m.Files)
+	}
+
+	write("persist/vlan", "changed\n")
+	write("machine.yaml", "changed\n")
+	b, _ := ioutil.ReadFile(archive)
+	files, err := readArchive(bytes.NewReader(b), []string{etc}, false,
+		"persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || read("persist/vlan") != "vlan.10: xeth1\n" ||
+		read("machine.yaml") != "changed\n" {
+		t.Errorf("restored: %+v", files)
+	}
+	if _, err = readArchive(bytes.NewReader(b), []string{dir + "/other"},
+		false); err == nil {
+		t.Error("restored outside of the backup paths")
+	}
+
+	// an archive with a bad sum replaces nothing
+	write("machine.yaml", "redisd:\n  port: 6379\n")
+	m.Files[1].Sha256 = m.Files[0].Sha256
+	buf := new(bytes.Buffer)
+	if err = writeArchive(buf, m); err != nil {
+		t.Fatal(err)
+	}
+	write("machine.yaml", "changed\n")
+	if _, err = readArchive(buf, []string{etc}, false); err == nil {
+		t.Error("restored mismatched sha256")
+	}
+	if read("machine.yaml") != "changed\n" {
+		t.Error("replaced after mismatch")
+	}
+}
@@ -0,0 +1,284 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package config provides a command to have the daemons that keep
+// persistent settings reapply these to the system, to converge these with a
+// declared state, and to save and restore an archive of these and the other
+// files of /etc/goes.
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+// Reconciler is a daemon with an RPC method that reapplies the given, or
+// all, of its settings and replies with the names of those applied,
+//
+//	func (*Xd) Reconcile(names []string, reply *[]string) error
+type Reconciler struct {
+	// Daemon and name of its atsock RPC server
+	Daemon string
+	// Method of the RPC, e.g. Portd.Reconcile
+	Method string
+}
+
+// Reconcilers called by config reconcile, in order
+var Reconcilers = []Reconciler{
+	{"portd", "Portd.Reconcile"},
+	{"neighd", "Neighd.Reconcile"},
+}
+
+func (Command) String() string { return "config" }
+
+func (Command) Usage() string {
+	return `config reconcile [NAME]...
+config apply [-n] URL
+config diff
+config save [URL]
+config restore [-n] URL [SECTION|PATH]...`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "reapply, apply, save, or restore persistent settings",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	config reconcile [NAME]...
+		have each daemon of persistent settings reapply those of the
+		given, or all, names to the system, e.g. after vnetd has
+		restarted and lost these; the daemons also do this on their
+		own when vnet becomes ready
+
+	config apply [-n] URL
+		converge the interfaces, vlans, routes, sensor limits, and
+		daemons with the desired state of the YAML file or URL
+		by way of their redis settable fields, printing the plan of
+		changes; with -n, just print the plan
+
+	config diff
+		print the changes that config save would make to the startup
+		configuration, the persisted settings that the daemons
+		restore on start, for it to be that running
+
+	config save
+		save the running configuration as that of startup; this is
+		that of the daemons' settable fields with the current MTU,
+		admin state, description, speed, and autoneg of each
+		configured port, as these may have been changed by other
+		means, e.g. "ip link set"
+
+	config save URL
+		write a gzip'd tar archive of the persistent settings, keys,
+		and other files of /etc/goes, and those of the machine's
+		config.backup.paths, to the file or URL, e.g. that of
+		s3://BUCKET/KEY or scp://HOST/PATH
+
+	config restore [-n] URL [SECTION|PATH]...
+		replace the files of the given sections or paths, or all, with
+		those of the archive; with -n, just list these
+
+	The sections of an apply file are,
+		interfaces:
+		  IFNAME:
+		    speed: SPEED
+		    fec: FEC
+		    autoneg: on|off
+		    mtu: MTU
+		    admin: up|down
+		    description: TEXT
+		vlans:
+		  VID:
+		    tagged: [PORT...]
+		    untagged: [PORT...]
+		stp:
+		  PORT: STATE
+		routes:
+		  PREFIX: NEXTHOP[, NEXTHOP]...
+		sensors:
+		  NAME:
+		    min|max|crit: VALUE
+		fields:
+		  FIELD: VALUE
+		daemons:
+		  NAME: running|stopped
+	The interfaces, vlans, stp, and routes sections are the whole of
+	their settings, so those of the current state that aren't listed are
+	removed. The sensors, fields, and daemons sections only change those
+	listed.
+	Sections that are absent are left as is, and applying the same file
+	again plans no changes.
+
+	Reconcile prints the names that each daemon reapplied. A daemon that
+	isn't running is skipped.
+
+	The archive begins with a MANIFEST of its format version, the host,
+	goes version, and the section, mode, size, and sha256 of each file.
+	Restore refuses an archive of a later format or with files outside
+	of the backup paths and doesn't replace any unless all of those
+	selected match their sha256. Restart the daemons, or reboot, to load
+	the restored settings.
+
+SECTIONS
+	persist	/etc/goes/persist, the saved redis settings
+	keys	/etc/goes/keys and /etc/goes/sshd
+	machine	/etc/goes/machine.yaml, start, stop, and init
+	etc	all other files
+
+DAEMONS
+	portd	port.IFNAME
+	neighd	neighbor.ADDRESS of the given address or IFNAME
+
+EXAMPLES
+	goes config apply -n http://10.0.0.1/config/$(hostname).yaml
+	goes config save http://10.0.0.1/backup/$(hostname).tgz
+	goes config restore -n /tmp/backup.tgz
+	goes config restore /tmp/backup.tgz persist keys
+
+SEE ALSO
+	show config, port, portd, neighbor, neighd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("reconcile, apply, save, or restore: missing")
+	}
+	switch args[0] {
+	case "reconcile":
+		return reconcile(args[1:]...)
+	case "apply":
+		flag, args := flags.New(args[1:], "-n")
+		switch len(args) {
+		case 0:
+			return fmt.Errorf("URL: missing")
+		case 1:
+		default:
+			return fmt.Errorf("%v: unexpected", args[1:])
+		}
+		return apply(args[0], flag.ByName["-n"])
+	case "diff", "save":
+		if len(args) > 2 || len(args) > 1 && args[0] == "diff" {
+			return fmt.Errorf("%v: unexpected", args[len(args)-1:])
+		}
+		if len(args) == 1 {
+			return startup(args[0] == "diff")
+		}
+		m, err := save(args[1], backupPaths())
+		if err != nil {
+			return err
+		}
+		fmt.Println("saved", len(m.Files), "files to", args[1])
+	case "restore":
+		flag, args := flags.New(args[1:], "-n")
+		if len(args) == 0 {
+			return fmt.Errorf("URL: missing")
+		}
+		files, err := restore(args[0], flag.ByName["-n"], args[1:]...)
+		for _, file := range files {
+			fmt.Print(file.Section, "\t", file.Path, "\n")
+		}
+		if len(files) > 0 && !flag.ByName["-n"] {
+			log.Audit.Note("restored config", "url", args[0],
+				"files", len(files))
+		}
+		return err
+	default:
+		return fmt.Errorf("%s: unknown", args[0])
+	}
+	return nil
+}
+
+func reconcile(names ...string) error {
+	var first error
+	for _, r := range Reconcilers {
+		applied, err := r.Reconcile(names...)
+		if err == errNotRunning {
+			continue
+		}
+		if len(applied) > 0 {
+			fmt.Print(r.Daemon, ": ", strings.Join(applied, " "), "\n")
+		}
+		if err != nil {
+			fmt.Print(r.Daemon, ": ", err, "\n")
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// startup prints the changes from the startup to the running configuration
+// then, unless just the diff, saves these.
+func startup(diff bool) error {
+	running, err := Running()
+	if err != nil {
+		return err
+	}
+	from, err := Startup()
+	if err != nil {
+		return err
+	}
+	plan := Diff(from, running)
+	for _, c := range plan {
+		fmt.Println(c)
+	}
+	if diff || len(plan) == 0 {
+		return nil
+	}
+	log.Audit.Note("saving running config", "changes", len(plan))
+	return plan.Apply()
+}
+
+var errNotRunning = fmt.Errorf("not running")
+
+// Reconcile calls the daemon's RPC method.
+func (r Reconciler) Reconcile(names ...string) ([]string, error) {
+	cl, err := atsock.NewRpcClient(r.Daemon)
+	if err != nil {
+		return nil, errNotRunning
+	}
+	defer cl.Close()
+	var applied []string
+	if names == nil {
+		names = []string{}
+	}
+	err = cl.Call(r.Method, names, &applied)
+	return applied, err
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(args) <= 1 {
+		return complete.Prefixed(last, "reconcile", "apply", "diff",
+			"save", "restore")
+	}
+	switch args[0] {
+	case "reconcile":
+		return complete.IfName(last)
+	case "restore":
+		if len(args) > 2 {
+			var names []string
+			for _, s := range Sections {
+				names = append(names, s.Name)
+			}
+			return complete.Prefixed(last, append(names, "etc")...)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"io"
+
+	redigo "github.com/garyburd/redigo/redis"
+	"github.com/platinasystems/goes/external/redis"
+)
+
+// VnetReadyField is published by vnetd as true once it has programmed the
+// switch, so a change of it to true means that vnetd has (re)started.
+const VnetReadyField = "vnet.ready"
+
+// VnetReady subscribes to the redis hash then relays each published
+// vnet.ready value until closed.
+func VnetReady() (<-chan bool, io.Closer, error) {
+	psc, err := redis.Subscribe(redis.DefaultHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	ready := make(chan bool, 4)
+	go func() {
+		prefix := []byte(VnetReadyField + ": ")
+		for {
+			switch t := psc.Receive().(type) {
+			case redigo.Message:
+				if bytes.HasPrefix(t.Data, prefix) {
+					ready <- string(t.Data[len(prefix):]) ==
+						"true"
+				}
+			case error:
+				return
+			}
+		}
+	}()
+	return ready, psc, nil
+}
+
+// IsVnetReady returns true if vnet.ready is currently true.
+func IsVnetReady() bool {
+	s, err := redis.Hget(redis.DefaultHash, VnetReadyField)
+	return err == nil && s == "true"
+}
@@ -0,0 +1,355 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package config
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/goes/cmd/acl"
+	"github.com/platinasystems/goes/cmd/frr"
+	"github.com/platinasystems/goes/cmd/lag"
+	"github.com/platinasystems/goes/cmd/port"
+	"github.com/platinasystems/goes/cmd/vlan"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/persist"
+)
+
+// Schema of the redis settable NAME.* fields that its daemon keeps in
+// persist.Dir/NAME.
+type Schema struct {
+	Name string
+	// Settable returns true if the field isn't one just published by
+	// the daemon; nil is all of NAME.*
+	Settable func(field string) bool
+	// Persisted, if true, are schemas whose settable fields can't be
+	// told from those published, so the running configuration has just
+	// the current values of those persisted.
+	Persisted bool
+}
+
+// Schemas of the running and startup configurations, in the order rendered
+var Schemas = []Schema{
+	{Name: "port"},
+	{Name: "lag", Settable: lag.Settable},
+	{Name: "vlan", Settable: func(field string) bool {
+		return field != vlan.BridgeField
+	}},
+	{Name: "route"},
+	{Name: "neighbor"},
+	{Name: "acl", Settable: acl.Settable},
+	{Name: "frr", Settable: frr.Settable},
+	{Name: "sensor", Persisted: true},
+}
+
+// Settable returns true if the field is that of a schema.
+func Settable(field string) bool {
+	for _, s := range Schemas {
+		if strings.HasPrefix(field, s.Name+".") {
+			return s.Settable == nil || s.Settable(field)
+		}
+	}
+	return false
+}
+
+// Startup configuration; the persisted settings that the daemons restore
+// on start.
+func Startup() (map[string]string, error) {
+	fields := make(map[string]string)
+	for _, s := range Schemas {
+		settings, err := persist.Load(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range settings.Fields() {
+			fields[field] = settings.Get(field)
+		}
+	}
+	return fields, nil
+}
+
+// Running configuration of the settable fields published by the daemons
+// with the current MTU, admin state, description, speed, and autoneg of
+// each configured port as these may have been changed by other means, e.g.
+// "ip link set".
+func Running() (map[string]string, error) {
+	fields := make(map[string]string)
+	var startup map[string]string
+	for _, s := range Schemas {
+		m, err := redis.Hgetall(redis.DefaultHash, s.Name+".")
+		if err != nil {
+			return nil, err
+		}
+		if s.Persisted && startup == nil {
+			if startup, err = Startup(); err != nil {
+				return nil, err
+			}
+		}
+		for field, value := range m {
+			switch {
+			case len(value) == 0:
+			case s.Persisted:
+				if _, found := startup[field]; found {
+					fields[field] = value
+				}
+			case s.Settable == nil || s.Settable(field):
+				fields[field] = value
+			}
+		}
+	}
+	for field, value := range fields {
+		if strings.HasPrefix(field, port.Prefix) {
+			fields[field] = currentPort(field[len(port.Prefix):],
+				value)
+		}
+	}
+	return fields, nil
+}
+
+// currentPort replaces the port's configured attributes with those current.
+func currentPort(name, value string) string {
+	p, err := port.ParsePort(strings.Fields(value)...)
+	if err != nil {
+		return value
+	}
+	st := port.GetStatus(name, p)
+	if st.CurMtu == 0 {
+		// no such interface, e.g. vnetd isn't running
+		return value
+	}
+	if p.Mtu != 0 {
+		p.Mtu = st.CurMtu
+	}
+	if len(p.Admin) > 0 {
+		p.Admin = st.CurAdmin
+	}
+	if len(p.Description) > 0 {
+		p.Description = port.Alias(name)
+	}
+	if len(st.Error) == 0 {
+		if p.Speed != 0 && st.Current != 0 && st.Current != ^uint32(0) {
+			p.Speed = st.Current
+		}
+		if len(p.Autoneg) > 0 {
+			p.Autoneg = st.CurAutoneg
+		}
+	}
+	return p.String()
+}
+
+// Diff returns the changes that would make the startup configuration that
+// of the running.
+func Diff(startup, running map[string]string) Plan {
+	var plan Plan
+	for field, to := range running {
+		if from := startup[field]; from != to {
+			plan = append(plan, Change{field, from, to})
+		}
+	}
+	for field, from := range startup {
+		if _, found := running[field]; !found && len(from) > 0 {
+			plan = append(plan, Change{field, from, ""})
+		}
+	}
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Field < plan[j].Field
+	})
+	return plan
+}
+
+// Script writes the fields as goes hset commands.
+func Script(w io.Writer, fields map[string]string) error {
+	for _, field := range sortedFields(fields) {
+		value := fields[field]
+		if !strings.Contains(value, "'") {
+			value = "'" + value + "'"
+		} else {
+			value = strconv.Quote(value)
+		}
+		_, err := fmt.Fprint(w, "hset ", redis.DefaultHash, " ", field,
+			" ", value, "\n")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// YAML writes the fields in the format of config apply.
+func YAML(w io.Writer, fields map[string]string) error {
+	var (
+		ports   = make(map[string]string)
+		vlans   = make(map[int]map[string]string)
+		stp     = make(map[string]string)
+		routes  = make(map[string]string)
+		sensors = make(map[string]map[string]string)
+		others  = make(map[string]string)
+	)
+	for field, value := range fields {
+		var vid int
+		var kind string
+		s := field[strings.Index(field, ".")+1:]
+		switch {
+		case strings.HasPrefix(field, port.Prefix):
+			ports[s] = value
+		case strings.HasPrefix(field, vlan.Prefix+"stp."):
+			stp[s[len("stp."):]] = value
+		case strings.HasPrefix(field, vlan.Prefix) &&
+			parseVlanField(s, &vid, &kind):
+			if vlans[vid] == nil {
+				vlans[vid] = make(map[string]string)
+			}
+			vlans[vid][kind] = value
+		case strings.HasPrefix(field, "route."):
+			routes[s] = value
+		case strings.HasPrefix(field, "sensor.") &&
+			strings.LastIndex(s, ".") > 0:
+			i := strings.LastIndex(s, ".")
+			if sensors[s[:i]] == nil {
+				sensors[s[:i]] = make(map[string]string)
+			}
+			sensors[s[:i]][s[i+1:]] = value
+		default:
+			others[field] = value
+		}
+	}
+	var lines []string
+	add := func(indent int, k, v string) {
+		line := strings.Repeat("  ", indent) + k + ":"
+		if len(v) > 0 {
+			line += " " + yamlScalar(v)
+		}
+		lines = append(lines, line)
+	}
+	if len(ports) > 0 {
+		add(0, "interfaces", "")
+		for _, name := range sortedFields(ports) {
+			add(1, name, "")
+			p, err := port.ParsePort(strings.Fields(ports[name])...)
+			if err != nil {
+				return fmt.Errorf("%s%s: %v", port.Prefix, name, err)
+			}
+			for _, attr := range port.Attrs {
+				if v := portAttr(p, attr); len(v) > 0 {
+					add(2, attr, v)
+				}
+			}
+		}
+	}
+	if len(vlans) > 0 {
+		add(0, "vlans", "")
+		vids := make([]int, 0, len(vlans))
+		for vid := range vlans {
+			vids = append(vids, vid)
+		}
+		sort.Ints(vids)
+		for _, vid := range vids {
+			add(1, strconv.Itoa(vid), "")
+			for _, kind := range []string{"tagged", "untagged"} {
+				if v, found := vlans[vid][kind]; found {
+					add(2, kind, v)
+				}
+			}
+		}
+	}
+	for _, x := range []struct {
+		key    string
+		fields map[string]string
+	}{
+		{"stp", stp},
+		{"routes", routes},
+	} {
+		if len(x.fields) > 0 {
+			add(0, x.key, "")
+			for _, k := range sortedFields(x.fields) {
+				add(1, k, x.fields[k])
+			}
+		}
+	}
+	if len(sensors) > 0 {
+		add(0, "sensors", "")
+		names := make([]string, 0, len(sensors))
+		for name := range sensors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			add(1, name, "")
+			for _, k := range sortedFields(sensors[name]) {
+				add(2, k, sensors[name][k])
+			}
+		}
+	}
+	if len(others) > 0 {
+		add(0, "fields", "")
+		for _, k := range sortedFields(others) {
+			add(1, k, others[k])
+		}
+	}
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseVlanField(s string, vid *int, kind *string) bool {
+	dot := strings.Index(s, ".")
+	if dot < 0 {
+		return false
+	}
+	u, err := vlan.ParseVid(s[:dot])
+	if err != nil {
+		return false
+	}
+	*vid, *kind = int(u), s[dot+1:]
+	return *kind == "tagged" || *kind == "untagged"
+}
+
+func portAttr(p *port.Port, attr string) string {
+	switch attr {
+	case "speed":
+		if p.Speed != 0 {
+			return port.SpeedString(p.Speed)
+		}
+	case "fec":
+		return p.Fec
+	case "autoneg":
+		return p.Autoneg
+	case "mtu":
+		if p.Mtu != 0 {
+			return strconv.FormatUint(uint64(p.Mtu), 10)
+		}
+	case "admin":
+		return p.Admin
+	case "description":
+		return p.Description
+	}
+	return ""
+}
+
+// yamlScalar quotes the value if the machine parser wouldn't otherwise
+// read it as is.
+func yamlScalar(s string) string {
+	if strings.TrimSpace(s) != s || strings.Contains(s, ": ") ||
+		strings.Contains(s, " #") || strings.HasSuffix(s, ":") ||
+		strings.IndexAny(s[:1], `"'[-#`) == 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func sortedFields(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
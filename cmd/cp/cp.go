@@ -12,8 +12,8 @@ import (
 
 	"github.com/platinasystems/goes/external/flags"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/url"
 	"github.com/platinasystems/goes/lang"
-	"github.com/platinasystems/url"
 )
 
 type Command struct{}
@@ -5,12 +5,17 @@
 package daemons
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/flags"
 	"github.com/platinasystems/goes/lang"
 )
 
@@ -21,21 +26,118 @@ var Admin = &goes.Goes{
 		lang.EnUS: "daemon admin",
 	},
 	ByName: map[string]cmd.Cmd{
+		"attach":  Attach{},
 		"log":     Log{},
+		"reload":  Reload{},
 		"restart": Restart{},
 		"start":   Start{},
 		"status":  Status{},
 		"stop":    Stop{},
+		"upgrade": Upgrade{},
 	},
 }
 
 var empty = struct{}{}
 
+type Attach struct{}
 type Log struct{}
+type Reload struct{}
 type Restart struct{}
 type Status struct{}
 type Start struct{}
 type Stop struct{}
+type Upgrade struct{}
+
+func (Attach) String() string { return "attach" }
+
+func (Attach) Usage() string {
+	return "daemon attach [-stdin] DAEMON"
+}
+
+func (Attach) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show live output of a daemon",
+	}
+}
+
+func (Attach) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the recently captured stdout and stderr of the named daemon then
+	follow its output until interrupted.
+
+OPTIONS
+	-stdin	forward input lines to a daemon listed as a console by the
+		machine; detach with a "~." line or end-of-file`,
+	}
+}
+
+func (Attach) Main(args ...string) error {
+	flag, args := flags.New(args, "-stdin")
+	switch len(args) {
+	case 0:
+		return fmt.Errorf("DAEMON: missing")
+	case 1:
+	default:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	name := args[0]
+	cl, err := atsock.NewRpcClient(Sockname())
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	detach := make(chan error, 1)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		<-sig
+		detach <- nil
+	}()
+	if flag.ByName["-stdin"] {
+		go func() {
+			scan := bufio.NewScanner(os.Stdin)
+			for scan.Scan() {
+				if scan.Text() == "~." {
+					break
+				}
+				in := InputArgs{
+					Name: name,
+					Data: append(scan.Bytes(), '\n'),
+				}
+				err := cl.Call("Daemons.Input", in, &empty)
+				if err != nil {
+					detach <- err
+					return
+				}
+			}
+			detach <- nil
+		}()
+	} else {
+		fmt.Fprintln(os.Stderr, "detach with ^C")
+	}
+
+	out := OutputArgs{Name: name, Wait: time.Second}
+	for {
+		var reply OutputReply
+		call := cl.Go("Daemons.Output", out, &reply, nil)
+		select {
+		case err = <-detach:
+			return err
+		case <-call.Done:
+		}
+		if call.Error != nil {
+			return call.Error
+		}
+		fprintOutput(os.Stdout, reply.Lines)
+		if n := len(reply.Lines); n > 0 {
+			out.Seq = reply.Lines[n-1].Seq
+		}
+	}
+}
 
 func (Log) String() string { return "log" }
 
@@ -51,7 +153,7 @@ func (Log) Apropos() lang.Alt {
 
 func (Log) Main(args ...string) error {
 	var s string
-	cl, err := atsock.NewRpcClient(sockname())
+	cl, err := atsock.NewRpcClient(Sockname())
 	if err != nil {
 		return err
 	}
@@ -62,6 +164,27 @@ func (Log) Main(args ...string) error {
 	return err
 }
 
+func (Reload) String() string { return "reload" }
+
+func (Reload) Usage() string {
+	return "daemon reload [PID]..."
+}
+
+func (Reload) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "SIGHUP daemons to reload their configuration",
+	}
+}
+
+func (Reload) Main(args ...string) error {
+	cl, err := atsock.NewRpcClient(Sockname())
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	return cl.Call("Daemons.Reload", args, &empty)
+}
+
 func (Restart) String() string { return "restart" }
 
 func (Restart) Usage() string {
@@ -75,7 +198,7 @@ func (Restart) Apropos() lang.Alt {
 }
 
 func (Restart) Main(args ...string) error {
-	cl, err := atsock.NewRpcClient(sockname())
+	cl, err := atsock.NewRpcClient(Sockname())
 	if err != nil {
 		return err
 	}
@@ -99,7 +222,7 @@ func (Start) Main(args ...string) error {
 	if len(args) < 1 {
 		return fmt.Errorf("missing DAEMON [ARG]...")
 	}
-	cl, err := atsock.NewRpcClient(sockname())
+	cl, err := atsock.NewRpcClient(Sockname())
 	if err != nil {
 		return err
 	}
@@ -110,7 +233,7 @@ func (Start) Main(args ...string) error {
 func (Status) String() string { return "status" }
 
 func (Status) Usage() string {
-	return "daemon status"
+	return "daemon status [-json] [-env]"
 }
 
 func (Status) Apropos() lang.Alt {
@@ -119,17 +242,45 @@ func (Status) Apropos() lang.Alt {
 	}
 }
 
+func (Status) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print a table of the supervised daemons.
+
+OPTIONS
+	-json	print the status of each daemon as JSON
+	-env	also list the environment variables injected into each
+		running daemon by the machine configuration and its file in
+		/etc/goes/env.d`,
+	}
+}
+
 func (Status) Main(args ...string) error {
-	var s string
-	cl, err := atsock.NewRpcClient(sockname())
+	var info []Info
+	flag, args := flags.New(args, "-json", "-env")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	cl, err := atsock.NewRpcClient(Sockname())
 	if err != nil {
 		return err
 	}
 	defer cl.Close()
-	if err = cl.Call("Daemons.List", struct{}{}, &s); err == nil {
-		os.Stdout.WriteString(s)
+	if err = cl.Call("Daemons.Info", struct{}{}, &info); err != nil {
+		return err
 	}
-	return err
+	if flag.ByName["-json"] {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "\t")
+		return enc.Encode(info)
+	}
+	Fprint(os.Stdout, info)
+	if flag.ByName["-env"] {
+		fmt.Println()
+		FprintEnv(os.Stdout, info)
+	}
+	return nil
 }
 
 func (Stop) String() string { return "stop" }
@@ -145,10 +296,42 @@ func (Stop) Apropos() lang.Alt {
 }
 
 func (Stop) Main(args ...string) error {
-	cl, err := atsock.NewRpcClient(sockname())
+	cl, err := atsock.NewRpcClient(Sockname())
 	if err != nil {
 		return err
 	}
 	defer cl.Close()
 	return cl.Call("Daemons.Stop", args, &empty)
 }
+
+func (Upgrade) String() string { return "upgrade" }
+
+func (Upgrade) Usage() string {
+	return "daemon upgrade [PID]..."
+}
+
+func (Upgrade) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "replace daemons with those of the installed goes",
+	}
+}
+
+func (Upgrade) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Start new instances of the listed, or all, daemons from the currently
+	installed goes program. Daemons such as redisd and sshd handoff their
+	listening sockets and state to the new instance then exit once their
+	clients are done; others are simply restarted.`,
+	}
+}
+
+func (Upgrade) Main(args ...string) error {
+	cl, err := atsock.NewRpcClient(Sockname())
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	return cl.Call("Daemons.Upgrade", args, &empty)
+}
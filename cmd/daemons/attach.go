@@ -0,0 +1,162 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const ringLines = 256
+
+// OutputLine is a captured line of daemon stdout ("info") or stderr ("err").
+type OutputLine struct {
+	Seq      uint64
+	Time     time.Time
+	Priority string
+	Text     string
+}
+
+type OutputArgs struct {
+	Name string
+	// Seq of the last line received; zero for the whole ring buffer.
+	Seq uint64
+	// Wait up to this long for lines after Seq.
+	Wait time.Duration
+}
+
+type OutputReply struct {
+	Lines []OutputLine
+}
+
+type InputArgs struct {
+	Name string
+	Data []byte
+}
+
+// outputRing retains the most recent lines of a daemon's output and wakes
+// those waiting for more.
+type outputRing struct {
+	sync.Mutex
+	lines []OutputLine
+	seq   uint64
+	more  chan struct{}
+}
+
+func newOutputRing() *outputRing {
+	return &outputRing{
+		lines: make([]OutputLine, 0, ringLines),
+		more:  make(chan struct{}),
+	}
+}
+
+func (r *outputRing) add(priority string, text []byte) {
+	r.Lock()
+	defer r.Unlock()
+	r.seq++
+	l := OutputLine{
+		Seq:      r.seq,
+		Time:     time.Now(),
+		Priority: priority,
+		Text:     string(text),
+	}
+	if len(r.lines) < cap(r.lines) {
+		r.lines = append(r.lines, l)
+	} else {
+		copy(r.lines, r.lines[1:])
+		r.lines[len(r.lines)-1] = l
+	}
+	close(r.more)
+	r.more = make(chan struct{})
+}
+
+// since returns the retained lines after seq along with a channel that's
+// closed on the next add.
+func (r *outputRing) since(seq uint64) ([]OutputLine, <-chan struct{}) {
+	r.Lock()
+	defer r.Unlock()
+	var lines []OutputLine
+	for _, l := range r.lines {
+		if l.Seq > seq {
+			lines = append(lines, l)
+		}
+	}
+	return lines, r.more
+}
+
+// last returns up to the n most recent lines.
+func (r *outputRing) last(n int) []OutputLine {
+	r.Lock()
+	defer r.Unlock()
+	if n > len(r.lines) {
+		n = len(r.lines)
+	}
+	lines := make([]OutputLine, n)
+	copy(lines, r.lines[len(r.lines)-n:])
+	return lines
+}
+
+// ring returns the, possibly new, output ring buffer of the named daemon.
+func (d *Daemons) ring(name string) *outputRing {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	r, found := d.rings[name]
+	if !found {
+		r = newOutputRing()
+		d.rings[name] = r
+	}
+	return r
+}
+
+// Output replies with the named daemon's captured output after the given
+// sequence, waiting if there isn't any yet.
+func (d *Daemons) Output(args OutputArgs, reply *OutputReply) error {
+	d.mutex.Lock()
+	r, found := d.rings[args.Name]
+	d.mutex.Unlock()
+	if !found {
+		return fmt.Errorf("%s: not found", args.Name)
+	}
+	lines, more := r.since(args.Seq)
+	if len(lines) == 0 && args.Wait > 0 {
+		select {
+		case <-more:
+			lines, _ = r.since(args.Seq)
+		case <-time.After(args.Wait):
+		case <-d.done:
+		}
+	}
+	reply.Lines = lines
+	return nil
+}
+
+// Input forwards data to the stdin of the named console daemon. This writes
+// without the mutex so a daemon that isn't reading its full stdin pipe
+// blocks just this input rather than the supervisor.
+func (d *Daemons) Input(args InputArgs, reply *struct{}) error {
+	var stdin io.Writer
+	d.mutex.Lock()
+	for pid, w := range d.stdins {
+		if p := d.cmdsByPid[pid]; p != nil && p.Args[0] == args.Name {
+			stdin = w
+			break
+		}
+	}
+	d.mutex.Unlock()
+	if stdin == nil {
+		return fmt.Errorf("%s: isn't a running console", args.Name)
+	}
+	_, err := stdin.Write(args.Data)
+	return err
+}
+
+func fprintOutput(w io.Writer, lines []OutputLine) {
+	for _, l := range lines {
+		fmt.Fprintln(w, l.Time.Format(time.StampMilli), l.Priority+":",
+			l.Text)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SysFsCgroup = "/sys/fs/cgroup"
+
+// Limits are the cgroup v2 resource constraints of a supervised daemon.
+// Zero fields are unlimited.
+type Limits struct {
+	// Memory is the memory.max bytes.
+	Memory int64
+
+	// CPU is the cpu.max fraction of one processor; e.g. 0.5 or 2.
+	CPU float64
+
+	// Pids is the pids.max number of tasks.
+	Pids int
+}
+
+func (l Limits) IsZero() bool {
+	return l.Memory == 0 && l.CPU == 0 && l.Pids == 0
+}
+
+func (l Limits) String() string {
+	var a []string
+	if l.Memory != 0 {
+		a = append(a, fmt.Sprint("memory ", l.Memory))
+	}
+	if l.CPU != 0 {
+		a = append(a, fmt.Sprint("cpu ", l.CPU))
+	}
+	if l.Pids != 0 {
+		a = append(a, fmt.Sprint("pids ", l.Pids))
+	}
+	if len(a) == 0 {
+		return "none"
+	}
+	return strings.Join(a, ", ")
+}
+
+// ParseSize returns the bytes of a decimal number with an optional K, M, or G
+// binary suffix.
+func ParseSize(s string) (int64, error) {
+	mul := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mul = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mul = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mul = 1 << 30
+	}
+	if mul > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%q isn't a size", s)
+	}
+	return n * mul, nil
+}
+
+// cgroup returns the directory of the named daemon's cgroup after creating
+// it, if necessary, and applying its limits.
+func (d *Daemons) cgroup(name string, l Limits) (string, error) {
+	root := filepath.Join(SysFsCgroup, "goes")
+	if _, err := os.Stat(filepath.Join(SysFsCgroup,
+		"cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 unavailable")
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	for _, dn := range []string{SysFsCgroup, root} {
+		// failure, e.g. an absent controller, is caught below
+		ioutil.WriteFile(filepath.Join(dn, "cgroup.subtree_control"),
+			[]byte("+memory +cpu +pids"), 0644)
+	}
+	dn := filepath.Join(root, name)
+	if err := os.MkdirAll(dn, 0755); err != nil {
+		return "", err
+	}
+	set := func(fn, s string) error {
+		err := ioutil.WriteFile(filepath.Join(dn, fn), []byte(s), 0644)
+		if err != nil {
+			err = fmt.Errorf("%s: %v", fn, err)
+		}
+		return err
+	}
+	memory, cpu, pids := "max", "max", "max"
+	if l.Memory > 0 {
+		memory = fmt.Sprint(l.Memory)
+	}
+	if l.CPU > 0 {
+		const period = 100 * time.Millisecond
+		quota := time.Duration(l.CPU * float64(period))
+		cpu = fmt.Sprint(quota.Microseconds(), " ",
+			period.Microseconds())
+	}
+	if l.Pids > 0 {
+		pids = fmt.Sprint(l.Pids)
+	}
+	if err := set("memory.max", memory); err != nil && l.Memory > 0 {
+		return dn, err
+	}
+	if err := set("cpu.max", cpu); err != nil && l.CPU > 0 {
+		return dn, err
+	}
+	if err := set("pids.max", pids); err != nil && l.Pids > 0 {
+		return dn, err
+	}
+	return dn, nil
+}
+
+// confine the given daemon process to its cgroup, if it has limits.
+func (d *Daemons) confine(name string, pid int) error {
+	l, found := d.limits[name]
+	if !found || l.IsZero() {
+		return nil
+	}
+	dn, err := d.cgroup(name, l)
+	if len(dn) > 0 {
+		xerr := ioutil.WriteFile(filepath.Join(dn, "cgroup.procs"),
+			[]byte(strconv.Itoa(pid)), 0644)
+		if err == nil {
+			err = xerr
+		}
+	}
+	return err
+}
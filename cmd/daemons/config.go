@@ -0,0 +1,211 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/platinasystems/goes/external/machine"
+)
+
+// configure overrides the machine's compiled in policies, dependencies, and
+// log capture with those of the machine configuration file, e.g.
+//
+//	daemons:
+//	  vnetd:
+//	    restart: on-failure
+//	    limit: -1
+//	    backoff: 2s
+//	    max-backoff: 1m
+//	    stable: 5m
+//	    grace: 30s
+//	    after: [redisd]
+//	    timeout: 1m
+//	    ready: ["vnet.ready=true"]
+//	    live: ["@vnetd"]
+//	    probe-interval: 5s
+//	    probe-failures: 3
+//	    memory: 256M
+//	    cpu: 0.5
+//	    pids: 64
+//	    console: false
+//	    env:
+//	      GOMAXPROCS: 2
+//	      HTTPS_PROXY: http://proxy:3128
+//	  snmpd:
+//	    exec: /usr/sbin/snmpd -f -Lo
+//	start:
+//	  serial: false
+//	logs:
+//	  dir: /var/log/goes
+//	  max-size: 1048576
+//	  max-age: 24h
+//	  keep: 4
+//	  compress: true
+func (c *Server) configure(cfg *machine.Config) error {
+	for _, name := range cfg.Keys("daemons") {
+		prefix := "daemons." + name + "."
+		policy, found := c.Policies[name]
+		if !found {
+			policy = DefaultPolicy
+		}
+		if s := cfg.String(prefix+"restart", ""); len(s) > 0 {
+			r, err := ParseRespawn(s)
+			if err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+			policy.Restart = r
+		}
+		var err error
+		if policy.Limit, err = cfg.Int(prefix+"limit",
+			policy.Limit); err != nil {
+			return err
+		}
+		if policy.Backoff, err = cfg.Duration(prefix+"backoff",
+			policy.Backoff); err != nil {
+			return err
+		}
+		if policy.MaxBackoff, err = cfg.Duration(prefix+"max-backoff",
+			policy.MaxBackoff); err != nil {
+			return err
+		}
+		if policy.Stable, err = cfg.Duration(prefix+"stable",
+			policy.Stable); err != nil {
+			return err
+		}
+		if policy.Grace, err = cfg.Duration(prefix+"grace",
+			policy.Grace); err != nil {
+			return err
+		}
+		if c.Policies == nil {
+			c.Policies = make(map[string]Policy)
+		}
+		c.Policies[name] = policy
+
+		dep := c.Depends[name]
+		dep.After = cfg.Strings(prefix+"after", dep.After)
+		if dep.Timeout, err = cfg.Duration(prefix+"timeout",
+			dep.Timeout); err != nil {
+			return err
+		}
+		if c.Depends == nil {
+			c.Depends = make(map[string]Depend)
+		}
+		c.Depends[name] = dep
+
+		probe := c.Probes[name]
+		if probe.Ready, err = conditions(cfg, prefix+"ready",
+			probe.Ready); err != nil {
+			return err
+		}
+		if probe.Live, err = conditions(cfg, prefix+"live",
+			probe.Live); err != nil {
+			return err
+		}
+		if probe.Interval, err = cfg.Duration(prefix+"probe-interval",
+			probe.Interval); err != nil {
+			return err
+		}
+		if probe.Failures, err = cfg.Int(prefix+"probe-failures",
+			probe.Failures); err != nil {
+			return err
+		}
+		if c.Probes == nil {
+			c.Probes = make(map[string]Probe)
+		}
+		c.Probes[name] = probe
+
+		if ext := cfg.Strings(prefix+"exec", nil); len(ext) > 0 {
+			if c.Externals == nil {
+				c.Externals = make(map[string][]string)
+			}
+			c.Externals[name] = ext
+		}
+
+		if console, err := cfg.Bool(prefix+"console",
+			false); err != nil {
+			return err
+		} else if console {
+			c.Consoles = append(c.Consoles, name)
+		}
+
+		if keys := cfg.Keys(prefix + "env"); len(keys) > 0 {
+			env := c.Env[name]
+			for _, k := range keys {
+				env = mergeEnv(env, []string{k + "=" +
+					cfg.String(prefix+"env."+k, "")})
+			}
+			if c.Env == nil {
+				c.Env = make(map[string][]string)
+			}
+			c.Env[name] = env
+		}
+
+		limits := c.Limits[name]
+		if s := cfg.String(prefix+"memory", ""); len(s) > 0 {
+			if limits.Memory, err = ParseSize(s); err != nil {
+				return fmt.Errorf("%smemory: %v", prefix, err)
+			}
+		}
+		if s := cfg.String(prefix+"cpu", ""); len(s) > 0 {
+			limits.CPU, err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("%scpu: %q isn't a number",
+					prefix, s)
+			}
+		}
+		if limits.Pids, err = cfg.Int(prefix+"pids",
+			limits.Pids); err != nil {
+			return err
+		}
+		if !limits.IsZero() {
+			if c.Limits == nil {
+				c.Limits = make(map[string]Limits)
+			}
+			c.Limits[name] = limits
+		}
+	}
+	var err error
+	if c.Serial, err = cfg.Bool("start.serial", c.Serial); err != nil {
+		return err
+	}
+	c.Logs.Dir = cfg.String("logs.dir", c.Logs.Dir)
+	maxSize, err := cfg.Int("logs.max-size", int(c.Logs.MaxSize))
+	if err != nil {
+		return err
+	}
+	c.Logs.MaxSize = int64(maxSize)
+	if c.Logs.MaxAge, err = cfg.Duration("logs.max-age",
+		c.Logs.MaxAge); err != nil {
+		return err
+	}
+	if c.Logs.Keep, err = cfg.Int("logs.keep", c.Logs.Keep); err != nil {
+		return err
+	}
+	if c.Logs.Compress, err = cfg.Bool("logs.compress",
+		c.Logs.Compress); err != nil {
+		return err
+	}
+	return nil
+}
+
+// conditions returns those parsed from the configured list, if present;
+// otherwise, the given default.
+func conditions(cfg *machine.Config, path string,
+	def []Condition) ([]Condition, error) {
+	if !cfg.Has(path) {
+		return def, nil
+	}
+	var conds []Condition
+	for _, s := range cfg.Strings(path, nil) {
+		cond, err := ParseCondition(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		conds = append(conds, cond)
+	}
+	return conds, nil
+}
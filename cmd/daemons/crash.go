@@ -0,0 +1,118 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// CrashKeep is the number of crash reports retained per daemon.
+	CrashKeep = 8
+
+	// crashLines of the daemon's final output are included in its report.
+	crashLines = 64
+
+	// crashSettle is the time allowed to drain a dead daemon's output.
+	crashSettle = 250 * time.Millisecond
+)
+
+// crashDir is where crash reports are stored; or an empty string if log
+// capture is disabled.
+func (d *Daemons) crashDir() string {
+	if d.logs.Dir == "-" || len(d.logs.Dir) == 0 {
+		return ""
+	}
+	return filepath.Join(d.logs.Dir, "crash")
+}
+
+// crashed records a report of the given abnormally exited daemon to
+// DIR/crash/NAME-TIME.txt then publishes its summary as
+// goes.daemon.NAME.crash and the count as goes.daemon.NAME.crashes.
+// The wait status is nil if the daemon was killed while stopping.
+func (d *Daemons) crashed(p *exec.Cmd, ws *syscall.WaitStatus, reason string) {
+	name := p.Args[0]
+	pid := p.Process.Pid
+	now := time.Now()
+	time.Sleep(crashSettle)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "daemon:", strings.Join(p.Args, " "))
+	fmt.Fprintln(buf, "pid:", pid)
+	fmt.Fprintln(buf, "time:", now.Format(time.RFC3339))
+	fmt.Fprintln(buf, "reason:", reason)
+	if ws != nil {
+		if ws.Signaled() {
+			fmt.Fprintln(buf, "signal:", ws.Signal())
+		}
+		fmt.Fprintln(buf, "core dumped:", ws.CoreDump())
+	}
+	if b, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern"); err == nil {
+		fmt.Fprintln(buf, "core pattern:", strings.TrimSpace(string(b)))
+	}
+	fmt.Fprintln(buf)
+	d.mutex.Lock()
+	r := d.rings[name]
+	d.mutex.Unlock()
+	if r != nil {
+		fprintOutput(buf, r.last(crashLines))
+	}
+
+	fn := "-"
+	if dn := d.crashDir(); len(dn) > 0 {
+		fn = filepath.Join(dn, fmt.Sprintf("%s-%s.txt", name,
+			now.Format("20060102T150405")))
+		err := os.MkdirAll(dn, 0755)
+		if err == nil {
+			err = ioutil.WriteFile(fn, buf.Bytes(), 0644)
+		}
+		if err != nil {
+			logger.Err("crash", "daemon", name, "err", err)
+			fn = "-"
+		} else {
+			pruneCrashes(dn, name)
+		}
+	}
+
+	d.mutex.Lock()
+	d.crashes[name]++
+	n := d.crashes[name]
+	d.mutex.Unlock()
+	d.publish(name, "crash", fmt.Sprint(now.Unix(), " ", reason, " ", fn))
+	d.publish(name, "crashes", n)
+}
+
+// pruneCrashes removes all but the newest CrashKeep reports of the daemon.
+func pruneCrashes(dn, name string) {
+	const stamp = "20060102T150405.txt"
+	all, err := filepath.Glob(filepath.Join(dn, name+"-*.txt"))
+	if err != nil {
+		return
+	}
+	var fns []string
+	for _, fn := range all {
+		// skip those of another daemon, e.g. NAME-suffix
+		if len(filepath.Base(fn)) == len(name)+1+len(stamp) {
+			fns = append(fns, fn)
+		}
+	}
+	if len(fns) <= CrashKeep {
+		return
+	}
+	// the time stamp suffix sorts chronologically
+	sort.Strings(fns)
+	for _, fn := range fns[:len(fns)-CrashKeep] {
+		os.Remove(fn)
+	}
+}
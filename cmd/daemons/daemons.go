@@ -7,6 +7,7 @@ package daemons
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"strconv"
@@ -16,11 +17,17 @@ import (
 	"time"
 
 	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/event"
 	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis/publisher"
 	"github.com/platinasystems/goes/internal/prog"
 )
 
+// logger of the supervisor's messages
+var logger = log.New("daemons")
+
 type Daemons struct {
 	mutex sync.Mutex
 	goes  *goes.Goes
@@ -31,18 +38,72 @@ type Daemons struct {
 
 	cmdsByPid map[int]*exec.Cmd
 	stopping  bool
+
+	policies  map[string]Policy
+	depends   map[string]Depend
+	probes    map[string]Probe
+	limits    map[string]Limits
+	logs      LogConfig
+	logFiles  map[string]*logFile
+	rings     map[string]*outputRing
+	consoles  map[string]bool
+	externals map[string][]string
+	envs      map[string][]string
+	cmdlines  map[int][]string
+	injected  map[int][]string
+	stdins    map[int]io.WriteCloser
+	restarts  map[string]int
+	exits     map[string]string
+	readiness map[string]bool
+	crashes   map[string]int
+	since     map[int]time.Time
+	pub       *publisher.Publisher
+
+	// version cache of the installed goes program
+	ver struct {
+		sync.Mutex
+		s, fn string
+		mod   time.Time
+	}
 }
 
-func sockname() string {
+// Sockname is that of the admin RPC socket of the daemons supervisor.
+func Sockname() string {
 	return prog.Base() + "-daemons"
 }
 
 func (d *Daemons) init() {
 	d.done = make(chan struct{})
 	d.cmdsByPid = make(map[int]*exec.Cmd)
+	d.restarts = make(map[string]int)
+	d.exits = make(map[string]string)
+	d.readiness = make(map[string]bool)
+	d.crashes = make(map[string]int)
+	d.since = make(map[int]time.Time)
+	d.logFiles = make(map[string]*logFile)
+	d.rings = make(map[string]*outputRing)
+	d.stdins = make(map[int]io.WriteCloser)
+	d.cmdlines = make(map[int][]string)
+	d.injected = make(map[int][]string)
 	d.log.init()
 	log.Tee(&d.log)
+	if pub, err := publisher.New(); err == nil {
+		d.pub = pub
+	}
+}
 
+func (d *Daemons) policy(name string) *Policy {
+	if p, found := d.policies[name]; found {
+		return &p
+	}
+	return &DefaultPolicy
+}
+
+// publish a "goes.daemon.NAME.FIELD: VALUE" to redisd, if it's running.
+func (d *Daemons) publish(name, field string, value interface{}) {
+	if d.pub != nil {
+		d.pub.Print("goes.daemon.", name, ".", field, ": ", value)
+	}
 }
 
 func (d *Daemons) start(restarts int, args ...string) {
@@ -52,7 +113,8 @@ func (d *Daemons) start(restarts int, args ...string) {
 	rout, wout, err := os.Pipe()
 	defer func(cs string) {
 		if err != nil {
-			log.Print("daemon", "err", cs, ": ", err)
+			logger.Err("start", "args", cs, "err", err)
+			d.setState(args[0], StateFailed, 0)
 		}
 	}(strings.Join(args, " "))
 	if err != nil {
@@ -62,40 +124,91 @@ func (d *Daemons) start(restarts int, args ...string) {
 	if err != nil {
 		return
 	}
-	p := d.goes.Fork(args...)
+	var p *exec.Cmd
+	if ext, found := d.externals[args[0]]; found {
+		// run with argv[0] of the daemon's name for admin lookup
+		p = exec.Command(ext[0])
+		p.Args = append(append([]string{args[0]}, ext[1:]...),
+			args[1:]...)
+	} else {
+		p = d.goes.Fork(args...)
+	}
 	p.Stdin = nil
 	p.Stdout = wout
 	p.Stderr = werr
 	p.Dir = "/"
-	p.Env = prog.DaemonEnv()
+	env := d.env(args[0])
+	p.Env = mergeEnv(prog.DaemonEnv(), env)
+	// a process group per daemon to kill its orphans on stop
+	p.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var rin, win *os.File
+	if d.consoles[args[0]] {
+		if rin, win, err = os.Pipe(); err != nil {
+			return
+		}
+		p.Stdin = rin
+	}
 
 	if err = p.Start(); err != nil {
+		if win != nil {
+			rin.Close()
+			win.Close()
+		}
 		return
 	}
-	log.Print("daemon", "info", "running ", p.Process.Pid, " ", args)
+	if win != nil {
+		rin.Close()
+		d.mutex.Lock()
+		d.stdins[p.Process.Pid] = win
+		d.mutex.Unlock()
+	}
+	logger.Info("running", "daemon", args[0], "pid", p.Process.Pid,
+		"args", args)
+	if xerr := d.confine(args[0], p.Process.Pid); xerr != nil {
+		logger.Err("cgroup", "daemon", args[0], "err", xerr)
+	}
 	id := fmt.Sprintf("%s.%s[%d]", prog.Base(), args[0], p.Process.Pid)
 	d.mutex.Lock()
 	d.pids = append(d.pids, p.Process.Pid)
 	d.cmdsByPid[p.Process.Pid] = p
+	d.since[p.Process.Pid] = time.Now()
+	d.cmdlines[p.Process.Pid] = append([]string{}, args...)
+	d.injected[p.Process.Pid] = env
 	d.mutex.Unlock()
-	go log.LinesFrom(rout, id, "info")
-	go log.LinesFrom(rerr, id, "err")
+	d.setState(args[0], StateRunning, p.Process.Pid)
+	go d.probe(p.Process.Pid, args[0])
+	lf := d.logFile(args[0])
+	ring := d.ring(args[0])
+	go log.LinesFrom(newTeeReadCloser(rout,
+		&lineWriter{lf: lf, ring: ring, priority: "info"}), id, "info")
+	go log.LinesFrom(newTeeReadCloser(rerr,
+		&lineWriter{lf: lf, ring: ring, priority: "err"}), id, "err")
 	go func(p *exec.Cmd, wout, werr *os.File, args ...string) {
-		if err := p.Wait(); err != nil {
+		started := time.Now()
+		err := p.Wait()
+		exit := "done"
+		if err != nil {
+			exit = err.Error()
 			fmt.Fprintln(werr, err)
 		} else {
-			fmt.Fprintln(wout, "done")
+			fmt.Fprintln(wout, exit)
 		}
+		d.mutex.Lock()
+		d.exits[args[0]] = exit
+		d.mutex.Unlock()
 		if d.cmd(p.Process.Pid) != nil {
 			d.del(p.Process.Pid)
-			if restarts == RestartLimit {
-				if RestartLimit != 0 {
-					fmt.Fprintln(werr, "to many restarts")
+			if err != nil {
+				var ws *syscall.WaitStatus
+				sys := p.ProcessState.Sys()
+				if x, ok := sys.(syscall.WaitStatus); ok {
+					ws = &x
 				}
-			} else {
-				fmt.Fprintln(werr, "restart")
-				defer d.start(restarts+1, args...)
+				go d.crashed(p, ws, exit)
 			}
+			d.respawn(restarts, time.Since(started), err, werr,
+				args...)
 		}
 		wout.Sync()
 		werr.Sync()
@@ -104,6 +217,56 @@ func (d *Daemons) start(restarts int, args ...string) {
 	}(p, wout, werr, args...)
 }
 
+// respawn an unexpectedly exited daemon per its restart policy.
+func (d *Daemons) respawn(restarts int, ran time.Duration, err error,
+	werr io.Writer, args ...string) {
+	name := args[0]
+	policy := d.policy(name)
+	if policy.Stable > 0 && ran > policy.Stable {
+		restarts = 0
+	}
+	delay, retry := policy.Retry(restarts, err)
+	if !retry {
+		if policy.Limit != 0 && policy.Restart != RespawnNever &&
+			(err != nil || policy.Restart == RespawnAlways) {
+			fmt.Fprintln(werr, "too many restarts")
+		}
+		if err != nil {
+			d.setState(name, StateFailed, 0)
+			event.Post("daemon.failed", "err",
+				name+" failed", "daemon", name, "err", err)
+		} else {
+			d.setState(name, StateExited, 0)
+		}
+		return
+	}
+	d.mutex.Lock()
+	d.restarts[name]++
+	n := d.restarts[name]
+	d.mutex.Unlock()
+	d.setState(name, StateBackoff, 0)
+	d.publish(name, "restarts", n)
+	kv := []interface{}{"daemon", name, "restarts", n, "delay", delay}
+	if err != nil {
+		kv = append(kv, "err", err)
+	}
+	event.Post("daemon.restart", "warn", name+" restart", kv...)
+	fmt.Fprintln(werr, "restart in", delay)
+	go func() {
+		select {
+		case <-d.done:
+			return
+		case <-time.After(delay):
+		}
+		d.mutex.Lock()
+		stopping := d.stopping
+		d.mutex.Unlock()
+		if !stopping {
+			d.start(restarts+1, args...)
+		}
+	}()
+}
+
 func (d *Daemons) List(args struct{}, reply *string) error {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
@@ -142,7 +305,7 @@ func (d *Daemons) Stop(pidlist []string, reply *struct{}) (err error) {
 			return syscall.EBUSY
 		}
 		d.stopping = true
-		log.Print("daemon", "info", "stopping")
+		logger.Info("stopping")
 		defer close(d.done)
 		// stop all in reverse order
 		pids = make([]int, len(d.pids))
@@ -159,6 +322,51 @@ func (d *Daemons) Stop(pidlist []string, reply *struct{}) (err error) {
 	return d.stop(pids)
 }
 
+// Reload sends SIGHUP to the listed daemons or, without a list, all of those
+// that implement cmd.Reloader; external programs and other goes daemons
+// aren't signaled unless listed.
+func (d *Daemons) Reload(pidlist []string, reply *struct{}) (err error) {
+	var pids []int
+	if len(pidlist) == 0 {
+		d.mutex.Lock()
+		for _, pid := range d.pids {
+			if args := d.cmdline(pid); len(args) > 0 &&
+				d.isReloader(args[0]) {
+				pids = append(pids, pid)
+			}
+		}
+		d.mutex.Unlock()
+	} else {
+		pids, err = d.pidlistToPids(pidlist)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pid := range pids {
+		if p := d.cmd(pid); p != nil {
+			logger.Info("reloading", "args", p.Args)
+			if xerr := p.Process.Signal(syscall.SIGHUP); err == nil {
+				err = xerr
+			}
+		}
+	}
+	return err
+}
+
+// isReloader returns true if the named daemon is a goes command that
+// implements cmd.Reloader. The caller must hold the mutex.
+func (d *Daemons) isReloader(name string) bool {
+	if _, found := d.externals[name]; found || d.goes == nil {
+		return false
+	}
+	v, found := d.goes.ByName[name]
+	if !found {
+		return false
+	}
+	_, found = v.(cmd.Reloader)
+	return found
+}
+
 func (d *Daemons) Restart(pidlist []string, reply *struct{}) (err error) {
 	var pargs [][]string
 	var pids []int
@@ -172,9 +380,7 @@ func (d *Daemons) Restart(pidlist []string, reply *struct{}) (err error) {
 		// but restart in original order
 		pargs = make([][]string, len(pids))
 		for i, pid := range d.pids {
-			p := d.cmdsByPid[pid]
-			pargs[i] = make([]string, len(p.Args))
-			copy(pargs[i], p.Args)
+			pargs[i] = d.cmdline(pid)
 		}
 	} else {
 		pids, err = d.pidlistToPids(pidlist)
@@ -183,9 +389,7 @@ func (d *Daemons) Restart(pidlist []string, reply *struct{}) (err error) {
 		}
 		pargs = make([][]string, len(pids))
 		for i, pid := range pids {
-			p := d.cmdsByPid[pid]
-			pargs[i] = make([]string, len(p.Args))
-			copy(pargs[i], p.Args)
+			pargs[i] = d.cmdline(pid)
 		}
 	}
 	d.mutex.Unlock()
@@ -193,7 +397,7 @@ func (d *Daemons) Restart(pidlist []string, reply *struct{}) (err error) {
 		return err
 	}
 	for _, args := range pargs {
-		log.Print("daemon", "info", "restarting: ", args)
+		logger.Info("restarting", "args", args)
 		d.start(0, args...)
 	}
 	return nil
@@ -222,6 +426,19 @@ func (d *Daemons) pidlistToPids(pidlist []string) (pids []int, err error) {
 	return pids, nil
 }
 
+// cmdline returns a copy of the goes command line, or the name and args of
+// an external program, that started the given daemon. The caller must hold
+// the mutex.
+func (d *Daemons) cmdline(pid int) []string {
+	if args, found := d.cmdlines[pid]; found {
+		return append([]string{}, args...)
+	}
+	if p := d.cmdsByPid[pid]; p != nil {
+		return append([]string{}, p.Args...)
+	}
+	return nil
+}
+
 func (d *Daemons) cmd(pid int) *exec.Cmd {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
@@ -232,6 +449,13 @@ func (d *Daemons) del(pid int) {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	delete(d.cmdsByPid, pid)
+	delete(d.since, pid)
+	delete(d.cmdlines, pid)
+	delete(d.injected, pid)
+	if w, found := d.stdins[pid]; found {
+		w.Close()
+		delete(d.stdins, pid)
+	}
 	for i, entry := range d.pids {
 		if pid == entry {
 			n := copy(d.pids[i:], d.pids[i+1:])
@@ -241,47 +465,72 @@ func (d *Daemons) del(pid int) {
 	}
 }
 
+// stop the given daemons with SIGTERM then, after the respective policy's
+// Grace, SIGQUIT for a goroutine dump followed by SIGKILL of its process
+// group. This logs the daemons that required such escalation.
 func (d *Daemons) stop(pids []int) error {
-	procdns := make(map[int]string)
-	for _, pid := range pids {
-		if p := d.cmd(pid); p != nil {
-			procdns[pid] = fmt.Sprint("/proc/", pid)
-			log.Print("daemon", "info", "stopping: ", p.Args)
-			d.del(pid)
-			p.Process.Signal(syscall.SIGTERM)
-		}
-	}
 	const (
 		period = 100 * time.Millisecond
+		quit   = time.Second
 		limit  = 5 * time.Second
 	)
-	for t := time.Duration(0); t < limit; t += period {
-		for pid, procdn := range procdns {
-			if _, err := os.Stat(procdn); os.IsNotExist(err) {
-				delete(procdns, pid)
+	type stopping struct {
+		p      *exec.Cmd
+		procdn string
+		grace  time.Duration
+		stage  int
+	}
+	const (
+		termed = iota
+		quitted
+		killed
+	)
+	m := make(map[int]*stopping)
+	for _, pid := range pids {
+		if p := d.cmd(pid); p != nil {
+			m[pid] = &stopping{
+				p:      p,
+				procdn: fmt.Sprint("/proc/", pid),
+				grace:  d.policy(p.Args[0]).grace(),
 			}
+			logger.Info("stopping", "daemon", p.Args[0], "pid", pid)
+			d.del(pid)
+			d.setState(p.Args[0], StateStopping, pid)
+			p.Process.Signal(syscall.SIGTERM)
 		}
-		if len(procdns) == 0 {
-			return nil
-		}
-		time.Sleep(period)
-	}
-	for pid := range procdns {
-		syscall.Kill(pid, syscall.SIGKILL)
 	}
-	for t := time.Duration(0); t < limit; t += period {
-		for pid, procdn := range procdns {
-			if _, err := os.Stat(procdn); os.IsNotExist(err) {
-				delete(procdns, pid)
+	start := time.Now()
+	for len(m) > 0 {
+		t := time.Since(start)
+		for pid, x := range m {
+			if _, err := os.Stat(x.procdn); os.IsNotExist(err) {
+				d.setState(x.p.Args[0], StateStopped, 0)
+				delete(m, pid)
+				continue
+			}
+			switch {
+			case x.stage == termed && t >= x.grace:
+				// the go runtime dumps goroutines to the
+				// captured stderr for the crash report
+				logger.Err("didn't stop", "daemon", x.p.Args[0],
+					"pid", pid, "grace", x.grace)
+				syscall.Kill(pid, syscall.SIGQUIT)
+				x.stage = quitted
+			case x.stage == quitted && t >= x.grace+quit:
+				logger.Err("killed", "daemon", x.p.Args[0],
+					"pid", pid)
+				// also kill its orphaned descendants
+				syscall.Kill(-pid, syscall.SIGKILL)
+				syscall.Kill(pid, syscall.SIGKILL)
+				go d.crashed(x.p, nil, "won't terminate")
+				x.stage = killed
+			case x.stage == killed && t >= x.grace+quit+limit:
+				return fmt.Errorf("%d won't die", pid)
 			}
 		}
-		if len(procdns) == 0 {
-			return nil
+		if len(m) > 0 {
+			time.Sleep(period)
 		}
-		time.Sleep(period)
-	}
-	for pid, _ := range procdns {
-		return fmt.Errorf("%d won't die", pid)
 	}
 	return nil
 }
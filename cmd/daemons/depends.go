@@ -0,0 +1,129 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"time"
+)
+
+// Depend declares a daemon's prerequisites.
+type Depend struct {
+	// After lists the daemons that must be ready, per their Probe, before
+	// this one starts.
+	After []string
+
+	// Timeout limits the wait for each of the After daemons; once
+	// expired, the supervisor logs the failed Condition and starts this
+	// daemon anyway. default: DefaultTimeout
+	Timeout time.Duration
+}
+
+const DefaultTimeout = 30 * time.Second
+
+// ready returns nil if the named daemon is running and all of its readiness
+// probe conditions hold; otherwise, the first pending condition.
+func (d *Daemons) ready(name string) error {
+	if !d.running(name) {
+		return fmt.Errorf("%s: not running", name)
+	}
+	return d.probeReady(name)
+}
+
+func (d *Daemons) running(name string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for _, p := range d.cmdsByPid {
+		if p.Args[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// wait, in the published StateWaiting, for the daemons that the named daemon
+// is after.
+func (d *Daemons) wait(name string) {
+	if len(d.depends[name].After) > 0 {
+		d.setState(name, StateWaiting, 0)
+		d.await(name)
+	}
+}
+
+// await the readiness of the daemons that the named daemon is after.
+func (d *Daemons) await(name string) {
+	dep := d.depends[name]
+	timeout := dep.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	for _, after := range dep.After {
+		const period = 250 * time.Millisecond
+		var err error
+		for end := time.Now().Add(timeout); ; time.Sleep(period) {
+			if err = d.ready(after); err == nil {
+				break
+			}
+			if time.Now().After(end) {
+				logger.Err("timeout", "daemon", name,
+					"after", after, "err", err)
+				break
+			}
+			select {
+			case <-d.done:
+				return
+			default:
+			}
+		}
+	}
+}
+
+// order the given daemon command lines such that each follows those that
+// it's declared after while otherwise retaining the given order.
+func order(init [][]string, depends map[string]Depend) ([][]string, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	byName := make(map[string][][]string)
+	for _, args := range init {
+		if len(args) > 0 {
+			byName[args[0]] = append(byName[args[0]], args)
+		}
+	}
+	sorted := make([][]string, 0, len(init))
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("%s: circular dependency", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, after := range depends[name].After {
+			if _, found := byName[after]; !found {
+				continue
+			}
+			if err := visit(after); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		sorted = append(sorted, byName[name]...)
+		return nil
+	}
+	for _, args := range init {
+		if len(args) == 0 {
+			continue
+		}
+		if err := visit(args[0]); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
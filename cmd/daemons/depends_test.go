@@ -0,0 +1,66 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrder(t *testing.T) {
+	init := [][]string{
+		{"nld"},
+		{"vnetd", "-x"},
+		{"uptimed"},
+		{"redisd"},
+	}
+	depends := map[string]Depend{
+		"nld":   {After: []string{"vnetd"}},
+		"vnetd": {After: []string{"redisd", "missing"}},
+	}
+	sorted, err := order(init, depends)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(sorted, [][]string{
+		{"redisd"},
+		{"vnetd", "-x"},
+		{"nld"},
+		{"uptimed"},
+	}) {
+		t.Error("wrong:", sorted)
+	}
+}
+
+func TestOrderCircular(t *testing.T) {
+	init := [][]string{{"a"}, {"b"}}
+	depends := map[string]Depend{
+		"a": {After: []string{"b"}},
+		"b": {After: []string{"a"}},
+	}
+	if _, err := order(init, depends); err == nil {
+		t.Error("expected circular dependency error")
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	for s, want := range map[string]Condition{
+		"@redisd":           Socket("@redisd"),
+		"/run/goes/socks/x": Socket("/run/goes/socks/x"),
+		"vnet.ready=true":   HashKey{Field: "vnet.ready", Value: "true"},
+		"platina redis.ready": HashKey{Key: "platina",
+			Field: "redis.ready"},
+	} {
+		got, err := ParseCondition(s)
+		if err != nil {
+			t.Error(s, err)
+		} else if got != want {
+			t.Errorf("%q: got %#v", s, got)
+		}
+	}
+	if _, err := ParseCondition("a b c"); err == nil {
+		t.Error("expected error")
+	}
+}
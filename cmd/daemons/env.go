@@ -0,0 +1,95 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnvDir has the optional environment file of each daemon, e.g.
+//
+//	/etc/goes/env.d/vnetd
+//
+// with lines of NAME=VALUE, blank lines, and # comments. The supervisor
+// reads it each time the daemon starts, so, a changed file applies with
+// the next restart.
+var EnvDir = "/etc/goes/env.d"
+
+// env returns the variables injected into the named daemon by the machine
+// and its environment file; the file's override the machine's of the same
+// name.
+func (d *Daemons) env(name string) []string {
+	env := append([]string{}, d.envs[name]...)
+	fn := filepath.Join(EnvDir, name)
+	f, err := os.Open(fn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Err("env", "daemon", name, "err", err)
+		}
+		return env
+	}
+	defer f.Close()
+	file, err := parseEnv(f)
+	if err != nil {
+		logger.Err("env", "daemon", name, "err",
+			fmt.Errorf("%s: %v", fn, err))
+	}
+	return mergeEnv(env, file)
+}
+
+// parseEnv returns the NAME=VALUE lines of an environment file; the VALUE
+// may be quoted. This returns the variables preceding the first invalid
+// line along with its error.
+func parseEnv(r io.Reader) ([]string, error) {
+	var env []string
+	scan := bufio.NewScanner(r)
+	for lineno := 1; scan.Scan(); lineno++ {
+		s := strings.TrimSpace(scan.Text())
+		if len(s) == 0 || strings.HasPrefix(s, "#") {
+			continue
+		}
+		s = strings.TrimPrefix(s, "export ")
+		eq := strings.Index(s, "=")
+		if eq < 1 || strings.ContainsAny(s[:eq], " \t") {
+			return env, fmt.Errorf("line %d: %q isn't NAME=VALUE",
+				lineno, s)
+		}
+		k, v := s[:eq], s[eq+1:]
+		if n := len(v); n > 1 && (v[0] == '"' || v[0] == '\'') &&
+			v[n-1] == v[0] {
+			v = v[1 : n-1]
+		}
+		env = append(env, k+"="+v)
+	}
+	return env, scan.Err()
+}
+
+// mergeEnv returns env with each NAME=VALUE of overrides replacing that of
+// the same NAME or appended if absent.
+func mergeEnv(env []string, overrides ...[]string) []string {
+	merged := append([]string{}, env...)
+	for _, list := range overrides {
+	mergeLoop:
+		for _, kv := range list {
+			k := kv + "="
+			if eq := strings.Index(kv, "="); eq >= 0 {
+				k = kv[:eq+1]
+			}
+			for i, x := range merged {
+				if strings.HasPrefix(x, k) {
+					merged[i] = kv
+					continue mergeLoop
+				}
+			}
+			merged = append(merged, kv)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,51 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/platinasystems/goes/external/machine"
+)
+
+func TestEnv(t *testing.T) {
+	cfg, err := machine.Parse(strings.NewReader(`
+daemons:
+  vnetd:
+    env:
+      GOMAXPROCS: 2
+      HTTPS_PROXY: http://proxy:3128
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Server
+	if err = c.configure(cfg); err != nil {
+		t.Fatal(err)
+	}
+	file, err := parseEnv(strings.NewReader(`
+# override the machine's
+export GOMAXPROCS=4
+FEATURE="x y"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := mergeEnv([]string{"PATH=/bin", "GOGC=50"}, c.Env["vnetd"], file)
+	if !reflect.DeepEqual(env, []string{
+		"PATH=/bin",
+		"GOGC=50",
+		"GOMAXPROCS=4",
+		"HTTPS_PROXY=http://proxy:3128",
+		"FEATURE=x y",
+	}) {
+		t.Error("env", env)
+	}
+	if _, err = parseEnv(strings.NewReader("A B=1\n")); err == nil {
+		t.Error("parsed invalid line")
+	}
+}
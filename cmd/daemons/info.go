@@ -0,0 +1,122 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/platinasystems/goes/internal/proc"
+)
+
+// Info is the status of a supervised daemon returned by Daemons.Info.
+type Info struct {
+	Name     string        `json:"name"`
+	Args     []string      `json:"args"`
+	Env      []string      `json:"env,omitempty"`
+	Pid      int           `json:"pid,omitempty"`
+	Since    time.Time     `json:"since"`
+	Restarts int           `json:"restarts"`
+	LastExit string        `json:"last_exit,omitempty"`
+	Rss      uint64        `json:"rss"`
+	Utime    time.Duration `json:"utime"`
+	Stime    time.Duration `json:"stime"`
+	Ready    bool          `json:"ready"`
+	Waiting  string        `json:"waiting,omitempty"`
+}
+
+// Info replies with the status of all running daemons followed by those
+// that have exited and aren't running.
+func (d *Daemons) Info(args struct{}, reply *[]Info) error {
+	var info []Info
+	running := make(map[string]bool)
+	d.mutex.Lock()
+	for _, pid := range d.pids {
+		p := d.cmdsByPid[pid]
+		name := p.Args[0]
+		running[name] = true
+		info = append(info, Info{
+			Name:     name,
+			Args:     d.cmdline(pid),
+			Env:      d.injected[pid],
+			Pid:      pid,
+			Since:    d.since[pid],
+			Restarts: d.restarts[name],
+			LastExit: d.exits[name],
+		})
+	}
+	var exited []string
+	for name := range d.exits {
+		if !running[name] {
+			exited = append(exited, name)
+		}
+	}
+	sort.Strings(exited)
+	for _, name := range exited {
+		info = append(info, Info{
+			Name:     name,
+			Restarts: d.restarts[name],
+			LastExit: d.exits[name],
+		})
+	}
+	d.mutex.Unlock()
+	pagesize := uint64(os.Getpagesize())
+	for i := range info {
+		if info[i].Pid == 0 {
+			info[i].Waiting = "not running"
+			continue
+		}
+		var stat proc.Stat
+		fn := fmt.Sprint("/proc/", info[i].Pid, "/stat")
+		if proc.Load(&stat).FromFile(fn) == nil {
+			info[i].Rss = uint64(stat.Rss) * pagesize
+			info[i].Utime = stat.Utime
+			info[i].Stime = stat.Stime
+		}
+		if err := d.ready(info[i].Name); err != nil {
+			info[i].Waiting = err.Error()
+		} else {
+			info[i].Ready = true
+		}
+	}
+	*reply = info
+	return nil
+}
+
+// Fprint a table of daemon status.
+func Fprint(w io.Writer, info []Info) {
+	const format = "%-16s %7s %12s %8v %9s %10s %-5s %s\n"
+	fmt.Fprintf(w, format, "NAME", "PID", "UPTIME", "RESTARTS", "RSS",
+		"CPU", "READY", "LAST EXIT")
+	now := time.Now()
+	for _, x := range info {
+		pid, uptime, rss, cpu := "-", "-", "-", "-"
+		if x.Pid != 0 {
+			pid = fmt.Sprint(x.Pid)
+			uptime = now.Sub(x.Since).Truncate(time.Second).String()
+			rss = fmt.Sprint(x.Rss>>10, "K")
+			cpu = (x.Utime + x.Stime).Truncate(10 *
+				time.Millisecond).String()
+		}
+		ready := "no"
+		if x.Ready {
+			ready = "yes"
+		}
+		fmt.Fprintf(w, format, x.Name, pid, uptime, x.Restarts, rss,
+			cpu, ready, x.LastExit)
+	}
+}
+
+// FprintEnv lists the variables injected into each running daemon.
+func FprintEnv(w io.Writer, info []Info) {
+	for _, x := range info {
+		for _, kv := range x.Env {
+			fmt.Fprintf(w, "%-16s %s\n", x.Name, kv)
+		}
+	}
+}
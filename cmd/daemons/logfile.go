@@ -0,0 +1,256 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const VarLogGoes = "/var/log/goes"
+
+// LogConfig describes the capture of each daemon's stdout and stderr to
+// DIR/NAME.log and its rotation to DIR/NAME.log.1[.gz], DIR/NAME.log.2[.gz],
+// etc.
+type LogConfig struct {
+	// Dir of the log files, default: VarLogGoes; "-" disables capture.
+	Dir string
+
+	// Rotate a log once it exceeds MaxSize bytes, default: 1MiB.
+	MaxSize int64
+
+	// Rotate a log once it's older than MaxAge, if non-zero.
+	MaxAge time.Duration
+
+	// Keep this many rotated logs, default: 4; negative keeps none.
+	Keep int
+
+	// Compress rotated logs with gzip.
+	Compress bool
+}
+
+// DefaultLogConfig applies unless overriden by Server.Logs.
+var DefaultLogConfig = LogConfig{
+	Dir:     VarLogGoes,
+	MaxSize: 1 << 20,
+	Keep:    4,
+}
+
+func (cfg *LogConfig) setDefaults() {
+	if len(cfg.Dir) == 0 {
+		cfg.Dir = DefaultLogConfig.Dir
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = DefaultLogConfig.MaxSize
+	}
+	if cfg.Keep == 0 {
+		cfg.Keep = DefaultLogConfig.Keep
+	}
+}
+
+// closeLogs forgets the log files with the mutex then closes these without.
+func (d *Daemons) closeLogs() {
+	d.mutex.Lock()
+	lfs := make([]*logFile, 0, len(d.logFiles))
+	for name, lf := range d.logFiles {
+		lfs = append(lfs, lf)
+		delete(d.logFiles, name)
+	}
+	d.mutex.Unlock()
+	for _, lf := range lfs {
+		lf.Close()
+	}
+}
+
+type logFile struct {
+	sync.Mutex
+	cfg   *LogConfig
+	fn    string
+	f     *os.File
+	size  int64
+	since time.Time
+}
+
+// logFile returns the, possibly shared, capture file of the named daemon;
+// or nil if disabled or unavailable. This creates and opens the file without
+// the mutex so a slow filesystem doesn't block the supervisor.
+func (d *Daemons) logFile(name string) *logFile {
+	d.mutex.Lock()
+	lf, found := d.logFiles[name]
+	d.mutex.Unlock()
+	if found {
+		return lf
+	}
+	if d.logs.Dir == "-" {
+		return nil
+	}
+	if err := os.MkdirAll(d.logs.Dir, 0755); err != nil {
+		return nil
+	}
+	lf = &logFile{
+		cfg: &d.logs,
+		fn:  filepath.Join(d.logs.Dir, name+".log"),
+	}
+	if err := lf.open(); err != nil {
+		return nil
+	}
+	d.mutex.Lock()
+	winner, found := d.logFiles[name]
+	if !found {
+		d.logFiles[name] = lf
+	}
+	d.mutex.Unlock()
+	if found {
+		// another start of the daemon opened it first
+		lf.Close()
+		return winner
+	}
+	return lf
+}
+
+func (lf *logFile) open() error {
+	f, err := os.OpenFile(lf.fn, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	lf.f = f
+	lf.size = 0
+	lf.since = time.Now()
+	if fi, err := f.Stat(); err == nil {
+		lf.size = fi.Size()
+		if lf.size > 0 {
+			lf.since = fi.ModTime()
+		}
+	}
+	return nil
+}
+
+// Print a time stamped line to the log; rotating the log if necessary.
+func (lf *logFile) Print(priority string, line []byte) {
+	lf.Lock()
+	defer lf.Unlock()
+	if lf.f == nil {
+		return
+	}
+	now := time.Now()
+	s := fmt.Sprintf("%s %s: %s\n", now.Format(time.StampMilli), priority,
+		line)
+	if lf.size > 0 && (lf.size+int64(len(s)) > lf.cfg.MaxSize ||
+		(lf.cfg.MaxAge > 0 && now.Sub(lf.since) > lf.cfg.MaxAge)) {
+		lf.rotate()
+		if lf.f == nil {
+			return
+		}
+	}
+	n, _ := lf.f.WriteString(s)
+	lf.size += int64(n)
+}
+
+func (lf *logFile) Close() error {
+	lf.Lock()
+	defer lf.Unlock()
+	if lf.f == nil {
+		return nil
+	}
+	err := lf.f.Close()
+	lf.f = nil
+	return err
+}
+
+func (lf *logFile) rotated(i int) string {
+	fn := fmt.Sprint(lf.fn, ".", i)
+	if lf.cfg.Compress {
+		fn += ".gz"
+	}
+	return fn
+}
+
+func (lf *logFile) rotate() {
+	lf.f.Close()
+	lf.f = nil
+	keep := lf.cfg.Keep
+	if keep <= 0 {
+		os.Remove(lf.fn)
+	} else {
+		os.Remove(lf.rotated(keep))
+		for i := keep - 1; i > 0; i-- {
+			os.Rename(lf.rotated(i), lf.rotated(i+1))
+		}
+		if lf.cfg.Compress {
+			gzipFile(lf.fn, lf.rotated(1))
+		} else {
+			os.Rename(lf.fn, lf.rotated(1))
+		}
+	}
+	lf.open()
+}
+
+func gzipFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	zw := gzip.NewWriter(w)
+	if _, err = io.Copy(zw, r); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = os.Remove(src)
+	}
+	return err
+}
+
+// lineWriter forwards complete lines of the written daemon output to its log
+// file and output ring buffer with the given priority.
+type lineWriter struct {
+	lf       *logFile
+	ring     *outputRing
+	priority string
+	partial  []byte
+}
+
+func (w *lineWriter) Write(b []byte) (int, error) {
+	w.partial = append(w.partial, b...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		if w.lf != nil {
+			w.lf.Print(w.priority, w.partial[:i])
+		}
+		if w.ring != nil {
+			w.ring.add(w.priority, w.partial[:i])
+		}
+		w.partial = w.partial[i+1:]
+	}
+	if len(w.partial) == 0 {
+		w.partial = nil
+	}
+	return len(b), nil
+}
+
+// teeReadCloser copies the read daemon output to the given writer before
+// forwarding it to the syslog reader.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newTeeReadCloser(rc io.ReadCloser, w io.Writer) io.ReadCloser {
+	return teeReadCloser{io.TeeReader(rc, w), rc}
+}
@@ -0,0 +1,69 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/platinasystems/goes/internal/prog"
+)
+
+// plan prints the ordered daemons with their environment, dependencies,
+// policies, and sockets without starting anything.
+func (d *Daemons) plan(w io.Writer, ordered [][]string, serial bool) {
+	fmt.Fprintln(w, "supervisor socket: @"+Sockname())
+	if serial {
+		fmt.Fprintln(w, "startup: serial")
+	} else {
+		fmt.Fprintln(w, "startup: parallel, gated by dependencies")
+	}
+	fmt.Fprintln(w, "environment:")
+	for _, e := range prog.DaemonEnv() {
+		fmt.Fprintln(w, "\t"+e)
+	}
+	for i, args := range ordered {
+		name := args[0]
+		fmt.Fprintf(w, "%d. %s\n", i+1, strings.Join(args, " "))
+		if ext, found := d.externals[name]; found {
+			fmt.Fprintln(w, "\texec:", strings.Join(ext, " "))
+		}
+		for _, e := range d.env(name) {
+			fmt.Fprintln(w, "\tenv:", e)
+		}
+		dep := d.depends[name]
+		if len(dep.After) > 0 {
+			timeout := dep.Timeout
+			if timeout == 0 {
+				timeout = DefaultTimeout
+			}
+			fmt.Fprintf(w, "\tafter: %s (timeout %v)\n",
+				strings.Join(dep.After, ", "), timeout)
+		}
+		probe := d.probes[name]
+		for _, c := range probe.Ready {
+			fmt.Fprintln(w, "\tready:", c)
+		}
+		for _, c := range probe.Live {
+			fmt.Fprintln(w, "\tlive:", c)
+		}
+		p := d.policy(name)
+		fmt.Fprintf(w, "\trestart: %v, limit %d, backoff %v..%v\n",
+			p.Restart, p.Limit, p.Backoff, p.MaxBackoff)
+		fmt.Fprintf(w, "\tstop: SIGTERM, SIGKILL after %v\n", p.grace())
+		if l := d.limits[name]; !l.IsZero() {
+			fmt.Fprintf(w, "\tcgroup: %s/goes/%s: %v\n",
+				SysFsCgroup, name, l)
+		}
+		if d.logs.Dir == "-" {
+			fmt.Fprintln(w, "\tlog: syslog")
+		} else {
+			fmt.Fprintln(w, "\tlog:", filepath.Join(d.logs.Dir,
+				name+".log"))
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"time"
+)
+
+// Respawn is the condition on which a supervised daemon is respawned.
+type Respawn int
+
+const (
+	RespawnNever Respawn = iota
+	RespawnOnFailure
+	RespawnAlways
+)
+
+var respawnByName = map[string]Respawn{
+	"never":      RespawnNever,
+	"on-failure": RespawnOnFailure,
+	"always":     RespawnAlways,
+}
+
+func (r Respawn) String() string {
+	for s, v := range respawnByName {
+		if v == r {
+			return s
+		}
+	}
+	return fmt.Sprint("respawn(", int(r), ")")
+}
+
+// ParseRespawn returns the Respawn named "never", "on-failure", or "always".
+func ParseRespawn(s string) (Respawn, error) {
+	if r, found := respawnByName[s]; found {
+		return r, nil
+	}
+	return RespawnNever, fmt.Errorf("%s: invalid restart policy", s)
+}
+
+// Policy describes how the supervisor respawns an exited daemon.
+type Policy struct {
+	Restart Respawn
+
+	// Limit is the number of consecutive restarts before the circuit
+	// breaker trips and the daemon is abandoned. A negative Limit never
+	// trips.
+	Limit int
+
+	// The delay before each restart starts at Backoff and doubles with
+	// each consecutive restart up to MaxBackoff.
+	Backoff, MaxBackoff time.Duration
+
+	// A daemon that runs for longer than Stable resets its consecutive
+	// restart count.
+	Stable time.Duration
+
+	// Grace is the time between SIGTERM and SIGKILL when stopping the
+	// daemon, default: DefaultGrace.
+	Grace time.Duration
+}
+
+const DefaultGrace = 5 * time.Second
+
+// DefaultPolicy applies to daemons without an entry in Server.Policies. Like
+// the supervisor's original respawn, it restarts a daemon that exits for
+// any reason; a machine may opt in to "on-failure" per daemon. Its Limit is
+// that of the "restarts" build tag.
+var DefaultPolicy = Policy{
+	Restart:    RespawnAlways,
+	Limit:      RestartLimit,
+	Backoff:    time.Second,
+	MaxBackoff: time.Minute,
+	Stable:     5 * time.Minute,
+	Grace:      DefaultGrace,
+}
+
+func (p *Policy) grace() time.Duration {
+	if p.Grace > 0 {
+		return p.Grace
+	}
+	return DefaultGrace
+}
+
+// Retry returns true with the delay before the next restart of a daemon
+// that exited with the given error after the given number of consecutive
+// restarts.
+func (p *Policy) Retry(restarts int, err error) (time.Duration, bool) {
+	switch p.Restart {
+	case RespawnNever:
+		return 0, false
+	case RespawnOnFailure:
+		if err == nil {
+			return 0, false
+		}
+	}
+	if p.Limit >= 0 && restarts >= p.Limit {
+		return 0, false
+	}
+	delay := p.Backoff
+	for i := 0; i < restarts && delay < p.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	return delay, true
+}
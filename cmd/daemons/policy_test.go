@@ -0,0 +1,47 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPolicyRetry(t *testing.T) {
+	failed := errors.New("exit status 1")
+	// like the original respawn, the default restarts clean exits too,
+	// although a build without the "restarts" tag has a Limit of 0
+	if _, retry := DefaultPolicy.Retry(0, nil); retry !=
+		(DefaultPolicy.Limit != 0) {
+		t.Error("default policy restart of a clean exit:", retry)
+	}
+	p := Policy{
+		Restart:    RespawnOnFailure,
+		Limit:      3,
+		Backoff:    time.Second,
+		MaxBackoff: 3 * time.Second,
+	}
+	if _, retry := p.Retry(0, nil); retry {
+		t.Error("on-failure restarted a clean exit")
+	}
+	for restarts, want := range []time.Duration{
+		time.Second,
+		2 * time.Second,
+		3 * time.Second,
+	} {
+		if delay, retry := p.Retry(restarts, failed); !retry ||
+			delay != want {
+			t.Error(restarts, "restarts:", delay, retry)
+		}
+	}
+	if _, retry := p.Retry(3, failed); retry {
+		t.Error("restarted beyond the limit")
+	}
+	p.Restart = RespawnNever
+	if _, retry := p.Retry(0, failed); retry {
+		t.Error("never restarted")
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/goes/external/redis"
+)
+
+// A Condition is a readiness or liveness test evaluated by the supervisor.
+type Condition interface {
+	Ready() bool
+	String() string
+}
+
+// HashKey is ready when the redis (Key, Field) has Value or, if Value is
+// empty, any value. An empty Key is the redis.DefaultHash.
+type HashKey struct {
+	Key, Field, Value string
+}
+
+// Socket is ready when the named file exists or, with a leading '@', the
+// named abstract socket accepts a connection.
+type Socket string
+
+// Func is ready when it returns nil.
+type Func func() error
+
+// Probe declares the conditions that the supervisor periodically evaluates
+// for a running daemon.
+type Probe struct {
+	// Ready lists the conditions that must all hold for this daemon to be
+	// considered ready by its dependents. A daemon without conditions is
+	// ready once started.
+	Ready []Condition
+
+	// Live lists the conditions that must all hold for the daemon to be
+	// considered alive. After Failures consecutive failures, the
+	// supervisor quits the daemon and respawns it per its Policy.
+	Live []Condition
+
+	// Interval between evaluations, default: DefaultProbeInterval
+	Interval time.Duration
+
+	// Failures of Live before quitting the daemon, default: 3
+	Failures int
+}
+
+const DefaultProbeInterval = 5 * time.Second
+
+func (c HashKey) Ready() bool {
+	s, err := redis.Hget(c.Key, c.Field)
+	if err != nil || len(s) == 0 {
+		return false
+	}
+	return len(c.Value) == 0 || s == c.Value
+}
+
+func (c HashKey) String() string {
+	key := c.Key
+	if len(key) == 0 {
+		key = redis.DefaultHash
+	}
+	if len(c.Value) == 0 {
+		return fmt.Sprintf("(%s,%s)", key, c.Field)
+	}
+	return fmt.Sprintf("(%s,%s) == %q", key, c.Field, c.Value)
+}
+
+func (c Socket) Ready() bool {
+	if strings.HasPrefix(string(c), "@") {
+		conn, err := net.Dial("unix", string(c))
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}
+	_, err := os.Stat(string(c))
+	return err == nil
+}
+
+func (c Socket) String() string { return string(c) }
+
+func (f Func) Ready() bool { return f() == nil }
+
+func (f Func) String() string { return "func" }
+
+// ParseCondition returns a Socket for "@NAME" or "/PATH"; otherwise, a
+// HashKey for "[KEY ]FIELD[=VALUE]".
+func ParseCondition(s string) (Condition, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "@") || strings.HasPrefix(s, "/") {
+		return Socket(s), nil
+	}
+	var c HashKey
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		c.Field = fields[0]
+	case 2:
+		c.Key, c.Field = fields[0], fields[1]
+	default:
+		return nil, fmt.Errorf("%q isn't a condition", s)
+	}
+	if i := strings.Index(c.Field, "="); i >= 0 {
+		c.Field, c.Value = c.Field[:i], c.Field[i+1:]
+	}
+	if len(c.Field) == 0 {
+		return nil, fmt.Errorf("%q: missing field", s)
+	}
+	return c, nil
+}
+
+// probeReady evaluates the named daemon's readiness conditions and publishes
+// any change as goes.daemon.NAME.ready.
+func (d *Daemons) probeReady(name string) error {
+	var err error
+	for _, c := range d.probes[name].Ready {
+		if !c.Ready() {
+			err = fmt.Errorf("%s: waiting for %s", name, c)
+			break
+		}
+	}
+	d.setReady(name, err == nil)
+	return err
+}
+
+func (d *Daemons) setReady(name string, ready bool) {
+	d.mutex.Lock()
+	was, found := d.readiness[name]
+	d.readiness[name] = ready
+	d.mutex.Unlock()
+	if !found || was != ready {
+		d.publish(name, "ready", ready)
+	}
+}
+
+// probe the given daemon process until it exits or the supervisor stops.
+func (d *Daemons) probe(pid int, name string) {
+	probe := d.probes[name]
+	interval := probe.Interval
+	if interval <= 0 {
+		interval = DefaultProbeInterval
+	}
+	failures := probe.Failures
+	if failures <= 0 {
+		failures = 3
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for n := 0; ; {
+		if d.cmd(pid) == nil {
+			if !d.running(name) {
+				d.setReady(name, false)
+			}
+			return
+		}
+		d.probeReady(name)
+		var dead Condition
+		for _, c := range probe.Live {
+			if !c.Ready() {
+				dead = c
+				break
+			}
+		}
+		if dead == nil {
+			n = 0
+		} else if n++; n >= failures {
+			// SIGQUIT has a go daemon dump its goroutines to the
+			// captured stderr before its respawn
+			logger.Err("not live", "daemon", name, "pid", pid,
+				"probe", dead)
+			d.publish(name, "live", false)
+			syscall.Kill(pid, syscall.SIGQUIT)
+			n = 0
+		}
+		select {
+		case <-d.done:
+			return
+		case <-t.C:
+		}
+	}
+}
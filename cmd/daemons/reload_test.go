@@ -0,0 +1,47 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"testing"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/lang"
+)
+
+type daemon struct{ name string }
+
+func (d daemon) String() string          { return d.name }
+func (daemon) Usage() string             { return "" }
+func (daemon) Apropos() lang.Alt         { return lang.Alt{} }
+func (daemon) Main(args ...string) error { return nil }
+func (daemon) Kind() cmd.Kind            { return cmd.Daemon }
+
+type reloader struct{ daemon }
+
+func (reloader) Reload() error { return nil }
+
+func TestIsReloader(t *testing.T) {
+	d := &Daemons{
+		goes: &goes.Goes{
+			ByName: map[string]cmd.Cmd{
+				"redisd": reloader{daemon{"redisd"}},
+				"vnetd":  daemon{"vnetd"},
+			},
+		},
+		externals: map[string][]string{"frr": {"/usr/lib/frr/watchfrr"}},
+	}
+	for name, want := range map[string]bool{
+		"redisd": true,
+		"vnetd":  false,
+		"frr":    false,
+		"sshd":   false,
+	} {
+		if got := d.isReloader(name); got != want {
+			t.Error(name, "got", got, "want", want)
+		}
+	}
+}
@@ -8,30 +8,90 @@ import (
 	"net/rpc"
 	"os"
 	"os/signal"
+	"sort"
 	"syscall"
 	"time"
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/lang"
 )
 
 type Server struct {
 	// Machines list goes command + args for daemons that run from start,
-	// including redisd.  Note that dependent daemons should wait on a
-	// respective redis key, e.g.
-	//	redis.Hwait(redis.DefaultHash, "redis.ready", "true", TIMEOUT)
-	// or
-	//	redis.IsReady()
+	// including redisd.  Rather than having dependent daemons wait on a
+	// respective redis key, machines should declare the dependency in
+	// Depends and the readiness in Probes, e.g.
+	//	Depends: map[string]daemons.Depend{
+	//		"vnetd": {After: []string{"redisd"}},
+	//	},
+	//	Probes: map[string]daemons.Probe{
+	//		"redisd": {
+	//			Ready: []daemons.Condition{
+	//				daemons.HashKey{
+	//					Field: "redis.ready",
+	//					Value: "true",
+	//				},
+	//			},
+	//			Live: []daemons.Condition{
+	//				daemons.Socket("@redisd"),
+	//			},
+	//		},
+	//	}
 	Init [][]string
+
+	// Machines may map daemon names to their prerequisites. The
+	// supervisor starts Init daemons in dependency order and waits for
+	// each prerequisite to be ready before starting its dependents.
+	Depends map[string]Depend
+
+	// Machines may map daemon names to readiness and liveness probes
+	// that the supervisor evaluates on an interval and publishes as
+	// goes.daemon.NAME.ready
+	Probes map[string]Probe
+
+	// Machines may map daemon names to the command line of an External
+	// program, e.g. snmpd or a vendor agent, that's supervised like the
+	// goes daemons with the same policies, logs, and published state.
+	// The program runs with an argv[0] of its daemon name. External
+	// daemons absent from Init start after those listed.
+	Externals map[string][]string
+
+	// Machines may map daemon names to NAME=VALUE environment variables,
+	// e.g. GOMAXPROCS=2, that the supervisor adds to those of its own
+	// that begin with GO. The daemon's file in EnvDir may override these.
+	Env map[string][]string
+
+	// Machines may map daemon names to cgroup v2 resource limits.
+	Limits map[string]Limits
+
+	// Machines may list daemons that have a stdin pipe for interactive
+	// debug consoles through `daemon attach -stdin NAME`.
+	Consoles []string
+
+	// Machines may set Serial to start each Init daemon after the
+	// previous rather than concurrently starting all daemons as soon as
+	// their dependencies are ready.
+	Serial bool
+
+	// Machines may override the DefaultLogConfig capture of each daemon's
+	// output; zero fields retain their default.
+	Logs LogConfig
+
+	// Machines may map daemon names to restart policies that override
+	// DefaultPolicy.
+	Policies map[string]Policy
+
 	Daemons
 }
 
 func (*Server) String() string { return "goes-daemons" }
 
 func (*Server) Usage() string {
-	return "goes-daemons [OPTIONS]..."
+	return "goes-daemons [-plan]"
 }
 
 func (*Server) Apropos() lang.Alt {
@@ -47,31 +107,110 @@ func (*Server) Kind() cmd.Kind { return cmd.Hidden }
 func (c *Server) Main(args ...string) error {
 	var err error
 
+	flag, args := flags.New(args, "-plan")
+
+	if err = machine.Err(); err != nil {
+		if flag.ByName["-plan"] {
+			return err
+		}
+		logger.Err("machine", "err", err)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	c.Daemons.policies = c.Policies
+	c.Daemons.depends = c.Depends
+	c.Daemons.probes = c.Probes
+	c.Daemons.limits = c.Limits
+	c.Daemons.consoles = make(map[string]bool)
+	for _, name := range c.Consoles {
+		c.Daemons.consoles[name] = true
+	}
+	c.Daemons.logs = c.Logs
+	c.Daemons.logs.setDefaults()
+
+	c.Daemons.externals = c.Externals
+	c.Daemons.envs = c.Env
+	all := append([][]string{}, c.Init...)
+	names := make([]string, 0, len(c.Externals))
+	for name := range c.Externals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		found := false
+		for _, args := range all {
+			if len(args) > 0 && args[0] == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			all = append(all, []string{name})
+		}
+	}
+
+	ordered, err := order(all, c.Depends)
+	if err != nil {
+		return err
+	}
+
+	if flag.ByName["-plan"] {
+		c.Daemons.plan(os.Stdout, ordered, c.Serial)
+		return nil
+	}
+
 	c.Daemons.init()
+	defer c.Daemons.closeLogs()
 
-	sig := make(chan os.Signal)
-	signal.Notify(sig, syscall.SIGTERM)
+	// SIGHUP, e.g. from a baseline `reload` of all goes processes,
+	// reloads the daemons rather than terminating the supervisor
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGHUP)
 	defer signal.Stop(sig)
 
-	c.rpc, err = atsock.NewRpcServer(sockname())
+	c.rpc, err = atsock.NewRpcServer(Sockname())
 	if err != nil {
 		return err
 	}
 	defer c.rpc.Close()
 
-	for _, dargs := range c.Init {
-		c.Daemons.start(0, dargs...)
-	}
-
 	rpc.Register(&c.Daemons)
 
+	if c.Serial {
+		for _, dargs := range ordered {
+			c.Daemons.wait(dargs[0])
+			c.Daemons.start(0, dargs...)
+		}
+	} else {
+		// each daemon starts as soon as those it's after are ready
+		for _, dargs := range ordered {
+			go func(dargs []string) {
+				c.Daemons.wait(dargs[0])
+				c.Daemons.mutex.Lock()
+				stopping := c.Daemons.stopping
+				c.Daemons.mutex.Unlock()
+				if !stopping {
+					c.Daemons.start(0, dargs...)
+				}
+			}(dargs)
+		}
+	}
+
 	for {
 		select {
 		case <-c.Daemons.done:
 			// delay for rpc Stop reply
 			time.Sleep(100 * time.Millisecond)
 			return nil
-		case <-sig:
+		case t := <-sig:
+			if t == syscall.SIGHUP {
+				err := c.Daemons.Reload([]string{}, &empty)
+				if err != nil {
+					logger.Err("reload", "err", err)
+				}
+				continue
+			}
 			c.Daemons.Stop([]string{}, &empty)
 			return nil
 		}
@@ -0,0 +1,89 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes/internal/buildinfo"
+	"github.com/platinasystems/goes/internal/prog"
+)
+
+// The supervisor maintains these fields of redis.DefaultHash for each
+// daemon, so that dashboards and status have one consistent source.
+//
+//	goes.daemon.NAME.state		a State
+//	goes.daemon.NAME.pid		process id; 0 if not running
+//	goes.daemon.NAME.since		RFC3339 time of the last state change
+//	goes.daemon.NAME.restarts	number of restarts
+//	goes.daemon.NAME.version	goes version of the running process;
+//					absent for External programs
+//
+// Along with those of its probes and crashes,
+//
+//	goes.daemon.NAME.ready		true or false
+//	goes.daemon.NAME.live		false after failing its liveness probe
+//	goes.daemon.NAME.crash		UNIXTIME REASON FILE
+//	goes.daemon.NAME.crashes	number of crash reports
+type State string
+
+const (
+	StateWaiting  State = "waiting"
+	StateRunning  State = "running"
+	StateBackoff  State = "backoff"
+	StateStopping State = "stopping"
+	StateStopped  State = "stopped"
+	StateExited   State = "exited"
+	StateFailed   State = "failed"
+)
+
+// StateField returns the redis field of the named daemon's state.
+func StateField(name string) string {
+	return "goes.daemon." + name + ".state"
+}
+
+// setState publishes the named daemon's state, pid, and since along with the
+// restarts and version of a running daemon.
+func (d *Daemons) setState(name string, state State, pid int) {
+	d.publish(name, "state", state)
+	d.publish(name, "pid", pid)
+	d.publish(name, "since", time.Now().Format(time.RFC3339))
+	if state == StateRunning {
+		d.mutex.Lock()
+		restarts := d.restarts[name]
+		d.mutex.Unlock()
+		d.publish(name, "restarts", restarts)
+		if _, external := d.externals[name]; !external {
+			d.publish(name, "version", d.version())
+		}
+	}
+}
+
+// version returns that of the installed goes program, which may differ from
+// the supervisor's after an upgrade.
+func (d *Daemons) version() string {
+	d.ver.Lock()
+	defer d.ver.Unlock()
+	fn := prog.Name()
+	fi, err := os.Stat(fn)
+	if err == nil && d.ver.s != "" && d.ver.fn == fn &&
+		fi.ModTime().Equal(d.ver.mod) {
+		return d.ver.s
+	}
+	s := buildinfo.New().Version()
+	if out, xerr := exec.Command(fn, "version").Output(); xerr == nil {
+		if v := strings.TrimSpace(string(out)); len(v) > 0 {
+			s = v
+		}
+	}
+	d.ver.s, d.ver.fn = s, fn
+	if err == nil {
+		d.ver.mod = fi.ModTime()
+	}
+	return s
+}
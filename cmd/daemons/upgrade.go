@@ -0,0 +1,77 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package daemons
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/goes/external/handoff"
+)
+
+// Upgrade replaces the listed, or all, daemons with instances of the
+// currently installed goes. Those offering a handoff pass their listening
+// sockets and state to the successor then exit on their own; the others are
+// restarted.
+func (d *Daemons) Upgrade(pidlist []string, reply *struct{}) (err error) {
+	var pids []int
+	if len(pidlist) == 0 {
+		d.mutex.Lock()
+		pids = make([]int, len(d.pids))
+		copy(pids, d.pids)
+		d.mutex.Unlock()
+	} else {
+		pids, err = d.pidlistToPids(pidlist)
+		if err != nil {
+			return err
+		}
+	}
+	for _, pid := range pids {
+		d.mutex.Lock()
+		args := d.cmdline(pid)
+		d.mutex.Unlock()
+		if len(args) == 0 {
+			continue
+		}
+		if !handoff.Offered(args[0]) {
+			err = d.Restart([]string{strconv.Itoa(pid)}, reply)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		logger.Info("upgrading", "daemon", args[0], "pid", pid,
+			"args", args)
+		// forget the predecessor so its exit isn't respawned
+		d.del(pid)
+		d.start(0, args...)
+		if err = d.retire(pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retire waits for a predecessor to exit after handoff; terminating it if it
+// takes too long.
+func (d *Daemons) retire(pid int) error {
+	const period = 100 * time.Millisecond
+	procdn := fmt.Sprint("/proc/", pid)
+	for t := time.Duration(0); t < DefaultTimeout; t += period {
+		if _, err := os.Stat(procdn); os.IsNotExist(err) {
+			return nil
+		}
+		time.Sleep(period)
+	}
+	logger.Err("didn't exit after handoff", "pid", pid)
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil &&
+		err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
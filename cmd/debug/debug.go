@@ -0,0 +1,116 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package debug provides the command to start and stop the pprof and expvar
+// server of a running daemon, or have it write a profile.
+package debug
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "debug" }
+
+func (Command) Usage() string {
+	return `debug DAEMON start [ADDRESS]
+debug DAEMON stop
+debug DAEMON status
+debug DAEMON profile [-seconds N] [-o FILE] [PROFILE]`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "profile a running daemon",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Each goes daemon has a Debug RPC server to profile it on demand,
+	without a restart with special flags.
+
+	The "start" form has the daemon serve /debug/pprof/ and /debug/vars
+	(expvar) at the loopback ADDRESS, by default 127.0.0.1 with any
+	available port, then prints the address. The "stop" form closes
+	this server and "status" prints its address, if running.
+
+	The "profile" form has the daemon write the PROFILE, default heap,
+	to a file, then prints its name. PROFILE is one of: cpu, heap,
+	allocs, goroutine, block, mutex, or threadcreate.
+
+OPTIONS
+	-seconds N
+		duration of the cpu profile, default 30
+	-o FILE
+		profile file, default /tmp/DAEMON-PROFILE-UNIXTIME.pprof
+
+EXAMPLES
+	goes debug vnetd start 127.0.0.1:6060
+	go tool pprof http://127.0.0.1:6060/debug/pprof/heap
+	goes debug vnetd stop
+	goes debug redisd profile -seconds 10 cpu`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-seconds", "-o")
+	if len(args) < 2 {
+		return fmt.Errorf("DAEMON and start|stop|status|profile: missing")
+	}
+	daemon, op, args := args[0], args[1], args[2:]
+	if len(args) > 1 || (len(args) > 0 && op != "start" &&
+		op != "profile") {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	cl, err := atsock.NewRpcClient(goes.DebugSocket(daemon))
+	if err != nil {
+		return fmt.Errorf("%s: %w", daemon, err)
+	}
+	defer cl.Close()
+	var reply string
+	switch op {
+	case "start":
+		var addr string
+		if len(args) > 0 {
+			addr = args[0]
+		}
+		err = cl.Call("Debug.Start", addr, &reply)
+	case "stop":
+		var empty struct{}
+		err = cl.Call("Debug.Stop", struct{}{}, &empty)
+	case "status":
+		err = cl.Call("Debug.Status", struct{}{}, &reply)
+		if err == nil && len(reply) == 0 {
+			reply = "stopped"
+		}
+	case "profile":
+		p := goes.DebugProfile{File: parm.ByName["-o"]}
+		if len(args) > 0 {
+			p.Name = args[0]
+		}
+		if s := parm.ByName["-seconds"]; len(s) > 0 {
+			if p.Seconds, err = strconv.Atoi(s); err != nil ||
+				p.Seconds <= 0 {
+				return fmt.Errorf("-seconds: %q invalid", s)
+			}
+		}
+		err = cl.Call("Debug.Profile", p, &reply)
+	default:
+		return fmt.Errorf("%s: unknown", op)
+	}
+	if err == nil && len(reply) > 0 {
+		fmt.Println(reply)
+	}
+	return err
+}
@@ -12,8 +12,8 @@ import (
 	"io/ioutil"
 	"math/bits"
 	"net"
-	"os"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 	"unsafe"
@@ -24,10 +24,14 @@ import (
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/log"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/external/redis/publisher"
 	"github.com/platinasystems/goes/lang"
 )
 
+var logger = log.New("dhcpcd")
+
 type Command struct {
 	g     *goes.Goes
 	myIP  string
@@ -37,6 +41,7 @@ type Command struct {
 	ack   dhcp4.Packet
 	cl    *dhcp4client.Client
 	i     string
+	pub   *publisher.Publisher
 }
 
 func (*Command) String() string { return "dhcpcd" }
@@ -49,6 +54,22 @@ func (*Command) Apropos() lang.Alt {
 	}
 }
 
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Request and renew a lease of the -i interface, default eth0, then
+	configure its address, default route, and /etc/resolv.conf. The
+	provisioning options of the lease, if any, are published for ztpd,
+		dhcpcd.IFNAME.url: URL			option 114
+		dhcpcd.IFNAME.server: NAME|ADDRESS	option 66 or siaddr
+		dhcpcd.IFNAME.bootfile: FILE		option 67 or file
+
+SEE ALSO
+	ztpd`,
+	}
+}
+
 func (c *Command) Goes(g *goes.Goes) { c.g = g }
 
 func (*Command) Kind() cmd.Kind { return cmd.Daemon }
@@ -64,15 +85,12 @@ func (c *Command) parseACK(ack dhcp4.Packet) (err error) {
 	c.myIP = ack.YIAddr().String()
 	opt := ack.ParseOptions()
 	nm := opt[1]
-	fmt.Printf("Option[1] netmask: %v\n", nm)
 	if len(nm) == 4 {
 		c.myIP = c.myIP + "/" + strconv.Itoa(bits.LeadingZeros32(^binary.BigEndian.Uint32(nm)))
 	}
-	fmt.Printf("Got address %s\n", c.myIP)
 
 	rtr := opt[3]
 	c.rtrIP = ""
-	fmt.Printf("Option[3] router: %v\n", rtr)
 	if len(rtr) == 4 {
 		ip := net.IP(rtr)
 		if !ip.Equal(net.IPv4(0, 0, 0, 0)) {
@@ -81,23 +99,57 @@ func (c *Command) parseACK(ack dhcp4.Packet) (err error) {
 	}
 
 	ltOpt := opt[51]
-	fmt.Printf("Got lease %v\n", ltOpt)
 	c.lt = uint32(86400)
 	if len(ltOpt) == 4 {
 		c.lt = binary.BigEndian.Uint32(ltOpt)
-		fmt.Printf("Lease time %d\n", c.lt)
 	}
 	dns := opt[6]
-	fmt.Printf("Got DNS %v\n", dns)
 	c.dnsIP = ""
+	var servers []string
 	for i := 0; i < len(dns) && len(dns[i:]) >= 4; i += 4 {
-		c.dnsIP = c.dnsIP + "nameserver " + net.IP(dns[i:i+4]).String() + "\n"
+		servers = append(servers, net.IP(dns[i:i+4]).String())
+		c.dnsIP = c.dnsIP + "nameserver " + servers[len(servers)-1] + "\n"
 	}
-	fmt.Printf("DNS resolved to %v\n", c.dnsIP)
-
+	logger.Info("ack", "ifname", c.i, "address", c.myIP,
+		"router", c.rtrIP, "lease", c.lt, "dns", servers)
+	c.publishProvisioning(ack, opt)
 	return
 }
 
+// publishProvisioning options of the lease, if redisd is running; a later
+// lease without an option deletes its field.
+func (c *Command) publishProvisioning(ack dhcp4.Packet,
+	opt dhcp4.Options) {
+	if c.pub == nil {
+		var err error
+		if c.pub, err = publisher.New(); err != nil {
+			logger.Debug("publisher", "err", err)
+			c.pub = nil
+			return
+		}
+	}
+	server := string(opt[66])
+	if siaddr := ack.SIAddr(); len(server) == 0 && !siaddr.IsUnspecified() {
+		server = siaddr.String()
+	}
+	bootfile := string(opt[67])
+	if len(bootfile) == 0 && len(ack) >= 236 {
+		bootfile = string(ack[108:236])
+	}
+	prefix := "dhcpcd." + c.i + "."
+	for _, x := range []struct{ name, value string }{
+		{"url", string(opt[114])},
+		{"server", server},
+		{"bootfile", bootfile},
+	} {
+		if v := strings.TrimRight(x.value, "\x00"); len(v) > 0 {
+			c.pub.Print(prefix, x.name, ": ", v)
+		} else {
+			c.pub.Print("delete: ", prefix, x.name)
+		}
+	}
+}
+
 func (c *Command) updateParm(myIP string, myLastIP string, rtrIP string,
 	rtrLastIP string, dnsIP string, dnsLastIP string) (err error) {
 	if myIP != myLastIP {
@@ -141,6 +193,11 @@ func (c *Command) updateParm(myIP string, myLastIP string, rtrIP string,
 
 func (c *Command) Main(args ...string) error {
 	parm, args := parms.New(args, "-i")
+	defer func() {
+		if c.pub != nil {
+			c.pub.Close()
+		}
+	}()
 	c.i = "eth0"
 	if parm.ByName["-i"] != "" {
 		c.i = parm.ByName["-i"]
@@ -165,7 +222,7 @@ func (c *Command) Main(args ...string) error {
 	}
 	mac := net.HardwareAddr(dev.ifrNewname[2:8])
 
-	fmt.Printf("Got %s\n", mac)
+	logger.Debug("hardware address", "ifname", c.i, "mac", mac)
 
 	err = c.g.Main("ip", "link", "change", c.i, "up")
 	if err != nil {
@@ -198,7 +255,7 @@ func (c *Command) Main(args ...string) error {
 		if c.ack != nil && c.myIP != "" {
 			err := c.cl.Release(c.ack)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error in release: %s\n", err)
+				logger.Err("release", "ifname", c.i, "err", err)
 			}
 		}
 		c.updateParm("", c.myIP, "", c.rtrIP, "", c.dnsIP)
@@ -243,17 +300,17 @@ func (c *Command) Main(args ...string) error {
 							if exit {
 								return nil
 							}
-							fmt.Fprintf(os.Stderr, "Error in renew: %s\n", err)
+							logger.Err("renew", "ifname", c.i, "err", err)
 						} else {
-							fmt.Fprintf(os.Stderr, "Error in updateParm: %s\n", err)
+							logger.Err("update", "ifname", c.i, "err", err)
 						}
 					}
 				} else {
-					fmt.Fprintf(os.Stderr, "Error in parseACK: %s\n", err)
+					logger.Err("ack", "ifname", c.i, "err", err)
 				}
 			}
 		} else {
-			fmt.Fprintf(os.Stderr, "Error in Request: %s\n", err)
+			logger.Err("request", "ifname", c.i, "err", err)
 		}
 
 		if !func() bool {
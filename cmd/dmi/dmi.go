@@ -0,0 +1,105 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package dmi provides a command and redisd hook that decode the SMBIOS
+// tables for the BIOS, board, processor, and memory inventory.
+package dmi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Table is the kernel's export of the SMBIOS structure table.
+var Table = "/sys/firmware/dmi/tables/DMI"
+
+type Command struct{}
+
+func (Command) String() string { return "dmi" }
+
+func (Command) Usage() string { return "dmi [PREFIX]..." }
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show SMBIOS inventory",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Decode and print the BIOS, system, board, chassis, processor, and
+	memory structures of the SMBIOS table as the same fields that
+	machines publish to redis through the dmi RedisdHook, e.g.
+		dmi.bios.version: 5.6.5
+		dmi.board.serial: QTFCQ714A0045
+		dmi.cpu.1.version: Intel(R) Atom(TM) CPU C2558 @ 2.40GHz
+		dmi.cpu.1.cores: 4
+		dmi.memory.1.size: 8192 MB
+		dmi.memory.total: 8192 MB
+
+	With arguments, only print the fields with these prefixes, e.g.
+		dmi bios. memory.total
+
+FILES
+	/sys/firmware/dmi/tables/DMI
+
+SEE ALSO
+	eeprom`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	fields, err := Read()
+	if err != nil {
+		return err
+	}
+	for _, s := range fields {
+		if len(args) == 0 {
+			fmt.Println(s)
+		}
+		for _, prefix := range args {
+			if strings.HasPrefix(s, "dmi."+prefix) {
+				fmt.Println(s)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Read and decode the SMBIOS Table.
+func Read() ([]string, error) {
+	b, err := ioutil.ReadFile(Table)
+	if err != nil {
+		return nil, err
+	}
+	l, err := Parse(b)
+	if err != nil && len(l) == 0 {
+		return nil, fmt.Errorf("%s: %v", Table, err)
+	}
+	return Fields(l), nil
+}
+
+// RedisdHook publishes the dmi.* fields alongside those of the eeprom.
+// Machines without SMBIOS, e.g. those without an x86 BIOS, may still use
+// this as it publishes nothing without the table.
+func RedisdHook(pub *publisher.Publisher) {
+	fields, err := Read()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Fprintln(os.Stderr, "dmi:", err)
+		}
+		return
+	}
+	for _, s := range fields {
+		pub.Print(s)
+	}
+}
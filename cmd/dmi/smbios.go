@@ -0,0 +1,222 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dmi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// SMBIOS structure types
+const (
+	BiosType      = 0
+	SystemType    = 1
+	BoardType     = 2
+	ChassisType   = 3
+	ProcessorType = 4
+	MemoryType    = 17
+	EndType       = 127
+)
+
+// Structure is an SMBIOS structure with its formatted area, including the
+// 4 byte header, and strings.
+type Structure struct {
+	Type      uint8
+	Handle    uint16
+	Formatted []byte
+	Strings   []string
+}
+
+// Parse the SMBIOS structure table, e.g. /sys/firmware/dmi/tables/DMI
+func Parse(b []byte) ([]Structure, error) {
+	var l []Structure
+	for len(b) >= 4 {
+		n := int(b[1])
+		if n < 4 || n > len(b) {
+			return l, fmt.Errorf("type %d: invalid length %d", b[0], n)
+		}
+		s := Structure{
+			Type:      b[0],
+			Handle:    binary.LittleEndian.Uint16(b[2:4]),
+			Formatted: b[:n],
+		}
+		b = b[n:]
+		// the strings end with a double NUL, as does an empty set
+		end := strings.Index(string(b), "\x00\x00")
+		if end < 0 {
+			return l, fmt.Errorf("type %d: unterminated strings", s.Type)
+		}
+		if end > 0 {
+			s.Strings = strings.Split(string(b[:end]), "\x00")
+		}
+		b = b[end+2:]
+		if s.Type == EndType {
+			break
+		}
+		l = append(l, s)
+	}
+	return l, nil
+}
+
+// String returns the string referenced by the byte at the given offset of
+// the formatted area.
+func (s *Structure) String(offset int) string {
+	i := int(s.Byte(offset))
+	if i == 0 || i > len(s.Strings) {
+		return ""
+	}
+	return strings.TrimSpace(s.Strings[i-1])
+}
+
+func (s *Structure) Byte(offset int) uint8 {
+	if offset >= len(s.Formatted) {
+		return 0
+	}
+	return s.Formatted[offset]
+}
+
+func (s *Structure) Word(offset int) uint16 {
+	if offset+2 > len(s.Formatted) {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(s.Formatted[offset:])
+}
+
+func (s *Structure) Dword(offset int) uint32 {
+	if offset+4 > len(s.Formatted) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(s.Formatted[offset:])
+}
+
+// Uuid formats the system UUID per SMBIOS 2.6, i.e. with the first three
+// fields little endian.
+func (s *Structure) Uuid(offset int) string {
+	if offset+16 > len(s.Formatted) {
+		return ""
+	}
+	u := s.Formatted[offset : offset+16]
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(u[0:4]),
+		binary.LittleEndian.Uint16(u[4:6]),
+		binary.LittleEndian.Uint16(u[6:8]),
+		u[8:10], u[10:])
+}
+
+var memoryTypes = map[uint8]string{
+	0x12: "DDR",
+	0x13: "DDR2",
+	0x18: "DDR3",
+	0x1a: "DDR4",
+	0x1b: "LPDDR",
+	0x1c: "LPDDR2",
+	0x1d: "LPDDR3",
+	0x1e: "LPDDR4",
+	0x22: "DDR5",
+	0x23: "LPDDR5",
+}
+
+// MemorySize returns the MB of a memory device structure; 0 if empty.
+func (s *Structure) MemorySize() uint64 {
+	size := s.Word(0x0c)
+	switch {
+	case size == 0 || size == 0xffff:
+		return 0
+	case size == 0x7fff:
+		return uint64(s.Dword(0x1c) & 0x7fffffff)
+	case size&0x8000 != 0:
+		// KB
+		return uint64(size&0x7fff) / 1024
+	}
+	return uint64(size)
+}
+
+// Fields returns the "dmi.*: VALUE" fields of the BIOS, system, board,
+// chassis, processor, and memory structures.
+func Fields(l []Structure) []string {
+	var fields []string
+	field := func(name, value string) {
+		if len(value) > 0 {
+			fields = append(fields, "dmi."+name+": "+value)
+		}
+	}
+	var cpus, dimms int
+	var total uint64
+	for i := range l {
+		s := &l[i]
+		switch s.Type {
+		case BiosType:
+			field("bios.vendor", s.String(0x04))
+			field("bios.version", s.String(0x05))
+			field("bios.date", s.String(0x08))
+		case SystemType:
+			field("system.manufacturer", s.String(0x04))
+			field("system.product", s.String(0x05))
+			field("system.version", s.String(0x06))
+			field("system.serial", s.String(0x07))
+			field("system.uuid", s.Uuid(0x08))
+		case BoardType:
+			field("board.manufacturer", s.String(0x04))
+			field("board.product", s.String(0x05))
+			field("board.version", s.String(0x06))
+			field("board.serial", s.String(0x07))
+			field("board.asset_tag", s.String(0x08))
+		case ChassisType:
+			field("chassis.manufacturer", s.String(0x04))
+			field("chassis.version", s.String(0x06))
+			field("chassis.serial", s.String(0x07))
+			field("chassis.asset_tag", s.String(0x08))
+		case ProcessorType:
+			// skip unpopulated sockets
+			if s.Byte(0x18)&(1<<6) == 0 {
+				continue
+			}
+			cpus++
+			prefix := fmt.Sprint("cpu.", cpus, ".")
+			field(prefix+"socket", s.String(0x04))
+			field(prefix+"manufacturer", s.String(0x07))
+			field(prefix+"version", s.String(0x10))
+			if mhz := s.Word(0x14); mhz > 0 {
+				field(prefix+"max_speed", fmt.Sprint(mhz, " MHz"))
+			}
+			if mhz := s.Word(0x16); mhz > 0 {
+				field(prefix+"speed", fmt.Sprint(mhz, " MHz"))
+			}
+			if n := s.Byte(0x23); n > 0 {
+				field(prefix+"cores", fmt.Sprint(n))
+			}
+			if n := s.Byte(0x25); n > 0 {
+				field(prefix+"threads", fmt.Sprint(n))
+			}
+		case MemoryType:
+			size := s.MemorySize()
+			if size == 0 {
+				continue
+			}
+			dimms++
+			total += size
+			prefix := fmt.Sprint("memory.", dimms, ".")
+			field(prefix+"locator", s.String(0x10))
+			field(prefix+"bank", s.String(0x11))
+			field(prefix+"size", fmt.Sprint(size, " MB"))
+			field(prefix+"type", memoryTypes[s.Byte(0x12)])
+			if mts := s.Word(0x15); mts > 0 {
+				field(prefix+"speed", fmt.Sprint(mts, " MT/s"))
+			}
+			field(prefix+"manufacturer", s.String(0x17))
+			field(prefix+"serial", s.String(0x18))
+			field(prefix+"part_number", s.String(0x1a))
+		}
+	}
+	if cpus > 0 {
+		field("cpu.count", fmt.Sprint(cpus))
+	}
+	if dimms > 0 {
+		field("memory.count", fmt.Sprint(dimms))
+		field("memory.total", fmt.Sprint(total, " MB"))
+	}
+	return fields
+}
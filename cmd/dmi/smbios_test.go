@@ -0,0 +1,53 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package dmi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	bios := []byte{BiosType, 0x18, 0, 0, 1, 2, 0, 0, 3}
+	bios = append(bios, make([]byte, 0x18-len(bios))...)
+	bios = append(bios, "Acme\x005.6.5\x0001/02/2020\x00\x00"...)
+	mem := make([]byte, 0x28)
+	mem[0], mem[1] = MemoryType, 0x28
+	mem[0x0c], mem[0x0d] = 0x00, 0x20 // 8192 MB
+	mem[0x10] = 1
+	mem[0x12] = 0x1a
+	mem[0x15], mem[0x16] = 0x60, 0x09 // 2400 MT/s
+	mem = append(mem, "DIMM0\x00\x00"...)
+	empty := make([]byte, 0x28)
+	empty[0], empty[1] = MemoryType, 0x28
+	empty = append(empty, 0, 0)
+	end := []byte{EndType, 4, 0, 0, 0, 0}
+
+	var b []byte
+	for _, s := range [][]byte{bios, mem, empty, end} {
+		b = append(b, s...)
+	}
+	l, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 3 {
+		t.Fatalf("%d structures", len(l))
+	}
+	want := []string{
+		"dmi.bios.vendor: Acme",
+		"dmi.bios.version: 5.6.5",
+		"dmi.bios.date: 01/02/2020",
+		"dmi.memory.1.locator: DIMM0",
+		"dmi.memory.1.size: 8192 MB",
+		"dmi.memory.1.type: DDR4",
+		"dmi.memory.1.speed: 2400 MT/s",
+		"dmi.memory.count: 1",
+		"dmi.memory.total: 8192 MB",
+	}
+	if got := Fields(l); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q\nwant %q", got, want)
+	}
+}
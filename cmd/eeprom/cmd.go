@@ -5,17 +5,23 @@
 package eeprom
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/signal"
 	"sort"
-	"time"
+	"strings"
 
 	"github.com/platinasystems/goes/external/flags"
 	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/lang"
 )
 
+var stdin = bufio.NewReader(os.Stdin)
+
 type Command struct {
 	Config func()
 }
@@ -23,7 +29,10 @@ type Command struct {
 func (Command) String() string { return "eeprom" }
 
 func (Command) Usage() string {
-	return "eeprom [-n] [-FIELD | FIELD=VALUE]..."
+	return `
+	eeprom [set] [-n] [-y] [-FIELD | FIELD=VALUE]...
+	eeprom -raw FILE [-n] [-y]
+	eeprom -repair [-n] [-y]`
 }
 
 func (Command) Apropos() lang.Alt {
@@ -38,12 +47,42 @@ func (Command) Man() lang.Alt {
 DESCRIPTION
 	Show, delete or modify system eeprom fields.
 
-	-n     dry-run to show modifications
+	Without any args, show current eeprom configuation.
+
+	An eeprom with the ONIE TlvInfo header is written in TLV type order
+	with a recomputed CRC-32; otherwise, it retains the Platina layout.
+
+	The shown, and published, eeprom.valid is false if the read image has
+	a CRC mismatch, bad header, or malformed field, each detailed as
+	eeprom.invalid.FIELD.
+
+	FIELD may be either the TLV type name, e.g. SerialNumber, or its
+	lower case, underscore separated equivalent, e.g. serial_number.
+	Values are validated per field; for example, BaseEthernetAddress must
+	be a MAC address and ManufactureDate, "MM/DD/YYYY hh:mm:ss".
+
+OPTIONS
+	-n	dry-run to show modifications
 
-	-vendor-extension 
+	-y	write without confirmation
+
+	-raw FILE
+		write the eeprom image from FILE, or "-" for stdin, as is
+
+	-repair	prompt for the replacement, or deletion, of each invalid
+		field then rewrite the eeprom with a recomputed CRC
+
+	-vendor-extension
 		set, modify, or delete vendor sub-fields
 
-	Without any args, show current eeprom configuation.`,
+EXAMPLES
+	eeprom set serial_number=XYZ1234 -ManufactureDate
+	eeprom -raw /tmp/eeprom.bin
+
+WRITE PROTECT
+	Writes require a goes built with the "diag" tag. The machine's
+	write-protect, if any, is released during the write and the eeprom is
+	read back to verify.`,
 	}
 }
 
@@ -64,12 +103,18 @@ func (c Command) Main(args ...string) error {
 	if c.Config != nil {
 		c.Config()
 	}
-	nargs := len(args)
+	if len(args) > 0 && args[0] == "set" {
+		args = args[1:]
+	}
+	args = fieldArgs(args)
 	flag, args := flags.New(args, c.flags()...)
 	parm, args := parms.New(args, c.parms()...)
 	if len(args) > 0 {
 		return fmt.Errorf("%v: unexpected", args)
 	}
+	if fn := parm.ByName["-raw"]; len(fn) > 0 {
+		return c.raw(fn, flag.ByName["-n"], flag.ByName["-y"])
+	}
 	buf, err := Vendor.ReadBytes()
 	if err != nil {
 		return err
@@ -78,27 +123,29 @@ func (c Command) Main(args ...string) error {
 	if err != nil {
 		return err
 	}
+	if flag.ByName["-repair"] {
+		return c.repair(&eeprom, flag.ByName["-n"], flag.ByName["-y"])
+	}
+	modified := false
 	for k, t := range flag.ByName {
-		if k != "-n" && t {
+		if k != "-n" && k != "-y" && k != "-repair" && t {
 			eeprom.Del(k[1:])
+			modified = true
 		}
 	}
 	for k, s := range parm.ByName {
-		if len(s) == 0 {
+		if len(s) == 0 || k == "-raw" {
 			continue
 		}
 		if err = eeprom.Set(k, s); err != nil {
-			return err
+			return fmt.Errorf("%s: %v", k, err)
 		}
-
+		modified = true
 	}
 	os.Stdout.WriteString(eeprom.String())
-	if nargs == 0 || flag.ByName["-n"] {
+	if !modified || flag.ByName["-n"] {
 		return nil
 	}
-	if !WriteEnable {
-		return fmt.Errorf("write disabled")
-	}
 	clone, err := eeprom.Clone()
 	if err != nil {
 		return err
@@ -107,33 +154,181 @@ func (c Command) Main(args ...string) error {
 	if err != nil {
 		return err
 	}
-	fmt.Print("Write in  ")
-	for i := 5; i > 0; i-- {
-		fmt.Print("\b", i)
-		time.Sleep(time.Second)
+	return c.write(eeprom.Bytes(), flag.ByName["-y"])
+}
+
+// raw validates then writes the given image file.
+func (c Command) raw(fn string, dryrun, yes bool) error {
+	var (
+		buf    []byte
+		err    error
+		eeprom Eeprom
+	)
+	if fn == "-" {
+		buf, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		buf, err = ioutil.ReadFile(fn)
+	}
+	if err != nil {
+		return err
+	}
+	if len(buf) < HeaderSz {
+		return fmt.Errorf("%s: too short", fn)
+	}
+	n := HeaderSz + int(binary.BigEndian.Uint16(buf[LenOffset:]))
+	if n > len(buf) {
+		return fmt.Errorf("%s: truncated, %d of %d bytes", fn, len(buf), n)
+	}
+	if _, err = eeprom.Write(buf[:n]); err != nil {
+		return fmt.Errorf("%s: %v", fn, err)
+	}
+	os.Stdout.WriteString(eeprom.String())
+	if dryrun {
+		return nil
+	}
+	return c.write(buf[:n], yes)
+}
+
+// repair each invalid field per the operator then rewrite the eeprom.
+func (c Command) repair(eeprom *Eeprom, dryrun, yes bool) error {
+	problems := eeprom.Problems()
+	if len(problems) == 0 {
+		fmt.Println("eeprom: valid")
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Println(problem)
+		if _, found := typesByName[problem.Field]; !found ||
+			problem.Field == "Crc" {
+			continue
+		}
+		for {
+			fmt.Print("New ", problem.Field, " (empty to delete): ")
+			s, err := stdin.ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("canceled")
+			}
+			if s = strings.TrimSpace(s); len(s) == 0 {
+				eeprom.Del(problem.Field)
+				break
+			}
+			if err = eeprom.Set(problem.Field, s); err == nil {
+				break
+			}
+			fmt.Print(problem.Field, ": ", err, "\n")
+		}
+	}
+	if eeprom.IsOnie() {
+		*eeprom.Onie.Version = OnieVersion
+	}
+	buf := eeprom.Bytes()
+	var repaired Eeprom
+	if _, err := repaired.Write(buf); err != nil {
+		return err
+	}
+	os.Stdout.WriteString(repaired.String())
+	if !repaired.Valid() {
+		return fmt.Errorf("still invalid")
+	}
+	if dryrun {
+		return nil
+	}
+	return c.write(buf, yes)
+}
+
+// write the eeprom after confirmation then read it back to verify.
+func (Command) write(buf []byte, yes bool) error {
+	if !WriteEnable {
+		return fmt.Errorf("write disabled")
+	}
+	if Vendor.Write == nil {
+		return fmt.Errorf("write unsupported")
+	}
+	if !yes && !confirm("Write eeprom? [y/N] ") {
+		return fmt.Errorf("canceled")
+	}
+	if Vendor.WriteProtect != nil {
+		if err := Vendor.WriteProtect(false); err != nil {
+			return fmt.Errorf("write protect: %v", err)
+		}
+		defer Vendor.WriteProtect(true)
 	}
-	fmt.Print("\rWriting...")
 	signal.Ignore(os.Interrupt)
-	_, err = Vendor.Write(eeprom.Bytes())
+	defer signal.Reset(os.Interrupt)
+	fmt.Print("Writing...")
+	_, err := Vendor.Write(buf)
 	fmt.Print("\r          \r")
-	return err
+	if err != nil {
+		return err
+	}
+	readback, err := Vendor.ReadBytes()
+	if err != nil {
+		return fmt.Errorf("verify: %v", err)
+	}
+	if !bytes.Equal(readback, buf) {
+		return fmt.Errorf("verify: mismatch")
+	}
+	return nil
+}
+
+func confirm(prompt string) bool {
+	fmt.Print(prompt)
+	s, _ := stdin.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// fieldArgs converts lower case, underscore separated field names to their
+// TLV type name, e.g. serial_number=XYZ to SerialNumber=XYZ.
+func fieldArgs(args []string) []string {
+	for i, arg := range args {
+		prefix := ""
+		if strings.HasPrefix(arg, "-") {
+			prefix, arg = "-", arg[1:]
+		}
+		name, value := arg, ""
+		if eq := strings.Index(arg, "="); eq > 0 {
+			name, value = arg[:eq], arg[eq:]
+		} else if len(prefix) == 0 {
+			continue
+		}
+		if strings.ToLower(name) != name || name == "n" || name == "y" ||
+			name == "raw" || name == "repair" ||
+			strings.Contains(name, ".") {
+			continue
+		}
+		words := strings.FieldsFunc(name, func(r rune) bool {
+			return r == '_'
+		})
+		for j, w := range words {
+			words[j] = strings.Title(w)
+		}
+		args[i] = prefix + strings.Join(words, "") + value
+	}
+	return args
 }
 
 func (Command) flags() []interface{} {
-	a := make([]interface{}, 1+len(Types))
+	a := make([]interface{}, 3+len(Types))
 	a[0] = "-n"
+	a[1] = "-y"
+	a[2] = "-repair"
 	for i, t := range Types {
-		a[1+i] = fmt.Sprint("-", t)
+		a[3+i] = fmt.Sprint("-", t)
 	}
 	return a
 }
 
 func (Command) parms() []interface{} {
-	a := make([]interface{}, 2+len(Types))
-	a[0] = "Onie.Data"
-	a[1] = "Onie.Version"
+	a := make([]interface{}, 3+len(Types))
+	a[0] = "-raw"
+	a[1] = "Onie.Data"
+	a[2] = "Onie.Version"
 	for i, t := range Types {
-		a[2+i] = t.String()
+		a[3+i] = t.String()
 	}
 	return a
 }
@@ -8,6 +8,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"strings"
+	"time"
 )
 
 const (
@@ -16,21 +18,34 @@ const (
 	HeaderSz   = LenOffset + 2
 )
 
+// ManufactureDateFormat is the time layout of ONIE's "MM/DD/YYYY hh:mm:ss"
+const ManufactureDateFormat = "01/02/2006 15:04:05"
+
 type Eeprom struct {
 	Onie struct {
 		Data    *OnieData
 		Version *Hex8
 	}
 	Tlv TlvMap
+
+	problems []Problem
 }
 
+// Bytes returns the eeprom image of type ordered TLVs. An ONIE image, or
+// any with a Crc, ends with a recomputed Crc.
 func (p *Eeprom) Bytes() []byte {
 	buf := new(bytes.Buffer)
 	buf.Write(p.Onie.Data[:])
 	buf.WriteByte(byte(*p.Onie.Version))
 	tlvbytes := p.Tlv.Bytes()
-	binary.Write(buf, binary.BigEndian, uint16(len(tlvbytes)))
+	if _, found := p.Tlv[CrcType]; !found && !p.IsOnie() {
+		binary.Write(buf, binary.BigEndian, uint16(len(tlvbytes)))
+		buf.Write(tlvbytes)
+		return buf.Bytes()
+	}
+	binary.Write(buf, binary.BigEndian, uint16(len(tlvbytes)+CrcSz))
 	buf.Write(tlvbytes)
+	p.onie(buf)
 	return buf.Bytes()
 }
 
@@ -71,7 +86,13 @@ func (p *Eeprom) Set(name, s string) (err error) {
 	default:
 		t, found := typesByName[name]
 		if !found {
-			err = p.Tlv[VendorExtensionType].(Setter).Set(name, s)
+			if method, found := p.Tlv[VendorExtensionType].(Setter); found {
+				return method.Set(name, s)
+			}
+			return fmt.Errorf("unknown field")
+		}
+		if err = validate(t, s); err != nil {
+			return
 		}
 		v := p.Tlv[t]
 		if v == nil {
@@ -91,11 +112,35 @@ func (p *Eeprom) Set(name, s string) (err error) {
 	return
 }
 
+// validate the string value of the given type before Set.
+func validate(t Type, s string) error {
+	if len(s) > 255 {
+		return fmt.Errorf("%d bytes exceeds max 255", len(s))
+	}
+	switch t {
+	case CrcType:
+		return fmt.Errorf("computed on write")
+	case ManufactureDateType:
+		if _, err := time.Parse(ManufactureDateFormat, s); err != nil {
+			return fmt.Errorf("%q isn't %q", s, "MM/DD/YYYY hh:mm:ss")
+		}
+	case CountryCodeType:
+		if len(s) != 2 || strings.ToUpper(s) != s {
+			return fmt.Errorf("%q isn't an ISO 3166-1 alpha-2 code", s)
+		}
+	}
+	return nil
+}
+
 func (p *Eeprom) String() string {
 	buf := new(bytes.Buffer)
 	fmt.Fprintln(buf, "eeprom.Onie.Data:", p.Onie.Data)
 	fmt.Fprintln(buf, "eeprom.Onie.Version:", p.Onie.Version)
 	buf.WriteString(p.Tlv.String())
+	fmt.Fprintln(buf, "eeprom.valid:", p.Valid())
+	for _, problem := range p.problems {
+		fmt.Fprint(buf, "eeprom.invalid.", problem, "\n")
+	}
 	return buf.String()
 }
 
@@ -109,6 +154,10 @@ func (p *Eeprom) Write(buf []byte) (n int, err error) {
 	if p.Tlv == nil {
 		p.Tlv = make(TlvMap)
 	}
+	if len(buf) < HeaderSz {
+		err = fmt.Errorf("header: too short")
+		return
+	}
 	i, err := p.Onie.Data.Write(buf)
 	if err != nil {
 		return
@@ -119,7 +168,15 @@ func (p *Eeprom) Write(buf []byte) (n int, err error) {
 		return
 	}
 	n += i
-	i, err = p.Tlv.Write(buf[OnieDataSz+1+2:])
+	tlvbuf := buf[HeaderSz:]
+	if l := int(binary.BigEndian.Uint16(buf[LenOffset:])); l < len(tlvbuf) {
+		tlvbuf = tlvbuf[:l]
+	}
+	n += 2
+	i, err = p.Tlv.Write(tlvbuf)
 	n += i
+	if err == nil {
+		p.verify(buf)
+	}
 	return
 }
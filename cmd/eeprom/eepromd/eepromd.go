@@ -0,0 +1,195 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package eepromd provides a daemon that discovers the field replaceable
+// unit eeproms, e.g. of fan trays and power supplies, and publishes their
+// fields as eeprom.NAME.FIELD with re-reads on hot-swap.
+package eepromd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/eeprom"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Device is a named eeprom published as eeprom.Name.FIELD
+type Device struct {
+	// Name, e.g. "psu1", prefixes the published fields.
+	Name string
+	eeprom.I2cDevice
+}
+
+type Command struct {
+	// Machines list their probed eeproms.
+	Devices []Device
+
+	// Interval between hot-swap probes, default: 5s
+	Interval time.Duration
+
+	pub *publisher.Publisher
+	// last Signature by device name; nil if absent
+	last map[string][]byte
+}
+
+func (*Command) String() string { return "eepromd" }
+
+func (*Command) Usage() string { return "eepromd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "publish eeproms of replaceable units",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Probe each configured eeprom, e.g. of the CPU card, fan trays, power
+	supplies, and chassis, and publish its fields to redis as,
+		eeprom.NAME.present: true
+		eeprom.NAME.FIELD: VALUE
+
+	The daemon re-reads an eeprom after its removal, insertion, or swap.
+
+FILES
+	/etc/goes/machine.yaml
+		eepromd:
+		  interval: 5s
+		  devices:
+		    - name: psu1
+		      bus: 1
+		      addresses: [0x50, 0x51]
+		      delay: 1ms
+
+SEE ALSO
+	eeprom`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+	c.last = make(map[string][]byte)
+
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		for _, dev := range c.Devices {
+			c.probe(dev)
+		}
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// configure overrides the machine's compiled in Devices with those of
+// eepromd.devices in the machine configuration file.
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	c.Interval, err = cfg.Duration("eepromd.interval", c.Interval)
+	if err != nil || !cfg.Has("eepromd.devices") {
+		return
+	}
+	var devices []Device
+	for _, i := range cfg.Keys("eepromd.devices") {
+		var dev Device
+		prefix := "eepromd.devices." + i + "."
+		dev.Name = cfg.String(prefix+"name", "")
+		if len(dev.Name) == 0 {
+			return fmt.Errorf("%sname: missing", prefix)
+		}
+		if dev.Bus, err = cfg.Int(prefix+"bus", 0); err != nil {
+			return
+		}
+		for _, s := range cfg.Strings(prefix+"addresses", nil) {
+			var addr int
+			if _, err = fmt.Sscan(s, &addr); err != nil {
+				return fmt.Errorf("%saddresses: %q isn't an address",
+					prefix, s)
+			}
+			dev.Addresses = append(dev.Addresses, addr)
+		}
+		if len(dev.Addresses) == 0 {
+			return fmt.Errorf("%saddresses: missing", prefix)
+		}
+		dev.Delay, err = cfg.Duration(prefix+"delay", 0)
+		if err != nil {
+			return
+		}
+		devices = append(devices, dev)
+	}
+	c.Devices = devices
+	return
+}
+
+// probe the device's signature then, if changed, re-read and publish its
+// fields.
+func (c *Command) probe(dev Device) {
+	sig, err := dev.Signature()
+	last, probed := c.last[dev.Name]
+	present := last != nil
+	if err != nil {
+		if present || !probed {
+			c.pub.Print("delete: eeprom.", dev.Name, ".")
+			c.pub.Print("eeprom.", dev.Name, ".present: false")
+		}
+		if present {
+			log.Print("daemon", "info", dev.Name, ": removed")
+		}
+		c.last[dev.Name] = nil
+		return
+	}
+	if present && bytes.Equal(last, sig) {
+		return
+	}
+	buf, err := dev.ReadBytes()
+	if err != nil {
+		log.Print("daemon", "err", dev.Name, ": ", err)
+		return
+	}
+	var p eeprom.Eeprom
+	if _, err = p.Write(buf); err != nil {
+		log.Print("daemon", "err", dev.Name, ": ", err)
+		return
+	}
+	c.last[dev.Name] = sig
+	if present {
+		log.Print("daemon", "info", dev.Name, ": swapped")
+	} else {
+		log.Print("daemon", "info", dev.Name, ": inserted")
+	}
+	c.pub.Print("delete: eeprom.", dev.Name, ".")
+	for _, s := range strings.Split(p.String(), "\n") {
+		if strings.HasPrefix(s, "eeprom.") {
+			c.pub.Print("eeprom.", dev.Name, ".", s[len("eeprom."):])
+		}
+	}
+	c.pub.Print("eeprom.", dev.Name, ".present: true")
+}
@@ -0,0 +1,89 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/platinasystems/goes/external/i2c"
+)
+
+// I2cDevice is a two byte addressed eeprom at one of Addresses on Bus.
+type I2cDevice struct {
+	Bus       int
+	Addresses []int
+	// Delay between each byte transaction
+	Delay time.Duration
+}
+
+// Signature returns the image header and last 4 bytes, e.g. the Crc, of the
+// probed device to detect a change without reading all TLVs.
+func (d *I2cDevice) Signature() ([]byte, error) {
+	var buf []byte
+	err := d.do(func(bus *i2c.Bus, lbuf []byte) (err error) {
+		if buf, err = bus.ReadBlock(0, HeaderSz, d.Delay); err != nil {
+			return
+		}
+		n := HeaderSz + int(binary.BigEndian.Uint16(lbuf))
+		if n < HeaderSz+4 {
+			return
+		}
+		tail, err := bus.ReadBlock(n-4, 4, d.Delay)
+		buf = append(buf, tail...)
+		return
+	})
+	return buf, err
+}
+
+// ReadBytes returns the header and TLVs of the probed device.
+func (d *I2cDevice) ReadBytes() ([]byte, error) {
+	var buf []byte
+	err := d.do(func(bus *i2c.Bus, lbuf []byte) (err error) {
+		n := HeaderSz + int(binary.BigEndian.Uint16(lbuf))
+		buf, err = bus.ReadBlock(0, n, d.Delay)
+		return
+	})
+	return buf, err
+}
+
+// Write the image to the probed device.
+func (d *I2cDevice) Write(buf []byte) (int, error) {
+	// typical eeprom write cycle
+	const cycle = 5 * time.Millisecond
+	delay := d.Delay
+	if delay < cycle {
+		delay = cycle
+	}
+	err := d.do(func(bus *i2c.Bus, lbuf []byte) error {
+		return bus.WriteBlock(0, buf, delay)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(buf), nil
+}
+
+// do f with the first of Addresses that has a readable length field
+func (d *I2cDevice) do(f func(bus *i2c.Bus, lbuf []byte) error) error {
+	err := fmt.Errorf("no addresses")
+	for _, address := range d.Addresses {
+		var bus *i2c.Bus
+		bus, err = i2c.New(d.Bus, address)
+		if err != nil {
+			continue
+		}
+		var lbuf []byte
+		lbuf, err = bus.ReadBlock(LenOffset, 2, d.Delay)
+		if err == nil {
+			err = f(bus, lbuf)
+			bus.Close()
+			return err
+		}
+		bus.Close()
+	}
+	return fmt.Errorf("i2c %d.%#x: %v", d.Bus, d.Addresses, err)
+}
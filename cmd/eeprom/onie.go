@@ -0,0 +1,41 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"bytes"
+	"hash/crc32"
+)
+
+// The ONIE TlvInfo header id and version. Other headers are the legacy
+// Platina layout that has neither a required order nor a trailing Crc.
+const (
+	OnieId      = "TlvInfo\x00"
+	OnieVersion = 0x01
+)
+
+// CrcSz is that of the trailing Crc TLV, including its type and length.
+const CrcSz = 2 + 4
+
+// IsOnie returns true if the eeprom has an ONIE TlvInfo header.
+func (p *Eeprom) IsOnie() bool {
+	return p.Onie.Data != nil && string(p.Onie.Data[:]) == OnieId
+}
+
+// Crc returns the ONIE CRC-32 of the given eeprom image up to and including
+// the type and length of the trailing Crc TLV.
+func Crc(buf []byte) uint32 {
+	return crc32.ChecksumIEEE(buf)
+}
+
+// onie appends the trailing Crc TLV to the given header and TLVs then
+// updates the eeprom's Crc.
+func (p *Eeprom) onie(buf *bytes.Buffer) {
+	buf.WriteByte(CrcType.Byte())
+	buf.WriteByte(4)
+	crc := Hex32(Crc(buf.Bytes()))
+	buf.Write(crc.Bytes())
+	p.Tlv[CrcType] = &crc
+}
@@ -0,0 +1,38 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package onie_eeprom reads and writes the ONIE TlvInfo system eeprom of
+// third party switches through its kernel (e.g. at24) driver.
+package onie_eeprom
+
+import "github.com/platinasystems/goes/cmd/eeprom"
+
+// The sysfs eeprom files searched for an ONIE TlvInfo header.
+const DefaultGlob = "/sys/bus/i2c/devices/*/eeprom"
+
+var config struct {
+	glob string
+	path string
+}
+
+// Glob of sysfs eeprom files, default: DefaultGlob
+type Glob string
+
+// Path of the sysfs eeprom file rather than that found with Glob
+type Path string
+
+func Config(args ...interface{}) {
+	config.glob = DefaultGlob
+	for _, arg := range args {
+		switch t := arg.(type) {
+		case Glob:
+			config.glob = string(t)
+		case Path:
+			config.path = string(t)
+		}
+	}
+	eeprom.Vendor.New = nil
+	eeprom.Vendor.ReadBytes = ReadBytes
+	eeprom.Vendor.Write = Write
+}
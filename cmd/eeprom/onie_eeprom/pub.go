@@ -0,0 +1,36 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package onie_eeprom
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/platinasystems/goes/cmd/eeprom"
+	"github.com/platinasystems/goes/external/redis/publisher"
+)
+
+// RedisdHook publishes the same eeprom.* fields as platina_eeprom.
+func RedisdHook(pub *publisher.Publisher) {
+	var p eeprom.Eeprom
+
+	buf, err := ReadBytes()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	if _, err = p.Write(buf); err != nil {
+		fmt.Fprintln(os.Stderr, "eeprom:", err)
+		return
+	}
+
+	for _, s := range strings.Split(p.String(), "\n") {
+		if len(s) > 0 {
+			pub.Write([]byte(s))
+		}
+	}
+}
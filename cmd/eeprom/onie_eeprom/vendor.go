@@ -0,0 +1,88 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package onie_eeprom
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/platinasystems/goes/cmd/eeprom"
+)
+
+func ReadBytes() ([]byte, error) {
+	fn, err := path()
+	if err != nil {
+		return nil, err
+	}
+	return readbytes(fn)
+}
+
+func Write(buf []byte) (int, error) {
+	fn, err := path()
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.WriteAt(buf, 0)
+}
+
+// path returns the configured eeprom file or the first of those matching
+// the glob with an ONIE TlvInfo header.
+func path() (string, error) {
+	if len(config.path) > 0 {
+		return config.path, nil
+	}
+	matches, err := filepath.Glob(config.glob)
+	if err != nil {
+		return "", err
+	}
+	for _, fn := range matches {
+		if header(fn) == nil {
+			config.path = fn
+			return fn, nil
+		}
+	}
+	return "", fmt.Errorf("eeprom: %s: no ONIE TlvInfo", config.glob)
+}
+
+func header(fn string) error {
+	f, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	buf := make([]byte, eeprom.HeaderSz)
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return err
+	}
+	if string(buf[:eeprom.OnieDataSz]) != eeprom.OnieId {
+		return fmt.Errorf("%s: not ONIE TlvInfo", fn)
+	}
+	return nil
+}
+
+func readbytes(fn string) ([]byte, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, eeprom.HeaderSz)
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("eeprom: %s: %v", fn, err)
+	}
+	n := eeprom.HeaderSz + int(binary.BigEndian.Uint16(buf[eeprom.LenOffset:]))
+	buf = make([]byte, n)
+	if _, err = f.ReadAt(buf, 0); err != nil {
+		return nil, fmt.Errorf("eeprom: %s: Read Data: %v", fn, err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,72 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestOnieCrc(t *testing.T) {
+	var p Eeprom
+	hdr := append([]byte(OnieId), OnieVersion, 0, 0)
+	if _, err := p.Write(hdr); err != nil {
+		t.Fatal(err)
+	}
+	if !p.IsOnie() {
+		t.Fatal("not ONIE")
+	}
+	for name, s := range map[string]string{
+		"SerialNumber":        "XYZ1234",
+		"ProductName":         "switch",
+		"BaseEthernetAddress": "02:46:8a:00:00:01",
+	} {
+		if err := p.Set(name, s); err != nil {
+			t.Fatal(name, ": ", err)
+		}
+	}
+	buf := p.Bytes()
+	n := len(buf)
+	if l := int(binary.BigEndian.Uint16(buf[LenOffset:])); HeaderSz+l != n {
+		t.Fatal("length", l, "vs.", n-HeaderSz)
+	}
+	if buf[n-CrcSz] != CrcType.Byte() {
+		t.Fatalf("%#x isn't the trailing Crc", buf[n-CrcSz])
+	}
+	if crc := binary.BigEndian.Uint32(buf[n-4:]); crc != Crc(buf[:n-4]) {
+		t.Fatalf("crc %#x vs. %#x", crc, Crc(buf[:n-4]))
+	}
+	if buf[HeaderSz] != ProductNameType.Byte() {
+		t.Fatalf("%#x isn't the first type", buf[HeaderSz])
+	}
+	clone, err := p.Clone()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Equal(clone); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOnieVerify(t *testing.T) {
+	var p, q Eeprom
+	hdr := append([]byte(OnieId), OnieVersion, 0, 0)
+	p.Write(hdr)
+	p.Set("SerialNumber", "XYZ1234")
+	buf := p.Bytes()
+	if _, err := q.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if !q.Valid() {
+		t.Fatal(q.Problems())
+	}
+	buf[HeaderSz+2] = 0x80
+	if _, err := q.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+	if problems := q.Problems(); len(problems) != 2 {
+		t.Fatal("expected Crc and SerialNumber problems, got", problems)
+	}
+}
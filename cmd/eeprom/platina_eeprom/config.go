@@ -16,8 +16,9 @@ var config struct {
 		addresses []int
 		delay     time.Duration
 	}
-	minMacs int
-	oui     [3]byte
+	minMacs      int
+	oui          [3]byte
+	writeProtect func(bool) error
 }
 
 type BusIndex int
@@ -26,6 +27,9 @@ type BusDelay time.Duration
 type MinMacs int
 type OUI [3]byte
 
+// WriteProtect asserts, if true, or releases the eeprom write protect
+type WriteProtect func(bool) error
+
 func Config(args ...interface{}) {
 	for _, arg := range args {
 		switch t := arg.(type) {
@@ -40,6 +44,8 @@ func Config(args ...interface{}) {
 			config.minMacs = int(t)
 		case OUI:
 			copy(config.oui[:], t[:])
+		case WriteProtect:
+			config.writeProtect = t
 		}
 	}
 	eeprom.Types = append(eeprom.Types,
@@ -58,4 +64,7 @@ func Config(args ...interface{}) {
 	}
 	eeprom.Vendor.ReadBytes = ReadBytes
 	eeprom.Vendor.Write = Write
+	if config.writeProtect != nil {
+		eeprom.Vendor.WriteProtect = config.writeProtect
+	}
 }
@@ -61,6 +61,35 @@ func RedisdHook(pub *publisher.Publisher) {
 }
 
 func readbytes() ([]byte, error) {
+	bus, lbuf, err := open()
+	if err != nil {
+		return nil, err
+	}
+	defer bus.Close()
+
+	n := eeprom.HeaderSz + int(binary.BigEndian.Uint16(lbuf))
+	buf, err := bus.ReadBlock(0, n, config.bus.delay)
+	if err != nil {
+		err = fmt.Errorf("eeprom: Read Data: %v", err)
+	}
+	return buf, err
+}
+
+func writebytes(buf []byte) (int, error) {
+	dev := eeprom.I2cDevice{
+		Bus:       config.bus.index,
+		Addresses: config.bus.addresses,
+		Delay:     config.bus.delay,
+	}
+	n, err := dev.Write(buf)
+	if err != nil {
+		err = fmt.Errorf("eeprom: Write Data: %v", err)
+	}
+	return n, err
+}
+
+// open the eeprom bus and return the header's TLV length field
+func open() (*i2c.Bus, []byte, error) {
 	// eeprom reads are called early, by redis hook in start
 	// i2cd is not up in start, so direct i2c calls are used
 	var (
@@ -76,18 +105,15 @@ func readbytes() ([]byte, error) {
 				fmt.Printf("success\n")
 				break
 			}
+			bus.Close()
 		}
 		fmt.Printf("%v\n", err)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("eeprom: %v", err)
+		return nil, nil, fmt.Errorf("eeprom: %v", err)
 	}
-	defer bus.Close()
-
-	n := eeprom.HeaderSz + int(binary.BigEndian.Uint16(lbuf))
-	buf, err := bus.ReadBlock(0, n, config.bus.delay)
-	if err != nil {
-		err = fmt.Errorf("eeprom: Read Data: %v", err)
+	if bus == nil {
+		return nil, nil, fmt.Errorf("eeprom: no bus addresses")
 	}
-	return buf, err
+	return bus, lbuf, nil
 }
@@ -4,12 +4,10 @@
 
 package platina_eeprom
 
-import "fmt"
-
 func ReadBytes() ([]byte, error) {
 	return readbytes()
 }
 
-func Write([]byte) (int, error) {
-	return 0, fmt.Errorf("FIXME")
+func Write(buf []byte) (int, error) {
+	return writebytes(buf)
 }
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"sort"
 )
 
 type TlvMap map[Typer]interface{}
@@ -21,7 +22,12 @@ func (m TlvMap) Add(t Type) (v interface{}) {
 	case NEthernetAddressType:
 		v = new(Dec16)
 	case VendorExtensionType:
-		v = Vendor.New()
+		if Vendor.New != nil {
+			v = Vendor.New()
+		} else {
+			// ONIE IANA enterprise number and data
+			v = new(bytes.Buffer)
+		}
 	case CrcType:
 		v = new(Hex32)
 	default:
@@ -31,10 +37,14 @@ func (m TlvMap) Add(t Type) (v interface{}) {
 	return
 }
 
+// Bytes returns the type ordered TLVs excluding the Crc, if any.
 func (m TlvMap) Bytes() []byte {
 	buf := new(bytes.Buffer)
-	for t, v := range m {
-		b := v.(Byteser).Bytes()
+	for _, t := range m.Types() {
+		if t == CrcType {
+			continue
+		}
+		b := m[t].(Byteser).Bytes()
 		buf.WriteByte(t.Byte())
 		buf.WriteByte(byte(len(b)))
 		buf.Write(b)
@@ -67,7 +77,8 @@ func (m TlvMap) Equal(nm TlvMap) error {
 
 func (m TlvMap) String() string {
 	buf := new(bytes.Buffer)
-	for t, v := range m {
+	for _, t := range m.Types() {
+		v := m[t]
 		_, isBytesBuffer := v.(*bytes.Buffer)
 		if t != VendorExtensionType || isBytesBuffer {
 			fmt.Fprint(buf, "eeprom.", t, ": ", v, "\n")
@@ -78,6 +89,18 @@ func (m TlvMap) String() string {
 	return buf.String()
 }
 
+// Types returns the map's types in ascending order.
+func (m TlvMap) Types() []Typer {
+	types := make([]Typer, 0, len(m))
+	for t := range m {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		return types[i].Byte() < types[j].Byte()
+	})
+	return types
+}
+
 // Write buf into TlvMap
 func (m TlvMap) Write(buf []byte) (n int, err error) {
 	for len(buf) > 2 && err == nil {
@@ -133,7 +133,7 @@ func (t Type) String() string {
 		CrcType:                 "Crc",
 	}[t]
 	if len(s) == 0 {
-		s = fmt.Sprintf("%#x", t)
+		s = fmt.Sprintf("%#x", uint8(t))
 	}
 	return s
 }
@@ -167,9 +167,14 @@ func (p EthernetAddress) Bytes() []byte {
 }
 
 func (p EthernetAddress) Scan(s string) error {
-	//input := new(parse.Input)
-	//input.SetString(s)
-	//(*ethernet.Address)(p).Parse(input)
+	ea, err := net.ParseMAC(s)
+	if err != nil {
+		return err
+	}
+	if len(ea) != len(p) {
+		return fmt.Errorf("%s: isn't a %d byte address", s, len(p))
+	}
+	copy(p, ea)
 	return nil
 }
 
@@ -9,4 +9,8 @@ var Vendor struct {
 	New       func() VendorExtension
 	ReadBytes func() ([]byte, error)
 	Write     func([]byte) (int, error)
+
+	// WriteProtect, if set, asserts or releases the eeprom's write
+	// protect, e.g. through a GPIO.
+	WriteProtect func(bool) error
 }
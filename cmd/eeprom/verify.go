@@ -0,0 +1,91 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eeprom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"unicode"
+)
+
+// A Problem found while reading an eeprom image, e.g. a Crc mismatch or an
+// unprintable serial number.
+type Problem struct {
+	Field string
+	Err   error
+}
+
+func (p Problem) String() string {
+	return fmt.Sprint(p.Field, ": ", p.Err)
+}
+
+// Problems returns those found by the last Write.
+func (p *Eeprom) Problems() []Problem { return p.problems }
+
+// Valid returns true if Write didn't find any problems.
+func (p *Eeprom) Valid() bool { return len(p.problems) == 0 }
+
+func (p *Eeprom) problem(field string, format string, args ...interface{}) {
+	p.problems = append(p.problems, Problem{
+		Field: field,
+		Err:   fmt.Errorf(format, args...),
+	})
+}
+
+// verify the header, Crc, and fields of the given image.
+func (p *Eeprom) verify(buf []byte) {
+	p.problems = nil
+	if p.IsOnie() && byte(*p.Onie.Version) != OnieVersion {
+		p.problem("Onie.Version", "%#x isn't %#x",
+			byte(*p.Onie.Version), OnieVersion)
+	}
+	n := HeaderSz + int(binary.BigEndian.Uint16(buf[LenOffset:]))
+	if n > len(buf) {
+		p.problem("Onie.Length", "truncated, %d of %d bytes",
+			len(buf), n)
+		n = len(buf)
+	}
+	if v, found := p.Tlv[CrcType]; found {
+		want := uint32(*v.(*Hex32))
+		if n < HeaderSz+CrcSz || buf[n-CrcSz] != CrcType.Byte() {
+			p.problem("Crc", "isn't last")
+		} else if crc := Crc(buf[:n-4]); crc != want {
+			p.problem("Crc", "%#08x isn't computed %#08x", want, crc)
+		}
+	} else if p.IsOnie() {
+		p.problem("Crc", "missing")
+	}
+	for _, typer := range p.Tlv.Types() {
+		t, ok := typer.(Type)
+		if !ok || t == VendorExtensionType {
+			continue
+		}
+		switch v := p.Tlv[t].(type) {
+		case *bytes.Buffer:
+			s := v.String()
+			if err := validate(t, s); err != nil {
+				p.problem(t.String(), "%v", err)
+			} else if !printable(s) {
+				p.problem(t.String(), "%q is unprintable", s)
+			}
+		case EthernetAddress:
+			if bytes.Equal(v, make([]byte, len(v))) {
+				p.problem(t.String(), "zero")
+			} else if v[0]&1 != 0 {
+				p.problem(t.String(), "%s is multicast", v)
+			}
+		}
+	}
+}
+
+func printable(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,339 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package eventd numbers the posted events, publishes these to the ring
+// buffer of the redis "event" hash, saves the history for the next boot, and
+// dispatches the events to webhooks.
+package eventd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/event"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+const (
+	// DefaultHistory is the number of events in the ring buffer.
+	DefaultHistory = 1000
+	// DefaultFile saves the history through reboot.
+	DefaultFile = "/var/lib/goes/events"
+)
+
+// the interval of saving the changed history
+const saveInterval = 10 * time.Second
+
+// redisd reads published datagrams of a page, so longer events are trimmed
+const maxEncoded = 3584
+
+var logger = log.New("eventd")
+
+type Command struct {
+	History  int
+	File     string
+	Webhooks []*Webhook
+
+	pub   *publisher.Publisher
+	seq   uint64
+	ring  []*event.Event
+	dirty bool
+}
+
+func (*Command) String() string { return "eventd" }
+
+func (*Command) Usage() string { return "eventd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "event bus daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Receive the events posted by goes daemons and programs, e.g. link
+	flaps, daemon restarts, and threshold alarms; then number and
+	publish each to the ring buffer of the redis "event" hash as,
+		event: SLOT: JSON
+		event: seq: SEQ
+
+	Also post a boot event on the first start after each boot.
+
+	The history is saved to the FILE so it's restored after reboot.
+
+	Each webhook receives the JSON POST of the events that match its
+	kind prefixes and minimum severity.
+
+FILES
+	/etc/goes/machine.yaml
+		eventd:
+		  history: 1000
+		  file: /var/lib/goes/events
+		  webhooks:
+		    - url: http://10.0.0.10:8080/goes
+		      kinds: [link., daemon.]
+		      severity: warn
+
+SEE ALSO
+	events`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err := c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if err := redis.IsReady(); err != nil {
+		return err
+	}
+	pub, err := publisher.New()
+	if err != nil {
+		return err
+	}
+	defer pub.Close()
+	c.pub = pub
+	c.restore()
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: event.Socket,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, w := range c.Webhooks {
+		goes.WG.Add(1)
+		go func(w *Webhook) {
+			defer goes.WG.Done()
+			w.run(goes.Stop)
+		}(w)
+	}
+	c.boot()
+	posted := make(chan *event.Event, 64)
+	done := make(chan error, 1)
+	go func() { done <- receive(conn, posted) }()
+	t := time.NewTicker(saveInterval)
+	defer t.Stop()
+	defer c.save()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case err = <-done:
+			return err
+		case e := <-posted:
+			c.add(e)
+		case <-t.C:
+			c.save()
+		}
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) error {
+	var err error
+	c.History, err = cfg.Int("eventd.history", DefaultHistory)
+	if err != nil {
+		return err
+	}
+	if c.History <= 0 {
+		return fmt.Errorf("eventd.history: %d: invalid", c.History)
+	}
+	c.File = cfg.String("eventd.file", DefaultFile)
+	for _, i := range cfg.Keys("eventd.webhooks") {
+		prefix := "eventd.webhooks." + i + "."
+		w, err := NewWebhook(cfg.String(prefix+"url", ""),
+			&event.Filter{
+				Kinds:    cfg.Strings(prefix+"kinds", nil),
+				Severity: cfg.String(prefix+"severity", ""),
+			})
+		if err != nil {
+			return err
+		}
+		c.Webhooks = append(c.Webhooks, w)
+	}
+	return nil
+}
+
+func receive(conn *net.UnixConn, posted chan<- *event.Event) error {
+	buf := make([]byte, 1<<16)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return err
+		}
+		e := new(event.Event)
+		if err = json.Unmarshal(buf[:n], e); err != nil {
+			logger.Warn("invalid", "err", err)
+			continue
+		}
+		posted <- e
+	}
+}
+
+// restore the history published by a prior eventd or, after reboot, that
+// of the file.
+func (c *Command) restore() {
+	var events []*event.Event
+	if m, err := redis.Hgetall(event.Key, ""); err == nil {
+		events = event.Parse(m)
+	}
+	republish := len(events) == 0 && len(c.File) > 0
+	if republish {
+		events = c.load()
+	}
+	if len(events) > c.History {
+		events = events[len(events)-c.History:]
+	}
+	c.ring = make([]*event.Event, c.History)
+	for _, e := range events {
+		c.ring[e.Seq%uint64(c.History)] = e
+		if e.Seq > c.seq {
+			c.seq = e.Seq
+		}
+		if republish {
+			c.publish(e)
+		}
+	}
+	c.pub.Print(event.Key, ": seq: ", c.seq)
+}
+
+// load the saved history, one JSON event per line.
+func (c *Command) load() []*event.Event {
+	var events []*event.Event
+	f, err := os.Open(c.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Err("load", "file", c.File, "err", err)
+		}
+		return nil
+	}
+	defer f.Close()
+	scan := bufio.NewScanner(f)
+	scan.Buffer(make([]byte, 0, 4096), 1<<16)
+	for scan.Scan() {
+		if e, err := event.Decode(scan.Text()); err == nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// save the changed history to a temporary file renamed to the configured.
+func (c *Command) save() {
+	if !c.dirty || len(c.File) == 0 {
+		return
+	}
+	c.dirty = false
+	var b []byte
+	for _, e := range c.sorted() {
+		x, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		b = append(append(b, x...), '\n')
+	}
+	if err := os.MkdirAll(filepath.Dir(c.File), 0755); err != nil {
+		logger.Err("save", "file", c.File, "err", err)
+		return
+	}
+	tmp := c.File + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		logger.Err("save", "file", tmp, "err", err)
+		return
+	}
+	if err := os.Rename(tmp, c.File); err != nil {
+		logger.Err("save", "file", c.File, "err", err)
+	}
+}
+
+func (c *Command) sorted() []*event.Event {
+	events := make([]*event.Event, 0, len(c.ring))
+	for i := uint64(0); i < uint64(len(c.ring)); i++ {
+		// oldest first, from the slot after that of the last event
+		e := c.ring[(c.seq+1+i)%uint64(len(c.ring))]
+		if e != nil {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// add the posted event to the ring, publish, then dispatch it.
+func (c *Command) add(e *event.Event) {
+	c.seq++
+	e.Seq = c.seq
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if len(e.Severity) == 0 {
+		e.Severity = "info"
+	}
+	c.ring[e.Seq%uint64(len(c.ring))] = e
+	c.dirty = true
+	c.publish(e)
+	c.pub.Print(event.Key, ": seq: ", e.Seq)
+	for _, w := range c.Webhooks {
+		w.Push(e)
+	}
+}
+
+func (c *Command) publish(e *event.Event) {
+	b, err := e.Encode()
+	if err == nil && len(b) > maxEncoded {
+		e.Fields = nil
+		if len(e.Msg) > maxEncoded/2 {
+			e.Msg = e.Msg[:maxEncoded/2]
+		}
+		b, err = e.Encode()
+	}
+	if err != nil {
+		logger.Err("encode", "seq", e.Seq, "err", err)
+		return
+	}
+	slot := strconv.FormatUint(e.Seq%uint64(len(c.ring)), 10)
+	c.pub.Print(event.Key, ": ", slot, ": ", string(b))
+}
+
+// boot posts an event of the first start after each boot.
+func (c *Command) boot() {
+	b, err := ioutil.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return
+	}
+	id := strings.TrimSpace(string(b))
+	for _, e := range c.ring {
+		if e != nil && e.Kind == "boot" && e.Fields["boot_id"] == id {
+			return
+		}
+	}
+	e := event.New("boot", "note", "system boot", "boot_id", id)
+	var si syscall.Sysinfo_t
+	if syscall.Sysinfo(&si) == nil {
+		e.Time = time.Now().Add(-time.Duration(si.Uptime) * time.Second)
+	}
+	c.add(e)
+}
@@ -0,0 +1,105 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package eventd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/platinasystems/goes/external/event"
+)
+
+// events beyond this many pending for a webhook are dropped
+const webhookQueue = 256
+
+// Webhook receives the JSON POST of each matching event.
+type Webhook struct {
+	URL    string
+	Filter *event.Filter
+
+	client  http.Client
+	queue   chan *event.Event
+	dropped int32
+}
+
+// NewWebhook returns the webhook of the http or https URL.
+func NewWebhook(s string, f *event.Filter) (*Webhook, error) {
+	if len(s) == 0 {
+		return nil, errors.New("webhook url: missing")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%s: unsupported scheme", s)
+	}
+	return &Webhook{
+		URL:    s,
+		Filter: f,
+		client: http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan *event.Event, webhookQueue),
+	}, nil
+}
+
+// Push the event to the webhook's queue if it matches the filter.
+func (w *Webhook) Push(e *event.Event) {
+	if !w.Filter.Match(e) {
+		return
+	}
+	select {
+	case w.queue <- e:
+	default:
+		atomic.AddInt32(&w.dropped, 1)
+	}
+}
+
+func (w *Webhook) run(stop <-chan struct{}) {
+	down := false
+	for {
+		var e *event.Event
+		select {
+		case <-stop:
+			return
+		case e = <-w.queue:
+		}
+		err := w.post(e)
+		if err != nil && !down {
+			logger.Err("webhook", "url", w.URL, "err", err)
+		} else if err == nil && down {
+			logger.Info("webhook", "url", w.URL, "status", "up")
+		}
+		down = err != nil
+		if n := atomic.SwapInt32(&w.dropped, 0); n > 0 {
+			logger.Warn("webhook", "url", w.URL, "dropped", n)
+		}
+	}
+}
+
+func (w *Webhook) post(e *event.Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := w.client.Post(w.URL, "application/json",
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
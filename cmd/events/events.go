@@ -0,0 +1,152 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package events provides the command to query and tail the event bus.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/event"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "events" }
+
+func (Command) Usage() string {
+	return `events [-n COUNT] [-kind PREFIX[,PREFIX]...] [-source NAME[,NAME]...]
+	[-severity LEVEL] [-since DURATION|TIME] [-f] [-json]`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "print the event history",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the event history published by eventd, oldest first, as
+		TIME SEVERITY KIND SOURCE: MSG [KEY=VALUE]...
+
+OPTIONS
+	-n COUNT
+		print the last COUNT matching events
+	-kind PREFIX[,PREFIX]...
+		print events of these kinds, e.g. "link.,daemon.restart"
+	-source NAME[,NAME]...
+		print events of these daemons or programs
+	-severity LEVEL
+		print events of this or higher severity: emerg, alert, crit,
+		err, warn, note, info, or debug
+	-since DURATION|TIME
+		print events since this long ago, e.g. "10m", or RFC 3339
+		time
+	-f	follow, i.e. print matching events as these are published
+	-json	print each event as a line of JSON
+
+EXAMPLES
+	goes events -n 20
+	goes events -kind link. -since 1h
+	goes events -severity warn -f
+
+SEE ALSO
+	eventd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-n", "-kind", "-source", "-severity",
+		"-since")
+	flag, args := flags.New(args, "-f", "-json")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	f := new(event.Filter)
+	if s := parm.ByName["-kind"]; len(s) > 0 {
+		f.Kinds = strings.Split(s, ",")
+	}
+	if s := parm.ByName["-source"]; len(s) > 0 {
+		f.Sources = strings.Split(s, ",")
+	}
+	if s := parm.ByName["-severity"]; len(s) > 0 {
+		if _, err := log.ParseLevel(s); err != nil {
+			return err
+		}
+		f.Severity = s
+	}
+	if s := parm.ByName["-since"]; len(s) > 0 {
+		t, err := event.ParseSince(s)
+		if err != nil {
+			return err
+		}
+		f.Since = t
+	}
+	count := -1
+	if s := parm.ByName["-n"]; len(s) > 0 {
+		n, err := strconv.ParseUint(s, 0, 32)
+		if err != nil {
+			return fmt.Errorf("-n: %q invalid", s)
+		}
+		count = int(n)
+	}
+	show := func(e *event.Event) {
+		if flag.ByName["-json"] {
+			b, _ := json.Marshal(e)
+			os.Stdout.Write(append(b, '\n'))
+		} else {
+			fmt.Println(e)
+		}
+	}
+	// subscribe before the history so none are missed
+	var sub *event.Subscription
+	if flag.ByName["-f"] {
+		var err error
+		if sub, err = event.Subscribe(f); err != nil {
+			return err
+		}
+		defer sub.Close()
+	}
+	history, err := event.History(f)
+	if err != nil {
+		return err
+	}
+	if count >= 0 && len(history) > count {
+		history = history[len(history)-count:]
+	}
+	var last uint64
+	for _, e := range history {
+		show(e)
+		last = e.Seq
+	}
+	if sub == nil {
+		return nil
+	}
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return sub.Err
+			}
+			if e.Seq > last {
+				show(e)
+				last = e.Seq
+			}
+		}
+	}
+}
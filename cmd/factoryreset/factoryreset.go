@@ -0,0 +1,251 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package factoryreset provides a command to erase the configuration, keys,
+// and logs of the machine and reboot with its defaults.
+package factoryreset
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd/config"
+	"github.com/platinasystems/goes/cmd/daemons"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/lang"
+)
+
+// DefaultSuffix of the files that are kept and copied over those without
+// it, e.g. /etc/goes/sshd/authorized_keys.default
+const DefaultSuffix = ".default"
+
+// Paths that are erased; those of config.BackupPaths and the daemon logs.
+var Paths = append(append([]string{}, config.BackupPaths...),
+	daemons.VarLogGoes)
+
+// NetworkPaths are kept with -keep-network so that the machine is still
+// reachable after its reboot.
+var NetworkPaths = []string{"/etc/goes/start", "/etc/goes/sshd"}
+
+type Command struct {
+	g *goes.Goes
+}
+
+func (*Command) String() string { return "factory-reset" }
+
+func (*Command) Usage() string {
+	return "factory-reset [-n] [-keep-network]"
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "erase configuration, keys, and logs then reboot",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Erase the persisted redis settings, the overlays of /etc/goes, e.g.
+	machine.yaml, start, and stop, the pinned image keys, the sshd host
+	key and authorized keys, and the daemon logs, then reboot. The
+	machine restarts with its builtin configuration and, as its ztp
+	completion is also erased, provisions itself again.
+
+	Files of the "FILE.default" name are kept and copied over FILE.
+
+	This asks to type a random code before erasing anything.
+
+OPTIONS
+	-n	just list the files that would be erased
+	-keep-network
+		keep /etc/goes/start and /etc/goes/sshd so that the machine
+		may still be reached with its management address and keys
+
+FILES
+	/etc/goes
+	/var/log/goes
+
+EXAMPLES
+	goes factory-reset -n
+	goes factory-reset -keep-network
+
+SEE ALSO
+	config, ztp, reboot`,
+	}
+}
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-n", "-keep-network")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	var keep []string
+	if flag.ByName["-keep-network"] {
+		keep = NetworkPaths
+	}
+	files, err := plan(Paths, keep)
+	if err != nil {
+		return err
+	}
+	if flag.ByName["-n"] {
+		for _, fn := range files {
+			fmt.Println(fn)
+		}
+		return nil
+	}
+	if err = confirm(os.Stdin, os.Stdout, len(files)); err != nil {
+		return err
+	}
+	log.Audit.Warn("factory reset", "files", len(files),
+		"keep-network", flag.ByName["-keep-network"])
+	if err = erase(files, Paths); err != nil {
+		return err
+	}
+	if err = restoreDefaults(Paths); err != nil {
+		return err
+	}
+	return c.g.Main("reboot")
+}
+
+func (*Command) Complete(args ...string) []string {
+	var list []string
+	for _, s := range []string{"-n", "-keep-network"} {
+		if strings.HasPrefix(s, args[len(args)-1]) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// confirm reads a random code that it first asks be typed.
+func confirm(r io.Reader, w io.Writer, n int) error {
+	b := make([]byte, 3)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	code := strings.ToUpper(hex.EncodeToString(b))
+	fmt.Fprintf(w, "This erases %d files and reboots.\n", n)
+	fmt.Fprint(w, "Type ", code, " to continue: ")
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if strings.TrimSpace(line) != code {
+		return fmt.Errorf("not confirmed")
+	}
+	return nil
+}
+
+// plan returns the files within paths to erase, skipping those of keep and
+// the defaults.
+func plan(paths, keep []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(fn string, fi os.FileInfo,
+			err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			for _, k := range keep {
+				if fn == k || strings.HasPrefix(fn, k+"/") {
+					if fi.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+			}
+			if !fi.IsDir() && !strings.HasSuffix(fn, DefaultSuffix) {
+				files = append(files, fn)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// erase the files, then any directories below paths that this leaves empty.
+func erase(files, paths []string) error {
+	dirs := make(map[string]bool)
+	for _, fn := range files {
+		if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		dirs[filepath.Dir(fn)] = true
+	}
+	var list []string
+	for dir := range dirs {
+		list = append(list, dir)
+	}
+	// deepest first so that parents may then be empty
+	sort.Sort(sort.Reverse(sort.StringSlice(list)))
+	for _, dir := range list {
+		if has(paths, dir) {
+			continue
+		}
+		os.Remove(dir)
+	}
+	return nil
+}
+
+// restoreDefaults copies each FILE.default within paths to FILE, unless
+// FILE was kept.
+func restoreDefaults(paths []string) error {
+	for _, path := range paths {
+		err := filepath.Walk(path, func(fn string, fi os.FileInfo,
+			err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if fi.IsDir() || !strings.HasSuffix(fn, DefaultSuffix) {
+				return nil
+			}
+			dst := strings.TrimSuffix(fn, DefaultSuffix)
+			if _, err = os.Stat(dst); err == nil {
+				return nil
+			}
+			b, err := ioutil.ReadFile(fn)
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(dst, b, fi.Mode().Perm())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func has(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package factoryreset
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	dir, err := ioutil.TempDir("", "factoryreset")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	etc := filepath.Join(dir, "etc")
+	for _, fn := range []string{
+		"machine.yaml",
+		"start",
+		"persist/vlan",
+		"sshd/authorized_keys",
+		"sshd/authorized_keys.default",
+	} {
+		fn = filepath.Join(etc, fn)
+		os.MkdirAll(filepath.Dir(fn), 0755)
+		if err = ioutil.WriteFile(fn, []byte(fn), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths := []string{etc, filepath.Join(dir, "missing")}
+	keep := []string{filepath.Join(etc, "start")}
+	files, err := plan(paths, keep)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, fn := range files {
+		got = append(got, strings.TrimPrefix(fn, etc+"/"))
+	}
+	want := "machine.yaml persist/vlan sshd/authorized_keys"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("plan: %v", got)
+	}
+	if err = erase(files, paths); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreDefaults(paths); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(etc, "persist")); err == nil {
+		t.Error("empty persist wasn't removed")
+	}
+	if _, err = os.Stat(filepath.Join(etc, "start")); err != nil {
+		t.Error("start wasn't kept")
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(etc, "sshd/authorized_keys"))
+	if !strings.HasSuffix(string(b), ".default") {
+		t.Errorf("authorized_keys: %q", b)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	out := new(strings.Builder)
+	if err := confirm(strings.NewReader("yes\n"), out, 1); err == nil {
+		t.Error("confirmed without the code")
+	}
+	s := out.String()
+	i := strings.Index(s, "Type ") + len("Type ")
+	code := s[i : i+6]
+	out.Reset()
+	if err := confirm(strings.NewReader(code+"\n"), out, 1); err == nil {
+		t.Error("confirmed with the previous code")
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fand
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Point of a fan Curve, a duty cycle percentage at a temperature in °C.
+type Point struct {
+	Temp float64
+	Duty int
+}
+
+// Curve is the linear interpolation of its Points in ascending Temp order.
+// Below the first point, the duty is that of the first; above the last,
+// that of the last.
+type Curve []Point
+
+// ParsePoint parses "TEMP:DUTY", e.g. "60:75"
+func ParsePoint(s string) (Point, error) {
+	var p Point
+	colon := strings.Index(s, ":")
+	if colon < 0 {
+		return p, fmt.Errorf("%q isn't TEMP:DUTY", s)
+	}
+	if _, err := fmt.Sscan(s[:colon], &p.Temp); err != nil {
+		return p, fmt.Errorf("%q: invalid temperature", s)
+	}
+	if _, err := fmt.Sscan(s[colon+1:], &p.Duty); err != nil ||
+		p.Duty < 0 || p.Duty > 100 {
+		return p, fmt.Errorf("%q: invalid duty", s)
+	}
+	return p, nil
+}
+
+func (c Curve) Sort() {
+	sort.Slice(c, func(i, j int) bool { return c[i].Temp < c[j].Temp })
+}
+
+// Duty returns the interpolated duty cycle percentage at temp.
+func (c Curve) Duty(temp float64) int {
+	if len(c) == 0 {
+		return 100
+	}
+	if temp <= c[0].Temp {
+		return c[0].Duty
+	}
+	for i := 1; i < len(c); i++ {
+		if temp > c[i].Temp {
+			continue
+		}
+		lo, hi := c[i-1], c[i]
+		f := (temp - lo.Temp) / (hi.Temp - lo.Temp)
+		return lo.Duty + int(f*float64(hi.Duty-lo.Duty)+0.5)
+	}
+	return c[len(c)-1].Duty
+}
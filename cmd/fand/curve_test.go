@@ -0,0 +1,37 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fand
+
+import "testing"
+
+func TestCurve(t *testing.T) {
+	var c Curve
+	for _, s := range []string{"75:100", "40:30", "60:60"} {
+		p, err := ParsePoint(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c = append(c, p)
+	}
+	c.Sort()
+	for temp, want := range map[float64]int{
+		20:  30,
+		40:  30,
+		50:  45,
+		60:  60,
+		70:  87,
+		90:  100,
+		-10: 30,
+	} {
+		if got := c.Duty(temp); got != want {
+			t.Errorf("%v°C: %d%%, want %d%%", temp, got, want)
+		}
+	}
+	for _, s := range []string{"40", "x:30", "40:101", "40:-1"} {
+		if _, err := ParsePoint(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
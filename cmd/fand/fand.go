@@ -0,0 +1,344 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package fand provides a daemon that drives fan PWMs per the temperature
+// curve of each thermal zone and publishes the fan speeds, temperatures, and
+// policy state to redis.
+package fand
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/led"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Policies published as fand.policy
+const (
+	Normal   = "normal"
+	Failsafe = "failsafe"
+)
+
+// Zone is a set of temperature sensors cooled by a set of fans.
+type Zone struct {
+	Name string
+
+	// Sensors are hwmon tempN_input files of millidegrees C; the zone's
+	// temperature is the max of these.
+	Sensors []string
+
+	// Pwms are hwmon pwmN files (0-255) driven by the zone's duty.
+	Pwms []string
+
+	// Fans are hwmon fanN_input files of RPM.
+	Fans []string
+
+	Curve Curve
+
+	// current duty percentage and the temperature that set it
+	duty int
+	at   float64
+}
+
+type Command struct {
+	// Machines list their thermal zones; the machine configuration file
+	// may override these.
+	Zones []Zone
+
+	// Interval between updates, default: 5s
+	Interval time.Duration
+
+	// Hysteresis in °C below the temperature that raised the duty before
+	// it's lowered, default: 2
+	Hysteresis float64
+
+	// MinRpm of a driven fan before it's considered failed, default: 500
+	MinRpm int
+
+	pub    *publisher.Publisher
+	policy string
+}
+
+func (*Command) String() string { return "fand" }
+
+func (*Command) Usage() string { return "fand" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "fan and thermal control daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Periodically read the temperature sensors of each thermal zone and
+	drive the zone's fan PWMs per its curve of TEMP:DUTY points, linearly
+	interpolated. A zone's duty is lowered only after its temperature
+	drops the hysteresis below that which raised it.
+
+	If any sensor is unreadable or any driven fan is below the minimum
+	RPM, fand drives all fans at full speed and publishes the failsafe
+	policy until the fault clears. Fans are left at full speed when fand
+	stops. The "fan" LED, if any, is green in the normal policy,
+	otherwise, amber.
+
+	fand publishes,
+		fand.policy: normal|failsafe
+		fand.ZONE.temp: °C
+		fand.ZONE.duty: %
+		fand.ZONE.fanN.rpm: RPM
+		fand.ZONE.error: "..."
+
+FILES
+	/etc/goes/machine.yaml
+		fand:
+		  interval: 5s
+		  hysteresis: 2
+		  min-rpm: 500
+		  zones:
+		    - name: cpu
+		      sensors: [/sys/class/hwmon/hwmon0/temp1_input]
+		      pwms: [/sys/class/hwmon/hwmon1/pwm1]
+		      fans: [/sys/class/hwmon/hwmon1/fan1_input]
+		      curve: ["40:30", "60:60", "75:100"]
+
+	Sensor, PWM, and fan files may be globs.`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if len(c.Zones) == 0 {
+		return fmt.Errorf("no zones")
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	if c.Hysteresis <= 0 {
+		c.Hysteresis = 2
+	}
+	if c.MinRpm <= 0 {
+		c.MinRpm = 500
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	for i := range c.Zones {
+		z := &c.Zones[i]
+		z.Sensors = glob(z.Sensors)
+		z.Pwms = glob(z.Pwms)
+		z.Fans = glob(z.Fans)
+		z.Curve.Sort()
+		// start at full speed until the first update
+		z.duty, z.at = 100, math.Inf(1)
+		for _, pwm := range z.Pwms {
+			// manual rather than the chip's automatic control
+			if err = write(pwm+"_enable", 1); err != nil {
+				log.Print("daemon", "err", err)
+			}
+		}
+	}
+	defer c.full()
+
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		c.update()
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// configure overrides the machine's compiled in policy and zones with those
+// of fand in the machine configuration file.
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	if c.Interval, err = cfg.Duration("fand.interval", c.Interval); err != nil {
+		return
+	}
+	if s := cfg.String("fand.hysteresis", ""); len(s) > 0 {
+		if c.Hysteresis, err = strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("fand.hysteresis: %q isn't a number", s)
+		}
+	}
+	if c.MinRpm, err = cfg.Int("fand.min-rpm", c.MinRpm); err != nil {
+		return
+	}
+	if !cfg.Has("fand.zones") {
+		return
+	}
+	var zones []Zone
+	for _, i := range cfg.Keys("fand.zones") {
+		prefix := "fand.zones." + i + "."
+		z := Zone{
+			Name:    cfg.String(prefix+"name", "zone"+i),
+			Sensors: cfg.Strings(prefix+"sensors", nil),
+			Pwms:    cfg.Strings(prefix+"pwms", nil),
+			Fans:    cfg.Strings(prefix+"fans", nil),
+		}
+		if len(z.Sensors) == 0 {
+			return fmt.Errorf("%ssensors: missing", prefix)
+		}
+		for _, s := range cfg.Strings(prefix+"curve", nil) {
+			p, err := ParsePoint(s)
+			if err != nil {
+				return fmt.Errorf("%scurve: %v", prefix, err)
+			}
+			z.Curve = append(z.Curve, p)
+		}
+		if len(z.Curve) == 0 {
+			return fmt.Errorf("%scurve: missing", prefix)
+		}
+		zones = append(zones, z)
+	}
+	c.Zones = zones
+	return
+}
+
+// update each zone's duty and, if any zone failed, drive all fans full.
+func (c *Command) update() {
+	var fault error
+	for i := range c.Zones {
+		if err := c.zone(&c.Zones[i]); err != nil && fault == nil {
+			fault = err
+		}
+	}
+	for _, z := range c.Zones {
+		for _, pwm := range z.Pwms {
+			if fault != nil {
+				break
+			}
+			fault = write(pwm, z.duty*255/100)
+		}
+	}
+	policy := Normal
+	if fault != nil {
+		policy = Failsafe
+		c.full()
+	}
+	if policy != c.policy {
+		if policy == Failsafe {
+			log.Print("daemon", "err", fault, ": fans at full speed")
+		} else if len(c.policy) > 0 {
+			log.Print("daemon", "info", "normal")
+		}
+		c.policy = policy
+		c.pub.Print("fand.policy: ", policy)
+		if err := led.Health("fan", policy == Normal); err != nil {
+			log.Print("daemon", "err", "led: ", err)
+		}
+	}
+}
+
+// zone reads the zone's sensors and fans, sets its duty per its curve and
+// hysteresis, and returns the first fault.
+func (c *Command) zone(z *Zone) error {
+	var fault error
+	temp, err := z.temp()
+	if err != nil {
+		fault = err
+	} else {
+		want := z.Curve.Duty(temp)
+		if want > z.duty || want < z.duty && temp <= z.at-c.Hysteresis {
+			z.duty, z.at = want, temp
+		}
+		c.pub.Printf("fand.%s.temp: %.1f", z.Name, temp)
+	}
+	c.pub.Printf("fand.%s.duty: %d", z.Name, z.duty)
+	for i, fan := range z.Fans {
+		rpm, err := read(fan)
+		if err == nil && z.duty > 0 && rpm < c.MinRpm {
+			err = fmt.Errorf("%s: %d rpm", fan, rpm)
+		}
+		if err != nil && fault == nil {
+			fault = err
+		}
+		c.pub.Printf("fand.%s.fan%d.rpm: %d", z.Name, i+1, rpm)
+	}
+	if fault != nil {
+		c.pub.Printf("fand.%s.error: %q", z.Name, fault.Error())
+	} else {
+		c.pub.Printf("fand.%s.error: %q", z.Name, "")
+	}
+	return fault
+}
+
+// temp returns the max °C of the zone's sensors.
+func (z *Zone) temp() (float64, error) {
+	var max float64
+	for i, sensor := range z.Sensors {
+		mc, err := read(sensor)
+		if err != nil {
+			return 0, err
+		}
+		if t := float64(mc) / 1000; i == 0 || t > max {
+			max = t
+		}
+	}
+	return max, nil
+}
+
+// full drives all fans at full speed.
+func (c *Command) full() {
+	for _, z := range c.Zones {
+		for _, pwm := range z.Pwms {
+			write(pwm, 255)
+		}
+	}
+}
+
+func glob(patterns []string) []string {
+	var fns []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil || len(matches) == 0 {
+			// retain the missing file as a fault
+			fns = append(fns, pattern)
+		} else {
+			fns = append(fns, matches...)
+		}
+	}
+	return fns
+}
+
+func read(fn string) (int, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", fn, err)
+	}
+	return i, nil
+}
+
+func write(fn string, i int) error {
+	return ioutil.WriteFile(fn, []byte(strconv.Itoa(i)), 0644)
+}
@@ -0,0 +1,352 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package fastboot provides a command to kexec the kernel of the inactive
+// A/B root, or that of a boot image, after stopping all daemons; so, the
+// machine restarts without the firmware's POST.
+package fastboot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/assert"
+	"github.com/platinasystems/goes/internal/fit"
+	"github.com/platinasystems/goes/internal/kexec"
+	"github.com/platinasystems/goes/internal/url"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Slots are the partition labels of the A/B roots made by "install".
+var Slots = []string{"root-a", "root-b"}
+
+const (
+	DevDiskByPartlabel = "/dev/disk/by-partlabel"
+	// RunGoesFastboot is where the slot is mounted, read-only, while its
+	// kernel and initrd are loaded.
+	RunGoesFastboot = "/run/goes/fastboot"
+)
+
+type Command struct {
+	g *goes.Goes
+}
+
+func (*Command) String() string { return "fastboot" }
+
+func (*Command) Usage() string {
+	return "fastboot [-n] [-f] [-c CMDLINE] [-slot LABEL | [-x CONFIG] IMAGE]"
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "kexec another root or image then reboot without POST",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Load the kernel and initrd of the inactive A/B root, i.e. root-b if
+	running root-a or vice versa, then stop all daemons, with the stop
+	script of the machine, and reboot into the loaded kernel rather than
+	through the firmware; so, a maintenance reboot takes seconds rather
+	than the minutes of a switch's POST.
+
+	The root's kernel and initrd are those linked by its /vmlinuz and
+	/initrd.img or, without these, the latest /boot/vmlinuz-VERSION and
+	its /boot/initrd.img-VERSION. The kernel command line is that of the
+	running kernel with root=PARTLABEL=LABEL of the loaded slot.
+
+	With IMAGE, a file or URL of a FIT image like that of 'kexec -l', e.g.
+	that served by bootd, this loads the configured kernel and ramdisk
+	instead.
+
+OPTIONS
+	-n	just show what would be loaded
+	-f	reboot without stopping daemons or unmounting cleanly
+	-c CMDLINE
+		the kernel command line; '+' prefixed is appended to that of
+		the running kernel
+	-slot LABEL
+		the partition label of the root to load, e.g. root-a
+	-x CONFIG
+		the IMAGE configuration, default: that of the image
+
+EXAMPLES
+	fastboot -n
+	fastboot -c +console=ttyS0,115200
+	fastboot http://bootd/goes/leaf-1.fit
+
+SEE ALSO
+	kexec, reboot, stop, install`,
+	}
+}
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-n", "-f")
+	parm, args := parms.New(args, "-c", "-slot", "-x")
+	switch len(args) {
+	case 0:
+	case 1:
+		if len(parm.ByName["-slot"]) > 0 {
+			return fmt.Errorf("%s: unexpected with -slot", args[0])
+		}
+	default:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if err := assert.Root(); err != nil {
+		return err
+	}
+	cmdline, err := kernelCmdline(parm.ByName["-c"])
+	if err != nil {
+		return err
+	}
+	load := image{dryrun: flag.ByName["-n"]}
+	if len(args) == 1 {
+		err = load.fit(args[0], parm.ByName["-x"], cmdline)
+	} else {
+		slot := parm.ByName["-slot"]
+		if len(slot) == 0 {
+			if slot, err = inactiveSlot(); err != nil {
+				return err
+			}
+		}
+		err = load.slot(slot, cmdline)
+	}
+	if err != nil || load.dryrun {
+		return err
+	}
+	log.Audit.Warn("fastboot", "from", load.from)
+	if flag.ByName["-f"] {
+		return c.g.Main("reboot", "-f")
+	}
+	if err = c.g.Main("stop"); err != nil {
+		return fmt.Errorf("stop: %v; to reboot anyway, use -f", err)
+	}
+	return c.g.Main("reboot")
+}
+
+func (*Command) Complete(args ...string) []string {
+	var list []string
+	last := args[len(args)-1]
+	if len(args) > 1 && args[len(args)-2] == "-slot" {
+		for _, s := range Slots {
+			if strings.HasPrefix(s, last) {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	for _, s := range []string{"-n", "-f", "-c", "-slot", "-x"} {
+		if strings.HasPrefix(s, last) {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+type image struct {
+	dryrun bool
+	// from is the slot or image that was loaded
+	from string
+}
+
+// slot loads the kernel and initrd of the labeled root partition.
+func (load *image) slot(slot, cmdline string) error {
+	dev, err := filepath.EvalSymlinks(filepath.Join(DevDiskByPartlabel,
+		slot))
+	if err != nil {
+		return fmt.Errorf("%s: %v", slot, err)
+	}
+	if err = os.MkdirAll(RunGoesFastboot, 0755); err != nil {
+		return err
+	}
+	err = syscall.Mount(dev, RunGoesFastboot, "ext4", syscall.MS_RDONLY,
+		"")
+	if err != nil {
+		return fmt.Errorf("mount %s: %v", dev, err)
+	}
+	defer syscall.Unmount(RunGoesFastboot, 0)
+	kernel, initrd, err := findKernel(RunGoesFastboot)
+	if err != nil {
+		return fmt.Errorf("%s: %v", slot, err)
+	}
+	cmdline = withRoot(cmdline, "PARTLABEL="+slot)
+	load.from = slot
+	if load.dryrun {
+		fmt.Println("slot:", slot, dev)
+		fmt.Println("kernel:", strings.TrimPrefix(kernel, RunGoesFastboot))
+		fmt.Println("initrd:", strings.TrimPrefix(initrd, RunGoesFastboot))
+		fmt.Println("cmdline:", cmdline)
+		return nil
+	}
+	k, err := os.Open(kernel)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	i, err := os.Open(initrd)
+	if err != nil {
+		return err
+	}
+	defer i.Close()
+	return kexec.FileLoad(k, i, cmdline, 0)
+}
+
+// fit loads the kernel and ramdisk of the given, or default, configuration
+// of a FIT image.
+func (load *image) fit(fn, x, cmdline string) error {
+	r, err := url.Open(fn)
+	if err != nil {
+		return err
+	}
+	b, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	f := fit.Parse(b)
+	if len(x) == 0 {
+		if x = f.DefaultConfig; len(x) == 0 {
+			return fmt.Errorf("%s: no default configuration, use -x",
+				fn)
+		}
+	}
+	config := f.Configs[x]
+	if config == nil {
+		return fmt.Errorf("%s: configuration %s not found", fn, x)
+	}
+	load.from = fn
+	if load.dryrun {
+		fmt.Println("image:", fn)
+		fmt.Println("config:", x)
+		for _, image := range config.ImageList {
+			fmt.Print(image.Type, ": ", image.Name, "\n")
+		}
+		fmt.Println("cmdline:", cmdline)
+		return nil
+	}
+	return f.KexecLoadConfig(config, cmdline)
+}
+
+// inactiveSlot returns the label of the A/B root that isn't mounted on /.
+func inactiveSlot() (string, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat("/", &st); err != nil {
+		return "", err
+	}
+	devs := make(map[string]uint64)
+	for _, slot := range Slots {
+		var dev syscall.Stat_t
+		fn := filepath.Join(DevDiskByPartlabel, slot)
+		if syscall.Stat(fn, &dev) == nil {
+			devs[slot] = uint64(dev.Rdev)
+		}
+	}
+	return otherSlot(uint64(st.Dev), devs)
+}
+
+// otherSlot returns the slot, of those present, that isn't the root device.
+func otherSlot(root uint64, devs map[string]uint64) (string, error) {
+	active := ""
+	for slot, dev := range devs {
+		if dev == root {
+			active = slot
+		}
+	}
+	if len(active) == 0 {
+		return "", fmt.Errorf("/ isn't on a slot of %v, use -slot",
+			Slots)
+	}
+	for _, slot := range Slots {
+		if _, found := devs[slot]; found && slot != active {
+			return slot, nil
+		}
+	}
+	return "", fmt.Errorf("%s: no inactive slot", active)
+}
+
+// findKernel returns the kernel and initrd of the mounted root.
+func findKernel(root string) (kernel, initrd string, err error) {
+	kernel, kerr := rootLink(root, "vmlinuz")
+	initrd, ierr := rootLink(root, "initrd.img")
+	if kerr == nil && ierr == nil {
+		return kernel, initrd, nil
+	}
+	kernels, _ := filepath.Glob(filepath.Join(root, "boot", "vmlinuz-*"))
+	sort.Strings(kernels)
+	for n := len(kernels) - 1; n >= 0; n-- {
+		version := strings.TrimPrefix(filepath.Base(kernels[n]),
+			"vmlinuz-")
+		initrd = filepath.Join(root, "boot", "initrd.img-"+version)
+		if _, err = os.Stat(initrd); err == nil {
+			return kernels[n], initrd, nil
+		}
+	}
+	return "", "", fmt.Errorf("no kernel and initrd")
+}
+
+// rootLink returns the file of the root's named link, resolved within the
+// root rather than that of the running system.
+func rootLink(root, name string) (string, error) {
+	fn := filepath.Join(root, name)
+	for i := 0; i < 8; i++ {
+		target, err := os.Readlink(fn)
+		if err != nil {
+			if _, err = os.Stat(fn); err != nil {
+				return "", err
+			}
+			return fn, nil
+		}
+		if filepath.IsAbs(target) {
+			fn = filepath.Join(root, target)
+		} else {
+			fn = filepath.Join(filepath.Dir(fn), target)
+		}
+	}
+	return "", fmt.Errorf("%s: too many links", name)
+}
+
+// kernelCmdline returns that given, or the running kernel's if empty or
+// '+' prefixed parameters to append.
+func kernelCmdline(s string) (string, error) {
+	if len(s) > 0 && s[0] != '+' {
+		return s, nil
+	}
+	b, err := ioutil.ReadFile("/proc/cmdline")
+	if err != nil {
+		return "", err
+	}
+	cmdline := strings.TrimSpace(string(b))
+	if len(s) > 1 {
+		cmdline += " " + s[1:]
+	}
+	return cmdline, nil
+}
+
+// withRoot returns the kernel command line with the given root= parameter
+// rather than that of the running kernel, and without the BOOT_IMAGE
+// parameter of its boot loader.
+func withRoot(cmdline, root string) string {
+	args := []string{"root=" + root}
+	for _, arg := range strings.Fields(cmdline) {
+		if !strings.HasPrefix(arg, "root=") &&
+			!strings.HasPrefix(arg, "BOOT_IMAGE=") {
+			args = append(args, arg)
+		}
+	}
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,77 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fastboot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOtherSlot(t *testing.T) {
+	devs := map[string]uint64{"root-a": 0x802, "root-b": 0x803}
+	if s, err := otherSlot(0x802, devs); err != nil || s != "root-b" {
+		t.Error("root-a:", s, err)
+	}
+	if s, err := otherSlot(0x803, devs); err != nil || s != "root-a" {
+		t.Error("root-b:", s, err)
+	}
+	if _, err := otherSlot(0x801, devs); err == nil {
+		t.Error("expected error of / on another device")
+	}
+	delete(devs, "root-b")
+	if _, err := otherSlot(0x802, devs); err == nil {
+		t.Error("expected error without root-b")
+	}
+}
+
+func TestWithRoot(t *testing.T) {
+	s := withRoot("BOOT_IMAGE=/vmlinuz root=UUID=1234 ro quiet",
+		"PARTLABEL=root-b")
+	if s != "root=PARTLABEL=root-b ro quiet" {
+		t.Error(s)
+	}
+}
+
+func TestFindKernel(t *testing.T) {
+	root, err := ioutil.TempDir("", "fastboot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	boot := filepath.Join(root, "boot")
+	os.Mkdir(boot, 0755)
+	for _, fn := range []string{
+		"vmlinuz-4.19.0-6-amd64",
+		"initrd.img-4.19.0-6-amd64",
+		"vmlinuz-5.4.0-1-amd64",
+		"initrd.img-5.4.0-1-amd64",
+		"vmlinuz-5.10.0-9-amd64",
+	} {
+		ioutil.WriteFile(filepath.Join(boot, fn), nil, 0644)
+	}
+	k, i, err := findKernel(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != filepath.Join(boot, "vmlinuz-5.4.0-1-amd64") ||
+		i != filepath.Join(boot, "initrd.img-5.4.0-1-amd64") {
+		t.Error("latest with initrd:", k, i)
+	}
+	// absolute links are within the root, not that of the running system
+	os.Symlink("/boot/vmlinuz-4.19.0-6-amd64",
+		filepath.Join(root, "vmlinuz"))
+	os.Symlink("boot/initrd.img-4.19.0-6-amd64",
+		filepath.Join(root, "initrd.img"))
+	k, i, err = findKernel(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k != filepath.Join(boot, "vmlinuz-4.19.0-6-amd64") ||
+		i != filepath.Join(boot, "initrd.img-4.19.0-6-amd64") {
+		t.Error("links:", k, i)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package fib provides a command to show the resolved nexthop group of the
+// routes in the kernel's main table, as relayed by the xeth driver to vnetd,
+// with the hardware ECMP group and per member counters published by vnetd.
+package fib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Prefix of the redis fields published by vnetd,
+//
+//	fib.PREFIX.ecmp: INDEX
+//	fib.PREFIX.nexthop.MEMBER.packets: COUNT
+//
+// where MEMBER is the nexthop's gateway or, without one, its interface.
+const Prefix = "fib."
+
+type Command struct{}
+
+// Entry of the FIB
+type Entry struct {
+	Prefix   string `json:"prefix"`
+	Protocol string `json:"protocol"`
+	Metric   uint32 `json:"metric"`
+	// Ecmp is the hardware group index, if any, published by vnetd
+	Ecmp    string    `json:"ecmp,omitempty"`
+	Members []*Member `json:"members"`
+
+	dst *net.IPNet
+}
+
+// Member of the nexthop group
+type Member struct {
+	Gateway string `json:"gateway,omitempty"`
+	Dev     string `json:"dev"`
+	Weight  int    `json:"weight"`
+	// Packets forwarded through the member, if published by vnetd
+	Packets *uint64 `json:"packets,omitempty"`
+}
+
+func (Command) String() string { return "fib" }
+
+func (Command) Usage() string {
+	return "fib [-json] [show] [PREFIX|ADDRESS]..."
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show forwarding entries and their ECMP members",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the unicast routes of the kernel's main table, which the xeth
+	driver relays to vnetd, with the gateway, interface, and weight of
+	each member of the nexthop group. With a PREFIX, print that route;
+	with an ADDRESS, print the longest matching route.
+
+	vnetd publishes the hardware ECMP group index and per member packet
+	counters of each programmed route as,
+		fib.PREFIX.ecmp: INDEX
+		fib.PREFIX.nexthop.MEMBER.packets: COUNT
+	where MEMBER is the nexthop's gateway or, without one, its
+	interface; a route without these isn't in hardware.
+
+EXAMPLES
+	fib show 10.1.0.0/16
+	fib -json 10.1.2.3
+
+SEE ALSO
+	route, ip route`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	entries, err := Dump(nl.NewSockReceiver(sock))
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		var selected []*Entry
+		for _, arg := range args {
+			e, err := Lookup(entries, arg)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, e)
+		}
+		entries = selected
+	}
+	if fields, err := redis.Hgetall(redis.DefaultHash, Prefix); err == nil {
+		Merge(entries, fields)
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(entries, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tPROTO\tMETRIC\tECMP\tVIA\tDEV\tWEIGHT\tPACKETS")
+	for _, e := range entries {
+		ecmp := e.Ecmp
+		if len(ecmp) == 0 {
+			ecmp = "-"
+		}
+		for i, m := range e.Members {
+			gw, packets := m.Gateway, "-"
+			if len(gw) == 0 {
+				gw = "-"
+			}
+			if m.Packets != nil {
+				packets = strconv.FormatUint(*m.Packets, 10)
+			}
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t", e.Prefix,
+					e.Protocol, e.Metric, ecmp)
+			} else {
+				fmt.Fprint(w, "\t\t\t\t")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", gw, m.Dev, m.Weight,
+				packets)
+		}
+	}
+	return w.Flush()
+}
+
+// Lookup the entry of the prefix or the longest match, least metric, of
+// the address.
+func Lookup(entries []*Entry, s string) (*Entry, error) {
+	var found *Entry
+	if strings.Contains(s, "/") {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.dst.String() == ipnet.String() &&
+				(found == nil || e.Metric < found.Metric) {
+				found = e
+			}
+		}
+	} else {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("%s: invalid address", s)
+		}
+		best := -1
+		for _, e := range entries {
+			ones, _ := e.dst.Mask.Size()
+			if !e.dst.Contains(ip) || ones < best ||
+				(ones == best && e.Metric >= found.Metric) {
+				continue
+			}
+			found, best = e, ones
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no route", s)
+	}
+	return found, nil
+}
+
+// Merge the fields published by vnetd with the entries.
+func Merge(entries []*Entry, fields map[string]string) {
+	for _, e := range entries {
+		e.Ecmp = fields[Prefix+e.Prefix+".ecmp"]
+		for _, m := range e.Members {
+			member := m.Gateway
+			if len(member) == 0 {
+				member = m.Dev
+			}
+			s, found := fields[fmt.Sprint(Prefix, e.Prefix, ".nexthop.",
+				member, ".packets")]
+			if !found {
+				continue
+			}
+			if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+				m.Packets = &u
+			}
+		}
+	}
+}
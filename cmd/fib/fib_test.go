@@ -0,0 +1,57 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fib
+
+import (
+	"net"
+	"testing"
+)
+
+func entry(prefix string, metric uint32, members ...*Member) *Entry {
+	_, dst, _ := net.ParseCIDR(prefix)
+	return &Entry{Prefix: dst.String(), Metric: metric, Members: members,
+		dst: dst}
+}
+
+func TestLookup(t *testing.T) {
+	entries := []*Entry{
+		entry("0.0.0.0/0", 0, &Member{Gateway: "192.168.1.1"}),
+		entry("10.1.0.0/16", 20, &Member{Gateway: "10.0.0.9"}),
+		entry("10.1.0.0/16", 10, &Member{Gateway: "10.0.0.1"},
+			&Member{Gateway: "10.0.0.2"}),
+		entry("10.1.2.0/24", 0, &Member{Dev: "eth-1-1"}),
+	}
+	for _, x := range []struct{ arg, prefix string }{
+		{"10.1.2.3", "10.1.2.0/24"},
+		{"10.1.3.3", "10.1.0.0/16"},
+		{"10.1.0.0/16", "10.1.0.0/16"},
+		{"8.8.8.8", "0.0.0.0/0"},
+	} {
+		e, err := Lookup(entries, x.arg)
+		if err != nil {
+			t.Errorf("%s: %v", x.arg, err)
+		} else if e.Prefix != x.prefix {
+			t.Errorf("%s: %s", x.arg, e.Prefix)
+		} else if e.Prefix == "10.1.0.0/16" && e.Metric != 10 {
+			t.Errorf("%s: metric %d", x.arg, e.Metric)
+		}
+	}
+	if _, err := Lookup(entries, "10.2.0.0/16"); err == nil {
+		t.Error("10.2.0.0/16: expected no route")
+	}
+	Merge(entries, map[string]string{
+		"fib.10.1.0.0/16.ecmp":                      "7",
+		"fib.10.1.0.0/16.nexthop.10.0.0.2.packets":  "99",
+		"fib.10.1.2.0/24.nexthop.eth-1-1.packets":   "5",
+		"fib.10.1.2.0/24.nexthop.eth-1-1.something": "x",
+	})
+	if e := entries[2]; e.Ecmp != "7" || e.Members[0].Packets != nil ||
+		*e.Members[1].Packets != 99 {
+		t.Errorf("%+v", e)
+	}
+	if p := entries[3].Members[0].Packets; p == nil || *p != 5 {
+		t.Error("eth-1-1: packets")
+	}
+}
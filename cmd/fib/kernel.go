@@ -0,0 +1,133 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package fib
+
+import (
+	"net"
+	"strconv"
+	"unsafe"
+
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+// struct rtnexthop of linux/rtnetlink.h
+type rtNexthop struct {
+	Len     uint16
+	Flags   uint8
+	Hops    uint8
+	Ifindex int32
+}
+
+const sizeofRtNexthop = 8
+
+// Dump the unicast routes of the kernel's main table, those that the xeth
+// driver relays to vnetd.
+func Dump(sr *nl.SockReceiver) ([]*Entry, error) {
+	var entries []*Entry
+	names := make(map[int32]string)
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifs {
+			names[int32(ifi.Index)] = ifi.Name
+		}
+	}
+	for _, af := range []uint8{rtnl.AF_INET, rtnl.AF_INET6} {
+		req, err := nl.NewMessage(nl.Hdr{
+			Type:  rtnl.RTM_GETROUTE,
+			Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+		}, rtnl.RtGenMsg{
+			Family: af,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = sr.UntilDone(req, func(b []byte) {
+			if e := parse(b, names); e != nil {
+				entries = append(entries, e)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func parse(b []byte, names map[int32]string) *Entry {
+	var rta rtnl.Rta
+	if nl.HdrPtr(b).Type != rtnl.RTM_NEWROUTE {
+		return nil
+	}
+	msg := rtnl.RtMsgPtr(b)
+	if msg == nil || msg.Type != rtnl.RTN_UNICAST {
+		return nil
+	}
+	rta.Write(b)
+	table := uint32(msg.Table)
+	if len(rta[rtnl.RTA_TABLE]) > 0 {
+		table = nl.Uint32(rta[rtnl.RTA_TABLE])
+	}
+	if table != rtnl.RT_TABLE_MAIN {
+		return nil
+	}
+	bits := 32
+	if msg.Family == rtnl.AF_INET6 {
+		bits = 128
+	}
+	dst := &net.IPNet{
+		IP:   make(net.IP, bits/8),
+		Mask: net.CIDRMask(int(msg.Dst_len), bits),
+	}
+	copy(dst.IP, rta[rtnl.RTA_DST])
+	e := &Entry{
+		Prefix:   dst.String(),
+		Protocol: rtnl.RtProtName[msg.Protocol],
+		dst:      dst,
+	}
+	if len(e.Protocol) == 0 {
+		e.Protocol = strconv.Itoa(int(msg.Protocol))
+	}
+	if len(rta[rtnl.RTA_PRIORITY]) > 0 {
+		e.Metric = nl.Uint32(rta[rtnl.RTA_PRIORITY])
+	}
+	mp := rta[rtnl.RTA_MULTIPATH]
+	if len(mp) == 0 {
+		e.Members = []*Member{{
+			Gateway: ipString(rta[rtnl.RTA_GATEWAY]),
+			Dev:     names[nl.Int32(rta[rtnl.RTA_OIF])],
+			Weight:  1,
+		}}
+		return e
+	}
+	for len(mp) >= sizeofRtNexthop {
+		rtnh := (*rtNexthop)(unsafe.Pointer(&mp[0]))
+		n := int(rtnh.Len)
+		if n < sizeofRtNexthop || n > len(mp) {
+			break
+		}
+		m := &Member{
+			Dev:    names[rtnh.Ifindex],
+			Weight: int(rtnh.Hops) + 1,
+		}
+		nl.ForEachAttr(mp[sizeofRtNexthop:n], func(t uint16, v []byte) {
+			if t == rtnl.RTA_GATEWAY {
+				m.Gateway = ipString(v)
+			}
+		})
+		e.Members = append(e.Members, m)
+		if n = nl.NLMSG.Align(n); n > len(mp) {
+			break
+		}
+		mp = mp[n:]
+	}
+	return e
+}
+
+func ipString(b []byte) string {
+	if len(b) == 4 || len(b) == 16 {
+		return net.IP(b).String()
+	}
+	return ""
+}
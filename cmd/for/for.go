@@ -66,7 +66,7 @@ func (c Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func
 	for {
 		if len(cl.Cmds) == 0 {
 			for len(ls.Cmds) == 0 {
-				newls, err := shellutils.Parse("for>", g.Catline)
+				newls, err := shellutils.Parse("for>", g)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -149,20 +149,17 @@ func runList(pipeline []func(stdin io.Reader, stdout io.Writer, stderr io.Writer
 func makeBlockFunc(g *goes.Goes, varName string,
 	wordList []shellutils.Word,
 	doList []func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error) (func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error, error) {
-	if g.EnvMap == nil {
-		g.EnvMap = make(map[string]string)
-	}
 	runfun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		for _, word := range wordList {
 			for _, str := range word.Expand() {
-				g.EnvMap[varName] = str
+				g.Context().Setenv(varName, str)
 				err := runList(doList, stdin, stdout, stderr)
 				if err != nil {
 					fmt.Fprintln(stderr, err)
 				}
-				if g.Status != nil {
-					if g.Status.Error() == "signal: interrupt" {
-						return g.Status
+				if status := g.Status(); status != nil {
+					if status.Error() == "signal: interrupt" {
+						return status
 					}
 				}
 			}
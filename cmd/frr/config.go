@@ -0,0 +1,273 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package frr
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the redis settable fields,
+//
+//	frr.hostname: NAME
+//	frr.bgp.asn: ASN
+//	frr.bgp.router-id: A.B.C.D
+//	frr.bgp.neighbor.ADDRESS: ASN
+//	frr.bgp.networks: PREFIX...
+//	frr.bgp.redistribute: connected|static|ospf...
+//	frr.ospf.router-id: A.B.C.D
+//	frr.ospf.network.PREFIX: AREA
+//	frr.ospf.passive: IFNAME...
+//	frr.ospf.redistribute: connected|static|bgp...
+//
+// and those published by frrd,
+//
+//	frr.ready: true
+//	frr.daemon.NAME: PID|down
+//	frr.bgp.peer.ADDRESS.state: STATE
+//	frr.bgp.peer.ADDRESS.prefixes: COUNT
+//	frr.ospf.peer.ROUTER-ID.state: STATE
+//	frr.ospf.peer.ROUTER-ID.address: ADDRESS
+//	frr.routes.PROTOCOL: COUNT
+const Prefix = "frr."
+
+const (
+	ReadyField = Prefix + "ready"
+	PeerPrefix = "peer."
+)
+
+// Config of the FRR routing protocols
+type Config struct {
+	Hostname string `json:"hostname,omitempty"`
+	Bgp      *Bgp   `json:"bgp,omitempty"`
+	Ospf     *Ospf  `json:"ospf,omitempty"`
+}
+
+type Bgp struct {
+	Asn          uint32            `json:"asn"`
+	RouterId     net.IP            `json:"router-id,omitempty"`
+	Neighbors    map[string]uint32 `json:"neighbors,omitempty"`
+	Networks     []string          `json:"networks,omitempty"`
+	Redistribute []string          `json:"redistribute,omitempty"`
+}
+
+type Ospf struct {
+	RouterId     net.IP            `json:"router-id,omitempty"`
+	Networks     map[string]string `json:"networks,omitempty"`
+	Passive      []string          `json:"passive,omitempty"`
+	Redistribute []string          `json:"redistribute,omitempty"`
+}
+
+// Settable returns true if the field isn't one published by frrd.
+func Settable(field string) bool {
+	return strings.HasPrefix(field, Prefix) && field != ReadyField &&
+		!strings.HasPrefix(field, Prefix+"bgp."+PeerPrefix) &&
+		!strings.HasPrefix(field, Prefix+"ospf."+PeerPrefix) &&
+		!strings.HasPrefix(field, Prefix+"routes.") &&
+		!strings.HasPrefix(field, Prefix+"daemon.")
+}
+
+// Parse the settable frr.* fields; others are ignored.
+func Parse(fields map[string]string) (*Config, error) {
+	c := new(Config)
+	bgp := func() *Bgp {
+		if c.Bgp == nil {
+			c.Bgp = &Bgp{Neighbors: make(map[string]uint32)}
+		}
+		return c.Bgp
+	}
+	ospf := func() *Ospf {
+		if c.Ospf == nil {
+			c.Ospf = &Ospf{Networks: make(map[string]string)}
+		}
+		return c.Ospf
+	}
+	for field, value := range fields {
+		value = strings.TrimSpace(value)
+		if !Settable(field) || len(value) == 0 {
+			continue
+		}
+		name := field[len(Prefix):]
+		var err error
+		switch {
+		case name == "hostname":
+			c.Hostname = value
+		case name == "bgp.asn":
+			bgp().Asn, err = parseAsn(value)
+		case name == "bgp.router-id":
+			bgp().RouterId, err = parseId(value)
+		case strings.HasPrefix(name, "bgp.neighbor."):
+			addr := name[len("bgp.neighbor."):]
+			if net.ParseIP(addr) == nil {
+				err = fmt.Errorf("%q: invalid address", addr)
+				break
+			}
+			bgp().Neighbors[addr], err = parseAsn(value)
+		case name == "bgp.networks":
+			bgp().Networks, err = parsePrefixes(strings.Fields(value))
+		case name == "bgp.redistribute":
+			bgp().Redistribute, err = parseRedistribute(value, "bgp")
+		case name == "ospf.router-id":
+			ospf().RouterId, err = parseId(value)
+		case strings.HasPrefix(name, "ospf.network."):
+			var prefixes []string
+			prefixes, err = parsePrefixes(
+				[]string{name[len("ospf.network."):]})
+			if err == nil && strings.Contains(prefixes[0], ":") {
+				err = fmt.Errorf("ospf is IPv4 only")
+			} else if err == nil && !isArea(value) {
+				err = fmt.Errorf("%q: invalid area", value)
+			}
+			if err == nil {
+				ospf().Networks[prefixes[0]] = value
+			}
+		case name == "ospf.passive":
+			ospf().Passive = strings.Fields(value)
+		case name == "ospf.redistribute":
+			ospf().Redistribute, err = parseRedistribute(value, "ospf")
+		default:
+			err = fmt.Errorf("unknown")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+	}
+	if c.Bgp != nil && c.Bgp.Asn == 0 {
+		return nil, fmt.Errorf("%sbgp.asn: missing", Prefix)
+	}
+	return c, nil
+}
+
+func parseAsn(s string) (uint32, error) {
+	u, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || u == 0 {
+		return 0, fmt.Errorf("%q: invalid AS number", s)
+	}
+	return uint32(u), nil
+}
+
+func parseId(s string) (net.IP, error) {
+	if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+		return ip.To4(), nil
+	}
+	return nil, fmt.Errorf("%q: invalid router-id", s)
+}
+
+// parsePrefixes requires canonical prefixes so that these match the
+// fields.
+func parsePrefixes(args []string) ([]string, error) {
+	for _, s := range args {
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid prefix", s)
+		}
+		if ipnet.String() != s {
+			return nil, fmt.Errorf("%q: should be %s", s, ipnet)
+		}
+	}
+	return args, nil
+}
+
+func parseRedistribute(s, self string) ([]string, error) {
+	args := strings.Fields(s)
+	for _, x := range args {
+		switch x {
+		case "connected", "static", "kernel", "bgp", "ospf":
+			if x != self {
+				continue
+			}
+		}
+		return nil, fmt.Errorf("%q: invalid", x)
+	}
+	return args, nil
+}
+
+// isArea returns true if s is a decimal or dotted area.
+func isArea(s string) bool {
+	if _, err := strconv.ParseUint(s, 10, 32); err == nil {
+		return true
+	}
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil
+}
+
+// Daemons returns the FRR daemons of the configuration.
+func (c *Config) Daemons() []string {
+	daemons := []string{"zebra"}
+	if c.Bgp != nil {
+		daemons = append(daemons, "bgpd")
+	}
+	if c.Ospf != nil {
+		daemons = append(daemons, "ospfd")
+	}
+	return daemons
+}
+
+// Render the FRR integrated configuration, frr.conf.
+func (c *Config) Render(w io.Writer) {
+	fmt.Fprintln(w, "! rendered by frrd of the frr.* fields; don't edit")
+	fmt.Fprintln(w, "frr defaults traditional")
+	if len(c.Hostname) > 0 {
+		fmt.Fprintln(w, "hostname", c.Hostname)
+	}
+	fmt.Fprintln(w, "log syslog informational")
+	fmt.Fprintln(w, "service integrated-vtysh-config")
+	fmt.Fprintln(w, "!")
+	if bgp := c.Bgp; bgp != nil {
+		fmt.Fprintln(w, "router bgp", bgp.Asn)
+		if bgp.RouterId != nil {
+			fmt.Fprintln(w, " bgp router-id", bgp.RouterId)
+		}
+		fmt.Fprintln(w, " no bgp ebgp-requires-policy")
+		neighbors := Keys(bgp.Neighbors)
+		for _, addr := range neighbors {
+			fmt.Fprintln(w, " neighbor", addr, "remote-as",
+				bgp.Neighbors[addr])
+		}
+		for _, af := range []string{"ipv4", "ipv6"} {
+			inet6 := af == "ipv6"
+			fmt.Fprintf(w, " address-family %s unicast\n", af)
+			for _, s := range bgp.Networks {
+				if strings.Contains(s, ":") == inet6 {
+					fmt.Fprintln(w, "  network", s)
+				}
+			}
+			for _, s := range bgp.Redistribute {
+				if s != "ospf" || !inet6 {
+					fmt.Fprintln(w, "  redistribute", s)
+				}
+			}
+			for _, addr := range neighbors {
+				if inet6 && strings.Contains(addr, ":") {
+					fmt.Fprintln(w, "  neighbor", addr, "activate")
+				}
+			}
+			fmt.Fprintln(w, " exit-address-family")
+		}
+		fmt.Fprintln(w, "!")
+	}
+	if ospf := c.Ospf; ospf != nil {
+		fmt.Fprintln(w, "router ospf")
+		if ospf.RouterId != nil {
+			fmt.Fprintln(w, " ospf router-id", ospf.RouterId)
+		}
+		for _, s := range ospf.Redistribute {
+			fmt.Fprintln(w, " redistribute", s)
+		}
+		for _, ifname := range ospf.Passive {
+			fmt.Fprintln(w, " passive-interface", ifname)
+		}
+		for _, prefix := range Keys(ospf.Networks) {
+			fmt.Fprintln(w, " network", prefix, "area",
+				ospf.Networks[prefix])
+		}
+		fmt.Fprintln(w, "!")
+	}
+	fmt.Fprintln(w, "line vty")
+	fmt.Fprintln(w, "!")
+}
@@ -0,0 +1,74 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package frr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"frr.bgp.asn":                  "65001",
+		"frr.bgp.router-id":            "10.0.0.1",
+		"frr.bgp.neighbor.10.0.0.2":    "65002",
+		"frr.bgp.neighbor.2001:db8::2": "65002",
+		"frr.bgp.networks":             "10.1.0.0/16 2001:db8:1::/48",
+		"frr.ospf.network.10.0.0.0/24": "0",
+		"frr.bgp.peer.10.0.0.2.state":  "Established",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	c.Render(buf)
+	s := buf.String()
+	for _, line := range []string{
+		"router bgp 65001\n",
+		" neighbor 10.0.0.2 remote-as 65002\n",
+		" address-family ipv6 unicast\n  network 2001:db8:1::/48\n" +
+			"  neighbor 2001:db8::2 activate\n",
+		" network 10.0.0.0/24 area 0\n",
+	} {
+		if !strings.Contains(s, line) {
+			t.Errorf("missing %q of\n%s", line, s)
+		}
+	}
+	if d := c.Daemons(); len(d) != 3 {
+		t.Errorf("daemons: %v", d)
+	}
+	for _, fields := range []map[string]string{
+		{"frr.bgp.router-id": "10.0.0.1"},
+		{"frr.bgp.asn": "65001", "frr.bgp.networks": "10.1.2.3/16"},
+		{"frr.ospf.network.2001:db8::/32": "0"},
+		{"frr.ospf.redistribute": "ospf"},
+		{"frr.isis.net": "49.0001"},
+	} {
+		if _, err = Parse(fields); err == nil {
+			t.Errorf("%v: expected error", fields)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	st := &Status{
+		Ready:   true,
+		Daemons: map[string]string{"bgpd": "123"},
+		Bgp: map[string]*BgpPeer{
+			"2001:db8::2": {State: "Established", Prefixes: 3},
+		},
+		Ospf: map[string]*OspfPeer{
+			"10.0.0.9": {State: "Full/DR", Address: "10.0.0.2"},
+		},
+		Routes: map[string]int{"bgp": 3},
+	}
+	x := ParseStatus(st.Fields())
+	if !x.Ready || x.Daemons["bgpd"] != "123" || x.Routes["bgp"] != 3 ||
+		*x.Bgp["2001:db8::2"] != *st.Bgp["2001:db8::2"] ||
+		*x.Ospf["10.0.0.9"] != *st.Ospf["10.0.0.9"] {
+		t.Errorf("%+v", x)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package frr provides the schema of the redis settable frr.* fields that
+// frrd renders as the FRR configuration along with the BGP and OSPF status
+// that it mirrors from FRR and a command to show these.
+package frr
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "frr" }
+
+func (Command) Usage() string {
+	return "frr [-json] [show] [bgp|ospf|routes|config]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show FRR routing protocol status",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	frr [-json] [show]
+		print the FRR daemons, BGP and OSPF neighbors, and the
+		count of learned routes by protocol
+
+	frr [-json] show bgp|ospf|routes
+		print just the given status
+
+	frr [-json] show config
+		print the configuration, or the frr.conf rendered by frrd
+
+	The configuration is changed with these redis settable fields,
+		hset platina frr.hostname NAME
+		hset platina frr.bgp.asn ASN
+		hset platina frr.bgp.router-id A.B.C.D
+		hset platina frr.bgp.neighbor.ADDRESS ASN
+		hset platina frr.bgp.networks "PREFIX..."
+		hset platina frr.bgp.redistribute "connected|static|ospf..."
+		hset platina frr.ospf.router-id A.B.C.D
+		hset platina frr.ospf.network.PREFIX AREA
+		hset platina frr.ospf.passive "IFNAME..."
+		hset platina frr.ospf.redistribute "connected|static|bgp..."
+	An empty value removes the field.
+
+SEE ALSO
+	frrd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	what := ""
+	if len(args) > 0 {
+		what, args = args[0], args[1:]
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return err
+	}
+	settable := make(map[string]string)
+	for field, value := range fields {
+		if Settable(field) {
+			settable[field] = value
+		}
+	}
+	c, err := Parse(settable)
+	if err != nil {
+		return err
+	}
+	st := ParseStatus(fields)
+	var v interface{}
+	switch what {
+	case "":
+		v = st
+	case "bgp":
+		v = st.Bgp
+	case "ospf":
+		v = st.Ospf
+	case "routes":
+		v = st.Routes
+	case "config":
+		v = c
+	default:
+		return fmt.Errorf("%s: unknown", what)
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(v, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if what == "config" {
+		c.Render(os.Stdout)
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if what == "" {
+		fmt.Fprintln(w, "DAEMON\tPID")
+		for _, name := range Keys(st.Daemons) {
+			fmt.Fprintf(w, "%s\t%s\n", name, st.Daemons[name])
+		}
+		fmt.Fprintln(w)
+	}
+	if what == "" || what == "bgp" {
+		fmt.Fprintln(w, "BGP NEIGHBOR\tAS\tSTATE\tPREFIXES")
+		for _, addr := range Keys(st.Bgp) {
+			var asn uint32
+			if c.Bgp != nil {
+				asn = c.Bgp.Neighbors[addr]
+			}
+			peer := st.Bgp[addr]
+			fmt.Fprintf(w, "%s\t%d\t%s\t%d\n", addr, asn, peer.State,
+				peer.Prefixes)
+		}
+	}
+	if what == "" {
+		fmt.Fprintln(w)
+	}
+	if what == "" || what == "ospf" {
+		fmt.Fprintln(w, "OSPF NEIGHBOR\tADDRESS\tSTATE")
+		for _, id := range Keys(st.Ospf) {
+			peer := st.Ospf[id]
+			fmt.Fprintf(w, "%s\t%s\t%s\n", id, peer.Address, peer.State)
+		}
+	}
+	if what == "" {
+		fmt.Fprintln(w)
+	}
+	if what == "" || what == "routes" {
+		fmt.Fprintln(w, "PROTOCOL\tROUTES")
+		for _, proto := range Keys(st.Routes) {
+			fmt.Fprintf(w, "%s\t%d\n", proto, st.Routes[proto])
+		}
+	}
+	return w.Flush()
+}
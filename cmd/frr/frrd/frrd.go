@@ -0,0 +1,469 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package frrd provides a daemon that keeps the redis settable frr.*
+// fields, renders these as the FRR integrated configuration, and mirrors
+// the FRR daemons, BGP and OSPF neighbors, and learned route counts to
+// redis.
+package frrd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/rpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/frr"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Conf is the rendered configuration, default: /etc/frr/frr.conf
+	Conf string
+	// RunDir of the FRR daemon pid files, default: /var/run/frr
+	RunDir string
+	// Vtysh program, default: vtysh
+	Vtysh string
+	// Reload script that applies the differences of the configuration
+	// to the running daemons, default: /usr/lib/frr/frr-reload.py;
+	// without this, frrd adds the configuration with vtysh -f.
+	Reload string
+	// Interval of the status mirror, default: 10s
+	Interval time.Duration
+
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	cfg      *frr.Config
+	hset     chan hset
+	// pid of each daemon from the last poll
+	pids map[string]int
+	// published status fields
+	published map[string]string
+	// last logged error by vtysh command
+	errs map[string]string
+}
+
+// Frrd is the RPC handler of the redis settable frr.* fields.
+type Frrd struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+const timeout = 10 * time.Second
+
+func (*Command) String() string { return "frrd" }
+
+func (*Command) Usage() string { return "frrd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "FRR integration daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable frr.* fields in /etc/goes/persist/frr then
+	render these as the FRR integrated configuration on start and on
+	each change. frrd applies a changed configuration to the running
+	FRR daemons with frr-reload.py, if available, otherwise vtysh -f.
+
+	The FRR daemons are supervised by goes daemons as external programs
+	of the machine configuration that start after frrd has rendered the
+	configuration. frrd watches the pid files of the daemons needed by
+	the configuration, zebra, bgpd with frr.bgp.*, and ospfd with
+	frr.ospf.*, and applies the whole configuration with vtysh -b
+	whenever one has (re)started.
+
+	Each interval, frrd mirrors these to redis,
+		frr.daemon.NAME: PID|down
+		frr.bgp.peer.ADDRESS.state: STATE
+		frr.bgp.peer.ADDRESS.prefixes: COUNT
+		frr.ospf.peer.ROUTER-ID.state: STATE
+		frr.ospf.peer.ROUTER-ID.address: ADDRESS
+		frr.routes.PROTOCOL: COUNT
+	and publishes frr.ready: true once the configuration is rendered.
+
+FILES
+	/etc/goes/persist/frr
+	/etc/goes/machine.yaml
+		frrd:
+		  conf: /etc/frr/frr.conf
+		  rundir: /var/run/frr
+		  vtysh: vtysh
+		  reload: /usr/lib/frr/frr-reload.py
+		  interval: 10s
+		daemons:
+		  frrd:
+		    ready: ["frr.ready=true"]
+		  zebra:
+		    exec: /usr/lib/frr/zebra -A 127.0.0.1
+		    after: [frrd]
+		  bgpd:
+		    exec: /usr/lib/frr/bgpd -A 127.0.0.1
+		    after: [zebra]
+		  ospfd:
+		    exec: /usr/lib/frr/ospfd -A 127.0.0.1
+		    after: [zebra]
+
+SEE ALSO
+	frr, daemons`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	m := machine.Default()
+	c.Conf = m.String("frrd.conf", c.Conf)
+	if len(c.Conf) == 0 {
+		c.Conf = "/etc/frr/frr.conf"
+	}
+	c.RunDir = m.String("frrd.rundir", c.RunDir)
+	if len(c.RunDir) == 0 {
+		c.RunDir = "/var/run/frr"
+	}
+	c.Vtysh = m.String("frrd.vtysh", c.Vtysh)
+	if len(c.Vtysh) == 0 {
+		c.Vtysh = "vtysh"
+	}
+	c.Reload = m.String("frrd.reload", c.Reload)
+	if len(c.Reload) == 0 {
+		c.Reload = "/usr/lib/frr/frr-reload.py"
+	}
+	if c.Interval == 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.Interval, err = m.Duration("frrd.interval",
+		c.Interval); err != nil {
+		return err
+	}
+	if c.settings, err = persist.Load("frr"); err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	if c.cfg, err = frr.Parse(fields); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", frr.Prefix)
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	c.hset = make(chan hset)
+	rpc.Register(&Frrd{c.hset})
+	srvr, err := atsock.NewRpcServer("frrd")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", frr.Prefix)
+	if err = redis.Assign(key, "frrd", "Frrd"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	c.pids = make(map[string]int)
+	c.published = make(map[string]string)
+	c.errs = make(map[string]string)
+	if err = c.apply(); err != nil {
+		log.Print("daemon", "err", err)
+	}
+	// the first poll publishes frr.ready
+	c.poll()
+
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case <-t.C:
+			c.poll()
+		}
+	}
+}
+
+func (frrd *Frrd) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	frrd.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+// set validates the field with the others before saving, publishing, and
+// applying it.
+func (c *Command) set(field, value string) error {
+	value = strings.Join(strings.Fields(value), " ")
+	if !frr.Settable(field) {
+		return fmt.Errorf("%s: read only", field)
+	}
+	fields := map[string]string{field: value}
+	for _, x := range c.settings.Fields() {
+		if x != field {
+			fields[x] = c.settings.Get(x)
+		}
+	}
+	cfg, err := frr.Parse(fields)
+	if err != nil {
+		return err
+	}
+	if err = c.settings.Set(field, value); err != nil {
+		return err
+	}
+	c.cfg = cfg
+	if len(value) > 0 {
+		c.pub.Print(field, ": ", value)
+	} else {
+		c.pub.Print("delete: ", field)
+	}
+	return c.apply()
+}
+
+// apply renders the configuration then, if changed and any FRR daemon is
+// running, reloads it.
+func (c *Command) apply() error {
+	buf := new(bytes.Buffer)
+	c.cfg.Render(buf)
+	if b, err := ioutil.ReadFile(c.Conf); err == nil &&
+		bytes.Equal(b, buf.Bytes()) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Conf), 0755); err != nil {
+		return err
+	}
+	tmp := c.Conf + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, c.Conf); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if len(c.running()) == 0 {
+		return nil
+	}
+	if _, err := os.Stat(c.Reload); err == nil {
+		_, err = c.run(c.Reload, "--reload", c.Conf)
+		return err
+	}
+	_, err := c.run(c.Vtysh, "-f", c.Conf)
+	return err
+}
+
+// running returns the pids of the configured FRR daemons that are running.
+func (c *Command) running() map[string]int {
+	pids := make(map[string]int)
+	for _, name := range c.cfg.Daemons() {
+		b, err := ioutil.ReadFile(filepath.Join(c.RunDir, name+".pid"))
+		if err != nil {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil || pid <= 0 {
+			continue
+		}
+		if syscall.Kill(pid, 0) == nil {
+			pids[name] = pid
+		}
+	}
+	return pids
+}
+
+// poll the FRR daemons and mirror their status to redis.
+func (c *Command) poll() {
+	st := &frr.Status{
+		Ready:   true,
+		Daemons: make(map[string]string),
+		Bgp:     make(map[string]*frr.BgpPeer),
+		Ospf:    make(map[string]*frr.OspfPeer),
+		Routes:  make(map[string]int),
+	}
+	pids := c.running()
+	restarted := false
+	for _, name := range c.cfg.Daemons() {
+		pid, found := pids[name]
+		if !found {
+			st.Daemons[name] = "down"
+			continue
+		}
+		st.Daemons[name] = strconv.Itoa(pid)
+		if c.pids[name] != pid {
+			log.Print("daemon", "info", name, " started ", pid)
+			restarted = true
+		}
+	}
+	c.pids = pids
+	if restarted {
+		if _, err := c.run(c.Vtysh, "-b"); err != nil {
+			log.Print("daemon", "err", err)
+		}
+	}
+	if len(pids) > 0 {
+		if c.cfg.Bgp != nil && pids["bgpd"] != 0 {
+			c.bgp(st)
+		}
+		if c.cfg.Ospf != nil && pids["ospfd"] != 0 {
+			c.ospf(st)
+		}
+		c.routes(st)
+	}
+	fields := st.Fields()
+	for field, value := range fields {
+		if c.published[field] != value {
+			c.pub.Print(field, ": ", value)
+		}
+	}
+	for field := range c.published {
+		if _, found := fields[field]; !found {
+			c.pub.Print("delete: ", field)
+		}
+	}
+	c.published = fields
+}
+
+func (c *Command) bgp(st *frr.Status) {
+	var summary map[string]struct {
+		Peers map[string]struct {
+			State  string `json:"state"`
+			PfxRcd int    `json:"pfxRcd"`
+		} `json:"peers"`
+	}
+	if err := c.vtysh(&summary, "show bgp summary json"); err != nil {
+		return
+	}
+	for _, af := range summary {
+		for addr, peer := range af.Peers {
+			x, found := st.Bgp[addr]
+			if !found {
+				x = &frr.BgpPeer{State: peer.State}
+				st.Bgp[addr] = x
+			}
+			x.Prefixes += peer.PfxRcd
+		}
+	}
+}
+
+func (c *Command) ospf(st *frr.Status) {
+	var neighbors struct {
+		Neighbors map[string][]struct {
+			// older versions have nbrState and ifaceAddress
+			State        string `json:"state"`
+			NbrState     string `json:"nbrState"`
+			Address      string `json:"address"`
+			IfaceAddress string `json:"ifaceAddress"`
+		} `json:"neighbors"`
+	}
+	if err := c.vtysh(&neighbors, "show ip ospf neighbor json"); err != nil {
+		return
+	}
+	for id, list := range neighbors.Neighbors {
+		for _, nbr := range list {
+			peer := &frr.OspfPeer{
+				State:   nbr.State,
+				Address: nbr.Address,
+			}
+			if len(peer.State) == 0 {
+				peer.State = nbr.NbrState
+			}
+			if len(peer.Address) == 0 {
+				peer.Address = nbr.IfaceAddress
+			}
+			st.Ospf[id] = peer
+		}
+	}
+}
+
+func (c *Command) routes(st *frr.Status) {
+	for _, af := range []string{"ip", "ipv6"} {
+		var summary struct {
+			Routes []struct {
+				Type string `json:"type"`
+				Rib  int    `json:"rib"`
+			} `json:"routes"`
+		}
+		err := c.vtysh(&summary, "show "+af+" route summary json")
+		if err != nil {
+			continue
+		}
+		for _, r := range summary.Routes {
+			st.Routes[r.Type] += r.Rib
+		}
+	}
+}
+
+// vtysh decodes the JSON output of the command, logging changed errors.
+func (c *Command) vtysh(v interface{}, command string) error {
+	b, err := c.run(c.Vtysh, "-c", command)
+	if err == nil {
+		err = json.Unmarshal(b, v)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %v", command, err)
+		if c.errs[command] != err.Error() {
+			log.Print("daemon", "err", err)
+		}
+		c.errs[command] = err.Error()
+	} else {
+		delete(c.errs, command)
+	}
+	return err
+}
+
+func (*Command) run(name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	b, err := exec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			err = fmt.Errorf("%s: %s", name,
+				strings.TrimSpace(string(ee.Stderr)))
+		} else {
+			err = fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return b, err
+}
@@ -0,0 +1,145 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package frr
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Status of FRR published by frrd
+type Status struct {
+	Ready   bool                 `json:"ready"`
+	Daemons map[string]string    `json:"daemons,omitempty"`
+	Bgp     map[string]*BgpPeer  `json:"bgp,omitempty"`
+	Ospf    map[string]*OspfPeer `json:"ospf,omitempty"`
+	Routes  map[string]int       `json:"routes,omitempty"`
+}
+
+type BgpPeer struct {
+	State    string `json:"state"`
+	Prefixes int    `json:"prefixes"`
+}
+
+type OspfPeer struct {
+	State   string `json:"state"`
+	Address string `json:"address,omitempty"`
+}
+
+// ParseStatus of the frr.* fields published by frrd; others are ignored.
+func ParseStatus(fields map[string]string) *Status {
+	st := &Status{
+		Daemons: make(map[string]string),
+		Bgp:     make(map[string]*BgpPeer),
+		Ospf:    make(map[string]*OspfPeer),
+		Routes:  make(map[string]int),
+	}
+	// the peer address or router-id precedes the last dot
+	split := func(s string) (string, string) {
+		i := strings.LastIndex(s, ".")
+		if i < 0 {
+			return s, ""
+		}
+		return s[:i], s[i+1:]
+	}
+	for field, value := range fields {
+		if !strings.HasPrefix(field, Prefix) {
+			continue
+		}
+		name := field[len(Prefix):]
+		switch {
+		case field == ReadyField:
+			st.Ready = value == "true"
+		case strings.HasPrefix(name, "daemon."):
+			st.Daemons[name[len("daemon."):]] = value
+		case strings.HasPrefix(name, "routes."):
+			st.Routes[name[len("routes."):]], _ = strconv.Atoi(value)
+		case strings.HasPrefix(name, "bgp."+PeerPrefix):
+			addr, attr := split(name[len("bgp."+PeerPrefix):])
+			peer, found := st.Bgp[addr]
+			if !found {
+				peer = new(BgpPeer)
+				st.Bgp[addr] = peer
+			}
+			switch attr {
+			case "state":
+				peer.State = value
+			case "prefixes":
+				peer.Prefixes, _ = strconv.Atoi(value)
+			}
+		case strings.HasPrefix(name, "ospf."+PeerPrefix):
+			id, attr := split(name[len("ospf."+PeerPrefix):])
+			peer, found := st.Ospf[id]
+			if !found {
+				peer = new(OspfPeer)
+				st.Ospf[id] = peer
+			}
+			switch attr {
+			case "state":
+				peer.State = value
+			case "address":
+				peer.Address = value
+			}
+		}
+	}
+	return st
+}
+
+// Fields returns the published fields of the status.
+func (st *Status) Fields() map[string]string {
+	fields := make(map[string]string)
+	if st.Ready {
+		fields[ReadyField] = "true"
+	}
+	for name, s := range st.Daemons {
+		fields[Prefix+"daemon."+name] = s
+	}
+	for proto, n := range st.Routes {
+		fields[Prefix+"routes."+proto] = strconv.Itoa(n)
+	}
+	for addr, peer := range st.Bgp {
+		prefix := Prefix + "bgp." + PeerPrefix + addr + "."
+		fields[prefix+"state"] = peer.State
+		fields[prefix+"prefixes"] = strconv.Itoa(peer.Prefixes)
+	}
+	for id, peer := range st.Ospf {
+		prefix := Prefix + "ospf." + PeerPrefix + id + "."
+		fields[prefix+"state"] = peer.State
+		if len(peer.Address) > 0 {
+			fields[prefix+"address"] = peer.Address
+		}
+	}
+	return fields
+}
+
+// Keys returns the sorted keys of a status map.
+func Keys(m interface{}) []string {
+	var keys []string
+	switch t := m.(type) {
+	case map[string]string:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]uint32:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]int:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]*BgpPeer:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]*OspfPeer:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -53,7 +53,7 @@ func (Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func(s
 	for len(cl.Cmds) < 1 {
 		ls.Cmds = ls.Cmds[1:]
 		for len(ls.Cmds) == 0 {
-			newls, err := shellutils.Parse("function>", g.Catline)
+			newls, err := shellutils.Parse("function>", g)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -81,7 +81,7 @@ func (Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func(s
 		funList = append(funList, runfun)
 		ls = *nextls
 		for len(ls.Cmds) == 0 {
-			newls, err := shellutils.Parse("function>", g.Catline)
+			newls, err := shellutils.Parse("function>", g)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -110,10 +110,7 @@ func (Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func(s
 	f := goes.Function{Name: name, RunFun: runfun}
 
 	deffun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
-		if g.FunctionMap == nil {
-			g.FunctionMap = make(map[string]goes.Function, 0)
-		}
-		g.FunctionMap[name] = f
+		g.Context().SetFunction(f)
 		return nil
 	}
 	return &ls, deffun, nil
@@ -0,0 +1,170 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package gnmid provides a gNMI server of the interface state and counters
+// published to redis, and the interface config of the port.* fields kept by
+// portd, in the openconfig-interfaces schema.
+package gnmid
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/lang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// DefaultListen is the address of the gRPC server without a configured
+// gnmid.listen, that of the IANA assigned gNMI port.
+const DefaultListen = ":9339"
+
+type Command struct {
+	Listen string
+	// Cert and Key are the files of the server's TLS certificate; CA,
+	// if set, the file of the authorities of required client certificates.
+	Cert, Key, CA string
+}
+
+func (*Command) String() string { return "gnmid" }
+
+func (*Command) Usage() string { return "gnmid" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "gNMI telemetry and configuration daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Serve the gNMI Capabilities, Get, Subscribe, and Set RPCs of this
+	subset of openconfig-interfaces,
+		/interfaces/interface[name=IFNAME]/state/name
+		/interfaces/interface[name=IFNAME]/state/admin-status
+		/interfaces/interface[name=IFNAME]/state/oper-status
+		/interfaces/interface[name=IFNAME]/state/description
+		/interfaces/interface[name=IFNAME]/state/counters/COUNTER
+		/interfaces/interface[name=IFNAME]/config/name
+		/interfaces/interface[name=IFNAME]/config/mtu
+		/interfaces/interface[name=IFNAME]/config/enabled
+		/interfaces/interface[name=IFNAME]/config/description
+
+	The state leaves are those of the published fields,
+		IFNAME.admin, IFNAME.state, IFNAME.description
+		IFNAME.rx-packets		in-pkts
+		IFNAME.tx-packets		out-pkts
+		IFNAME.rx-bytes			in-octets
+		IFNAME.tx-bytes			out-octets
+		IFNAME.rx-errors		in-errors
+		IFNAME.tx-errors		out-errors
+		IFNAME.rx-dropped		in-discards
+		IFNAME.tx-dropped		out-discards
+		IFNAME.multicast		in-multicast-pkts
+	and the config leaves those of the port.IFNAME mtu, admin, and
+	description settings kept by portd. A Set of these config leaves, or
+	their config or interface container with a JSON value, changes the
+	port.IFNAME field with the validation of portd; the deletion of an
+	interface removes all of its port settings.
+
+	Paths may have "*" element names and key values, and "..." for any
+	number of elements. Values are typed with the PROTO encoding and
+	scalar JSON with the JSON and JSON_IETF encodings.
+
+	Subscriptions may be ONCE, POLL, or STREAM; the latter with SAMPLE or
+	ON_CHANGE modes, or TARGET_DEFINED as ON_CHANGE. Changes are detected
+	and samples taken each second, so that's also the least sample
+	interval. SAMPLE subscriptions may suppress redundant updates and
+	have a heartbeat interval.
+
+	Without a certificate the server is insecure and read only. With a
+	CA, clients must present a certificate that it signed and may Set.
+
+FILES
+	/etc/goes/machine.yaml
+		gnmid:
+		  listen: ":9339"
+		  cert: /etc/goes/gnmid.crt
+		  key: /etc/goes/gnmid.key
+		  ca: /etc/goes/gnmid-ca.crt
+
+SEE ALSO
+	port, portd, ip link counters`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	c.configure(machine.Default())
+	var opts []grpc.ServerOption
+	settable := false
+	if len(c.Cert) > 0 || len(c.Key) > 0 {
+		config, err := c.tlsConfig()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(config)))
+		settable = config.ClientCAs != nil
+	}
+	ln, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return err
+	}
+	srv := grpc.NewServer(opts...)
+	defer srv.Stop()
+	pb.RegisterGNMIServer(srv, &Server{Settable: settable})
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	select {
+	case <-goes.Stop:
+		return nil
+	case err = <-done:
+		return err
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) {
+	c.Listen = cfg.String("gnmid.listen", c.Listen)
+	if len(c.Listen) == 0 {
+		c.Listen = DefaultListen
+	}
+	c.Cert = cfg.String("gnmid.cert", c.Cert)
+	c.Key = cfg.String("gnmid.key", c.Key)
+	c.CA = cfg.String("gnmid.ca", c.CA)
+}
+
+func (c *Command) tlsConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.Key)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if len(c.CA) > 0 {
+		b, err := ioutil.ReadFile(c.CA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificates", c.CA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
@@ -0,0 +1,399 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gnmid
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/goes/cmd/port"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Counters maps the published IFNAME.COUNTER fields to the leaves of,
+//
+//	/interfaces/interface[name=IFNAME]/state/counters
+var Counters = map[string]string{
+	"rx-packets": "in-pkts",
+	"tx-packets": "out-pkts",
+	"rx-bytes":   "in-octets",
+	"tx-bytes":   "out-octets",
+	"rx-errors":  "in-errors",
+	"tx-errors":  "out-errors",
+	"rx-dropped": "in-discards",
+	"tx-dropped": "out-discards",
+	"multicast":  "in-multicast-pkts",
+}
+
+// OperStatus maps the published IFNAME.state to the openconfig-interfaces
+// oper-status.
+var OperStatus = map[string]string{
+	"unknown":        "UNKNOWN",
+	"notpresent":     "NOT_PRESENT",
+	"down":           "DOWN",
+	"lowerlayerdown": "LOWER_LAYER_DOWN",
+	"testing":        "TESTING",
+	"dormant":        "DORMANT",
+	"up":             "UP",
+}
+
+// Leaf of the data tree
+type Leaf struct {
+	Path   *pb.Path
+	Val    *pb.TypedValue
+	Config bool
+}
+
+// Leaves returns the sorted interface leaves of the published fields of the
+// named interfaces and the port.IFNAME settings.
+func Leaves(fields map[string]string, ifnames map[string]bool) []*Leaf {
+	var leaves []*Leaf
+	add := func(ifname string, config bool, val *pb.TypedValue,
+		names ...string) {
+		elems := []*pb.PathElem{
+			{Name: "interfaces"},
+			{Name: "interface", Key: map[string]string{"name": ifname}},
+		}
+		for _, name := range names {
+			elems = append(elems, &pb.PathElem{Name: name})
+		}
+		leaves = append(leaves, &Leaf{&pb.Path{Elem: elems}, val, config})
+	}
+	seen := make(map[string]bool)
+	for field, value := range fields {
+		if strings.HasPrefix(field, port.Prefix) {
+			name := field[len(port.Prefix):]
+			p, err := port.ParsePort(strings.Fields(value)...)
+			if err != nil || len(name) == 0 || len(value) == 0 {
+				continue
+			}
+			add(name, true, stringVal(name), "config", "name")
+			if p.Mtu != 0 {
+				add(name, true, uintVal(uint64(p.Mtu)),
+					"config", "mtu")
+			}
+			if len(p.Admin) > 0 {
+				add(name, true, boolVal(p.Admin == "up"),
+					"config", "enabled")
+			}
+			if len(p.Description) > 0 {
+				add(name, true, stringVal(p.Description),
+					"config", "description")
+			}
+			continue
+		}
+		i := strings.LastIndexByte(field, '.')
+		if i < 0 || !ifnames[field[:i]] {
+			continue
+		}
+		ifname, leaf := field[:i], field[i+1:]
+		if counter, found := Counters[leaf]; found {
+			u, err := strconv.ParseUint(value, 10, 64)
+			if err == nil {
+				add(ifname, false, uintVal(u),
+					"state", "counters", counter)
+			}
+			seen[ifname] = true
+			continue
+		}
+		switch leaf {
+		case "admin":
+			add(ifname, false, stringVal(strings.ToUpper(value)),
+				"state", "admin-status")
+		case "state":
+			if s, found := OperStatus[value]; found {
+				add(ifname, false, stringVal(s),
+					"state", "oper-status")
+			}
+		case "description":
+			add(ifname, false, stringVal(value),
+				"state", "description")
+		default:
+			continue
+		}
+		seen[ifname] = true
+	}
+	for ifname := range seen {
+		add(ifname, false, stringVal(ifname), "state", "name")
+	}
+	sort.Slice(leaves, func(i, j int) bool {
+		return PathString(leaves[i].Path) < PathString(leaves[j].Path)
+	})
+	return leaves
+}
+
+// Join the prefix and path elements.
+func Join(prefix, path *pb.Path) *pb.Path {
+	elems := append([]*pb.PathElem{}, prefix.GetElem()...)
+	return &pb.Path{Elem: append(elems, path.GetElem()...)}
+}
+
+// Match returns true if the pattern, with "*" names and key values and
+// "..." for any number of elements, is a prefix of the path.
+func Match(pattern, path *pb.Path) bool {
+	return match(pattern.GetElem(), path.GetElem())
+}
+
+func match(pat, elems []*pb.PathElem) bool {
+	if len(pat) == 0 {
+		return true
+	}
+	if pat[0].GetName() == "..." {
+		for i := 0; i <= len(elems); i++ {
+			if match(pat[1:], elems[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(elems) == 0 {
+		return false
+	}
+	p, e := pat[0], elems[0]
+	if p.Name != "*" && p.Name != e.Name {
+		return false
+	}
+	for k, v := range p.Key {
+		if v != "*" && v != e.Key[k] {
+			return false
+		}
+	}
+	return match(pat[1:], elems[1:])
+}
+
+// PathString formats the path as /NAME[KEY=VALUE]/...
+func PathString(path *pb.Path) string {
+	var sb strings.Builder
+	for _, e := range path.GetElem() {
+		sb.WriteString("/")
+		sb.WriteString(e.Name)
+		keys := make([]string, 0, len(e.Key))
+		for k := range e.Key {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, "[%s=%s]", k, e.Key[k])
+		}
+	}
+	if sb.Len() == 0 {
+		return "/"
+	}
+	return sb.String()
+}
+
+// Encode the scalar value as JSON with those encodings; others are as is.
+func Encode(val *pb.TypedValue, encoding pb.Encoding) *pb.TypedValue {
+	var v interface{}
+	switch x := val.GetValue().(type) {
+	case *pb.TypedValue_StringVal:
+		v = x.StringVal
+	case *pb.TypedValue_UintVal:
+		// RFC7951 encodes 64 bit integers as strings
+		if encoding == pb.Encoding_JSON_IETF {
+			v = strconv.FormatUint(x.UintVal, 10)
+		} else {
+			v = x.UintVal
+		}
+	case *pb.TypedValue_BoolVal:
+		v = x.BoolVal
+	default:
+		return val
+	}
+	b, _ := json.Marshal(v)
+	switch encoding {
+	case pb.Encoding_JSON:
+		return &pb.TypedValue{Value: &pb.TypedValue_JsonVal{JsonVal: b}}
+	case pb.Encoding_JSON_IETF:
+		return &pb.TypedValue{
+			Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: b},
+		}
+	}
+	return val
+}
+
+// ValueString formats the scalar value for change detection.
+func ValueString(val *pb.TypedValue) string {
+	switch x := val.GetValue().(type) {
+	case *pb.TypedValue_StringVal:
+		return x.StringVal
+	case *pb.TypedValue_UintVal:
+		return strconv.FormatUint(x.UintVal, 10)
+	case *pb.TypedValue_BoolVal:
+		return strconv.FormatBool(x.BoolVal)
+	}
+	return val.String()
+}
+
+func stringVal(s string) *pb.TypedValue {
+	return &pb.TypedValue{Value: &pb.TypedValue_StringVal{StringVal: s}}
+}
+
+func uintVal(u uint64) *pb.TypedValue {
+	return &pb.TypedValue{Value: &pb.TypedValue_UintVal{UintVal: u}}
+}
+
+func boolVal(b bool) *pb.TypedValue {
+	return &pb.TypedValue{Value: &pb.TypedValue_BoolVal{BoolVal: b}}
+}
+
+// ConfigPath returns the interface name and the config leaf of the path,
+//
+//	/interfaces/interface[name=IFNAME]
+//	/interfaces/interface[name=IFNAME]/config
+//	/interfaces/interface[name=IFNAME]/config/mtu|enabled|description
+//
+// where leaf is empty for the interface and "config" for its container.
+func ConfigPath(path *pb.Path) (ifname, leaf string, err error) {
+	elems := path.GetElem()
+	if len(elems) < 2 || len(elems) > 4 ||
+		elems[0].Name != "interfaces" || elems[1].Name != "interface" {
+		return "", "", fmt.Errorf("%s: not settable", PathString(path))
+	}
+	ifname = elems[1].Key["name"]
+	if len(ifname) == 0 || ifname == "*" {
+		return "", "", fmt.Errorf("%s: missing interface name",
+			PathString(path))
+	}
+	if len(elems) == 2 {
+		return ifname, "", nil
+	}
+	if elems[2].Name != "config" {
+		return "", "", fmt.Errorf("%s: not settable", PathString(path))
+	}
+	if len(elems) == 3 {
+		return ifname, "config", nil
+	}
+	switch leaf = elems[3].Name; leaf {
+	case "mtu", "enabled", "description":
+		return ifname, leaf, nil
+	}
+	return "", "", fmt.Errorf("%s: not settable", PathString(path))
+}
+
+// Apply the value of the config leaf, or that of the interface or config
+// container, to the port settings.
+func Apply(p *port.Port, leaf string, val *pb.TypedValue) error {
+	var v interface{}
+	switch x := val.GetValue().(type) {
+	case *pb.TypedValue_StringVal:
+		v = x.StringVal
+	case *pb.TypedValue_UintVal:
+		v = float64(x.UintVal)
+	case *pb.TypedValue_IntVal:
+		v = float64(x.IntVal)
+	case *pb.TypedValue_BoolVal:
+		v = x.BoolVal
+	case *pb.TypedValue_JsonVal:
+		if err := json.Unmarshal(x.JsonVal, &v); err != nil {
+			return err
+		}
+	case *pb.TypedValue_JsonIetfVal:
+		if err := json.Unmarshal(x.JsonIetfVal, &v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s: unsupported value type", leaf)
+	}
+	return apply(p, leaf, v)
+}
+
+func apply(p *port.Port, leaf string, v interface{}) error {
+	if leaf != "" && leaf != "config" {
+		return applyLeaf(p, leaf, v)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: not an object", leaf)
+	}
+	for k, v := range m {
+		// RFC7951 qualifies names with their module
+		k = k[strings.IndexByte(k, ':')+1:]
+		var err error
+		switch {
+		case k == "name":
+		case leaf == "" && k == "config":
+			err = apply(p, k, v)
+		case leaf == "":
+			err = fmt.Errorf("%s: not settable", k)
+		default:
+			err = applyLeaf(p, k, v)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyLeaf(p *port.Port, leaf string, v interface{}) error {
+	var args []string
+	switch leaf {
+	case "mtu":
+		switch t := v.(type) {
+		case float64:
+			args = []string{"mtu", strconv.FormatFloat(t, 'f', -1, 64)}
+		case string:
+			args = []string{"mtu", t}
+		default:
+			return fmt.Errorf("mtu: not a number")
+		}
+	case "enabled":
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("enabled: not a boolean")
+		}
+		args = []string{"admin", "down"}
+		if b {
+			args[1] = "up"
+		}
+	case "description":
+		s, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("description: not a string")
+		}
+		if len(strings.Fields(s)) == 0 {
+			p.Description = ""
+			return nil
+		}
+		args = append([]string{"description"}, strings.Fields(s)...)
+	default:
+		return fmt.Errorf("%s: not settable", leaf)
+	}
+	x, err := port.ParsePort(args...)
+	if err != nil {
+		return err
+	}
+	switch leaf {
+	case "mtu":
+		p.Mtu = x.Mtu
+	case "enabled":
+		p.Admin = x.Admin
+	case "description":
+		p.Description = x.Description
+	}
+	return nil
+}
+
+// Reset the config leaf, or container, of the port settings; that of the
+// interface removes all of its settings.
+func Reset(p *port.Port, leaf string) {
+	switch leaf {
+	case "":
+		*p = port.Port{}
+	case "config":
+		p.Mtu, p.Admin, p.Description = 0, "", ""
+	case "mtu":
+		p.Mtu = 0
+	case "enabled":
+		p.Admin = ""
+	case "description":
+		p.Description = ""
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gnmid
+
+import (
+	"testing"
+
+	"github.com/platinasystems/goes/cmd/port"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+func TestLeaves(t *testing.T) {
+	fields := map[string]string{
+		"eth-1-1.rx-packets":      "1234",
+		"eth-1-1.state":           "lowerlayerdown",
+		"eth-1-1.admin":           "up",
+		"eth-1-1.inet.route":      "10.0.0.0/8",
+		"eth-9-9.rx-packets":      "1",
+		"goes.daemon.vnetd.state": "running",
+		"port.eth-1-1":            "speed 100g mtu 9000 admin up",
+	}
+	want := []string{
+		"/interfaces/interface[name=eth-1-1]/config/enabled true",
+		"/interfaces/interface[name=eth-1-1]/config/mtu 9000",
+		"/interfaces/interface[name=eth-1-1]/config/name eth-1-1",
+		"/interfaces/interface[name=eth-1-1]/state/admin-status UP",
+		"/interfaces/interface[name=eth-1-1]/state/counters/in-pkts 1234",
+		"/interfaces/interface[name=eth-1-1]/state/name eth-1-1",
+		"/interfaces/interface[name=eth-1-1]/state/oper-status LOWER_LAYER_DOWN",
+	}
+	leaves := Leaves(fields, map[string]bool{"eth-1-1": true})
+	if len(leaves) != len(want) {
+		t.Fatalf("got %d leaves, want %d", len(leaves), len(want))
+	}
+	for i, l := range leaves {
+		got := PathString(l.Path) + " " + ValueString(l.Val)
+		if got != want[i] {
+			t.Errorf("got %q, want %q", got, want[i])
+		}
+	}
+	pattern := &pb.Path{Elem: []*pb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "*"}},
+		{Name: "..."},
+		{Name: "in-pkts"},
+	}}
+	n := 0
+	for _, l := range leaves {
+		if Match(pattern, l.Path) {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("matched %d leaves, want 1", n)
+	}
+}
+
+func TestApply(t *testing.T) {
+	path := &pb.Path{Elem: []*pb.PathElem{
+		{Name: "interfaces"},
+		{Name: "interface", Key: map[string]string{"name": "eth-1-1"}},
+		{Name: "config"},
+	}}
+	ifname, leaf, err := ConfigPath(path)
+	if err != nil || ifname != "eth-1-1" || leaf != "config" {
+		t.Fatalf("got %q %q %v", ifname, leaf, err)
+	}
+	p := &port.Port{Speed: 100000, Mtu: 1500}
+	err = Apply(p, leaf, &pb.TypedValue{
+		Value: &pb.TypedValue_JsonIetfVal{JsonIetfVal: []byte(`{
+			"openconfig-interfaces:mtu": 9000,
+			"enabled": false,
+			"description": "to spine-1"
+		}`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.String(); s != "speed 100g mtu 9000 admin down description to spine-1" {
+		t.Errorf("got %q", s)
+	}
+	if err = Apply(p, "mtu", uintVal(10000)); err == nil {
+		t.Error("mtu 10000: expected error")
+	}
+	Reset(p, "config")
+	if s := p.String(); s != "speed 100g" {
+		t.Errorf("got %q", s)
+	}
+}
@@ -0,0 +1,418 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gnmid
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/goes/cmd/port"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "github.com/openconfig/gnmi/proto/gnmi"
+)
+
+// Version of the implemented gNMI specification
+const Version = "0.7.0"
+
+// Server of the gNMI RPCs
+type Server struct {
+	// Settable if the clients are authenticated
+	Settable bool
+
+	mutex sync.Mutex
+}
+
+// the interval of change detection and least sample interval
+const tick = time.Second
+
+func (*Server) Capabilities(context.Context,
+	*pb.CapabilityRequest) (*pb.CapabilityResponse, error) {
+	return &pb.CapabilityResponse{
+		SupportedModels: []*pb.ModelData{{
+			Name:         "openconfig-interfaces",
+			Organization: "OpenConfig working group",
+		}},
+		SupportedEncodings: []pb.Encoding{
+			pb.Encoding_JSON,
+			pb.Encoding_JSON_IETF,
+			pb.Encoding_PROTO,
+		},
+		GNMIVersion: Version,
+	}, nil
+}
+
+func (*Server) Get(ctx context.Context,
+	req *pb.GetRequest) (*pb.GetResponse, error) {
+	if err := supported(req.Encoding); err != nil {
+		return nil, err
+	}
+	leaves, err := getLeaves()
+	if err != nil {
+		return nil, err
+	}
+	paths := req.Path
+	if len(paths) == 0 {
+		paths = []*pb.Path{{}}
+	}
+	resp := new(pb.GetResponse)
+	now := time.Now().UnixNano()
+	for _, path := range paths {
+		pattern := Join(req.Prefix, path)
+		n := &pb.Notification{Timestamp: now}
+		for _, l := range leaves {
+			switch {
+			case req.Type == pb.GetRequest_CONFIG && !l.Config:
+			case (req.Type == pb.GetRequest_STATE ||
+				req.Type == pb.GetRequest_OPERATIONAL) && l.Config:
+			case Match(pattern, l.Path):
+				n.Update = append(n.Update, &pb.Update{
+					Path: l.Path,
+					Val:  Encode(l.Val, req.Encoding),
+				})
+			}
+		}
+		if len(n.Update) == 0 {
+			return nil, status.Errorf(codes.NotFound, "%s: not found",
+				PathString(pattern))
+		}
+		resp.Notification = append(resp.Notification, n)
+	}
+	return resp, nil
+}
+
+// Set the port config of the request with the deletes, then replaces, then
+// updates; if any fails, none are.
+func (s *Server) Set(ctx context.Context,
+	req *pb.SetRequest) (*pb.SetResponse, error) {
+	if !s.Settable {
+		return nil, status.Error(codes.PermissionDenied,
+			"unauthenticated clients may not Set")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	fields, err := redis.Hgetall(redis.DefaultHash, port.Prefix)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	c, err := port.Parse(fields)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	changed := make(map[string]*port.Port)
+	get := func(ifname string) *port.Port {
+		p, found := changed[ifname]
+		if !found {
+			p = new(port.Port)
+			if x, found := c[ifname]; found {
+				*p = *x
+			}
+			changed[ifname] = p
+		}
+		return p
+	}
+	resp := &pb.SetResponse{Prefix: req.Prefix}
+	result := func(path *pb.Path, op pb.UpdateResult_Operation) {
+		resp.Response = append(resp.Response, &pb.UpdateResult{
+			Path: path,
+			Op:   op,
+		})
+	}
+	for _, path := range req.Delete {
+		ifname, leaf, err := ConfigPath(Join(req.Prefix, path))
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		Reset(get(ifname), leaf)
+		result(path, pb.UpdateResult_DELETE)
+	}
+	for _, x := range []struct {
+		updates []*pb.Update
+		op      pb.UpdateResult_Operation
+	}{
+		{req.Replace, pb.UpdateResult_REPLACE},
+		{req.Update, pb.UpdateResult_UPDATE},
+	} {
+		for _, u := range x.updates {
+			ifname, leaf, err := ConfigPath(Join(req.Prefix, u.Path))
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument,
+					err.Error())
+			}
+			p := get(ifname)
+			if x.op == pb.UpdateResult_REPLACE {
+				Reset(p, leaf)
+			}
+			if err = Apply(p, leaf, u.Val); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument,
+					"%s: %v", PathString(u.Path), err)
+			}
+			result(u.Path, x.op)
+		}
+	}
+	var set []string
+	for ifname, p := range changed {
+		_, err = redis.Hset(redis.DefaultHash, port.Prefix+ifname,
+			p.String())
+		if err != nil {
+			// restore those already set
+			for _, ifname := range set {
+				redis.Hset(redis.DefaultHash, port.Prefix+ifname,
+					fields[port.Prefix+ifname])
+			}
+			return nil, status.Errorf(codes.Aborted, "%s: %v",
+				ifname, err)
+		}
+		set = append(set, ifname)
+	}
+	if len(set) > 0 {
+		log.Print("daemon", "info", "set: ", strings.Join(set, " "))
+	}
+	resp.Timestamp = time.Now().UnixNano()
+	return resp, nil
+}
+
+func (*Server) Subscribe(stream pb.GNMI_SubscribeServer) error {
+	req, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	list := req.GetSubscribe()
+	if list == nil {
+		return status.Error(codes.InvalidArgument,
+			"the first request must be a subscription list")
+	}
+	if err = supported(list.Encoding); err != nil {
+		return err
+	}
+	subs := make([]*subscription, 0, len(list.Subscription))
+	for _, x := range list.Subscription {
+		subs = append(subs, newSubscription(list, x))
+	}
+	if len(subs) == 0 {
+		subs = append(subs, newSubscription(list, &pb.Subscription{}))
+	}
+	send := func(n *pb.Notification) error {
+		return stream.Send(&pb.SubscribeResponse{
+			Response: &pb.SubscribeResponse_Update{Update: n},
+		})
+	}
+	sync := func() error {
+		return stream.Send(&pb.SubscribeResponse{
+			Response: &pb.SubscribeResponse_SyncResponse{
+				SyncResponse: true,
+			},
+		})
+	}
+	all := func(initial bool) error {
+		leaves, err := getLeaves()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, sub := range subs {
+			n := sub.update(leaves, now, true)
+			if n != nil && !(initial && list.UpdatesOnly) {
+				if err = send(n); err != nil {
+					return err
+				}
+			}
+		}
+		return sync()
+	}
+	switch list.Mode {
+	case pb.SubscriptionList_ONCE:
+		return all(true)
+	case pb.SubscriptionList_POLL:
+		for initial := true; ; initial = false {
+			if err = all(initial); err != nil {
+				return err
+			}
+			req, err = stream.Recv()
+			if err == io.EOF {
+				return nil
+			} else if err != nil {
+				return err
+			}
+			if req.GetPoll() == nil {
+				return status.Error(codes.InvalidArgument,
+					"expected a poll request")
+			}
+		}
+	}
+	if err = all(true); err != nil {
+		return err
+	}
+	// the client may close its end without ending the subscription
+	recv := make(chan error, 1)
+	go func() {
+		for {
+			if _, err := stream.Recv(); err == io.EOF {
+				return
+			} else if err != nil {
+				recv <- err
+				return
+			}
+		}
+	}()
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case err = <-recv:
+			return err
+		case now := <-t.C:
+			var leaves []*Leaf
+			for _, sub := range subs {
+				if !sub.due(now) {
+					continue
+				}
+				if leaves == nil {
+					if leaves, err = getLeaves(); err != nil {
+						return err
+					}
+				}
+				n := sub.update(leaves, now, false)
+				if n != nil {
+					if err = send(n); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+}
+
+type subscription struct {
+	prefix   *pb.Path
+	pattern  *pb.Path
+	encoding pb.Encoding
+	mode     pb.SubscriptionMode
+	suppress bool
+
+	interval, heartbeat time.Duration
+	next, beat          time.Time
+
+	// last value and path of each leaf by path string
+	last map[string]sent
+}
+
+type sent struct {
+	val  string
+	path *pb.Path
+}
+
+func newSubscription(list *pb.SubscriptionList,
+	x *pb.Subscription) *subscription {
+	sub := &subscription{
+		pattern:   Join(list.Prefix, x.Path),
+		encoding:  list.Encoding,
+		mode:      x.Mode,
+		suppress:  x.SuppressRedundant,
+		interval:  time.Duration(x.SampleInterval),
+		heartbeat: time.Duration(x.HeartbeatInterval),
+		last:      make(map[string]sent),
+	}
+	if list.Prefix != nil {
+		sub.prefix = &pb.Path{
+			Origin: list.Prefix.Origin,
+			Target: list.Prefix.Target,
+		}
+	}
+	if sub.mode != pb.SubscriptionMode_SAMPLE {
+		sub.mode = pb.SubscriptionMode_ON_CHANGE
+		sub.interval = tick
+	}
+	if sub.interval < tick {
+		sub.interval = tick
+	}
+	if sub.heartbeat > 0 && sub.heartbeat < tick {
+		sub.heartbeat = tick
+	}
+	return sub
+}
+
+// due with half a tick of slack for the ticker's jitter
+func (sub *subscription) due(now time.Time) bool {
+	return !now.Add(tick / 2).Before(sub.next)
+}
+
+// update returns the notification, if any, of the subscribed leaves that
+// have changed, or all with a sample, heartbeat, or the initial update.
+func (sub *subscription) update(leaves []*Leaf, now time.Time,
+	initial bool) *pb.Notification {
+	all := initial ||
+		sub.mode == pb.SubscriptionMode_SAMPLE && !sub.suppress
+	if sub.heartbeat > 0 && !now.Before(sub.beat) {
+		all = true
+	}
+	if all {
+		sub.beat = now.Add(sub.heartbeat)
+	}
+	sub.next = now.Add(sub.interval)
+	n := &pb.Notification{
+		Timestamp: now.UnixNano(),
+		Prefix:    sub.prefix,
+	}
+	present := make(map[string]bool)
+	for _, l := range leaves {
+		if !Match(sub.pattern, l.Path) {
+			continue
+		}
+		k, v := PathString(l.Path), ValueString(l.Val)
+		present[k] = true
+		if last, found := sub.last[k]; found && last.val == v && !all {
+			continue
+		}
+		sub.last[k] = sent{v, l.Path}
+		n.Update = append(n.Update, &pb.Update{
+			Path: l.Path,
+			Val:  Encode(l.Val, sub.encoding),
+		})
+	}
+	for k, last := range sub.last {
+		if !present[k] {
+			delete(sub.last, k)
+			n.Delete = append(n.Delete, last.path)
+		}
+	}
+	if len(n.Update) == 0 && len(n.Delete) == 0 {
+		return nil
+	}
+	return n
+}
+
+func supported(encoding pb.Encoding) error {
+	switch encoding {
+	case pb.Encoding_JSON, pb.Encoding_JSON_IETF, pb.Encoding_PROTO:
+		return nil
+	}
+	return status.Errorf(codes.Unimplemented, "%s: unsupported encoding",
+		encoding)
+}
+
+// getLeaves of the published fields and present interfaces.
+func getLeaves() ([]*Leaf, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, "")
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	ifnames := make(map[string]bool)
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifs {
+			ifnames[ifi.Name] = true
+		}
+	}
+	return Leaves(fields, ifnames), nil
+}
@@ -9,15 +9,30 @@ import (
 	"fmt"
 	"sort"
 
+	"github.com/platinasystems/goes/external/gpiod"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/lang"
 	"github.com/platinasystems/gpio"
 )
 
-type Command struct{}
+type Command struct {
+	// Machines may map pin names to their gpiod chip and line, e.g.
+	//	"sys-reset": {Chip: "gpiochip0", Line: 12, ActiveLow: true},
+	// The machine configuration file may add or override these.
+	Pins map[string]gpiod.Pin
+}
 
 func (Command) String() string { return "gpio" }
 
-func (Command) Usage() string { return "gpio PIN_NAME [VALUE]" }
+func (Command) Usage() string {
+	return `
+	gpio [PIN_NAME [VALUE]]
+	gpio list
+	gpio get PIN
+	gpio set PIN VALUE
+	gpio direction PIN in|out [VALUE]
+	gpio poll [-t TIMEOUT] PIN [rising|falling|both]`
+}
 
 func (Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -25,7 +40,54 @@ func (Command) Apropos() lang.Alt {
 	}
 }
 
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Without a sub-command, show or set the value of the named sysfs GPIO
+	pins; "gpio default" sets the direction of all such pins.
+
+	The list, get, set, direction, and poll sub-commands access pins
+	through the gpiod character device, /dev/gpiochipN. PIN is either a
+	name from the machine's pin map or CHIP:LINE, e.g. gpiochip0:12.
+	Values are logical, i.e. inverted for active-low pins.
+
+	list	show each named pin's chip line, direction, and value
+	get	show the pin's value
+	set	drive the pin's value
+	direction
+		set the pin as an input or an output with VALUE (default 0)
+	poll	wait for the pin's edge (default both) or TIMEOUT
+
+FILES
+	/etc/goes/machine.yaml
+		gpio:
+		  pins:
+		    sys-reset: gpiochip0 12 active-low
+		    qsfp-18-reset: pca9535 3
+
+	where the chip is its device name, path, or label.`,
+	}
+}
+
 func (c Command) Main(args ...string) error {
+	if len(args) > 0 {
+		if f, found := subcommands[args[0]]; found {
+			return f(c, args[1:]...)
+		}
+	}
+	if len(args) == 1 || len(args) == 2 {
+		pins, err := c.pins(machine.Default())
+		if err != nil {
+			return err
+		}
+		if _, found := pins[args[0]]; found {
+			if len(args) == 1 {
+				return c.get(args...)
+			}
+			return c.set(args...)
+		}
+	}
 	switch len(args) {
 	case 0: // No args?  Report all pin values.
 		names := make([]string, 0, gpio.NumPins())
@@ -0,0 +1,213 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpio
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes/external/gpiod"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/parms"
+)
+
+// subcommands of named, gpiod character device pins
+var subcommands = map[string]func(c Command, args ...string) error{
+	"list":      Command.list,
+	"get":       Command.get,
+	"set":       Command.set,
+	"direction": Command.direction,
+	"poll":      Command.poll,
+}
+
+// pins returns the machine's Pins with those of gpio.pins in the machine
+// configuration file, e.g.
+//
+//	gpio:
+//	  pins:
+//	    sys-reset: gpiochip0 12 active-low
+//	    qsfp-18-reset: pca9535 3
+func (c Command) pins(cfg *machine.Config) (map[string]gpiod.Pin, error) {
+	pins := make(map[string]gpiod.Pin)
+	for name, pin := range c.Pins {
+		pins[name] = pin
+	}
+	for _, name := range cfg.Keys("gpio.pins") {
+		s := cfg.String("gpio.pins."+name, "")
+		pin, err := gpiod.ParsePin(s)
+		if err != nil {
+			return nil, fmt.Errorf("gpio.pins.%s: %v", name, err)
+		}
+		pins[name] = pin
+	}
+	return pins, nil
+}
+
+// pin returns the named pin or, with a CHIP:LINE name, that chip line.
+func (c Command) pin(name string) (gpiod.Pin, error) {
+	pins, err := c.pins(machine.Default())
+	if err != nil {
+		return gpiod.Pin{}, err
+	}
+	if pin, found := pins[name]; found {
+		return pin, nil
+	}
+	if colon := strings.Index(name, ":"); colon > 0 {
+		return gpiod.ParsePin(name[:colon] + " " + name[colon+1:])
+	}
+	return gpiod.Pin{}, fmt.Errorf("%s: not found", name)
+}
+
+func (c Command) list(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	pins, err := c.pins(machine.Default())
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(pins))
+	for name := range pins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pin := pins[name]
+		info, err := pin.Info()
+		if err != nil {
+			fmt.Printf("%s: %s: %v\n", name, pin, err)
+			continue
+		}
+		dir := "in"
+		if info.Out {
+			dir = "out"
+		}
+		v, err := pin.Value()
+		if err != nil {
+			fmt.Printf("%s: %s %s: %v\n", name, pin, dir, err)
+			continue
+		}
+		fmt.Printf("%s: %s %s %v\n", name, pin, dir, v)
+	}
+	return nil
+}
+
+func (c Command) get(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("PIN: missing")
+	} else if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	pin, err := c.pin(args[0])
+	if err != nil {
+		return err
+	}
+	v, err := pin.Value()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %v\n", args[0], v)
+	return nil
+}
+
+func (c Command) set(args ...string) error {
+	switch len(args) {
+	case 0:
+		return fmt.Errorf("PIN: missing")
+	case 1:
+		return fmt.Errorf("VALUE: missing")
+	case 2:
+	default:
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	pin, err := c.pin(args[0])
+	if err != nil {
+		return err
+	}
+	v, err := value(args[1])
+	if err != nil {
+		return err
+	}
+	return pin.SetValue(v)
+}
+
+func (c Command) direction(args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("PIN in|out: missing")
+	}
+	pin, err := c.pin(args[0])
+	if err != nil {
+		return err
+	}
+	switch args[1] {
+	case "in":
+		if len(args) > 2 {
+			return fmt.Errorf("%v: unexpected", args[2:])
+		}
+		return pin.SetInput()
+	case "out":
+		v := false
+		if len(args) > 3 {
+			return fmt.Errorf("%v: unexpected", args[3:])
+		} else if len(args) == 3 {
+			if v, err = value(args[2]); err != nil {
+				return err
+			}
+		}
+		return pin.SetValue(v)
+	}
+	return fmt.Errorf("%s: invalid, must be in|out", args[1])
+}
+
+func (c Command) poll(args ...string) error {
+	parm, args := parms.New(args, "-t")
+	if len(args) == 0 {
+		return fmt.Errorf("PIN: missing")
+	} else if len(args) > 2 {
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	edge := gpiod.Both
+	if len(args) == 2 {
+		switch args[1] {
+		case "rising":
+			edge = gpiod.Rising
+		case "falling":
+			edge = gpiod.Falling
+		case "both":
+		default:
+			return fmt.Errorf("%s: invalid, must be rising|falling|both",
+				args[1])
+		}
+	}
+	var timeout time.Duration
+	if s := parm.ByName["-t"]; len(s) > 0 {
+		var err error
+		if timeout, err = time.ParseDuration(s); err != nil {
+			return err
+		}
+	}
+	pin, err := c.pin(args[0])
+	if err != nil {
+		return err
+	}
+	got, t, err := pin.Poll(edge, timeout)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: %s %s\n", args[0], got, t.Format(time.RFC3339Nano))
+	return nil
+}
+
+func value(s string) (bool, error) {
+	switch s {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("%s: invalid, must be true|false", s)
+}
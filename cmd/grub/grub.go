@@ -8,7 +8,6 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -38,15 +37,14 @@ import (
 	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/lang"
 
-	"github.com/platinasystems/url"
+	"github.com/platinasystems/goes/internal/url"
 
 	"github.com/platinasystems/liner"
 )
 
 type Command struct {
-	g       *goes.Goes
-	root    string
-	scanner *bufio.Scanner
+	g    *goes.Goes
+	root string
 }
 
 var ErrNoDefinedKernelOrMenus = errors.New("No defined kernel or menus")
@@ -102,27 +100,19 @@ func (c *Command) Apropos() lang.Alt {
 
 func (c *Command) Goes(g *goes.Goes) { c.g = g }
 
-func (c *Command) Write(p []byte) (n int, err error) {
-	return len(p), nil
+// scriptTerminal reads the grub configuration and traces each line
+// with debug verbosity.
+type scriptTerminal struct {
+	*goes.PipeTerminal
+	g *goes.Goes
 }
 
-func (c *Command) Read(p []byte) (n int, err error) {
-	if c.scanner.Scan() {
-		t := c.scanner.Text()
-		if c.g.Verbosity >= goes.VerboseDebug {
-			fmt.Println("+", t)
-		}
-		n = copy(p, []byte(t))
-		if len(t) > len(p) {
-			err = errors.New("input too long")
-		}
-		return
+func (s scriptTerminal) ReadLine(prompt string) (string, error) {
+	t, err := s.PipeTerminal.ReadLine(prompt)
+	if err == nil && s.g.Verbosity >= goes.VerboseDebug {
+		fmt.Println("+", t)
 	}
-	err = c.scanner.Err()
-	if err == nil {
-		err = io.EOF
-	}
-	return 0, err
+	return t, err
 }
 
 func (c *Command) runScript(n string) (err error) {
@@ -134,9 +124,10 @@ func (c *Command) runScript(n string) (err error) {
 		}
 		defer script.Close()
 
-		c.scanner = bufio.NewScanner(script)
-
-		Goes.Catline = c
+		Goes.Terminal = scriptTerminal{
+			goes.NewPipeTerminal(script, nil).Named(fn),
+			c.g,
+		}
 
 	}
 	err = Goes.Main()
@@ -169,7 +160,7 @@ func (c *Command) Main(args ...string) (err error) {
 	}
 
 	if c.g.Verbosity >= goes.VerboseDebug {
-		root := Goes.EnvMap["root"]
+		root := Goes.Getenv("root")
 		fmt.Printf("Root is %s translated %s\n", root, c.GetRoot())
 	}
 
@@ -191,7 +182,7 @@ func (c *Command) Main(args ...string) (err error) {
 	}
 
 	if c.g.Verbosity >= goes.VerboseDebug {
-		root := Goes.EnvMap["root"]
+		root := Goes.Getenv("root")
 		fmt.Printf("Root is %s translated %s\n", root, c.GetRoot())
 	}
 
@@ -213,7 +204,7 @@ func (c *Command) RunMenu(m *menu.Menu, parm *parms.Parms, flag *flags.Flags) (e
 		fmt.Print(m.NumberedMenu())
 		var menuItem int
 		err = func() error {
-			def := Goes.EnvMap["default"]
+			def := Goes.Getenv("default")
 			if def == "" {
 				def = "0"
 			}
@@ -265,7 +256,7 @@ func (c *Command) AskKernel(parm *parms.Parms, flag *flags.Flags) (err error) {
 }
 
 func (c *Command) GetRoot() string {
-	root := Goes.EnvMap["root"]
+	root := Goes.Getenv("root")
 	if root == "" {
 		return c.root
 	}
@@ -340,7 +331,7 @@ func (c *Command) KexecCommand() []string {
 
 func (c *Command) readline(parm *parms.Parms, flag *flags.Flags, prompt string, def string) (mi string, err error) {
 	var timeout time.Duration
-	tmEnv := Goes.EnvMap["timeout"]
+	tmEnv := Goes.Getenv("timeout")
 	if tmEnv != "" {
 		tm, err := strconv.Atoi(tmEnv)
 		if err == nil {
@@ -348,11 +339,9 @@ func (c *Command) readline(parm *parms.Parms, flag *flags.Flags, prompt string,
 		}
 	}
 	if timeout == 0 {
-		if parm.ByName["-t"] != "" {
-			timeout, err = time.ParseDuration(parm.ByName["-t"])
-			if err != nil {
-				return "", err
-			}
+		timeout, err = parm.Duration("-t", 0)
+		if err != nil {
+			return "", err
 		}
 	}
 
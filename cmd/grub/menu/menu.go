@@ -171,8 +171,7 @@ func (c *Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List,
 	var funList []func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error
 	for {
 		for len(ls.Cmds) == 0 {
-			newls, err := shellutils.Parse(c.String()+">",
-				g.Catline)
+			newls, err := shellutils.Parse(c.String()+">", g)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -23,26 +23,23 @@ type ts struct {
 	script []string
 }
 
-func (t *ts) Write(p []byte) (n int, err error) {
-	//fmt.Printf("Test write %s\n", string(p))
-	return n, nil
-}
-
-func (t *ts) Read(p []byte) (n int, err error) {
+func (t *ts) ReadLine(prompt string) (string, error) {
 	if t.line >= len(t.script) {
 		//fmt.Printf("Test read returned EOF\n")
-		return 0, io.EOF
+		return "", io.EOF
 	}
 	//fmt.Printf("Test read returning: %s\n", t.script[t.line])
 	s := t.script[t.line]
 	t.line += 1
-	n = copy(p, []byte(s))
-	if len(s) > len(p) {
-		err = errors.New("input too long")
-	}
-	return
+	return s, nil
 }
 
+func (t *ts) AddHistory(string) {}
+
+func (t *ts) SetCompleter(func(string) []string) {}
+
+func (t *ts) Raw() (io.Reader, io.Writer) { return nil, nil }
+
 func (t *ts) Apropos() lang.Alt {
 	return lang.Alt{
 		lang.EnUS: "script testpoint",
@@ -73,7 +70,7 @@ func newgoes(t *ts) (m, s *Command, g *goes.Goes) {
 			"submenu":   s,
 			"testpoint": t,
 		},
-		Catline: t,
+		Terminal: t,
 	}
 }
 
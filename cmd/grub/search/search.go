@@ -77,10 +77,7 @@ func (c Command) Main(args ...string) error {
 		if err == nil {
 			u, _ := sb.UUID()
 			if u.String() == args[0] {
-				if c.g.EnvMap == nil {
-					c.g.EnvMap = make(map[string]string)
-				}
-				c.g.EnvMap[v] = "/dev/" + fileName
+				c.g.Context().Setenv(v, "/dev/"+fileName)
 				return nil
 			}
 		}
@@ -46,9 +46,6 @@ func (c Command) Main(args ...string) error {
 	if len(s) != 2 {
 		return fmt.Errorf("unexpected %v\n", args)
 	}
-	if c.g.EnvMap == nil {
-		c.g.EnvMap = make(map[string]string)
-	}
-	c.g.EnvMap[s[0]] = s[1]
+	c.g.Context().Setenv(s[0], s[1])
 	return nil
 }
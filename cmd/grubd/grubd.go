@@ -5,7 +5,6 @@
 package grubd
 
 import (
-	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -14,9 +13,12 @@ import (
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/log"
 	"github.com/platinasystems/goes/lang"
 )
 
+var logger = log.New("grubd")
+
 type Command struct {
 	g      *goes.Goes
 	mounts []*bootMnt
@@ -61,7 +63,7 @@ func (c *Command) Main(args ...string) (err error) {
 	for {
 		dirs, err := ioutil.ReadDir(mp)
 		if err != nil {
-			fmt.Printf("Error reading %s dir: %s", mp, err)
+			logger.Err("read", "dir", mp, "err", err)
 		}
 		cnt := 0
 		c.mounts = c.mounts[:0]
@@ -94,7 +96,7 @@ func (c *Command) Main(args ...string) (err error) {
 				args := []string{"grub", "--daemon"}
 				args = append(args, m.mnt,
 					filepath.Join(m.dir, "grub/grub.cfg"))
-				fmt.Printf("%v\n", args)
+				logger.Info("grub", "args", args)
 				x := c.g.Fork(args...)
 				x.Stdin = os.Stdin
 				x.Stdout = os.Stdout
@@ -118,7 +120,7 @@ func (c *Command) Main(args ...string) (err error) {
 				}()
 				err := x.Run()
 				if err != nil {
-					fmt.Printf("grub returned %s\n", err)
+					logger.Err("grub", "args", args, "err", err)
 				}
 				close(done)
 			}
@@ -147,7 +149,7 @@ func (*Command) tryScanFiles(m *bootMnt, done chan *bootMnt) {
 				m.dir, "grub/grub.cfg")); err == nil {
 				m.hasGrub = true
 			} else {
-				fmt.Printf("os.stat err %s\n", err)
+				logger.Debug("stat", "err", err)
 			}
 			continue
 		}
@@ -0,0 +1,182 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package healthd provides a daemon that serves the liveness and readiness of
+// the system, aggregated from redisd responsiveness, the supervised daemon
+// states and probes, and the sensor alarms, for load balancers and fleet
+// pollers.
+package healthd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/lang"
+)
+
+// DefaultListen is the address of the HTTP server without a configured
+// healthd.listen.
+const DefaultListen = ":9102"
+
+// DefaultTimeout is that of redisd responses.
+const DefaultTimeout = time.Second
+
+type Command struct {
+	Listen  string
+	Timeout time.Duration
+	// Ignore these daemons and sensors
+	Ignore []string
+}
+
+func (*Command) String() string { return "healthd" }
+
+func (*Command) Usage() string { return "healthd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "health check daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Serve the system health as a JSON document at,
+		http://ADDRESS/healthz
+		http://ADDRESS/readyz
+	with a status of pass, warn, or fail and that of each check,
+		redisd		responds within the timeout
+		daemons		the goes.daemon.NAME state, ready, and live
+		sensors		the sensor.NAME.alarm
+	The response code is 503 if failed, otherwise 200.
+
+	/healthz fails if redisd doesn't respond, a daemon has failed or
+	failed its liveness probe, or a sensor alarm is critical; other
+	alarms and daemons that aren't running are warnings.
+
+	/readyz also fails with any daemon that isn't running and ready,
+	e.g. while starting or in restart backoff.
+
+FILES
+	/etc/goes/machine.yaml
+		healthd:
+		  listen: ":9102"
+		  timeout: 1s
+		  ignore: [femtocom, sensor-fan3]
+
+EXAMPLES
+	curl -s http://localhost:9102/readyz
+
+SEE ALSO
+	daemons, sensorsd, promd`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err := c.configure(machine.Default()); err != nil {
+		return err
+	}
+	ln, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter,
+		r *http.Request) {
+		c.serve(w, r, false)
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter,
+		r *http.Request) {
+		c.serve(w, r, true)
+	})
+	srv := &http.Server{Handler: mux}
+	defer srv.Close()
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	select {
+	case <-goes.Stop:
+		return nil
+	case err = <-done:
+		return err
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) error {
+	c.Listen = cfg.String("healthd.listen", c.Listen)
+	if len(c.Listen) == 0 {
+		c.Listen = DefaultListen
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	var err error
+	c.Timeout, err = cfg.Duration("healthd.timeout", c.Timeout)
+	if err != nil {
+		return err
+	}
+	c.Ignore = cfg.Strings("healthd.ignore", c.Ignore)
+	return nil
+}
+
+func (c *Command) serve(w http.ResponseWriter, r *http.Request,
+	readiness bool) {
+	fields, latency, err := c.fields()
+	report := Evaluate(readiness, fields, latency, err, c.Ignore)
+	b, err := json.MarshalIndent(report, "", "\t")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(report.Code())
+	if r.Method != "HEAD" {
+		w.Write(append(b, '\n'))
+	}
+}
+
+// fields returns the published daemon and sensor fields and the latency of
+// redisd, or an error if it doesn't respond within the timeout.
+func (c *Command) fields() (map[string]string, time.Duration, error) {
+	type result struct {
+		fields  map[string]string
+		latency time.Duration
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var x result
+		start := time.Now()
+		if _, x.err = redis.Hget(redis.DefaultHash,
+			"redis.ready"); x.err != nil {
+			done <- x
+			return
+		}
+		x.latency = time.Since(start)
+		x.fields, x.err = redis.Hgetall(redis.DefaultHash, "")
+		done <- x
+	}()
+	t := time.NewTimer(c.Timeout)
+	defer t.Stop()
+	select {
+	case x := <-done:
+		return x.fields, x.latency, x.err
+	case <-t.C:
+		return nil, 0, errors.New("redisd: timeout")
+	}
+}
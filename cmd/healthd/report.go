@@ -0,0 +1,172 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package healthd
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status of a check or report, ordered by severity.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+func (s Status) worse(t Status) Status {
+	rank := map[Status]int{Pass: 0, Warn: 1, Fail: 2}
+	if rank[t] > rank[s] {
+		return t
+	}
+	return s
+}
+
+// Report is the JSON document of /healthz and /readyz.
+type Report struct {
+	Status  Status             `json:"status"`
+	Time    time.Time          `json:"time"`
+	Redisd  Redisd             `json:"redisd"`
+	Daemons map[string]*Daemon `json:"daemons,omitempty"`
+	Sensors map[string]*Sensor `json:"sensors,omitempty"`
+}
+
+type Redisd struct {
+	Status  Status `json:"status"`
+	Latency string `json:"latency,omitempty"`
+	Output  string `json:"output,omitempty"`
+}
+
+// Daemon of the published goes.daemon.NAME fields of the supervisor.
+type Daemon struct {
+	Status   Status `json:"status"`
+	State    string `json:"state"`
+	Ready    *bool  `json:"ready,omitempty"`
+	Live     *bool  `json:"live,omitempty"`
+	Restarts int    `json:"restarts,omitempty"`
+}
+
+// Sensor of the published sensor.NAME.alarm fields of sensorsd.
+type Sensor struct {
+	Status Status `json:"status"`
+	Alarm  string `json:"alarm"`
+	Value  string `json:"value,omitempty"`
+}
+
+const (
+	daemonPrefix = "goes.daemon."
+	sensorPrefix = "sensor."
+)
+
+// Evaluate the published fields and latency of redisd, or its error, as the
+// report of readiness or, if !readiness, liveness. Liveness fails with a
+// failed daemon, one failing its liveness probe, or a critical sensor;
+// readiness also fails with any daemon not yet running and ready. Other
+// sensor alarms are warnings.
+func Evaluate(readiness bool, fields map[string]string, latency time.Duration,
+	err error, ignore []string) *Report {
+	r := &Report{
+		Status: Pass,
+		Time:   time.Now(),
+		Redisd: Redisd{Status: Pass},
+	}
+	if err != nil {
+		r.Redisd.Status = Fail
+		r.Redisd.Output = err.Error()
+		r.Status = Fail
+		return r
+	}
+	r.Redisd.Latency = latency.String()
+	ignored := make(map[string]bool)
+	for _, name := range ignore {
+		ignored[name] = true
+	}
+	for k, v := range fields {
+		switch {
+		case strings.HasPrefix(k, daemonPrefix):
+			s := k[len(daemonPrefix):]
+			i := strings.LastIndex(s, ".")
+			if i <= 0 || ignored[s[:i]] {
+				continue
+			}
+			d := r.daemon(s[:i])
+			b := v == "true"
+			switch s[i+1:] {
+			case "state":
+				d.State = v
+			case "ready":
+				d.Ready = &b
+			case "live":
+				d.Live = &b
+			case "restarts":
+				d.Restarts, _ = strconv.Atoi(v)
+			}
+		case strings.HasPrefix(k, sensorPrefix) &&
+			strings.HasSuffix(k, ".alarm"):
+			name := strings.TrimSuffix(k[len(sensorPrefix):],
+				".alarm")
+			if len(name) == 0 || ignored[name] {
+				continue
+			}
+			s := &Sensor{Status: Pass, Alarm: v,
+				Value: fields[sensorPrefix+name]}
+			switch v {
+			case "ok", "":
+			case "critical":
+				s.Status = Fail
+			default:
+				s.Status = Warn
+			}
+			if r.Sensors == nil {
+				r.Sensors = make(map[string]*Sensor)
+			}
+			r.Sensors[name] = s
+			r.Status = r.Status.worse(s.Status)
+		}
+	}
+	for _, d := range r.Daemons {
+		d.Status = d.evaluate(readiness)
+		r.Status = r.Status.worse(d.Status)
+	}
+	return r
+}
+
+func (r *Report) daemon(name string) *Daemon {
+	if r.Daemons == nil {
+		r.Daemons = make(map[string]*Daemon)
+	}
+	d, found := r.Daemons[name]
+	if !found {
+		d = new(Daemon)
+		r.Daemons[name] = d
+	}
+	return d
+}
+
+func (d *Daemon) evaluate(readiness bool) Status {
+	if d.State == "failed" || (d.Live != nil && !*d.Live) {
+		return Fail
+	}
+	running := d.State == "running" && (d.Ready == nil || *d.Ready)
+	switch {
+	case running:
+		return Pass
+	case readiness:
+		return Fail
+	}
+	return Warn
+}
+
+// Code returns the HTTP status code of the report, 503 Service Unavailable
+// if failed, otherwise 200 OK.
+func (r *Report) Code() int {
+	if r.Status == Fail {
+		return 503
+	}
+	return 200
+}
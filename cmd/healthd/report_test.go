@@ -0,0 +1,63 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package healthd
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	fields := map[string]string{
+		"goes.daemon.redisd.state":    "running",
+		"goes.daemon.vnetd.state":     "running",
+		"goes.daemon.vnetd.ready":     "false",
+		"goes.daemon.vnetd.restarts":  "2",
+		"goes.daemon.lldpd.state":     "backoff",
+		"goes.daemon.femtocom.state":  "failed",
+		"sensor.cpu":                  "45.5",
+		"sensor.cpu.alarm":            "ok",
+		"sensor.board.alarm":          "high",
+		"eth-1-1.link":                "up",
+		"goes.daemon.vnetd.version":   "v1",
+		"goes.daemon.femtocom.pid":    "0",
+		"goes.daemon.redisd.restarts": "0",
+	}
+	for _, x := range []struct {
+		readiness bool
+		ignore    []string
+		status    Status
+		vnetd     Status
+	}{
+		{false, []string{"femtocom"}, Warn, Warn},
+		{true, []string{"femtocom"}, Fail, Fail},
+		{false, nil, Fail, Warn},
+	} {
+		r := Evaluate(x.readiness, fields, time.Millisecond, nil,
+			x.ignore)
+		if r.Status != x.status {
+			t.Errorf("%v %v: status %v", x.readiness, x.ignore,
+				r.Status)
+		}
+		if d := r.Daemons["vnetd"]; d == nil || d.Status != x.vnetd ||
+			d.Restarts != 2 {
+			t.Errorf("vnetd: %+v", d)
+		}
+		if s := r.Sensors["cpu"]; s == nil || s.Status != Pass ||
+			s.Value != "45.5" {
+			t.Errorf("cpu: %+v", s)
+		}
+	}
+	fields["sensor.board.alarm"] = "critical"
+	r := Evaluate(false, fields, 0, nil, []string{"femtocom"})
+	if r.Status != Fail || r.Code() != 503 {
+		t.Error("critical:", r.Status)
+	}
+	r = Evaluate(false, nil, 0, errors.New("refused"), nil)
+	if r.Status != Fail || r.Redisd.Status != Fail {
+		t.Error("redisd:", r.Status)
+	}
+}
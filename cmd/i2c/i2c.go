@@ -20,6 +20,10 @@ func (Command) String() string { return "i2c" }
 func (Command) Usage() string {
 	return `
 i2c [EEPROM][BLOCK] BUS.ADDR[.BEGIN][-END, -CNT][/8][/16] [VALUE] [WR-DELAY-SEC]
+i2c detect BUS
+i2c get BUS ADDR [REG [b|w|s|i]]
+i2c set [-y] BUS ADDR REG VALUE... [b|w|s|i]
+i2c dump BUS ADDR [b|w|i]
 `
 }
 
@@ -45,7 +49,22 @@ DESCRIPTION
             i2c 0.76/8             force reads at 8-bits
             i2c 0.76.0/8           force reads at 8-bits
 	    i2c 0.55.0-30/8        reads 0x0-0x30 8-bits at a time
-	    i2c 0.55.0-30/16       reads 0x0-0x30 16-bits at a time`,
+	    i2c 0.55.0-30/16       reads 0x0-0x30 16-bits at a time
+
+	The detect, get, set, and dump commands are like those of i2c-tools
+	but directly access /dev/i2c-BUS, rather than through i2cd, with the
+	bus locked from i2cd and other such commands. ADDR, REG, and VALUE
+	are decimal or 0x prefixed hexadecimal. The mode letters are,
+	    b   byte data (default)
+	    w   word data
+	    s   SMBus block data
+	    i   I2C block data
+
+	Examples:
+	    i2c detect 0           scans bus 0 for responding addresses
+	    i2c get 0 0x2f 0x1f    reads device 0x2f, register 0x1f
+	    i2c set 0 0x76 0 0x80  writes a 0x80 after confirmation
+	    i2c dump 0 0x51        dumps registers 0x00-0xff`,
 	}
 }
 
@@ -56,6 +75,12 @@ func (Command) Main(args ...string) error {
 		cs         [2]uint8
 	)
 
+	if len(args) > 0 {
+		if tool, found := tools[args[0]]; found {
+			return tool(args[1:]...)
+		}
+	}
+
 	if n := len(args); n == 0 {
 		return fmt.Errorf("BUS.ADDR.REG: missing")
 	} else if n > 3 {
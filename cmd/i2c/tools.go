@@ -0,0 +1,323 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2c
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/i2c"
+)
+
+// tools are the i2cdetect, i2cget, i2cset, and i2cdump equivalents that
+// directly access /dev/i2c-BUS, rather than through i2cd, with the bus
+// locked from other goes processes.
+var tools = map[string]func(args ...string) error{
+	"detect": detect,
+	"get":    get,
+	"set":    set,
+	"dump":   dump,
+}
+
+// mode returns the SMBus transaction of the i2c-tools style mode letter.
+func mode(s string) (i2c.SMBusSize, error) {
+	switch s {
+	case "b":
+		return i2c.ByteData, nil
+	case "w":
+		return i2c.WordData, nil
+	case "s":
+		return i2c.BlockData, nil
+	case "i":
+		return i2c.I2CBlockData, nil
+	}
+	return 0, fmt.Errorf("%s: invalid mode, expected b, w, s, or i", s)
+}
+
+func parseUint(name, s string, max uint64) (uint64, error) {
+	u, err := strconv.ParseUint(s, 0, 64)
+	if err != nil || u > max {
+		return 0, fmt.Errorf("%s: invalid %s", s, name)
+	}
+	return u, nil
+}
+
+// open and lock the BUS with the ADDR slave selected
+func open(b, a string) (*i2c.Bus, error) {
+	index, err := parseUint("BUS", b, 255)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := parseUint("ADDR", a, 0x77)
+	if err != nil {
+		return nil, err
+	}
+	bus := new(i2c.Bus)
+	if err = bus.Open(int(index)); err != nil {
+		return nil, err
+	}
+	if err = bus.Lock(); err == nil {
+		err = bus.ForceSlaveAddress(int(addr))
+	}
+	if err != nil {
+		bus.Close()
+		return nil, err
+	}
+	return bus, nil
+}
+
+func detect(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("BUS: missing")
+	} else if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	index, err := parseUint("BUS", args[0], 255)
+	if err != nil {
+		return err
+	}
+	bus := new(i2c.Bus)
+	if err = bus.Open(int(index)); err != nil {
+		return err
+	}
+	defer bus.Close()
+	if err = bus.Lock(); err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("    ")
+	for i := 0; i < 16; i++ {
+		fmt.Fprintf(buf, " %2x", i)
+	}
+	for addr := 0; addr < 0x78; addr++ {
+		if addr%16 == 0 {
+			fmt.Fprintf(buf, "\n%02x: ", addr)
+		}
+		if addr < 0x03 {
+			buf.WriteString("   ")
+			continue
+		}
+		err = bus.SetSlaveAddress(addr)
+		if err != nil {
+			if errors.Is(err, syscall.EBUSY) {
+				buf.WriteString("UU ")
+			} else {
+				buf.WriteString("-- ")
+			}
+			continue
+		}
+		// like i2cdetect, read rather than quick write eeproms and
+		// those that may be confused by a quick write
+		if addr >= 0x30 && addr <= 0x37 || addr >= 0x50 && addr <= 0x5f {
+			err = bus.ReadWrite(i2c.Read, 0, i2c.Byte, nil)
+		} else {
+			err = bus.ReadWrite(i2c.Write, 0, i2c.Quick, nil)
+		}
+		if err != nil {
+			buf.WriteString("-- ")
+		} else {
+			fmt.Fprintf(buf, "%02x ", addr)
+		}
+	}
+	buf.WriteString("\n")
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+func get(args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("BUS ADDR: missing")
+	} else if len(args) > 4 {
+		return fmt.Errorf("%v: unexpected", args[4:])
+	}
+	bus, err := open(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	var data i2c.SMBusData
+	if len(args) == 2 {
+		if err = bus.Read(0, i2c.Byte, &data); err != nil {
+			return err
+		}
+		fmt.Printf("%#02x\n", data[0])
+		return nil
+	}
+	reg, err := parseUint("REG", args[2], 0xff)
+	if err != nil {
+		return err
+	}
+	op := i2c.ByteData
+	if len(args) > 3 {
+		if op, err = mode(args[3]); err != nil {
+			return err
+		}
+	}
+	if op == i2c.I2CBlockData {
+		data[0] = i2c.SMBusMax
+	}
+	if err = bus.Read(uint8(reg), op, &data); err != nil {
+		return err
+	}
+	switch op {
+	case i2c.ByteData:
+		fmt.Printf("%#02x\n", data[0])
+	case i2c.WordData:
+		fmt.Printf("%#04x\n", uint16(data[1])<<8|uint16(data[0]))
+	default:
+		n := int(data[0])
+		if n > i2c.SMBusMax {
+			n = i2c.SMBusMax
+		}
+		for i := 1; i <= n; i++ {
+			fmt.Printf("%#02x ", data[i])
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+func set(args ...string) error {
+	flag, args := flags.New(args, "-y")
+	if len(args) < 4 {
+		return fmt.Errorf("BUS ADDR REG VALUE: missing")
+	}
+	reg, err := parseUint("REG", args[2], 0xff)
+	if err != nil {
+		return err
+	}
+	op := i2c.ByteData
+	values := args[3:]
+	if n := len(values); n > 1 {
+		if m, err := mode(values[n-1]); err == nil {
+			op, values = m, values[:n-1]
+		}
+	}
+	var data i2c.SMBusData
+	switch op {
+	case i2c.ByteData, i2c.WordData:
+		if len(values) > 1 {
+			return fmt.Errorf("%v: unexpected", values[1:])
+		}
+		max := uint64(0xff)
+		if op == i2c.WordData {
+			max = 0xffff
+		}
+		v, err := parseUint("VALUE", values[0], max)
+		if err != nil {
+			return err
+		}
+		data[0], data[1] = uint8(v), uint8(v>>8)
+	default:
+		if len(values) > i2c.SMBusMax {
+			return fmt.Errorf("%v: unexpected", values[i2c.SMBusMax:])
+		}
+		data[0] = uint8(len(values))
+		for i, s := range values {
+			v, err := parseUint("VALUE", s, 0xff)
+			if err != nil {
+				return err
+			}
+			data[1+i] = uint8(v)
+		}
+	}
+	if !flag.ByName["-y"] {
+		fmt.Printf("Write %v to bus %s, address %s, register %#02x? [y/N] ",
+			values, args[0], args[1], reg)
+		var s string
+		fmt.Scanln(&s)
+		if s != "y" && s != "Y" && s != "yes" {
+			return fmt.Errorf("canceled")
+		}
+	}
+	bus, err := open(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	return bus.Write(uint8(reg), op, &data)
+}
+
+func dump(args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("BUS ADDR: missing")
+	} else if len(args) > 3 {
+		return fmt.Errorf("%v: unexpected", args[3:])
+	}
+	op := i2c.ByteData
+	if len(args) > 2 {
+		var err error
+		if op, err = mode(args[2]); err != nil {
+			return err
+		}
+		if op == i2c.BlockData {
+			return fmt.Errorf("s: invalid dump mode")
+		}
+	}
+	bus, err := open(args[0], args[1])
+	if err != nil {
+		return err
+	}
+	defer bus.Close()
+	var regs [256]byte
+	valid := make([]bool, len(regs))
+	for reg := 0; reg < len(regs); {
+		var data i2c.SMBusData
+		switch op {
+		case i2c.WordData:
+			if bus.Read(uint8(reg), op, &data) == nil {
+				regs[reg], regs[reg+1] = data[0], data[1]
+				valid[reg], valid[reg+1] = true, true
+			}
+			reg += 2
+		case i2c.I2CBlockData:
+			data[0] = i2c.SMBusMax
+			if bus.Read(uint8(reg), op, &data) == nil {
+				copy(regs[reg:reg+i2c.SMBusMax], data[1:])
+				for i := reg; i < reg+i2c.SMBusMax; i++ {
+					valid[i] = true
+				}
+			}
+			reg += i2c.SMBusMax
+		default:
+			if bus.Read(uint8(reg), op, &data) == nil {
+				regs[reg], valid[reg] = data[0], true
+			}
+			reg++
+		}
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("    ")
+	for i := 0; i < 16; i++ {
+		fmt.Fprintf(buf, " %2x", i)
+	}
+	buf.WriteString("    0123456789abcdef\n")
+	for row := 0; row < len(regs); row += 16 {
+		fmt.Fprintf(buf, "%02x: ", row)
+		for i := row; i < row+16; i++ {
+			if valid[i] {
+				fmt.Fprintf(buf, "%02x ", regs[i])
+			} else {
+				buf.WriteString("XX ")
+			}
+		}
+		buf.WriteString("   ")
+		for i := row; i < row+16; i++ {
+			if c := regs[i]; valid[i] && c > 0x1f && c < 0x7f {
+				buf.WriteByte(c)
+			} else {
+				buf.WriteByte('.')
+			}
+		}
+		buf.WriteString("\n")
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
@@ -110,6 +110,11 @@ func (t *I2cReq) ReadWrite(g *[MAXOPS]I, f *[MAXOPS]R) error {
 			}
 			defer bus.Close()
 
+			if err = bus.Lock(); err != nil {
+				log.Print("Error locking I2C bus")
+				return err
+			}
+
 			err = bus.ForceSlaveAddress(g[x].Addr)
 			if err != nil {
 				log.Print("ERR2")
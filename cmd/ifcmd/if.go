@@ -54,7 +54,7 @@ func (c Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func
 		*curList = append(*curList, runfun)
 		ls = *nextls
 		for len(ls.Cmds) == 0 {
-			newls, err := shellutils.Parse("if>", g.Catline)
+			newls, err := shellutils.Parse("if>", g)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -95,7 +95,7 @@ func (c Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func
 				return nil, nil, err
 			}
 			runfun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
-				g.Status = nil
+				g.SetStatus(nil)
 				return elifFun(stdin, stdout, stderr)
 			}
 			elseList = append(elseList, runfun)
@@ -131,7 +131,7 @@ func runList(pipeline []func(stdin io.Reader, stdout io.Writer, stderr io.Writer
 func makeBlockFunc(g *goes.Goes, ifList, thenList, elseList []func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error) (func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error, error) {
 	runfun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		err := runList(ifList, stdin, stdout, stderr)
-		if err == nil && g.Status == nil {
+		if err == nil && g.Status() == nil {
 			err = runList(thenList, stdin, stdout, stderr)
 		} else {
 			err = runList(elseList, stdin, stdout, stderr)
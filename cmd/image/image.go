@@ -0,0 +1,28 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package image provides commands to manage the pinned keys of image signers
+// and verify images before these are flashed.
+package image
+
+import (
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/image/keys"
+	"github.com/platinasystems/goes/cmd/image/verify"
+	"github.com/platinasystems/goes/lang"
+)
+
+var Goes = &goes.Goes{
+	NAME: "image",
+	USAGE: `image keys [add FILE [NAME] | remove NAME]
+image verify IMAGE...`,
+	APROPOS: lang.Alt{
+		lang.EnUS: "image signature keys and verification",
+	},
+	ByName: map[string]cmd.Cmd{
+		"keys":   keys.Command{},
+		"verify": verify.Command{},
+	},
+}
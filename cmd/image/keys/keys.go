@@ -0,0 +1,104 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package keys provides the command to list, pin, and unpin the public keys
+// of image signers.
+package keys
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/imgverify"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "keys" }
+
+func (Command) Usage() string {
+	return "image keys [add FILE [NAME] | remove NAME]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "manage the pinned keys of image signers",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Without arguments, list the pinned public keys of image signers.
+
+	The "add" form pins a minisign (.pub) or armored OpenPGP (.asc)
+	public key FILE as NAME, by default the file's base name. The
+	"remove" form unpins the named key. OpenPGP keys are those of RSA,
+	DSA, or ECDSA rather than EdDSA.
+
+FILES
+	/etc/goes/keys/NAME.pub
+	/etc/goes/keys/NAME.asc
+
+EXAMPLES
+	goes image keys add /tmp/platina-release.pub
+	goes image keys remove platina-release
+
+SEE ALSO
+	image verify`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) == 0 {
+		keys, err := imgverify.Keys()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKIND\tID")
+		for _, k := range keys {
+			fmt.Fprint(w, k.Name, "\t", k.Kind, "\t", k.ID, "\n")
+		}
+		return w.Flush()
+	}
+	switch args[0] {
+	case "add":
+		var name string
+		switch len(args) {
+		case 1:
+			return fmt.Errorf("FILE: missing")
+		case 2:
+		case 3:
+			name = args[2]
+		default:
+			return fmt.Errorf("%v: unexpected", args[3:])
+		}
+		k, err := imgverify.AddKey(name, args[1])
+		if err != nil {
+			return err
+		}
+		log.Audit.Note("pinned image key", "name", k.Name,
+			"kind", k.Kind, "id", k.ID)
+	case "remove":
+		switch len(args) {
+		case 1:
+			return fmt.Errorf("NAME: missing")
+		case 2:
+		default:
+			return fmt.Errorf("%v: unexpected", args[2:])
+		}
+		if err := imgverify.RemoveKey(args[1]); err != nil {
+			return err
+		}
+		log.Audit.Note("unpinned image key", "name", args[1])
+	default:
+		return fmt.Errorf("%s: unknown", args[0])
+	}
+	return nil
+}
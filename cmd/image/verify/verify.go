@@ -0,0 +1,62 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package verify provides the command to verify the signed sha256 manifest
+// of images.
+package verify
+
+import (
+	"fmt"
+
+	"github.com/platinasystems/goes/external/imgverify"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "verify" }
+
+func (Command) Usage() string {
+	return "image verify IMAGE..."
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "verify the signature and sha256 of images",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Verify that the sha256 of each IMAGE is that of its manifest,
+	IMAGE.sha256 or SHA256SUMS in the same directory, and that the
+	manifest has a detached signature, MANIFEST.minisig, MANIFEST.asc,
+	or MANIFEST.sig, by a pinned key. Commands that flash images do the
+	same unless run with their logged -insecure override.
+
+EXAMPLES
+	sha256sum coreboot-platina-mk1.rom >SHA256SUMS
+	minisign -S -m SHA256SUMS
+	goes image verify coreboot-platina-mk1.rom
+
+SEE ALSO
+	image keys, biosupdate`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("IMAGE: missing")
+	}
+	for _, image := range args {
+		k, err := imgverify.Verify(image)
+		if err != nil {
+			return err
+		}
+		fmt.Println(image+":", "verified by", k.Name, k.ID)
+	}
+	return nil
+}
@@ -12,7 +12,7 @@ import (
 
 	"github.com/cavaliercoder/go-cpio"
 
-	"github.com/platinasystems/url"
+	"github.com/platinasystems/goes/internal/url"
 
 	"github.com/ulikunitz/xz"
 	//	"golang.org/x/sys/unix"
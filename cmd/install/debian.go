@@ -6,12 +6,14 @@ package install
 
 var setupChroot = chroot{
 	setup: bootstrap.setup + `mkdir -p /debian
-mount /dev/{{ .InstallDev }}2 /debian
+mount {{ .RootPartition.Dev }} /debian
+mkdir -p /debian/etc/goes
+mount {{ .ConfigPartition.Dev }} /debian/etc/goes
 `,
 }
 
 var debianChroot = chroot{
-	setup: bootstrap.setup + `mount /dev/{{ .InstallDev }}2 /debian
+	setup: bootstrap.setup + `mount {{ .RootPartition.Dev }} /debian
 chroot /debian /bin/sh << EOF
 set -x
 mount -t proc none proc
@@ -20,7 +22,10 @@ mount -t devtmpfs none dev
 mount -t devpts none /dev/pts
 mount -t sysfs none sys
 mkdir -p /debian
-mount /dev/{{ .InstallDev }}2 /debian
+mount {{ .RootPartition.Dev }} /debian
+mkdir -p /boot/efi /etc/goes
+mount {{ .ESPPartition.Dev }} /boot/efi
+mount {{ .ConfigPartition.Dev }} /etc/goes
 export PATH
 `,
 	teardown: "EOF",
@@ -38,6 +43,7 @@ func (c *Command) debianInstall() (err error) {
 			"cp fstab /debian/etc/fstab",
 			"mkdir -p /debian/etc/network/interfaces.d",
 			"cp {{ .MgmtEth }} /debian/etc/network/interfaces.d",
+			"{{ if .FirstBoot }}cp machine.yaml /debian/etc/goes/machine.yaml{{ end }}",
 		},
 		},
 
@@ -48,6 +54,7 @@ func (c *Command) debianInstall() (err error) {
 			"apt-key adv --keyserver {{ .GPGServer }} --recv-keys {{ .PlatinaGPG }}",
 			"apt-get update",
 			"apt-get -y install {{ .PlatinaRelease }}",
+			"grub-install --target=x86_64-efi --efi-directory=/boot/efi --bootloader-id=debian /dev/{{ .InstallDev }}",
 			"update-grub",
 			`adduser --gecos "System Administrator" --disabled-password {{ .AdminUser }}`,
 			"adduser {{ .AdminUser }} sudo",
@@ -0,0 +1,328 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package install
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+	"github.com/satori/uuid"
+)
+
+// Partition types
+const (
+	typeESP   = "C12A7328-F81F-11D2-BA4B-00A0C93EC93B"
+	typeLinux = "0FC63DAF-8483-4772-8E79-3D69D8477DE4"
+)
+
+// Disk is a candidate install target.
+type Disk struct {
+	Name, Model string
+	// Size in bytes
+	Size      int64
+	Removable bool
+	// InUse if any of its partitions are mounted or swap.
+	InUse bool
+}
+
+func (d Disk) String() string {
+	s := fmt.Sprintf("%-10s %8s", d.Name, humanSize(d.Size))
+	if len(d.Model) > 0 {
+		s += " " + d.Model
+	}
+	if d.Removable {
+		s += " (removable)"
+	}
+	if d.InUse {
+		s += " (in use)"
+	}
+	return s
+}
+
+// Partition of the install layout
+type Partition struct {
+	Dev  string
+	Name string
+	// Size in MiB; zero is the remainder of the disk
+	Size     int64
+	Type     string
+	PartUUID uuid.UUID
+	FSUUID   uuid.UUID
+}
+
+var sysBlock = "/sys/block"
+
+// Disks returns the whole disks that may be install targets.
+func Disks() ([]Disk, error) {
+	fis, err := ioutil.ReadDir(sysBlock)
+	if err != nil {
+		return nil, err
+	}
+	used := usedDevs()
+	var disks []Disk
+	for _, fi := range fis {
+		name := fi.Name()
+		skip := false
+		for _, prefix := range []string{"loop", "ram", "zram", "dm-",
+			"sr", "md", "nbd", "mtdblock"} {
+			if strings.HasPrefix(name, prefix) {
+				skip = true
+			}
+		}
+		if skip {
+			continue
+		}
+		dn := filepath.Join(sysBlock, name)
+		sectors, err := strconv.ParseInt(readTrim(filepath.Join(dn,
+			"size")), 10, 64)
+		if err != nil || sectors == 0 {
+			continue
+		}
+		d := Disk{
+			Name:      name,
+			Model:     readTrim(filepath.Join(dn, "device", "model")),
+			Size:      sectors * 512,
+			Removable: readTrim(filepath.Join(dn, "removable")) == "1",
+		}
+		for dev := range used {
+			if dev == name || strings.HasPrefix(dev, name) &&
+				partitionOf(dev, name) {
+				d.InUse = true
+			}
+		}
+		disks = append(disks, d)
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		return disks[i].Name < disks[j].Name
+	})
+	return disks, nil
+}
+
+// partitionOf returns true if dev is a partition name of the disk, e.g.
+// sda1 of sda, nvme0n1p1 of nvme0n1.
+func partitionOf(dev, disk string) bool {
+	s := strings.TrimPrefix(dev, disk)
+	if lastIsDigit(disk) {
+		s = strings.TrimPrefix(s, "p")
+	}
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// usedDevs returns the base names of mounted and swap block devices.
+func usedDevs() map[string]bool {
+	used := make(map[string]bool)
+	for _, fn := range []string{"/proc/mounts", "/proc/swaps"} {
+		f, err := os.Open(fn)
+		if err != nil {
+			continue
+		}
+		scan := bufio.NewScanner(f)
+		for scan.Scan() {
+			fields := strings.Fields(scan.Text())
+			if len(fields) > 0 &&
+				strings.HasPrefix(fields[0], "/dev/") {
+				used[strings.TrimPrefix(fields[0], "/dev/")] = true
+			}
+		}
+		f.Close()
+	}
+	return used
+}
+
+func readTrim(fn string) string {
+	b, _ := ioutil.ReadFile(fn)
+	return strings.TrimSpace(string(b))
+}
+
+func lastIsDigit(s string) bool {
+	return len(s) > 0 && s[len(s)-1] >= '0' && s[len(s)-1] <= '9'
+}
+
+// partitionDev returns the n'th partition of the disk, e.g. sda1 or
+// nvme0n1p1.
+func partitionDev(disk string, n int) string {
+	if lastIsDigit(disk) {
+		return fmt.Sprint(disk, "p", n)
+	}
+	return fmt.Sprint(disk, n)
+}
+
+// parseSize of MiB from a number with an optional K, M, G, or T suffix,
+// e.g. 512M, 8GiB, or 1T; plain numbers are MiB.
+func parseSize(s string) (int64, error) {
+	t := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"),
+		"I")
+	mul := 1.0
+	if i := strings.IndexAny(t, "KMGT"); i > 0 && i == len(t)-1 {
+		mul = map[byte]float64{
+			'K': 1.0 / 1024,
+			'M': 1,
+			'G': 1024,
+			'T': 1024 * 1024,
+		}[t[i]]
+		t = t[:i]
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil || f <= 0 {
+		return 0, fmt.Errorf("%q: invalid size", s)
+	}
+	return int64(f * mul), nil
+}
+
+func humanSize(n int64) string {
+	for _, u := range []string{"B", "KiB", "MiB", "GiB"} {
+		if n < 1024 {
+			return fmt.Sprint(n, u)
+		}
+		n /= 1024
+	}
+	return fmt.Sprint(n, "TiB")
+}
+
+// layout returns the partitions of the disk: the ESP, root-a and root-b of
+// LayoutAB, and the persistent goes-config partition; with LayoutSingle,
+// root-a is last and takes the remainder of the disk.
+func (c *Command) layout(size int64) ([]Partition, error) {
+	esp, err := parseSize(c.ESPSize)
+	if err != nil {
+		return nil, fmt.Errorf("-esp-size: %w", err)
+	}
+	conf, err := parseSize(c.ConfigSize)
+	if err != nil {
+		return nil, fmt.Errorf("-config-size: %w", err)
+	}
+	disk := size >> 20
+	ps := []Partition{{Name: "EFI", Size: esp, Type: typeESP}}
+	config := Partition{Name: "goes-config", Size: conf, Type: typeLinux}
+	switch c.Layout {
+	case LayoutSingle:
+		ps = append(ps, config,
+			Partition{Name: "root-a", Type: typeLinux})
+	case LayoutAB:
+		var root int64
+		if c.RootSize == "" {
+			// split what remains between the roots
+			root = (disk - esp - conf - 2) / 2
+		} else if root, err = parseSize(c.RootSize); err != nil {
+			return nil, fmt.Errorf("-root-size: %w", err)
+		}
+		ps = append(ps,
+			Partition{Name: "root-a", Size: root, Type: typeLinux},
+			Partition{Name: "root-b", Size: root, Type: typeLinux},
+			config)
+	default:
+		return nil, fmt.Errorf("%s: unknown layout", c.Layout)
+	}
+	var total int64
+	for i := range ps {
+		p := &ps[i]
+		p.Dev = "/dev/" + partitionDev(c.InstallDev, i+1)
+		p.PartUUID = uuid.NewV4()
+		p.FSUUID = uuid.NewV4()
+		total += p.Size
+	}
+	// leave an MiB for the alignment and backup GPT
+	if total+1 > disk {
+		return nil, fmt.Errorf("%s: %s is too small for the %s layout",
+			c.InstallDev, humanSize(size), c.Layout)
+	}
+	return ps, nil
+}
+
+// partition returns the named partition of the layout.
+func (c *Command) partition(name string) *Partition {
+	for i := range c.Partitions {
+		if c.Partitions[i].Name == name {
+			return &c.Partitions[i]
+		}
+	}
+	return nil
+}
+
+// selectDisk validates the preseeded or given install device or, with a
+// terminal, prompts for it; "auto" is the only unused, fixed disk.
+func (c *Command) selectDisk(confirmed bool) (Disk, error) {
+	disks, err := Disks()
+	if err != nil {
+		return Disk{}, err
+	}
+	find := func(name string) (Disk, error) {
+		name = strings.TrimPrefix(name, "/dev/")
+		for _, d := range disks {
+			if d.Name == name {
+				if d.InUse {
+					return d, fmt.Errorf("%s: in use", name)
+				}
+				return d, nil
+			}
+		}
+		return Disk{}, fmt.Errorf("%s: no such disk", name)
+	}
+	tty := isatty.IsTerminal(os.Stdin.Fd())
+	switch c.InstallDev {
+	case "auto":
+		var candidates []Disk
+		for _, d := range disks {
+			if !d.InUse && !d.Removable {
+				candidates = append(candidates, d)
+			}
+		}
+		if len(candidates) != 1 {
+			return Disk{}, fmt.Errorf("auto: %d candidate disks; %s",
+				len(candidates), "use -install-dev")
+		}
+		c.InstallDev = candidates[0].Name
+	case "":
+		if !tty {
+			return Disk{}, fmt.Errorf("-install-dev or -preseed: missing")
+		}
+		fmt.Println("Disks:")
+		for _, d := range disks {
+			fmt.Println("   ", d)
+		}
+		c.InstallDev = prompt("Install on which disk", "")
+	}
+	d, err := find(c.InstallDev)
+	if err != nil {
+		return d, err
+	}
+	c.InstallDev = d.Name
+	if !confirmed {
+		if !tty {
+			return d, fmt.Errorf("%s: unconfirmed erase; use -yes",
+				d.Name)
+		}
+		c.Layout = prompt("Layout, ab or single", c.Layout)
+		if prompt(fmt.Sprint("Erase all data on ", d, "?"),
+			"no") != "yes" {
+			return d, fmt.Errorf("install canceled")
+		}
+	}
+	return d, nil
+}
+
+func prompt(question, def string) string {
+	if len(def) > 0 {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+	scan := bufio.NewScanner(os.Stdin)
+	if !scan.Scan() {
+		return def
+	}
+	if s := strings.TrimSpace(scan.Text()); len(s) > 0 {
+		return s
+	}
+	return def
+}
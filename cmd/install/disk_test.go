@@ -0,0 +1,97 @@
+// Copyright © 2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package install
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"512M":   512,
+		"512MiB": 512,
+		"8G":     8192,
+		"1.5GiB": 1536,
+		"1t":     1 << 20,
+		"2048K":  2,
+		"100":    100,
+	} {
+		if got, err := parseSize(s); err != nil || got != want {
+			t.Errorf("%s: got %d, %v want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "G", "-1G", "1X"} {
+		if _, err := parseSize(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
+
+func TestPartitionDev(t *testing.T) {
+	if s := partitionDev("sda", 2); s != "sda2" {
+		t.Error(s)
+	}
+	if s := partitionDev("nvme0n1", 2); s != "nvme0n1p2" {
+		t.Error(s)
+	}
+	if !partitionOf("nvme0n1p3", "nvme0n1") || partitionOf("sdaa", "sda") {
+		t.Error("partitionOf")
+	}
+}
+
+func TestLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "install")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	c := &Command{
+		InstallDev: "sda",
+		Layout:     LayoutAB,
+		ESPSize:    "512M",
+		ConfigSize: "1G",
+		Target:     dir,
+	}
+	const disk = 32 << 30
+	if c.Partitions, err = c.layout(disk); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range c.Partitions {
+		names = append(names, p.Dev+"="+p.Name)
+	}
+	if s := strings.Join(names, " "); s != "/dev/sda1=EFI "+
+		"/dev/sda2=root-a /dev/sda3=root-b /dev/sda4=goes-config" {
+		t.Error(s)
+	}
+	if root := c.RootPartition().Size; root != (32768-512-1024-2)/2 {
+		t.Error("root size", root)
+	}
+	if err = c.writeTemplateToFile("disk.format", format); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadFile(filepath.Join(dir, "disk.format"))
+	if !strings.Contains(string(b), "/dev/sda1 : size=512MiB, type="+
+		typeESP+", uuid="+c.ESPPartition().PartUUID.String()+
+		", name=\"EFI\"\n") {
+		t.Errorf("format:\n%s", b)
+	}
+
+	c.Layout, c.InstallDev = LayoutSingle, "nvme0n1"
+	if c.Partitions, err = c.layout(disk); err != nil {
+		t.Fatal(err)
+	}
+	if p := c.RootPartition(); p.Dev != "/dev/nvme0n1p3" || p.Size != 0 {
+		t.Errorf("single root: %+v", p)
+	}
+	c.RootSize, c.Layout = "20G", LayoutAB
+	if _, err = c.layout(disk); err == nil {
+		t.Error("too small")
+	}
+}
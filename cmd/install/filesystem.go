@@ -8,22 +8,24 @@ import (
 	"fmt"
 )
 
-var formatNoSwap = `label: gpt
+var format = `label: gpt
 device: /dev/{{ .InstallDev }}
 unit: sectors
 sector-size: 512
-
-/dev/{{ .InstallDev }}1 : size=     1024000, type=C12A7328-F81F-11D2-BA4B-00A0C93EC93B, uuid={{ .UUIDEFI }}
-/dev/{{ .InstallDev }}2 :                    type=0FC63DAF-8483-4772-8E79-3D69D8477DE4
+{{ range .Partitions }}
+{{ .Dev }} : {{ if .Size }}size={{ .Size }}MiB, {{ end }}type={{ .Type }}, uuid={{ .PartUUID }}, name="{{ .Name }}"
+{{- end }}
 `
-var fstab = `PARTUUID={{ .UUIDEFI }}	/boot/efi	vfat	umask=0077	0	1
-UUID={{ .UUIDLinux }}	/	ext4	errors=remount-ro	0	1
+
+var fstab = `PARTUUID={{ .ESPPartition.PartUUID }}	/boot/efi	vfat	umask=0077	0	1
+UUID={{ .RootPartition.FSUUID }}	/	ext4	errors=remount-ro	0	1
+UUID={{ .ConfigPartition.FSUUID }}	/etc/goes	ext4	defaults	0	2
 `
 
 func (c *Command) filesystemSetup() (err error) {
-	err = c.writeTemplateToFile("sda.format", formatNoSwap)
+	err = c.writeTemplateToFile("disk.format", format)
 	if err != nil {
-		return fmt.Errorf("filesystemSetup: Error writing sda.format: %w", err)
+		return fmt.Errorf("filesystemSetup: Error writing disk.format: %w", err)
 	}
 
 	err = c.writeTemplateToFile("fstab", fstab)
@@ -31,11 +33,16 @@ func (c *Command) filesystemSetup() (err error) {
 		return fmt.Errorf("filesystemSetup: Error writing fstab: %w", err)
 	}
 
-	err = c.doCommandsInChroot(bootstrap, []string{
-		"sfdisk /dev/{{ .InstallDev }} < sda.format",
-		"mkfs.vfat /dev/{{ .InstallDev }}1",
-		"mkfs.ext4 -U {{ .UUIDLinux }} /dev/{{ .InstallDev }}2 > /dev/null",
-	})
+	commands := []string{"sfdisk /dev/{{ .InstallDev }} < disk.format"}
+	for _, p := range c.Partitions {
+		if p.Type == typeESP {
+			commands = append(commands, "mkfs.vfat -n EFI "+p.Dev)
+		} else {
+			commands = append(commands, fmt.Sprint("mkfs.ext4 -U ",
+				p.FSUUID, " -L ", p.Name, " ", p.Dev, " > /dev/null"))
+		}
+	}
+	err = c.doCommandsInChroot(bootstrap, commands)
 	if err != nil {
 		return fmt.Errorf("Error setting up filesystems: %w", err)
 	}
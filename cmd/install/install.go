@@ -5,19 +5,30 @@
 package install
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/url"
 	"github.com/platinasystems/goes/lang"
+)
 
-	"github.com/satori/uuid"
+// Layouts of the install disk
+const (
+	// LayoutAB has A and B roots of images, e.g. the installed and next.
+	LayoutAB = "ab"
+	// LayoutSingle has one root.
+	LayoutSingle = "single"
 )
 
 type Command struct {
@@ -46,12 +57,24 @@ type Command struct {
 
 	DNSAddr string
 
+	// ESPSize, RootSize, and ConfigSize of the partitions, e.g. 512M;
+	// without RootSize, the A/B roots split the rest of the disk.
+	ESPSize    string
+	RootSize   string
+	ConfigSize string
+
+	// FirstBoot is the file or URL of the installed machine.yaml
+	FirstBoot string
+
 	GPGServer string
 
 	Hostname string
 
 	InstallDev string
 
+	Layout     string
+	Partitions []Partition
+
 	MgmtEth string
 	MgmtIP  string
 	MgmtGW  string
@@ -61,11 +84,10 @@ type Command struct {
 	PlatinaGPG      string
 	PlatinaRelease  string
 
-	Target string
+	// Preseed is the file or URL of the install options.
+	Preseed string
 
-	UUIDEFI   uuid.UUID
-	UUIDLinux uuid.UUID
-	UUIDSwap  uuid.UUID
+	Target string
 }
 
 func (c *Command) Goes(g *goes.Goes) { c.g = g }
@@ -92,7 +114,25 @@ DESCRIPTION
 	be presently installed.
 
 	In the simplest case, typing "install" with no arguments will
-	install the current Platina-verified Debian release.
+	list the disks, prompt for the target and its layout, then install
+	the current Platina-verified Debian release after confirming that
+	the disk is to be erased.
+
+	The default "ab" layout partitions the disk with an EFI system
+	partition, A and B roots, and a persistent goes-config partition
+	mounted at /etc/goes. Debian is installed on root-a with the GRUB
+	EFI boot loader; root-b is formatted for a later image. The
+	"single" layout has the ESP, goes-config, then one root with the
+	remainder of the disk.
+
+	A preseed file, or URL, installs without prompts. Its install
+	section has the options below without the leading dash, e.g.
+		install:
+		  install-dev: auto
+		  layout: ab
+		  root-size: 16G
+		  hostname: leaf-1
+		  first-boot: http://bootd/leaf-1/machine.yaml
 
 USAGE
 	install [options] [installer URL]
@@ -129,7 +169,24 @@ OPTIONS
 	-hostname name		Hostname to set. Default is ` +
 			c.DefaultHostname + `
 
-	-install-dev DEV	Device to install upon. Default is sda
+	-install-dev DEV	Device to install upon; "auto" is the only
+				unused, fixed disk. Default is to prompt
+
+	-layout LAYOUT		Disk layout, ab or single. Default is ab
+
+	-esp-size SIZE		EFI system partition size. Default is 512M
+
+	-root-size SIZE		Size of each A/B root. Default is half of
+				what remains
+
+	-config-size SIZE	goes-config partition size. Default is 1G
+
+	-first-boot FILE|URL	Install this as /etc/goes/machine.yaml
+
+	-preseed FILE|URL	Install the options of this file without
+				prompts
+
+	-yes			Erase the disk without confirmation
 
 	-mgmt-eth IF		Management ethernet. Default is enp5s0
 
@@ -179,7 +236,13 @@ func (c *Command) Main(args ...string) error {
 
 		{"-hostname", &c.Hostname, c.DefaultHostname},
 
-		{"-install-dev", &c.InstallDev, "sda"},
+		{"-install-dev", &c.InstallDev, ""},
+
+		{"-layout", &c.Layout, LayoutAB},
+		{"-esp-size", &c.ESPSize, "512M"},
+		{"-root-size", &c.RootSize, ""},
+		{"-config-size", &c.ConfigSize, "1G"},
+		{"-first-boot", &c.FirstBoot, ""},
 
 		{"-mgmt-eth", &c.MgmtEth, "enp5s0"},
 		{"-mgmt-ip", &c.MgmtIP, ""},
@@ -192,9 +255,9 @@ func (c *Command) Main(args ...string) error {
 		{"-platina-release", &c.PlatinaRelease, c.DefaultRelease},
 	}
 
-	parm, args := parms.New(args)
+	parm, args := parms.New(args, "-preseed")
 	flag, args := flags.New(args, "-shell", "-allow-unauthenticated",
-		"-debug")
+		"-debug", "-yes")
 
 	for _, x := range parmTable {
 		parm.ByName[x.parm] = ""
@@ -211,8 +274,24 @@ func (c *Command) Main(args ...string) error {
 		return fmt.Errorf("Unexpected: %v", args)
 	}
 
+	var preseed *machine.Config
+	if s := parm.ByName["-preseed"]; s != "" {
+		var err error
+		if preseed, err = loadPreseed(s); err != nil {
+			return err
+		}
+		c.Preseed = s
+		if c.Archive == c.DefaultArchive {
+			c.Archive = preseed.String("install.archive", c.Archive)
+		}
+	}
+
 	for _, x := range parmTable {
-		if val := parm.ByName[x.parm]; val != "" {
+		val := parm.ByName[x.parm]
+		if val == "" && preseed != nil {
+			val = preseed.String("install."+x.parm[1:], "")
+		}
+		if val != "" {
 			*x.strPtr = val
 		}
 		if *x.strPtr == "" {
@@ -220,9 +299,23 @@ func (c *Command) Main(args ...string) error {
 		}
 	}
 
-	c.UUIDEFI = uuid.NewV4()
-	c.UUIDLinux = uuid.NewV4()
-	c.UUIDSwap = uuid.NewV4()
+	if !flag.ByName["-shell"] {
+		disk, err := c.selectDisk(flag.ByName["-yes"] || preseed != nil)
+		if err != nil {
+			return err
+		}
+		if c.Partitions, err = c.layout(disk.Size); err != nil {
+			return err
+		}
+		fmt.Println("Partitions of", disk)
+		for _, p := range c.Partitions {
+			size := "remainder"
+			if p.Size > 0 {
+				size = humanSize(p.Size << 20)
+			}
+			fmt.Printf("    %-16s %-12s %s\n", p.Dev, p.Name, size)
+		}
+	}
 
 	mgmtDev := "eth0" // default
 	if c.MgmtGW != "" {
@@ -307,6 +400,11 @@ func (c *Command) Main(args ...string) error {
 	if c.Components != "" {
 		c.DebootstrapOptions += "--components " + c.Components + " "
 	}
+	err = c.firstBootSetup()
+	if err != nil {
+		return err
+	}
+
 	err = c.filesystemSetup()
 	if err != nil {
 		return err
@@ -319,3 +417,51 @@ func (c *Command) Main(args ...string) error {
 
 	return c.debianInstall()
 }
+
+// loadPreseed options from the file or URL.
+func loadPreseed(s string) (*machine.Config, error) {
+	r, err := url.Open(s)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening preseed %s: %w", s, err)
+	}
+	defer r.Close()
+	cfg, err := machine.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing preseed %s: %w", s, err)
+	}
+	return cfg, nil
+}
+
+// firstBootSetup copies and validates the first boot machine.yaml, if any,
+// before the disk is formatted.
+func (c *Command) firstBootSetup() error {
+	if c.FirstBoot == "" {
+		return nil
+	}
+	r, err := url.Open(c.FirstBoot)
+	if err != nil {
+		return fmt.Errorf("Error opening %s: %w", c.FirstBoot, err)
+	}
+	defer r.Close()
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("Error reading %s: %w", c.FirstBoot, err)
+	}
+	if _, err = machine.Parse(bytes.NewReader(b)); err != nil {
+		return fmt.Errorf("Error parsing %s: %w", c.FirstBoot, err)
+	}
+	err = ioutil.WriteFile(filepath.Join(c.Target, "machine.yaml"), b, 0644)
+	if err != nil {
+		return fmt.Errorf("Error writing machine.yaml: %w", err)
+	}
+	return nil
+}
+
+// ESPPartition, RootPartition, and ConfigPartition are those of the
+// install templates.
+func (c *Command) ESPPartition() *Partition  { return c.partition("EFI") }
+func (c *Command) RootPartition() *Partition { return c.partition("root-a") }
+
+func (c *Command) ConfigPartition() *Partition {
+	return c.partition("goes-config")
+}
@@ -0,0 +1,196 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package installlinks provides a command to link each of the program's
+// commands to it so that these may be run by name, like busybox applets.
+package installlinks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/internal/prog"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Dir is the default directory of the links.
+const Dir = "/usr/bin"
+
+type Command struct {
+	g *goes.Goes
+}
+
+type linker struct {
+	target          string
+	hard, force, rm bool
+	dryrun          bool
+	w               io.Writer
+}
+
+func (*Command) String() string { return "install-links" }
+
+func (*Command) Usage() string {
+	return "install-links [-n] [-f] [-hard] [-r] [DIR]"
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "link each command to this program",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Create a symbolic link in DIR, default /usr/bin, of each command
+	name to this program so that, like a busybox applet, it runs that
+	command when invoked by the name, e.g. "ls -l" rather than
+	"goes ls -l".
+
+	Existing files of other programs are skipped unless forced; those
+	already linked to this program are left as is.
+
+OPTIONS
+	-n	just print the links that would be made or removed
+	-f	replace the files of other programs
+	-hard	make hard rather than symbolic links; DIR must be on the same
+		filesystem as this program
+	-r	remove the links to this program rather than make them
+
+EXAMPLES
+	goes install-links -n
+	goes install-links /usr/local/bin
+
+SEE ALSO
+	install, ln`,
+	}
+}
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-n", "-f", "-hard", "-r")
+	dir := Dir
+	switch len(args) {
+	case 0:
+	case 1:
+		dir = args[0]
+	default:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if fi, err := os.Stat(dir); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s: isn't a directory", dir)
+	}
+	l := &linker{
+		target: prog.Name(),
+		hard:   flag.ByName["-hard"],
+		force:  flag.ByName["-f"],
+		rm:     flag.ByName["-r"],
+		dryrun: flag.ByName["-n"],
+		w:      os.Stdout,
+	}
+	return l.links(dir, names(c.g))
+}
+
+func (*Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(last) > 0 && last[0] == '-' {
+		return complete.Prefixed(last, "-n", "-f", "-hard", "-r")
+	}
+	list, _ := filepath.Glob(last + "*")
+	return list
+}
+
+// names of the commands that may be links; these exclude the default
+// command, those that aren't file names, and the program itself.
+func names(g *goes.Goes) []string {
+	var list []string
+	for _, name := range g.Names() {
+		if len(name) == 0 || name == "goes" || name == g.String() ||
+			name == "." || name == ".." ||
+			strings.ContainsRune(name, '/') {
+			continue
+		}
+		list = append(list, name)
+	}
+	return list
+}
+
+// links makes, or removes, the link of each name; it prints and skips the
+// files of other programs rather than fail the remaining names.
+func (l *linker) links(dir string, names []string) error {
+	target, err := os.Stat(l.target)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fn := filepath.Join(dir, name)
+		fi, err := os.Lstat(fn)
+		exists := err == nil
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		ours := exists && l.isOurs(fn, fi, target)
+		switch {
+		case l.rm:
+			if !ours {
+				continue
+			}
+			fmt.Fprintln(l.w, "rm", fn)
+			if !l.dryrun {
+				if err = os.Remove(fn); err != nil {
+					return err
+				}
+			}
+			continue
+		case ours:
+			continue
+		case exists && !l.force:
+			fmt.Fprintln(os.Stderr, fn+": exists, skipped")
+			continue
+		}
+		op := "ln -s"
+		if l.hard {
+			op = "ln"
+		}
+		fmt.Fprintln(l.w, op, l.target, fn)
+		if l.dryrun {
+			continue
+		}
+		if exists {
+			if err = os.Remove(fn); err != nil {
+				return err
+			}
+		}
+		if l.hard {
+			err = os.Link(l.target, fn)
+		} else {
+			err = os.Symlink(l.target, fn)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isOurs is true if the file is, or links to, the target program.
+func (l *linker) isOurs(fn string, fi, target os.FileInfo) bool {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if fi, err = os.Stat(fn); err != nil {
+			return false
+		}
+	}
+	return os.SameFile(fi, target)
+}
@@ -0,0 +1,53 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package installlinks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "installlinks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "goes")
+	other := filepath.Join(dir, "cat")
+	for _, fn := range []string{target, other} {
+		if err = ioutil.WriteFile(fn, nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l := &linker{target: target, w: ioutil.Discard}
+	if err = l.links(dir, []string{"cat", "ls"}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := os.Readlink(filepath.Join(dir, "ls")); err != nil {
+		t.Error(err)
+	} else if s != target {
+		t.Error("ls links to", s)
+	}
+	if _, err = os.Readlink(other); err == nil {
+		t.Error("replaced other program without force")
+	}
+	// again, leaving the existing link
+	if err = l.links(dir, []string{"ls"}); err != nil {
+		t.Fatal(err)
+	}
+	l.rm = true
+	if err = l.links(dir, []string{"cat", "ls"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Lstat(filepath.Join(dir, "ls")); !os.IsNotExist(err) {
+		t.Error("ls wasn't removed")
+	}
+	if _, err = os.Stat(other); err != nil {
+		t.Error("removed other program:", err)
+	}
+}
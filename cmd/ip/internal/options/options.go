@@ -5,13 +5,13 @@
 package options
 
 import (
-	"net"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/platinasystems/goes/external/flags"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/complete"
 )
 
 var (
@@ -120,20 +120,8 @@ func CompleteFile(s string) (list []string) {
 	return
 }
 
-func CompleteIfName(s string) (list []string) {
-	itfs, err := net.Interfaces()
-	if err != nil {
-		return
-	}
-	for _, itf := range itfs {
-		if len(s) == 0 || strings.HasPrefix(itf.Name, s) {
-			list = append(list, itf.Name)
-		}
-	}
-	if len(list) > 0 {
-		sort.Strings(list)
-	}
-	return
+func CompleteIfName(s string) []string {
+	return complete.IfName(s)
 }
 
 func NoComplete(string) []string { return []string{} }
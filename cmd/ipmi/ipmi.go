@@ -0,0 +1,247 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package ipmi provides a command to list the sensors, read or clear the
+// event log, and control the power of a BMC through either /dev/ipmi0 or
+// the LAN.
+package ipmi
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/platinasystems/goes/external/ipmi"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "ipmi" }
+
+func (Command) Usage() string {
+	return `ipmi [-H HOST [-U USER] [-P PASSWORD]] [-d DEVICE] COMMAND
+	mc info
+	sensor [list]
+	sel [list | info | clear]
+	power [status | on | off | cycle | reset | soft]`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "BMC sensors, event log, and power control",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Request the BMC through the system interface, /dev/ipmi0, or with
+	-H, an IPMI v2.0 RMCP+ LAN session, cipher suite 3, of an
+	administrator.
+
+	mc info
+		show the BMC's device ID and firmware revision
+
+	sensor [list]
+		list each BMC sensor's name, reading, unit, and status; the
+		status of threshold sensors is "ok" or the most severe of
+		the crossed, upper or lower, non-recoverable (unr, lnr),
+		critical (ucr, lcr), or non-critical (unc, lnc) thresholds
+
+	sel [list]
+		list the system event log
+	sel info
+		show the number of entries and free bytes of the event log
+	sel clear
+		erase the system event log
+
+	power [status]
+		show the chassis power state
+	power on | off | cycle | reset | soft
+		control the chassis power; soft requests an ACPI shutdown
+
+OPTIONS
+	-H HOST[:PORT]
+	-U USER
+	-P PASSWORD
+		default: the IPMI_PASSWORD environment variable
+	-d DEVICE
+		default: /dev/ipmi0
+
+FILES
+	/etc/goes/machine.yaml
+		ipmi:
+		  host: bmc
+		  user: admin
+		  password: secret
+
+SEE ALSO
+	ipmid`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-H", "-U", "-P", "-d")
+	if len(args) == 0 {
+		return fmt.Errorf("COMMAND: missing")
+	}
+	conn, err := Dial(machine.Default(), parm.ByName["-H"],
+		parm.ByName["-U"], parm.ByName["-P"], parm.ByName["-d"])
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	switch args[0] {
+	case "mc":
+		if len(args) != 2 || args[1] != "info" {
+			return fmt.Errorf("expected: mc info")
+		}
+		return mcInfo(conn)
+	case "sensor":
+		if len(args) > 2 || len(args) == 2 && args[1] != "list" {
+			return fmt.Errorf("%v: unexpected", args[1:])
+		}
+		return sensors(conn)
+	case "sel":
+		return sel(conn, args[1:]...)
+	case "power":
+		return power(conn, args[1:]...)
+	}
+	return fmt.Errorf("%s: unknown", args[0])
+}
+
+// Dial the BMC through the LAN if there's a host, either that given or
+// ipmi.host of the machine configuration; otherwise, open the system
+// interface device.
+func Dial(cfg *machine.Config, host, user, password, dev string) (ipmi.Conn, error) {
+	if len(host) == 0 && len(dev) == 0 {
+		host = cfg.String("ipmi.host", "")
+	}
+	if len(host) == 0 {
+		d, err := ipmi.Open(dev)
+		if err != nil {
+			return nil, err
+		}
+		return d, nil
+	}
+	if len(user) == 0 {
+		user = cfg.String("ipmi.user", "")
+	}
+	if len(password) == 0 {
+		password = os.Getenv("IPMI_PASSWORD")
+	}
+	if len(password) == 0 {
+		password = cfg.String("ipmi.password", "")
+	}
+	l, err := ipmi.Dial(host, user, password)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func mcInfo(conn ipmi.Conn) error {
+	id, err := ipmi.GetDeviceId(conn)
+	if err != nil {
+		return err
+	}
+	fmt.Println("device id:", id.Id)
+	fmt.Println("device revision:", id.Revision)
+	fmt.Println("firmware revision:", id.Firmware)
+	fmt.Println("ipmi version:", id.IpmiVersion)
+	fmt.Println("manufacturer id:", id.ManufacturerId)
+	fmt.Println("product id:", id.ProductId)
+	fmt.Println("device available:", id.DeviceAvailable)
+	return nil
+}
+
+func sensors(conn ipmi.Conn) error {
+	l, err := ipmi.Sensors(conn)
+	if err != nil && len(l) == 0 {
+		return err
+	}
+	for _, s := range l {
+		r, err := s.Read(conn)
+		if err != nil {
+			fmt.Printf("%-16s | %-10s | %-10s | na\n", s.Name, "na",
+				s.Unit)
+			continue
+		}
+		v := fmt.Sprintf("%#02x", r.Raw)
+		if s.Analog {
+			v = fmt.Sprintf("%.3f", r.Value)
+		}
+		fmt.Printf("%-16s | %-10s | %-10s | %s\n", s.Name, v, s.Unit,
+			r.Status)
+	}
+	return err
+}
+
+func sel(conn ipmi.Conn, args ...string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch args[0] {
+	case "list":
+		entries, err := ipmi.Sel(conn)
+		for _, e := range entries {
+			fmt.Printf("%4x | %s\n", e.Id, e)
+		}
+		return err
+	case "info":
+		info, err := ipmi.GetSelInfo(conn)
+		if err != nil {
+			return err
+		}
+		fmt.Println("entries:", info.Entries)
+		fmt.Println("free:", info.Free, "bytes")
+		if !info.LastAdd.IsZero() {
+			fmt.Println("last add:", info.LastAdd.UTC())
+		}
+		if !info.LastErase.IsZero() {
+			fmt.Println("last erase:", info.LastErase.UTC())
+		}
+		return nil
+	case "clear":
+		return ipmi.ClearSel(conn)
+	}
+	return fmt.Errorf("%s: unknown", args[0])
+}
+
+func power(conn ipmi.Conn, args ...string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if len(args) == 0 || args[0] == "status" {
+		on, err := ipmi.PowerIsOn(conn)
+		if err != nil {
+			return err
+		}
+		if on {
+			fmt.Println("on")
+		} else {
+			fmt.Println("off")
+		}
+		return nil
+	}
+	control, found := ipmi.PowerControls[args[0]]
+	if !found {
+		var names []string
+		for name := range ipmi.PowerControls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("%s: unknown, expected status or %s", args[0],
+			strings.Join(names, ", "))
+	}
+	return ipmi.ChassisControl(conn, control)
+}
@@ -0,0 +1,210 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package ipmid provides a daemon that mirrors the BMC's sensors and system
+// event log into redis as bmc.* fields.
+package ipmid
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	cmdipmi "github.com/platinasystems/goes/cmd/ipmi"
+	"github.com/platinasystems/goes/external/ipmi"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Interval between polls, default: 10s
+	Interval time.Duration
+
+	pub     *publisher.Publisher
+	conn    ipmi.Conn
+	sensors []ipmi.Sensor
+	status  map[string]string
+	// published SEL record IDs and the info when last read
+	sel     map[uint16]bool
+	selInfo ipmi.SelInfo
+}
+
+func (*Command) String() string { return "ipmid" }
+
+func (*Command) Usage() string { return "ipmid" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "BMC sensor and event log mirror daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Periodically read the BMC's sensors and system event log through
+	the system interface, or LAN if configured, and publish,
+		bmc.power: on|off
+		bmc.sensor.NAME: VALUE
+		bmc.sensor.NAME.unit: UNIT
+		bmc.sensor.NAME.status: ok|unc|ucr|unr|lnc|lcr|lnr|0xSTATE
+		bmc.sel.ID: "TIME TYPE #SENSOR event N asserted|deasserted ..."
+
+	ipmid logs each new event log entry and sensor status change, and
+	deletes bmc.sel.* after the log is cleared. Sensor names are lower
+	case with dashes rather than spaces or dots.
+
+FILES
+	/etc/goes/machine.yaml
+		ipmid:
+		  interval: 10s
+		ipmi:
+		  host: bmc
+		  user: admin
+		  password: secret
+
+SEE ALSO
+	ipmi`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	cfg := machine.Default()
+	if c.Interval, err = cfg.Duration("ipmid.interval", c.Interval); err != nil {
+		return err
+	}
+	if c.Interval <= 0 {
+		c.Interval = 10 * time.Second
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+	c.status = make(map[string]string)
+	c.sel = make(map[uint16]bool)
+	defer func() {
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	}()
+
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		if err = c.poll(cfg); err != nil {
+			log.Print("daemon", "err", err)
+			// reconnect, e.g. after a BMC reset or session timeout
+			if c.conn != nil {
+				c.conn.Close()
+				c.conn = nil
+			}
+		}
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Command) poll(cfg *machine.Config) error {
+	var err error
+	if c.conn == nil {
+		c.conn, err = cmdipmi.Dial(cfg, "", "", "", "")
+		if err != nil {
+			return err
+		}
+		if c.sensors, err = ipmi.Sensors(c.conn); err != nil &&
+			len(c.sensors) == 0 {
+			return err
+		}
+	}
+	if on, err := ipmi.PowerIsOn(c.conn); err == nil {
+		if on {
+			c.pub.Print("bmc.power: on")
+		} else {
+			c.pub.Print("bmc.power: off")
+		}
+	}
+	for i := range c.sensors {
+		s := &c.sensors[i]
+		name := "bmc.sensor." + sanitize(s.Name)
+		r, err := s.Read(c.conn)
+		if err != nil {
+			continue
+		}
+		if s.Analog {
+			c.pub.Printf("%s: %.3f", name, r.Value)
+		} else {
+			c.pub.Printf("%s: %#02x", name, r.Raw)
+		}
+		if status, found := c.status[name]; !found || status != r.Status {
+			if !found {
+				c.pub.Print(name, ".unit: ", s.Unit)
+			} else if s.EventType == 0x01 {
+				log.Print("daemon", "info", "bmc ", s.Name, ": ",
+					r.Status)
+			}
+			c.status[name] = r.Status
+			c.pub.Print(name, ".status: ", r.Status)
+		}
+	}
+	return c.mirrorSel()
+}
+
+// mirrorSel publishes the new entries of the system event log.
+func (c *Command) mirrorSel() error {
+	info, err := ipmi.GetSelInfo(c.conn)
+	if err != nil {
+		return err
+	}
+	if info.Entries == c.selInfo.Entries &&
+		info.LastAdd.Equal(c.selInfo.LastAdd) &&
+		info.LastErase.Equal(c.selInfo.LastErase) {
+		return nil
+	}
+	entries, err := ipmi.Sel(c.conn)
+	if err != nil {
+		return err
+	}
+	if !info.LastErase.Equal(c.selInfo.LastErase) ||
+		int(info.Entries) < len(c.sel) {
+		c.pub.Print("delete: bmc.sel.")
+		c.sel = make(map[uint16]bool)
+	}
+	initial := c.selInfo.Entries == 0 && c.selInfo.LastAdd.IsZero()
+	for _, e := range entries {
+		if c.sel[e.Id] {
+			continue
+		}
+		c.sel[e.Id] = true
+		c.pub.Printf("bmc.sel.%d: %q", e.Id, e.String())
+		if !initial {
+			log.Print("daemon", "info", "bmc sel: ", e)
+		}
+	}
+	c.selInfo = *info
+	return nil
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '.', ':':
+			return '-'
+		}
+		return r
+	}, strings.ToLower(s))
+}
@@ -0,0 +1,132 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lag
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Prefix of the redis settable fields,
+//
+//	lag.NAME.members: PORT...
+//	lag.NAME.mode: lacp|static
+//	lag.NAME.rate: slow|fast
+//
+// and those published by lagd,
+//
+//	lag.NAME.active: PORT...
+//	lag.NAME.partner: PRIORITY,SYSTEM key KEY
+//	lag.NAME.PORT: down|waiting|attached
+const Prefix = "lag."
+
+// Config of the link aggregation groups by name
+type Config map[string]*Lag
+
+// Lag configuration and status
+type Lag struct {
+	Members []string `json:"members,omitempty"`
+	// Mode lacp, the default, only attaches the members that have
+	// negotiated with the same partner; whereas static attaches all
+	// that are up.
+	Mode string `json:"mode"`
+	// Rate of the LACPDUs requested of the partner, slow, the
+	// default, is every 30s and fast is every second.
+	Rate string `json:"rate,omitempty"`
+
+	Active  []string          `json:"active,omitempty"`
+	Partner string            `json:"partner,omitempty"`
+	Ports   map[string]string `json:"ports,omitempty"`
+}
+
+// Settable returns true if the field is lag.NAME.members, mode, or rate.
+func Settable(field string) bool {
+	_, name, ok := split(field)
+	return ok && (name == "members" || name == "mode" || name == "rate")
+}
+
+// split lag.LAG.NAME
+func split(field string) (lag, name string, ok bool) {
+	if !strings.HasPrefix(field, Prefix) {
+		return
+	}
+	s := field[len(Prefix):]
+	dot := strings.Index(s, ".")
+	if dot <= 0 || dot == len(s)-1 {
+		return
+	}
+	return s[:dot], s[dot+1:], true
+}
+
+// Parse the lag.* fields.
+func Parse(fields map[string]string) (Config, error) {
+	c := make(Config)
+	for field, value := range fields {
+		name, attr, ok := split(field)
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid", field)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) == 0 {
+			continue
+		}
+		l, found := c[name]
+		if !found {
+			l = &Lag{Ports: make(map[string]string)}
+			c[name] = l
+		}
+		switch attr {
+		case "members":
+			l.Members = strings.Fields(value)
+		case "mode":
+			if value != "lacp" && value != "static" {
+				return nil, fmt.Errorf("%s: %q: invalid mode",
+					field, value)
+			}
+			l.Mode = value
+		case "rate":
+			if value != "slow" && value != "fast" {
+				return nil, fmt.Errorf("%s: %q: invalid rate",
+					field, value)
+			}
+			l.Rate = value
+		case "active":
+			l.Active = strings.Fields(value)
+		case "partner":
+			l.Partner = value
+		default:
+			l.Ports[attr] = value
+		}
+	}
+	member := make(map[string]string)
+	for _, name := range c.Names() {
+		l := c[name]
+		if len(l.Mode) == 0 {
+			l.Mode = "lacp"
+		}
+		if len(l.Rate) == 0 {
+			l.Rate = "slow"
+		}
+		for _, port := range l.Members {
+			if other, found := member[port]; found {
+				return nil, fmt.Errorf("%s: member of %s and %s",
+					port, other, name)
+			}
+			member[port] = name
+		}
+	}
+	return c, nil
+}
+
+// Names returns the sorted names of the groups.
+func (c Config) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,181 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// EtherType of the IEEE 802.3 slow protocols
+	EtherType = 0x8809
+	// dst address of the slow protocols
+	Multicast = "01:80:c2:00:00:02"
+)
+
+const (
+	subtypeLacp = 1
+	versionLacp = 1
+
+	tlvTerminator = 0
+	tlvActor      = 1
+	tlvPartner    = 2
+	tlvCollector  = 3
+
+	sizeofInfo = 18
+	// SizeofPdu of an LACPDU less the ethernet header
+	SizeofPdu = 110
+)
+
+// LACP port state bits
+const (
+	StateActivity uint8 = 1 << iota
+	StateTimeout
+	StateAggregation
+	StateSynchronization
+	StateCollecting
+	StateDistributing
+	StateDefaulted
+	StateExpired
+)
+
+var stateNames = []string{
+	"activity",
+	"timeout",
+	"aggregation",
+	"synchronization",
+	"collecting",
+	"distributing",
+	"defaulted",
+	"expired",
+}
+
+// StateString returns the comma separated names of the state bits.
+func StateString(state uint8) string {
+	var names []string
+	for i, name := range stateNames {
+		if state&(1<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// Info of an LACP actor or partner
+type Info struct {
+	SystemPriority uint16
+	System         net.HardwareAddr
+	Key            uint16
+	PortPriority   uint16
+	Port           uint16
+	State          uint8
+}
+
+// Pdu is an LACPDU without its ethernet header.
+type Pdu struct {
+	Actor, Partner    Info
+	CollectorMaxDelay uint16
+}
+
+// String of the aggregation system and key.
+func (info *Info) String() string {
+	return fmt.Sprintf("%d,%s key %d", info.SystemPriority, info.System,
+		info.Key)
+}
+
+// Aggregates returns true if the two have the same system and key.
+func (info *Info) Aggregates(other *Info) bool {
+	return info.SystemPriority == other.SystemPriority &&
+		info.System.String() == other.System.String() &&
+		info.Key == other.Key
+}
+
+// Marshal the LACPDU.
+func (pdu *Pdu) Marshal() []byte {
+	b := make([]byte, SizeofPdu)
+	b[0] = subtypeLacp
+	b[1] = versionLacp
+	i := 2
+	for _, x := range []struct {
+		t    byte
+		info *Info
+	}{
+		{tlvActor, &pdu.Actor},
+		{tlvPartner, &pdu.Partner},
+	} {
+		b[i] = x.t
+		b[i+1] = 2 + sizeofInfo
+		x.info.put(b[i+2:])
+		i += 2 + sizeofInfo
+	}
+	b[i] = tlvCollector
+	b[i+1] = 16
+	binary.BigEndian.PutUint16(b[i+2:], pdu.CollectorMaxDelay)
+	// the terminator and reserved octets are zero
+	return b
+}
+
+func (info *Info) put(b []byte) {
+	binary.BigEndian.PutUint16(b[0:], info.SystemPriority)
+	copy(b[2:8], info.System)
+	binary.BigEndian.PutUint16(b[8:], info.Key)
+	binary.BigEndian.PutUint16(b[10:], info.PortPriority)
+	binary.BigEndian.PutUint16(b[12:], info.Port)
+	b[14] = info.State
+}
+
+// Decode the slow protocol frame that follows the ethernet header; it
+// returns nil, nil if this isn't an LACPDU, e.g. marker protocol.
+func Decode(b []byte) (*Pdu, error) {
+	if len(b) < 2 || b[0] != subtypeLacp {
+		return nil, nil
+	}
+	if len(b) < SizeofPdu-50 {
+		return nil, fmt.Errorf("lacpdu: truncated")
+	}
+	pdu := new(Pdu)
+	var have int
+	for i := 2; i+2 <= len(b); {
+		t, l := b[i], int(b[i+1])
+		if t == tlvTerminator {
+			break
+		}
+		if l < 2 || i+l > len(b) {
+			return nil, fmt.Errorf("lacpdu: tlv %d: invalid length",
+				t)
+		}
+		v := b[i+2 : i+l]
+		switch t {
+		case tlvActor, tlvPartner:
+			if len(v) < sizeofInfo {
+				return nil, fmt.Errorf("lacpdu: tlv %d: truncated",
+					t)
+			}
+			info := &pdu.Actor
+			if t == tlvPartner {
+				info = &pdu.Partner
+			}
+			info.SystemPriority = binary.BigEndian.Uint16(v[0:])
+			info.System = net.HardwareAddr(append([]byte{}, v[2:8]...))
+			info.Key = binary.BigEndian.Uint16(v[8:])
+			info.PortPriority = binary.BigEndian.Uint16(v[10:])
+			info.Port = binary.BigEndian.Uint16(v[12:])
+			info.State = v[14]
+			have |= 1 << t
+		case tlvCollector:
+			if len(v) >= 2 {
+				pdu.CollectorMaxDelay = binary.BigEndian.Uint16(v)
+			}
+		}
+		i += l
+	}
+	if have != 1<<tlvActor|1<<tlvPartner {
+		return nil, fmt.Errorf("lacpdu: missing actor or partner")
+	}
+	return pdu, nil
+}
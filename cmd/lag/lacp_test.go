@@ -0,0 +1,82 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lag
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPdu(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	pdu := &Pdu{
+		Actor: Info{
+			SystemPriority: 0x8000,
+			System:         mac,
+			Key:            7,
+			PortPriority:   0x8000,
+			Port:           3,
+			State:          StateActivity | StateAggregation,
+		},
+		Partner: Info{System: make(net.HardwareAddr, 6)},
+	}
+	b := pdu.Marshal()
+	if len(b) != SizeofPdu {
+		t.Fatalf("len: %d", len(b))
+	}
+	got, err := Decode(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.Actor.Aggregates(&pdu.Actor) || got.Actor.Port != 3 ||
+		got.Actor.State != pdu.Actor.State {
+		t.Errorf("actor: %+v", got.Actor)
+	}
+	if s := StateString(got.Actor.State); s != "activity,aggregation" {
+		t.Errorf("state: %q", s)
+	}
+	// marker protocol
+	if got, err = Decode([]byte{2, 1}); got != nil || err != nil {
+		t.Errorf("marker: %v, %v", got, err)
+	}
+	if _, err = Decode(b[:40]); err == nil {
+		t.Error("expected truncated")
+	}
+}
+
+func TestParse(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"lag.bond1.members": "eth-1-1 eth-2-1",
+		"lag.bond1.rate":    "fast",
+		"lag.bond1.eth-1-1": "attached",
+		"lag.bond2.members": "eth-3-1",
+		"lag.bond2.mode":    "static",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := c["bond1"]; l.Mode != "lacp" || l.Rate != "fast" ||
+		len(l.Members) != 2 || l.Ports["eth-1-1"] != "attached" {
+		t.Errorf("bond1: %+v", l)
+	}
+	if l := c["bond2"]; l.Mode != "static" || l.Rate != "slow" {
+		t.Errorf("bond2: %+v", l)
+	}
+	if !Settable("lag.bond1.members") || Settable("lag.bond1.active") {
+		t.Error("settable")
+	}
+	for _, fields := range []map[string]string{
+		{"lag.bond1.mode": "dynamic"},
+		{"lag.bond1": "eth-1-1"},
+		{
+			"lag.bond1.members": "eth-1-1",
+			"lag.bond2.members": "eth-1-1",
+		},
+	} {
+		if _, err = Parse(fields); err == nil {
+			t.Errorf("%v: expected error", fields)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package lag provides the LACPDU codec and the schema of the redis
+// settable lag.* fields kept and applied by lagd along with a command to
+// show and change these.
+package lag
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "lag" }
+
+func (Command) Usage() string {
+	return `lag [-json] [show] [NAME]...
+lag add NAME PORT...
+lag del NAME [PORT]...
+lag mode NAME lacp|static
+lag rate NAME slow|fast`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or change link aggregation groups",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	lag [-json] [show] [NAME]...
+		print the members, mode, and status of all, or the given,
+		link aggregation groups
+
+	lag add NAME PORT...
+		add members, creating the group if it doesn't exist
+
+	lag del NAME [PORT]...
+		remove the given members, or the group
+
+	lag mode NAME lacp|static
+		lacp, the default, only attaches members that have
+		negotiated with the same partner; static attaches all
+		members that are up
+
+	lag rate NAME slow|fast
+		the rate of LACPDUs requested of the partner, every 30s,
+		the default, or every second
+
+	These are wrappers of the redis settable fields kept by lagd,
+		hset platina lag.NAME.members "PORT..."
+		hset platina lag.NAME.mode lacp|static
+		hset platina lag.NAME.rate slow|fast
+
+SEE ALSO
+	lagd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return add(args[1:]...)
+		case "del", "delete":
+			return del(args[1:]...)
+		case "mode", "rate":
+			if len(args) != 3 {
+				return fmt.Errorf("NAME %s: missing or unexpected",
+					strings.ToUpper(args[0]))
+			}
+			return hset(args[1], args[0], args[2])
+		}
+	}
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		selected := make(Config)
+		for _, name := range args {
+			if l, found := c[name]; found {
+				selected[name] = l
+			} else {
+				return fmt.Errorf("%s: not found", name)
+			}
+		}
+		c = selected
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LAG\tMODE\tMEMBER\tSTATUS\tPARTNER")
+	for _, name := range c.Names() {
+		l := c[name]
+		mode := l.Mode
+		if mode == "lacp" {
+			mode += "/" + l.Rate
+		}
+		if len(l.Members) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t\t\t%s\n", name, mode, l.Partner)
+		}
+		for i, port := range l.Members {
+			status := l.Ports[port]
+			if len(status) == 0 {
+				status = "unknown"
+			}
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", name, mode,
+					port, status, l.Partner)
+			} else {
+				fmt.Fprintf(w, "\t\t%s\t%s\t\n", port, status)
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// Get the configuration and status published by lagd.
+func Get() (Config, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(fields)
+}
+
+func add(args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("NAME PORT: missing")
+	}
+	name, ports := args[0], args[1:]
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	var members []string
+	if l, found := c[name]; found {
+		members = without(l.Members, ports)
+	}
+	return hset(name, "members", strings.Join(append(members, ports...),
+		" "))
+}
+
+func del(args ...string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("NAME: missing")
+	}
+	name, ports := args[0], args[1:]
+	if len(ports) > 0 {
+		c, err := Get()
+		if err != nil {
+			return err
+		}
+		l, found := c[name]
+		if !found {
+			return fmt.Errorf("%s: not found", name)
+		}
+		return hset(name, "members",
+			strings.Join(without(l.Members, ports), " "))
+	}
+	for _, attr := range []string{"members", "mode", "rate"} {
+		if err := hset(name, attr, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hset(name, attr, value string) error {
+	_, err := redis.Hset(redis.DefaultHash, Prefix+name+"."+attr, value)
+	return err
+}
+
+// without returns the list less the ports.
+func without(list, ports []string) []string {
+	var ret []string
+	for _, s := range list {
+		found := false
+		for _, port := range ports {
+			if s == port {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(args) <= 1 {
+		return complete.Prefixed(last, "-json", "show", "add", "del",
+			"mode", "rate")
+	}
+	names := func() []string {
+		if c, err := Get(); err == nil {
+			return complete.Prefixed(last, c.Names()...)
+		}
+		return nil
+	}
+	switch args[0] {
+	case "add", "del", "delete":
+		if len(args) == 2 {
+			return names()
+		}
+		return complete.IfName(last)
+	case "mode", "rate":
+		switch {
+		case len(args) == 2:
+			return names()
+		case len(args) == 3 && args[0] == "mode":
+			return complete.Prefixed(last, "lacp", "static")
+		case len(args) == 3:
+			return complete.Prefixed(last, "slow", "fast")
+		}
+		return nil
+	}
+	return names()
+}
@@ -0,0 +1,715 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package lagd provides a daemon that keeps the redis settable lag.*
+// fields, creates the configured link aggregation groups, runs LACP on
+// their members, and attaches those that are ready.
+package lagd
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/lag"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Type of the created aggregation links, default: xeth-lag; this
+	// may be overridden by lagd.type of the machine configuration.
+	Type string
+	// Link, if any, of the created aggregation links; from lagd.link
+	Link string
+
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	sr       *nl.SockReceiver
+	fd       int
+	hset     chan hset
+	groups   map[string]*group
+}
+
+// Lagd is the RPC handler of the redis settable lag.* fields.
+type Lagd struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+type group struct {
+	name  string
+	cfg   *lag.Lag
+	index int32
+	mac   net.HardwareAddr
+	key   uint16
+	// by name
+	members map[string]*member
+	// status fields of the last publication
+	published map[string]string
+	err       string
+}
+
+type member struct {
+	name   string
+	index  int32
+	mac    net.HardwareAddr
+	up     bool
+	master int32
+	// ifindex of the slow protocols multicast membership
+	joined int32
+	// LACP actor state and partner, if any
+	state   uint8
+	partner *lag.Info
+	expires time.Time
+	next    time.Time
+	err     string
+}
+
+type frame struct {
+	ifindex int
+	b       []byte
+}
+
+// linux/if_packet.h
+const (
+	packetMrMulticast = 0
+	packetOutgoing    = 4
+)
+
+type packetMreq struct {
+	ifindex int32
+	typ     uint16
+	alen    uint16
+	addr    [8]byte
+}
+
+const (
+	systemPriority = 0x8000
+	portPriority   = 0x8000
+)
+
+var multicast, _ = net.ParseMAC(lag.Multicast)
+
+func (*Command) String() string { return "lagd" }
+
+func (*Command) Usage() string { return "lagd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "link aggregation daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable fields,
+		lag.NAME.members: PORT...
+		lag.NAME.mode: lacp|static
+		lag.NAME.rate: slow|fast
+	in /etc/goes/persist/lag then create each NAME link of lagd.type
+	and attach, i.e. enslave, its members that are ready.
+
+	A static member is ready when it's up. An LACP member is ready when
+	it's up, has received an LACPDU within three times the requested
+	rate, and has the same partner system and key as the others.
+	lagd sends LACPDUs on each LACP member at the rate requested by its
+	partner and immediately on any change of the member's state.
+
+	lagd publishes the status of each group as,
+		lag.NAME.active: PORT...
+		lag.NAME.partner: PRIORITY,SYSTEM key KEY
+		lag.NAME.PORT: down|waiting|attached
+
+	lagd detaches removed members but doesn't delete the aggregation
+	link of a removed group. On stop, it detaches the LACP members so
+	that these don't forward without negotiation.
+
+FILES
+	/etc/goes/persist/lag
+	/etc/goes/machine.yaml
+		lagd:
+		  type: xeth-lag
+		  link: LINK
+
+SEE ALSO
+	lag`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	cfg := machine.Default()
+	if c.Type = cfg.String("lagd.type", c.Type); len(c.Type) == 0 {
+		c.Type = "xeth-lag"
+	}
+	c.Link = cfg.String("lagd.link", c.Link)
+	if c.settings, err = persist.Load("lag"); err != nil {
+		return err
+	}
+	fields := c.fields()
+	groups, err := lag.Parse(fields)
+	if err != nil {
+		return err
+	}
+
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	c.sr = nl.NewSockReceiver(sock)
+
+	c.fd, err = syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW,
+		int(htons(lag.EtherType)))
+	if err != nil {
+		return fmt.Errorf("socket: %v", err)
+	}
+	defer syscall.Close(c.fd)
+	// the receiver polls for stop
+	tv := syscall.NsecToTimeval(int64(time.Second))
+	err = syscall.SetsockoptTimeval(c.fd, syscall.SOL_SOCKET,
+		syscall.SO_RCVTIMEO, &tv)
+	if err != nil {
+		return fmt.Errorf("SO_RCVTIMEO: %v", err)
+	}
+
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+	c.pub.Print("delete: ", lag.Prefix)
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	c.hset = make(chan hset)
+	rpc.Register(&Lagd{c.hset})
+	srvr, err := atsock.NewRpcServer("lagd")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", lag.Prefix)
+	if err = redis.Assign(key, "lagd", "Lagd"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	frames := make(chan frame, 16)
+	done := make(chan struct{})
+	defer close(done)
+	go c.receive(frames, done)
+
+	c.groups = make(map[string]*group)
+	c.apply(groups)
+	defer c.stop()
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case now := <-t.C:
+			for _, g := range c.groups {
+				c.update(g, now)
+			}
+		case f := <-frames:
+			c.handle(f)
+		}
+	}
+}
+
+func (lagd *Lagd) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	lagd.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+func (c *Command) fields() map[string]string {
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	return fields
+}
+
+// set validates the field with the others before saving, publishing, and
+// applying it.
+func (c *Command) set(field, value string) error {
+	if !lag.Settable(field) {
+		return fmt.Errorf("%s: read only", field)
+	}
+	value = strings.Join(strings.Fields(value), " ")
+	fields := c.fields()
+	fields[field] = value
+	groups, err := lag.Parse(fields)
+	if err != nil {
+		return err
+	}
+	if err = c.settings.Set(field, value); err != nil {
+		return err
+	}
+	if len(value) > 0 {
+		c.pub.Print(field, ": ", value)
+	} else {
+		c.pub.Print("delete: ", field)
+	}
+	c.apply(groups)
+	return nil
+}
+
+// apply the configuration, releasing the members of removed groups and
+// removed members.
+func (c *Command) apply(groups lag.Config) {
+	for name, g := range c.groups {
+		if _, found := groups[name]; !found {
+			for _, m := range g.members {
+				c.detach(g, m)
+			}
+			c.pub.Print("delete: ", lag.Prefix, name, ".")
+			delete(c.groups, name)
+		}
+	}
+	now := time.Now()
+	for name, cfg := range groups {
+		g, found := c.groups[name]
+		if !found {
+			h := fnv.New32a()
+			h.Write([]byte(name))
+			g = &group{
+				name:      name,
+				key:       uint16(h.Sum32()) | 1,
+				members:   make(map[string]*member),
+				published: make(map[string]string),
+			}
+			c.groups[name] = g
+		}
+		if g.cfg != nil && g.cfg.Mode != cfg.Mode {
+			// restart negotiation
+			for _, m := range g.members {
+				m.partner = nil
+				m.state = 0
+			}
+		}
+		g.cfg = cfg
+		configured := make(map[string]struct{})
+		for _, port := range cfg.Members {
+			configured[port] = struct{}{}
+			if _, found := g.members[port]; !found {
+				g.members[port] = &member{name: port}
+			}
+		}
+		for port, m := range g.members {
+			if _, found := configured[port]; !found {
+				c.detach(g, m)
+				delete(g.members, port)
+			}
+		}
+		c.update(g, now)
+	}
+}
+
+// stop detaches the LACP members
+func (c *Command) stop() {
+	for _, g := range c.groups {
+		if g.cfg.Mode == "lacp" {
+			for _, m := range g.members {
+				c.detach(g, m)
+			}
+		}
+	}
+}
+
+// update the group's link and members then publish its status.
+func (c *Command) update(g *group, now time.Time) {
+	if err := c.link(g); err != nil {
+		if s := err.Error(); s != g.err {
+			log.Print("daemon", "err", g.name, ": ", s)
+			g.err = s
+		}
+		g.index = 0
+	} else {
+		g.err = ""
+	}
+	lacp := g.cfg.Mode == "lacp"
+	timeout := 30 * time.Second
+	if g.cfg.Rate == "fast" {
+		timeout = time.Second
+	}
+	names := make([]string, 0, len(g.members))
+	for name, m := range g.members {
+		names = append(names, name)
+		c.refresh(m)
+		if !m.up {
+			m.partner = nil
+		} else if m.partner != nil && now.After(m.expires) {
+			log.Print("daemon", "info", g.name, ": ", m.name,
+				": partner ", m.partner, " expired")
+			m.partner = nil
+		}
+		if lacp && m.up && m.joined != m.index {
+			if err := c.join(m.index); err != nil {
+				c.fail(m, err)
+			} else {
+				m.joined = m.index
+			}
+		}
+	}
+	sort.Strings(names)
+	var chosen *lag.Info
+	for _, name := range names {
+		if p := g.members[name].partner; p != nil &&
+			p.State&lag.StateAggregation != 0 {
+			chosen = p
+			break
+		}
+	}
+	status := make(map[string]string)
+	var active []string
+	for _, name := range names {
+		m := g.members[name]
+		ready := m.up
+		if lacp {
+			selected := m.up && m.partner != nil && chosen != nil &&
+				m.partner.Aggregates(chosen) &&
+				m.partner.State&lag.StateAggregation != 0
+			state := lag.StateActivity | lag.StateAggregation
+			if timeout == time.Second {
+				state |= lag.StateTimeout
+			}
+			if m.partner == nil {
+				state |= lag.StateDefaulted
+			}
+			if selected {
+				state |= lag.StateSynchronization
+				if m.partner.State&lag.StateSynchronization != 0 {
+					state |= lag.StateCollecting |
+						lag.StateDistributing
+				}
+			}
+			ready = state&lag.StateDistributing != 0
+			if state != m.state {
+				m.state = state
+				m.next = now
+			}
+			if m.up && !now.Before(m.next) {
+				c.transmit(g, m)
+				period := 30 * time.Second
+				if m.partner != nil &&
+					m.partner.State&lag.StateTimeout != 0 {
+					period = time.Second
+				}
+				m.next = now.Add(period)
+			}
+		}
+		if ready && g.index != 0 && m.index != 0 && m.master != g.index {
+			if err := c.master(m, g.index); err != nil {
+				c.fail(m, err)
+			} else {
+				log.Print("daemon", "info", g.name, ": attached ",
+					m.name)
+				m.master = g.index
+			}
+		} else if !ready {
+			c.detach(g, m)
+		}
+		switch {
+		case !m.up:
+			status[name] = "down"
+		case g.index != 0 && m.master == g.index:
+			status[name] = "attached"
+			active = append(active, name)
+		default:
+			status[name] = "waiting"
+		}
+	}
+	fields := make(map[string]string)
+	prefix := lag.Prefix + g.name + "."
+	for name, s := range status {
+		fields[prefix+name] = s
+	}
+	fields[prefix+"active"] = strings.Join(active, " ")
+	if lacp && chosen != nil {
+		fields[prefix+"partner"] = chosen.String()
+	}
+	for field := range g.published {
+		if _, found := fields[field]; !found {
+			c.pub.Print("delete: ", field)
+			delete(g.published, field)
+		}
+	}
+	for field, value := range fields {
+		if g.published[field] != value {
+			c.pub.Print(field, ": ", value)
+			g.published[field] = value
+		}
+	}
+}
+
+func (c *Command) fail(m *member, err error) {
+	if s := err.Error(); s != m.err {
+		log.Print("daemon", "err", m.name, ": ", s)
+		m.err = s
+	}
+}
+
+// link finds or creates the group's aggregation link.
+func (c *Command) link(g *group) error {
+	if ifi, err := net.InterfaceByName(g.name); err == nil {
+		g.index, g.mac = int32(ifi.Index), ifi.HardwareAddr
+		return nil
+	}
+	attrs := []nl.Attr{
+		{Type: rtnl.IFLA_IFNAME, Value: nl.KstringAttr(g.name)},
+	}
+	if len(c.Link) > 0 {
+		ifi, err := net.InterfaceByName(c.Link)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, nl.Attr{Type: rtnl.IFLA_LINK,
+			Value: nl.Int32Attr(ifi.Index)})
+	}
+	attrs = append(attrs, nl.Attr{Type: rtnl.IFLA_LINKINFO,
+		Value: nl.Attr{Type: rtnl.IFLA_INFO_KIND,
+			Value: nl.KstringAttr(c.Type)}})
+	req, err := nl.NewMessage(nl.Hdr{
+		Type: rtnl.RTM_NEWLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK |
+			nl.NLM_F_CREATE | nl.NLM_F_EXCL,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+		Flags:  rtnl.IFF_UP,
+		Change: rtnl.IFF_UP,
+	}, attrs...)
+	if err != nil {
+		return err
+	}
+	if err = c.sr.UntilDone(req, nl.DoNothing); err != nil {
+		return fmt.Errorf("create %s: %v", c.Type, err)
+	}
+	ifi, err := net.InterfaceByName(g.name)
+	if err != nil {
+		return err
+	}
+	log.Print("daemon", "info", g.name, ": created ", c.Type)
+	g.index, g.mac = int32(ifi.Index), ifi.HardwareAddr
+	return nil
+}
+
+// refresh the member's link state
+func (c *Command) refresh(m *member) {
+	ifi, err := net.InterfaceByName(m.name)
+	if err != nil {
+		m.index, m.up, m.master = 0, false, 0
+		return
+	}
+	m.index, m.mac = int32(ifi.Index), ifi.HardwareAddr
+	m.up = ifi.Flags&net.FlagUp != 0 && carrier(m.name)
+	m.master = 0
+	if s, err := filepath.EvalSymlinks(filepath.Join("/sys/class/net",
+		m.name, "master")); err == nil {
+		if ifi, err = net.InterfaceByName(filepath.Base(s)); err == nil {
+			m.master = int32(ifi.Index)
+		}
+	}
+}
+
+func carrier(name string) bool {
+	b, err := ioutil.ReadFile(filepath.Join("/sys/class/net", name,
+		"carrier"))
+	return err == nil && strings.TrimSpace(string(b)) == "1"
+}
+
+func (c *Command) detach(g *group, m *member) {
+	if g.index == 0 || m.index == 0 || m.master != g.index {
+		return
+	}
+	if err := c.master(m, 0); err != nil {
+		c.fail(m, err)
+		return
+	}
+	log.Print("daemon", "info", g.name, ": detached ", m.name)
+	m.master = 0
+}
+
+func (c *Command) master(m *member, index int32) error {
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_SETLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+		Index:  m.index,
+	}, nl.Attr{Type: rtnl.IFLA_MASTER, Value: nl.Int32Attr(index)})
+	if err != nil {
+		return err
+	}
+	return c.sr.UntilDone(req, nl.DoNothing)
+}
+
+// join the slow protocols multicast group of the interface
+func (c *Command) join(ifindex int32) error {
+	mreq := packetMreq{
+		ifindex: ifindex,
+		typ:     packetMrMulticast,
+		alen:    uint16(len(multicast)),
+	}
+	copy(mreq.addr[:], multicast)
+	_, _, e := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(c.fd),
+		syscall.SOL_PACKET, syscall.PACKET_ADD_MEMBERSHIP,
+		uintptr(unsafe.Pointer(&mreq)), unsafe.Sizeof(mreq), 0)
+	if e != 0 {
+		return fmt.Errorf("PACKET_ADD_MEMBERSHIP: %v", e)
+	}
+	return nil
+}
+
+func (c *Command) transmit(g *group, m *member) {
+	if len(m.mac) != 6 {
+		return
+	}
+	system := g.mac
+	if len(system) != 6 {
+		system = m.mac
+	}
+	pdu := &lag.Pdu{
+		Actor: lag.Info{
+			SystemPriority: systemPriority,
+			System:         system,
+			Key:            g.key,
+			PortPriority:   portPriority,
+			Port:           uint16(m.index),
+			State:          m.state,
+		},
+	}
+	if m.partner != nil {
+		pdu.Partner = *m.partner
+	} else {
+		pdu.Partner.System = make(net.HardwareAddr, 6)
+	}
+	b := make([]byte, 0, 14+lag.SizeofPdu)
+	b = append(b, multicast...)
+	b = append(b, m.mac...)
+	b = append(b, lag.EtherType>>8, lag.EtherType&0xff)
+	b = append(b, pdu.Marshal()...)
+	sa := &syscall.SockaddrLinklayer{
+		Protocol: htons(lag.EtherType),
+		Ifindex:  int(m.index),
+		Halen:    uint8(len(multicast)),
+	}
+	copy(sa.Addr[:], multicast)
+	if err := syscall.Sendto(c.fd, b, 0, sa); err != nil {
+		c.fail(m, err)
+	}
+}
+
+func (c *Command) receive(frames chan<- frame, done <-chan struct{}) {
+	buf := make([]byte, 1518)
+	for {
+		n, from, err := syscall.Recvfrom(c.fd, buf, 0)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if err != nil {
+			if err != syscall.EAGAIN && err != syscall.EINTR {
+				log.Print("daemon", "err", "recvfrom: ", err)
+				return
+			}
+			continue
+		}
+		sa, ok := from.(*syscall.SockaddrLinklayer)
+		if !ok || sa.Pkttype == packetOutgoing || n < 14 {
+			continue
+		}
+		b := make([]byte, n-14)
+		copy(b, buf[14:n])
+		select {
+		case frames <- frame{sa.Ifindex, b}:
+		case <-done:
+			return
+		}
+	}
+}
+
+// handle an LACPDU by recording the sender as the member's partner.
+func (c *Command) handle(f frame) {
+	for _, g := range c.groups {
+		if g.cfg.Mode != "lacp" {
+			continue
+		}
+		for _, m := range g.members {
+			if int(m.index) != f.ifindex {
+				continue
+			}
+			pdu, err := lag.Decode(f.b)
+			if err != nil {
+				c.fail(m, err)
+				return
+			}
+			if pdu == nil {
+				return
+			}
+			if m.partner == nil || !m.partner.Aggregates(&pdu.Actor) {
+				log.Print("daemon", "info", g.name, ": ", m.name,
+					": partner ", &pdu.Actor)
+			}
+			m.partner = &pdu.Actor
+			if pdu.Partner.Port != uint16(m.index) ||
+				pdu.Partner.State != m.state {
+				// the partner is out of date
+				m.next = time.Time{}
+			}
+			timeout := 30 * time.Second
+			if g.cfg.Rate == "fast" {
+				timeout = time.Second
+			}
+			m.expires = time.Now().Add(3 * timeout)
+			c.update(g, time.Now())
+			return
+		}
+	}
+}
+
+func htons(u uint16) uint16 {
+	return u<<8 | u>>8
+}
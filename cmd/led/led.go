@@ -0,0 +1,139 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package led provides a command to show and set the machine's LEDs and to
+// bind port LEDs to the link and activity of their net devices.
+package led
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/platinasystems/goes/external/led"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "led" }
+
+func (Command) Usage() string {
+	return `led [NAME [off | COLOR | blink-COLOR]]
+led ports`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or set LEDs",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Without arguments, list each LED with its supported colors and
+	current state. With NAME, show its state; with a STATE, set it.
+
+	The "ports" form has the kernel drive each port's LED with the link
+	and activity of its net device.
+
+	Daemons show the health of their subsystem on the correspondingly
+	named LED, e.g. fand on "fan" and psud on "psu" and "psuN", as green
+	if healthy, otherwise, amber.
+
+FILES
+	/etc/goes/machine.yaml
+		led:
+		  leds:
+		    system:
+		      sysfs:
+		        green: system:green
+		        amber: system:amber
+		    fan:
+		      gpio:
+		        green: pca9535 4
+		        amber: pca9535 5
+		    port1:
+		      sysfs:
+		        green: port1:green
+		  ports:
+		    eth-1-1: port1
+
+SEE ALSO
+	gpio`,
+	}
+}
+
+func (c Command) Main(args ...string) error {
+	leds, err := led.Leds()
+	if err != nil {
+		return err
+	}
+	switch len(args) {
+	case 0:
+		var names []string
+		for name := range leds {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			state, err := leds[name].Get()
+			if err != nil {
+				fmt.Println(name, err)
+				continue
+			}
+			fmt.Printf("%s: %s (%s)\n", name, state,
+				strings.Join(leds[name].Colors(), ", "))
+		}
+		return nil
+	case 1:
+		if args[0] == "ports" {
+			return c.ports(leds)
+		}
+		l, found := leds[args[0]]
+		if !found {
+			return fmt.Errorf("%s: no such LED", args[0])
+		}
+		state, err := l.Get()
+		if err != nil {
+			return err
+		}
+		fmt.Println(state)
+		return nil
+	case 2:
+		l, found := leds[args[0]]
+		if !found {
+			return fmt.Errorf("%s: no such LED", args[0])
+		}
+		state, err := led.ParseState(args[1])
+		if err != nil {
+			return err
+		}
+		return l.Set(state)
+	}
+	return fmt.Errorf("%v: unexpected", args[2:])
+}
+
+func (Command) ports(leds map[string]led.Led) error {
+	var first error
+	for dev, name := range led.Ports() {
+		var err error
+		if l, found := leds[name]; !found {
+			err = fmt.Errorf("%s: no such LED", name)
+		} else if n, ok := l.(led.Netdever); !ok {
+			err = fmt.Errorf("%s: can't show link", name)
+		} else {
+			err = n.Netdev(dev)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %v", dev, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
@@ -0,0 +1,174 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package lldp provides the LLDPDU codec of lldpd and a command to show
+// the neighbors that it publishes.
+package lldp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "lldp" }
+
+func (Command) Usage() string {
+	return "lldp [-json] [neighbors] [PORT]..."
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show LLDP neighbors",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Show the neighbors of all, or the given, ports that lldpd has
+	published as lldp.PORT.FIELD.
+
+OPTIONS
+	-json	print the neighbors by port as JSON
+
+SEE ALSO
+	lldpd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "neighbors" {
+		args = args[1:]
+	}
+	neighbors, err := Neighbors()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		m := make(map[string]*Neighbor)
+		for _, port := range args {
+			if n, found := neighbors[port]; found {
+				m[port] = n
+			}
+		}
+		neighbors = m
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(neighbors, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	var ports []string
+	for port := range neighbors {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "LOCAL\tCHASSIS\tPORT\tSYSTEM\tMGMT\tTTL")
+	for _, port := range ports {
+		n := neighbors[port]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", port, n.Chassis,
+			n.Port, n.System, strings.Join(n.Mgmt, ","), n.Ttl)
+	}
+	return w.Flush()
+}
+
+// Neighbors returns the published neighbors by local port.
+func Neighbors() (map[string]*Neighbor, error) {
+	conn, err := redis.Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ret, err := conn.Do("HGETALL", redis.DefaultHash)
+	if err != nil {
+		return nil, err
+	}
+	list, _ := ret.([]interface{})
+	neighbors := make(map[string]*Neighbor)
+	for i := 0; i+1 < len(list); i += 2 {
+		field, _ := list[i].([]byte)
+		value, _ := list[i+1].([]byte)
+		s := string(field)
+		if !strings.HasPrefix(s, "lldp.") {
+			continue
+		}
+		// the port name may have dots
+		dot := strings.LastIndex(s, ".")
+		if dot <= len("lldp.") {
+			continue
+		}
+		port, name := s[len("lldp."):dot], s[dot+1:]
+		n, found := neighbors[port]
+		if !found {
+			n = new(Neighbor)
+			neighbors[port] = n
+		}
+		n.set(name, string(value))
+	}
+	return neighbors, nil
+}
+
+func (n *Neighbor) set(name, value string) {
+	switch name {
+	case "chassis":
+		n.Chassis = value
+	case "port":
+		n.Port = value
+	case "ttl":
+		u, _ := strconv.ParseUint(value, 10, 16)
+		n.Ttl = uint16(u)
+	case "port_description":
+		n.PortDescription = value
+	case "system":
+		n.System = value
+	case "system_description":
+		n.SystemDescription = value
+	case "mgmt":
+		n.Mgmt = strings.Fields(value)
+	}
+}
+
+// Publish the neighbor fields of the port through the given printer.
+func (n *Neighbor) Publish(port string, printf func(string, ...interface{}) (int, error)) {
+	prefix := "lldp." + port + "."
+	printf("%schassis: %s", prefix, n.Chassis)
+	printf("%sport: %s", prefix, n.Port)
+	printf("%sttl: %d", prefix, n.Ttl)
+	for _, x := range []struct {
+		name, value string
+	}{
+		{"port_description", n.PortDescription},
+		{"system", n.System},
+		{"system_description", n.SystemDescription},
+		{"mgmt", strings.Join(n.Mgmt, " ")},
+	} {
+		if len(x.value) > 0 {
+			printf("%s%s: %s", prefix, x.name, x.value)
+		}
+	}
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	return append(complete.Prefixed(last, "-json", "neighbors"),
+		complete.IfName(last)...)
+}
@@ -0,0 +1,438 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package lldpd provides a daemon that advertises LLDP on each port and
+// publishes the discovered neighbors as lldp.PORT.FIELD.
+package lldpd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/lldp"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Prefixes of the LLDP port names; if empty, all but loopback
+	// ethernet interfaces.
+	Prefixes []string
+
+	// Interval between advertisements, default: 30s
+	Interval time.Duration
+
+	// Hold multiplier, of the interval, of the advertised TTL,
+	// default: 4
+	Hold int
+
+	// Mgmt is the interface of the advertised management addresses
+	// and chassis ID, default: eth0
+	Mgmt string
+
+	pub     *publisher.Publisher
+	fd      int
+	chassis net.HardwareAddr
+	// by interface index
+	ports map[int]*port
+}
+
+type port struct {
+	name     string
+	mac      net.HardwareAddr
+	neighbor *lldp.Neighbor
+	expires  time.Time
+}
+
+type frame struct {
+	ifindex int
+	b       []byte
+}
+
+// linux/if_packet.h
+const (
+	packetMrMulticast = 0
+	packetOutgoing    = 4
+)
+
+type packetMreq struct {
+	ifindex int32
+	typ     uint16
+	alen    uint16
+	addr    [8]byte
+}
+
+var multicast, _ = net.ParseMAC(lldp.Multicast)
+
+func (*Command) String() string { return "lldpd" }
+
+func (*Command) Usage() string { return "lldpd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "link layer discovery protocol daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Periodically transmit an LLDPDU on each port through a raw packet
+	socket and publish the received neighbor of each port as,
+		lldp.PORT.chassis: ID
+		lldp.PORT.port: ID
+		lldp.PORT.ttl: SECONDS
+		lldp.PORT.port_description: TEXT
+		lldp.PORT.system: NAME
+		lldp.PORT.system_description: TEXT
+		lldp.PORT.mgmt: ADDRESS...
+
+	The advertised chassis ID is the MAC address of the management
+	interface and the port ID is the interface name. A neighbor is
+	deleted when its TTL expires or with its shutdown LLDPDU.
+
+	lldpd re-enumerates the ports with each advertisement.
+
+FILES
+	/etc/goes/machine.yaml
+		lldpd:
+		  prefixes: [eth-, eth0]
+		  interval: 30s
+		  hold: 4
+		  mgmt: eth0
+
+SEE ALSO
+	lldp`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	c.fd, err = syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW,
+		int(htons(lldp.EtherType)))
+	if err != nil {
+		return fmt.Errorf("socket: %v", err)
+	}
+	defer syscall.Close(c.fd)
+	// the receiver polls for stop
+	tv := syscall.NsecToTimeval(int64(time.Second))
+	err = syscall.SetsockoptTimeval(c.fd, syscall.SOL_SOCKET,
+		syscall.SO_RCVTIMEO, &tv)
+	if err != nil {
+		return fmt.Errorf("SO_RCVTIMEO: %v", err)
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.ports = make(map[int]*port)
+	frames := make(chan frame, 16)
+	done := make(chan struct{})
+	defer close(done)
+	go c.receive(frames, done)
+
+	c.advertise(uint16(c.Hold) * uint16(c.Interval/time.Second))
+	defer c.advertise(0)
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	age := time.NewTicker(time.Second)
+	defer age.Stop()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+			c.advertise(uint16(c.Hold) * uint16(c.Interval/time.Second))
+		case now := <-age.C:
+			for _, p := range c.ports {
+				if p.neighbor != nil && now.After(p.expires) {
+					log.Print("daemon", "info", p.name,
+						": neighbor ", p.neighbor.System,
+						" ", p.neighbor.Port, " aged out")
+					c.forget(p)
+				}
+			}
+		case f := <-frames:
+			c.handle(f)
+		}
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	c.Prefixes = cfg.Strings("lldpd.prefixes", c.Prefixes)
+	if c.Interval, err = cfg.Duration("lldpd.interval", c.Interval); err != nil {
+		return
+	}
+	if c.Interval < time.Second {
+		c.Interval = 30 * time.Second
+	}
+	if c.Hold, err = cfg.Int("lldpd.hold", c.Hold); err != nil {
+		return
+	}
+	if c.Hold <= 0 {
+		c.Hold = 4
+	}
+	if c.Interval*time.Duration(c.Hold) > 0xffff*time.Second {
+		return fmt.Errorf("lldpd: interval * hold exceeds 65535s")
+	}
+	c.Mgmt = cfg.String("lldpd.mgmt", c.Mgmt)
+	if len(c.Mgmt) == 0 {
+		c.Mgmt = "eth0"
+	}
+	return
+}
+
+// scan the interfaces for new and removed ports
+func (c *Command) scan() {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		log.Print("daemon", "err", err)
+		return
+	}
+	present := make(map[int]struct{})
+	for _, ifi := range ifs {
+		if !c.match(ifi) {
+			continue
+		}
+		present[ifi.Index] = struct{}{}
+		if _, found := c.ports[ifi.Index]; found {
+			continue
+		}
+		if err := c.join(ifi.Index); err != nil {
+			log.Print("daemon", "err", ifi.Name, ": ", err)
+			continue
+		}
+		c.ports[ifi.Index] = &port{
+			name: ifi.Name,
+			mac:  ifi.HardwareAddr,
+		}
+	}
+	for index, p := range c.ports {
+		if _, found := present[index]; !found {
+			c.forget(p)
+			delete(c.ports, index)
+		}
+	}
+	c.chassis = nil
+	if ifi, err := net.InterfaceByName(c.Mgmt); err == nil &&
+		len(ifi.HardwareAddr) == 6 {
+		c.chassis = ifi.HardwareAddr
+	} else {
+		var names []string
+		macs := make(map[string]net.HardwareAddr)
+		for _, p := range c.ports {
+			names = append(names, p.name)
+			macs[p.name] = p.mac
+		}
+		if len(names) > 0 {
+			sort.Strings(names)
+			c.chassis = macs[names[0]]
+		}
+	}
+}
+
+func (c *Command) match(ifi net.Interface) bool {
+	if ifi.Flags&net.FlagLoopback != 0 || len(ifi.HardwareAddr) != 6 {
+		return false
+	}
+	if len(c.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range c.Prefixes {
+		if strings.HasPrefix(ifi.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// join the nearest bridge multicast group of the interface
+func (c *Command) join(ifindex int) error {
+	mreq := packetMreq{
+		ifindex: int32(ifindex),
+		typ:     packetMrMulticast,
+		alen:    uint16(len(multicast)),
+	}
+	copy(mreq.addr[:], multicast)
+	_, _, e := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(c.fd),
+		syscall.SOL_PACKET, syscall.PACKET_ADD_MEMBERSHIP,
+		uintptr(unsafe.Pointer(&mreq)), unsafe.Sizeof(mreq), 0)
+	if e != 0 {
+		return fmt.Errorf("PACKET_ADD_MEMBERSHIP: %v", e)
+	}
+	return nil
+}
+
+// advertise the chassis on each up port; a zero ttl is the shutdown
+// LLDPDU.
+func (c *Command) advertise(ttl uint16) {
+	if ttl > 0 {
+		c.scan()
+	}
+	if c.chassis == nil {
+		return
+	}
+	a := &lldp.Advertisement{
+		Chassis:           c.chassis,
+		Ttl:               ttl,
+		SystemDescription: systemDescription(),
+	}
+	a.System, _ = os.Hostname()
+	if ifi, err := net.InterfaceByName(c.Mgmt); err == nil {
+		addrs, _ := ifi.Addrs()
+		for _, addr := range addrs {
+			if ipn, ok := addr.(*net.IPNet); ok &&
+				!ipn.IP.IsLinkLocalUnicast() {
+				a.Mgmt = append(a.Mgmt, ipn.IP)
+			}
+		}
+	}
+	for index, p := range c.ports {
+		if ifi, err := net.InterfaceByIndex(index); err != nil ||
+			ifi.Flags&net.FlagUp == 0 {
+			continue
+		}
+		a.Port = p.name
+		a.PortDescription = p.name
+		b := make([]byte, 0, 128)
+		b = append(b, multicast...)
+		b = append(b, p.mac...)
+		b = append(b, lldp.EtherType>>8, lldp.EtherType&0xff)
+		b = append(b, a.Marshal()...)
+		for len(b) < 60 {
+			b = append(b, 0)
+		}
+		sa := &syscall.SockaddrLinklayer{
+			Protocol: htons(lldp.EtherType),
+			Ifindex:  index,
+			Halen:    uint8(len(multicast)),
+		}
+		copy(sa.Addr[:], multicast)
+		if err := syscall.Sendto(c.fd, b, 0, sa); err != nil &&
+			ttl > 0 {
+			log.Print("daemon", "err", p.name, ": ", err)
+		}
+	}
+}
+
+func (c *Command) receive(frames chan<- frame, done <-chan struct{}) {
+	buf := make([]byte, 1518)
+	for {
+		n, from, err := syscall.Recvfrom(c.fd, buf, 0)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if err != nil {
+			if err != syscall.EAGAIN && err != syscall.EINTR {
+				log.Print("daemon", "err", "recvfrom: ", err)
+				return
+			}
+			continue
+		}
+		sa, ok := from.(*syscall.SockaddrLinklayer)
+		if !ok || sa.Pkttype == packetOutgoing || n < 14 {
+			continue
+		}
+		b := make([]byte, n-14)
+		copy(b, buf[14:n])
+		select {
+		case frames <- frame{sa.Ifindex, b}:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (c *Command) handle(f frame) {
+	p, found := c.ports[f.ifindex]
+	if !found {
+		return
+	}
+	n, err := lldp.Decode(f.b)
+	if err != nil {
+		log.Print("daemon", "err", p.name, ": ", err)
+		return
+	}
+	if n.Ttl == 0 {
+		if p.neighbor != nil {
+			log.Print("daemon", "info", p.name, ": neighbor ",
+				p.neighbor.System, " ", p.neighbor.Port, " shutdown")
+			c.forget(p)
+		}
+		return
+	}
+	p.expires = time.Now().Add(time.Duration(n.Ttl) * time.Second)
+	if p.neighbor != nil && equal(p.neighbor, n) {
+		return
+	}
+	if p.neighbor == nil || p.neighbor.Chassis != n.Chassis ||
+		p.neighbor.Port != n.Port {
+		log.Print("daemon", "info", p.name, ": neighbor ", n.System, " ",
+			n.Port, " chassis ", n.Chassis)
+	}
+	if p.neighbor != nil {
+		c.pub.Print("delete: lldp.", p.name, ".")
+	}
+	p.neighbor = n
+	n.Publish(p.name, c.pub.Printf)
+}
+
+func (c *Command) forget(p *port) {
+	if p.neighbor != nil {
+		c.pub.Print("delete: lldp.", p.name, ".")
+		p.neighbor = nil
+	}
+}
+
+func equal(a, b *lldp.Neighbor) bool {
+	return a.Chassis == b.Chassis && a.Port == b.Port && a.Ttl == b.Ttl &&
+		a.PortDescription == b.PortDescription &&
+		a.System == b.System &&
+		a.SystemDescription == b.SystemDescription &&
+		strings.Join(a.Mgmt, " ") == strings.Join(b.Mgmt, " ")
+}
+
+func systemDescription() string {
+	var uts syscall.Utsname
+	if err := syscall.Uname(&uts); err != nil {
+		return "goes"
+	}
+	// the Utsname fields are int8 or uint8 by arch
+	str := func(p unsafe.Pointer) string {
+		b := (*[65]byte)(p)[:]
+		if i := strings.IndexByte(string(b), 0); i >= 0 {
+			b = b[:i]
+		}
+		return string(b)
+	}
+	return fmt.Sprint("goes ", str(unsafe.Pointer(&uts.Sysname)), " ",
+		str(unsafe.Pointer(&uts.Release)), " ",
+		str(unsafe.Pointer(&uts.Machine)))
+}
+
+func htons(u uint16) uint16 {
+	return u<<8 | u>>8
+}
@@ -0,0 +1,195 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lldp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+const (
+	// EtherType of LLDP frames
+	EtherType = 0x88cc
+	// dst address of nearest bridge
+	Multicast = "01:80:c2:00:00:0e"
+)
+
+// IEEE 802.1AB TLV types
+const (
+	tlvEnd = iota
+	tlvChassisId
+	tlvPortId
+	tlvTtl
+	tlvPortDescription
+	tlvSystemName
+	tlvSystemDescription
+	tlvSystemCapabilities
+	tlvMgmtAddress
+)
+
+// chassis and port ID subtypes
+const (
+	chassisIdMac     = 4
+	chassisIdNetwork = 5
+	portIdMac        = 3
+	portIdNetwork    = 4
+	portIdIfName     = 5
+)
+
+// IANA address family numbers of management and network address IDs
+const (
+	ianaIPv4 = 1
+	ianaIPv6 = 2
+)
+
+// Advertisement of the local chassis and port
+type Advertisement struct {
+	Chassis           net.HardwareAddr
+	Port              string
+	Ttl               uint16
+	PortDescription   string
+	System            string
+	SystemDescription string
+	Mgmt              []net.IP
+}
+
+// Neighbor is a decoded LLDPDU.
+type Neighbor struct {
+	Chassis           string   `json:"chassis"`
+	Port              string   `json:"port"`
+	Ttl               uint16   `json:"ttl"`
+	PortDescription   string   `json:"port_description,omitempty"`
+	System            string   `json:"system,omitempty"`
+	SystemDescription string   `json:"system_description,omitempty"`
+	Mgmt              []string `json:"mgmt,omitempty"`
+}
+
+// Marshal the LLDPDU, without the ethernet header, of the advertisement.
+func (a *Advertisement) Marshal() []byte {
+	var b []byte
+	tlv := func(t int, v ...byte) {
+		if len(v) > 511 {
+			v = v[:511]
+		}
+		b = append(b, byte(t<<1|len(v)>>8), byte(len(v)))
+		b = append(b, v...)
+	}
+	tlv(tlvChassisId, append([]byte{chassisIdMac}, a.Chassis...)...)
+	tlv(tlvPortId, append([]byte{portIdIfName}, a.Port...)...)
+	tlv(tlvTtl, byte(a.Ttl>>8), byte(a.Ttl))
+	for _, x := range []struct {
+		t int
+		s string
+	}{
+		{tlvPortDescription, a.PortDescription},
+		{tlvSystemName, a.System},
+		{tlvSystemDescription, a.SystemDescription},
+	} {
+		if len(x.s) > 0 {
+			tlv(x.t, []byte(x.s)...)
+		}
+	}
+	for _, ip := range a.Mgmt {
+		family, addr := byte(ianaIPv6), ip.To16()
+		if ip4 := ip.To4(); ip4 != nil {
+			family, addr = ianaIPv4, ip4
+		}
+		v := []byte{byte(1 + len(addr)), family}
+		v = append(v, addr...)
+		// unknown interface numbering and no OID
+		v = append(v, 1, 0, 0, 0, 0, 0)
+		tlv(tlvMgmtAddress, v...)
+	}
+	tlv(tlvEnd)
+	return b
+}
+
+// Decode the LLDPDU that follows the ethernet header.
+func Decode(b []byte) (*Neighbor, error) {
+	n := new(Neighbor)
+	var mandatory int
+	for len(b) >= 2 {
+		t := int(b[0] >> 1)
+		l := int(b[0]&1)<<8 | int(b[1])
+		if len(b) < 2+l {
+			return nil, fmt.Errorf("tlv %d: truncated", t)
+		}
+		v := b[2 : 2+l]
+		b = b[2+l:]
+		switch t {
+		case tlvEnd:
+			b = nil
+		case tlvChassisId:
+			if len(v) < 2 {
+				return nil, fmt.Errorf("chassis id: truncated")
+			}
+			n.Chassis = id(v[0], v[1:], chassisIdMac, chassisIdNetwork)
+			mandatory |= 1 << tlvChassisId
+		case tlvPortId:
+			if len(v) < 2 {
+				return nil, fmt.Errorf("port id: truncated")
+			}
+			n.Port = id(v[0], v[1:], portIdMac, portIdNetwork)
+			mandatory |= 1 << tlvPortId
+		case tlvTtl:
+			if len(v) < 2 {
+				return nil, fmt.Errorf("ttl: truncated")
+			}
+			n.Ttl = binary.BigEndian.Uint16(v)
+			mandatory |= 1 << tlvTtl
+		case tlvPortDescription:
+			n.PortDescription = text(v)
+		case tlvSystemName:
+			n.System = text(v)
+		case tlvSystemDescription:
+			n.SystemDescription = text(v)
+		case tlvMgmtAddress:
+			if len(v) < 2 || int(v[0]) < 1 || len(v) < 1+int(v[0]) {
+				continue
+			}
+			if ip := ipOf(v[1], v[2:1+int(v[0])]); ip != nil {
+				n.Mgmt = append(n.Mgmt, ip.String())
+			}
+		}
+	}
+	if want := 1<<tlvChassisId | 1<<tlvPortId | 1<<tlvTtl; mandatory != want {
+		return nil, fmt.Errorf("missing chassis id, port id, or ttl")
+	}
+	return n, nil
+}
+
+// id formats a chassis or port ID per its subtype.
+func id(subtype byte, v []byte, mac, network byte) string {
+	switch subtype {
+	case mac:
+		if len(v) == 6 {
+			return net.HardwareAddr(v).String()
+		}
+	case network:
+		if len(v) > 1 {
+			if ip := ipOf(v[0], v[1:]); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	return text(v)
+}
+
+// text returns the string with the white space, including line breaks,
+// reduced to single spaces for publication.
+func text(v []byte) string {
+	return strings.Join(strings.Fields(string(v)), " ")
+}
+
+func ipOf(family byte, v []byte) net.IP {
+	switch {
+	case family == ianaIPv4 && len(v) == 4,
+		family == ianaIPv6 && len(v) == 16:
+		return net.IP(v)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lldp
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalDecode(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+	a := &Advertisement{
+		Chassis:           mac,
+		Port:              "eth-1-1",
+		Ttl:               120,
+		PortDescription:   "eth-1-1",
+		System:            "invader",
+		SystemDescription: "goes Linux\n4.19 x86_64",
+		Mgmt: []net.IP{
+			net.ParseIP("192.0.2.1"),
+			net.ParseIP("2001:db8::1"),
+		},
+	}
+	n, err := Decode(a.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Neighbor{
+		Chassis:           "02:00:00:00:00:01",
+		Port:              "eth-1-1",
+		Ttl:               120,
+		PortDescription:   "eth-1-1",
+		System:            "invader",
+		SystemDescription: "goes Linux 4.19 x86_64",
+		Mgmt:              []string{"192.0.2.1", "2001:db8::1"},
+	}
+	if !reflect.DeepEqual(n, want) {
+		t.Errorf("%+v\nvs.\n%+v", n, want)
+	}
+}
+
+func TestDecodeMissing(t *testing.T) {
+	// chassis id and end only
+	b := []byte{2, 7, chassisIdMac, 2, 0, 0, 0, 0, 1, 0, 0}
+	if _, err := Decode(b); err == nil {
+		t.Error("missing port id and ttl not detected")
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package locale
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "locale" }
+
+func (Command) Usage() string { return "locale [-a | -r | LOCALE]" }
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "print or change the language of messages",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Without arguments, print the language of messages and the
+	environment variables that select it. With a LOCALE, e.g.
+	fr_FR.UTF-8, change the language of this console and the commands
+	that it then runs by setting LC_MESSAGES and unsetting LC_ALL.
+
+	Text that isn't compiled in for the language is translated by its
+	message catalog, if there is one, otherwise it's in the default
+	language.
+
+OPTIONS
+	-a	list the locales that have a catalog
+	-r	reload the catalogs, e.g. after installing another
+
+FILES
+	/usr/share/goes/locale/LOCALE/LC_MESSAGES/goes.mo
+	/usr/share/goes/locale/LOCALE/LC_MESSAGES/goes.po
+		the catalogs of each locale, also tried without the codeset
+		of LOCALE and as just its language, e.g. fr_FR then fr
+
+EXAMPLES
+	locale fr_FR.UTF-8
+	locale en_US.UTF-8
+
+SEE ALSO
+	export`,
+	}
+}
+
+func (Command) Kind() cmd.Kind { return cmd.DontFork | cmd.CantPipe }
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-a", "-r")
+	switch {
+	case flag.ByName["-a"]:
+		fmt.Println(lang.EnUS)
+		for _, s := range lang.Available() {
+			fmt.Println(s)
+		}
+		return nil
+	case flag.ByName["-r"]:
+		lang.Reload()
+		return nil
+	case len(args) == 0:
+		fmt.Println(lang.Locale())
+		for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			fmt.Print(name, "=", os.Getenv(name), "\n")
+		}
+		return nil
+	case len(args) > 1:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	lang.Reload()
+	if !lang.HasCatalog(args[0]) && args[0] != lang.EnUS &&
+		args[0] != lang.Default {
+		fmt.Fprintln(os.Stderr, args[0]+": no catalog")
+	}
+	if err := os.Setenv("LC_MESSAGES", args[0]); err != nil {
+		return err
+	}
+	// LC_ALL would otherwise override this
+	if len(os.Getenv("LC_ALL")) > 0 {
+		if err := os.Unsetenv("LC_ALL"); err != nil {
+			return err
+		}
+	}
+	lang.Lang = ""
+	return nil
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(last) > 0 && last[0] == '-' {
+		return complete.Prefixed(last, "-a", "-r")
+	}
+	return complete.Prefixed(last, append([]string{lang.EnUS},
+		lang.Available()...)...)
+}
@@ -6,8 +6,12 @@ package log
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
 	"github.com/platinasystems/goes/lang"
 )
 
@@ -16,7 +20,9 @@ type Command struct{}
 func (Command) String() string { return "log" }
 
 func (Command) Usage() string {
-	return "log [PRIORITY [FACILITY]] TEXT..."
+	return `log [PRIORITY [FACILITY]] TEXT...
+log level [SUBSYS [LEVEL]]
+log format [text|json]`
 }
 
 func (Command) Apropos() lang.Alt {
@@ -31,6 +37,18 @@ func (Command) Man() lang.Alt {
 DESCRIPTION
 	Logged text may be viewed with 'dmesg' command.
 
+	The "level" form shows or sets the level of each daemon or
+	subsystem; messages of lower priority are dropped. Without SUBSYS
+	this lists the set levels. The "default" SUBSYS is that of those
+	without a set level; the default LEVEL, debug, logs everything.
+	A LEVEL of "default" clears that of the SUBSYS.
+
+	The "format" form shows or sets the encoding of daemon messages,
+	text (the default) or json.
+
+	Daemons apply these published log.level.SUBSYS and log.format
+	fields as these change.
+
 PRIORITIES
 	emerg, alert, crit, err, warn, note, info, debug
 
@@ -40,7 +58,12 @@ FACILITIES
 	kern, user, mail, daemon, auth, syslog, lpr, news, uucp, cron, priv,
 	ftp, local0, local1, local2, local3, local4, local5, local6, local7
 
-	The default priority is: user.`,
+	The default priority is: user.
+
+EXAMPLES
+	goes log level vnetd debug
+	goes log level default warn
+	goes log format json`,
 	}
 }
 
@@ -48,6 +71,12 @@ func (Command) Main(args ...string) error {
 	if len(args) == 0 {
 		return errors.New("TEXT: missing")
 	}
+	switch args[0] {
+	case "level":
+		return level(args[1:])
+	case "format":
+		return format(args[1:])
+	}
 	argv := make([]interface{}, 0, len(args))
 	defer func() { argv = argv[:0] }()
 	for _, s := range args {
@@ -56,3 +85,80 @@ func (Command) Main(args ...string) error {
 	log.Print(argv...)
 	return nil
 }
+
+func level(args []string) error {
+	const prefix = "log.level"
+	switch len(args) {
+	case 0:
+		fields, err := redis.Hgetall(redis.DefaultHash, prefix)
+		if err != nil {
+			return err
+		}
+		var lines []string
+		for field, value := range fields {
+			subsys := strings.TrimPrefix(field, prefix)
+			if len(subsys) == 0 {
+				subsys = "default"
+			} else if subsys[0] == '.' {
+				subsys = subsys[1:]
+			} else {
+				continue
+			}
+			lines = append(lines, subsys+": "+value)
+		}
+		sort.Strings(lines)
+		for _, s := range lines {
+			fmt.Println(s)
+		}
+		return nil
+	case 1, 2:
+	default:
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	field := prefix
+	if args[0] != "default" {
+		field += "." + args[0]
+	}
+	if len(args) == 1 {
+		s, err := redis.Hget(redis.DefaultHash, field)
+		if err != nil {
+			return err
+		}
+		if len(s) == 0 {
+			s = "default"
+		}
+		fmt.Println(s)
+		return nil
+	}
+	if args[1] != "default" {
+		if _, err := log.ParseLevel(args[1]); err != nil {
+			return err
+		}
+	}
+	_, err := redis.Hset(redis.DefaultHash, field, args[1])
+	return err
+}
+
+func format(args []string) error {
+	const field = "log.format"
+	switch len(args) {
+	case 0:
+		s, err := redis.Hget(redis.DefaultHash, field)
+		if err != nil {
+			return err
+		}
+		if len(s) == 0 {
+			s = "text"
+		}
+		fmt.Println(s)
+		return nil
+	case 1:
+	default:
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if _, found := log.Encoders[args[0]]; !found {
+		return fmt.Errorf("%q: unknown format", args[0])
+	}
+	_, err := redis.Hset(redis.DefaultHash, field, args[0])
+	return err
+}
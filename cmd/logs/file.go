@@ -0,0 +1,149 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Line of a daemon's captured output.
+type Line struct {
+	Daemon   string
+	Time     time.Time
+	Priority string
+	Text     string
+}
+
+// Filter of the printed lines.
+type Filter struct {
+	Since time.Time
+	Grep  *regexp.Regexp
+}
+
+func (f *Filter) Match(l *Line) bool {
+	if !f.Since.IsZero() && l.Time.Before(f.Since) {
+		return false
+	}
+	return f.Grep == nil || f.Grep.MatchString(l.Text)
+}
+
+// parseLine of a log file,
+//
+//	Jan _2 15:04:05.000 PRIORITY: TEXT
+//
+// The time stamp has no year so this infers the latest that isn't after now.
+func parseLine(daemon, s string, now time.Time) (Line, bool) {
+	l := Line{Daemon: daemon}
+	n := len(time.StampMilli)
+	if len(s) < n+1 || s[n] != ' ' {
+		return l, false
+	}
+	t, err := time.ParseInLocation(time.StampMilli, s[:n], now.Location())
+	if err != nil {
+		return l, false
+	}
+	l.Time = t.AddDate(now.Year(), 0, 0)
+	if l.Time.After(now.Add(time.Minute)) {
+		l.Time = l.Time.AddDate(-1, 0, 0)
+	}
+	rest := s[n+1:]
+	if i := strings.Index(rest, ": "); i > 0 {
+		l.Priority, l.Text = rest[:i], rest[i+2:]
+	} else {
+		l.Text = rest
+	}
+	return l, true
+}
+
+// logFiles returns the rotated then current log files of the daemon in DIR,
+// oldest first, i.e. NAME.log.N[.gz] ... NAME.log.1[.gz] NAME.log
+func logFiles(dir, daemon string) []string {
+	fn := filepath.Join(dir, daemon+".log")
+	rotated, _ := filepath.Glob(fn + ".*")
+	nth := func(s string) int {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, fn+"."), ".gz")
+		i, err := strconv.Atoi(s)
+		if err != nil {
+			return -1
+		}
+		return i
+	}
+	var files []string
+	for _, s := range rotated {
+		if nth(s) > 0 {
+			files = append(files, s)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return nth(files[i]) > nth(files[j])
+	})
+	if _, err := os.Stat(fn); err == nil {
+		files = append(files, fn)
+	}
+	return files
+}
+
+// daemonsWithLogs returns the names of the daemons with a log file in DIR.
+func daemonsWithLogs(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.log"))
+	names := make([]string, 0, len(matches))
+	for _, s := range matches {
+		names = append(names, strings.TrimSuffix(filepath.Base(s), ".log"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readLogs returns the matching lines of the daemon's log files, oldest
+// first; lines without a time stamp have that of the preceding line.
+func readLogs(dir, daemon string, f *Filter) ([]Line, error) {
+	var lines []Line
+	now := time.Now()
+	for _, fn := range logFiles(dir, daemon) {
+		if err := func() error {
+			file, err := os.Open(fn)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			var r io.Reader = file
+			if strings.HasSuffix(fn, ".gz") {
+				zr, err := gzip.NewReader(file)
+				if err != nil {
+					return err
+				}
+				defer zr.Close()
+				r = zr
+			}
+			var prev Line
+			scan := bufio.NewScanner(r)
+			scan.Buffer(make([]byte, 0, 4096), 1<<20)
+			for scan.Scan() {
+				l, ok := parseLine(daemon, scan.Text(), now)
+				if !ok {
+					l = prev
+					l.Text = scan.Text()
+				}
+				prev = l
+				if f.Match(&l) {
+					lines = append(lines, l)
+				}
+			}
+			return scan.Err()
+		}(); err != nil {
+			return lines, err
+		}
+	}
+	return lines, nil
+}
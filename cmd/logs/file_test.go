@@ -0,0 +1,97 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package logs
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestParseLine(t *testing.T) {
+	now := time.Date(2020, time.January, 2, 0, 0, 0, 0, time.UTC)
+	l, ok := parseLine("vnetd", "Jan  1 23:59:59.500 err: oops: x", now)
+	if !ok || l.Priority != "err" || l.Text != "oops: x" ||
+		l.Time.Year() != 2020 {
+		t.Errorf("%v %+v", ok, l)
+	}
+	l, ok = parseLine("vnetd", "Dec 31 23:59:59.500 info: last year", now)
+	if !ok || l.Time.Year() != 2019 {
+		t.Errorf("%v %+v", ok, l)
+	}
+	if _, ok = parseLine("vnetd", "panic: runtime error", now); ok {
+		t.Error("parsed line without time")
+	}
+}
+
+func TestReadLogs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stamp := func(d time.Duration) string {
+		return time.Now().Add(-d).Format(time.StampMilli)
+	}
+	write := func(fn, s string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, fn), []byte(s),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("vnetd.log.2", stamp(3*time.Hour)+" info: two\n")
+	f, err := os.Create(filepath.Join(dir, "vnetd.log.1.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(f)
+	zw.Write([]byte(stamp(2*time.Hour) + " info: one\n"))
+	zw.Close()
+	f.Close()
+	write("vnetd.log", stamp(time.Minute)+" err: panic: zero\n"+
+		"goroutine 1 [running]:\n")
+	write("vnetd.log.tmp", "ignored\n")
+	write("redisd.log", "")
+
+	if got := daemonsWithLogs(dir); len(got) != 2 ||
+		got[0] != "redisd" || got[1] != "vnetd" {
+		t.Errorf("daemons: %q", got)
+	}
+	text := func(lines []Line) (s []string) {
+		for _, l := range lines {
+			s = append(s, l.Text)
+		}
+		return
+	}
+	for _, x := range []struct {
+		f    Filter
+		want []string
+	}{
+		{Filter{}, []string{"two", "one", "panic: zero",
+			"goroutine 1 [running]:"}},
+		{Filter{Since: time.Now().Add(-150 * time.Minute)},
+			[]string{"one", "panic: zero", "goroutine 1 [running]:"}},
+		{Filter{Grep: regexp.MustCompile("^(one|goroutine)")},
+			[]string{"one", "goroutine 1 [running]:"}},
+	} {
+		lines, err := readLogs(dir, "vnetd", &x.f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := text(lines)
+		if len(got) != len(x.want) {
+			t.Fatalf("got %q want %q", got, x.want)
+		}
+		for i := range got {
+			if got[i] != x.want[i] {
+				t.Errorf("got %q want %q", got[i], x.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package logs provides the command to search and tail the captured output
+// of the daemons.
+package logs
+
+import (
+	"fmt"
+	"net/rpc"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd/daemons"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/event"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "logs" }
+
+func (Command) Usage() string {
+	return "logs [-since DURATION|TIME] [-grep PATTERN] [-follow] [DAEMON]..."
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "search and tail the output of daemons",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the stdout ("info") and stderr ("err") of the named daemons, or
+	all, as captured by the daemons supervisor, oldest first, as
+		TIME [DAEMON] PRIORITY: TEXT
+
+	The history is that of the current and rotated, possibly compressed,
+	log files of each daemon; without these, it's the most recent output
+	retained by the supervisor.
+
+OPTIONS
+	-since DURATION|TIME
+		print lines since this long ago, e.g. "10m", or RFC 3339 time
+	-grep PATTERN
+		print lines matching this regular expression
+	-follow, -f
+		print the matching output of the daemons as it's captured
+
+FILES
+	/var/log/goes/DAEMON.log[.N[.gz]]
+		the default directory of the captured output
+
+	/etc/goes/machine.yaml
+		logs:
+		  dir: /var/log/goes
+
+EXAMPLES
+	goes logs vnetd -since 10m
+	goes logs -grep "link (up|down)"
+	goes logs -follow redisd
+
+SEE ALSO
+	daemons, events`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-since", "-grep")
+	flag, args := flags.New(args, "-follow", "-f")
+	f := new(Filter)
+	if s := parm.ByName["-since"]; len(s) > 0 {
+		t, err := event.ParseSince(s)
+		if err != nil {
+			return err
+		}
+		f.Since = t
+	}
+	if s := parm.ByName["-grep"]; len(s) > 0 {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return fmt.Errorf("-grep: %v", err)
+		}
+		f.Grep = re
+	}
+	dir := machine.Default().String("logs.dir",
+		daemons.DefaultLogConfig.Dir)
+	if dir == "-" {
+		dir = ""
+	}
+	names := args
+	if len(names) == 0 && len(dir) > 0 {
+		names = daemonsWithLogs(dir)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("DAEMON: missing")
+	}
+	var cl *rpc.Client
+	if c, err := atsock.NewRpcClient(daemons.Sockname()); err == nil {
+		cl = c
+		defer cl.Close()
+	}
+	var lines []Line
+	for _, name := range names {
+		var history []Line
+		if len(dir) > 0 {
+			l, err := readLogs(dir, name, f)
+			if err != nil {
+				return err
+			}
+			history = l
+		}
+		if len(history) == 0 && cl != nil {
+			history, _, _ = output(cl, name, f, 0, 0)
+		}
+		lines = append(lines, history...)
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Time.Before(lines[j].Time)
+	})
+	show := func(l *Line) {
+		if len(names) > 1 {
+			fmt.Println(l.Time.Format(time.StampMilli), l.Daemon,
+				l.Priority+":", l.Text)
+		} else {
+			fmt.Println(l.Time.Format(time.StampMilli),
+				l.Priority+":", l.Text)
+		}
+	}
+	for i := range lines {
+		show(&lines[i])
+	}
+	if !flag.ByName["-follow"] && !flag.ByName["-f"] {
+		return nil
+	}
+	if cl == nil {
+		return fmt.Errorf("daemons: not running")
+	}
+	// don't repeat the history, whose times are of milliseconds
+	if n := len(lines); n > 0 {
+		f.Since = lines[n-1].Time.Add(time.Millisecond)
+	}
+	ch := make(chan Line, 64)
+	errs := make(chan error, len(names))
+	for _, name := range names {
+		go follow(cl, name, f, ch, errs)
+	}
+	for pending := len(names); ; {
+		select {
+		case <-goes.Stop:
+			return nil
+		case l := <-ch:
+			show(&l)
+		case err := <-errs:
+			if pending--; pending == 0 || len(names) == 1 {
+				return err
+			}
+		}
+	}
+}
+
+// output returns the matching lines retained by the supervisor after seq
+// and the sequence of the last.
+func output(cl *rpc.Client, name string, f *Filter, seq uint64,
+	wait time.Duration) ([]Line, uint64, error) {
+	var reply daemons.OutputReply
+	err := cl.Call("Daemons.Output", daemons.OutputArgs{
+		Name: name,
+		Seq:  seq,
+		Wait: wait,
+	}, &reply)
+	if err != nil {
+		return nil, seq, fmt.Errorf("%s: %v", name, err)
+	}
+	var lines []Line
+	for _, o := range reply.Lines {
+		seq = o.Seq
+		l := Line{
+			Daemon:   name,
+			Time:     o.Time,
+			Priority: o.Priority,
+			Text:     o.Text,
+		}
+		if f.Match(&l) {
+			lines = append(lines, l)
+		}
+	}
+	return lines, seq, nil
+}
+
+// follow the output of the named daemon until stopped.
+func follow(cl *rpc.Client, name string, f *Filter, ch chan<- Line,
+	errs chan<- error) {
+	var seq uint64
+	for {
+		lines, last, err := output(cl, name, f, seq, time.Second)
+		if err != nil {
+			errs <- err
+			return
+		}
+		seq = last
+		for _, l := range lines {
+			select {
+			case ch <- l:
+			case <-goes.Stop:
+				return
+			}
+		}
+	}
+}
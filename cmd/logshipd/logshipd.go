@@ -0,0 +1,413 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package logshipd forwards the daemon logs and command audit records to
+// remote syslog servers.
+package logshipd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/lang"
+)
+
+// DefaultBuffer is the number of messages held for each server during an
+// outage, after which the oldest are dropped.
+const DefaultBuffer = 10000
+
+// the limit of UDP message length, RFC 5426 3.2
+const maxUDP = 8192
+
+var logger = log.New("logshipd")
+
+type Command struct {
+	// Level is the lowest priority forwarded
+	Level    syslog.Priority
+	Buffer   int
+	Hostname string
+	SdID     string
+	Servers  []*Server
+}
+
+// Server of remote syslog
+type Server struct {
+	// Address is HOST[:PORT]
+	Address string
+	// Transport is udp, tcp, or tls
+	Transport string
+	TLS       *tls.Config
+
+	max     int
+	mutex   sync.Mutex
+	queue   []queued
+	seq     uint64
+	dropped int
+	wake    chan struct{}
+}
+
+type queued struct {
+	seq uint64
+	msg []byte
+}
+
+func (*Command) String() string { return "logshipd" }
+
+func (*Command) Usage() string { return "logshipd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "syslog forwarding daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Forward the messages of goes daemons and the audit records of the
+	commands entered at the cli and of ssh sessions to remote syslog
+	servers as RFC 5424 messages over UDP, TCP, or TLS (RFC 5425).
+	The record fields are the structured data of the configured SD-ID.
+
+	Each server has a buffer of messages held during an outage; when
+	full, the oldest are dropped then counted in a warning after
+	reconnecting. TCP and TLS servers are redialed with an exponential
+	backoff of up to a minute.
+
+	The default level is info, so debug messages aren't forwarded.
+	The default UDP and TCP port is 514, and that of TLS, 6514.
+
+FILES
+	/etc/goes/machine.yaml
+		logshipd:
+		  level: info
+		  buffer: 10000
+		  hostname: HOSTNAME
+		  sd-id: goes@32473
+		  servers:
+		    - address: 10.0.0.10
+		      transport: udp
+		    - address: logs.example.com:6514
+		      transport: tls
+		      ca: /etc/goes/logshipd/ca.pem
+		      cert: /etc/goes/logshipd/cert.pem
+		      key: /etc/goes/logshipd/key.pem
+
+SEE ALSO
+	log, cli, sshd`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err := c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if len(c.Servers) == 0 {
+		logger.Warn("no servers")
+		<-goes.Stop
+		return nil
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: log.ShipSocket,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, s := range c.Servers {
+		wg.Add(1)
+		go func(s *Server) {
+			defer wg.Done()
+			s.run(stop)
+		}(s)
+	}
+	done := make(chan error, 1)
+	go func() { done <- c.receive(conn) }()
+	select {
+	case <-goes.Stop:
+	case err = <-done:
+	}
+	close(stop)
+	wg.Wait()
+	return err
+}
+
+func (c *Command) configure(cfg *machine.Config) error {
+	level := cfg.String("logshipd.level", "info")
+	pri, err := log.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	c.Level = pri
+	c.Buffer, err = cfg.Int("logshipd.buffer", DefaultBuffer)
+	if err != nil {
+		return err
+	}
+	if c.Buffer <= 0 {
+		return fmt.Errorf("logshipd.buffer: %d: invalid", c.Buffer)
+	}
+	c.Hostname = cfg.String("logshipd.hostname", "")
+	if len(c.Hostname) == 0 {
+		c.Hostname, _ = os.Hostname()
+	}
+	c.SdID = cfg.String("logshipd.sd-id", DefaultSdID)
+	for _, i := range cfg.Keys("logshipd.servers") {
+		prefix := "logshipd.servers." + i + "."
+		s, err := NewServer(cfg.String(prefix+"address", ""),
+			cfg.String(prefix+"transport", "udp"), c.Buffer)
+		if err != nil {
+			return err
+		}
+		if s.Transport == "tls" {
+			s.TLS, err = tlsConfig(s.Address,
+				cfg.String(prefix+"ca", ""),
+				cfg.String(prefix+"cert", ""),
+				cfg.String(prefix+"key", ""))
+			if err != nil {
+				return fmt.Errorf("%s: %w", s.Address, err)
+			}
+		}
+		c.Servers = append(c.Servers, s)
+	}
+	return nil
+}
+
+// NewServer returns the server of the address with the given buffer; the
+// default port is that of the transport.
+func NewServer(address, transport string, buffer int) (*Server, error) {
+	if len(address) == 0 {
+		return nil, errors.New("server address: missing")
+	}
+	port := "514"
+	switch transport {
+	case "udp", "tcp":
+	case "tls":
+		port = "6514"
+	default:
+		return nil, fmt.Errorf("%s: %q: unsupported transport",
+			address, transport)
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, port)
+	}
+	return &Server{
+		Address:   address,
+		Transport: transport,
+		max:       buffer,
+		wake:      make(chan struct{}, 1),
+	}, nil
+}
+
+func tlsConfig(address, ca, cert, key string) (*tls.Config, error) {
+	host, _, _ := net.SplitHostPort(address)
+	config := &tls.Config{ServerName: host}
+	if len(ca) > 0 {
+		b, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("%s: no certificates", ca)
+		}
+	}
+	if len(cert) > 0 {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
+
+// receive the shipped records, forwarding those of the configured level.
+func (c *Command) receive(conn *net.UnixConn) error {
+	buf := make([]byte, 1<<16)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return err
+		}
+		var x log.Shipped
+		if err = json.Unmarshal(buf[:n], &x); err != nil {
+			continue
+		}
+		if x.Priority&log.PriorityMask > c.Level {
+			continue
+		}
+		msg := Format(&x, c.Hostname, c.SdID)
+		for _, s := range c.Servers {
+			s.Push(msg)
+		}
+	}
+}
+
+// Push the message to the server's queue, dropping the oldest if full.
+func (s *Server) Push(msg []byte) {
+	s.mutex.Lock()
+	if len(s.queue) >= s.max {
+		s.queue = s.queue[1:]
+		s.dropped++
+	}
+	s.seq++
+	s.queue = append(s.queue, queued{s.seq, msg})
+	s.mutex.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Len returns the number of queued messages.
+func (s *Server) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.queue)
+}
+
+func (s *Server) head() (queued, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) == 0 {
+		return queued{}, false
+	}
+	return s.queue[0], true
+}
+
+// pop the sent message unless it was already dropped.
+func (s *Server) pop(seq uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) > 0 && s.queue[0].seq == seq {
+		s.queue[0] = queued{}
+		s.queue = s.queue[1:]
+	}
+}
+
+func (s *Server) takeDropped() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := s.dropped
+	s.dropped = 0
+	return n
+}
+
+// Frame the message per the transport; TCP and TLS have octet counting,
+// RFC 6587 3.4.1.
+func (s *Server) Frame(msg []byte) []byte {
+	if s.Transport == "udp" {
+		if len(msg) > maxUDP {
+			msg = msg[:maxUDP]
+		}
+		return msg
+	}
+	b := strconv.AppendInt(nil, int64(len(msg)), 10)
+	b = append(b, ' ')
+	return append(b, msg...)
+}
+
+func (s *Server) dial() (net.Conn, error) {
+	d := &net.Dialer{Timeout: 10 * time.Second}
+	if s.Transport == "tls" {
+		return tls.DialWithDialer(d, "tcp", s.Address, s.TLS)
+	}
+	return d.Dial(s.Transport, s.Address)
+}
+
+// run sends the queued messages until stopped, redialing after failures.
+func (s *Server) run(stop <-chan struct{}) {
+	const minBackoff, maxBackoff = time.Second, time.Minute
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	backoff, down := minBackoff, false
+	wait := func() bool {
+		select {
+		case <-stop:
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		return true
+	}
+	for {
+		x, ok := s.head()
+		if !ok {
+			select {
+			case <-stop:
+				return
+			case <-s.wake:
+			}
+			continue
+		}
+		if conn == nil {
+			var err error
+			if conn, err = s.dial(); err != nil {
+				conn = nil
+				if !down {
+					logger.Err("dial", "server", s.Address,
+						"err", err)
+					down = true
+				}
+				if !wait() {
+					return
+				}
+				continue
+			}
+			if down {
+				logger.Info("connected", "server", s.Address)
+				down = false
+			}
+			if n := s.takeDropped(); n > 0 {
+				logger.Warn("dropped", "server", s.Address,
+					"messages", n)
+			}
+		}
+		conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		if _, err := conn.Write(s.Frame(x.msg)); err != nil {
+			conn.Close()
+			conn = nil
+			if !down {
+				logger.Err("write", "server", s.Address,
+					"err", err)
+				down = true
+			}
+			if !wait() {
+				return
+			}
+			continue
+		}
+		backoff = minBackoff
+		s.pop(x.seq)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package logshipd
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/goes/external/log"
+)
+
+// DefaultSdID is that of the structured data of record fields; 32473 is the
+// enterprise number reserved for documentation by RFC 5612, so sites with
+// their own should configure logshipd.sd-id.
+const DefaultSdID = "goes@32473"
+
+const rfc5424Time = "2006-01-02T15:04:05.000000Z07:00"
+
+// Format the shipped record as an RFC 5424 message of an APP-NAME and PROCID
+// from the record ID, PROG[PID], a MSGID of the subsystem, and the fields as
+// SD-PARAMs of the given SD-ID.
+func Format(x *log.Shipped, hostname, sdID string) []byte {
+	app, procid := x.ID, ""
+	if i := strings.LastIndex(app, "["); i > 0 &&
+		strings.HasSuffix(app, "]") {
+		app, procid = app[:i], app[i+1:len(app)-1]
+	}
+	b := make([]byte, 0, 128+len(x.Msg))
+	b = append(b, '<')
+	b = strconv.AppendUint(b, uint64(x.Priority), 10)
+	b = append(b, ">1 "...)
+	if x.Time.IsZero() {
+		b = append(b, '-')
+	} else {
+		b = x.Time.AppendFormat(b, rfc5424Time)
+	}
+	for _, f := range []struct {
+		s   string
+		max int
+	}{
+		{hostname, 255},
+		{app, 48},
+		{procid, 128},
+		{x.Subsys, 32},
+	} {
+		b = append(b, ' ')
+		b = appendHeader(b, f.s, f.max)
+	}
+	b = append(b, ' ')
+	if len(x.Fields) == 0 {
+		b = append(b, '-')
+	} else {
+		b = append(b, '[')
+		b = appendHeader(b, sdID, 32)
+		for i := 0; i+1 < len(x.Fields); i += 2 {
+			b = append(b, ' ')
+			b = appendParamName(b, x.Fields[i])
+			b = append(b, '=', '"')
+			for _, r := range x.Fields[i+1] {
+				if r == '"' || r == '\\' || r == ']' {
+					b = append(b, '\\')
+				}
+				b = append(b, string(r)...)
+			}
+			b = append(b, '"')
+		}
+		b = append(b, ']')
+	}
+	if len(x.Msg) > 0 {
+		b = append(b, ' ')
+		b = append(b, x.Msg...)
+	}
+	return b
+}
+
+// appendHeader of PRINTUSASCII, or the NILVALUE, "-", if empty.
+func appendHeader(b []byte, s string, max int) []byte {
+	if len(s) == 0 {
+		return append(b, '-')
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 33 || c > 126 {
+			b = append(b, '_')
+		} else {
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// appendParamName of PRINTUSASCII except '=', ']', and '"'.
+func appendParamName(b []byte, s string) []byte {
+	if len(s) == 0 {
+		return append(b, '_')
+	}
+	if len(s) > 32 {
+		s = s[:32]
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 33 || c > 126 || c == '=' || c == ']' ||
+			c == '"' {
+			b = append(b, '_')
+		} else {
+			b = append(b, c)
+		}
+	}
+	return b
+}
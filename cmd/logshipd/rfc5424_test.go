@@ -0,0 +1,53 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package logshipd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/platinasystems/goes/external/log"
+)
+
+func TestFormat(t *testing.T) {
+	x := &log.Shipped{
+		Priority: 30,
+		Time:     time.Date(2020, 1, 2, 3, 4, 5, 6000, time.UTC),
+		ID:       "goes.vnetd[123]",
+		Subsys:   "vnetd",
+		Msg:      "port up",
+		Fields:   []string{"ifname", "eth-1-1", "note", `a "quoted] \ value`},
+	}
+	want := `<30>1 2020-01-02T03:04:05.000006Z switch-1 goes.vnetd 123 vnetd [goes@32473 ifname="eth-1-1" note="a \"quoted\] \\ value"] port up`
+	if got := string(Format(x, "switch-1", DefaultSdID)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	x = &log.Shipped{Priority: 86, ID: "cli", Msg: "x"}
+	want = `<86>1 - - cli - - - x`
+	if got := string(Format(x, "", DefaultSdID)); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("10.0.0.1", "tls", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Address != "10.0.0.1:6514" {
+		t.Errorf("got %q", s.Address)
+	}
+	for _, msg := range []string{"one", "two", "three"} {
+		s.Push([]byte(msg))
+	}
+	x, _ := s.head()
+	if string(s.Frame(x.msg)) != "3 two" || s.Len() != 2 ||
+		s.takeDropped() != 1 {
+		t.Errorf("got %q of %d", x.msg, s.Len())
+	}
+	if _, err = NewServer("10.0.0.1", "sctp", 2); err == nil {
+		t.Error("sctp: expected error")
+	}
+}
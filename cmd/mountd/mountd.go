@@ -7,7 +7,6 @@ package mountd
 import (
 	"bufio"
 	"errors"
-	"fmt"
 	"os"
 	"strings"
 	"syscall"
@@ -22,6 +21,8 @@ import (
 
 var ErrUnknownPartition = errors.New("Unable to determine partition type")
 
+var logger = log.New("mountd")
+
 type Command struct {
 	partitions map[string]error
 }
@@ -80,7 +81,7 @@ func (c *Command) mountall(mp string) {
 
 	pm, err := os.Open("/proc/mounts")
 	if err != nil {
-		fmt.Printf("opening /proc/mounts: %s\n", err)
+		logger.Err("open", "err", err)
 		return
 	}
 	defer pm.Close()
@@ -101,17 +102,17 @@ func (c *Command) mountall(mp string) {
 			if _, err := os.Stat(mpd); os.IsNotExist(err) {
 				err := os.Mkdir(mpd, os.FileMode(0555))
 				if err != nil {
-					fmt.Println("mkdir", mpd, "err:", err)
+					logger.Err("mkdir", "dir", mpd, "err", err)
 					continue
 				}
 			}
 			err := c.mountone("/dev/"+part, mpd)
 			if err == nil {
-				fmt.Println("Mounted", "/dev/"+part,
-					mpd)
+				logger.Info("mounted", "dev", "/dev/"+part,
+					"dir", mpd)
 			} else {
 				if err != ErrUnknownPartition {
-					fmt.Println("mount", mpd, "err:", err)
+					logger.Err("mount", "dir", mpd, "err", err)
 				}
 			}
 			c.partitions[mpd] = err
@@ -129,7 +130,7 @@ func (c *Command) unmountall(mp string) {
 		if e == nil {
 			err := syscall.Unmount(mpd, syscall.MNT_DETACH)
 			if err != nil {
-				fmt.Printf("Error unmounting %s: %s\n", mpd, err)
+				logger.Err("unmount", "dir", mpd, "err", err)
 			}
 		}
 		delete(c.partitions, mpd)
@@ -0,0 +1,135 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package neighbor
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Prefix of the redis settable fields,
+//
+//	neighbor.ADDRESS: lladdr LLADDR dev IFNAME [permanent]
+//
+// of the static ARP and ND entries kept by neighd.
+const Prefix = "neighbor."
+
+// Config of the static entries by address
+type Config map[string]*Entry
+
+// Entry of a static neighbor
+type Entry struct {
+	Address string `json:"address"`
+	Lladdr  string `json:"lladdr"`
+	Dev     string `json:"dev"`
+	// Permanent entries are never aged nor replaced by the kernel;
+	// otherwise, the entry is reachable until the kernel probes it.
+	Permanent bool `json:"permanent"`
+}
+
+// ParseEntry of the ADDRESS and [lladdr LLADDR dev IFNAME [permanent]]
+// args.
+func ParseEntry(address string, args ...string) (*Entry, error) {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return nil, fmt.Errorf("%s: invalid address", address)
+	}
+	e := &Entry{Address: ip.String()}
+	for len(args) > 0 {
+		switch args[0] {
+		case "permanent":
+			e.Permanent = true
+			args = args[1:]
+			continue
+		case "lladdr", "dev":
+		default:
+			return nil, fmt.Errorf("%s: unknown", args[0])
+		}
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s: missing value", args[0])
+		}
+		if args[0] == "lladdr" {
+			mac, err := net.ParseMAC(args[1])
+			if err != nil || len(mac) != 6 {
+				return nil, fmt.Errorf("%s: invalid lladdr",
+					args[1])
+			}
+			e.Lladdr = mac.String()
+		} else {
+			e.Dev = args[1]
+		}
+		args = args[2:]
+	}
+	if len(e.Lladdr) == 0 {
+		return nil, fmt.Errorf("lladdr: missing")
+	}
+	if len(e.Dev) == 0 {
+		return nil, fmt.Errorf("dev: missing")
+	}
+	return e, nil
+}
+
+// String returns the entry's field value.
+func (e *Entry) String() string {
+	s := fmt.Sprint("lladdr ", e.Lladdr, " dev ", e.Dev)
+	if e.Permanent {
+		s += " permanent"
+	}
+	return s
+}
+
+// Parse the neighbor.* fields.
+func Parse(fields map[string]string) (Config, error) {
+	c := make(Config)
+	for field, value := range fields {
+		if !strings.HasPrefix(field, Prefix) {
+			return nil, fmt.Errorf("%s: invalid", field)
+		}
+		if len(strings.TrimSpace(value)) == 0 {
+			continue
+		}
+		e, err := ParseEntry(field[len(Prefix):],
+			strings.Fields(value)...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+		if e.Address != field[len(Prefix):] {
+			return nil, fmt.Errorf("%s: not canonical, %s", field,
+				Prefix+e.Address)
+		}
+		c[e.Address] = e
+	}
+	return c, nil
+}
+
+// Addresses returns the sorted addresses of the entries, IPv4 first.
+func (c Config) Addresses() []string {
+	addrs := make([]string, 0, len(c))
+	for addr := range c {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool {
+		return less(addrs[i], addrs[j])
+	})
+	return addrs
+}
+
+// less returns true if address a sorts before b, IPv4 before IPv6.
+func less(a, b string) bool {
+	x, y := net.ParseIP(a), net.ParseIP(b)
+	if x == nil || y == nil {
+		return a < b
+	}
+	x4, y4 := x.To4(), y.To4()
+	if (x4 == nil) != (y4 == nil) {
+		return x4 != nil
+	}
+	if x4 != nil {
+		x, y = x4, y4
+	}
+	return string(x) < string(y)
+}
@@ -0,0 +1,42 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package neighbor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"neighbor.10.0.0.2":    "lladdr 02:00:00:00:00:02 dev eth-3-1 permanent",
+		"neighbor.2001:db8::2": "dev eth-4-1 lladdr 02-00-00-00-00-04",
+		"neighbor.10.0.0.3":    "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(c.Addresses(), " "); s != "10.0.0.2 2001:db8::2" {
+		t.Errorf("addresses: %q", s)
+	}
+	if s := c["2001:db8::2"].String(); s !=
+		"lladdr 02:00:00:00:00:04 dev eth-4-1" {
+		t.Errorf("2001:db8::2: %q", s)
+	}
+	if !c["10.0.0.2"].Permanent {
+		t.Error("10.0.0.2: not permanent")
+	}
+	for field, value := range map[string]string{
+		"neighbor.10.0.0.256":  "lladdr 02:00:00:00:00:02 dev eth-3-1",
+		"neighbor.10.0.0.4":    "lladdr 02:00:00:00:00:02",
+		"neighbor.10.0.0.5":    "lladdr xyz dev eth-3-1",
+		"neighbor.10.0.0.6":    "lladdr 02:00:00:00:00:02 dev eth-3-1 x",
+		"neighbor.2001:DB8::2": "lladdr 02:00:00:00:00:02 dev eth-3-1",
+	} {
+		if _, err = Parse(map[string]string{field: value}); err == nil {
+			t.Errorf("%s: %q: expected error", field, value)
+		}
+	}
+}
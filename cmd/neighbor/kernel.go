@@ -0,0 +1,152 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package neighbor
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+// Neighbor of the kernel's ARP and ND tables
+type Neighbor struct {
+	Address string `json:"address"`
+	Lladdr  string `json:"lladdr,omitempty"`
+	Dev     string `json:"dev"`
+	State   string `json:"state"`
+	// Static entries are permanent; otherwise, these are dynamic.
+	Static bool `json:"static"`
+	// Configured is true if the entry is that of neighd.
+	Configured bool `json:"configured"`
+}
+
+var nudNames = []struct {
+	state uint16
+	name  string
+}{
+	{rtnl.NUD_PERMANENT, "permanent"},
+	{rtnl.NUD_REACHABLE, "reachable"},
+	{rtnl.NUD_STALE, "stale"},
+	{rtnl.NUD_DELAY, "delay"},
+	{rtnl.NUD_PROBE, "probe"},
+	{rtnl.NUD_INCOMPLETE, "incomplete"},
+	{rtnl.NUD_FAILED, "failed"},
+}
+
+// StateName of the NUD_* state.
+func StateName(state uint16) string {
+	for _, x := range nudNames {
+		if state&x.state != 0 {
+			return x.name
+		}
+	}
+	return "none"
+}
+
+// Dump the IPv4 and IPv6 neighbors, less those without ARP or ND, e.g.
+// multicast.
+func Dump(sr *nl.SockReceiver) ([]*Neighbor, error) {
+	var list []*Neighbor
+	names := make(map[int32]string)
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifs {
+			names[int32(ifi.Index)] = ifi.Name
+		}
+	}
+	for _, af := range []uint8{rtnl.AF_INET, rtnl.AF_INET6} {
+		req, err := nl.NewMessage(nl.Hdr{
+			Type:  rtnl.RTM_GETNEIGH,
+			Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+		}, rtnl.RtGenMsg{
+			Family: af,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = sr.UntilDone(req, func(b []byte) {
+			var nda rtnl.Nda
+			msg := rtnl.NdMsgPtr(b)
+			if nl.HdrPtr(b).Type != rtnl.RTM_NEWNEIGH || msg == nil ||
+				msg.State&rtnl.NUD_NOARP != 0 ||
+				msg.State == rtnl.NUD_NONE {
+				return
+			}
+			nda.Write(b)
+			if len(nda[rtnl.NDA_DST]) == 0 {
+				return
+			}
+			n := &Neighbor{
+				Address: net.IP(nda[rtnl.NDA_DST]).String(),
+				Dev:     names[msg.Index],
+				State:   StateName(msg.State),
+				Static:  msg.State&rtnl.NUD_PERMANENT != 0,
+			}
+			if val := nda[rtnl.NDA_LLADDR]; len(val) > 0 {
+				n.Lladdr = net.HardwareAddr(val).String()
+			}
+			list = append(list, n)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// Replace, or add, the kernel's entry of the address with the static
+// entry.
+func Replace(sr *nl.SockReceiver, e *Entry) error {
+	return modify(sr, rtnl.RTM_NEWNEIGH, e)
+}
+
+// Delete the kernel's entry of the static entry, if any.
+func Delete(sr *nl.SockReceiver, e *Entry) error {
+	return modify(sr, rtnl.RTM_DELNEIGH, e)
+}
+
+func modify(sr *nl.SockReceiver, t uint16, e *Entry) error {
+	ifi, err := net.InterfaceByName(e.Dev)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(e.Address)
+	msg := rtnl.NdMsg{
+		Family: rtnl.AF_INET6,
+		Index:  int32(ifi.Index),
+		State:  rtnl.NUD_REACHABLE,
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, msg.Family = ip4, rtnl.AF_INET
+	}
+	if e.Permanent {
+		msg.State = rtnl.NUD_PERMANENT
+	}
+	hdr := nl.Hdr{
+		Type:  t,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK,
+	}
+	attrs := []nl.Attr{
+		{Type: rtnl.NDA_DST, Value: nl.BytesAttr(ip)},
+	}
+	if t == rtnl.RTM_NEWNEIGH {
+		hdr.Flags |= nl.NLM_F_CREATE | nl.NLM_F_REPLACE
+		mac, err := net.ParseMAC(e.Lladdr)
+		if err != nil {
+			return err
+		}
+		attrs = append(attrs, nl.Attr{Type: rtnl.NDA_LLADDR,
+			Value: nl.BytesAttr(mac)})
+	}
+	req, err := nl.NewMessage(hdr, msg, attrs...)
+	if err != nil {
+		return err
+	}
+	if err = sr.UntilDone(req, nl.DoNothing); err != nil {
+		return fmt.Errorf("%s: %v", e.Address, err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package neighbor provides the schema of the redis settable neighbor.*
+// fields of the static ARP and ND entries kept by neighd, along with a
+// command to show the static and dynamic entries and change the static.
+package neighbor
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "neighbor" }
+
+func (Command) Usage() string {
+	return `neighbor [-json] [show] [-static | -dynamic] [IFNAME]...
+neighbor add ADDRESS lladdr LLADDR dev IFNAME [permanent]
+neighbor del ADDRESS...`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show ARP and ND entries or change the static entries",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	neighbor [-json] [show] [-static | -dynamic] [IFNAME]...
+		print the ARP and ND entries of all, or the given,
+		interfaces with their state and type, static or dynamic;
+		and whether these are configured, i.e. kept by neighd; a
+		configured entry that isn't in the kernel has state missing
+
+	neighbor add ADDRESS lladdr LLADDR dev IFNAME [permanent]
+		add or replace the static entry of the address; without
+		permanent, the kernel may probe, then age, the entry until
+		neighd reapplies it
+
+	neighbor del ADDRESS...
+		remove the static entries
+
+	These are wrappers of the redis settable fields kept by neighd,
+		hset platina neighbor.ADDRESS "lladdr LLADDR dev IFNAME [permanent]"
+
+EXAMPLES
+	neighbor add 10.0.0.2 lladdr 02:00:00:00:00:02 dev eth-3-1 permanent
+	neighbor -static
+
+SEE ALSO
+	neighd, ip neighbor`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return add(args[1:]...)
+		case "del", "delete":
+			return del(args[1:]...)
+		}
+	}
+	flag, args := flags.New(args, "-json", "-static", "-dynamic")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	list, err := Dump(nl.NewSockReceiver(sock))
+	if err != nil {
+		return err
+	}
+	if c, err := Get(); err == nil {
+		list = Merge(list, c)
+	}
+	devs := make(map[string]bool)
+	for _, dev := range args {
+		devs[dev] = true
+	}
+	var selected []*Neighbor
+	for _, n := range list {
+		if len(devs) > 0 && !devs[n.Dev] ||
+			flag.ByName["-static"] && !n.Static && !n.Configured ||
+			flag.ByName["-dynamic"] && (n.Static || n.Configured) {
+			continue
+		}
+		selected = append(selected, n)
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		if selected[i].Dev != selected[j].Dev {
+			return selected[i].Dev < selected[j].Dev
+		}
+		return less(selected[i].Address, selected[j].Address)
+	})
+	if flag.ByName["-json"] {
+		if selected == nil {
+			selected = []*Neighbor{}
+		}
+		b, err := json.MarshalIndent(selected, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tLLADDR\tDEV\tSTATE\tTYPE\tCONFIGURED")
+	for _, n := range selected {
+		typ, configured, lladdr := "dynamic", "-", n.Lladdr
+		if n.Static {
+			typ = "static"
+		}
+		if n.Configured {
+			configured = "yes"
+		}
+		if len(lladdr) == 0 {
+			lladdr = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", n.Address, lladdr,
+			n.Dev, n.State, typ, configured)
+	}
+	return w.Flush()
+}
+
+// Get the static entries kept by neighd.
+func Get() (Config, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(fields)
+}
+
+// Merge the configured entries with those of the kernel; those missing
+// from the kernel are appended with state "missing".
+func Merge(list []*Neighbor, c Config) []*Neighbor {
+	found := make(map[string]bool)
+	for _, n := range list {
+		if e, ok := c[n.Address]; ok && e.Dev == n.Dev {
+			n.Configured = true
+			found[n.Address] = true
+		}
+	}
+	for _, addr := range c.Addresses() {
+		if e := c[addr]; !found[addr] {
+			list = append(list, &Neighbor{
+				Address:    e.Address,
+				Lladdr:     e.Lladdr,
+				Dev:        e.Dev,
+				State:      "missing",
+				Static:     e.Permanent,
+				Configured: true,
+			})
+		}
+	}
+	return list
+}
+
+func add(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("ADDRESS: missing")
+	}
+	e, err := ParseEntry(args[0], args[1:]...)
+	if err != nil {
+		return err
+	}
+	_, err = redis.Hset(redis.DefaultHash, Prefix+e.Address, e.String())
+	return err
+}
+
+func del(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("ADDRESS: missing")
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	for _, arg := range args {
+		ip := net.ParseIP(arg)
+		if ip == nil {
+			return fmt.Errorf("%s: invalid address", arg)
+		}
+		if _, found := c[ip.String()]; !found {
+			return fmt.Errorf("%s: not found", arg)
+		}
+		_, err = redis.Hset(redis.DefaultHash, Prefix+ip.String(), "")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (Command) Complete(args ...string) []string {
+	last, prev := complete.Last(args)
+	if len(args) <= 1 {
+		return append(complete.Prefixed(last, "-json", "show", "add",
+			"del", "-static", "-dynamic"), complete.IfName(last)...)
+	}
+	switch args[0] {
+	case "add":
+		switch {
+		case len(args) == 2:
+			return nil
+		case prev == "dev":
+			return complete.IfName(last)
+		case prev == "lladdr":
+			return nil
+		}
+		return complete.Prefixed(last, "lladdr", "dev", "permanent")
+	case "del", "delete":
+		if c, err := Get(); err == nil {
+			return complete.Prefixed(last, c.Addresses()...)
+		}
+		return nil
+	}
+	return append(complete.Prefixed(last, "-json", "-static", "-dynamic"),
+		complete.IfName(last)...)
+}
@@ -0,0 +1,356 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package neighd provides a daemon that keeps the redis settable neighbor.*
+// fields and pins these static ARP and ND entries in the kernel.
+package neighd
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/config"
+	"github.com/platinasystems/goes/cmd/neighbor"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	cfg      neighbor.Config
+	sr       *nl.SockReceiver
+	hset     chan hset
+	recon    chan reconcile
+	// ifindex of each configured dev at its last apply
+	indices map[string]int32
+}
+
+// Neighd is the RPC handler of the redis settable neighbor.* fields and of
+// the Reconcile requests of "config reconcile".
+type Neighd struct {
+	hset  chan<- hset
+	recon chan<- reconcile
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+type reconcile struct {
+	names []string
+	done  chan reconciled
+}
+
+type reconciled struct {
+	applied []string
+	err     error
+}
+
+func (*Command) String() string { return "neighd" }
+
+func (*Command) Usage() string { return "neighd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "static neighbor daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable fields,
+		neighbor.ADDRESS: lladdr LLADDR dev IFNAME [permanent]
+	in /etc/goes/persist/neighbor then add or replace these ARP and ND
+	entries of the kernel, which the xeth driver relays to vnetd, on
+	start and on each change.
+
+	neighd reapplies an entry whenever the kernel deletes or changes
+	it, its interface is recreated, or vnet.ready becomes true, i.e.
+	when vnetd restarts; and on demand with "config reconcile".
+
+	A field of an interface that doesn't exist is kept and applied once
+	the interface is added.
+
+	nld publishes the permanent entries of each interface, whether
+	kept by neighd or not, as,
+		IFNAME.inet.static-neighbor: ADDRESS@LLADDR...
+		IFNAME.inet6.static-neighbor: ADDRESS@LLADDR...
+
+FILES
+	/etc/goes/persist/neighbor
+
+SEE ALSO
+	neighbor, config, nld`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if c.settings, err = persist.Load("neighbor"); err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	if c.cfg, err = neighbor.Parse(fields); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", neighbor.Prefix)
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	c.sr = nl.NewSockReceiver(sock)
+
+	c.hset = make(chan hset)
+	c.recon = make(chan reconcile)
+	rpc.Register(&Neighd{c.hset, c.recon})
+	srvr, err := atsock.NewRpcServer("neighd")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", neighbor.Prefix)
+	if err = redis.Assign(key, "neighd", "Neighd"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	// subscribe before applying to not miss intervening changes
+	sub, err := nl.NewSock(nl.NETLINK_ROUTE, 16,
+		rtnl.RTNLGRP_LINK.Bit()|rtnl.RTNLGRP_NEIGH.Bit())
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	ready, closer, err := config.VnetReady()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	c.indices = make(map[string]int32)
+	c.reconcile(nil)
+	wasReady := config.IsVnetReady()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case r := <-c.recon:
+			applied, err := c.reconcile(r.names)
+			r.done <- reconciled{applied, err}
+		case isReady := <-ready:
+			if isReady && !wasReady {
+				log.Print("daemon", "info", "vnet ready, reapply")
+				c.reconcile(nil)
+			}
+			wasReady = isReady
+		case b, opened := <-sub.RxCh:
+			if !opened {
+				return sub.Err
+			}
+			var names []string
+			for len(b) >= nl.SizeofHdr {
+				var msg []byte
+				if msg, b, err = nl.Pop(b); err != nil {
+					log.Print("daemon", "err", err)
+					break
+				}
+				if name := c.changed(msg); len(name) > 0 {
+					names = append(names, name)
+				}
+			}
+			if len(names) > 0 {
+				c.reconcile(names)
+			}
+		}
+	}
+}
+
+func (neighd *Neighd) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	neighd.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+// Reconcile reapplies the entries of the given, or all, addresses or
+// interfaces and replies with the addresses of those applied.
+func (neighd *Neighd) Reconcile(names []string, reply *[]string) error {
+	r := reconcile{names, make(chan reconciled, 1)}
+	neighd.recon <- r
+	done := <-r.done
+	*reply = done.applied
+	return done.err
+}
+
+// set validates the field before saving, publishing, and applying it.
+func (c *Command) set(field, value string) error {
+	value = strings.Join(strings.Fields(value), " ")
+	cfg, err := neighbor.Parse(map[string]string{field: value})
+	if err != nil {
+		return err
+	}
+	addr := field[len(neighbor.Prefix):]
+	e := cfg[addr]
+	if e != nil {
+		value = e.String()
+	}
+	if err = c.settings.Set(field, value); err != nil {
+		return err
+	}
+	if prev, found := c.cfg[addr]; found &&
+		(e == nil || e.Dev != prev.Dev) {
+		if err = neighbor.Delete(c.sr, prev); err != nil {
+			log.Print("daemon", "warning", err)
+		}
+	}
+	if e == nil {
+		delete(c.cfg, addr)
+		c.pub.Print("delete: ", field)
+		return nil
+	}
+	c.cfg[addr] = e
+	c.pub.Print(field, ": ", value)
+	return c.apply(e)
+}
+
+// changed returns the address of a configured entry that the kernel
+// deleted or changed, or the name of a configured interface that was
+// recreated.
+func (c *Command) changed(msg []byte) string {
+	h := nl.HdrPtr(msg)
+	if h == nil {
+		return ""
+	}
+	switch h.Type {
+	case rtnl.RTM_NEWLINK, rtnl.RTM_DELLINK:
+		var ifla rtnl.Ifla
+		ifinfo := rtnl.IfInfoMsgPtr(msg)
+		if ifinfo == nil || ifinfo.Family == rtnl.AF_BRIDGE {
+			return ""
+		}
+		ifla.Write(msg)
+		name := nl.Kstring(ifla[rtnl.IFLA_IFNAME])
+		if _, found := c.indices[name]; !found && !c.hasDev(name) {
+			return ""
+		}
+		if h.Type == rtnl.RTM_DELLINK {
+			delete(c.indices, name)
+			return ""
+		}
+		if c.indices[name] == ifinfo.Index {
+			return ""
+		}
+		return name
+	case rtnl.RTM_NEWNEIGH, rtnl.RTM_DELNEIGH:
+		var nda rtnl.Nda
+		ndmsg := rtnl.NdMsgPtr(msg)
+		if ndmsg == nil {
+			return ""
+		}
+		nda.Write(msg)
+		if len(nda[rtnl.NDA_DST]) == 0 {
+			return ""
+		}
+		e, found := c.cfg[net.IP(nda[rtnl.NDA_DST]).String()]
+		if !found || c.indices[e.Dev] != ndmsg.Index {
+			return ""
+		}
+		if h.Type == rtnl.RTM_NEWNEIGH &&
+			ndmsg.State&(rtnl.NUD_FAILED|rtnl.NUD_INCOMPLETE) == 0 &&
+			(!e.Permanent || ndmsg.State&rtnl.NUD_PERMANENT != 0) &&
+			net.HardwareAddr(nda[rtnl.NDA_LLADDR]).String() ==
+				e.Lladdr {
+			return ""
+		}
+		return e.Address
+	}
+	return ""
+}
+
+func (c *Command) hasDev(name string) bool {
+	for _, e := range c.cfg {
+		if e.Dev == name {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcile applies the entries of the given, or all, addresses or
+// interfaces, logging then returning the first error along with the
+// addresses of those with existing interfaces.
+func (c *Command) reconcile(names []string) ([]string, error) {
+	var first error
+	var applied []string
+	selected := make(map[string]bool)
+	for _, name := range names {
+		selected[name] = true
+	}
+	for _, addr := range c.cfg.Addresses() {
+		e := c.cfg[addr]
+		if len(selected) > 0 && !selected[addr] && !selected[e.Dev] {
+			continue
+		}
+		if _, err := net.InterfaceByName(e.Dev); err != nil {
+			continue
+		}
+		applied = append(applied, addr)
+		if err := c.apply(e); err != nil {
+			log.Print("daemon", "err", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return applied, first
+}
+
+// apply the entry if its interface exists.
+func (c *Command) apply(e *neighbor.Entry) error {
+	ifi, err := net.InterfaceByName(e.Dev)
+	if err != nil {
+		return nil
+	}
+	c.indices[e.Dev] = int32(ifi.Index)
+	return neighbor.Replace(c.sr, e)
+}
@@ -0,0 +1,816 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package nld provides a daemon that subscribes to rtnetlink and publishes
+// the link, address, route, and neighbor state of the matching interfaces
+// as IFNAME.FIELD.
+package nld
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/vrf"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/event"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Prefixes of the published interface names, e.g. "eth-"; if
+	// empty, nld publishes all but the loopback interface.  This may be
+	// overridden by nld.prefixes of the machine configuration then
+	// changed at runtime with,
+	//	hset platina nld.prefixes "eth- xeth"
+	Prefixes []string
+
+	pub   *publisher.Publisher
+	links map[int32]*link
+	// VRF devices by ifindex, regardless of prefixes
+	vrfs map[int32]*vrfDev
+	// events are published only after the initial dump
+	dumped bool
+	// new prefixes from the Hset of nld.prefixes or Reload
+	prefixes chan []string
+	// defaults are the Prefixes before those of the machine configuration
+	defaults []string
+	initOnce sync.Once
+}
+
+// Nld is the RPC handler of the redis settable nld.prefixes.
+type Nld struct {
+	prefixes chan<- []string
+}
+
+const prefixesField = "nld.prefixes"
+
+type link struct {
+	name string
+	up   bool
+	mac  string
+	mtu  uint32
+	// name of the enslaving VRF device, if any
+	vrf string
+	// alias of the interface, e.g. the port description set by portd
+	alias string
+	// by address family, rtnl.AF_INET or rtnl.AF_INET6
+	addrs  map[uint8]map[string]struct{}
+	routes map[uint8]map[route]struct{}
+	neighs map[uint8]map[string]neigh
+	// field values of the last sync
+	published map[string]string
+}
+
+type route struct {
+	dst, gw string
+}
+
+// vrfDev is a VRF device and the unicast routes of its table
+type vrfDev struct {
+	name      string
+	table     uint32
+	routes    map[uint8]map[route]struct{}
+	published map[string]string
+}
+
+type neigh struct {
+	lladdr string
+	state  uint16
+}
+
+// entries below this state are unresolved
+const nudValid = rtnl.NUD_REACHABLE | rtnl.NUD_STALE | rtnl.NUD_DELAY |
+	rtnl.NUD_PROBE | rtnl.NUD_PERMANENT
+
+var families = []struct {
+	af   uint8
+	name string
+}{
+	{rtnl.AF_INET, "inet"},
+	{rtnl.AF_INET6, "inet6"},
+}
+
+func (*Command) String() string { return "nld" }
+
+func (*Command) Usage() string { return "nld" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "netlink publisher daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Subscribe to the link, IPv4 and IPv6 address, route, and neighbor
+	groups of rtnetlink then publish the state of each matching
+	interface as,
+		IFNAME.link: up|down
+		IFNAME.mac: XX:XX:XX:XX:XX:XX
+		IFNAME.mtu: BYTES
+		IFNAME.description: ALIAS
+		IFNAME.inet.address: ADDRESS/PREFIXLEN...
+		IFNAME.inet.gateway: ADDRESS...
+		IFNAME.inet.route: PREFIX[@GATEWAY]...
+		IFNAME.inet.neighbor: ADDRESS@LLADDR...
+		IFNAME.inet6.address: ADDRESS/PREFIXLEN...
+		IFNAME.inet6.gateway: ADDRESS...
+		IFNAME.inet6.route: PREFIX[@GATEWAY]...
+		IFNAME.inet6.neighbor: ADDRESS@LLADDR...
+		IFNAME.inet.static-neighbor: ADDRESS@LLADDR...
+		IFNAME.inet6.static-neighbor: ADDRESS@LLADDR...
+		IFNAME.vrf: NAME
+
+	The gateways are those of the interface's default routes; the routes
+	are the unicast entries of the main table, less IPv6 link-local; and
+	the neighbors are the resolved ARP and ND entries, of which the
+	static neighbors are those that are permanent.
+	nld deletes the fields of removed state and interfaces.
+
+	nld also publishes the table and unicast routes of each VRF device,
+	regardless of the prefixes, as,
+		vrf.NAME.table: TABLE
+		vrf.NAME.inet.route: PREFIX[@GATEWAY]...
+		vrf.NAME.inet6.route: PREFIX[@GATEWAY]...
+	with IFNAME.vrf of its enslaved interfaces.
+
+	The published interfaces are those with a name that has one of the
+	space separated prefixes of nld.prefixes; or all but lo if empty.
+	Set these at runtime with,
+		hset platina nld.prefixes "eth- xeth"
+
+	nld also publishes each neighbor change as,
+		IFNAME.neighbor.event: add|delete|stale ADDRESS[@LLADDR]
+
+	So, to follow the neighbor events of the management port,
+		redis-cli subscribe $(hostname) | grep eth0.neighbor
+
+SIGNALS
+	SIGHUP	re-read nld.prefixes of the machine configuration, e.g.
+		goes daemon reload nld
+
+FILES
+	/etc/goes/machine.yaml
+		nld:
+		  prefixes: [eth-, xeth]
+
+SEE ALSO
+	ip, vrf`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+// Reload re-reads nld.prefixes of the machine configuration, e.g. after
+// `daemon reload nld`.
+func (c *Command) Reload() error {
+	cfg, err := machine.Load(machine.EtcGoesMachine)
+	if os.IsNotExist(err) {
+		cfg, err = &machine.Config{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	c.init()
+	select {
+	case c.prefixes <- cfg.Strings(prefixesField, c.defaults):
+	case <-goes.Stop:
+	}
+	return nil
+}
+
+// init the prefixes channel before its first use by either Main or a
+// Reload of an early SIGHUP.
+func (c *Command) init() {
+	c.initOnce.Do(func() {
+		c.defaults = c.Prefixes
+		c.prefixes = make(chan []string, 1)
+	})
+}
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	c.init()
+	c.Prefixes = machine.Default().Strings(prefixesField, c.Prefixes)
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	rpc.Register(&Nld{c.prefixes})
+	srvr, err := atsock.NewRpcServer("nld")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", prefixesField)
+	if err = redis.Assign(key, "nld", "Nld"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+	c.pub.Print(prefixesField, ": ", strings.Join(c.Prefixes, " "))
+
+	// subscribe before the dump to not miss intervening changes
+	sub, err := nl.NewSock(nl.NETLINK_ROUTE, 16,
+		rtnl.RTNLGRP_LINK.Bit()|
+			rtnl.RTNLGRP_IPV4_IFADDR.Bit()|
+			rtnl.RTNLGRP_IPV6_IFADDR.Bit()|
+			rtnl.RTNLGRP_IPV4_ROUTE.Bit()|
+			rtnl.RTNLGRP_IPV6_ROUTE.Bit()|
+			rtnl.RTNLGRP_NEIGH.Bit())
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	c.links = make(map[int32]*link)
+	c.vrfs = make(map[int32]*vrfDev)
+	if err = c.dump(); err != nil {
+		return err
+	}
+	c.dumped = true
+	c.syncAll()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case prefixes := <-c.prefixes:
+			if err = c.refilter(prefixes); err != nil {
+				return err
+			}
+		case b, opened := <-sub.RxCh:
+			if !opened {
+				return sub.Err
+			}
+			synced := make(map[*link]struct{})
+			for len(b) >= nl.SizeofHdr {
+				var msg []byte
+				if msg, b, err = nl.Pop(b); err != nil {
+					log.Print("daemon", "err", err)
+					break
+				}
+				if l := c.handle(msg); l != nil {
+					synced[l] = struct{}{}
+				}
+			}
+			for l := range synced {
+				c.sync(l)
+			}
+			for _, v := range c.vrfs {
+				c.publish(v.fields(), v.published)
+			}
+		}
+	}
+}
+
+// refilter deletes the published fields of all links then dumps those
+// matching the new prefixes.
+func (c *Command) refilter(prefixes []string) error {
+	for _, l := range c.links {
+		c.pub.Print("delete: ", l.name, ".")
+	}
+	for _, v := range c.vrfs {
+		c.pub.Print("delete: vrf.", v.name, ".")
+	}
+	c.Prefixes = prefixes
+	c.links = make(map[int32]*link)
+	c.vrfs = make(map[int32]*vrfDev)
+	c.dumped = false
+	if err := c.dump(); err != nil {
+		return err
+	}
+	c.dumped = true
+	c.syncAll()
+	log.Print("daemon", "info", prefixesField, ": ",
+		strings.Join(prefixes, " "))
+	c.pub.Print(prefixesField, ": ", strings.Join(prefixes, " "))
+	return nil
+}
+
+func (c *Command) dump() error {
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	sr := nl.NewSockReceiver(sock)
+	handle := func(b []byte) { c.handle(b) }
+
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_GETLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+	})
+	if err != nil {
+		return err
+	}
+	if err = sr.UntilDone(req, handle); err != nil {
+		return fmt.Errorf("link dump: %v", err)
+	}
+	for _, t := range []struct {
+		msg  uint16
+		name string
+	}{
+		{rtnl.RTM_GETADDR, "address"},
+		{rtnl.RTM_GETROUTE, "route"},
+		{rtnl.RTM_GETNEIGH, "neighbor"},
+	} {
+		for _, f := range families {
+			req, err := nl.NewMessage(nl.Hdr{
+				Type:  t.msg,
+				Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+			}, rtnl.RtGenMsg{
+				Family: f.af,
+			})
+			if err != nil {
+				return err
+			}
+			if err = sr.UntilDone(req, handle); err != nil {
+				return fmt.Errorf("%s %s dump: %v", f.name,
+					t.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// handle the rtnetlink message and return the changed link, if any.
+func (c *Command) handle(b []byte) *link {
+	switch nl.HdrPtr(b).Type {
+	case rtnl.RTM_NEWLINK:
+		return c.newLink(b)
+	case rtnl.RTM_DELLINK:
+		c.delLink(b)
+	case rtnl.RTM_NEWADDR, rtnl.RTM_DELADDR:
+		return c.addr(b)
+	case rtnl.RTM_NEWROUTE, rtnl.RTM_DELROUTE:
+		return c.route(b)
+	case rtnl.RTM_NEWNEIGH, rtnl.RTM_DELNEIGH:
+		return c.neigh(b)
+	}
+	return nil
+}
+
+func (c *Command) newLink(b []byte) *link {
+	var ifla rtnl.Ifla
+	msg := rtnl.IfInfoMsgPtr(b)
+	if msg == nil {
+		return nil
+	}
+	ifla.Write(b)
+	name := nl.Kstring(ifla[rtnl.IFLA_IFNAME])
+	table, isVrf := vrf.Table(ifla[rtnl.IFLA_LINKINFO])
+	if v, found := c.vrfs[msg.Index]; isVrf && !found {
+		c.vrfs[msg.Index] = &vrfDev{
+			name:  name,
+			table: table,
+			routes: map[uint8]map[route]struct{}{
+				rtnl.AF_INET:  make(map[route]struct{}),
+				rtnl.AF_INET6: make(map[route]struct{}),
+			},
+			published: make(map[string]string),
+		}
+	} else if found && v.name != name {
+		c.pub.Print("delete: vrf.", v.name, ".")
+		v.name = name
+		v.published = make(map[string]string)
+	}
+	l, found := c.links[msg.Index]
+	if found && l.name != name {
+		// renamed
+		c.pub.Print("delete: ", l.name, ".")
+		l.name = name
+		l.published = make(map[string]string)
+	} else if !found {
+		if !c.match(name) {
+			return nil
+		}
+		l = &link{
+			name:      name,
+			addrs:     make(map[uint8]map[string]struct{}),
+			routes:    make(map[uint8]map[route]struct{}),
+			neighs:    make(map[uint8]map[string]neigh),
+			published: make(map[string]string),
+		}
+		for _, f := range families {
+			l.addrs[f.af] = make(map[string]struct{})
+			l.routes[f.af] = make(map[route]struct{})
+			l.neighs[f.af] = make(map[string]neigh)
+		}
+		c.links[msg.Index] = l
+	}
+	wasUp := l.up
+	if val := ifla[rtnl.IFLA_OPERSTATE]; len(val) > 0 {
+		l.up = nl.Uint8(val) == rtnl.IF_OPER_UP
+	} else {
+		l.up = msg.Flags&rtnl.IFF_UP == rtnl.IFF_UP
+	}
+	if found && c.dumped && l.up != wasUp {
+		if l.up {
+			event.Post("link.up", "note", l.name+" up",
+				"link", l.name)
+		} else {
+			event.Post("link.down", "warn", l.name+" down",
+				"link", l.name)
+		}
+	}
+	if val := ifla[rtnl.IFLA_ADDRESS]; len(val) > 0 {
+		l.mac = net.HardwareAddr(val).String()
+	}
+	if val := ifla[rtnl.IFLA_MTU]; len(val) > 0 {
+		l.mtu = nl.Uint32(val)
+	}
+	l.alias = nl.Kstring(ifla[rtnl.IFLA_IFALIAS])
+	l.vrf = ""
+	if val := ifla[rtnl.IFLA_MASTER]; len(val) > 0 {
+		if v, found := c.vrfs[nl.Int32(val)]; found {
+			l.vrf = v.name
+		}
+	}
+	return l
+}
+
+func (c *Command) delLink(b []byte) {
+	msg := rtnl.IfInfoMsgPtr(b)
+	if msg == nil {
+		return
+	}
+	if l, found := c.links[msg.Index]; found {
+		delete(c.links, msg.Index)
+		c.pub.Print("delete: ", l.name, ".")
+	}
+	if v, found := c.vrfs[msg.Index]; found {
+		delete(c.vrfs, msg.Index)
+		c.pub.Print("delete: vrf.", v.name, ".")
+	}
+}
+
+func (c *Command) addr(b []byte) *link {
+	var ifa rtnl.Ifa
+	msg := rtnl.IfAddrMsgPtr(b)
+	if msg == nil {
+		return nil
+	}
+	l, found := c.links[int32(msg.Index)]
+	if !found {
+		return nil
+	}
+	addrs, found := l.addrs[msg.Family]
+	if !found {
+		return nil
+	}
+	ifa.Write(b)
+	// IFA_ADDRESS is the peer of point-to-point IPv4 interfaces
+	val := ifa[rtnl.IFA_LOCAL]
+	if len(val) == 0 {
+		val = ifa[rtnl.IFA_ADDRESS]
+	}
+	if len(val) == 0 {
+		return nil
+	}
+	a := fmt.Sprint(net.IP(val), "/", msg.Prefixlen)
+	if nl.HdrPtr(b).Type == rtnl.RTM_NEWADDR {
+		addrs[a] = struct{}{}
+	} else {
+		delete(addrs, a)
+	}
+	return l
+}
+
+func (c *Command) route(b []byte) *link {
+	var rta rtnl.Rta
+	msg := rtnl.RtMsgPtr(b)
+	if msg == nil || msg.Type != rtnl.RTN_UNICAST {
+		return nil
+	}
+	rta.Write(b)
+	table := uint32(msg.Table)
+	if val := rta[rtnl.RTA_TABLE]; len(val) > 0 {
+		table = nl.Uint32(val)
+	}
+	dst := "default"
+	if val := rta[rtnl.RTA_DST]; len(val) > 0 {
+		ip := net.IP(val)
+		if ip.IsLinkLocalUnicast() {
+			return nil
+		}
+		dst = fmt.Sprint(ip, "/", msg.Dst_len)
+	}
+	add := nl.HdrPtr(b).Type == rtnl.RTM_NEWROUTE
+	if table != rtnl.RT_TABLE_MAIN {
+		for _, v := range c.vrfs {
+			routes, found := v.routes[msg.Family]
+			if !found || v.table != table {
+				continue
+			}
+			for _, hop := range nexthops(rta) {
+				r := route{dst: dst}
+				if len(hop.gw) > 0 {
+					r.gw = net.IP(hop.gw).String()
+				}
+				if add {
+					routes[r] = struct{}{}
+				} else {
+					delete(routes, r)
+				}
+			}
+		}
+		return nil
+	}
+	var l *link
+	for _, hop := range nexthops(rta) {
+		hl, found := c.links[hop.index]
+		if !found {
+			continue
+		}
+		routes, found := hl.routes[msg.Family]
+		if !found {
+			continue
+		}
+		r := route{dst: dst}
+		if len(hop.gw) > 0 {
+			r.gw = net.IP(hop.gw).String()
+		}
+		if add {
+			routes[r] = struct{}{}
+		} else {
+			delete(routes, r)
+		}
+		if l == nil {
+			l = hl
+		} else if l != hl {
+			// multipath among links
+			c.sync(hl)
+		}
+	}
+	return l
+}
+
+// neigh updates the link's neighbors and publishes the add, delete, or
+// stale event, if any, of the entry.
+func (c *Command) neigh(b []byte) *link {
+	var nda rtnl.Nda
+	msg := rtnl.NdMsgPtr(b)
+	if msg == nil || msg.State&rtnl.NUD_NOARP != 0 {
+		return nil
+	}
+	l, found := c.links[msg.Index]
+	if !found {
+		return nil
+	}
+	neighs, found := l.neighs[msg.Family]
+	if !found {
+		return nil
+	}
+	nda.Write(b)
+	val := nda[rtnl.NDA_DST]
+	if len(val) == 0 {
+		return nil
+	}
+	addr := net.IP(val).String()
+	prev, was := neighs[addr]
+	n := neigh{state: msg.State}
+	if val = nda[rtnl.NDA_LLADDR]; len(val) > 0 {
+		n.lladdr = net.HardwareAddr(val).String()
+	} else {
+		n.lladdr = prev.lladdr
+	}
+	var event string
+	switch {
+	case nl.HdrPtr(b).Type == rtnl.RTM_DELNEIGH ||
+		n.state&nudValid == 0 || len(n.lladdr) == 0:
+		if !was {
+			return nil
+		}
+		delete(neighs, addr)
+		event = "delete"
+	case !was || prev.lladdr != n.lladdr:
+		neighs[addr] = n
+		event = "add"
+	case n.state&rtnl.NUD_STALE != 0 && prev.state&rtnl.NUD_STALE == 0:
+		neighs[addr] = n
+		event = "stale"
+	default:
+		neighs[addr] = n
+		return nil
+	}
+	if len(n.lladdr) > 0 {
+		addr += "@" + n.lladdr
+	}
+	if c.dumped {
+		c.pub.Print(l.name, ".neighbor.event: ", event, " ", addr)
+	}
+	return l
+}
+
+type nexthop struct {
+	index int32
+	gw    []byte
+}
+
+// nexthops returns the output interface and gateway of each RTA_MULTIPATH
+// struct rtnexthop or that of RTA_OIF and RTA_GATEWAY.
+func nexthops(rta rtnl.Rta) []nexthop {
+	mp := rta[rtnl.RTA_MULTIPATH]
+	if len(mp) == 0 {
+		return []nexthop{{
+			index: nl.Int32(rta[rtnl.RTA_OIF]),
+			gw:    rta[rtnl.RTA_GATEWAY],
+		}}
+	}
+	var hops []nexthop
+	for len(mp) >= sizeofRtNexthop {
+		rtnh := (*rtNexthop)(unsafe.Pointer(&mp[0]))
+		n := int(rtnh.Len)
+		if n < sizeofRtNexthop || n > len(mp) {
+			break
+		}
+		hop := nexthop{index: rtnh.Ifindex}
+		for attrs := mp[sizeofRtNexthop:n]; len(attrs) >= 4; {
+			alen := int(nl.Uint16(attrs))
+			if alen < 4 || alen > len(attrs) {
+				break
+			}
+			if nl.Uint16(attrs[2:]) == rtnl.RTA_GATEWAY {
+				hop.gw = attrs[4:alen]
+			}
+			if alen = nl.NLATTR.Align(alen); alen > len(attrs) {
+				break
+			}
+			attrs = attrs[alen:]
+		}
+		hops = append(hops, hop)
+		if n = nl.NLMSG.Align(n); n > len(mp) {
+			break
+		}
+		mp = mp[n:]
+	}
+	return hops
+}
+
+// linux/rtnetlink.h: struct rtnexthop
+type rtNexthop struct {
+	Len     uint16
+	Flags   uint8
+	Hops    uint8
+	Ifindex int32
+}
+
+const sizeofRtNexthop = 8
+
+// match returns true if the name has one of the configured prefixes.
+func (c *Command) match(name string) bool {
+	if len(c.Prefixes) == 0 {
+		return name != "lo"
+	}
+	for _, prefix := range c.Prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sync publishes the changed fields of the link and deletes those removed.
+func (c *Command) sync(l *link) {
+	c.publish(l.fields(), l.published)
+}
+
+func (c *Command) syncAll() {
+	for _, l := range c.links {
+		c.sync(l)
+	}
+	for _, v := range c.vrfs {
+		c.publish(v.fields(), v.published)
+	}
+}
+
+// publish the fields that differ from those published and delete those
+// removed.
+func (c *Command) publish(fields, published map[string]string) {
+	for k, v := range fields {
+		if pv, found := published[k]; !found || pv != v {
+			c.pub.Print(k, ": ", v)
+			published[k] = v
+		}
+	}
+	for k := range published {
+		if _, found := fields[k]; !found {
+			c.pub.Print("delete: ", k)
+			delete(published, k)
+		}
+	}
+}
+
+func (l *link) fields() map[string]string {
+	m := make(map[string]string)
+	prefix := l.name + "."
+	if l.up {
+		m[prefix+"link"] = "up"
+	} else {
+		m[prefix+"link"] = "down"
+	}
+	if len(l.mac) > 0 {
+		m[prefix+"mac"] = l.mac
+	}
+	if l.mtu > 0 {
+		m[prefix+"mtu"] = fmt.Sprint(l.mtu)
+	}
+	if len(l.alias) > 0 {
+		m[prefix+"description"] = l.alias
+	}
+	if len(l.vrf) > 0 {
+		m[prefix+"vrf"] = l.vrf
+	}
+	for _, f := range families {
+		var addrs, gws, routes, neighs, statics []string
+		for a := range l.addrs[f.af] {
+			addrs = append(addrs, a)
+		}
+		for a, n := range l.neighs[f.af] {
+			neighs = append(neighs, a+"@"+n.lladdr)
+			if n.state&rtnl.NUD_PERMANENT != 0 {
+				statics = append(statics, a+"@"+n.lladdr)
+			}
+		}
+		for r := range l.routes[f.af] {
+			if len(r.gw) == 0 {
+				routes = append(routes, r.dst)
+				continue
+			}
+			if r.dst == "default" {
+				gws = append(gws, r.gw)
+			}
+			routes = append(routes, r.dst+"@"+r.gw)
+		}
+		for _, x := range []struct {
+			name string
+			list []string
+		}{
+			{"address", addrs},
+			{"gateway", gws},
+			{"route", routes},
+			{"neighbor", neighs},
+			{"static-neighbor", statics},
+		} {
+			if len(x.list) > 0 {
+				sort.Strings(x.list)
+				m[prefix+f.name+"."+x.name] = strings.Join(x.list,
+					" ")
+			}
+		}
+	}
+	return m
+}
+
+func (v *vrfDev) fields() map[string]string {
+	m := make(map[string]string)
+	prefix := "vrf." + v.name + "."
+	m[prefix+"table"] = fmt.Sprint(v.table)
+	for _, f := range families {
+		var routes []string
+		for r := range v.routes[f.af] {
+			if len(r.gw) > 0 {
+				routes = append(routes, r.dst+"@"+r.gw)
+			} else {
+				routes = append(routes, r.dst)
+			}
+		}
+		if len(routes) > 0 {
+			sort.Strings(routes)
+			m[prefix+f.name+".route"] = strings.Join(routes, " ")
+		}
+	}
+	return m
+}
+
+func (nld *Nld) Hset(args args.Hset, reply *reply.Hset) error {
+	nld.prefixes <- strings.FieldsFunc(string(args.Value), func(r rune) bool {
+		return r == ' ' || r == ','
+	})
+	*reply = 1
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package nld
+
+import (
+	"net"
+	"testing"
+
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+func TestNexthops(t *testing.T) {
+	gw := net.ParseIP("10.0.0.1").To4()
+	mp := []byte{
+		// rtnexthop, ifindex 3, RTA_GATEWAY 10.0.0.1
+		16, 0, 0, 0, 3, 0, 0, 0,
+		8, 0, byte(rtnl.RTA_GATEWAY), 0, gw[0], gw[1], gw[2], gw[3],
+		// rtnexthop, ifindex 4, no gateway
+		8, 0, 0, 0, 4, 0, 0, 0,
+	}
+	var rta rtnl.Rta
+	rta[rtnl.RTA_MULTIPATH] = mp
+	hops := nexthops(rta)
+	if len(hops) != 2 {
+		t.Fatalf("%d hops", len(hops))
+	}
+	if hops[0].index != 3 || !net.IP(hops[0].gw).Equal(gw) {
+		t.Error("hop 0:", hops[0].index, net.IP(hops[0].gw))
+	}
+	if hops[1].index != 4 || len(hops[1].gw) != 0 {
+		t.Error("hop 1:", hops[1].index, net.IP(hops[1].gw))
+	}
+}
+
+func TestFields(t *testing.T) {
+	l := &link{
+		name:  "eth0",
+		up:    true,
+		mtu:   1500,
+		alias: "uplink to spine2",
+		addrs: map[uint8]map[string]struct{}{
+			rtnl.AF_INET6: {
+				"2001:db8::2/64": {},
+				"2001:db8::1/64": {},
+			},
+		},
+		routes: map[uint8]map[route]struct{}{
+			rtnl.AF_INET: {
+				{dst: "default", gw: "10.0.0.1"}: {},
+				{dst: "10.0.0.0/24"}:             {},
+			},
+		},
+		neighs: map[uint8]map[string]neigh{
+			rtnl.AF_INET: {
+				"10.0.0.1": {"02:00:00:00:00:01", rtnl.NUD_STALE},
+				"10.0.0.2": {"02:00:00:00:00:02",
+					rtnl.NUD_PERMANENT},
+			},
+		},
+	}
+	m := l.fields()
+	for k, v := range map[string]string{
+		"eth0.link":          "up",
+		"eth0.mtu":           "1500",
+		"eth0.description":   "uplink to spine2",
+		"eth0.inet6.address": "2001:db8::1/64 2001:db8::2/64",
+		"eth0.inet.gateway":  "10.0.0.1",
+		"eth0.inet.route":    "10.0.0.0/24 default@10.0.0.1",
+		"eth0.inet.neighbor": "10.0.0.1@02:00:00:00:00:01 " +
+			"10.0.0.2@02:00:00:00:00:02",
+		"eth0.inet.static-neighbor": "10.0.0.2@02:00:00:00:00:02",
+	} {
+		if m[k] != v {
+			t.Errorf("%s: %q vs. %q", k, m[k], v)
+		}
+	}
+	if len(m) != 8 {
+		t.Error("unexpected:", m)
+	}
+}
+
+func TestVrf(t *testing.T) {
+	v := &vrfDev{
+		name:  "mgmt",
+		table: 10,
+		routes: map[uint8]map[route]struct{}{
+			rtnl.AF_INET: {
+				{dst: "default", gw: "192.168.0.1"}: {},
+				{dst: "192.168.0.0/24"}:             {},
+			},
+		},
+	}
+	m := v.fields()
+	for k, s := range map[string]string{
+		"vrf.mgmt.table":      "10",
+		"vrf.mgmt.inet.route": "192.168.0.0/24 default@192.168.0.1",
+	} {
+		if m[k] != s {
+			t.Errorf("%s: %q vs. %q", k, m[k], s)
+		}
+	}
+	if len(m) != 2 {
+		t.Error("unexpected:", m)
+	}
+}
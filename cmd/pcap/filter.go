@@ -0,0 +1,421 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// link types of the pcapng interface description
+const (
+	LinkTypeEthernet = 1
+	LinkTypeRaw      = 101
+)
+
+const (
+	etherTypeIp4  = 0x0800
+	etherTypeArp  = 0x0806
+	etherTypeVlan = 0x8100
+	etherTypeQinQ = 0x88a8
+	etherTypeIp6  = 0x86dd
+	etherTypeLacp = 0x8809
+	etherTypeLldp = 0x88cc
+
+	protoIcmp  = 1
+	protoTcp   = 6
+	protoUdp   = 17
+	protoIcmp6 = 58
+)
+
+// Packet is the decoded headers of a captured frame.
+type Packet struct {
+	Len       int
+	Ether     bool
+	Src, Dst  net.HardwareAddr
+	EtherType uint16
+	Vlans     []uint16
+	// IP is true if SrcIP and DstIP are that of an IPv4 or IPv6 header
+	IP           bool
+	SrcIP, DstIP net.IP
+	Proto        uint8
+	// Ports is true if SrcPort and DstPort are that of TCP or UDP
+	Ports            bool
+	SrcPort, DstPort uint16
+}
+
+// Decode the headers of a frame of the given link type.
+func Decode(linkType int, b []byte, length int) *Packet {
+	p := &Packet{Len: length}
+	if linkType == LinkTypeEthernet {
+		if len(b) < 14 {
+			return p
+		}
+		p.Ether = true
+		p.Dst = net.HardwareAddr(b[0:6])
+		p.Src = net.HardwareAddr(b[6:12])
+		p.EtherType = binary.BigEndian.Uint16(b[12:14])
+		b = b[14:]
+		for (p.EtherType == etherTypeVlan || p.EtherType == etherTypeQinQ) &&
+			len(b) >= 4 {
+			p.Vlans = append(p.Vlans, binary.BigEndian.Uint16(b)&0xfff)
+			p.EtherType = binary.BigEndian.Uint16(b[2:4])
+			b = b[4:]
+		}
+	} else if len(b) > 0 {
+		switch b[0] >> 4 {
+		case 4:
+			p.EtherType = etherTypeIp4
+		case 6:
+			p.EtherType = etherTypeIp6
+		}
+	}
+	switch p.EtherType {
+	case etherTypeIp4:
+		if len(b) < 20 {
+			return p
+		}
+		ihl := int(b[0]&0xf) * 4
+		p.IP = true
+		p.Proto = b[9]
+		p.SrcIP = net.IP(b[12:16])
+		p.DstIP = net.IP(b[16:20])
+		// only the first fragment has the ports
+		if binary.BigEndian.Uint16(b[6:8])&0x1fff != 0 || len(b) < ihl {
+			return p
+		}
+		b = b[ihl:]
+	case etherTypeIp6:
+		if len(b) < 40 {
+			return p
+		}
+		p.IP = true
+		p.Proto = b[6]
+		p.SrcIP = net.IP(b[8:24])
+		p.DstIP = net.IP(b[24:40])
+		b = b[40:]
+	default:
+		return p
+	}
+	if (p.Proto == protoTcp || p.Proto == protoUdp) && len(b) >= 4 {
+		p.Ports = true
+		p.SrcPort = binary.BigEndian.Uint16(b[0:2])
+		p.DstPort = binary.BigEndian.Uint16(b[2:4])
+	}
+	return p
+}
+
+// String summarizes the packet, e.g. "10.0.0.1.5000 > 10.0.0.2.80 tcp 74".
+func (p *Packet) String() string {
+	var s []string
+	if p.Ether {
+		s = append(s, fmt.Sprint(p.Src, " > ", p.Dst))
+		for _, vid := range p.Vlans {
+			s = append(s, fmt.Sprint("vlan ", vid))
+		}
+	}
+	if !p.IP {
+		s = append(s, etherTypeName(p.EtherType))
+		return strings.Join(append(s, strconv.Itoa(p.Len)), " ")
+	}
+	src, dst := p.SrcIP.String(), p.DstIP.String()
+	if p.Ports {
+		src += fmt.Sprint(".", p.SrcPort)
+		dst += fmt.Sprint(".", p.DstPort)
+	}
+	s = append(s, src, ">", dst, protoName(p.Proto), strconv.Itoa(p.Len))
+	return strings.Join(s, " ")
+}
+
+func etherTypeName(t uint16) string {
+	switch t {
+	case etherTypeArp:
+		return "arp"
+	case etherTypeLacp:
+		return "lacp"
+	case etherTypeLldp:
+		return "lldp"
+	}
+	return fmt.Sprintf("ethertype 0x%04x", t)
+}
+
+func protoName(proto uint8) string {
+	switch proto {
+	case protoIcmp:
+		return "icmp"
+	case protoTcp:
+		return "tcp"
+	case protoUdp:
+		return "udp"
+	case protoIcmp6:
+		return "icmp6"
+	}
+	return fmt.Sprint("proto ", proto)
+}
+
+// Filter returns true for packets to capture.
+type Filter func(*Packet) bool
+
+// ParseFilter of a pcap-filter(7) style expression of these primitives,
+//
+//	ether|arp|ip|ip6|tcp|udp|icmp|icmp6|lldp|lacp
+//	vlan [VID]
+//	ether host|src|dst MAC
+//	[src|dst] host ADDRESS
+//	[src|dst] net PREFIX
+//	[src|dst] port PORT
+//	proto NUMBER
+//	less|greater LENGTH
+//
+// combined with not (!), and (&&), or (||), and parentheses. The
+// expression may be a single argument or split among several.
+func ParseFilter(args ...string) (Filter, error) {
+	var tokens []string
+	for _, arg := range args {
+		arg = strings.NewReplacer("(", " ( ", ")", " ) ", "!", " ! ",
+			"&&", " && ", "||", " || ").Replace(arg)
+		tokens = append(tokens, strings.Fields(arg)...)
+	}
+	if len(tokens) == 0 {
+		return func(*Packet) bool { return true }, nil
+	}
+	fp := &filterParser{tokens: tokens}
+	f, err := fp.or()
+	if err == nil && len(fp.tokens) > 0 {
+		err = fmt.Errorf("%q: unexpected", fp.tokens[0])
+	}
+	return f, err
+}
+
+type filterParser struct {
+	tokens []string
+}
+
+func (fp *filterParser) peek() string {
+	if len(fp.tokens) == 0 {
+		return ""
+	}
+	return fp.tokens[0]
+}
+
+func (fp *filterParser) next() (string, error) {
+	if len(fp.tokens) == 0 {
+		return "", fmt.Errorf("incomplete filter")
+	}
+	s := fp.tokens[0]
+	fp.tokens = fp.tokens[1:]
+	return s, nil
+}
+
+func (fp *filterParser) or() (Filter, error) {
+	f, err := fp.and()
+	for err == nil && (fp.peek() == "or" || fp.peek() == "||") {
+		fp.next()
+		var g Filter
+		if g, err = fp.and(); err == nil {
+			f = func(f, g Filter) Filter {
+				return func(p *Packet) bool { return f(p) || g(p) }
+			}(f, g)
+		}
+	}
+	return f, err
+}
+
+// and of the unary expressions; like pcap-filter, juxtaposition is an
+// implicit and, e.g. "udp port 53".
+func (fp *filterParser) and() (Filter, error) {
+	f, err := fp.unary()
+	for err == nil {
+		switch fp.peek() {
+		case "and", "&&":
+			fp.next()
+		case "", "or", "||", ")":
+			return f, nil
+		}
+		var g Filter
+		if g, err = fp.unary(); err == nil {
+			f = func(f, g Filter) Filter {
+				return func(p *Packet) bool { return f(p) && g(p) }
+			}(f, g)
+		}
+	}
+	return f, err
+}
+
+func (fp *filterParser) unary() (Filter, error) {
+	switch fp.peek() {
+	case "not", "!":
+		fp.next()
+		f, err := fp.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(p *Packet) bool { return !f(p) }, nil
+	case "(":
+		fp.next()
+		f, err := fp.or()
+		if err != nil {
+			return nil, err
+		}
+		if s, err := fp.next(); err != nil || s != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return f, nil
+	}
+	return fp.primitive()
+}
+
+func (fp *filterParser) primitive() (Filter, error) {
+	s, err := fp.next()
+	if err != nil {
+		return nil, err
+	}
+	dir := ""
+	if s == "src" || s == "dst" {
+		dir = s
+		if s, err = fp.next(); err != nil {
+			return nil, err
+		}
+	}
+	either := func(src, dst func(*Packet) bool) Filter {
+		switch dir {
+		case "src":
+			return src
+		case "dst":
+			return dst
+		}
+		return func(p *Packet) bool { return src(p) || dst(p) }
+	}
+	number := func(max uint64) (uint64, error) {
+		arg, err := fp.next()
+		if err != nil {
+			return 0, err
+		}
+		u, err := strconv.ParseUint(arg, 0, 64)
+		if err != nil || u > max {
+			return 0, fmt.Errorf("%s %q: invalid", s, arg)
+		}
+		return u, nil
+	}
+	if len(dir) > 0 && s != "host" && s != "net" && s != "port" {
+		return nil, fmt.Errorf("%s %s: invalid", dir, s)
+	}
+	switch s {
+	case "ether":
+		switch fp.peek() {
+		case "host", "src", "dst":
+			dir, _ = fp.next()
+			arg, err := fp.next()
+			if err != nil {
+				return nil, err
+			}
+			mac, err := net.ParseMAC(arg)
+			if err != nil {
+				return nil, fmt.Errorf("ether %s %q: invalid", dir, arg)
+			}
+			if dir == "host" {
+				dir = ""
+			}
+			return either(func(p *Packet) bool {
+				return p.Ether && p.Src.String() == mac.String()
+			}, func(p *Packet) bool {
+				return p.Ether && p.Dst.String() == mac.String()
+			}), nil
+		}
+		return func(p *Packet) bool { return p.Ether }, nil
+	case "arp", "lldp", "lacp", "ip", "ip6":
+		t := map[string]uint16{
+			"arp":  etherTypeArp,
+			"lldp": etherTypeLldp,
+			"lacp": etherTypeLacp,
+			"ip":   etherTypeIp4,
+			"ip6":  etherTypeIp6,
+		}[s]
+		return func(p *Packet) bool { return p.EtherType == t }, nil
+	case "tcp", "udp", "icmp", "icmp6":
+		proto := map[string]uint8{
+			"tcp":   protoTcp,
+			"udp":   protoUdp,
+			"icmp":  protoIcmp,
+			"icmp6": protoIcmp6,
+		}[s]
+		return func(p *Packet) bool { return p.IP && p.Proto == proto },
+			nil
+	case "proto":
+		u, err := number(255)
+		if err != nil {
+			return nil, err
+		}
+		return func(p *Packet) bool {
+			return p.IP && p.Proto == uint8(u)
+		}, nil
+	case "vlan":
+		if _, err := strconv.ParseUint(fp.peek(), 0, 12); err != nil {
+			return func(p *Packet) bool { return len(p.Vlans) > 0 },
+				nil
+		}
+		u, _ := number(4095)
+		return func(p *Packet) bool {
+			for _, vid := range p.Vlans {
+				if vid == uint16(u) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	case "host":
+		arg, err := fp.next()
+		if err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(arg)
+		if ip == nil {
+			return nil, fmt.Errorf("host %q: invalid", arg)
+		}
+		return either(func(p *Packet) bool {
+			return p.IP && p.SrcIP.Equal(ip)
+		}, func(p *Packet) bool {
+			return p.IP && p.DstIP.Equal(ip)
+		}), nil
+	case "net":
+		arg, err := fp.next()
+		if err != nil {
+			return nil, err
+		}
+		_, ipnet, err := net.ParseCIDR(arg)
+		if err != nil {
+			return nil, fmt.Errorf("net %q: invalid", arg)
+		}
+		return either(func(p *Packet) bool {
+			return p.IP && ipnet.Contains(p.SrcIP)
+		}, func(p *Packet) bool {
+			return p.IP && ipnet.Contains(p.DstIP)
+		}), nil
+	case "port":
+		u, err := number(65535)
+		if err != nil {
+			return nil, err
+		}
+		port := uint16(u)
+		return either(func(p *Packet) bool {
+			return p.Ports && p.SrcPort == port
+		}, func(p *Packet) bool {
+			return p.Ports && p.DstPort == port
+		}), nil
+	case "less", "greater":
+		u, err := number(1 << 31)
+		if err != nil {
+			return nil, err
+		}
+		if s == "less" {
+			return func(p *Packet) bool { return p.Len <= int(u) }, nil
+		}
+		return func(p *Packet) bool { return p.Len >= int(u) }, nil
+	}
+	return nil, fmt.Errorf("%q: unknown", s)
+}
@@ -0,0 +1,82 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcap
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// 802.1Q VLAN 10 UDP 10.0.0.1:5000 > 10.0.0.2:53
+const frame = "020000000002" + "020000000001" + "8100000a" + "0800" +
+	"4500001c000000004011000a0a0000010a000002" + "1388003500080000"
+
+func TestFilter(t *testing.T) {
+	b, _ := hex.DecodeString(frame)
+	p := Decode(LinkTypeEthernet, b, len(b))
+	if s := p.String(); s != "02:00:00:00:00:01 > 02:00:00:00:00:02 "+
+		"vlan 10 10.0.0.1.5000 > 10.0.0.2.53 udp 46" {
+		t.Errorf("%q", s)
+	}
+	for _, x := range []struct {
+		expr  string
+		match bool
+	}{
+		{"", true},
+		{"udp port 53", true},
+		{"src port 53", false},
+		{"vlan 10 and (host 10.0.0.9 or dst net 10.0.0.0/30)", true},
+		{"not ip", false},
+		{"ether src 02:00:00:00:00:01 && !tcp", true},
+		{"ip6 || arp", false},
+		{"greater 100", false},
+	} {
+		f, err := ParseFilter(x.expr)
+		if err != nil {
+			t.Errorf("%q: %v", x.expr, err)
+		} else if f(p) != x.match {
+			t.Errorf("%q: expected %v", x.expr, x.match)
+		}
+	}
+	for _, expr := range []string{"port", "src tcp", "(udp", "host x",
+		"udp port 53 extra"} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w, err := NewWriter(buf, "eth-1-1", LinkTypeEthernet, 65535)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WritePacket(time.Unix(1, 2), []byte{1, 2, 3}, 60,
+		FlagInbound); err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	if int64(len(b)) != w.N {
+		t.Fatalf("wrote %d of %d", len(b), w.N)
+	}
+	// walk the blocks by their leading and trailing lengths
+	var types []uint32
+	for len(b) > 0 {
+		n := le.Uint32(b[4:])
+		if n%4 != 0 || int(n) > len(b) || le.Uint32(b[n-4:]) != n {
+			t.Fatalf("block %d: bad length %d", len(types), n)
+		}
+		types = append(types, le.Uint32(b))
+		b = b[n:]
+	}
+	if len(types) != 3 || types[0] != blockSectionHeader ||
+		types[1] != blockInterfaceDescription ||
+		types[2] != blockEnhancedPacket {
+		t.Errorf("blocks: %x", types)
+	}
+}
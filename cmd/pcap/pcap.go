@@ -0,0 +1,353 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package pcap provides a command that captures the frames of a vnet or
+// tuntap interface that match a filter expression and either writes these
+// as pcapng or prints a summary of each.
+package pcap
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+const (
+	ethPAll          = 0x0003
+	packetMrPromisc  = 1
+	packetOutgoing   = 4
+	defaultSnaplen   = 262144
+	receiveTimeoutUs = 100000
+)
+
+// struct packet_mreq of linux/if_packet.h
+type packetMreq struct {
+	ifindex int32
+	typ     uint16
+	alen    uint16
+	addr    [8]byte
+}
+
+func (Command) String() string { return "pcap" }
+
+func (Command) Usage() string {
+	return `pcap [-p] [-c COUNT] [-d DURATION] [-C SIZE] [-s SNAPLEN] [-w FILE]
+	IFNAME [FILTER]...`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "capture interface traffic",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Capture the received and transmitted frames of the named interface,
+	e.g. the traffic punted to or from a vnet port or that mirrored to
+	a tuntap, that match the FILTER expression. Without -w, print a
+	summary of each frame; otherwise, write these as pcapng.
+
+	Capture until interrupted or the first of the count, duration, or
+	size limits.
+
+OPTIONS
+	-p	don't put the interface in promiscuous mode
+	-c COUNT
+		stop after COUNT frames
+	-d DURATION
+		stop after seconds, or duration, e.g. 1m
+	-C SIZE
+		stop before writing more than SIZE bytes; with a k, m, or g
+		suffix, this is in KiB, MiB, or GiB
+	-s SNAPLEN
+		capture at most SNAPLEN bytes of each frame, default: 262144
+	-w FILE
+		write pcapng to FILE, or stdout with -, e.g.
+		ssh switch goes pcap -w - eth-1-1 | wireshark -k -i -
+
+FILTER
+	Like pcap-filter(7), these primitives,
+		ether|arp|ip|ip6|tcp|udp|icmp|icmp6|lldp|lacp
+		vlan [VID]
+		ether host|src|dst MAC
+		[src|dst] host ADDRESS
+		[src|dst] net PREFIX
+		[src|dst] port PORT
+		proto NUMBER
+		less|greater LENGTH
+	combined with not (!), and (&&), or (||), and parentheses.
+
+EXAMPLES
+	pcap -c 10 eth-1-1 tcp port 179
+	pcap -d 30s -w /tmp/ospf.pcapng eth-2-1 proto 89`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-c", "-d", "-C", "-s", "-w")
+	flag, args := flags.New(args, "-p")
+	if len(args) == 0 {
+		return fmt.Errorf("IFNAME: missing")
+	}
+	ifname, args := args[0], args[1:]
+	filter, err := ParseFilter(args...)
+	if err != nil {
+		return err
+	}
+	count := uint64(0)
+	if s := parm.ByName["-c"]; len(s) > 0 {
+		if count, err = strconv.ParseUint(s, 0, 64); err != nil ||
+			count == 0 {
+			return fmt.Errorf("-c: %q invalid", s)
+		}
+	}
+	var stop <-chan time.Time
+	if s := parm.ByName["-d"]; len(s) > 0 {
+		d, err := duration(s)
+		if err != nil {
+			return fmt.Errorf("-d: %q invalid", s)
+		}
+		stop = time.After(d)
+	}
+	size := int64(0)
+	if s := parm.ByName["-C"]; len(s) > 0 {
+		if size, err = bytesize(s); err != nil {
+			return fmt.Errorf("-C: %q invalid", s)
+		}
+	}
+	snaplen := defaultSnaplen
+	if s := parm.ByName["-s"]; len(s) > 0 {
+		n, err := strconv.ParseUint(s, 0, 31)
+		if err != nil || n == 0 {
+			return fmt.Errorf("-s: %q invalid", s)
+		}
+		snaplen = int(n)
+	}
+
+	ifi, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return err
+	}
+	linkType := LinkTypeEthernet
+	if b, err := ioutil.ReadFile(filepath.Join("/sys/class/net", ifname,
+		"type")); err == nil {
+		// ARPHRD_ETHER, ARPHRD_LOOPBACK
+		if t := strings.TrimSpace(string(b)); t != "1" && t != "772" {
+			linkType = LinkTypeRaw
+		}
+	}
+	fd, err := open(ifi.Index, !flag.ByName["-p"])
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var pw *Writer
+	var bw *bufio.Writer
+	if fn := parm.ByName["-w"]; len(fn) > 0 {
+		var w io.Writer = os.Stdout
+		if fn != "-" {
+			f, err := os.Create(fn)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		bw = bufio.NewWriter(w)
+		defer bw.Flush()
+		if pw, err = NewWriter(bw, ifname, linkType, snaplen); err != nil {
+			return err
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM, syscall.SIGPIPE)
+	defer signal.Stop(sig)
+
+	buf := make([]byte, snaplen)
+	captured := uint64(0)
+	written := int64(0)
+loop:
+	for count == 0 || captured < count {
+		select {
+		case <-sig:
+			break loop
+		case <-stop:
+			break loop
+		default:
+		}
+		n, from, err := syscall.Recvfrom(fd, buf, syscall.MSG_TRUNC)
+		if err != nil {
+			if err == syscall.EAGAIN || err == syscall.EINTR {
+				// flush a stream so that the reader keeps up
+				if bw != nil {
+					bw.Flush()
+				}
+				continue
+			}
+			return err
+		}
+		now := time.Now()
+		data := buf
+		if n < len(buf) {
+			data = buf[:n]
+		}
+		p := Decode(linkType, data, n)
+		if !filter(p) {
+			continue
+		}
+		flags := uint32(FlagInbound)
+		dir := "in"
+		if sa, ok := from.(*syscall.SockaddrLinklayer); ok &&
+			sa.Pkttype == packetOutgoing {
+			flags, dir = FlagOutbound, "out"
+		}
+		if pw != nil {
+			// an EPB is 32 bytes plus the padded data and flags
+			next := int64(32 + (len(data)+3)&^3 + 12)
+			if size > 0 && pw.N+next > size {
+				break loop
+			}
+			if err = pw.WritePacket(now, data, n, flags); err != nil {
+				return err
+			}
+		} else {
+			if size > 0 && written+int64(n) > size {
+				break loop
+			}
+			written += int64(n)
+			fmt.Println(now.Format("15:04:05.000000"), ifname, dir, p)
+		}
+		captured++
+	}
+	if pw != nil && parm.ByName["-w"] != "-" {
+		fmt.Fprintln(os.Stderr, captured, "frames captured")
+	}
+	return nil
+}
+
+// open a packet socket of all protocols on the interface with a receive
+// timeout to poll the limits and interrupts.
+func open(ifindex int, promisc bool) (int, error) {
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW,
+		int(htons(ethPAll)))
+	if err != nil {
+		return -1, err
+	}
+	err = syscall.Bind(fd, &syscall.SockaddrLinklayer{
+		Protocol: htons(ethPAll),
+		Ifindex:  ifindex,
+	})
+	if err == nil {
+		err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET,
+			syscall.SO_RCVTIMEO, &syscall.Timeval{
+				Usec: receiveTimeoutUs,
+			})
+	}
+	if err == nil && promisc {
+		mreq := packetMreq{
+			ifindex: int32(ifindex),
+			typ:     packetMrPromisc,
+		}
+		_, _, e := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd),
+			syscall.SOL_PACKET, syscall.PACKET_ADD_MEMBERSHIP,
+			uintptr(unsafe.Pointer(&mreq)), unsafe.Sizeof(mreq), 0)
+		if e != 0 {
+			err = fmt.Errorf("PACKET_ADD_MEMBERSHIP: %v", e)
+		}
+	}
+	if err != nil {
+		syscall.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// duration of seconds or a time.Duration string
+func duration(s string) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(s, 64); err == nil && f > 0 {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = fmt.Errorf("not positive")
+	}
+	return d, err
+}
+
+// bytesize of a number with an optional k, m, or g suffix
+func bytesize(s string) (int64, error) {
+	scale := int64(1)
+	switch strings.ToLower(s[len(s)-1:]) {
+	case "k":
+		scale = 1 << 10
+	case "m":
+		scale = 1 << 20
+	case "g":
+		scale = 1 << 30
+	}
+	if scale > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid")
+	}
+	return n * scale, nil
+}
+
+func htons(u uint16) uint16 {
+	return u<<8 | u>>8
+}
+
+func (Command) Complete(args ...string) []string {
+	last, prev := complete.Last(args)
+	switch prev {
+	case "-c", "-d", "-C", "-s":
+		return nil
+	case "-w":
+		list, _ := filepath.Glob(last + "*")
+		return list
+	}
+	// the interface is the first argument that isn't an option
+	n := len(args) - 1
+	for i := 0; i < n; i++ {
+		switch args[i] {
+		case "-p":
+		case "-c", "-d", "-C", "-s", "-w":
+			i++
+		default:
+			return complete.Prefixed(last, "ether", "arp", "ip", "ip6",
+				"tcp", "udp", "icmp", "icmp6", "lldp", "lacp",
+				"vlan", "host", "net", "port", "src", "dst",
+				"proto", "less", "greater", "and", "or", "not")
+		}
+	}
+	if strings.HasPrefix(last, "-") {
+		return complete.Prefixed(last, "-p", "-c", "-d", "-C", "-s",
+			"-w")
+	}
+	return complete.IfName(last)
+}
@@ -0,0 +1,132 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package pcap
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// pcapng block types and options
+const (
+	blockSectionHeader        = 0x0a0d0d0a
+	blockInterfaceDescription = 1
+	blockEnhancedPacket       = 6
+	byteOrderMagic            = 0x1a2b3c4d
+
+	optEnd        = 0
+	optShbUserApp = 4
+	optIfName     = 2
+	optIfTsResol  = 9
+	optEpbFlags   = 2
+
+	// EPB flags of the packet direction
+	FlagInbound  = 1
+	FlagOutbound = 2
+)
+
+var le = binary.LittleEndian
+
+// Writer of a pcapng section with a single interface of nanosecond
+// timestamps.
+type Writer struct {
+	w io.Writer
+	// N is the number of bytes written
+	N int64
+}
+
+// NewWriter writes the section header and interface description blocks.
+func NewWriter(w io.Writer, ifname string, linkType, snaplen int) (*Writer,
+	error) {
+	pw := &Writer{w: w}
+	var shb []byte
+	shb = put32(shb, byteOrderMagic)
+	shb = put16(shb, 1)
+	shb = put16(shb, 0)
+	// unspecified section length
+	shb = put64(shb, ^uint64(0))
+	shb = option(shb, optShbUserApp, []byte("goes pcap"))
+	shb = option(shb, optEnd, nil)
+	if err := pw.block(blockSectionHeader, shb); err != nil {
+		return nil, err
+	}
+	var idb []byte
+	idb = put16(idb, uint16(linkType))
+	idb = put16(idb, 0)
+	idb = put32(idb, uint32(snaplen))
+	idb = option(idb, optIfName, []byte(ifname))
+	idb = option(idb, optIfTsResol, []byte{9})
+	idb = option(idb, optEnd, nil)
+	if err := pw.block(blockInterfaceDescription, idb); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WritePacket of the captured data, originally length bytes, with the
+// given direction flags, if any.
+func (pw *Writer) WritePacket(t time.Time, data []byte, length int,
+	flags uint32) error {
+	ns := uint64(t.UnixNano())
+	epb := make([]byte, 0, 20+len(data)+3+12+4)
+	epb = put32(epb, 0)
+	epb = put32(epb, uint32(ns>>32))
+	epb = put32(epb, uint32(ns))
+	epb = put32(epb, uint32(len(data)))
+	epb = put32(epb, uint32(length))
+	epb = append(epb, data...)
+	epb = pad(epb)
+	if flags != 0 {
+		epb = option(epb, optEpbFlags, put32(nil, flags))
+		epb = option(epb, optEnd, nil)
+	}
+	return pw.block(blockEnhancedPacket, epb)
+}
+
+func (pw *Writer) block(t uint32, body []byte) error {
+	n := uint32(12 + len(body))
+	b := make([]byte, 0, n)
+	b = put32(b, t)
+	b = put32(b, n)
+	b = append(b, body...)
+	b = put32(b, n)
+	_, err := pw.w.Write(b)
+	if err == nil {
+		pw.N += int64(n)
+	}
+	return err
+}
+
+func option(b []byte, code uint16, value []byte) []byte {
+	b = put16(b, code)
+	b = put16(b, uint16(len(value)))
+	return pad(append(b, value...))
+}
+
+func put16(b []byte, v uint16) []byte {
+	var x [2]byte
+	le.PutUint16(x[:], v)
+	return append(b, x[:]...)
+}
+
+func put32(b []byte, v uint32) []byte {
+	var x [4]byte
+	le.PutUint32(x[:], v)
+	return append(b, x[:]...)
+}
+
+func put64(b []byte, v uint64) []byte {
+	var x [8]byte
+	le.PutUint64(x[:], v)
+	return append(b, x[:]...)
+}
+
+func pad(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
@@ -0,0 +1,233 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ping
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/platinasystems/goes/cmd/vrf"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// maxSize of echo data in an IPv4 datagram
+const maxSize = 65535 - 20 - 8
+
+const (
+	protoIcmp   = 1
+	protoIcmpv6 = 58
+)
+
+type echo struct {
+	conn net.PacketConn
+	p4   *ipv4.PacketConn
+	p6   *ipv6.PacketConn
+	dst  *net.IPAddr
+	// WriteTo address, that of dst for raw sockets; otherwise, its
+	// UDPAddr equivalent
+	to    net.Addr
+	proto int
+	// the kernel replaces the id of datagram sockets and filters
+	// their replies
+	raw bool
+	id  int
+
+	closed chan struct{}
+}
+
+func open(network, dest, source, vrfName string) (*echo, error) {
+	dst, err := net.ResolveIPAddr(network, dest)
+	if err != nil {
+		return nil, err
+	}
+	v6 := dst.IP.To4() == nil
+	src, err := sourceAddr(source, v6)
+	if err != nil {
+		return nil, err
+	}
+	e := &echo{
+		dst:    dst,
+		proto:  protoIcmp,
+		id:     os.Getpid() & 0xffff,
+		closed: make(chan struct{}),
+	}
+	raw, dgram := "ip4:icmp", "udp4"
+	if v6 {
+		raw, dgram = "ip6:ipv6-icmp", "udp6"
+		e.proto = protoIcmpv6
+	}
+	if len(vrfName) > 0 {
+		// SO_BINDTODEVICE needs the same privilege as a raw socket
+		if e.conn, err = vrf.ListenPacket(vrfName, raw, src); err != nil {
+			return nil, err
+		}
+		e.raw = true
+		e.to = dst
+		if v6 {
+			e.p6 = ipv6.NewPacketConn(e.conn)
+		} else {
+			e.p4 = ipv4.NewPacketConn(e.conn)
+		}
+	} else {
+		var conn *icmp.PacketConn
+		if conn, err = icmp.ListenPacket(raw, src); err == nil {
+			e.raw = true
+			e.to = dst
+		} else {
+			var derr error
+			if conn, derr = icmp.ListenPacket(dgram, src); derr != nil {
+				return nil, err
+			}
+			e.to = &net.UDPAddr{IP: dst.IP, Zone: dst.Zone}
+		}
+		e.conn, e.p4, e.p6 = conn, conn.IPv4PacketConn(),
+			conn.IPv6PacketConn()
+	}
+	if v6 {
+		e.p6.SetControlMessage(ipv6.FlagHopLimit, true)
+	} else {
+		e.p4.SetControlMessage(ipv4.FlagTTL, true)
+	}
+	return e, nil
+}
+
+// sourceAddr returns the given address or the first address of the family
+// on the named interface, preferring those not link-local.
+func sourceAddr(source string, v6 bool) (string, error) {
+	if len(source) == 0 || net.ParseIP(source) != nil {
+		return source, nil
+	}
+	ifi, err := net.InterfaceByName(source)
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", source, err)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", source, err)
+	}
+	var ll string
+	for _, addr := range addrs {
+		ipn, ok := addr.(*net.IPNet)
+		if !ok || (ipn.IP.To4() == nil) != v6 {
+			continue
+		}
+		if !ipn.IP.IsLinkLocalUnicast() {
+			return ipn.IP.String(), nil
+		}
+		if len(ll) == 0 {
+			ll = ipn.IP.String() + "%" + ifi.Name
+		}
+	}
+	if len(ll) == 0 {
+		return "", fmt.Errorf("%s: no address", source)
+	}
+	return ll, nil
+}
+
+func (e *echo) close() error {
+	close(e.closed)
+	return e.conn.Close()
+}
+
+func (e *echo) send(seq, size int) error {
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if e.proto == protoIcmpv6 {
+		typ = ipv6.ICMPTypeEchoRequest
+	}
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	m := icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{
+			ID:   e.id,
+			Seq:  seq & 0xffff,
+			Data: data,
+		},
+	}
+	// the kernel sums ICMPv6
+	b, err := m.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = e.conn.WriteTo(b, e.to)
+	return err
+}
+
+// receive echo replies until closed.
+func (e *echo) receive(replies chan<- Reply) {
+	b := make([]byte, maxSize+8)
+	for {
+		var n, ttl int
+		var from net.Addr
+		var err error
+		if e.p6 != nil {
+			var cm *ipv6.ControlMessage
+			n, cm, from, err = e.p6.ReadFrom(b)
+			if cm != nil {
+				ttl = cm.HopLimit
+			}
+		} else {
+			var cm *ipv4.ControlMessage
+			n, cm, from, err = e.p4.ReadFrom(b)
+			if cm != nil {
+				ttl = cm.TTL
+			}
+		}
+		at := time.Now()
+		if err != nil {
+			select {
+			case <-e.closed:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return
+		}
+		m, err := icmp.ParseMessage(e.proto, b[:n])
+		if err != nil {
+			continue
+		}
+		if m.Type != ipv4.ICMPTypeEchoReply &&
+			m.Type != ipv6.ICMPTypeEchoReply {
+			continue
+		}
+		echo, ok := m.Body.(*icmp.Echo)
+		if !ok || e.raw && echo.ID != e.id {
+			continue
+		}
+		r := Reply{
+			Seq:   echo.Seq,
+			Bytes: n,
+			From:  addrString(from),
+			Ttl:   ttl,
+			at:    at,
+		}
+		select {
+		case replies <- r:
+		case <-e.closed:
+			return
+		}
+	}
+}
+
+func addrString(addr net.Addr) string {
+	switch t := addr.(type) {
+	case *net.IPAddr:
+		return t.IP.String()
+	case *net.UDPAddr:
+		return t.IP.String()
+	case nil:
+		return ""
+	}
+	return addr.String()
+}
@@ -1,25 +1,63 @@
-// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
 package ping
 
 import (
+	"encoding/json"
 	"fmt"
-	"net"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/platinasystems/goes/cmd/vrf"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/complete"
 	"github.com/platinasystems/goes/lang"
-	"github.com/tatsushid/go-fastping"
 )
 
 type Command struct{}
 
+// Reply to an echo request
+type Reply struct {
+	Seq   int     `json:"seq"`
+	Bytes int     `json:"bytes"`
+	From  string  `json:"from"`
+	Ttl   int     `json:"ttl,omitempty"`
+	Rtt   float64 `json:"rtt_ms"`
+
+	// receive time
+	at time.Time
+}
+
+// Stats summarize the ping of a destination; the round trip times are in
+// milliseconds.
+type Stats struct {
+	Destination string  `json:"destination"`
+	Address     string  `json:"address"`
+	Transmitted int     `json:"transmitted"`
+	Received    int     `json:"received"`
+	Loss        float64 `json:"loss_percent"`
+	Time        float64 `json:"time_ms"`
+	Min         float64 `json:"rtt_min_ms"`
+	Avg         float64 `json:"rtt_avg_ms"`
+	Max         float64 `json:"rtt_max_ms"`
+	Mdev        float64 `json:"rtt_mdev_ms"`
+	Replies     []Reply `json:"replies"`
+
+	sum, sumsq float64
+}
+
 func (Command) String() string { return "ping" }
 
 func (Command) Usage() string {
-	return "ping DESTINATION"
+	return `ping [-4 | -6] [-c COUNT] [-i INTERVAL] [-s SIZE] [-I INTERFACE]
+	[-W TIMEOUT] [-vrf NAME] [-json] DESTINATION`
 }
 
 func (Command) Apropos() lang.Alt {
@@ -32,34 +70,237 @@ func (Command) Man() lang.Alt {
 	return lang.Alt{
 		lang.EnUS: `
 DESCRIPTION
-	Send ICMP ECHO_REQUEST to given host and print ECHO_REPLY.`,
+	Send ICMP or ICMPv6 ECHO_REQUEST to given host and print each
+	ECHO_REPLY followed by the transmitted and received counts, loss,
+	and the minimum, average, maximum, and mean deviation of the round
+	trip times.
+
+	ping uses a raw socket if permitted; otherwise, an unprivileged
+	ICMP datagram socket of a process group in
+	/proc/sys/net/ipv4/ping_group_range.
+
+	ping exits with an error if there were no replies.
+
+OPTIONS
+	-4, -6	resolve DESTINATION to an IPv4 or IPv6 address
+	-c COUNT
+		stop after sending COUNT requests, default: until interrupted
+	-i INTERVAL
+		seconds, or duration, between requests, default: 1
+	-s SIZE
+		data bytes of each request, default: 56
+	-I INTERFACE
+		the source address, or interface of the source address
+	-W TIMEOUT
+		seconds, or duration, to wait for replies after the last
+		request, default: 2
+	-vrf NAME
+		use the routing table of the VRF device; this needs a raw
+		socket
+	-json	print the statistics and replies as JSON`,
 	}
 }
 
 func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-c", "-i", "-s", "-I", "-W", "-vrf")
+	flag, args := flags.New(args, "-4", "-6", "-json")
 	if n := len(args); n == 0 {
 		return fmt.Errorf("DESTINATION: missing")
 	} else if n > 1 {
 		return fmt.Errorf("%v: unexpected", args[1:])
 	}
+	count := 0
+	if s := parm.ByName["-c"]; len(s) > 0 {
+		n, err := strconv.ParseUint(s, 0, 32)
+		if err != nil || n == 0 {
+			return fmt.Errorf("-c: %q invalid", s)
+		}
+		count = int(n)
+	}
+	interval, err := seconds("-i", parm.ByName["-i"], time.Second)
+	if err != nil {
+		return err
+	}
+	timeout, err := seconds("-W", parm.ByName["-W"], 2*time.Second)
+	if err != nil {
+		return err
+	}
+	size := 56
+	if s := parm.ByName["-s"]; len(s) > 0 {
+		n, err := strconv.ParseUint(s, 0, 16)
+		if err != nil || n > maxSize {
+			return fmt.Errorf("-s: %q invalid", s)
+		}
+		size = int(n)
+	}
+	network := "ip"
+	if flag.ByName["-4"] {
+		network = "ip4"
+	} else if flag.ByName["-6"] {
+		network = "ip6"
+	}
+
 	dest := args[0]
-	pinger := fastping.NewPinger()
-	pinger.Size = 64
-	da, err := net.ResolveIPAddr("ip4:icmp", dest)
+	e, err := open(network, dest, parm.ByName["-I"], parm.ByName["-vrf"])
 	if err != nil {
 		return err
 	}
-	err = syscall.ETIMEDOUT
-	pinger.AddIPAddr(da)
-	pinger.OnRecv = func(addr *net.IPAddr, rtt time.Duration) {
-		fmt.Printf("%d bytes from %s in %s\n",
-			pinger.Size, addr.String(), rtt.String())
-		err = nil
+	defer e.close()
+
+	js := flag.ByName["-json"]
+	stats := &Stats{
+		Destination: dest,
+		Address:     e.dst.String(),
+		Replies:     []Reply{},
+	}
+	if !js {
+		fmt.Printf("PING %s (%s) %d data bytes\n", dest, stats.Address,
+			size)
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+
+	replies := make(chan Reply, 16)
+	go e.receive(replies)
+
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var done <-chan time.Time
+	// send times by the 16 bit sequence number
+	sent := make(map[int]time.Time)
+	start := time.Now()
+	send := func() {
+		seq := stats.Transmitted + 1
+		sent[seq&0xffff] = time.Now()
+		if err := e.send(seq, size); err != nil {
+			fmt.Fprintln(os.Stderr, "ping:", err)
+		}
+		stats.Transmitted = seq
+		if stats.Transmitted == count {
+			t.Stop()
+			done = time.After(timeout)
+		}
+	}
+	send()
+loop:
+	for {
+		select {
+		case <-sig:
+			break loop
+		case <-done:
+			break loop
+		case <-t.C:
+			send()
+		case r := <-replies:
+			at, found := sent[r.Seq]
+			if !found {
+				// duplicate or late
+				continue
+			}
+			delete(sent, r.Seq)
+			r.Rtt = msec(r.at.Sub(at))
+			stats.add(r)
+			if !js {
+				fmt.Printf("%d bytes from %s: icmp_seq=%d", r.Bytes,
+					r.From, r.Seq)
+				if r.Ttl > 0 {
+					fmt.Printf(" ttl=%d", r.Ttl)
+				}
+				fmt.Printf(" time=%.3f ms\n", r.Rtt)
+			}
+			if stats.Transmitted == count && len(sent) == 0 {
+				break loop
+			}
+		}
+	}
+	stats.Time = msec(time.Since(start))
+	stats.finish()
+	if js {
+		b, err := json.MarshalIndent(stats, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	} else {
+		stats.Print()
+	}
+	if stats.Received == 0 {
+		return syscall.ETIMEDOUT
+	}
+	return nil
+}
+
+func (stats *Stats) add(r Reply) {
+	if stats.Received == 0 || r.Rtt < stats.Min {
+		stats.Min = r.Rtt
+	}
+	if r.Rtt > stats.Max {
+		stats.Max = r.Rtt
 	}
-	pinger.OnIdle = func() {}
-	fmt.Printf("PING %s (%s)\n", dest, da.String())
-	if rerr := pinger.Run(); err == nil {
-		err = rerr
+	stats.Received++
+	stats.sum += r.Rtt
+	stats.sumsq += r.Rtt * r.Rtt
+	stats.Replies = append(stats.Replies, r)
+}
+
+func (stats *Stats) finish() {
+	if stats.Transmitted > 0 {
+		stats.Loss = 100 * float64(stats.Transmitted-stats.Received) /
+			float64(stats.Transmitted)
+	}
+	if stats.Received == 0 {
+		return
+	}
+	n := float64(stats.Received)
+	stats.Avg = stats.sum / n
+	if v := stats.sumsq/n - stats.Avg*stats.Avg; v > 0 {
+		stats.Mdev = math.Sqrt(v)
+	}
+}
+
+func (stats *Stats) Print() {
+	fmt.Printf("--- %s ping statistics ---\n", stats.Destination)
+	fmt.Printf("%d packets transmitted, %d received, %g%% packet loss, time %.0fms\n",
+		stats.Transmitted, stats.Received, math.Round(stats.Loss*10)/10,
+		stats.Time)
+	if stats.Received > 0 {
+		fmt.Printf("rtt min/avg/max/mdev = %.3f/%.3f/%.3f/%.3f ms\n",
+			stats.Min, stats.Avg, stats.Max, stats.Mdev)
+	}
+}
+
+// seconds parses a number of seconds or a duration
+func seconds(name, s string, def time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		f, ferr := strconv.ParseFloat(s, 64)
+		if ferr != nil {
+			return def, fmt.Errorf("%s: %q invalid", name, s)
+		}
+		d = time.Duration(f * float64(time.Second))
+	}
+	if d <= 0 {
+		return def, fmt.Errorf("%s: %q invalid", name, s)
+	}
+	return d, nil
+}
+
+func msec(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (Command) Complete(args ...string) []string {
+	last, prev := complete.Last(args)
+	switch prev {
+	case "-I":
+		return complete.IfName(last)
+	case "-vrf":
+		return vrf.CompleteName(last)
 	}
-	return err
+	return nil
 }
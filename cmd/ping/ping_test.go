@@ -0,0 +1,46 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ping
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	stats := &Stats{Transmitted: 4}
+	for _, rtt := range []float64{1, 2, 3} {
+		stats.add(Reply{Rtt: rtt})
+	}
+	stats.finish()
+	if stats.Received != 3 || stats.Loss != 25 {
+		t.Error("received", stats.Received, "loss", stats.Loss)
+	}
+	if stats.Min != 1 || stats.Avg != 2 || stats.Max != 3 {
+		t.Error("min/avg/max", stats.Min, stats.Avg, stats.Max)
+	}
+	if math.Abs(stats.Mdev-math.Sqrt(2.0/3)) > 1e-9 {
+		t.Error("mdev", stats.Mdev)
+	}
+}
+
+func TestSeconds(t *testing.T) {
+	for s, want := range map[string]time.Duration{
+		"":      time.Second,
+		"0.2":   200 * time.Millisecond,
+		"2":     2 * time.Second,
+		"500ms": 500 * time.Millisecond,
+	} {
+		if d, err := seconds("-i", s, time.Second); err != nil || d != want {
+			t.Errorf("%q: %v %v", s, d, err)
+		}
+	}
+	for _, s := range []string{"0", "-1", "x"} {
+		if _, err := seconds("-i", s, time.Second); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
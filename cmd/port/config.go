@@ -0,0 +1,254 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package port
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the redis settable fields,
+//
+//	port.IFNAME: [speed SPEED] [fec FEC] [autoneg on|off] [mtu MTU]
+//		[admin up|down] [description TEXT...]
+//	SPEED := auto|1g|10g|25g|40g|50g|100g...
+//	FEC := auto|off|rs|baser
+//
+// These are a field per port so that a change of several is validated as a
+// whole.
+const Prefix = "port."
+
+// Attrs are the settable attributes of each port.
+var Attrs = []string{"speed", "fec", "autoneg", "mtu", "admin",
+	"description"}
+
+// Config of the ports by name
+type Config map[string]*Port
+
+// Port settings; the zero value of each leaves the driver's default.
+type Port struct {
+	// Speed in Mb/s, 0 is auto
+	Speed   uint32 `json:"speed,omitempty"`
+	Fec     string `json:"fec,omitempty"`
+	Autoneg string `json:"autoneg,omitempty"`
+	Mtu     uint32 `json:"mtu,omitempty"`
+	// Admin state, up or down
+	Admin string `json:"admin,omitempty"`
+	// Description of the port, e.g. its peer, that's also the alias of
+	// the interface
+	Description string `json:"description,omitempty"`
+}
+
+// the range of a valid MTU, that of IPv4 through jumbo frames
+const (
+	minMtu = 68
+	maxMtu = 9216
+)
+
+// maxDescription is that of the interface alias, IFALIASZ less one.
+const maxDescription = 255
+
+// Fecs are the valid FEC names.
+var Fecs = []string{"auto", "off", "rs", "baser"}
+
+// ParseSpeed of auto, a number of Mb/s, or that of Gb/s with a g suffix,
+// e.g. 2.5g or 100g.
+func ParseSpeed(s string) (uint32, error) {
+	t := strings.ToLower(s)
+	if t == "auto" {
+		return 0, nil
+	}
+	scale := 1.0
+	if strings.HasSuffix(t, "g") {
+		t, scale = t[:len(t)-1], 1000
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil || f <= 0 || f*scale != float64(uint32(f*scale)) {
+		return 0, fmt.Errorf("%q: invalid speed", s)
+	}
+	return uint32(f * scale), nil
+}
+
+// SpeedString formats Mb/s as in ParseSpeed.
+func SpeedString(mbps uint32) string {
+	switch {
+	case mbps == 0:
+		return "auto"
+	case mbps%1000 == 0:
+		return fmt.Sprint(mbps/1000, "g")
+	case mbps > 1000:
+		return strconv.FormatFloat(float64(mbps)/1000, 'f', -1, 64) +
+			"g"
+	}
+	return fmt.Sprint(mbps)
+}
+
+// ParsePort settings of the [speed SPEED] [fec FEC] [autoneg on|off]
+// [mtu MTU] [admin up|down] [description TEXT...] args; the description is
+// the remaining args.
+func ParsePort(args ...string) (*Port, error) {
+	p := new(Port)
+	for ; len(args) > 0; args = args[2:] {
+		if len(args) < 2 {
+			return nil, fmt.Errorf("%s: missing value", args[0])
+		}
+		if args[0] == "description" {
+			p.Description = strings.Join(args[1:], " ")
+			if len(p.Description) > maxDescription {
+				return nil, fmt.Errorf("description: too long")
+			}
+			break
+		}
+		value := strings.ToLower(args[1])
+		var err error
+		switch args[0] {
+		case "speed":
+			p.Speed, err = ParseSpeed(value)
+		case "fec":
+			if value == "none" {
+				value = "off"
+			}
+			if !has(Fecs, value) {
+				err = fmt.Errorf("%q: invalid fec", value)
+			}
+			p.Fec = value
+		case "autoneg":
+			if value != "on" && value != "off" {
+				err = fmt.Errorf("%q: invalid autoneg", value)
+			}
+			p.Autoneg = value
+		case "mtu":
+			var u uint64
+			u, err = strconv.ParseUint(value, 10, 32)
+			if err != nil || u < minMtu || u > maxMtu {
+				err = fmt.Errorf("%q: invalid mtu", value)
+			}
+			p.Mtu = uint32(u)
+		case "admin":
+			if value != "up" && value != "down" {
+				err = fmt.Errorf("%q: invalid admin state", value)
+			}
+			p.Admin = value
+		default:
+			err = fmt.Errorf("%s: unknown", args[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// String returns the port's settings as a field value.
+func (p *Port) String() string {
+	var args []string
+	if p.Speed != 0 {
+		args = append(args, "speed", SpeedString(p.Speed))
+	}
+	if len(p.Fec) > 0 {
+		args = append(args, "fec", p.Fec)
+	}
+	if len(p.Autoneg) > 0 {
+		args = append(args, "autoneg", p.Autoneg)
+	}
+	if p.Mtu != 0 {
+		args = append(args, "mtu", strconv.FormatUint(uint64(p.Mtu), 10))
+	}
+	if len(p.Admin) > 0 {
+		args = append(args, "admin", p.Admin)
+	}
+	if len(p.Description) > 0 {
+		args = append(args, "description", p.Description)
+	}
+	return strings.Join(args, " ")
+}
+
+// Parse the port.* fields.
+func Parse(fields map[string]string) (Config, error) {
+	c := make(Config)
+	for field, value := range fields {
+		if !strings.HasPrefix(field, Prefix) ||
+			len(field) == len(Prefix) {
+			return nil, fmt.Errorf("%s: invalid", field)
+		}
+		if len(strings.TrimSpace(value)) == 0 {
+			continue
+		}
+		p, err := ParsePort(strings.Fields(value)...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+		c[field[len(Prefix):]] = p
+	}
+	return c, nil
+}
+
+// Names returns the sorted port names.
+func (c Config) Names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Capabilities of a port from its supported link modes
+type Capabilities struct {
+	Autoneg bool `json:"autoneg"`
+	// FEC names allowed at each speed in Mb/s
+	Speeds map[uint32][]string `json:"speeds"`
+}
+
+// SortedSpeeds returns the supported speeds in ascending order.
+func (caps *Capabilities) SortedSpeeds() []uint32 {
+	speeds := make([]uint32, 0, len(caps.Speeds))
+	for speed := range caps.Speeds {
+		speeds = append(speeds, speed)
+	}
+	sort.Slice(speeds, func(i, j int) bool { return speeds[i] < speeds[j] })
+	return speeds
+}
+
+// Validate the port settings with its capabilities.
+func (p *Port) Validate(caps *Capabilities) error {
+	if p.Autoneg == "on" && !caps.Autoneg {
+		return fmt.Errorf("autoneg unsupported")
+	}
+	if p.Speed == 0 {
+		if p.Autoneg == "off" {
+			return fmt.Errorf("autoneg off requires a speed")
+		}
+		if len(p.Fec) == 0 || p.Fec == "auto" || p.Fec == "off" {
+			return nil
+		}
+		for _, fecs := range caps.Speeds {
+			if has(fecs, p.Fec) {
+				return nil
+			}
+		}
+		return fmt.Errorf("fec %s unsupported", p.Fec)
+	}
+	fecs, found := caps.Speeds[p.Speed]
+	if !found {
+		return fmt.Errorf("speed %s unsupported", SpeedString(p.Speed))
+	}
+	if len(p.Fec) > 0 && p.Fec != "auto" && !has(fecs, p.Fec) {
+		return fmt.Errorf("fec %s unsupported at %s", p.Fec,
+			SpeedString(p.Speed))
+	}
+	return nil
+}
+
+func has(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
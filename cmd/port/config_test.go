@@ -0,0 +1,64 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package port
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"port.eth-12-1": "speed 100g fec RS autoneg off",
+		"port.eth-1-1":  "speed 2.5g",
+		"port.eth-2-1":  "",
+		"port.eth-3-1":  "admin UP mtu 9216",
+		"port.eth-4-1":  "description Uplink to spine2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c) != 4 {
+		t.Fatalf("%v", c)
+	}
+	if s := c["eth-12-1"].String(); s != "speed 100g fec rs autoneg off" {
+		t.Errorf("eth-12-1: %q", s)
+	}
+	if c["eth-1-1"].Speed != 2500 {
+		t.Errorf("eth-1-1: %v", c["eth-1-1"].Speed)
+	}
+	if s := c["eth-3-1"].String(); s != "mtu 9216 admin up" {
+		t.Errorf("eth-3-1: %q", s)
+	}
+	if s := c["eth-4-1"].Description; s != "Uplink to spine2" {
+		t.Errorf("eth-4-1: %q", s)
+	}
+	for _, v := range []string{"speed fast", "fec xyz", "mtu 9999",
+		"admin on", "lanes 4", "autoneg"} {
+		if _, err = Parse(map[string]string{"port.x": v}); err == nil {
+			t.Errorf("%q: expected error", v)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// 100GBASE-CR4 and 25GBASE-CR with autoneg and RS FEC
+	supported := []uint32{1<<6 | 1<<31, 1<<(38-32) | 1<<(50-32)}
+	ls := newLinkSettings()
+	ls.hdr().linkModeNwords = int8(len(supported))
+	copy(ls.Supported(), supported)
+	caps := ls.Capabilities()
+	for _, x := range []struct {
+		p  Port
+		ok bool
+	}{
+		{Port{Speed: 100000, Fec: "rs", Autoneg: "off"}, true},
+		{Port{Speed: 25000, Autoneg: "on"}, true},
+		{Port{Speed: 25000, Fec: "baser"}, false},
+		{Port{Speed: 40000}, false},
+		{Port{Autoneg: "off"}, false},
+	} {
+		if err := x.p.Validate(caps); (err == nil) != x.ok {
+			t.Errorf("%s: %v", x.p.String(), err)
+		}
+	}
+}
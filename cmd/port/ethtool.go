@@ -0,0 +1,306 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package port
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+// linux/ethtool.h
+const (
+	siocEthtool           = 0x8946
+	ethtoolGFecParam      = 0x50
+	ethtoolSFecParam      = 0x51
+	ethtoolGLinkSettings  = 0x4c
+	ethtoolSLinkSettings  = 0x4d
+	ethtoolLinkModeAuto   = 6
+	ethtoolLinkModeFecOff = 49
+	ethtoolLinkModeFecRs  = 50
+	ethtoolLinkModeFecBr  = 51
+	autonegDisable        = 0
+	autonegEnable         = 1
+	duplexFull            = 1
+	maxNwords             = 127
+)
+
+// ETHTOOL_FEC_*
+var fecBits = map[string]uint32{
+	"auto":  1 << 1,
+	"off":   1 << 2,
+	"rs":    1 << 3,
+	"baser": 1 << 4,
+}
+
+// speed of each ETHTOOL_LINK_MODE_* bit in Mb/s
+var linkModeSpeeds = map[uint]uint32{
+	0: 10, 1: 10, 2: 100, 3: 100, 4: 1000, 5: 1000,
+	12: 10000, 15: 2500, 17: 1000, 18: 10000, 19: 10000,
+	21: 20000, 22: 20000,
+	23: 40000, 24: 40000, 25: 40000, 26: 40000,
+	27: 56000, 28: 56000, 29: 56000, 30: 56000,
+	31: 25000, 32: 25000, 33: 25000,
+	34: 50000, 35: 50000,
+	36: 100000, 37: 100000, 38: 100000, 39: 100000,
+	40: 50000, 41: 1000,
+	42: 10000, 43: 10000, 44: 10000, 45: 10000, 46: 10000,
+	47: 2500, 48: 5000,
+	52: 50000, 53: 50000, 54: 50000, 55: 50000, 56: 50000,
+	57: 100000, 58: 100000, 59: 100000, 60: 100000, 61: 100000,
+	62: 200000, 63: 200000, 64: 200000, 65: 200000, 66: 200000,
+	67: 100, 68: 1000,
+	69: 400000, 70: 400000, 71: 400000, 72: 400000, 73: 400000,
+}
+
+// FEC names allowed by IEEE 802.3 at each speed in Mb/s
+var speedFecs = map[uint32][]string{
+	10000:  {"off", "baser"},
+	25000:  {"off", "baser", "rs"},
+	40000:  {"off", "baser"},
+	50000:  {"off", "baser", "rs"},
+	100000: {"off", "rs"},
+	200000: {"rs"},
+	400000: {"rs"},
+}
+
+// struct ethtool_link_settings without the link mode masks
+type linkSettings struct {
+	cmd              uint32
+	speed            uint32
+	duplex           uint8
+	port             uint8
+	phyAddress       uint8
+	autoneg          uint8
+	mdioSupport      uint8
+	ethTpMdix        uint8
+	ethTpMdixCtrl    uint8
+	linkModeNwords   int8
+	transceiver      uint8
+	masterSlaveCfg   uint8
+	masterSlaveState uint8
+	rateMatching     uint8
+	reserved         [7]uint32
+}
+
+const sizeofLinkSettings = 48
+
+// LinkSettings of a device with its supported, advertising, and link
+// partner advertising link mode masks.
+type LinkSettings struct {
+	buf []byte
+}
+
+// newLinkSettings allocates the header with the maximum words of each mask
+// so that these may be viewed as a [3 * maxNwords]uint32 regardless of the
+// device's linkModeNwords.
+func newLinkSettings() *LinkSettings {
+	return &LinkSettings{make([]byte, sizeofLinkSettings+3*4*maxNwords)}
+}
+
+func (ls *LinkSettings) hdr() *linkSettings {
+	return (*linkSettings)(unsafe.Pointer(&ls.buf[0]))
+}
+
+func (ls *LinkSettings) mask(i int) []uint32 {
+	n := int(ls.hdr().linkModeNwords)
+	p := (*[3 * maxNwords]uint32)(unsafe.Pointer(&ls.buf[sizeofLinkSettings]))
+	return p[i*n : (i+1)*n]
+}
+
+func (ls *LinkSettings) Supported() []uint32   { return ls.mask(0) }
+func (ls *LinkSettings) Advertising() []uint32 { return ls.mask(1) }
+
+func test(mask []uint32, bit uint) bool {
+	i := int(bit / 32)
+	return i < len(mask) && mask[i]&(1<<(bit%32)) != 0
+}
+
+// GetLinkSettings of the named device with the ETHTOOL_GLINKSETTINGS
+// handshake of the number of mask words.
+func GetLinkSettings(dev string) (*LinkSettings, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+	ls := newLinkSettings()
+	ls.hdr().cmd = ethtoolGLinkSettings
+	if err = ethtool(fd, dev, unsafe.Pointer(&ls.buf[0])); err != nil {
+		return nil, fmt.Errorf("%s: link settings: %v", dev, err)
+	}
+	n := -ls.hdr().linkModeNwords
+	if n <= 0 {
+		return nil, fmt.Errorf("%s: link settings: no handshake", dev)
+	}
+	*ls.hdr() = linkSettings{
+		cmd:            ethtoolGLinkSettings,
+		linkModeNwords: n,
+	}
+	if err = ethtool(fd, dev, unsafe.Pointer(&ls.buf[0])); err != nil {
+		return nil, fmt.Errorf("%s: link settings: %v", dev, err)
+	}
+	return ls, nil
+}
+
+// Capabilities of the device from its supported link modes.
+func (ls *LinkSettings) Capabilities() *Capabilities {
+	supported := ls.Supported()
+	caps := &Capabilities{
+		Autoneg: test(supported, ethtoolLinkModeAuto),
+		Speeds:  make(map[uint32][]string),
+	}
+	// intersect the IEEE FEC of each speed with those supported
+	// unless the driver doesn't list any
+	fecs := map[string]bool{
+		"off":   test(supported, ethtoolLinkModeFecOff),
+		"rs":    test(supported, ethtoolLinkModeFecRs),
+		"baser": test(supported, ethtoolLinkModeFecBr),
+	}
+	anyFec := fecs["off"] || fecs["rs"] || fecs["baser"]
+	for bit, speed := range linkModeSpeeds {
+		if !test(supported, bit) {
+			continue
+		}
+		if _, found := caps.Speeds[speed]; found {
+			continue
+		}
+		allowed := []string{}
+		for _, fec := range speedFecs[speed] {
+			if !anyFec || fecs[fec] {
+				allowed = append(allowed, fec)
+			}
+		}
+		if len(speedFecs[speed]) == 0 {
+			allowed = append(allowed, "off")
+		}
+		caps.Speeds[speed] = allowed
+	}
+	return caps
+}
+
+// Speed and autoneg of the current link settings.
+func (ls *LinkSettings) Speed() uint32 { return ls.hdr().speed }
+
+func (ls *LinkSettings) Autoneg() bool {
+	return ls.hdr().autoneg == autonegEnable
+}
+
+// Apply the port's speed and autoneg; with autoneg, the speed restricts
+// the advertised link modes.
+func (ls *LinkSettings) Apply(dev string, p *Port) error {
+	h := ls.hdr()
+	changed := false
+	autoneg := h.autoneg
+	switch p.Autoneg {
+	case "on":
+		autoneg = autonegEnable
+	case "off":
+		autoneg = autonegDisable
+	}
+	if autoneg != h.autoneg {
+		h.autoneg = autoneg
+		changed = true
+	}
+	if autoneg == autonegEnable && p.Speed != 0 {
+		supported := ls.Supported()
+		advertising := ls.Advertising()
+		for i := range advertising {
+			var want uint32
+			for b := uint(0); b < 32; b++ {
+				bit := uint(i)*32 + b
+				speed, isSpeed := linkModeSpeeds[bit]
+				if !test(supported, bit) {
+					continue
+				}
+				if (isSpeed && speed == p.Speed) ||
+					(!isSpeed && advertising[i]&(1<<b) != 0) {
+					want |= 1 << b
+				}
+			}
+			if advertising[i] != want {
+				advertising[i] = want
+				changed = true
+			}
+		}
+	} else if autoneg == autonegDisable && p.Speed != 0 &&
+		(h.speed != p.Speed || h.duplex != duplexFull) {
+		h.speed = p.Speed
+		h.duplex = duplexFull
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	h.cmd = ethtoolSLinkSettings
+	err = ethtool(fd, dev, unsafe.Pointer(&ls.buf[0]))
+	h.cmd = ethtoolGLinkSettings
+	if err != nil {
+		return fmt.Errorf("%s: set link settings: %v", dev, err)
+	}
+	return nil
+}
+
+type fecParam struct {
+	cmd, active, fec, reserved uint32
+}
+
+// GetFec returns the configured and active FEC names of the device.
+func GetFec(dev string) (configured, active string, err error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return
+	}
+	defer syscall.Close(fd)
+	param := fecParam{cmd: ethtoolGFecParam}
+	if err = ethtool(fd, dev, unsafe.Pointer(&param)); err != nil {
+		err = fmt.Errorf("%s: fec: %v", dev, err)
+		return
+	}
+	for name, bit := range fecBits {
+		if param.fec&bit != 0 {
+			configured = name
+		}
+		if param.active&bit != 0 {
+			active = name
+		}
+	}
+	return
+}
+
+// SetFec of the device.
+func SetFec(dev, fec string) error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+	param := fecParam{cmd: ethtoolSFecParam, fec: fecBits[fec]}
+	if err = ethtool(fd, dev, unsafe.Pointer(&param)); err != nil {
+		return fmt.Errorf("%s: set fec %s: %v", dev, fec, err)
+	}
+	return nil
+}
+
+func ethtool(fd int, dev string, data unsafe.Pointer) error {
+	var ifr struct {
+		name [syscall.IFNAMSIZ]byte
+		data uintptr
+		pad  [16]byte
+	}
+	copy(ifr.name[:syscall.IFNAMSIZ-1], dev)
+	ifr.data = uintptr(data)
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package port
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+// Ethtool returns true if the port has any of the speed, FEC, or autoneg
+// settings applied through ethtool.
+func (p *Port) Ethtool() bool {
+	return p.Speed != 0 || len(p.Fec) > 0 || len(p.Autoneg) > 0
+}
+
+// SetLink changes the MTU, admin state, and alias of the named interface to
+// those of the port, if set and different.
+func SetLink(name string, p *Port) error {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	msg := rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+		Index:  int32(ifi.Index),
+	}
+	up := ifi.Flags&net.FlagUp != 0
+	switch {
+	case p.Admin == "up" && !up:
+		msg.Flags, msg.Change = rtnl.IFF_UP, rtnl.IFF_UP
+	case p.Admin == "down" && up:
+		msg.Change = rtnl.IFF_UP
+	}
+	var attrs []nl.Attr
+	if p.Mtu != 0 && p.Mtu != uint32(ifi.MTU) {
+		attrs = append(attrs, nl.Attr{Type: rtnl.IFLA_MTU,
+			Value: nl.Uint32Attr(p.Mtu)})
+	}
+	if len(p.Description) > 0 && p.Description != Alias(name) {
+		attrs = append(attrs, nl.Attr{Type: rtnl.IFLA_IFALIAS,
+			Value: nl.KstringAttr(p.Description)})
+	}
+	if msg.Change == 0 && len(attrs) == 0 {
+		return nil
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_SETLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK,
+	}, msg, attrs...)
+	if err != nil {
+		return err
+	}
+	return nl.NewSockReceiver(sock).UntilDone(req, nl.DoNothing)
+}
+
+// Alias of the named interface, if any.
+func Alias(name string) string {
+	b, err := ioutil.ReadFile(filepath.Join("/sys/class/net", name,
+		"ifalias"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(b), "\n")
+}
@@ -0,0 +1,394 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package port provides the schema of the redis settable port.* fields of
+// the speed, FEC, autoneg, MTU, admin state, and description of each
+// ethernet port, kept and applied by portd, along with a command to show and
+// change these and the capabilities of each port.
+package port
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+// Status of a port's configuration and current link settings
+type Status struct {
+	Port
+	Name       string `json:"name"`
+	Current    uint32 `json:"current-speed,omitempty"`
+	CurAutoneg string `json:"current-autoneg,omitempty"`
+	ActiveFec  string `json:"active-fec,omitempty"`
+	CurMtu     uint32 `json:"current-mtu,omitempty"`
+	CurAdmin   string `json:"current-admin,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+const speedUnknown = ^uint32(0)
+
+func (Command) String() string { return "port" }
+
+func (Command) Usage() string {
+	return `port [-json] [show] [IFNAME]...
+port [-json] capabilities [IFNAME]...
+port IFNAME [speed SPEED] [fec FEC] [autoneg on|off] [mtu MTU]
+	[admin up|down] [description TEXT...]`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or change ethernet port speed, FEC, autoneg, and MTU",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	port [-json] [show] [IFNAME]...
+		print the configured and current speed, autoneg, FEC, MTU,
+		and admin state of all, or the given, ports with their
+		descriptions
+
+	port [-json] capabilities [IFNAME]...
+		print the autoneg support and allowed speed and FEC
+		combinations of all, or the given, ports
+
+	port IFNAME [speed SPEED] [fec FEC] [autoneg on|off] [mtu MTU]
+		[admin up|down] [description TEXT...]
+		change the port's settings; a value of default removes the
+		setting to leave that of the driver; the description is the
+		remaining, or quoted, text that portd also sets as the
+		interface alias
+
+	SPEED is auto, a number of Mb/s, or of Gb/s with a g suffix, e.g.
+	100g; FEC is auto, off, rs, or baser; and MTU is 68 through 9216.
+	portd rejects settings that the port's capabilities don't allow.
+
+	This is a wrapper of the redis settable field kept by portd,
+		hset platina port.IFNAME "[speed SPEED] [fec FEC] ..."
+	that portd reapplies whenever the port is recreated or vnetd
+	restarts, and with "config reconcile".
+
+EXAMPLES
+	port eth-12-1 speed 100g fec rs autoneg off
+	port eth-12-1 mtu 9216 admin up
+	port eth-4-1 description "uplink to spine2"
+
+SEE ALSO
+	portd, config`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-json")
+	what := "show"
+	if len(args) > 0 && (args[0] == "show" || args[0] == "capabilities") {
+		what, args = args[0], args[1:]
+	} else if len(args) > 1 {
+		return set(args[0], args[1:]...)
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	names := args
+	if len(names) == 0 {
+		names = ports(c)
+	}
+	var v interface{}
+	if what == "capabilities" {
+		m := make(map[string]*Capabilities)
+		for _, name := range names {
+			ls, err := GetLinkSettings(name)
+			if err != nil {
+				if len(args) > 0 {
+					return err
+				}
+				continue
+			}
+			m[name] = ls.Capabilities()
+		}
+		if !flag.ByName["-json"] {
+			return showCapabilities(m)
+		}
+		v = m
+	} else {
+		var list []*Status
+		for _, name := range names {
+			list = append(list, GetStatus(name, c[name]))
+		}
+		if !flag.ByName["-json"] {
+			return show(list)
+		}
+		v = list
+	}
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(b))
+	return nil
+}
+
+// Get the port settings kept by portd.
+func Get() (Config, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(fields)
+}
+
+// GetStatus of the named port with its configuration, if any.
+func GetStatus(name string, p *Port) *Status {
+	st := &Status{Name: name}
+	if p != nil {
+		st.Port = *p
+	}
+	if ifi, err := net.InterfaceByName(name); err == nil {
+		st.CurMtu = uint32(ifi.MTU)
+		st.CurAdmin = "down"
+		if ifi.Flags&net.FlagUp != 0 {
+			st.CurAdmin = "up"
+		}
+	}
+	ls, err := GetLinkSettings(name)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Current = ls.Speed()
+	st.CurAutoneg = "off"
+	if ls.Autoneg() {
+		st.CurAutoneg = "on"
+	}
+	if _, active, err := GetFec(name); err == nil {
+		st.ActiveFec = active
+	}
+	return st
+}
+
+// ports returns the sorted names of the configured ports and those with
+// link settings of supported speeds.
+func ports(c Config) []string {
+	found := make(map[string]bool)
+	for name := range c {
+		found[name] = true
+	}
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifs {
+			ls, err := GetLinkSettings(ifi.Name)
+			if err == nil && len(ls.Capabilities().Speeds) > 0 {
+				found[ifi.Name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func show(list []*Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tSPEED\tAUTONEG\tFEC\tMTU\tADMIN\tCURRENT\t"+
+		"DESCRIPTION")
+	for _, st := range list {
+		speed := "-"
+		if st.Speed != 0 {
+			speed = SpeedString(st.Speed)
+		}
+		autoneg := "-"
+		if len(st.Autoneg) > 0 {
+			autoneg = st.Autoneg
+		}
+		fec := "-"
+		if len(st.Fec) > 0 {
+			fec = st.Fec
+		}
+		mtu := "-"
+		if st.Mtu != 0 {
+			mtu = fmt.Sprint(st.Mtu)
+		}
+		admin := "-"
+		if len(st.Admin) > 0 {
+			admin = st.Admin
+		}
+		current := st.Error
+		if len(current) == 0 {
+			cur := "unknown"
+			if st.Current != 0 && st.Current != speedUnknown {
+				cur = SpeedString(st.Current)
+			}
+			current = fmt.Sprint(cur, " autoneg ", st.CurAutoneg)
+			if len(st.ActiveFec) > 0 {
+				current += " fec " + st.ActiveFec
+			}
+		}
+		if len(st.CurAdmin) > 0 {
+			current += fmt.Sprint(" mtu ", st.CurMtu, " ", st.CurAdmin)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", st.Name,
+			speed, autoneg, fec, mtu, admin,
+			strings.TrimSpace(current), st.Description)
+	}
+	return w.Flush()
+}
+
+func showCapabilities(m map[string]*Capabilities) error {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PORT\tAUTONEG\tSPEED\tFEC")
+	for _, name := range names {
+		caps := m[name]
+		autoneg := "no"
+		if caps.Autoneg {
+			autoneg = "yes"
+		}
+		speeds := caps.SortedSpeeds()
+		if len(speeds) == 0 {
+			fmt.Fprintf(w, "%s\t%s\t\t\n", name, autoneg)
+		}
+		for i, speed := range speeds {
+			fecs := strings.Join(append([]string{"auto"},
+				caps.Speeds[speed]...), " ")
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, autoneg,
+					SpeedString(speed), fecs)
+			} else {
+				fmt.Fprintf(w, "\t\t%s\t%s\n", SpeedString(speed),
+					fecs)
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// set merges the given settings with those configured; a value of default
+// removes the setting.
+func set(name string, args ...string) error {
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	p, found := c[name]
+	if !found {
+		p = new(Port)
+	}
+	for i, arg := range args {
+		if arg == "description" && i%2 == 0 && i+1 < len(args) {
+			if args[i+1] == "default" && i+2 == len(args) {
+				p.Description = ""
+			} else {
+				p.Description = strings.Join(args[i+1:], " ")
+			}
+			args = args[:i]
+			break
+		}
+	}
+	if len(args)%2 != 0 {
+		return fmt.Errorf("%s: missing value", args[len(args)-1])
+	}
+	for ; len(args) > 0; args = args[2:] {
+		if !has(Attrs, args[0]) {
+			return fmt.Errorf("%s: unknown", args[0])
+		}
+		if args[1] == "default" {
+			switch args[0] {
+			case "speed":
+				p.Speed = 0
+			case "fec":
+				p.Fec = ""
+			case "autoneg":
+				p.Autoneg = ""
+			case "mtu":
+				p.Mtu = 0
+			case "admin":
+				p.Admin = ""
+			}
+			continue
+		}
+		x, err := ParsePort(args[:2]...)
+		if err != nil {
+			return err
+		}
+		switch args[0] {
+		case "speed":
+			p.Speed = x.Speed
+		case "fec":
+			p.Fec = x.Fec
+		case "autoneg":
+			p.Autoneg = x.Autoneg
+		case "mtu":
+			p.Mtu = x.Mtu
+		case "admin":
+			p.Admin = x.Admin
+		}
+	}
+	_, err = redis.Hset(redis.DefaultHash, Prefix+name, p.String())
+	return err
+}
+
+// Descriptions of the configured ports by name.
+func Descriptions() map[string]string {
+	m := make(map[string]string)
+	if c, err := Get(); err == nil {
+		for name, p := range c {
+			if len(p.Description) > 0 {
+				m[name] = p.Description
+			}
+		}
+	}
+	return m
+}
+
+func (Command) Complete(args ...string) []string {
+	last, prev := complete.Last(args)
+	if len(args) > 0 && args[0] == "-json" {
+		args = args[1:]
+	}
+	switch {
+	case len(args) <= 1:
+		return append(complete.Prefixed(last, "-json", "show",
+			"capabilities"), complete.IfName(last)...)
+	case args[0] == "show" || args[0] == "capabilities":
+		return complete.IfName(last)
+	case prev == "fec":
+		names := []string{"default"}
+		for name := range fecBits {
+			names = append(names, name)
+		}
+		return complete.Prefixed(last, names...)
+	case prev == "autoneg":
+		return complete.Prefixed(last, "on", "off", "default")
+	case prev == "speed":
+		return complete.Prefixed(last, "auto", "default")
+	case prev == "admin":
+		return complete.Prefixed(last, "up", "down", "default")
+	case prev == "mtu":
+		return complete.Prefixed(last, "1500", "9216", "default")
+	case prev == "description":
+		return complete.Prefixed(last, "default")
+	}
+	return complete.Prefixed(last, Attrs...)
+}
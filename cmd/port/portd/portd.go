@@ -0,0 +1,337 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package portd provides a daemon that keeps the redis settable port.*
+// fields and applies the speed, FEC, and autoneg of each port through
+// ethtool and its MTU and admin state through netlink.
+package portd
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/config"
+	"github.com/platinasystems/goes/cmd/port"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	cfg      port.Config
+	hset     chan hset
+	recon    chan reconcile
+	// ifindex of each configured port at its last apply
+	indices map[string]int32
+}
+
+// Portd is the RPC handler of the redis settable port.* fields and of the
+// Reconcile requests of "config reconcile".
+type Portd struct {
+	hset  chan<- hset
+	recon chan<- reconcile
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+type reconcile struct {
+	names []string
+	done  chan reconciled
+}
+
+type reconciled struct {
+	applied []string
+	err     error
+}
+
+func (*Command) String() string { return "portd" }
+
+func (*Command) Usage() string { return "portd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "ethernet port settings daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable fields,
+		port.IFNAME: [speed SPEED] [fec FEC] [autoneg on|off] [mtu MTU]
+			[admin up|down] [description TEXT...]
+	in /etc/goes/persist/port then apply these through ethtool and
+	netlink on start and on each change. The xeth driver relays these
+	to vnetd which programs the switch. The description is set as the
+	interface alias that nld publishes as IFNAME.description.
+
+	portd rejects settings that aren't allowed by the port's supported
+	link modes, i.e. its platform capabilities. A field of a port that
+	doesn't exist is kept and applied once the port is added.
+
+	Since vnetd doesn't keep these, portd reapplies the settings of all
+	ports whenever vnet.ready becomes true, i.e. when vnetd restarts,
+	and those of a port whenever it's recreated. "config reconcile"
+	reapplies these on demand.
+
+FILES
+	/etc/goes/persist/port
+
+SEE ALSO
+	port, config`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if c.settings, err = persist.Load("port"); err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	if c.cfg, err = port.Parse(fields); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", port.Prefix)
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	c.hset = make(chan hset)
+	c.recon = make(chan reconcile)
+	rpc.Register(&Portd{c.hset, c.recon})
+	srvr, err := atsock.NewRpcServer("portd")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", port.Prefix)
+	if err = redis.Assign(key, "portd", "Portd"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	// subscribe before applying to not miss intervening changes
+	sub, err := nl.NewSock(nl.NETLINK_ROUTE, 16, rtnl.RTNLGRP_LINK.Bit())
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+	ready, closer, err := config.VnetReady()
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	c.indices = make(map[string]int32)
+	c.reconcile(nil)
+	wasReady := config.IsVnetReady()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case r := <-c.recon:
+			applied, err := c.reconcile(r.names)
+			r.done <- reconciled{applied, err}
+		case isReady := <-ready:
+			if isReady && !wasReady {
+				log.Print("daemon", "info", "vnet ready, reapply")
+				c.reconcile(nil)
+			}
+			wasReady = isReady
+		case b, opened := <-sub.RxCh:
+			if !opened {
+				return sub.Err
+			}
+			for len(b) >= nl.SizeofHdr {
+				var msg []byte
+				if msg, b, err = nl.Pop(b); err != nil {
+					log.Print("daemon", "err", err)
+					break
+				}
+				if name := c.recreated(msg); len(name) > 0 {
+					c.reconcile([]string{name})
+				}
+			}
+		}
+	}
+}
+
+// recreated returns the name of a configured port of an RTM_NEWLINK
+// message with a new ifindex.
+func (c *Command) recreated(msg []byte) string {
+	var ifla rtnl.Ifla
+	h := nl.HdrPtr(msg)
+	if h == nil || (h.Type != rtnl.RTM_NEWLINK && h.Type != rtnl.RTM_DELLINK) {
+		return ""
+	}
+	ifinfo := rtnl.IfInfoMsgPtr(msg)
+	if ifinfo == nil || ifinfo.Family == rtnl.AF_BRIDGE {
+		return ""
+	}
+	ifla.Write(msg)
+	name := nl.Kstring(ifla[rtnl.IFLA_IFNAME])
+	if _, found := c.cfg[name]; !found {
+		return ""
+	}
+	if h.Type == rtnl.RTM_DELLINK {
+		delete(c.indices, name)
+		return ""
+	}
+	if c.indices[name] == ifinfo.Index {
+		return ""
+	}
+	return name
+}
+
+// reconcile applies the settings of the given, or all, configured ports,
+// logging then returning the first error along with the names of the
+// existing ports.
+func (c *Command) reconcile(names []string) ([]string, error) {
+	var first error
+	var applied []string
+	if len(names) == 0 {
+		names = c.cfg.Names()
+	}
+	for _, name := range names {
+		p, found := c.cfg[name]
+		if !found {
+			err := fmt.Errorf("%s: not configured", name)
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		if _, err := net.InterfaceByName(name); err != nil {
+			continue
+		}
+		applied = append(applied, name)
+		if err := c.apply(name, p); err != nil {
+			log.Print("daemon", "err", err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return applied, first
+}
+
+func (portd *Portd) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	portd.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+// Reconcile reapplies the settings of the given, or all, ports and replies
+// with the names of those that exist.
+func (portd *Portd) Reconcile(names []string, reply *[]string) error {
+	r := reconcile{names, make(chan reconciled, 1)}
+	portd.recon <- r
+	done := <-r.done
+	*reply = done.applied
+	return done.err
+}
+
+// set validates the field with the port's capabilities before saving,
+// publishing, and applying it.
+func (c *Command) set(field, value string) error {
+	value = strings.Join(strings.Fields(value), " ")
+	cfg, err := port.Parse(map[string]string{field: value})
+	if err != nil {
+		return err
+	}
+	name := field[len(port.Prefix):]
+	p := cfg[name]
+	if p != nil {
+		if _, err = net.InterfaceByName(name); err == nil && p.Ethtool() {
+			ls, err := port.GetLinkSettings(name)
+			if err != nil {
+				return err
+			}
+			if err = p.Validate(ls.Capabilities()); err != nil {
+				return fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		value = p.String()
+	}
+	if err = c.settings.Set(field, value); err != nil {
+		return err
+	}
+	if p != nil && len(value) > 0 {
+		c.cfg[name] = p
+		c.pub.Print(field, ": ", value)
+	} else {
+		delete(c.cfg, name)
+		c.pub.Print("delete: ", field)
+		return nil
+	}
+	return c.apply(name, p)
+}
+
+// apply the port's settings if it exists.
+func (c *Command) apply(name string, p *port.Port) error {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil
+	}
+	c.indices[name] = int32(ifi.Index)
+	if err = port.SetLink(name, p); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if !p.Ethtool() {
+		return nil
+	}
+	ls, err := port.GetLinkSettings(name)
+	if err != nil {
+		return err
+	}
+	if err = p.Validate(ls.Capabilities()); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	if err = ls.Apply(name, p); err != nil {
+		return err
+	}
+	if len(p.Fec) > 0 {
+		if configured, _, err := port.GetFec(name); err != nil ||
+			configured != p.Fec {
+			return port.SetFec(name, p.Fec)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package promd
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Namespace of the exported metric names.
+const Namespace = "goes"
+
+// Patterns of the published fields, tried in order, that map the dot
+// separated segments of a field to a metric name and its labels.
+//
+//	{LABEL}		a segment that is the value of LABEL
+//	{LABEL+}	one or more segments that are the value of LABEL
+//	WORD[|WORD]...	a segment that is one of the words
+//	*		the remaining segments, if any
+//
+// Literal and remaining segments form the metric name. The value of an
+// interface label must name a present interface. A field that doesn't
+// match any pattern is named by all of its segments without labels.
+var Patterns = []Pattern{
+	{"goes.daemon.{daemon}.*", ""},
+	{"sensor.{sensor+}.min|max|crit|alarm", ""},
+	{"sensor.{sensor+}", ""},
+	{"fand.{zone}.temp|duty", ""},
+	{"qos.{interface}.queue.{queue}.*", ""},
+	{"acl.{acl}.rule.{seq}.hits", ""},
+	{"fib.{prefix+}.nexthop.{nexthop+}.packets", ""},
+	{"{interface}.*", "link"},
+}
+
+// Pattern of published fields with the Subsystem, if any, that is
+// inserted before the literal segments of the metric name.
+type Pattern struct {
+	Pattern, Subsystem string
+}
+
+// Enums are the last words of metric names with a string value that is
+// exported as a label of that name with a constant value of 1, e.g.
+//
+//	goes.daemon.vnetd.state: running
+//
+// is exported as,
+//
+//	goes_daemon_state{daemon="vnetd",state="running"} 1
+var Enums = []string{"state", "alarm", "policy"}
+
+// Counters are the last words of metric names of monotonic counters;
+// all other metrics are gauges.
+var Counters = []string{
+	"packets",
+	"bytes",
+	"errors",
+	"dropped",
+	"drops",
+	"multicast",
+	"collisions",
+	"compressed",
+	"hits",
+	"restarts",
+	"crashes",
+}
+
+// Filter fields by prefix, e.g. "qos." or "eth-1-1."
+type Filter struct {
+	// Include, if not empty, are the prefixes of exported fields
+	Include []string
+	// Exclude are the prefixes of fields that aren't exported
+	Exclude []string
+}
+
+// Label of a Sample
+type Label struct {
+	Name, Value string
+}
+
+// Sample of a metric
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Match returns true if the field is selected by the filter.
+func (f Filter) Match(field string) bool {
+	for _, prefix := range f.Exclude {
+		if strings.HasPrefix(field, prefix) {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, prefix := range f.Include {
+		if strings.HasPrefix(field, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Collect the samples of the filtered fields that have a numeric, boolean,
+// time, or enumerated value; ifnames are those of the present interfaces.
+func Collect(fields map[string]string, f Filter,
+	ifnames map[string]bool) []Sample {
+	var samples []Sample
+	for field, value := range fields {
+		if !f.Match(field) {
+			continue
+		}
+		if s, ok := sample(field, value, ifnames); ok {
+			samples = append(samples, s)
+		}
+	}
+	return samples
+}
+
+func sample(field, value string, ifnames map[string]bool) (Sample, bool) {
+	var s Sample
+	segs := strings.Split(field, ".")
+	var name []string
+	found := false
+	for _, p := range Patterns {
+		var labels []Label
+		name, labels, found = match(strings.Split(p.Pattern, "."), segs,
+			nil, nil, ifnames)
+		if found {
+			if len(p.Subsystem) > 0 {
+				name = append([]string{p.Subsystem}, name...)
+			}
+			s.Labels = labels
+			break
+		}
+	}
+	if !found {
+		name = segs
+	}
+	if len(name) == 0 {
+		return s, false
+	}
+	s.Name = MetricName(name...)
+	value = strings.TrimSpace(value)
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		s.Value = v
+		return s, true
+	}
+	if isEnum(name[len(name)-1]) {
+		if len(value) == 0 || strings.ContainsAny(value, " \t") {
+			return s, false
+		}
+		s.Labels = append(s.Labels, Label{name[len(name)-1], value})
+		s.Value = 1
+		return s, true
+	}
+	switch value {
+	case "true", "up", "yes", "on":
+		s.Value = 1
+		return s, true
+	case "false", "down", "no", "off":
+		s.Value = 0
+		return s, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		s.Value = float64(t.Unix())
+		return s, true
+	}
+	return s, false
+}
+
+// match the pattern with the field segments returning the literal segments
+// of the metric name and the labels.
+func match(pat, segs, name []string, labels []Label,
+	ifnames map[string]bool) ([]string, []Label, bool) {
+	if len(pat) == 0 {
+		return name, labels, len(segs) == 0
+	}
+	p := pat[0]
+	switch {
+	case p == "*":
+		return append(name, segs...), labels, true
+	case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "+}"):
+		label := p[1 : len(p)-2]
+		for n := 1; n <= len(segs); n++ {
+			l := append(labels[:len(labels):len(labels)],
+				Label{label, strings.Join(segs[:n], ".")})
+			if rn, rl, ok := match(pat[1:], segs[n:], name, l,
+				ifnames); ok {
+				return rn, rl, true
+			}
+		}
+		return nil, nil, false
+	case len(segs) == 0 || len(segs[0]) == 0:
+		return nil, nil, false
+	case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}"):
+		label := p[1 : len(p)-1]
+		if label == "interface" && !ifnames[segs[0]] {
+			return nil, nil, false
+		}
+		l := append(labels[:len(labels):len(labels)],
+			Label{label, segs[0]})
+		return match(pat[1:], segs[1:], name, l, ifnames)
+	}
+	for _, word := range strings.Split(p, "|") {
+		if word == segs[0] {
+			return match(pat[1:], segs[1:],
+				append(name[:len(name):len(name)], word),
+				labels, ifnames)
+		}
+	}
+	return nil, nil, false
+}
+
+// MetricName returns the namespaced, underscore separated words with any
+// character other than a letter, digit, or underscore replaced with an
+// underscore. Words that begin with the namespace aren't prefaced again.
+func MetricName(words ...string) string {
+	if len(words) == 0 || words[0] != Namespace {
+		words = append([]string{Namespace}, words...)
+	}
+	name := strings.Join(words, "_")
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// Type returns counter or gauge by the last word of the metric name.
+func Type(name string) string {
+	if i := strings.LastIndexByte(name, '_'); i >= 0 {
+		name = name[i+1:]
+	}
+	for _, word := range Counters {
+		if name == word {
+			return "counter"
+		}
+	}
+	return "gauge"
+}
+
+func isEnum(word string) bool {
+	for _, enum := range Enums {
+		if word == enum {
+			return true
+		}
+	}
+	return false
+}
+
+// Write the samples in the Prometheus text exposition format sorted by
+// metric name and labels.
+func Write(w io.Writer, samples []Sample) error {
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Name != samples[j].Name {
+			return samples[i].Name < samples[j].Name
+		}
+		return labelString(samples[i].Labels) <
+			labelString(samples[j].Labels)
+	})
+	bw := bufio.NewWriter(w)
+	for i, s := range samples {
+		if i == 0 || s.Name != samples[i-1].Name {
+			bw.WriteString("# TYPE ")
+			bw.WriteString(s.Name)
+			bw.WriteString(" ")
+			bw.WriteString(Type(s.Name))
+			bw.WriteString("\n")
+		}
+		bw.WriteString(s.Name)
+		bw.WriteString(labelString(s.Labels))
+		bw.WriteString(" ")
+		bw.WriteString(strconv.FormatFloat(s.Value, 'f', -1, 64))
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelString(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("{")
+	for i, l := range labels {
+		if i > 0 {
+			sb.WriteString(",")
+		}
+		sb.WriteString(l.Name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelEscaper.Replace(l.Value))
+		sb.WriteString(`"`)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package promd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	fields := map[string]string{
+		"eth-1-1.rx-packets":                       "1234",
+		"eth-1-1.admin":                            "up",
+		"eth-1-1.state":                            "up",
+		"eth-1-1.inet.route":                       "10.0.0.0/8 via 10.1.1.1",
+		"qos.eth-1-1.queue.3.drop-packets":         "56",
+		"sensor.coretemp.temp1":                    "45.5",
+		"sensor.coretemp.temp1.max":                "90",
+		"goes.daemon.vnetd.state":                  "running",
+		"goes.daemon.vnetd.restarts":               "2",
+		"goes.daemon.vnetd.since":                  "2020-01-02T03:04:05Z",
+		"fib.10.1.0.0/16.nexthop.10.2.0.1.packets": "7",
+		"redis.ready":                              "true",
+		"eth-9-9.mtu":                              "1500",
+		"lldp.excluded":                            "1",
+	}
+	var sb strings.Builder
+	Write(&sb, Collect(fields, Filter{Exclude: []string{"lldp."}},
+		map[string]bool{"eth-1-1": true}))
+	want := `# TYPE goes_daemon_restarts counter
+goes_daemon_restarts{daemon="vnetd"} 2
+# TYPE goes_daemon_since gauge
+goes_daemon_since{daemon="vnetd"} 1577934245
+# TYPE goes_daemon_state gauge
+goes_daemon_state{daemon="vnetd",state="running"} 1
+# TYPE goes_eth_9_9_mtu gauge
+goes_eth_9_9_mtu 1500
+# TYPE goes_fib_nexthop_packets counter
+goes_fib_nexthop_packets{prefix="10.1.0.0/16",nexthop="10.2.0.1"} 7
+# TYPE goes_link_admin gauge
+goes_link_admin{interface="eth-1-1"} 1
+# TYPE goes_link_rx_packets counter
+goes_link_rx_packets{interface="eth-1-1"} 1234
+# TYPE goes_link_state gauge
+goes_link_state{interface="eth-1-1",state="up"} 1
+# TYPE goes_qos_queue_drop_packets counter
+goes_qos_queue_drop_packets{interface="eth-1-1",queue="3"} 56
+# TYPE goes_redis_ready gauge
+goes_redis_ready 1
+# TYPE goes_sensor gauge
+goes_sensor{sensor="coretemp.temp1"} 45.5
+# TYPE goes_sensor_max gauge
+goes_sensor_max{sensor="coretemp.temp1"} 90
+`
+	if got := sb.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := Filter{
+		Include: []string{"eth-", "qos."},
+		Exclude: []string{"eth-0."},
+	}
+	for field, want := range map[string]bool{
+		"eth-1-1.rx-packets": true,
+		"eth-0.rx-packets":   false,
+		"qos.eth-1-1.queue":  true,
+		"sensor.cpu":         false,
+	} {
+		if got := f.Match(field); got != want {
+			t.Errorf("%s: got %v, want %v", field, got, want)
+		}
+	}
+}
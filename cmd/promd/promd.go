@@ -0,0 +1,147 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package promd provides a daemon that exports the published redis fields,
+// e.g. interface and queue counters, sensors, and daemon states, as
+// Prometheus metrics.
+package promd
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/lang"
+)
+
+// DefaultListen is the address of the HTTP server without a configured
+// promd.listen.
+const DefaultListen = ":9101"
+
+type Command struct {
+	Listen string
+	Filter
+}
+
+func (*Command) String() string { return "promd" }
+
+func (*Command) Usage() string { return "promd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "prometheus exporter daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Serve the published redis fields as Prometheus metrics at,
+		http://ADDRESS/metrics
+	reading the fields with each scrape.
+
+	Fields with a numeric value are exported as is; those with true, up,
+	yes, or on as 1 and false, down, no, or off as 0; and RFC3339 times
+	as UNIX seconds. Fields ending in state, alarm, or policy are
+	exported with their value as a label of that name and a constant 1.
+	Other fields aren't exported.
+
+	The metric names are the goes_ prefaced, underscore separated
+	segments of the field less those that are labels, e.g.
+		eth-1-1.rx-packets: 1234
+		qos.eth-1-1.queue.3.drop-packets: 56
+		sensor.cpu: 45.5
+		goes.daemon.vnetd.state: running
+	are exported as,
+		goes_link_rx_packets{interface="eth-1-1"} 1234
+		goes_qos_queue_drop_packets{interface="eth-1-1",queue="3"} 56
+		goes_sensor{sensor="cpu"} 45.5
+		goes_daemon_state{daemon="vnetd",state="running"} 1
+
+	The labels are,
+		daemon		of goes.daemon.NAME.*
+		sensor		of sensor.NAME and sensor.NAME.min|max|crit|alarm
+		zone		of fand.NAME.temp|duty
+		interface	of IFNAME.* and qos.IFNAME.*
+		queue		of qos.IFNAME.queue.N.*
+		acl, seq	of acl.NAME.rule.SEQ.hits
+		prefix, nexthop	of fib.PREFIX.nexthop.MEMBER.packets
+
+	Metrics ending in packets, bytes, errors, dropped, drops, multicast,
+	collisions, compressed, hits, restarts, or crashes are counters;
+	all others are gauges.
+
+OPTIONS
+	The machine configuration may change the listening address and
+	filter the exported fields by prefix; without include, all fields
+	not excluded are exported.
+
+FILES
+	/etc/goes/machine.yaml
+		promd:
+		  listen: ":9101"
+		  include: [eth-, qos., sensor., goes.daemon.]
+		  exclude: [eth-0.]
+
+SEE ALSO
+	sensorsd, qosd, ip link counters`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	c.configure(machine.Default())
+	ln, err := net.Listen("tcp", c.Listen)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", c.metrics)
+	srv := &http.Server{Handler: mux}
+	defer srv.Close()
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	select {
+	case <-goes.Stop:
+		return nil
+	case err = <-done:
+		return err
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) {
+	c.Listen = cfg.String("promd.listen", c.Listen)
+	if len(c.Listen) == 0 {
+		c.Listen = DefaultListen
+	}
+	c.Include = cfg.Strings("promd.include", c.Include)
+	c.Exclude = cfg.Strings("promd.exclude", c.Exclude)
+}
+
+func (c *Command) metrics(w http.ResponseWriter, r *http.Request) {
+	fields, err := redis.Hgetall(redis.DefaultHash, "")
+	if err != nil {
+		log.Print("daemon", "err", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	ifnames := make(map[string]bool)
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifs {
+			ifnames[ifi.Name] = true
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	Write(w, Collect(fields, c.Filter, ifnames))
+}
@@ -0,0 +1,72 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package psud
+
+import (
+	"math"
+	"strings"
+)
+
+// PMBus commands
+const (
+	VoutMode    = 0x20
+	StatusWord  = 0x79
+	ReadVin     = 0x88
+	ReadIin     = 0x89
+	ReadVout    = 0x8b
+	ReadIout    = 0x8c
+	ReadTemp1   = 0x8d
+	ReadFan1    = 0x90
+	ReadPout    = 0x96
+	ReadPin     = 0x97
+	MfrModel    = 0x9a
+	MfrSerial   = 0x9e
+	MfrRevision = 0x9b
+)
+
+// Linear11 returns the value of a PMBus LINEAR11 word, an 11 bit two's
+// complement mantissa with a 5 bit two's complement exponent.
+func Linear11(w uint16) float64 {
+	exp := int(int16(w) >> 11)
+	mant := int(int16(w<<5) >> 5)
+	return float64(mant) * math.Pow(2, float64(exp))
+}
+
+// Linear16 returns the value of a PMBus LINEAR16 VOUT word with the
+// exponent of the given VOUT_MODE.
+func Linear16(w uint16, mode uint8) float64 {
+	exp := int(int8(mode<<3) >> 3)
+	return float64(w) * math.Pow(2, float64(exp))
+}
+
+var statusBits = []string{
+	15: "vout",
+	14: "iout-pout",
+	13: "input",
+	12: "mfr",
+	11: "power-good#",
+	10: "fans",
+	9:  "other",
+	8:  "unknown",
+	7:  "busy",
+	6:  "off",
+	5:  "vout-ov",
+	4:  "iout-oc",
+	3:  "vin-uv",
+	2:  "temperature",
+	1:  "cml",
+	0:  "none-of-the-above",
+}
+
+// Faults returns the space separated names of the set STATUS_WORD bits.
+func Faults(status uint16) string {
+	var faults []string
+	for bit := len(statusBits) - 1; bit >= 0; bit-- {
+		if status&(1<<uint(bit)) != 0 {
+			faults = append(faults, statusBits[bit])
+		}
+	}
+	return strings.Join(faults, " ")
+}
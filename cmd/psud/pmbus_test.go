@@ -0,0 +1,28 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package psud
+
+import "testing"
+
+func TestLinear(t *testing.T) {
+	for w, want := range map[uint16]float64{
+		0xf0c8: 200 * 0.25, // exp -2, mant 200
+		0x0001: 1,          // exp 0, mant 1
+		0x03ff: 1023,       // max positive mantissa
+		0xe7ff: -1.0 / 16,  // exp -4, mant -1
+		0x1803: 3 * 8,      // exp 3, mant 3
+		0xd23c: 572.0 / 64, // exp -6, mant 572
+	} {
+		if got := Linear11(w); got != want {
+			t.Errorf("Linear11(%#04x) = %v, want %v", w, got, want)
+		}
+	}
+	if got := Linear16(0x1800, 0x17); got != 12 {
+		t.Errorf("Linear16 = %v, want 12", got)
+	}
+	if got := Faults(0x0844); got != "power-good# off temperature" {
+		t.Errorf("Faults = %q", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package psud provides a daemon that polls PMBus power supplies and
+// publishes their readings and faults as psu.N.* redis fields.
+package psud
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/gpiod"
+	"github.com/platinasystems/goes/external/i2c"
+	"github.com/platinasystems/goes/external/led"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Psu is a PMBus power supply at Address on Bus.
+type Psu struct {
+	Bus, Address int
+
+	// Present, if set, is the supply's presence pin; otherwise, the supply
+	// is present while it responds.
+	Present *gpiod.Pin
+
+	present bool
+	faults  string
+}
+
+type Command struct {
+	// Machines list their power supplies, published as psu.1, psu.2, ...
+	// The machine configuration file may override these.
+	Psus []Psu
+
+	// Interval between polls, default: 5s
+	Interval time.Duration
+
+	pub *publisher.Publisher
+	// last shown health by LED name
+	leds map[string]bool
+}
+
+func (*Command) String() string { return "psud" }
+
+func (*Command) Usage() string { return "psud" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "PMBus power supply monitor daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Periodically poll each PMBus power supply and publish,
+		psu.N.present: true|false
+		psu.N.model: MFR_MODEL
+		psu.N.serial: MFR_SERIAL
+		psu.N.vin: V
+		psu.N.iin: A
+		psu.N.pin: W
+		psu.N.vout: V
+		psu.N.iout: A
+		psu.N.pout: W
+		psu.N.temp: °C
+		psu.N.fan: RPM
+		psu.N.status: STATUS_WORD
+		psu.N.faults: "..."
+
+	psud logs and publishes an event on each removal, insertion, or
+	change of faults, e.g.
+		psu.N.event: removed|inserted|fault power-good# off|ok
+
+	The "psuN" LED, if any, is green while the supply is present without
+	faults, otherwise, amber; the "psu" LED is amber if any supply isn't
+	green.
+
+FILES
+	/etc/goes/machine.yaml
+		psud:
+		  interval: 5s
+		  psus:
+		    - bus: 1
+		      address: 0x58
+		      present: gpiochip1 4 active-low
+		    - bus: 1
+		      address: 0x59
+
+SEE ALSO
+	gpio, i2c`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if len(c.Psus) == 0 {
+		return fmt.Errorf("no power supplies")
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	for i := range c.Psus {
+		c.Psus[i].faults = "-"
+		c.pub.Printf("psu.%d.present: false", i+1)
+	}
+	c.leds = make(map[string]bool)
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		healthy := true
+		for i := range c.Psus {
+			psu := &c.Psus[i]
+			c.poll(i+1, psu)
+			ok := psu.present && len(psu.faults) == 0
+			c.health(fmt.Sprint("psu", i+1), ok)
+			healthy = healthy && ok
+		}
+		c.health("psu", healthy)
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// configure overrides the machine's compiled in power supplies with those of
+// psud in the machine configuration file.
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	if c.Interval, err = cfg.Duration("psud.interval", c.Interval); err != nil {
+		return
+	}
+	if !cfg.Has("psud.psus") {
+		return
+	}
+	var psus []Psu
+	for _, i := range cfg.Keys("psud.psus") {
+		var psu Psu
+		prefix := "psud.psus." + i + "."
+		if psu.Bus, err = cfg.Int(prefix+"bus", 0); err != nil {
+			return
+		}
+		if psu.Address, err = cfg.Int(prefix+"address", 0); err != nil {
+			return
+		}
+		if psu.Address == 0 {
+			return fmt.Errorf("%saddress: missing", prefix)
+		}
+		if s := cfg.String(prefix+"present", ""); len(s) > 0 {
+			pin, err := gpiod.ParsePin(s)
+			if err != nil {
+				return fmt.Errorf("%spresent: %v", prefix, err)
+			}
+			psu.Present = &pin
+		}
+		psus = append(psus, psu)
+	}
+	c.Psus = psus
+	return
+}
+
+func (c *Command) poll(n int, psu *Psu) {
+	present := true
+	if psu.Present != nil {
+		present, _ = psu.Present.Value()
+	}
+	var err error
+	if present {
+		err = i2c.Do(psu.Bus, psu.Address, func(bus *i2c.Bus) error {
+			if err := bus.Lock(); err != nil {
+				return err
+			}
+			return c.read(n, psu, bus)
+		})
+		present = err == nil || psu.Present != nil
+	}
+	if present != psu.present {
+		psu.present = present
+		c.pub.Printf("psu.%d.present: %v", n, present)
+		if present {
+			c.event(n, "inserted")
+		} else {
+			c.pub.Printf("delete: psu.%d.", n)
+			c.pub.Printf("psu.%d.present: false", n)
+			c.event(n, "removed")
+			psu.faults = "-"
+		}
+	}
+	if present && err != nil {
+		c.pub.Printf("psu.%d.faults: %q", n, err.Error())
+	}
+}
+
+// read and publish the supply's PMBus registers.
+func (c *Command) read(n int, psu *Psu, bus *i2c.Bus) error {
+	word := func(cmd uint8) (uint16, error) {
+		var data i2c.SMBusData
+		err := bus.Read(cmd, i2c.WordData, &data)
+		return uint16(data[1])<<8 | uint16(data[0]), err
+	}
+	status, err := word(StatusWord)
+	if err != nil {
+		return err
+	}
+	if !psu.present {
+		// identify the newly inserted supply
+		for name, cmd := range map[string]uint8{
+			"model":    MfrModel,
+			"serial":   MfrSerial,
+			"revision": MfrRevision,
+		} {
+			var data i2c.SMBusData
+			if bus.Read(cmd, i2c.BlockData, &data) == nil {
+				l := int(data[0])
+				if l > i2c.SMBusMax {
+					l = i2c.SMBusMax
+				}
+				s := strings.TrimSpace(string(data[1 : 1+l]))
+				c.pub.Printf("psu.%d.%s: %s", n, name, s)
+			}
+		}
+	}
+	for _, x := range []struct {
+		field, format string
+		cmd           uint8
+	}{
+		{"vin", "%.2f", ReadVin},
+		{"iin", "%.2f", ReadIin},
+		{"pin", "%.1f", ReadPin},
+		{"iout", "%.2f", ReadIout},
+		{"pout", "%.1f", ReadPout},
+		{"temp", "%.1f", ReadTemp1},
+		{"fan", "%.0f", ReadFan1},
+	} {
+		if w, err := word(x.cmd); err == nil {
+			c.pub.Printf("psu.%d.%s: "+x.format, n, x.field,
+				Linear11(w))
+		}
+	}
+	var mode i2c.SMBusData
+	if bus.Read(VoutMode, i2c.ByteData, &mode) == nil {
+		if w, err := word(ReadVout); err == nil {
+			c.pub.Printf("psu.%d.vout: %.2f", n,
+				Linear16(w, mode[0]))
+		}
+	}
+	c.pub.Printf("psu.%d.status: %#04x", n, status)
+	faults := Faults(status)
+	if faults != psu.faults {
+		c.pub.Printf("psu.%d.faults: %q", n, faults)
+		if len(faults) > 0 {
+			c.event(n, "fault "+faults)
+		} else if psu.faults != "-" {
+			c.event(n, "ok")
+		}
+		psu.faults = faults
+	}
+	return nil
+}
+
+// health shows the named LED, if any, as green or amber on change.
+func (c *Command) health(name string, ok bool) {
+	if was, found := c.leds[name]; found && was == ok {
+		return
+	}
+	c.leds[name] = ok
+	if err := led.Health(name, ok); err != nil {
+		log.Print("daemon", "err", "led: ", err)
+	}
+}
+
+func (c *Command) event(n int, s string) {
+	if strings.HasPrefix(s, "fault") {
+		log.Print("daemon", "err", "psu", n, ": ", s)
+	} else {
+		log.Print("daemon", "info", "psu", n, ": ", s)
+	}
+	c.pub.Printf("psu.%d.event: %s", n, s)
+}
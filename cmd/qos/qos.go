@@ -0,0 +1,216 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package qos provides the schema of the redis qos.* fields of the per
+// port, per queue enqueue and drop counters published by qosd, along with
+// a command to show these.
+package qos
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/cmd/port"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Prefix of the redis fields,
+//
+//	qos.IFNAME.queue.N.enqueue-packets: COUNT
+//	qos.IFNAME.queue.N.enqueue-bytes: COUNT
+//	qos.IFNAME.queue.N.drop-packets: COUNT
+//	qos.IFNAME.queue.N.drop-bytes: COUNT
+const Prefix = "qos."
+
+const queueInfix = ".queue."
+
+type Command struct{}
+
+func (Command) String() string { return "qos" }
+
+func (Command) Usage() string {
+	return "qos [-json] [show] [interface] [IFNAME]..."
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show per queue enqueue and drop counters",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the enqueued and dropped packets and bytes of each egress queue
+	of all, or the given, interfaces as published by qosd.
+
+	These are the redis fields,
+		qos.IFNAME.queue.N.enqueue-packets: COUNT
+		qos.IFNAME.queue.N.enqueue-bytes: COUNT
+		qos.IFNAME.queue.N.drop-packets: COUNT
+		qos.IFNAME.queue.N.drop-bytes: COUNT
+
+EXAMPLES
+	qos show interface eth-1-1
+	qos -json
+
+SEE ALSO
+	qosd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "interface" {
+		args = args[1:]
+	}
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return err
+	}
+	m := Parse(fields)
+	if len(args) > 0 {
+		selected := make(map[string][]*Queue)
+		for _, name := range args {
+			queues, found := m[name]
+			if !found {
+				return fmt.Errorf("%s: no queue counters", name)
+			}
+			selected[name] = queues
+		}
+		m = selected
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(m, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "INTERFACE\tQUEUE\tENQUEUE-PACKETS\tENQUEUE-BYTES\t"+
+		"DROP-PACKETS\tDROP-BYTES\tDESCRIPTION")
+	descriptions := port.Descriptions()
+	for _, name := range Names(m) {
+		description := descriptions[name]
+		for _, q := range m[name] {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%s\n", name,
+				q.Queue, q.EnqueuePackets, q.EnqueueBytes,
+				q.DropPackets, q.DropBytes, description)
+			description = ""
+		}
+	}
+	return w.Flush()
+}
+
+// Fields of the interface queue counters.
+func Fields(ifname string, queues []*Queue) map[string]string {
+	fields := make(map[string]string)
+	for _, q := range queues {
+		pre := fmt.Sprint(Prefix, ifname, queueInfix, q.Queue, ".")
+		fields[pre+"enqueue-packets"] = strconv.FormatUint(q.EnqueuePackets,
+			10)
+		fields[pre+"enqueue-bytes"] = strconv.FormatUint(q.EnqueueBytes, 10)
+		fields[pre+"drop-packets"] = strconv.FormatUint(q.DropPackets, 10)
+		fields[pre+"drop-bytes"] = strconv.FormatUint(q.DropBytes, 10)
+	}
+	return fields
+}
+
+// Parse the qos.* fields into the sorted queue counters of each interface;
+// others are ignored.
+func Parse(fields map[string]string) map[string][]*Queue {
+	byName := make(map[string]map[int]*Queue)
+	for field, value := range fields {
+		if !strings.HasPrefix(field, Prefix) {
+			continue
+		}
+		s := field[len(Prefix):]
+		i := strings.LastIndex(s, queueInfix)
+		if i <= 0 {
+			continue
+		}
+		name := s[:i]
+		s = s[i+len(queueInfix):]
+		j := strings.Index(s, ".")
+		if j <= 0 {
+			continue
+		}
+		n, err := strconv.Atoi(s[:j])
+		if err != nil {
+			continue
+		}
+		count, _ := strconv.ParseUint(value, 10, 64)
+		queues, found := byName[name]
+		if !found {
+			queues = make(map[int]*Queue)
+			byName[name] = queues
+		}
+		q, found := queues[n]
+		if !found {
+			q = &Queue{Queue: n}
+			queues[n] = q
+		}
+		switch s[j+1:] {
+		case "enqueue-packets":
+			q.EnqueuePackets = count
+		case "enqueue-bytes":
+			q.EnqueueBytes = count
+		case "drop-packets":
+			q.DropPackets = count
+		case "drop-bytes":
+			q.DropBytes = count
+		}
+	}
+	m := make(map[string][]*Queue)
+	for name, queues := range byName {
+		list := make([]*Queue, 0, len(queues))
+		for _, q := range queues {
+			list = append(list, q)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].Queue < list[j].Queue
+		})
+		m[name] = list
+	}
+	return m
+}
+
+// Names of the interfaces, sorted.
+func Names(m map[string][]*Queue) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	words := []string{"-json", "show", "interface"}
+	for _, arg := range args {
+		for i, word := range words {
+			if arg == word {
+				words = words[i+1:]
+				break
+			}
+		}
+	}
+	return append(complete.Prefixed(last, words...),
+		complete.IfName(last)...)
+}
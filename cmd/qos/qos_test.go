@@ -0,0 +1,38 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package qos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueues(t *testing.T) {
+	queues := Queues(map[string]uint64{
+		"port tx cos0 packets":                100,
+		"port tx cos0 bytes":                  6400,
+		"mmu unicast tx cos0 drop packets":    3,
+		"mmu multicast tx cos0 drop packets":  2,
+		"port_tx_cos7_drop_bytes":             128,
+		"rx_queue_0_packets":                  99,
+		"rx bytes":                            1,
+		"tx_queue_7_packets":                  5,
+		"cos1 something that isn't a counter": 1,
+	})
+	expect := []*Queue{
+		{Queue: 0, EnqueuePackets: 100, EnqueueBytes: 6400,
+			DropPackets: 5},
+		{Queue: 7, EnqueuePackets: 5, DropBytes: 128},
+	}
+	if !reflect.DeepEqual(queues, expect) {
+		for _, q := range queues {
+			t.Errorf("%+v", *q)
+		}
+	}
+	m := Parse(Fields("eth-1-1", queues))
+	if !reflect.DeepEqual(m, map[string][]*Queue{"eth-1-1": expect}) {
+		t.Errorf("%v", m)
+	}
+}
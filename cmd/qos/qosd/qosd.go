@@ -0,0 +1,130 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package qosd provides a daemon that publishes the per queue enqueue and
+// drop counters of each port to the redis qos.* fields.
+package qosd
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/qos"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Interval of the counter poll, default: 5s
+	Interval time.Duration
+
+	pub *publisher.Publisher
+	// published counter fields
+	published map[string]string
+}
+
+func (*Command) String() string { return "qosd" }
+
+func (*Command) Usage() string { return "qosd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "queue counter daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Each interval, publish the changed per queue counters of each port,
+		qos.IFNAME.queue.N.enqueue-packets: COUNT
+		qos.IFNAME.queue.N.enqueue-bytes: COUNT
+		qos.IFNAME.queue.N.drop-packets: COUNT
+		qos.IFNAME.queue.N.drop-bytes: COUNT
+
+	These are the sum of the port's transmit ethtool statistics with a
+	queue or cos number, e.g. "port tx cos3 drop bytes", that vnetd
+	relays through the xeth driver from the switch MMU.
+
+FILES
+	/etc/goes/machine.yaml
+		qosd:
+		  interval: 5s
+
+SEE ALSO
+	qos`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if c.Interval == 0 {
+		c.Interval = 5 * time.Second
+	}
+	if c.Interval, err = machine.Default().Duration("qosd.interval",
+		c.Interval); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", qos.Prefix)
+	c.published = make(map[string]string)
+	c.poll()
+
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+			c.poll()
+		}
+	}
+}
+
+// poll the statistics of each interface and publish the changed
+// counters of those with queues.
+func (c *Command) poll() {
+	fields := make(map[string]string)
+	if ifs, err := net.Interfaces(); err == nil {
+		for _, ifi := range ifs {
+			if ifi.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+			stats, err := qos.GetStats(ifi.Name)
+			if err != nil {
+				continue
+			}
+			queues := qos.Queues(stats)
+			for field, value := range qos.Fields(ifi.Name, queues) {
+				fields[field] = value
+			}
+		}
+	}
+	for field, value := range fields {
+		if c.published[field] != value {
+			c.pub.Print(field, ": ", value)
+		}
+	}
+	for field := range c.published {
+		if _, found := fields[field]; !found {
+			c.pub.Print("delete: ", field)
+		}
+	}
+	c.published = fields
+}
@@ -0,0 +1,152 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package qos
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// linux/ethtool.h
+const (
+	siocEthtool       = 0x8946
+	ethtoolGStrings   = 0x1b
+	ethtoolGStats     = 0x1d
+	ethtoolGSsetInfo  = 0x37
+	ethSsStats        = 1
+	ethGstringLen     = 32
+	maxStats          = 1 << 16
+	sizeofGStringsHdr = 12
+)
+
+// Queue counters of an egress port queue, i.e. class of service.
+type Queue struct {
+	Queue          int    `json:"queue"`
+	EnqueuePackets uint64 `json:"enqueue-packets"`
+	EnqueueBytes   uint64 `json:"enqueue-bytes"`
+	DropPackets    uint64 `json:"drop-packets"`
+	DropBytes      uint64 `json:"drop-bytes"`
+}
+
+var queueStat = regexp.MustCompile(`\b(?:cos|queue|q) ?([0-9]+)\b`)
+
+// GetStats returns the ethtool statistics of the device by name.
+func GetStats(dev string) (map[string]uint64, error) {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer syscall.Close(fd)
+	var info struct {
+		cmd, reserved uint32
+		mask          uint64
+		n             uint32
+	}
+	info.cmd = ethtoolGSsetInfo
+	info.mask = 1 << ethSsStats
+	if err = ethtool(fd, dev, unsafe.Pointer(&info)); err != nil {
+		return nil, fmt.Errorf("%s: stats: %v", dev, err)
+	}
+	if info.mask == 0 || info.n == 0 {
+		return map[string]uint64{}, nil
+	}
+	n := int(info.n)
+	if n > maxStats {
+		return nil, fmt.Errorf("%s: stats: %d: too many", dev, n)
+	}
+	// uint64 buffers to align the statistics
+	strs := make([]uint64, (sizeofGStringsHdr+n*ethGstringLen+7)/8)
+	hdr := (*[3]uint32)(unsafe.Pointer(&strs[0]))
+	hdr[0], hdr[1], hdr[2] = ethtoolGStrings, ethSsStats, uint32(n)
+	if err = ethtool(fd, dev, unsafe.Pointer(&strs[0])); err != nil {
+		return nil, fmt.Errorf("%s: stat names: %v", dev, err)
+	}
+	stats := make([]uint64, 1+n)
+	shdr := (*[2]uint32)(unsafe.Pointer(&stats[0]))
+	shdr[0], shdr[1] = ethtoolGStats, uint32(n)
+	if err = ethtool(fd, dev, unsafe.Pointer(&stats[0])); err != nil {
+		return nil, fmt.Errorf("%s: stats: %v", dev, err)
+	}
+	b := (*[1 << 30]byte)(unsafe.Pointer(&strs[0]))[:8*len(strs)]
+	b = b[sizeofGStringsHdr : sizeofGStringsHdr+n*ethGstringLen]
+	m := make(map[string]uint64, n)
+	for i := 0; i < n; i++ {
+		name := b[i*ethGstringLen : (i+1)*ethGstringLen]
+		if j := bytes.IndexByte(name, 0); j >= 0 {
+			name = name[:j]
+		}
+		m[string(name)] += stats[1+i]
+	}
+	return m, nil
+}
+
+// Queues of the egress per queue statistics, e.g. "port tx cos3 drop
+// bytes" or "tx_queue_3_packets". The sum of several statistics of a queue
+// and kind, e.g. unicast and multicast drops, is that of the queue. Receive
+// statistics are ignored.
+func Queues(stats map[string]uint64) []*Queue {
+	m := make(map[int]*Queue)
+	for name, n := range stats {
+		s := strings.ToLower(strings.NewReplacer("_", " ", "-", " ",
+			".", " ").Replace(name))
+		match := queueStat.FindStringSubmatch(s)
+		if match == nil || strings.Contains(" "+s+" ", " rx ") {
+			continue
+		}
+		var counter *uint64
+		i, _ := strconv.Atoi(match[1])
+		q, found := m[i]
+		if !found {
+			q = &Queue{Queue: i}
+		}
+		drop := strings.Contains(s, "drop")
+		switch {
+		case strings.Contains(s, "byte") || strings.Contains(s, "octet"):
+			counter = &q.EnqueueBytes
+			if drop {
+				counter = &q.DropBytes
+			}
+		case strings.Contains(s, "packet") || strings.Contains(s, "pkt"):
+			counter = &q.EnqueuePackets
+			if drop {
+				counter = &q.DropPackets
+			}
+		default:
+			continue
+		}
+		*counter += n
+		m[i] = q
+	}
+	queues := make([]*Queue, 0, len(m))
+	for _, q := range m {
+		queues = append(queues, q)
+	}
+	sort.Slice(queues, func(i, j int) bool {
+		return queues[i].Queue < queues[j].Queue
+	})
+	return queues
+}
+
+func ethtool(fd int, dev string, data unsafe.Pointer) error {
+	var ifr struct {
+		name [syscall.IFNAMSIZ]byte
+		data uintptr
+		pad  [16]byte
+	}
+	copy(ifr.name[:syscall.IFNAMSIZ-1], dev)
+	ifr.data = uintptr(data)
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
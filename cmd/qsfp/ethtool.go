@@ -0,0 +1,84 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package qsfp
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// linux/ethtool.h
+const (
+	siocEthtool          = 0x8946
+	ethtoolGModuleInfo   = 0x42
+	ethtoolGModuleEeprom = 0x43
+)
+
+// Read returns the module eeprom type and dump of the given device, either
+// a net device name, through ethtool, or the path of an optoe or sfp driver
+// eeprom file, e.g. /sys/bus/i2c/devices/11-0050/eeprom.
+func Read(dev string) (uint32, []byte, error) {
+	if strings.HasPrefix(dev, "/") {
+		return readFile(dev)
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer syscall.Close(fd)
+	var info struct {
+		cmd, typ, len uint32
+		reserved      [8]uint32
+	}
+	info.cmd = ethtoolGModuleInfo
+	if err = ethtool(fd, dev, unsafe.Pointer(&info)); err != nil {
+		return 0, nil, fmt.Errorf("%s: module info: %v", dev, err)
+	}
+	buf := make([]byte, 16+info.len)
+	*(*uint32)(unsafe.Pointer(&buf[0])) = ethtoolGModuleEeprom
+	*(*uint32)(unsafe.Pointer(&buf[12])) = info.len
+	if err = ethtool(fd, dev, unsafe.Pointer(&buf[0])); err != nil {
+		return 0, nil, fmt.Errorf("%s: module eeprom: %v", dev, err)
+	}
+	return info.typ, buf[16:], nil
+}
+
+func ethtool(fd int, dev string, data unsafe.Pointer) error {
+	var ifr struct {
+		name [syscall.IFNAMSIZ]byte
+		data uintptr
+		pad  [16]byte
+	}
+	copy(ifr.name[:syscall.IFNAMSIZ-1], dev)
+	ifr.data = uintptr(data)
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		siocEthtool, uintptr(unsafe.Pointer(&ifr)))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// readFile infers the eeprom type from its SFF-8024 identifier; SFP files
+// include A2h at offset 256 if the driver maps it.
+func readFile(fn string) (uint32, []byte, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(b) == 0 {
+		return 0, nil, fmt.Errorf("%s: empty", fn)
+	}
+	switch b[0] {
+	case 0x03:
+		return Sff8472, b, nil
+	case 0x0c, 0x0d:
+		return Sff8436, b, nil
+	}
+	return Sff8636, b, nil
+}
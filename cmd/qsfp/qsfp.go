@@ -0,0 +1,213 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package qsfp provides a command that reads and decodes the identification
+// and digital optical monitoring of SFP and QSFP transceivers.
+package qsfp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Ports maps port names to either a net device name, read through
+	// ethtool, or the path of an eeprom file. If neither these nor the
+	// machine configuration lists any, all net devices are tried.
+	Ports map[string]string
+}
+
+func (*Command) String() string { return "qsfp" }
+
+func (*Command) Usage() string { return "qsfp [-json] [PORT]..." }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show transceiver identification and monitors",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Read and decode the SFF-8472 (SFP) or SFF-8636 (QSFP) eeprom of each,
+	or the given, PORT's transceiver and print its identifier, vendor,
+	part and serial numbers, and digital optical monitors, i.e.
+	temperature, supply voltage, and each channel's laser bias, transmit
+	and receive power. Ports without a module are skipped.
+
+OPTIONS
+	-json	print a JSON array of modules
+
+FILES
+	/etc/goes/machine.yaml
+		qsfp:
+		  ports:
+		    eth-1-1: eth-1-1
+		    xe1: /sys/bus/i2c/devices/11-0050/eeprom
+
+SEE ALSO
+	qsfpd`,
+	}
+}
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-json")
+	ports, err := c.ports(args)
+	if err != nil {
+		return err
+	}
+	var modules []*Module
+	for _, port := range ports {
+		m, err := c.Module(port)
+		if err != nil {
+			if len(args) > 0 {
+				fmt.Fprintln(os.Stderr, port, ":", err)
+			}
+			continue
+		}
+		modules = append(modules, m)
+	}
+	if flag.ByName["-json"] {
+		if modules == nil {
+			modules = []*Module{}
+		}
+		b, err := json.MarshalIndent(modules, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	for _, m := range modules {
+		m.Format(buf)
+	}
+	_, err = os.Stdout.Write(buf.Bytes())
+	return err
+}
+
+// Module returns the decoded transceiver of the named port.
+func (c *Command) Module(port string) (*Module, error) {
+	dev, found := c.Ports[port]
+	if !found {
+		dev = port
+	}
+	typ, b, err := Read(dev)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Decode(typ, b)
+	if err != nil {
+		return nil, err
+	}
+	m.Port = port
+	return m, nil
+}
+
+// ports returns the sorted names of the given, configured, or all net
+// device ports.
+func (c *Command) ports(args []string) ([]string, error) {
+	if err := c.Configure(machine.Default()); err != nil {
+		return nil, err
+	}
+	if len(args) > 0 {
+		return args, nil
+	}
+	var ports []string
+	if len(c.Ports) > 0 {
+		for port := range c.Ports {
+			ports = append(ports, port)
+		}
+	} else {
+		fis, err := ioutil.ReadDir("/sys/class/net")
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range fis {
+			if fi.Name() != "lo" {
+				ports = append(ports, fi.Name())
+			}
+		}
+	}
+	sort.Strings(ports)
+	return ports, nil
+}
+
+// Configure adds or replaces Ports with qsfp.ports of the machine
+// configuration file.
+func (c *Command) Configure(cfg *machine.Config) error {
+	keys := cfg.Keys("qsfp.ports")
+	if len(keys) > 0 && c.Ports == nil {
+		c.Ports = make(map[string]string)
+	}
+	for _, port := range keys {
+		dev := cfg.String("qsfp.ports."+port, "")
+		if len(dev) == 0 {
+			return fmt.Errorf("qsfp.ports.%s: missing device", port)
+		}
+		c.Ports[port] = dev
+	}
+	return nil
+}
+
+// Format the module as human-readable text.
+func (m *Module) Format(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "%s:\n", m.Port)
+	fmt.Fprintf(buf, "\tidentifier: %s\n", m.Identifier)
+	fmt.Fprintf(buf, "\tvendor: %s\n", m.Vendor)
+	fmt.Fprintf(buf, "\tpart number: %s\n", m.PartNumber)
+	fmt.Fprintf(buf, "\trevision: %s\n", m.Revision)
+	fmt.Fprintf(buf, "\tserial: %s\n", m.Serial)
+	fmt.Fprintf(buf, "\tdate: %s\n", m.Date)
+	if !m.Dom {
+		return
+	}
+	fmt.Fprintf(buf, "\ttemperature: %.1f °C\n", m.Temp)
+	fmt.Fprintf(buf, "\tvcc: %.3f V\n", m.Vcc)
+	fmt.Fprintf(buf, "\t%-8s%12s%16s%16s\n", "channel", "bias (mA)",
+		"tx power (dBm)", "rx power (dBm)")
+	for i, ch := range m.Channels {
+		fmt.Fprintf(buf, "\t%-8d%12.2f%16.2f%16.2f\n", i+1, ch.TxBias,
+			ch.TxPower, ch.RxPower)
+	}
+}
+
+// Publish the module's fields as qsfp.PORT.FIELD
+func (m *Module) Publish(pub *publisher.Publisher) {
+	prefix := "qsfp." + m.Port + "."
+	pub.Print(prefix, "identifier: ", m.Identifier)
+	pub.Print(prefix, "vendor: ", m.Vendor)
+	pub.Print(prefix, "part_number: ", m.PartNumber)
+	pub.Print(prefix, "revision: ", m.Revision)
+	pub.Print(prefix, "serial: ", m.Serial)
+	pub.Print(prefix, "date: ", m.Date)
+	if !m.Dom {
+		return
+	}
+	pub.Printf("%stemp: %.1f", prefix, m.Temp)
+	pub.Printf("%svcc: %.3f", prefix, m.Vcc)
+	for i, ch := range m.Channels {
+		pub.Printf("%sch%d.tx_bias: %.2f", prefix, i+1, ch.TxBias)
+		pub.Printf("%sch%d.tx_power: %.2f", prefix, i+1, ch.TxPower)
+		pub.Printf("%sch%d.rx_power: %.2f", prefix, i+1, ch.RxPower)
+	}
+}
+
+func (*Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	return append(complete.Prefixed(last, "-json"),
+		complete.IfName(last)...)
+}
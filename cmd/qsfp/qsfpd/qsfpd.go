@@ -0,0 +1,153 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package qsfpd provides a daemon that polls the transceiver of each port
+// and publishes its identification and monitors as qsfp.PORT.FIELD.
+package qsfpd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/qsfp"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Ports as those of the qsfp command
+	Ports map[string]string
+
+	// Interval between polls, default: 5s
+	Interval time.Duration
+
+	pub *publisher.Publisher
+	// serial number of present modules by port
+	present map[string]string
+}
+
+func (*Command) String() string { return "qsfpd" }
+
+func (*Command) Usage() string { return "qsfpd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "transceiver monitor daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Periodically read the transceiver of each port and publish,
+		qsfp.PORT.present: true|false
+		qsfp.PORT.identifier: SFP/SFP+/SFP28|QSFP+|QSFP28|...
+		qsfp.PORT.vendor: NAME
+		qsfp.PORT.part_number: PN
+		qsfp.PORT.revision: REV
+		qsfp.PORT.serial: SN
+		qsfp.PORT.date: YYMMDD
+		qsfp.PORT.temp: °C
+		qsfp.PORT.vcc: V
+		qsfp.PORT.chN.tx_bias: mA
+		qsfp.PORT.chN.tx_power: dBm
+		qsfp.PORT.chN.rx_power: dBm
+
+	qsfpd logs each module insertion and removal.
+
+FILES
+	/etc/goes/machine.yaml
+		qsfpd:
+		  interval: 5s
+		qsfp:
+		  ports:
+		    eth-1-1: eth-1-1
+
+SEE ALSO
+	qsfp`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	cfg := machine.Default()
+	if c.Interval, err = cfg.Duration("qsfpd.interval", c.Interval); err != nil {
+		return err
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	q := &qsfp.Command{Ports: c.Ports}
+	if err = q.Configure(cfg); err != nil {
+		return err
+	}
+	if len(q.Ports) == 0 {
+		q.Ports = make(map[string]string)
+		fis, err := ioutil.ReadDir("/sys/class/net")
+		if err != nil {
+			return err
+		}
+		for _, fi := range fis {
+			if fi.Name() != "lo" {
+				q.Ports[fi.Name()] = fi.Name()
+			}
+		}
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.present = make(map[string]string)
+	for port := range q.Ports {
+		c.pub.Printf("qsfp.%s.present: false", port)
+	}
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		for port := range q.Ports {
+			c.poll(q, port)
+		}
+		select {
+		case <-goes.Stop:
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Command) poll(q *qsfp.Command, port string) {
+	m, err := q.Module(port)
+	serial, was := c.present[port]
+	switch {
+	case err != nil && was:
+		delete(c.present, port)
+		c.pub.Printf("delete: qsfp.%s.", port)
+		c.pub.Printf("qsfp.%s.present: false", port)
+		log.Print("daemon", "info", port, ": module removed")
+	case err != nil:
+	case !was || serial != m.Serial:
+		if was {
+			c.pub.Printf("delete: qsfp.%s.", port)
+		}
+		c.present[port] = m.Serial
+		c.pub.Printf("qsfp.%s.present: true", port)
+		log.Print("daemon", "info", port, ": ", m.Vendor, " ",
+			m.PartNumber, " ", m.Serial, " inserted")
+		fallthrough
+	default:
+		m.Publish(c.pub)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package qsfp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ethtool module eeprom types
+const (
+	Sff8079 = 0x1
+	Sff8472 = 0x2
+	Sff8636 = 0x3
+	Sff8436 = 0x4
+)
+
+// Module is the decoded identification and digital optical monitoring of a
+// transceiver.
+type Module struct {
+	Port       string    `json:"port"`
+	Identifier string    `json:"identifier"`
+	Vendor     string    `json:"vendor"`
+	PartNumber string    `json:"part_number"`
+	Revision   string    `json:"revision"`
+	Serial     string    `json:"serial"`
+	Date       string    `json:"date"`
+	Dom        bool      `json:"dom"`
+	Temp       float64   `json:"temp_c,omitempty"`
+	Vcc        float64   `json:"vcc_v,omitempty"`
+	Channels   []Channel `json:"channels,omitempty"`
+}
+
+// Channel monitors of a lane
+type Channel struct {
+	TxBias  float64 `json:"tx_bias_ma"`
+	TxPower float64 `json:"tx_power_dbm"`
+	RxPower float64 `json:"rx_power_dbm"`
+}
+
+var identifiers = map[byte]string{
+	0x03: "SFP/SFP+/SFP28",
+	0x0c: "QSFP",
+	0x0d: "QSFP+",
+	0x11: "QSFP28",
+	0x18: "QSFP-DD",
+}
+
+// Decode the ethtool module eeprom dump of the given type.
+func Decode(typ uint32, b []byte) (*Module, error) {
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty eeprom")
+	}
+	m := &Module{Identifier: identifiers[b[0]]}
+	if len(m.Identifier) == 0 {
+		m.Identifier = fmt.Sprintf("%#02x", b[0])
+	}
+	switch typ {
+	case Sff8079, Sff8472:
+		return m, m.sfp(b)
+	case Sff8436, Sff8636:
+		return m, m.qsfp(b)
+	}
+	return m, fmt.Errorf("%#x: unsupported eeprom type", typ)
+}
+
+// sfp decodes SFF-8472 A0h and, if present, A2h at offset 256.
+func (m *Module) sfp(b []byte) error {
+	if len(b) < 96 {
+		return fmt.Errorf("%d byte SFP eeprom", len(b))
+	}
+	m.Vendor = ascii(b[20:36])
+	m.PartNumber = ascii(b[40:56])
+	m.Revision = ascii(b[56:60])
+	m.Serial = ascii(b[68:84])
+	m.Date = ascii(b[84:92])
+	if len(b) < 256+106 || b[92]&(1<<6) == 0 {
+		return nil
+	}
+	a2 := b[256:]
+	m.Dom = true
+	m.Temp = float64(int16(binary.BigEndian.Uint16(a2[96:]))) / 256
+	m.Vcc = float64(binary.BigEndian.Uint16(a2[98:])) / 10000
+	m.Channels = []Channel{{
+		TxBias:  current(a2[100:]),
+		TxPower: dbm(a2[102:]),
+		RxPower: dbm(a2[104:]),
+	}}
+	return nil
+}
+
+// qsfp decodes SFF-8636 lower page 0 and upper page 00h.
+func (m *Module) qsfp(b []byte) error {
+	if len(b) < 220 {
+		return fmt.Errorf("%d byte QSFP eeprom", len(b))
+	}
+	m.Vendor = ascii(b[148:164])
+	m.PartNumber = ascii(b[168:184])
+	m.Revision = ascii(b[184:186])
+	m.Serial = ascii(b[196:212])
+	m.Date = ascii(b[212:220])
+	m.Dom = true
+	m.Temp = float64(int16(binary.BigEndian.Uint16(b[22:]))) / 256
+	m.Vcc = float64(binary.BigEndian.Uint16(b[26:])) / 10000
+	for i := 0; i < 4; i++ {
+		m.Channels = append(m.Channels, Channel{
+			RxPower: dbm(b[34+2*i:]),
+			TxBias:  current(b[42+2*i:]),
+			TxPower: dbm(b[50+2*i:]),
+		})
+	}
+	return nil
+}
+
+// current returns mA of the 2uA unit word.
+func current(b []byte) float64 {
+	return float64(binary.BigEndian.Uint16(b)) * 0.002
+}
+
+// NoPower is the dBm reported for 0 mW, i.e. no light.
+const NoPower = -40
+
+// dbm returns dBm of the 0.1uW unit word.
+func dbm(b []byte) float64 {
+	mw := float64(binary.BigEndian.Uint16(b)) / 10000
+	if mw <= 0.0001 {
+		return NoPower
+	}
+	return 10 * math.Log10(mw)
+}
+
+func ascii(b []byte) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e {
+			return -1
+		}
+		return r
+	}, string(b)))
+}
@@ -0,0 +1,55 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package qsfp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodeQsfp(t *testing.T) {
+	b := make([]byte, 256)
+	b[0] = 0x11
+	copy(b[148:], "ACME            ")
+	copy(b[168:], "QSFP-100G-SR4   ")
+	copy(b[196:], "X1234           ")
+	b[22], b[23] = 35, 128    // 35.5 °C
+	b[26], b[27] = 0x80, 0xe8 // 3.3 V
+	b[34], b[35] = 0x27, 0x10 // 1 mW, 0 dBm
+	b[42], b[43] = 0x0f, 0xa0 // 8 mA
+	m, err := Decode(Sff8636, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct {
+		name, got, want string
+	}{
+		{"identifier", m.Identifier, "QSFP28"},
+		{"vendor", m.Vendor, "ACME"},
+		{"part number", m.PartNumber, "QSFP-100G-SR4"},
+		{"serial", m.Serial, "X1234"},
+	} {
+		if x.got != x.want {
+			t.Errorf("%s: %q vs. %q", x.name, x.got, x.want)
+		}
+	}
+	if len(m.Channels) != 4 {
+		t.Fatalf("%d channels", len(m.Channels))
+	}
+	for _, x := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"temp", m.Temp, 35.5},
+		{"vcc", m.Vcc, 3.3},
+		{"rx power", m.Channels[0].RxPower, 0},
+		{"tx bias", m.Channels[0].TxBias, 8},
+		{"no power", m.Channels[1].RxPower, NoPower},
+	} {
+		if math.Abs(x.got-x.want) > 0.001 {
+			t.Errorf("%s: %v vs. %v", x.name, x.got, x.want)
+		}
+	}
+}
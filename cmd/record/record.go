@@ -0,0 +1,208 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package record
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/creack/pty"
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/internal/asciicast"
+	"github.com/platinasystems/goes/internal/url"
+	"github.com/platinasystems/goes/lang"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+type Command struct {
+	g *goes.Goes
+}
+
+func (*Command) String() string { return "record" }
+
+func (*Command) Usage() string {
+	return "record [-i] FILE.cast\n    record [-t] FILE SCRIPT"
+}
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "record a cli session or script transcript",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Without SCRIPT, record runs an interactive cli in a pseudo-terminal
+	and saves its output, with timing, to the asciinema v2 FILE until this
+	cli exits. The recording may be played with 'replay' or asciinema.
+
+	With SCRIPT, a file or URL like that of 'source', record saves the
+	transcript of its prompted command lines and their output rather than
+	an interactive session. This also stops at the first error.
+
+OPTIONS
+	-i	also record the session's input, including passwords
+	-t	save a plain text transcript of SCRIPT instead of asciicast
+
+EXAMPLES
+	record support.cast
+	record -t change.txt scp://server/etc/goes/change
+
+SEE ALSO
+	replay, source`,
+	}
+}
+
+func (c *Command) Goes(g *goes.Goes) { c.g = g }
+
+func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-i", "-t")
+	switch len(args) {
+	case 0:
+		return fmt.Errorf("FILE: missing")
+	case 1:
+		if flag.ByName["-t"] {
+			return fmt.Errorf("SCRIPT: missing")
+		}
+	case 2:
+	default:
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	f, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if flag.ByName["-t"] {
+		return c.transcript(f, args[1])
+	}
+	h := asciicast.Header{
+		Env: map[string]string{
+			"SHELL": c.g.NAME,
+			"TERM":  os.Getenv("TERM"),
+		},
+	}
+	if rows, cols, err := pty.Getsize(os.Stdin); err == nil {
+		h.Width, h.Height = cols, rows
+	}
+	if len(args) == 2 {
+		h.Title = filepath.Base(args[1])
+	}
+	cast, err := asciicast.NewWriter(f, h)
+	if err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		return c.transcript(cast.Output(), args[1])
+	}
+	return c.session(cast, flag.ByName["-i"])
+}
+
+// session records a forked cli in a pseudo-terminal of the same size as
+// that of the standard input, if any.
+func (c *Command) session(cast *asciicast.Writer, input bool) error {
+	x := c.g.Fork("cli")
+	ptmx, err := pty.Start(x)
+	if err != nil {
+		return err
+	}
+	defer ptmx.Close()
+
+	winch := make(chan os.Signal, 1)
+	signal.Notify(winch, syscall.SIGWINCH)
+	defer signal.Stop(winch)
+	go func() {
+		for range winch {
+			if pty.InheritSize(os.Stdin, ptmx) == nil {
+				rows, cols, _ := pty.Getsize(ptmx)
+				cast.Event(asciicast.Resize,
+					[]byte(fmt.Sprint(cols, "x", rows)))
+			}
+		}
+	}()
+	pty.InheritSize(os.Stdin, ptmx)
+
+	if fd := int(os.Stdin.Fd()); terminal.IsTerminal(fd) {
+		state, err := terminal.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer terminal.Restore(fd, state)
+	}
+
+	go func() {
+		var r io.Reader = os.Stdin
+		if input {
+			r = io.TeeReader(os.Stdin, cast.Input())
+		}
+		io.Copy(ptmx, r)
+	}()
+	// this ends with EIO once the cli exits
+	io.Copy(io.MultiWriter(os.Stdout, cast.Output()), ptmx)
+	return x.Wait()
+}
+
+// transcript runs the script with a cli that echoes each of its lines
+// after the prompt and writes all output to both the standard output and
+// the given writer.
+func (c *Command) transcript(w io.Writer, fn string) error {
+	script, err := url.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer script.Close()
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+	done := make(chan struct{})
+	go func(stdout io.Writer) {
+		defer close(done)
+		io.Copy(io.MultiWriter(stdout, w), pr)
+	}(os.Stdout)
+
+	t, stdout, stderr := c.g.Terminal, c.g.Stdout, c.g.Stderr
+	osStdout, osStderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = pw, pw
+	c.g.Stdout, c.g.Stderr = pw, pw
+	c.g.Terminal = echo{goes.NewPipeTerminal(script, nil).Named(fn), pw}
+
+	err = c.g.Main("cli", "-")
+
+	c.g.Terminal, c.g.Stdout, c.g.Stderr = t, stdout, stderr
+	os.Stdout, os.Stderr = osStdout, osStderr
+	pw.Close()
+	<-done
+	if err != nil {
+		// that shown by the caller on stderr
+		fmt.Fprintln(w, err)
+	}
+	return err
+}
+
+// echo prints each command and continuation line of the script after its
+// prompt, as if typed.
+type echo struct {
+	*goes.PipeTerminal
+	w io.Writer
+}
+
+func (t echo) ReadLine(prompt string) (string, error) {
+	s, err := t.PipeTerminal.ReadLine(prompt)
+	if err == nil {
+		fmt.Fprint(t.w, prompt, s, "\n")
+	}
+	return s, err
+}
@@ -0,0 +1,59 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package redisd
+
+import (
+	"bytes"
+	"encoding/gob"
+	"net"
+
+	grs "github.com/platinasystems/go-redis-server"
+)
+
+// relinquish stops publication and closes the listeners before the
+// snapshot of the handoff to a successor so the published hashes don't
+// change after these are taken.
+func (c *Command) relinquish() (map[string]net.Listener, []byte) {
+	if c.pubconn != nil {
+		c.pubconn.Close()
+	}
+	c.redisd.mutex.Lock()
+	for _, srvs := range c.redisd.devs {
+		for _, srv := range srvs {
+			srv.server.Close()
+		}
+	}
+	c.redisd.mutex.Unlock()
+	return nil, c.redisd.snapshot()
+}
+
+// snapshot returns the gob encoded published hashes for a successor.
+func (redisd *Redisd) snapshot() []byte {
+	buf := new(bytes.Buffer)
+	redisd.mutex.Lock()
+	defer redisd.mutex.Unlock()
+	if err := gob.NewEncoder(buf).Encode(redisd.published); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
+
+// inherit the published hashes of a predecessor's snapshot.
+func (redisd *Redisd) inherit(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	var hh grs.HashHash
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&hh); err != nil {
+		return err
+	}
+	redisd.mutex.Lock()
+	defer redisd.mutex.Unlock()
+	for k, hv := range hh {
+		redisd.published[k] = hv
+	}
+	redisd.flushKeyCache()
+	return nil
+}
@@ -0,0 +1,289 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package redisd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	grs "github.com/platinasystems/go-redis-server"
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+)
+
+// Tier of history retains a sample per Interval for Retain; the first tier
+// is that of the sampling interval and those that follow downsample it to
+// the last value of each coarser interval, as suits counters.
+type Tier struct {
+	Interval, Retain time.Duration
+}
+
+// DefaultTiers retain 10s samples for an hour, 1m for 6 hours, and 10m for
+// a day.
+var DefaultTiers = []Tier{
+	{10 * time.Second, time.Hour},
+	{time.Minute, 6 * time.Hour},
+	{10 * time.Minute, 24 * time.Hour},
+}
+
+// DefaultHistoryMaxFields limits the memory of the sampled fields, about
+// 20KiB each with the default tiers.
+const DefaultHistoryMaxFields = 1024
+
+// History of the default hash fields matching any of the Fields globs,
+// e.g. "vnet.*.rx-packets"; none disables sampling.
+type History struct {
+	Fields    []string
+	Tiers     []Tier
+	MaxFields int
+
+	mutex  sync.Mutex
+	series map[string][]*samples
+}
+
+// samples is a ring buffer of a tier
+type samples struct {
+	buf     []redis.Sample
+	head, n int
+	// interval of the downsampled tiers, zero for the first
+	interval   time.Duration
+	lastBucket time.Time
+}
+
+func (h *History) configure(cfg *machine.Config) error {
+	h.Fields = cfg.Strings("redisd.history.fields", h.Fields)
+	var tiers []Tier
+	for _, i := range cfg.Keys("redisd.history.tiers") {
+		prefix := "redisd.history.tiers." + i + "."
+		var t Tier
+		var err error
+		if t.Interval, err = cfg.Duration(prefix+"interval",
+			0); err != nil {
+			return err
+		}
+		if t.Retain, err = cfg.Duration(prefix+"retain", 0); err != nil {
+			return err
+		}
+		tiers = append(tiers, t)
+	}
+	if len(tiers) > 0 {
+		h.Tiers = tiers
+	}
+	max, err := cfg.Int("redisd.history.max-fields", h.MaxFields)
+	if err != nil {
+		return err
+	}
+	h.MaxFields = max
+	return h.init()
+}
+
+func (h *History) init() error {
+	if len(h.Tiers) == 0 {
+		h.Tiers = DefaultTiers
+	}
+	if h.MaxFields == 0 {
+		h.MaxFields = DefaultHistoryMaxFields
+	}
+	for i, t := range h.Tiers {
+		if t.Interval <= 0 || t.Retain < t.Interval {
+			return fmt.Errorf("redisd.history.tiers.%d: %v/%v: invalid",
+				i, t.Interval, t.Retain)
+		}
+		if i > 0 && t.Interval%h.Tiers[i-1].Interval != 0 {
+			return fmt.Errorf("redisd.history.tiers.%d: %v: %s",
+				i, t.Interval, "isn't a multiple of the prior")
+		}
+	}
+	for _, glob := range h.Fields {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("redisd.history.fields: %q: %v",
+				glob, err)
+		}
+	}
+	h.series = make(map[string][]*samples)
+	return nil
+}
+
+func (h *History) match(field string) bool {
+	for _, glob := range h.Fields {
+		if matched, _ := path.Match(glob, field); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// sample the numeric values of the matching fields.
+func (h *History) sample(now time.Time, hv grs.HashValue) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for field, b := range hv {
+		series, found := h.series[field]
+		if !found {
+			if len(h.series) >= h.MaxFields || !h.match(field) {
+				continue
+			}
+		}
+		v, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			continue
+		}
+		if !found {
+			series = make([]*samples, len(h.Tiers))
+			for i, t := range h.Tiers {
+				series[i] = &samples{
+					buf: make([]redis.Sample,
+						t.Retain/t.Interval),
+				}
+				if i > 0 {
+					series[i].interval = t.Interval
+				}
+			}
+			h.series[field] = series
+		}
+		for _, s := range series {
+			s.add(redis.Sample{Time: now, Value: v})
+		}
+	}
+	for field := range h.series {
+		if _, found := hv[field]; !found {
+			delete(h.series, field)
+		}
+	}
+}
+
+// add a sample or, within the same downsampled interval, replace the last.
+func (s *samples) add(x redis.Sample) {
+	var bucket time.Time
+	if s.interval > 0 {
+		bucket = x.Time.Truncate(s.interval)
+	}
+	switch {
+	case s.interval > 0 && s.n > 0 && bucket.Equal(s.lastBucket):
+		s.buf[(s.head+s.n-1)%len(s.buf)] = x
+	case s.n < len(s.buf):
+		s.buf[(s.head+s.n)%len(s.buf)] = x
+		s.n++
+	default:
+		s.buf[s.head] = x
+		s.head = (s.head + 1) % len(s.buf)
+	}
+	s.lastBucket = bucket
+}
+
+// since appends the retained samples at or after t and, if non-zero,
+// before the given time.
+func (s *samples) since(dst []redis.Sample, t, before time.Time) []redis.Sample {
+	for i := 0; i < s.n; i++ {
+		x := s.buf[(s.head+i)%len(s.buf)]
+		if !x.Time.Before(t) &&
+			(before.IsZero() || x.Time.Before(before)) {
+			dst = append(dst, x)
+		}
+	}
+	return dst
+}
+
+// Range returns the samples of the field since t, oldest first, with those
+// of the finest tier retaining each interval.
+func (h *History) Range(field string, t time.Time) ([]redis.Sample, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	series, found := h.series[field]
+	if !found {
+		return nil, false
+	}
+	var r []redis.Sample
+	var before time.Time
+	for _, s := range series {
+		n := len(r)
+		r = s.since(r, t, before)
+		if len(r) > n {
+			before = r[n].Time
+		}
+	}
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].Time.Before(r[j].Time)
+	})
+	return r, true
+}
+
+// Names returns the sampled fields.
+func (h *History) Names() []string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	names := make([]string, 0, len(h.series))
+	for field := range h.series {
+		names = append(names, field)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gohistory samples the default hash at the first tier interval until stop.
+func (redisd *Redisd) gohistory() {
+	h := &redisd.history
+	t := time.NewTicker(h.Tiers[0].Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-goes.Stop:
+			return
+		case now := <-t.C:
+			redisd.mutex.Lock()
+			if hv, found := redisd.published[redis.DefaultHash]; found {
+				h.sample(now, hv)
+			}
+			redisd.mutex.Unlock()
+		}
+	}
+}
+
+// History replies with the sampled fields or, given a field and optional
+// duration (default: all retained), alternating RFC 3339 times and values.
+//
+//	HISTORY [FIELD [DURATION]]
+func (redisd *Redisd) History(args ...string) (_ [][]byte, err error) {
+	span := startSpan("HISTORY", args...)
+	defer func() { span.End(err) }()
+	var bs [][]byte
+	h := &redisd.history
+	if len(h.Fields) == 0 {
+		return bs, fmt.Errorf("history: disabled")
+	}
+	switch len(args) {
+	case 0:
+		for _, name := range h.Names() {
+			bs = append(bs, []byte(name))
+		}
+		return bs, nil
+	case 1, 2:
+	default:
+		return bs, fmt.Errorf("%v: unexpected", args[2:])
+	}
+	var t time.Time
+	if len(args) > 1 {
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			return bs, err
+		}
+		t = time.Now().Add(-d)
+	}
+	r, found := h.Range(args[0], t)
+	if !found {
+		return bs, fmt.Errorf("%s: not sampled", args[0])
+	}
+	bs = make([][]byte, 0, 2*len(r))
+	for _, x := range r {
+		bs = append(bs, []byte(x.Time.Format(time.RFC3339Nano)),
+			[]byte(strconv.FormatFloat(x.Value, 'g', -1, 64)))
+	}
+	return bs, nil
+}
@@ -0,0 +1,66 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package redisd
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	grs "github.com/platinasystems/go-redis-server"
+)
+
+func TestHistory(t *testing.T) {
+	h := &History{
+		Fields: []string{"*.rx-packets"},
+		Tiers: []Tier{
+			{10 * time.Second, time.Minute},
+			{time.Minute, 5 * time.Minute},
+		},
+	}
+	if err := h.init(); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Now().Truncate(time.Minute).Add(-10 * time.Minute)
+	// 10 minutes of 10s samples
+	for i := 1; i <= 60; i++ {
+		h.sample(t0.Add(time.Duration(i)*10*time.Second), grs.HashValue{
+			"eth-1.rx-packets": []byte(strconv.Itoa(i)),
+			"eth-1.tx-packets": []byte(strconv.Itoa(i)),
+			"eth-2.rx-packets": []byte("down"),
+		})
+	}
+	if names := h.Names(); len(names) != 1 ||
+		names[0] != "eth-1.rx-packets" {
+		t.Fatalf("names: %q", names)
+	}
+	r, found := h.Range("eth-1.rx-packets", time.Time{})
+	if !found {
+		t.Fatal("not found")
+	}
+	// the last of each minute, retained for 5, before the last minute of
+	// 10s samples
+	var got []float64
+	for _, x := range r {
+		got = append(got, x.Value)
+	}
+	want := []float64{41, 47, 53, 55, 56, 57, 58, 59, 60}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v want %v", got, want)
+		}
+	}
+	r, _ = h.Range("eth-1.rx-packets", t0.Add(8*time.Minute))
+	if len(r) != 7 || r[0].Value != 53 || r[1].Value != 55 {
+		t.Errorf("since: %v", r)
+	}
+	h.sample(t0.Add(11*time.Minute), grs.HashValue{})
+	if names := h.Names(); len(names) != 0 {
+		t.Errorf("deleted: %q", names)
+	}
+}
@@ -19,9 +19,12 @@ import (
 
 const SizeofInt = (32 << (^uint(0) >> 63)) >> 3
 
-func (redisd *Redisd) Info(secs ...string) ([]byte, error) {
+func (redisd *Redisd) Info(secs ...string) (_ []byte, err error) {
+	span := startSpan("INFO")
+	defer func() { span.End(err) }()
+
 	stat := new(proc.Stat)
-	err := proc.Load(stat).FromFile("/proc/self/stat")
+	err = proc.Load(stat).FromFile("/proc/self/stat")
 	if err != nil {
 		return nil, err
 	}
@@ -21,15 +21,21 @@ import (
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/handoff"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/external/redis"
 	"github.com/platinasystems/goes/external/redis/publisher"
 	"github.com/platinasystems/goes/external/redis/rpc/reg"
+	"github.com/platinasystems/goes/external/trace"
 	"github.com/platinasystems/goes/internal/cmdline"
 	"github.com/platinasystems/goes/internal/fields"
 	"github.com/platinasystems/goes/lang"
 )
 
+var logger = log.New("redisd")
+
 type Command struct {
 	// Machines may restrict redisd listening to this list of net devices.
 	// If unset, the local admin may restrict this through
@@ -53,6 +59,7 @@ type Command struct {
 
 	pubconn *net.UnixConn
 	redisd  Redisd
+	reload  chan struct{}
 }
 
 func (*Command) String() string { return "redisd" }
@@ -78,22 +85,65 @@ OPTIONS
 	-port PORT
 		network port, default: 6379
 	-set FIELD=VALUE
-		initialize the default hash with the given field values`,
+		initialize the default hash with the given field values
+
+HISTORY
+	With the machine's configured history fields, redisd samples the
+	matching numeric fields of the default hash and retains tiers of
+	downsampled history, by default: 10s samples for an hour, 1m for 6
+	hours, and 10m for a day. The HISTORY command replies with the sampled
+	fields or, given a field and optional duration, its samples, e.g.
+		goes show history vnet.eth-1-1.rx-packets 1h
+
+FILES
+	/etc/goes/machine.yaml
+		redisd:
+		  history:
+		    fields: ["vnet.*.rx-packets", "vnet.*.tx-packets"]
+		    max-fields: 1024
+		    tiers:
+		      - interval: 10s
+		        retain: 1h
+		      - interval: 1m
+		        retain: 24h
+
+SIGNALS
+	SIGHUP	rescan the listening network devices, e.g.
+		goes daemon reload redisd
+
+UPGRADE
+	A redisd started while another is running takes its published hashes
+	then waits for it to stop and release its sockets, e.g.
+		goes daemon upgrade redisd`,
 	}
 }
 
 func (*Command) Kind() cmd.Kind { return cmd.Daemon }
 
+// Reload immediately rescans the listening interfaces rather than waiting
+// for the next periodic scan.
+func (c *Command) Reload() error {
+	select {
+	case c.reload <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 func (c *Command) Main(args ...string) error {
 	parm, args := parms.New(args, "-port", "-set")
+	if err := c.configure(machine.Default()); err != nil {
+		return err
+	}
 	if s := parm.ByName["-port"]; len(s) > 0 {
 		if _, err := fmt.Sscan(s, &c.Port); err != nil {
 			return err
 		}
-	} else {
+	} else if c.Port == 0 {
 		c.Port = 6379
 	}
 	c.redisd.port = c.Port
+	c.reload = make(chan struct{}, 1)
 
 	// filter non-existent devs
 	for i := 0; i < len(c.Devs); {
@@ -136,6 +186,20 @@ func (c *Command) Main(args ...string) error {
 		c.redisd.published[k] = make(grs.HashValue)
 	}
 
+	// an upgrading predecessor hands off its published hashes then stops;
+	// wait for it to release its sockets before binding these
+	if in, err := handoff.Take("redisd"); err != nil {
+		logger.Err("handoff", "err", err)
+	} else if in != nil {
+		if err = c.redisd.inherit(in.State); err != nil {
+			logger.Err("handoff", "err", err)
+		}
+		if err = in.Wait(); err != nil {
+			logger.Err("handoff", "err", err)
+		}
+		in.Close()
+	}
+
 	cfg := grs.DefaultConfig()
 	cfg = cfg.Proto("unix")
 	cfg = cfg.Host("@redisd")
@@ -168,6 +232,14 @@ func (c *Command) Main(args ...string) error {
 		return err
 	}
 
+	if len(c.redisd.history.Fields) > 0 {
+		goes.WG.Add(1)
+		go func() {
+			defer goes.WG.Done()
+			c.redisd.gohistory()
+		}()
+	}
+
 	goes.WG.Add(1)
 	go func() {
 		defer goes.WG.Done()
@@ -188,6 +260,8 @@ func (c *Command) Main(args ...string) error {
 				select {
 				case <-goes.Stop:
 					return false
+				case <-c.reload:
+					return true
 				case <-t.C:
 					return true
 				}
@@ -197,6 +271,12 @@ func (c *Command) Main(args ...string) error {
 		}
 	}(&c.redisd, args...)
 
+	offer, err := handoff.NewOffer("redisd", c.relinquish,
+		handoff.Terminate)
+	if err != nil {
+		logger.Err("handoff", "err", err)
+	}
+
 	<-goes.Stop
 
 	if c.redisd.reg != nil {
@@ -217,9 +297,25 @@ func (c *Command) Main(args ...string) error {
 	}
 	c.redisd.mutex.Unlock()
 
+	// closing the offer after the sockets ends the successor's wait
+	if offer != nil {
+		offer.Close()
+	}
 	return nil
 }
 
+// configure overrides the machine's compiled in devs, etc. with those of
+// the redisd section of the machine configuration file.
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	c.Devs = cfg.Strings("redisd.devs", c.Devs)
+	c.Machine = cfg.String("redisd.machine", c.Machine)
+	c.PublishedKeys = cfg.Strings("redisd.published-keys", c.PublishedKeys)
+	if c.Port, err = cfg.Int("redisd.port", c.Port); err != nil {
+		return
+	}
+	return c.redisd.history.configure(cfg)
+}
+
 func (c *Command) gopub() {
 	const sep = ": "
 	var key, field string
@@ -359,6 +455,8 @@ type Redisd struct {
 	cachedKeys    []string
 	cachedSubkeys map[string][]string
 
+	history History
+
 	port int
 }
 
@@ -404,12 +502,12 @@ func (redisd *Redisd) listenOnInterface(name string) {
 	ok := true
 	dev, err := net.InterfaceByName(name)
 	if err != nil {
-		fmt.Fprint(os.Stderr, name, ": ", err, "\n")
+		logger.Err("listen", "ifname", name, "err", err)
 		ok = false
 	}
 
 	if ok && ((dev.Flags & net.FlagUp) != net.FlagUp) {
-		fmt.Fprint(os.Stderr, name, ": down\n")
+		logger.Info("down", "ifname", name)
 		ok = false
 	}
 
@@ -417,12 +515,11 @@ func (redisd *Redisd) listenOnInterface(name string) {
 	if ok {
 		addrs, err = dev.Addrs()
 		if err != nil {
-			fmt.Fprint(os.Stderr, name, ": ", err, "\n")
+			logger.Err("listen", "ifname", name, "err", err)
 			ok = false
 		} else {
 			if len(addrs) == 0 {
-				fmt.Fprint(os.Stderr, name,
-					": no address or isn't up\n")
+				logger.Info("no address", "ifname", name)
 				ok = false
 			}
 		}
@@ -438,10 +535,7 @@ devloop:
 				continue devloop
 			}
 		}
-		if true {
-			fmt.Fprint(os.Stderr, srv.addr, ": removed from ",
-				name)
-		}
+		logger.Info("removed", "ifname", name, "addr", srv.addr)
 		srv.server.Close()
 		redisd.devs[name][i] = nil
 	}
@@ -449,18 +543,16 @@ devloop:
 	for _, addr := range addrs {
 		ip, _, err := net.ParseCIDR(addr.String())
 		if err != nil {
-			fmt.Fprint(os.Stderr, addr, ": CIDR: ",
-				err, "\n")
+			logger.Err("listen", "ifname", name, "addr", addr,
+				"err", err)
 			continue
 		}
 		if ip.IsMulticast() {
 			continue
 		}
 		if found, _ := redisd.findServerOnInterface(name, addr.String()); found {
-			if false {
-				fmt.Fprint(os.Stderr, addr, ": already up on: ",
-					name, "\n")
-			}
+			logger.Debug("already up", "ifname", name,
+				"addr", addr)
 			continue
 		}
 		id := fmt.Sprint("[", ip, "%", name, "]:", redisd.port)
@@ -476,7 +568,7 @@ devloop:
 		}
 		srv, err := grs.NewServer(cfg)
 		if err != nil {
-			fmt.Fprint(os.Stderr, id, ": ", err, "\n")
+			logger.Err("listen", "id", id, "err", err)
 		} else {
 			srvs = append(srvs, &Server{server: srv,
 				addr: addr.String()})
@@ -485,9 +577,7 @@ devloop:
 				defer goes.WG.Done()
 				srv.Start()
 			}()
-			if true {
-				fmt.Println("listen:", id)
-			}
+			logger.Info("listen", "id", id)
 		}
 	}
 	redisd.devs[name] = srvs
@@ -507,7 +597,20 @@ func (redisd *Redisd) flushSubkeyCache(key string) {
 	}
 }
 
-func (redisd *Redisd) Hexists(key, field string) (int, error) {
+// startSpan of the redis command with the optional key and field.
+func startSpan(command string, args ...string) *trace.Span {
+	kv := []interface{}{"db.system", "redis", "db.operation", command}
+	for i, k := range []string{"db.redis.key", "db.redis.field"} {
+		if i < len(args) {
+			kv = append(kv, k, args[i])
+		}
+	}
+	return trace.StartKind(nil, trace.Server, command, kv...)
+}
+
+func (redisd *Redisd) Hexists(key, field string) (_ int, err error) {
+	span := startSpan("HEXISTS", key, field)
+	defer func() { span.End(err) }()
 	redisd.mutex.Lock()
 	defer redisd.mutex.Unlock()
 	hv, found := redisd.published[key]
@@ -521,9 +624,12 @@ func (redisd *Redisd) Hexists(key, field string) (int, error) {
 	return 1, nil
 }
 
-func (redisd *Redisd) Hget(key, field string) ([]byte, error) {
+func (redisd *Redisd) Hget(key, field string) (_ []byte, err error) {
 	var keys []string
 
+	span := startSpan("HGET", key, field)
+	defer func() { span.End(err) }()
+
 	redisd.mutex.Lock()
 	defer redisd.mutex.Unlock()
 
@@ -568,8 +674,10 @@ func (redisd *Redisd) Hget(key, field string) ([]byte, error) {
 	return b, nil
 }
 
-func (redisd *Redisd) Hgetall(key string) ([][]byte, error) {
+func (redisd *Redisd) Hgetall(key string) (_ [][]byte, err error) {
 	var bs [][]byte
+	span := startSpan("HGETALL", key)
+	defer func() { span.End(err) }()
 	redisd.mutex.Lock()
 	defer redisd.mutex.Unlock()
 	hv, found := redisd.published[key]
@@ -584,8 +692,10 @@ func (redisd *Redisd) Hgetall(key string) ([][]byte, error) {
 	return bs, nil
 }
 
-func (redisd *Redisd) Hkeys(key string) ([][]byte, error) {
+func (redisd *Redisd) Hkeys(key string) (_ [][]byte, err error) {
 	var bs [][]byte
+	span := startSpan("HKEYS", key)
+	defer func() { span.End(err) }()
 	redisd.mutex.Lock()
 	defer redisd.mutex.Unlock()
 	hv, found := redisd.published[key]
@@ -600,7 +710,9 @@ func (redisd *Redisd) Hkeys(key string) ([][]byte, error) {
 	return bs, nil
 }
 
-func (redisd *Redisd) Hset(key, field string, value []byte) (int, error) {
+func (redisd *Redisd) Hset(key, field string, value []byte) (_ int, err error) {
+	span := startSpan("HSET", key, field)
+	defer func() { span.End(err) }()
 	type t interface {
 		Hset(string, string, []byte) (int, error)
 	}
@@ -618,9 +730,10 @@ func (redisd *Redisd) Hset(key, field string, value []byte) (int, error) {
 	return f(key, field, value)
 }
 
-func (redisd *Redisd) Keys(pattern string) ([][]byte, error) {
+func (redisd *Redisd) Keys(pattern string) (_ [][]byte, err error) {
 	var re *regexp.Regexp
-	var err error
+	span := startSpan("KEYS", pattern)
+	defer func() { span.End(err) }()
 	isMatch := func(k string) bool { return true }
 	if len(pattern) > 0 && pattern != "*" {
 		if strings.ContainsAny(pattern, "?*\\") {
@@ -2,16 +2,15 @@
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
-// Package reload provides the named command that sends SIGHUP to all of the
-// daemons associated with this executable.
+// Package reload provides the named command that has the daemons supervisor
+// SIGHUP those daemons that reload their configuration.
 package reload
 
 import (
-	"syscall"
-
-	"github.com/platinasystems/goes/lang"
+	"github.com/platinasystems/goes/cmd/daemons"
+	"github.com/platinasystems/goes/external/atsock"
 	"github.com/platinasystems/goes/internal/assert"
-	"github.com/platinasystems/goes/internal/kill"
+	"github.com/platinasystems/goes/lang"
 )
 
 type Command struct{}
@@ -22,7 +21,7 @@ func (Command) Usage() string { return "reload" }
 
 func (Command) Apropos() lang.Alt {
 	return lang.Alt{
-		lang.EnUS: "SIGHUP this goes machine",
+		lang.EnUS: "reload the configuration of this goes machine's daemons",
 	}
 }
 
@@ -31,5 +30,10 @@ func (Command) Main(...string) error {
 	if err != nil {
 		return err
 	}
-	return kill.All(syscall.SIGHUP)
+	cl, err := atsock.NewRpcClient(daemons.Sockname())
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	return cl.Call("Daemons.Reload", []string{}, &struct{}{})
 }
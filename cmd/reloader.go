@@ -0,0 +1,14 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package cmd
+
+// Daemons may implement Reloader to reconfigure without a disruptive restart.
+// Goes.Main calls Reload on each SIGHUP that the daemon receives; other
+// daemons ignore SIGHUP. The supervisor sends SIGHUP to the listed daemons
+// of `daemon reload [DAEMON]...`; without a list, or with the `reload`
+// command, just to those that implement Reloader.
+type Reloader interface {
+	Reload() error
+}
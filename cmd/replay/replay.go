@@ -0,0 +1,104 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package replay
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/asciicast"
+	"github.com/platinasystems/goes/internal/url"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "replay" }
+
+func (Command) Usage() string {
+	return "replay [-s SPEED] [-m SECONDS] FILE.cast"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "play a recorded cli session",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Replay writes the output of an asciinema v2 recording, a file or URL,
+	to the terminal with its original timing.
+
+OPTIONS
+	-s SPEED	divide the time between events by SPEED, e.g. 2
+	-m SECONDS	limit the idle time between events, e.g. 1.5
+
+SEE ALSO
+	record`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-s", "-m")
+	if len(args) == 0 {
+		return fmt.Errorf("FILE: missing")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	speed, maxIdle := 1.0, 0.0
+	if s := parm.ByName["-s"]; len(s) > 0 {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f <= 0 {
+			return fmt.Errorf("-s %s: invalid", s)
+		}
+		speed = f
+	}
+	if s := parm.ByName["-m"]; len(s) > 0 {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || f <= 0 {
+			return fmt.Errorf("-m %s: invalid", s)
+		}
+		maxIdle = f
+	}
+	f, err := url.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	r, err := asciicast.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	last := 0.0
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", args[0], err)
+		}
+		if e.Type != asciicast.Output {
+			continue
+		}
+		idle := e.Time - last
+		if maxIdle > 0 && idle > maxIdle {
+			idle = maxIdle
+		}
+		last = e.Time
+		if idle > 0 {
+			time.Sleep(time.Duration(idle / speed * float64(time.Second)))
+		}
+		os.Stdout.WriteString(e.Data)
+	}
+}
@@ -7,8 +7,6 @@ package resize
 import (
 	"fmt"
 	"os"
-	"syscall"
-	"unsafe"
 
 	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/lang"
@@ -16,6 +14,8 @@ import (
 
 type Command struct{}
 
+type winsize struct{ Row, Col, X, Y uint16 }
+
 func (Command) String() string { return "resize" }
 func (Command) Usage() string  { return "resize" }
 
@@ -29,17 +29,15 @@ func (Command) Kind() cmd.Kind { return cmd.DontFork | cmd.CantPipe }
 
 func (Command) Main(args ...string) error {
 	var (
-		rcxy    struct{ Row, Col, X, Y uint16 }
+		rcxy    winsize
 		mustset bool
 		err     error
 	)
 	if len(args) != 0 {
 		return fmt.Errorf("%v: unexpected", args)
 	}
-	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(syscall.Stdout),
-		syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&rcxy)))
-	if e < 0 {
-		return fmt.Errorf("TIOCGWINSZ: %v", e)
+	if err = rcxy.get(); err != nil {
+		return err
 	}
 	for _, dimension := range []struct {
 		name string
@@ -78,13 +76,7 @@ func (Command) Main(args ...string) error {
 		}
 	}
 	if mustset {
-		_, _, e := syscall.Syscall(syscall.SYS_IOCTL,
-			uintptr(syscall.Stdout),
-			syscall.TIOCSWINSZ,
-			uintptr(unsafe.Pointer(&rcxy)))
-		if e < 0 {
-			err = fmt.Errorf("TIOCSWINSZ: %v", e)
-		}
+		err = rcxy.set()
 	}
 	return err
 }
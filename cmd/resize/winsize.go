@@ -0,0 +1,31 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build !windows
+
+package resize
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+func (rcxy *winsize) get() error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(syscall.Stdout),
+		syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(rcxy)))
+	if e < 0 {
+		return fmt.Errorf("TIOCGWINSZ: %v", e)
+	}
+	return nil
+}
+
+func (rcxy *winsize) set() error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(syscall.Stdout),
+		syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(rcxy)))
+	if e < 0 {
+		return fmt.Errorf("TIOCSWINSZ: %v", e)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package resize
+
+// The console doesn't have a settable window size, so this just exports
+// ROWS and COLUMNS.
+func (*winsize) get() error { return nil }
+func (*winsize) set() error { return nil }
@@ -0,0 +1,192 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package route
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the redis settable fields,
+//
+//	route.PREFIX: NEXTHOP[, NEXTHOP]...
+//	NEXTHOP := [via GATEWAY] [dev IFNAME] [metric METRIC]
+//
+// The nexthops of the same metric are a multipath route.
+const Prefix = "route."
+
+// Config of the static routes by the canonical prefix
+type Config map[string][]Nexthop
+
+type Nexthop struct {
+	Gateway net.IP `json:"via,omitempty"`
+	Dev     string `json:"dev,omitempty"`
+	Metric  uint32 `json:"metric"`
+}
+
+// Route is a kernel route of the configured nexthops with the same
+// metric.
+type Route struct {
+	Dst    *net.IPNet
+	Metric uint32
+	Hops   []Nexthop
+}
+
+// ParsePrefix returns the canonical prefix of ADDRESS[/LEN] or default,
+// e.g. "10.1.2.0/24" of "10.1.2.3/24" or "::/0" of "default" with an IPv6
+// gateway.
+func ParsePrefix(s string, inet6 bool) (*net.IPNet, error) {
+	if s == "default" {
+		if inet6 {
+			s = "::/0"
+		} else {
+			s = "0.0.0.0/0"
+		}
+	} else if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil && ip.To4() != nil {
+			s += "/32"
+		} else {
+			s += "/128"
+		}
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("%q: invalid prefix", s)
+	}
+	return ipnet, nil
+}
+
+// ParseNexthop of the [via GATEWAY] [dev IFNAME] [metric METRIC] args.
+func ParseNexthop(args ...string) (Nexthop, error) {
+	var nh Nexthop
+	for len(args) > 0 {
+		if len(args) < 2 {
+			return nh, fmt.Errorf("%s: missing value", args[0])
+		}
+		switch args[0] {
+		case "via":
+			if nh.Gateway = net.ParseIP(args[1]); nh.Gateway == nil {
+				return nh, fmt.Errorf("via %s: invalid", args[1])
+			}
+		case "dev":
+			nh.Dev = args[1]
+		case "metric":
+			u, err := strconv.ParseUint(args[1], 0, 32)
+			if err != nil {
+				return nh, fmt.Errorf("metric %s: invalid", args[1])
+			}
+			nh.Metric = uint32(u)
+		default:
+			return nh, fmt.Errorf("%s: unexpected", args[0])
+		}
+		args = args[2:]
+	}
+	if nh.Gateway == nil && len(nh.Dev) == 0 {
+		return nh, fmt.Errorf("missing via or dev")
+	}
+	return nh, nil
+}
+
+func (nh Nexthop) String() string {
+	var args []string
+	if nh.Gateway != nil {
+		args = append(args, "via", nh.Gateway.String())
+	}
+	if len(nh.Dev) > 0 {
+		args = append(args, "dev", nh.Dev)
+	}
+	if nh.Metric != 0 {
+		args = append(args, "metric", fmt.Sprint(nh.Metric))
+	}
+	return strings.Join(args, " ")
+}
+
+// Format the nexthops as a field value.
+func Format(hops []Nexthop) string {
+	s := make([]string, len(hops))
+	for i, nh := range hops {
+		s[i] = nh.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// Parse the route.* fields.
+func Parse(fields map[string]string) (Config, error) {
+	c := make(Config)
+	for field, value := range fields {
+		if !strings.HasPrefix(field, Prefix) {
+			return nil, fmt.Errorf("%s: not a route", field)
+		}
+		s := field[len(Prefix):]
+		if len(strings.TrimSpace(value)) == 0 {
+			continue
+		}
+		var hops []Nexthop
+		for _, spec := range strings.Split(value, ",") {
+			nh, err := ParseNexthop(strings.Fields(spec)...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", field, err)
+			}
+			hops = append(hops, nh)
+		}
+		ipnet, err := ParsePrefix(s, false)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", field, err)
+		}
+		if ipnet.String() != s {
+			return nil, fmt.Errorf("%s: should be %s%s", field, Prefix,
+				ipnet)
+		}
+		inet6 := ipnet.IP.To4() == nil
+		for _, nh := range hops {
+			if nh.Gateway != nil && (nh.Gateway.To4() == nil) != inet6 {
+				return nil, fmt.Errorf("%s: via %s: wrong family",
+					field, nh.Gateway)
+			}
+		}
+		c[s] = hops
+	}
+	return c, nil
+}
+
+// Prefixes returns the sorted prefixes.
+func (c Config) Prefixes() []string {
+	prefixes := make([]string, 0, len(c))
+	for prefix := range c {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// Routes returns the kernel routes of the configuration; IPv6 routes
+// without a metric have the kernel's default, 1024.
+func (c Config) Routes() []Route {
+	var routes []Route
+	for _, prefix := range c.Prefixes() {
+		_, dst, _ := net.ParseCIDR(prefix)
+		byMetric := make(map[uint32]int)
+		for _, nh := range c[prefix] {
+			metric := nh.Metric
+			if metric == 0 && dst.IP.To4() == nil {
+				metric = 1024
+			}
+			i, found := byMetric[metric]
+			if !found {
+				i = len(routes)
+				byMetric[metric] = i
+				routes = append(routes, Route{
+					Dst:    dst,
+					Metric: metric,
+				})
+			}
+			routes[i].Hops = append(routes[i].Hops, nh)
+		}
+	}
+	return routes
+}
@@ -0,0 +1,52 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package route
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"route.10.1.0.0/16":    "via 10.0.0.1, via 10.0.0.2, dev eth1 metric 10",
+		"route.2001:db8::/32":  "dev eth2",
+		"route.192.168.1.0/24": "",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := c.Routes()
+	if len(routes) != 3 {
+		t.Fatalf("routes: %v", routes)
+	}
+	for i, x := range []struct {
+		dst    string
+		metric uint32
+		hops   int
+	}{
+		{"10.1.0.0/16", 0, 2},
+		{"10.1.0.0/16", 10, 1},
+		{"2001:db8::/32", 1024, 1},
+	} {
+		r := routes[i]
+		if r.Dst.String() != x.dst || r.Metric != x.metric ||
+			len(r.Hops) != x.hops {
+			t.Errorf("route[%d]: %v metric %d %v", i, r.Dst, r.Metric,
+				r.Hops)
+		}
+	}
+	if s := Format(c["10.1.0.0/16"]); s !=
+		"via 10.0.0.1, via 10.0.0.2, dev eth1 metric 10" {
+		t.Errorf("format: %q", s)
+	}
+	for _, fields := range []map[string]string{
+		{"route.10.1.2.3/16": "via 10.0.0.1"},
+		{"route.10.1.0.0/16": "via 2001:db8::1"},
+		{"route.10.1.0.0/16": "metric 10"},
+		{"route.default": "via 10.0.0.1"},
+	} {
+		if _, err = Parse(fields); err == nil {
+			t.Errorf("%v: expected error", fields)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package route
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+// Installed is a static route of the kernel's main table.
+type Installed struct {
+	Dst    *net.IPNet
+	Metric uint32
+	Hops   []InstalledHop
+}
+
+type InstalledHop struct {
+	Gateway net.IP
+	Index   int32
+}
+
+// struct rtnexthop of linux/rtnetlink.h
+type rtNexthop struct {
+	Len     uint16
+	Flags   uint8
+	Hops    uint8
+	Ifindex int32
+}
+
+const sizeofRtNexthop = 8
+
+// Key of a route by its prefix and metric.
+func Key(dst *net.IPNet, metric uint32) string {
+	return fmt.Sprint(dst, " metric ", metric)
+}
+
+// Dump the static routes of the main table by Key.
+func Dump(sr *nl.SockReceiver) (map[string]*Installed, error) {
+	routes := make(map[string]*Installed)
+	for _, af := range []uint8{rtnl.AF_INET, rtnl.AF_INET6} {
+		req, err := nl.NewMessage(nl.Hdr{
+			Type:  rtnl.RTM_GETROUTE,
+			Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+		}, rtnl.RtGenMsg{
+			Family: af,
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = sr.UntilDone(req, func(b []byte) {
+			if r := parse(b); r != nil {
+				routes[Key(r.Dst, r.Metric)] = r
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return routes, nil
+}
+
+func parse(b []byte) *Installed {
+	var rta rtnl.Rta
+	if nl.HdrPtr(b).Type != rtnl.RTM_NEWROUTE {
+		return nil
+	}
+	msg := rtnl.RtMsgPtr(b)
+	if msg == nil || msg.Protocol != rtnl.RTPROT_STATIC ||
+		msg.Type != rtnl.RTN_UNICAST {
+		return nil
+	}
+	rta.Write(b)
+	table := uint32(msg.Table)
+	if len(rta[rtnl.RTA_TABLE]) > 0 {
+		table = nl.Uint32(rta[rtnl.RTA_TABLE])
+	}
+	if table != rtnl.RT_TABLE_MAIN {
+		return nil
+	}
+	bits := 32
+	if msg.Family == rtnl.AF_INET6 {
+		bits = 128
+	}
+	r := &Installed{
+		Dst: &net.IPNet{
+			IP:   make(net.IP, bits/8),
+			Mask: net.CIDRMask(int(msg.Dst_len), bits),
+		},
+	}
+	copy(r.Dst.IP, rta[rtnl.RTA_DST])
+	if len(rta[rtnl.RTA_PRIORITY]) > 0 {
+		r.Metric = nl.Uint32(rta[rtnl.RTA_PRIORITY])
+	}
+	mp := rta[rtnl.RTA_MULTIPATH]
+	if len(mp) == 0 {
+		r.Hops = []InstalledHop{{
+			Gateway: ipOf(rta[rtnl.RTA_GATEWAY]),
+			Index:   nl.Int32(rta[rtnl.RTA_OIF]),
+		}}
+		return r
+	}
+	for len(mp) >= sizeofRtNexthop {
+		rtnh := (*rtNexthop)(unsafe.Pointer(&mp[0]))
+		n := int(rtnh.Len)
+		if n < sizeofRtNexthop || n > len(mp) {
+			break
+		}
+		hop := InstalledHop{Index: rtnh.Ifindex}
+		nl.ForEachAttr(mp[sizeofRtNexthop:n], func(t uint16, v []byte) {
+			if t == rtnl.RTA_GATEWAY {
+				hop.Gateway = ipOf(v)
+			}
+		})
+		r.Hops = append(r.Hops, hop)
+		if n = nl.NLMSG.Align(n); n > len(mp) {
+			break
+		}
+		mp = mp[n:]
+	}
+	return r
+}
+
+func ipOf(b []byte) net.IP {
+	if len(b) == 4 || len(b) == 16 {
+		return net.IP(append([]byte{}, b...))
+	}
+	return nil
+}
+
+// Matches returns true if the installed route has the configured nexthops;
+// a nexthop without a dev matches any interface.
+func (r *Route) Matches(in *Installed) bool {
+	if in == nil || len(in.Hops) != len(r.Hops) {
+		return false
+	}
+	have := make([]string, len(in.Hops))
+	for i, hop := range in.Hops {
+		have[i] = fmt.Sprint(hop.Gateway, "@", hop.Index)
+	}
+	want := make([]string, len(r.Hops))
+	for i, nh := range r.Hops {
+		var index int32
+		if len(nh.Dev) > 0 {
+			ifi, err := net.InterfaceByName(nh.Dev)
+			if err != nil {
+				return false
+			}
+			index = int32(ifi.Index)
+		} else {
+			for _, hop := range in.Hops {
+				if hop.Gateway.Equal(nh.Gateway) {
+					index = hop.Index
+					break
+				}
+			}
+		}
+		var gw net.IP
+		if nh.Gateway != nil {
+			gw = ipOf(nh.Gateway.To16())
+			if nh.Gateway.To4() != nil {
+				gw = ipOf(nh.Gateway.To4())
+			}
+		}
+		want[i] = fmt.Sprint(gw, "@", index)
+	}
+	sort.Strings(have)
+	sort.Strings(want)
+	return strings.Join(have, " ") == strings.Join(want, " ")
+}
+
+// Install or replace the route.
+func (r *Route) Install(sr *nl.SockReceiver) error {
+	msg, attrs := r.message()
+	scope := rtnl.RT_SCOPE_LINK
+	var hops []byte
+	for _, nh := range r.Hops {
+		var gw []byte
+		if nh.Gateway != nil {
+			scope = rtnl.RT_SCOPE_UNIVERSE
+			if gw = nh.Gateway.To4(); msg.Family == rtnl.AF_INET6 {
+				gw = nh.Gateway.To16()
+			}
+		}
+		var index int32
+		if len(nh.Dev) > 0 {
+			ifi, err := net.InterfaceByName(nh.Dev)
+			if err != nil {
+				return fmt.Errorf("dev %s: %v", nh.Dev, err)
+			}
+			index = int32(ifi.Index)
+		}
+		if len(r.Hops) == 1 {
+			if gw != nil {
+				attrs = append(attrs, nl.Attr{Type: rtnl.RTA_GATEWAY,
+					Value: nl.BytesAttr(gw)})
+			}
+			if index != 0 {
+				attrs = append(attrs, nl.Attr{Type: rtnl.RTA_OIF,
+					Value: nl.Int32Attr(index)})
+			}
+			continue
+		}
+		b := make([]byte, sizeofRtNexthop, sizeofRtNexthop+4+len(gw))
+		if gw != nil {
+			a := make([]byte, 4)
+			*(*uint16)(unsafe.Pointer(&a[0])) = uint16(4 + len(gw))
+			*(*uint16)(unsafe.Pointer(&a[2])) = rtnl.RTA_GATEWAY
+			b = append(append(b, a...), gw...)
+		}
+		*(*rtNexthop)(unsafe.Pointer(&b[0])) = rtNexthop{
+			Len:     uint16(len(b)),
+			Ifindex: index,
+		}
+		hops = append(hops, b...)
+	}
+	if len(hops) > 0 {
+		attrs = append(attrs, nl.Attr{Type: rtnl.RTA_MULTIPATH,
+			Value: nl.BytesAttr(hops)})
+	}
+	msg.Scope = scope
+	req, err := nl.NewMessage(nl.Hdr{
+		Type: rtnl.RTM_NEWROUTE,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK | nl.NLM_F_CREATE |
+			nl.NLM_F_REPLACE,
+	}, msg, attrs...)
+	if err != nil {
+		return err
+	}
+	return sr.UntilDone(req, nl.DoNothing)
+}
+
+// Remove the static route of the given prefix and metric.
+func Remove(sr *nl.SockReceiver, dst *net.IPNet, metric uint32) error {
+	r := &Route{Dst: dst, Metric: metric}
+	msg, attrs := r.message()
+	msg.Scope = rtnl.RT_SCOPE_NOWHERE
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_DELROUTE,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK,
+	}, msg, attrs...)
+	if err != nil {
+		return err
+	}
+	return sr.UntilDone(req, nl.DoNothing)
+}
+
+func (r *Route) message() (rtnl.RtMsg, []nl.Attr) {
+	ones, _ := r.Dst.Mask.Size()
+	msg := rtnl.RtMsg{
+		Family:   rtnl.AF_INET,
+		Dst_len:  uint8(ones),
+		Table:    uint8(rtnl.RT_TABLE_MAIN),
+		Protocol: rtnl.RTPROT_STATIC,
+		Type:     rtnl.RTN_UNICAST,
+	}
+	dst := r.Dst.IP.To4()
+	if dst == nil {
+		msg.Family = rtnl.AF_INET6
+		dst = r.Dst.IP.To16()
+	}
+	var attrs []nl.Attr
+	if ones > 0 {
+		attrs = append(attrs, nl.Attr{Type: rtnl.RTA_DST,
+			Value: nl.BytesAttr(dst)})
+	}
+	attrs = append(attrs, nl.Attr{Type: rtnl.RTA_PRIORITY,
+		Value: nl.Uint32Attr(r.Metric)})
+	return msg, attrs
+}
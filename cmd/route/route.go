@@ -0,0 +1,227 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package route provides the schema of the redis settable route.* fields
+// kept and applied by routed along with a command to show and change these
+// static routes.
+package route
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "route" }
+
+func (Command) Usage() string {
+	return `route [-json] [show]
+route add PREFIX [via GATEWAY] [dev IFNAME] [metric METRIC]
+route del PREFIX [[via GATEWAY] [dev IFNAME] [metric METRIC]]`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or change static routes",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	route [-json] [show]
+		print the configured static routes and whether each is
+		installed in the kernel's main table
+
+	route add PREFIX [via GATEWAY] [dev IFNAME] [metric METRIC]
+		add a nexthop to the route of PREFIX, ADDRESS[/LEN] or
+		default; nexthops of the same metric are a multipath route
+
+	route del PREFIX [[via GATEWAY] [dev IFNAME] [metric METRIC]]
+		remove the given nexthop, or the route
+
+	These are wrappers of the redis settable fields kept by routed,
+		hset platina route.PREFIX "NEXTHOP[, NEXTHOP]..."
+
+EXAMPLES
+	route add default via 192.168.1.1
+	route add 10.1.0.0/16 via 10.0.0.1 dev eth1 metric 10
+	route add 2001:db8::/32 dev eth2
+
+SEE ALSO
+	routed`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return add(args[1:]...)
+		case "del", "delete":
+			return del(args[1:]...)
+		}
+	}
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	installed, err := dump()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PREFIX\tVIA\tDEV\tMETRIC\tINSTALLED")
+	for _, r := range c.Routes() {
+		status := "no"
+		if r.Matches(installed[Key(r.Dst, r.Metric)]) {
+			status = "yes"
+		}
+		for _, nh := range r.Hops {
+			via := "-"
+			if nh.Gateway != nil {
+				via = nh.Gateway.String()
+			}
+			dev := "-"
+			if len(nh.Dev) > 0 {
+				dev = nh.Dev
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", r.Dst, via, dev,
+				r.Metric, status)
+		}
+	}
+	return w.Flush()
+}
+
+// Get the static routes kept by routed.
+func Get() (Config, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(fields)
+}
+
+func dump() (map[string]*Installed, error) {
+	sock, err := nl.NewSock()
+	if err != nil {
+		return nil, err
+	}
+	defer sock.Close()
+	return Dump(nl.NewSockReceiver(sock))
+}
+
+func add(args ...string) error {
+	prefix, nh, err := parseArgs(true, args...)
+	if err != nil {
+		return err
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	hops := without(c[prefix], nh)
+	return hset(prefix, append(hops, nh))
+}
+
+func del(args ...string) error {
+	prefix, nh, err := parseArgs(false, args...)
+	if err != nil {
+		return err
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	hops, found := c[prefix]
+	if !found {
+		return fmt.Errorf("%s: not found", prefix)
+	}
+	if len(args) > 1 {
+		if len(without(hops, nh)) == len(hops) {
+			return fmt.Errorf("%s %s: not found", prefix, nh)
+		}
+		hops = without(hops, nh)
+	} else {
+		hops = nil
+	}
+	return hset(prefix, hops)
+}
+
+func parseArgs(nexthop bool, args ...string) (string, Nexthop, error) {
+	var nh Nexthop
+	if len(args) < 1 {
+		return "", nh, fmt.Errorf("PREFIX: missing")
+	}
+	if nexthop || len(args) > 1 {
+		var err error
+		if nh, err = ParseNexthop(args[1:]...); err != nil {
+			return "", nh, err
+		}
+	}
+	ipnet, err := ParsePrefix(args[0],
+		nh.Gateway != nil && nh.Gateway.To4() == nil)
+	if err != nil {
+		return "", nh, err
+	}
+	return ipnet.String(), nh, nil
+}
+
+func hset(prefix string, hops []Nexthop) error {
+	_, err := redis.Hset(redis.DefaultHash, Prefix+prefix, Format(hops))
+	return err
+}
+
+// without returns the nexthops less those the same as nh.
+func without(hops []Nexthop, nh Nexthop) []Nexthop {
+	var ret []Nexthop
+	for _, x := range hops {
+		if x.String() != nh.String() {
+			ret = append(ret, x)
+		}
+	}
+	return ret
+}
+
+func (Command) Complete(args ...string) []string {
+	last, prev := complete.Last(args)
+	switch {
+	case len(args) <= 1:
+		return complete.Prefixed(last, "-json", "show", "add", "del")
+	case args[0] != "add" && args[0] != "del" && args[0] != "delete":
+		return nil
+	case len(args) == 2:
+		return complete.Prefixed(last, "default")
+	case prev == "dev":
+		return complete.IfName(last)
+	case prev == "via" || prev == "metric":
+		return nil
+	}
+	return complete.Prefixed(last, "via", "dev", "metric")
+}
@@ -0,0 +1,290 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package routed provides a daemon that keeps the redis settable route.*
+// fields and reconciles the static routes of the kernel's main table with
+// these.
+package routed
+
+import (
+	"fmt"
+	"net/rpc"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/route"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	cfg      route.Config
+	hset     chan hset
+	// IFF_UP and IFF_LOWER_UP of each link by index
+	links map[int32]uint32
+	// last logged error by route key
+	errs map[string]string
+}
+
+// Routed is the RPC handler of the redis settable route.* fields.
+type Routed struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+const upFlags = rtnl.IFF_UP | rtnl.IFF_LOWER_UP
+
+func (*Command) String() string { return "routed" }
+
+func (*Command) Usage() string { return "routed" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "static route daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable fields,
+		route.PREFIX: NEXTHOP[, NEXTHOP]...
+		NEXTHOP := [via GATEWAY] [dev IFNAME] [metric METRIC]
+	in /etc/goes/persist/route then reconcile the kernel's main table
+	with these on start, on each change, and whenever a link comes up
+	or is added, an address is added, or a static route is removed.
+	This restores the routes of the front panel ports after vnetd
+	restarts and recreates or relinks these.
+
+	The nexthops of a prefix with the same metric are installed as one
+	multipath route. IPv6 routes without a metric have the kernel's
+	default, 1024.
+
+	routed owns the "proto static" routes of the main table; it removes
+	any of these that aren't configured.
+
+	A field is kept even if it can't be applied now, e.g. because its
+	dev doesn't exist or is down; such errors are returned to hset and
+	logged once.
+
+FILES
+	/etc/goes/persist/route
+
+SEE ALSO
+	route`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if c.settings, err = persist.Load("route"); err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	if c.cfg, err = route.Parse(fields); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", route.Prefix)
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	c.hset = make(chan hset)
+	rpc.Register(&Routed{c.hset})
+	srvr, err := atsock.NewRpcServer("routed")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", route.Prefix)
+	if err = redis.Assign(key, "routed", "Routed"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	// subscribe before reconciling to not miss intervening changes
+	sub, err := nl.NewSock(nl.NETLINK_ROUTE, 16,
+		rtnl.RTNLGRP_LINK.Bit()|
+			rtnl.RTNLGRP_IPV4_IFADDR.Bit()|
+			rtnl.RTNLGRP_IPV6_IFADDR.Bit()|
+			rtnl.RTNLGRP_IPV4_ROUTE.Bit()|
+			rtnl.RTNLGRP_IPV6_ROUTE.Bit())
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	c.links = make(map[int32]uint32)
+	c.errs = make(map[string]string)
+	c.reconcile()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case b, opened := <-sub.RxCh:
+			if !opened {
+				return sub.Err
+			}
+			changed := false
+			for len(b) >= nl.SizeofHdr {
+				var msg []byte
+				if msg, b, err = nl.Pop(b); err != nil {
+					log.Print("daemon", "err", err)
+					break
+				}
+				if c.changed(msg) {
+					changed = true
+				}
+			}
+			if changed {
+				c.reconcile()
+			}
+		}
+	}
+}
+
+func (routed *Routed) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	routed.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+// set validates the field with the others before saving, publishing, and
+// applying it.
+func (c *Command) set(field, value string) error {
+	value = strings.Join(strings.Fields(value), " ")
+	fields := map[string]string{field: value}
+	for _, x := range c.settings.Fields() {
+		if x != field {
+			fields[x] = c.settings.Get(x)
+		}
+	}
+	cfg, err := route.Parse(fields)
+	if err != nil {
+		return err
+	}
+	if err = c.settings.Set(field, value); err != nil {
+		return err
+	}
+	c.cfg = cfg
+	if len(value) > 0 {
+		c.pub.Print(field, ": ", value)
+	} else {
+		c.pub.Print("delete: ", field)
+	}
+	return c.reconcile()
+}
+
+// changed returns true for a link that is new, removed, or has come up or
+// gone down; a new address; or a removed static route. The kernel flushes
+// the routes of a link that goes down without notice.
+func (c *Command) changed(msg []byte) bool {
+	h := nl.HdrPtr(msg)
+	if h == nil {
+		return false
+	}
+	switch h.Type {
+	case rtnl.RTM_NEWLINK, rtnl.RTM_DELLINK:
+		ifinfo := rtnl.IfInfoMsgPtr(msg)
+		if ifinfo == nil || ifinfo.Family == rtnl.AF_BRIDGE {
+			return false
+		}
+		if h.Type == rtnl.RTM_DELLINK {
+			delete(c.links, ifinfo.Index)
+			return false
+		}
+		flags, found := c.links[ifinfo.Index]
+		c.links[ifinfo.Index] = ifinfo.Flags & upFlags
+		return !found || flags != ifinfo.Flags&upFlags
+	case rtnl.RTM_NEWADDR:
+		return true
+	case rtnl.RTM_DELROUTE:
+		msg := rtnl.RtMsgPtr(msg)
+		return msg != nil && msg.Protocol == rtnl.RTPROT_STATIC &&
+			uint32(msg.Table) == rtnl.RT_TABLE_MAIN
+	}
+	return false
+}
+
+// reconcile the static routes with the configuration, logging changed
+// errors then returning the first.
+func (c *Command) reconcile() error {
+	var first error
+	errs := make(map[string]string)
+	fail := func(key string, err error) {
+		if s := err.Error(); c.errs[key] != s {
+			log.Print("daemon", "err", err)
+		}
+		errs[key] = err.Error()
+		if first == nil {
+			first = err
+		}
+	}
+	defer func() { c.errs = errs }()
+	sock, err := nl.NewSock()
+	if err != nil {
+		fail("", err)
+		return first
+	}
+	defer sock.Close()
+	sr := nl.NewSockReceiver(sock)
+
+	installed, err := route.Dump(sr)
+	if err != nil {
+		fail("", err)
+		return first
+	}
+	for _, r := range c.cfg.Routes() {
+		key := route.Key(r.Dst, r.Metric)
+		in := installed[key]
+		delete(installed, key)
+		if r.Matches(in) {
+			continue
+		}
+		if err = r.Install(sr); err != nil {
+			fail(key, fmt.Errorf("%s: %v", key, err))
+		}
+	}
+	for key, in := range installed {
+		if err = route.Remove(sr, in.Dst, in.Metric); err != nil {
+			fail(key, fmt.Errorf("%s: remove: %v", key, err))
+		}
+	}
+	return first
+}
@@ -0,0 +1,81 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package sbom
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/internal/prog"
+	"github.com/platinasystems/goes/internal/sbom"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "sbom" }
+
+func (Command) Usage() string {
+	return "sbom [-format text|spdx|cyclonedx]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "print the software bill of materials",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the path, version, license, and go.sum hash of the main and
+	each module linked into this program. The versions and hashes are
+	those recorded by the go build; the licenses are the SPDX identifiers
+	found by "go generate ./internal/sbom" from each module's license
+	file, or NOASSERTION if unknown.
+
+OPTIONS
+	-format text|spdx|cyclonedx
+		print a table, the default, or an SPDX 2.2 or CycloneDX 1.2
+		JSON document
+
+EXAMPLES
+	goes sbom -format spdx >goes.spdx.json
+
+SEE ALSO
+	buildinfo, version`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-format")
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	bom := sbom.New(prog.Base())
+	if len(bom.Modules) == 0 {
+		return fmt.Errorf("no build info")
+	}
+	switch parm.ByName["-format"] {
+	case "", "text":
+		return bom.Text(os.Stdout)
+	case "spdx":
+		return bom.SPDX(os.Stdout)
+	case "cyclonedx":
+		return bom.CycloneDX(os.Stdout)
+	}
+	return fmt.Errorf("%s: unknown format", parm.ByName["-format"])
+}
+
+func (Command) Complete(args ...string) []string {
+	last, prev := complete.Last(args)
+	if prev == "-format" {
+		return complete.Prefixed(last, "text", "spdx", "cyclonedx")
+	}
+	return complete.Prefixed(last, "-format")
+}
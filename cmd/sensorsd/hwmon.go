@@ -0,0 +1,223 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package sensorsd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Root of the hwmon class
+var Root = "/sys/class/hwmon"
+
+// Alarms published as sensor.NAME.alarm
+const (
+	Ok       = "ok"
+	Low      = "low"
+	High     = "high"
+	Critical = "critical"
+)
+
+// Sensor is an hwmon input and its limits in scaled units.
+type Sensor struct {
+	// Name published as sensor.Name
+	Name string
+
+	// Chip is the hwmon device name; Input, is the channel, e.g. "temp1".
+	Chip, Input string
+
+	// Path of the input file
+	Path string
+
+	// Scale divides the raw input, e.g. 1000 for millidegrees C
+	Scale float64
+
+	// Min, Max, and Crit are NaN if unset.
+	Min, Max, Crit float64
+
+	alarm string
+}
+
+var nan = math.NaN()
+
+var inputRe = regexp.MustCompile(`^(temp|in|fan|curr|power|energy|humidity)[0-9]+_input$`)
+
+// default scales of the hwmon sysfs ABI units
+var scales = map[string]float64{
+	"temp":     1000,    // millidegree C
+	"in":       1000,    // millivolt
+	"fan":      1,       // RPM
+	"curr":     1000,    // milliamp
+	"power":    1000000, // microwatt
+	"energy":   1000000, // microjoule
+	"humidity": 1000,    // milli-percent
+}
+
+// units of the hwmon input types as published by sensorsd
+var units = map[string]string{
+	"temp":     "C",
+	"in":       "V",
+	"fan":      "RPM",
+	"curr":     "A",
+	"power":    "W",
+	"energy":   "J",
+	"humidity": "%RH",
+}
+
+// Units of the sensor's published values, e.g. C or RPM.
+func (s *Sensor) Units() string {
+	return units[strings.TrimRight(s.Input, "0123456789")]
+}
+
+// Enumerate the sensors of all hwmon devices, ordered by chip and input.
+func Enumerate() ([]Sensor, error) {
+	if err := simulate(); err != nil {
+		return nil, err
+	}
+	dirs, err := filepath.Glob(filepath.Join(Root, "hwmon*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return hwmonIndex(dirs[i]) < hwmonIndex(dirs[j])
+	})
+	var sensors []Sensor
+	chips := make(map[string]int)
+	for _, dir := range dirs {
+		chip := attr(dir, "name")
+		if len(chip) == 0 {
+			// some devices have their attributes in device/
+			chip = attr(filepath.Join(dir, "device"), "name")
+			if len(chip) > 0 {
+				dir = filepath.Join(dir, "device")
+			}
+		}
+		if len(chip) == 0 {
+			chip = filepath.Base(dir)
+		}
+		chip = sanitize(chip)
+		if chips[chip]++; chips[chip] > 1 {
+			chip = fmt.Sprint(chip, "-", chips[chip])
+		}
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		var inputs []string
+		for _, fi := range fis {
+			if inputRe.MatchString(fi.Name()) {
+				inputs = append(inputs, fi.Name())
+			}
+		}
+		sort.Slice(inputs, func(i, j int) bool {
+			return naturalLess(inputs[i], inputs[j])
+		})
+		for _, fn := range inputs {
+			input := strings.TrimSuffix(fn, "_input")
+			kind := inputRe.FindStringSubmatch(fn)[1]
+			s := Sensor{
+				Chip:  chip,
+				Input: input,
+				Path:  filepath.Join(dir, fn),
+				Scale: scales[kind],
+			}
+			label := sanitize(attr(dir, input+"_label"))
+			if len(label) == 0 {
+				label = input
+			}
+			s.Name = chip + "." + label
+			s.Min = s.limit(dir, "min")
+			s.Max = s.limit(dir, "max")
+			s.Crit = s.limit(dir, "crit")
+			sensors = append(sensors, s)
+		}
+	}
+	return sensors, nil
+}
+
+// Read returns the scaled input.
+func (s *Sensor) Read() (float64, error) {
+	b, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", s.Path, err)
+	}
+	return float64(i) / s.Scale, nil
+}
+
+// Alarm returns the classification of the value per the sensor's limits.
+func (s *Sensor) Alarm(v float64) string {
+	switch {
+	case !math.IsNaN(s.Crit) && v >= s.Crit:
+		return Critical
+	case !math.IsNaN(s.Max) && v >= s.Max:
+		return High
+	case !math.IsNaN(s.Min) && v < s.Min:
+		return Low
+	}
+	return Ok
+}
+
+// limit returns the scaled limit attribute or NaN.
+func (s *Sensor) limit(dir, name string) float64 {
+	v := attr(dir, s.Input+"_"+name)
+	if len(v) == 0 {
+		return nan
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nan
+	}
+	// ignore the zero limits of those chips that don't set them
+	if i == 0 && name != "min" {
+		return nan
+	}
+	return float64(i) / s.Scale
+}
+
+func attr(dir, name string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// sanitize lower cases and replaces the spaces and dots of a published name.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '.', ':':
+			return '-'
+		}
+		return r
+	}, strings.ToLower(s))
+}
+
+func hwmonIndex(dir string) int {
+	i, _ := strconv.Atoi(strings.TrimPrefix(filepath.Base(dir), "hwmon"))
+	return i
+}
+
+// naturalLess orders temp2_input before temp10_input.
+func naturalLess(a, b string) bool {
+	ai := strings.IndexAny(a, "0123456789")
+	bi := strings.IndexAny(b, "0123456789")
+	if ai < 0 || bi < 0 || a[:ai] != b[:bi] {
+		return a < b
+	}
+	an, _ := strconv.Atoi(strings.TrimSuffix(a[ai:], "_input"))
+	bn, _ := strconv.Atoi(strings.TrimSuffix(b[bi:], "_input"))
+	return an < bn
+}
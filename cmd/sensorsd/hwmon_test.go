@@ -0,0 +1,69 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package sensorsd
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnumerate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hwmon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(root string) { Root = root }(Root)
+	Root = dir
+	hwmon0 := filepath.Join(dir, "hwmon0")
+	os.Mkdir(hwmon0, 0755)
+	for fn, s := range map[string]string{
+		"name":         "coretemp\n",
+		"temp10_input": "51000\n",
+		"temp2_input":  "45500\n",
+		"temp2_label":  "Core 0\n",
+		"temp2_max":    "80000\n",
+		"temp2_crit":   "100000\n",
+		"in0_input":    "1200\n",
+	} {
+		ioutil.WriteFile(filepath.Join(hwmon0, fn), []byte(s), 0644)
+	}
+	sensors, err := Enumerate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range sensors {
+		names = append(names, s.Name)
+	}
+	if len(names) != 3 || names[0] != "coretemp.in0" ||
+		names[1] != "coretemp.core-0" || names[2] != "coretemp.temp10" {
+		t.Fatalf("%q", names)
+	}
+	s := sensors[1]
+	if v, err := s.Read(); err != nil {
+		t.Fatal(err)
+	} else if v != 45.5 {
+		t.Error("read", v)
+	}
+	if s.Max != 80 || s.Crit != 100 || !math.IsNaN(s.Min) {
+		t.Error("limits", s.Min, s.Max, s.Crit)
+	}
+	for v, want := range map[float64]string{
+		45:  Ok,
+		80:  High,
+		101: Critical,
+	} {
+		if got := s.Alarm(v); got != want {
+			t.Errorf("%v: %s vs. %s", v, got, want)
+		}
+	}
+	if v, _ := sensors[0].Read(); v != 1.2 {
+		t.Error("in0", v)
+	}
+}
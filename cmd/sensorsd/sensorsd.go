@@ -0,0 +1,407 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package sensorsd provides a daemon that publishes the hwmon sensors, with
+// per machine labels, scales, and limits, and their alarms to redis.
+package sensorsd
+
+import (
+	"fmt"
+	"math"
+	"net/rpc"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Prefix of the published and the redis settable limit fields,
+//
+//	sensor.NAME.min|max|crit: VALUE
+//
+// An empty VALUE reverts the limit to that of the driver or machine.
+const Prefix = "sensor."
+
+// Override of an hwmon sensor, keyed by CHIP and INPUT, e.g.
+//
+//	Overrides: map[string]map[string]Override{
+//		"coretemp": {
+//			"temp1": {Label: "cpu", Max: 90, Crit: 100},
+//		},
+//	}
+type Override struct {
+	// Label, if set, replaces the published CHIP.LABEL name
+	Label string
+
+	// Scale, if non-zero, replaces that of the input's unit
+	Scale float64
+
+	// Min, Max, and Crit, if non-zero, replace those of the driver
+	Min, Max, Crit float64
+
+	// Ignore, if true, skips the sensor.
+	Ignore bool
+}
+
+type Command struct {
+	// Machines may override the labels, scales, and limits of their
+	// sensors; the machine configuration file may override these.
+	Overrides map[string]map[string]Override
+
+	// Interval between polls, default: 5s
+	Interval time.Duration
+
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	hset     chan hset
+	sensors  []Sensor
+	// limits of each sensor before those of the settings
+	configured []Sensor
+}
+
+// Sensorsd is the RPC handler of the redis settable sensor limits.
+type Sensorsd struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+func (*Command) String() string { return "sensorsd" }
+
+func (*Command) Usage() string { return "sensorsd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "hwmon sensors publisher daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Enumerate the temperature, voltage, fan, current, power, energy, and
+	humidity inputs of /sys/class/hwmon and periodically publish each,
+	in °C, V, RPM, A, W, J, and %RH, as,
+		sensor.NAME: VALUE
+		sensor.NAME.min: VALUE
+		sensor.NAME.max: VALUE
+		sensor.NAME.crit: VALUE
+		sensor.NAME.alarm: ok|low|high|critical
+		sensor.NAME.units: C|V|RPM|A|W|J|%RH
+
+	The NAME is CHIP.LABEL where CHIP is the hwmon device name and LABEL,
+	that of the driver or, without, the input, e.g. coretemp.core-0 or
+	lm75.temp1; the machine may replace the NAME with its own label.
+
+	The machine may also override the scale and limits of any input. The
+	limits are those of the driver if not overridden. sensorsd logs each
+	alarm change.
+
+	The limits are also redis settable; these are kept in
+	/etc/goes/persist/sensor and override those of the driver and
+	machine until set to an empty value, e.g.
+		hset platina sensor.cpu.max 85
+		hset platina sensor.cpu.max ""
+
+FILES
+	/etc/goes/persist/sensor
+	/etc/goes/machine.yaml
+		sensorsd:
+		  interval: 5s
+		  sensors:
+		    coretemp:
+		      temp1:
+		        label: cpu
+		        max: 90
+		        crit: 100
+		    lm75:
+		      temp2:
+		        ignore: true
+
+SEE ALSO
+	fand`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if c.Interval <= 0 {
+		c.Interval = 5 * time.Second
+	}
+	sensors, err := Enumerate()
+	if err != nil {
+		return err
+	}
+	for _, s := range sensors {
+		if c.override(&s) {
+			c.sensors = append(c.sensors, s)
+		}
+	}
+	if len(c.sensors) == 0 {
+		return fmt.Errorf("no sensors")
+	}
+	c.configured = append([]Sensor{}, c.sensors...)
+	if c.settings, err = persist.Load("sensor"); err != nil {
+		return err
+	}
+	for _, field := range c.settings.Fields() {
+		if err = c.limit(field, c.settings.Get(field)); err != nil {
+			log.Print("daemon", "err", err)
+		}
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.hset = make(chan hset)
+	rpc.Register(&Sensorsd{c.hset})
+	srvr, err := atsock.NewRpcServer("sensorsd")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", Prefix)
+	if err = redis.Assign(key, "sensorsd", "Sensorsd"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	for _, s := range c.sensors {
+		c.pub.Print("sensor.", s.Name, ".units: ", s.Units())
+		for _, x := range []struct {
+			name  string
+			limit float64
+		}{
+			{"min", s.Min},
+			{"max", s.Max},
+			{"crit", s.Crit},
+		} {
+			if !math.IsNaN(x.limit) {
+				c.pub.Print("sensor.", s.Name, ".", x.name, ": ",
+					format(x.limit))
+			}
+		}
+	}
+	t := time.NewTicker(c.Interval)
+	defer t.Stop()
+	for {
+		for i := range c.sensors {
+			c.poll(&c.sensors[i])
+		}
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case <-t.C:
+		}
+	}
+}
+
+func (sensorsd *Sensorsd) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	sensorsd.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+// set the limit then save and publish it.
+func (c *Command) set(field, value string) error {
+	value = strings.TrimSpace(value)
+	if err := c.limit(field, value); err != nil {
+		return err
+	}
+	if err := c.settings.Set(field, value); err != nil {
+		return err
+	}
+	i := strings.LastIndex(field, ".")
+	for _, s := range c.sensors {
+		if Prefix+s.Name == field[:i] {
+			v := map[string]float64{
+				"min":  s.Min,
+				"max":  s.Max,
+				"crit": s.Crit,
+			}[field[i+1:]]
+			if math.IsNaN(v) {
+				c.pub.Print("delete: ", field)
+			} else {
+				c.pub.Print(field, ": ", format(v))
+			}
+		}
+	}
+	return nil
+}
+
+// limit sets the sensor.NAME.min|max|crit field's limit or reverts it to
+// the configured limit with an empty value.
+func (c *Command) limit(field, value string) error {
+	i := strings.LastIndex(field, ".")
+	if !strings.HasPrefix(field, Prefix) || i <= len(Prefix) {
+		return fmt.Errorf("%s: invalid", field)
+	}
+	name, attr := field[len(Prefix):i], field[i+1:]
+	for j := range c.sensors {
+		s, cfg := &c.sensors[j], &c.configured[j]
+		if s.Name != name {
+			continue
+		}
+		var p, def *float64
+		switch attr {
+		case "min":
+			p, def = &s.Min, &cfg.Min
+		case "max":
+			p, def = &s.Max, &cfg.Max
+		case "crit":
+			p, def = &s.Crit, &cfg.Crit
+		default:
+			return fmt.Errorf("%s: not a settable limit", field)
+		}
+		if len(value) == 0 {
+			*p = *def
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("%s: %q isn't a number", field, value)
+		}
+		*p = f
+		return nil
+	}
+	return fmt.Errorf("%s: no such sensor", name)
+}
+
+// configure adds those of sensorsd in the machine configuration file to
+// the machine's compiled in Overrides.
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	if c.Interval, err = cfg.Duration("sensorsd.interval", c.Interval); err != nil {
+		return
+	}
+	if c.Overrides == nil {
+		c.Overrides = make(map[string]map[string]Override)
+	}
+	float := func(path string, def float64) (float64, error) {
+		s := cfg.String(path, "")
+		if len(s) == 0 {
+			return def, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return def, fmt.Errorf("%s: %q isn't a number", path, s)
+		}
+		return f, nil
+	}
+	for _, chip := range cfg.Keys("sensorsd.sensors") {
+		if c.Overrides[chip] == nil {
+			c.Overrides[chip] = make(map[string]Override)
+		}
+		for _, input := range cfg.Keys("sensorsd.sensors." + chip) {
+			prefix := "sensorsd.sensors." + chip + "." + input + "."
+			o := c.Overrides[chip][input]
+			o.Label = cfg.String(prefix+"label", o.Label)
+			if o.Scale, err = float(prefix+"scale", o.Scale); err != nil {
+				return
+			}
+			if o.Min, err = float(prefix+"min", o.Min); err != nil {
+				return
+			}
+			if o.Max, err = float(prefix+"max", o.Max); err != nil {
+				return
+			}
+			if o.Crit, err = float(prefix+"crit", o.Crit); err != nil {
+				return
+			}
+			if o.Ignore, err = cfg.Bool(prefix+"ignore", o.Ignore); err != nil {
+				return
+			}
+			c.Overrides[chip][input] = o
+		}
+	}
+	return
+}
+
+// override the sensor's label, scale, and limits; returns false if ignored.
+func (c *Command) override(s *Sensor) bool {
+	o, found := c.Overrides[s.Chip][s.Input]
+	if !found {
+		return true
+	}
+	if o.Ignore {
+		return false
+	}
+	if len(o.Label) > 0 {
+		s.Name = sanitize(o.Label)
+	}
+	if o.Scale != 0 {
+		// rescale the driver's limits
+		for _, p := range []*float64{&s.Min, &s.Max, &s.Crit} {
+			*p = *p * s.Scale / o.Scale
+		}
+		s.Scale = o.Scale
+	}
+	if o.Min != 0 {
+		s.Min = o.Min
+	}
+	if o.Max != 0 {
+		s.Max = o.Max
+	}
+	if o.Crit != 0 {
+		s.Crit = o.Crit
+	}
+	return true
+}
+
+func (c *Command) poll(s *Sensor) {
+	v, err := s.Read()
+	if err != nil {
+		if s.alarm != "error" {
+			s.alarm = "error"
+			log.Print("daemon", "err", s.Name, ": ", err)
+			c.pub.Print("sensor.", s.Name, ".alarm: error")
+		}
+		return
+	}
+	c.pub.Print("sensor.", s.Name, ": ", format(v))
+	alarm := s.Alarm(v)
+	if alarm == s.alarm {
+		return
+	}
+	if alarm != Ok {
+		log.Print("daemon", "err", s.Name, ": ", alarm, " ", format(v))
+	} else if len(s.alarm) > 0 {
+		log.Print("daemon", "info", s.Name, ": ok ", format(v))
+	}
+	s.alarm = alarm
+	c.pub.Print("sensor.", s.Name, ".alarm: ", alarm)
+}
+
+func format(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,70 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package sensorsd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/platinasystems/goes/external/machine"
+)
+
+// SimRoot is the hwmon class of a simulated machine made from the hwmon
+// section of its fixture, e.g.
+//
+//	hwmon:
+//	  coretemp:
+//	    temp1_input: 45000
+//	    temp1_label: Core 0
+//	    temp1_crit: 100000
+//
+// Each chip, in name order, is a hwmonN directory with a file of each
+// attribute. Existing files are retained, so, a test may rewrite these,
+// e.g. to raise an alarm, without the next command restoring them.
+var SimRoot = filepath.Join(os.TempDir(), "goes-sim", "hwmon")
+
+var sim struct {
+	once sync.Once
+	err  error
+}
+
+// simulate replaces Root with SimRoot if the machine is simulated.
+func simulate() error {
+	sim.once.Do(func() {
+		cfg, err := machine.Sim()
+		if cfg == nil {
+			return
+		}
+		if err != nil {
+			sim.err = err
+			return
+		}
+		for i, chip := range cfg.Keys("hwmon") {
+			dir := filepath.Join(SimRoot, fmt.Sprint("hwmon", i))
+			if sim.err = os.MkdirAll(dir, 0755); sim.err != nil {
+				return
+			}
+			attrs := map[string]string{"name": chip}
+			for _, k := range cfg.Keys("hwmon." + chip) {
+				attrs[k] = cfg.String("hwmon."+chip+"."+k, "")
+			}
+			for k, v := range attrs {
+				fn := filepath.Join(dir, k)
+				if _, err := os.Stat(fn); err == nil {
+					continue
+				}
+				sim.err = ioutil.WriteFile(fn, []byte(v+"\n"), 0644)
+				if sim.err != nil {
+					return
+				}
+			}
+		}
+		Root = SimRoot
+	})
+	return sim.err
+}
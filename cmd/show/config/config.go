@@ -0,0 +1,84 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package config provides the command to show the running or startup
+// configuration as a replayable goes script or config apply file.
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/platinasystems/goes/cmd/config"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "config" }
+
+func (Command) Usage() string {
+	return "show config [-yaml] running|startup"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show the running or startup configuration",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the running configuration, that of the daemons' settable
+	fields with the current link state of each configured port, or the
+	startup configuration, the persisted settings that the daemons
+	restore on start, as a goes script of hset commands or, with -yaml,
+	a file for config apply.
+
+EXAMPLES
+	goes show config running >/tmp/running.goes
+	goes source /tmp/running.goes
+	goes show config -yaml startup >/tmp/startup.yaml
+	goes config apply -n /tmp/startup.yaml
+
+SEE ALSO
+	config diff, config save, config apply`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-yaml")
+	if len(args) == 0 {
+		return fmt.Errorf("running or startup: missing")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	var fields map[string]string
+	var err error
+	switch args[0] {
+	case "running":
+		fields, err = config.Running()
+	case "startup":
+		fields, err = config.Startup()
+	default:
+		return fmt.Errorf("%s: unknown", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	if flag.ByName["-yaml"] {
+		return config.YAML(os.Stdout, fields)
+	}
+	return config.Script(os.Stdout, fields)
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	return complete.Prefixed(last, "-yaml", "running", "startup")
+}
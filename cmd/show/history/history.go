@@ -0,0 +1,96 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package history provides the command to show the counter history retained
+// by redisd.
+package history
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "history" }
+
+func (Command) Usage() string {
+	return "show history [FIELD [DURATION]]"
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show the sampled history of a redis field",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Without a FIELD, list the default hash fields sampled by redisd.
+	Otherwise, print the samples of the FIELD retained for the DURATION,
+	default all, with the per second rate since the prior sample, e.g.
+		TIME                VALUE    RATE
+		Oct 14 10:20:00     123456   1200
+
+	Older samples are downsampled to those of longer intervals.
+
+EXAMPLES
+	goes show history
+	goes show history vnet.eth-1-1.rx-packets 1h
+
+SEE ALSO
+	redisd`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	var d time.Duration
+	switch len(args) {
+	case 0:
+		fields, err := redis.HistoryFields()
+		if err != nil {
+			return err
+		}
+		for _, field := range fields {
+			fmt.Println(field)
+		}
+		return nil
+	case 1:
+	case 2:
+		var err error
+		if d, err = time.ParseDuration(args[1]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	samples, err := redis.History(args[0], d)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "TIME\tVALUE\tRATE")
+	for i, x := range samples {
+		rate := "-"
+		if i > 0 {
+			prev := samples[i-1]
+			dt := x.Time.Sub(prev.Time).Seconds()
+			if dt > 0 && x.Value >= prev.Value {
+				rate = strconv.FormatFloat((x.Value-prev.Value)/dt,
+					'f', 1, 64)
+			}
+		}
+		fmt.Fprint(w, x.Time.Format(time.Stamp), "\t",
+			strconv.FormatFloat(x.Value, 'f', -1, 64), "\t", rate, "\n")
+	}
+	return w.Flush()
+}
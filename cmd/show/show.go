@@ -0,0 +1,27 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package show provides commands to show retained machine state.
+package show
+
+import (
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/show/config"
+	"github.com/platinasystems/goes/cmd/show/history"
+	"github.com/platinasystems/goes/lang"
+)
+
+var Goes = &goes.Goes{
+	NAME: "show",
+	USAGE: `show config [-yaml] running|startup
+show history [FIELD [DURATION]]`,
+	APROPOS: lang.Alt{
+		lang.EnUS: "show retained machine state",
+	},
+	ByName: map[string]cmd.Cmd{
+		"config":  config.Command{},
+		"history": history.Command{},
+	},
+}
@@ -108,7 +108,8 @@ func (c *Command) Main(_ ...string) error {
 		}
 	}
 	fmt.Printf("starting start\n")
-	err := c.g.Main("start")
+	// start returns, rather than shutting down, on SIGTERM to exec /init
+	err := c.g.Main("start", "-no-shutdown")
 	if err != nil {
 		fmt.Printf("Error from start: %s\n", err)
 	}
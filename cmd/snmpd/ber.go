@@ -0,0 +1,374 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package snmpd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// BER tags of the SNMP types and PDUs
+const (
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagOid         = 0x06
+	tagSequence    = 0x30
+	tagIPAddress   = 0x40
+	tagCounter32   = 0x41
+	tagGauge32     = 0x42
+	tagTimeTicks   = 0x43
+	tagCounter64   = 0x46
+
+	tagGetRequest     = 0xa0
+	tagGetNextRequest = 0xa1
+	tagResponse       = 0xa2
+	tagSetRequest     = 0xa3
+	tagGetBulkRequest = 0xa5
+	tagInformRequest  = 0xa6
+	tagTrapV2         = 0xa7
+	tagReport         = 0xa8
+)
+
+// Exceptions of a varbind value
+type Exception byte
+
+const (
+	NoSuchObject   Exception = 0x80
+	NoSuchInstance Exception = 0x81
+	EndOfMibView   Exception = 0x82
+)
+
+// SNMP application types of varbind values; others are int (INTEGER),
+// string (OCTET STRING), Oid, and nil (NULL).
+type (
+	Counter32 uint32
+	Gauge32   uint32
+	TimeTicks uint32
+	Counter64 uint64
+	IPAddress [4]byte
+)
+
+// error-status of a response PDU
+const (
+	noError     = 0
+	tooBig      = 1
+	genErr      = 5
+	notWritable = 17
+)
+
+var errBer = errors.New("malformed BER")
+
+// Oid is an object identifier.
+type Oid []uint32
+
+// ParseOid of dotted decimal, with or without a leading dot.
+func ParseOid(s string) (Oid, error) {
+	var oid Oid
+	for _, sub := range strings.Split(strings.TrimPrefix(s, "."), ".") {
+		u, err := strconv.ParseUint(sub, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("%q: invalid OID", s)
+		}
+		oid = append(oid, uint32(u))
+	}
+	return oid, nil
+}
+
+// MustParseOid panics with an invalid OID.
+func MustParseOid(s string) Oid {
+	oid, err := ParseOid(s)
+	if err != nil {
+		panic(err)
+	}
+	return oid
+}
+
+func (oid Oid) String() string {
+	subs := make([]string, len(oid))
+	for i, sub := range oid {
+		subs[i] = strconv.FormatUint(uint64(sub), 10)
+	}
+	return strings.Join(subs, ".")
+}
+
+// Compare returns -1, 0, or 1 as oid is less, equal, or greater than x in
+// lexicographic order.
+func (oid Oid) Compare(x Oid) int {
+	for i := 0; i < len(oid) && i < len(x); i++ {
+		if oid[i] < x[i] {
+			return -1
+		} else if oid[i] > x[i] {
+			return 1
+		}
+	}
+	switch {
+	case len(oid) < len(x):
+		return -1
+	case len(oid) > len(x):
+		return 1
+	}
+	return 0
+}
+
+// HasPrefix returns true if the oid is, or descends from, the prefix.
+func (oid Oid) HasPrefix(prefix Oid) bool {
+	return len(oid) >= len(prefix) && oid[:len(prefix)].Compare(prefix) == 0
+}
+
+// Append the sub-identifiers to a copy of the oid.
+func (oid Oid) Append(subs ...uint32) Oid {
+	return append(append(make(Oid, 0, len(oid)+len(subs)), oid...), subs...)
+}
+
+// appendTLV appends the tag, length, and content.
+func appendTLV(b []byte, tag byte, content []byte) []byte {
+	b = append(b, tag)
+	n := len(content)
+	switch {
+	case n < 0x80:
+		b = append(b, byte(n))
+	case n < 0x100:
+		b = append(b, 0x81, byte(n))
+	case n < 0x10000:
+		b = append(b, 0x82, byte(n>>8), byte(n))
+	default:
+		b = append(b, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(b, content...)
+}
+
+func appendInt(b []byte, tag byte, i int64) []byte {
+	var content []byte
+	for n := 8; n > 0; n-- {
+		// the minimal two's complement octets
+		if n > 1 {
+			top := i >> uint(8*(n-1)-1)
+			if top == 0 || top == -1 {
+				continue
+			}
+		}
+		for j := n - 1; j >= 0; j-- {
+			content = append(content, byte(i>>uint(8*j)))
+		}
+		break
+	}
+	return appendTLV(b, tag, content)
+}
+
+func appendUint(b []byte, tag byte, u uint64) []byte {
+	content := []byte{0}
+	for n := 8; n > 0; n-- {
+		if u>>uint(8*(n-1)) != 0 {
+			content = content[:0]
+			if u>>uint(8*n-1) != 0 {
+				// leading zero of an unsigned high bit
+				content = append(content, 0)
+			}
+			for j := n - 1; j >= 0; j-- {
+				content = append(content, byte(u>>uint(8*j)))
+			}
+			break
+		}
+	}
+	return appendTLV(b, tag, content)
+}
+
+func appendOid(b []byte, oid Oid) []byte {
+	var content []byte
+	if len(oid) < 2 {
+		oid = append(oid.Append(), 0, 0)[:2]
+	}
+	subs := append(Oid{oid[0]*40 + oid[1]}, oid[2:]...)
+	for _, sub := range subs {
+		var bytes [5]byte
+		i := len(bytes) - 1
+		bytes[i] = byte(sub & 0x7f)
+		for sub >>= 7; sub > 0; sub >>= 7 {
+			i--
+			bytes[i] = byte(sub&0x7f) | 0x80
+		}
+		content = append(content, bytes[i:]...)
+	}
+	return appendTLV(b, tagOid, content)
+}
+
+// appendValue of a varbind
+func appendValue(b []byte, v interface{}) []byte {
+	switch t := v.(type) {
+	case nil:
+		return appendTLV(b, tagNull, nil)
+	case int:
+		return appendInt(b, tagInteger, int64(t))
+	case string:
+		return appendTLV(b, tagOctetString, []byte(t))
+	case []byte:
+		return appendTLV(b, tagOctetString, t)
+	case Oid:
+		return appendOid(b, t)
+	case IPAddress:
+		return appendTLV(b, tagIPAddress, t[:])
+	case Counter32:
+		return appendUint(b, tagCounter32, uint64(t))
+	case Gauge32:
+		return appendUint(b, tagGauge32, uint64(t))
+	case TimeTicks:
+		return appendUint(b, tagTimeTicks, uint64(t))
+	case Counter64:
+		return appendUint(b, tagCounter64, uint64(t))
+	case Exception:
+		return appendTLV(b, byte(t), nil)
+	}
+	panic(fmt.Errorf("%T: unsupported varbind value", v))
+}
+
+// parseTLV returns the tag, content, and remainder of b.
+func parseTLV(b []byte) (tag byte, content, rest []byte, err error) {
+	if len(b) < 2 {
+		return 0, nil, nil, errBer
+	}
+	tag, n, b := b[0], int(b[1]), b[2:]
+	if n&0x80 != 0 {
+		octets := n & 0x7f
+		if octets == 0 || octets > 3 || octets > len(b) {
+			return 0, nil, nil, errBer
+		}
+		n = 0
+		for _, x := range b[:octets] {
+			n = n<<8 | int(x)
+		}
+		b = b[octets:]
+	}
+	if n > len(b) {
+		return 0, nil, nil, errBer
+	}
+	return tag, b[:n], b[n:], nil
+}
+
+// parseExpected parses the TLV of the given tag.
+func parseExpected(b []byte, expect byte) (content, rest []byte, err error) {
+	tag, content, rest, err := parseTLV(b)
+	if err == nil && tag != expect {
+		err = fmt.Errorf("tag %#x: expected %#x", tag, expect)
+	}
+	return content, rest, err
+}
+
+func parseInt(b []byte) (int64, []byte, error) {
+	content, rest, err := parseExpected(b, tagInteger)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(content) == 0 || len(content) > 8 {
+		return 0, nil, errBer
+	}
+	i := int64(int8(content[0]))
+	for _, x := range content[1:] {
+		i = i<<8 | int64(x)
+	}
+	return i, rest, nil
+}
+
+func parseOctets(b []byte) ([]byte, []byte, error) {
+	return parseExpected(b, tagOctetString)
+}
+
+func parseOidContent(content []byte) (Oid, error) {
+	if len(content) == 0 {
+		return nil, errBer
+	}
+	var subs Oid
+	var sub uint32
+	for i, x := range content {
+		if sub > 0x1ffffff {
+			return nil, errBer
+		}
+		sub = sub<<7 | uint32(x&0x7f)
+		if x&0x80 == 0 {
+			subs = append(subs, sub)
+			sub = 0
+		} else if i == len(content)-1 {
+			return nil, errBer
+		}
+	}
+	first := subs[0]
+	oid := Oid{first / 40, first % 40}
+	if first >= 80 {
+		oid = Oid{2, first - 80}
+	}
+	return append(oid, subs[1:]...), nil
+}
+
+// Varbind of a PDU
+type Varbind struct {
+	Oid   Oid
+	Value interface{}
+}
+
+// PDU of a message; a GetBulkRequest has NonRepeaters and MaxRepetitions
+// in place of ErrorStatus and ErrorIndex.
+type PDU struct {
+	Type        byte
+	RequestID   int32
+	ErrorStatus int
+	ErrorIndex  int
+	Varbinds    []Varbind
+}
+
+// ParsePDU of the tag and content; request varbind values are ignored.
+func ParsePDU(b []byte) (*PDU, error) {
+	tag, content, _, err := parseTLV(b)
+	if err != nil {
+		return nil, err
+	}
+	pdu := &PDU{Type: tag}
+	var id, status, index int64
+	if id, content, err = parseInt(content); err != nil {
+		return nil, err
+	}
+	if status, content, err = parseInt(content); err != nil {
+		return nil, err
+	}
+	if index, content, err = parseInt(content); err != nil {
+		return nil, err
+	}
+	pdu.RequestID = int32(id)
+	pdu.ErrorStatus, pdu.ErrorIndex = int(status), int(index)
+	list, _, err := parseExpected(content, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	for len(list) > 0 {
+		var vb, oidContent []byte
+		if vb, list, err = parseExpected(list, tagSequence); err != nil {
+			return nil, err
+		}
+		if oidContent, _, err = parseExpected(vb, tagOid); err != nil {
+			return nil, err
+		}
+		oid, err := parseOidContent(oidContent)
+		if err != nil {
+			return nil, err
+		}
+		pdu.Varbinds = append(pdu.Varbinds, Varbind{Oid: oid})
+	}
+	return pdu, nil
+}
+
+// Marshal the PDU
+func (pdu *PDU) Marshal() []byte {
+	var list []byte
+	for _, vb := range pdu.Varbinds {
+		list = appendTLV(list, tagSequence,
+			appendValue(appendOid(nil, vb.Oid), vb.Value))
+	}
+	b := appendInt(nil, tagInteger, int64(pdu.RequestID))
+	b = appendInt(b, tagInteger, int64(pdu.ErrorStatus))
+	b = appendInt(b, tagInteger, int64(pdu.ErrorIndex))
+	b = appendTLV(b, tagSequence, list)
+	return appendTLV(nil, pdu.Type, b)
+}
@@ -0,0 +1,380 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package snmpd
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Object identifiers of the served tables
+var (
+	System         = MustParseOid("1.3.6.1.2.1.1")
+	IfNumber       = MustParseOid("1.3.6.1.2.1.2.1.0")
+	IfEntry        = MustParseOid("1.3.6.1.2.1.2.2.1")
+	IfXEntry       = MustParseOid("1.3.6.1.2.1.31.1.1.1")
+	EntPhysicalEnt = MustParseOid("1.3.6.1.2.1.47.1.1.1.1")
+	EntPhySensor   = MustParseOid("1.3.6.1.2.1.99.1.1.1")
+	ZeroDotZero    = MustParseOid("0.0")
+)
+
+// Entry of a MIB Table
+type Entry struct {
+	Oid   Oid
+	Value interface{}
+}
+
+// Table of entries in OID order
+type Table []Entry
+
+// SystemInfo of the system group
+type SystemInfo struct {
+	Descr, Contact, Name, Location string
+	// UpTime in hundredths of a second
+	UpTime uint32
+}
+
+// Interface of the ifTable and ifXTable
+type Interface struct {
+	Index        int
+	Name         string
+	Type         int
+	Mtu          int
+	Speed        uint64 // Mb/s
+	HardwareAddr []byte
+	Admin        bool
+	Oper         int
+	Alias        string
+	// Counters by published name, e.g. rx-bytes
+	Counters map[string]uint64
+}
+
+// ifOperStatus of the published IFNAME.state
+var ifOperStatus = map[string]int{
+	"up":             1,
+	"down":           2,
+	"testing":        3,
+	"unknown":        4,
+	"dormant":        5,
+	"notpresent":     6,
+	"lowerlayerdown": 7,
+}
+
+// OperStatus returns the ifOperStatus of the published interface state.
+func OperStatus(state string) int {
+	if i, found := ifOperStatus[state]; found {
+		return i
+	}
+	return 4
+}
+
+// Entity of the entPhysicalTable
+type Entity struct {
+	Index       int
+	Descr       string
+	ContainedIn int
+	Class       int
+	RelPos      int
+	Name        string
+	HardwareRev string
+	SoftwareRev string
+	SerialNum   string
+	MfgName     string
+	ModelName   string
+	IsFRU       bool
+	Sensor      *Sensor
+}
+
+// entPhysicalClass
+const (
+	classChassis     = 3
+	classPowerSupply = 6
+	classFan         = 7
+	classSensor      = 8
+	classModule      = 9
+)
+
+// Sensor of the entPhySensorTable
+type Sensor struct {
+	Name  string
+	Value float64
+	Units string
+	Alarm string
+}
+
+// entPhySensorType and precision by units
+var sensorTypes = map[string]struct{ typ, precision int }{
+	"C":   {8, 1},
+	"V":   {4, 3},
+	"RPM": {10, 0},
+	"A":   {5, 3},
+	"W":   {6, 3},
+	"J":   {1, 0},
+	"%RH": {9, 1},
+}
+
+// Sensors returns the sorted sensors of the sensor.NAME fields published by
+// sensorsd.
+func Sensors(fields map[string]string) []*Sensor {
+	var sensors []*Sensor
+	for field, value := range fields {
+		if !strings.HasPrefix(field, "sensor.") {
+			continue
+		}
+		name := field[len("sensor."):]
+		if _, found := fields[field+".units"]; !found {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		sensors = append(sensors, &Sensor{
+			Name:  name,
+			Value: v,
+			Units: fields[field+".units"],
+			Alarm: fields[field+".alarm"],
+		})
+	}
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].Name < sensors[j].Name
+	})
+	return sensors
+}
+
+// Entities returns the chassis, then the other present eeproms published by
+// eepromd as eeprom.NAME.FIELD, then the sensors.
+func Entities(fields map[string]string, chassis, descr, version string,
+	sensors []*Sensor) []*Entity {
+	var names []string
+	for field, value := range fields {
+		if strings.HasPrefix(field, "eeprom.") &&
+			strings.HasSuffix(field, ".present") && value == "true" {
+			name := field[len("eeprom.") : len(field)-len(".present")]
+			if name != chassis {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	eeprom := func(e *Entity, name string) {
+		prefix := "eeprom." + name + "."
+		e.HardwareRev = fields[prefix+"DeviceVersion"]
+		e.SerialNum = fields[prefix+"SerialNumber"]
+		e.MfgName = fields[prefix+"Manufacturer"]
+		e.ModelName = fields[prefix+"PartNumber"]
+		if s := fields[prefix+"ProductName"]; len(s) > 0 {
+			e.Descr = s
+		}
+	}
+	top := &Entity{
+		Index:       1,
+		Descr:       descr,
+		Class:       classChassis,
+		RelPos:      -1,
+		Name:        chassis,
+		SoftwareRev: version,
+	}
+	eeprom(top, chassis)
+	entities := []*Entity{top}
+	for i, name := range names {
+		e := &Entity{
+			Index:       len(entities) + 1,
+			Descr:       name,
+			ContainedIn: 1,
+			Class:       classModule,
+			RelPos:      i + 1,
+			Name:        name,
+			IsFRU:       true,
+		}
+		switch {
+		case strings.HasPrefix(name, "psu"):
+			e.Class = classPowerSupply
+		case strings.HasPrefix(name, "fan"):
+			e.Class = classFan
+		}
+		eeprom(e, name)
+		entities = append(entities, e)
+	}
+	for i, s := range sensors {
+		entities = append(entities, &Entity{
+			Index:       len(entities) + 1,
+			Descr:       s.Name,
+			ContainedIn: 1,
+			Class:       classSensor,
+			RelPos:      len(names) + i + 1,
+			Name:        s.Name,
+			Sensor:      s,
+		})
+	}
+	return entities
+}
+
+// Build the table of the system group, ifTable, ifXTable,
+// entPhysicalTable, and entPhySensorTable.
+func Build(sys SystemInfo, ifs []*Interface, entities []*Entity) Table {
+	var t Table
+	add := func(oid Oid, v interface{}) {
+		t = append(t, Entry{oid, v})
+	}
+	add(System.Append(1, 0), sys.Descr)
+	add(System.Append(2, 0), ZeroDotZero)
+	add(System.Append(3, 0), TimeTicks(sys.UpTime))
+	add(System.Append(4, 0), sys.Contact)
+	add(System.Append(5, 0), sys.Name)
+	add(System.Append(6, 0), sys.Location)
+	// datalink and internet layers
+	add(System.Append(7, 0), 6)
+	add(IfNumber, len(ifs))
+
+	counter32 := func(ifi *Interface, names ...string) Counter32 {
+		var u uint64
+		for _, name := range names {
+			u += ifi.Counters[name]
+		}
+		return Counter32(u)
+	}
+	ucast := func(ifi *Interface, name string) uint64 {
+		u := ifi.Counters[name]
+		if m := ifi.Counters["multicast"]; name == "rx-packets" && u >= m {
+			u -= m
+		}
+		return u
+	}
+	for _, ifi := range ifs {
+		index := uint32(ifi.Index)
+		col := func(column uint32, v interface{}) {
+			add(IfEntry.Append(column, index), v)
+		}
+		admin, speed := 2, ifi.Speed*1000000
+		if ifi.Admin {
+			admin = 1
+		}
+		if speed > math.MaxUint32 {
+			speed = math.MaxUint32
+		}
+		col(1, ifi.Index)
+		col(2, ifi.Name)
+		col(3, ifi.Type)
+		col(4, ifi.Mtu)
+		col(5, Gauge32(speed))
+		col(6, ifi.HardwareAddr)
+		col(7, admin)
+		col(8, ifi.Oper)
+		col(10, counter32(ifi, "rx-bytes"))
+		col(11, Counter32(ucast(ifi, "rx-packets")))
+		col(12, counter32(ifi, "multicast"))
+		col(13, counter32(ifi, "rx-dropped"))
+		col(14, counter32(ifi, "rx-errors"))
+		col(16, counter32(ifi, "tx-bytes"))
+		col(17, counter32(ifi, "tx-packets"))
+		col(19, counter32(ifi, "tx-dropped"))
+		col(20, counter32(ifi, "tx-errors"))
+	}
+	for _, ifi := range ifs {
+		index := uint32(ifi.Index)
+		col := func(column uint32, v interface{}) {
+			add(IfXEntry.Append(column, index), v)
+		}
+		col(1, ifi.Name)
+		col(2, counter32(ifi, "multicast"))
+		col(6, Counter64(ifi.Counters["rx-bytes"]))
+		col(7, Counter64(ucast(ifi, "rx-packets")))
+		col(8, Counter64(ifi.Counters["multicast"]))
+		col(10, Counter64(ifi.Counters["tx-bytes"]))
+		col(11, Counter64(ifi.Counters["tx-packets"]))
+		col(15, Gauge32(ifi.Speed))
+		col(18, ifi.Alias)
+	}
+	for _, e := range entities {
+		index := uint32(e.Index)
+		col := func(column uint32, v interface{}) {
+			add(EntPhysicalEnt.Append(column, index), v)
+		}
+		fru := 2
+		if e.IsFRU {
+			fru = 1
+		}
+		col(2, e.Descr)
+		col(3, ZeroDotZero)
+		col(4, e.ContainedIn)
+		col(5, e.Class)
+		col(6, e.RelPos)
+		col(7, e.Name)
+		col(8, e.HardwareRev)
+		col(10, e.SoftwareRev)
+		col(11, e.SerialNum)
+		col(12, e.MfgName)
+		col(13, e.ModelName)
+		col(16, fru)
+	}
+	for _, e := range entities {
+		s := e.Sensor
+		if s == nil {
+			continue
+		}
+		index := uint32(e.Index)
+		col := func(column uint32, v interface{}) {
+			add(EntPhySensor.Append(column, index), v)
+		}
+		st, found := sensorTypes[s.Units]
+		if !found {
+			st.typ = 2
+		}
+		v := s.Value * math.Pow10(st.precision)
+		if v > math.MaxInt32 {
+			v = math.MaxInt32
+		} else if v < math.MinInt32 {
+			v = math.MinInt32
+		}
+		status := 1
+		if s.Alarm == "error" {
+			status = 2
+		}
+		col(1, st.typ)
+		// units, i.e. no multiplier
+		col(2, 9)
+		col(3, st.precision)
+		col(4, int(math.Round(v)))
+		col(5, status)
+		col(6, s.Units)
+	}
+	sort.Slice(t, func(i, j int) bool {
+		return t[i].Oid.Compare(t[j].Oid) < 0
+	})
+	return t
+}
+
+// Get returns the value of the oid or an exception.
+func (t Table) Get(oid Oid) interface{} {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].Oid.Compare(oid) >= 0
+	})
+	if i < len(t) && t[i].Oid.Compare(oid) == 0 {
+		return t[i].Value
+	}
+	if len(oid) > 0 {
+		parent := oid[:len(oid)-1]
+		if i < len(t) && t[i].Oid.HasPrefix(parent) ||
+			i > 0 && t[i-1].Oid.HasPrefix(parent) {
+			return NoSuchInstance
+		}
+	}
+	return NoSuchObject
+}
+
+// Next returns the entry that follows the oid, or false at the end of the
+// MIB view.
+func (t Table) Next(oid Oid) (Entry, bool) {
+	i := sort.Search(len(t), func(i int) bool {
+		return t[i].Oid.Compare(oid) > 0
+	})
+	if i < len(t) {
+		return t[i], true
+	}
+	return Entry{}, false
+}
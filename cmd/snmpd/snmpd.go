@@ -0,0 +1,566 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package snmpd provides a read only SNMPv2c and SNMPv3 agent of the system
+// group, IF-MIB, ENTITY-MIB, and ENTITY-SENSOR-MIB from the interfaces and
+// the fields published by vnetd, sensorsd, and eepromd.
+package snmpd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/buildinfo"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+// DefaultListen is the address without a configured snmpd.listen.
+const DefaultListen = ":161"
+
+// maxMsgSize is that of a UDP datagram
+const maxMsgSize = 65507
+
+// the allowance of message headers in the PDU size limit
+const msgOverhead = 256
+
+type Command struct {
+	Listen    string
+	Community string
+	Contact   string
+	Location  string
+	// Chassis is the name of the chassis eeprom published by eepromd
+	Chassis string
+
+	users    map[string]*User
+	engineID []byte
+	boots    int64
+	start    time.Time
+	salt     uint64
+	stats    map[string]uint32
+
+	table   Table
+	tableAt time.Time
+}
+
+func (*Command) String() string { return "snmpd" }
+
+func (*Command) Usage() string { return "snmpd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "SNMP agent daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Serve the Get, GetNext, and GetBulk requests of SNMPv2c and SNMPv3
+	managers of these read only objects,
+		system		1.3.6.1.2.1.1
+		ifNumber	1.3.6.1.2.1.2.1
+		ifTable		1.3.6.1.2.1.2.2
+		ifXTable	1.3.6.1.2.1.31.1.1
+		entPhysicalTable	1.3.6.1.2.1.47.1.1.1
+		entPhySensorTable	1.3.6.1.2.1.99.1.1
+
+	The interface counters are those published by vnetd, or ip link
+	counters, as IFNAME.rx-packets, etc., or without, those of the
+	kernel; ifOperStatus is that of IFNAME.state.
+
+	The physical entities are the chassis, then the other present
+	eeproms published by eepromd, e.g. the power supplies and fan trays,
+	then the sensors published by sensorsd with their values in the
+	entPhySensorTable.
+
+	SNMPv2c requests must have the configured community; without one,
+	these are ignored. SNMPv3 requests must be of a configured user at
+	its security level with HMAC-MD5-96 or HMAC-SHA-96 authentication
+	and AES-128 privacy. Passwords must have at least 8 characters.
+
+	The SNMPv3 engine ID is generated on first start unless configured
+	as hex; it and the count of engine boots are saved in
+	/etc/goes/persist/snmpd.
+
+FILES
+	/etc/goes/machine.yaml
+		snmpd:
+		  listen: ":161"
+		  community: COMMUNITY
+		  contact: noc@example.com
+		  location: rack 12
+		  chassis: chassis
+		  engine-id: 800000000501020304
+		  users:
+		    - name: noc
+		      auth: sha
+		      auth-password: PASSWORD
+		      priv: aes
+		      priv-password: PASSWORD
+	/etc/goes/persist/snmpd
+
+EXAMPLES
+	snmpwalk -v3 -l authPriv -u noc -a SHA -A PASSWORD -x AES \
+		-X PASSWORD SWITCH IF-MIB::ifXTable
+
+SEE ALSO
+	sensorsd, eepromd, promd`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	cfg := machine.Default()
+	c.configure(cfg)
+	if err := c.engine(cfg.String("snmpd.engine-id", "")); err != nil {
+		return err
+	}
+	c.users = make(map[string]*User)
+	for _, i := range cfg.Keys("snmpd.users") {
+		prefix := "snmpd.users." + i + "."
+		u, err := NewUser(cfg.String(prefix+"name", ""),
+			cfg.String(prefix+"auth", ""),
+			cfg.String(prefix+"auth-password", ""),
+			cfg.String(prefix+"priv", ""),
+			cfg.String(prefix+"priv-password", ""),
+			c.engineID)
+		if err != nil {
+			return err
+		}
+		if len(u.Name) == 0 {
+			return fmt.Errorf("%s: missing name", prefix[:len(prefix)-1])
+		}
+		c.users[u.Name] = u
+	}
+	if len(c.Community) == 0 && len(c.users) == 0 {
+		log.Print("daemon", "warning", "no community or users")
+	}
+	c.stats = make(map[string]uint32)
+	conn, err := net.ListenPacket("udp", c.Listen)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	done := make(chan error, 1)
+	go func() { done <- c.serve(conn) }()
+	select {
+	case <-goes.Stop:
+		return nil
+	case err = <-done:
+		return err
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) {
+	c.Listen = cfg.String("snmpd.listen", c.Listen)
+	if len(c.Listen) == 0 {
+		c.Listen = DefaultListen
+	}
+	c.Community = cfg.String("snmpd.community", c.Community)
+	c.Contact = cfg.String("snmpd.contact", c.Contact)
+	c.Location = cfg.String("snmpd.location", c.Location)
+	c.Chassis = cfg.String("snmpd.chassis", c.Chassis)
+	if len(c.Chassis) == 0 {
+		c.Chassis = "chassis"
+	}
+}
+
+// engine loads, or generates, the engine ID and increments its boots.
+func (c *Command) engine(engineID string) (err error) {
+	settings, err := persist.Load("snmpd")
+	if err != nil {
+		return err
+	}
+	if len(engineID) == 0 {
+		engineID = settings.Get("engine-id")
+	}
+	if len(engineID) == 0 {
+		// RFC 3411 format 5, administratively assigned octets
+		b := make([]byte, 13)
+		b[0], b[4] = 0x80, 5
+		if _, err = rand.Read(b[5:]); err != nil {
+			return err
+		}
+		engineID = hex.EncodeToString(b)
+	}
+	if c.engineID, err = hex.DecodeString(engineID); err != nil ||
+		len(c.engineID) < 5 || len(c.engineID) > 32 {
+		return fmt.Errorf("%s: invalid engine ID", engineID)
+	}
+	c.boots, _ = strconv.ParseInt(settings.Get("engine-boots"), 10, 32)
+	if c.boots++; c.boots >= 1<<31-1 {
+		c.boots = 1
+	}
+	if err = settings.Set("engine-id", engineID); err != nil {
+		return err
+	}
+	if err = settings.Set("engine-boots", fmt.Sprint(c.boots)); err != nil {
+		return err
+	}
+	c.start = time.Now()
+	var salt [8]byte
+	rand.Read(salt[:])
+	c.salt = binary.BigEndian.Uint64(salt[:])
+	return nil
+}
+
+func (c *Command) serve(conn net.PacketConn) error {
+	buf := make([]byte, maxMsgSize)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if resp := c.handle(buf[:n]); resp != nil {
+			if _, err = conn.WriteTo(resp, addr); err != nil {
+				log.Print("daemon", "err", addr, ": ", err)
+			}
+		}
+	}
+}
+
+// handle the request returning the response, if any.
+func (c *Command) handle(b []byte) []byte {
+	content, _, err := parseExpected(b, tagSequence)
+	if err != nil {
+		return nil
+	}
+	version, rest, err := parseInt(content)
+	if err != nil {
+		return nil
+	}
+	switch version {
+	case 1:
+		return c.v2c(rest)
+	case 3:
+		return c.v3(b)
+	}
+	return nil
+}
+
+func (c *Command) v2c(b []byte) []byte {
+	community, rest, err := parseOctets(b)
+	if err != nil || len(c.Community) == 0 ||
+		!hmac.Equal(community, []byte(c.Community)) {
+		return nil
+	}
+	pdu, err := ParsePDU(rest)
+	if err != nil {
+		return nil
+	}
+	resp := c.respond(pdu, maxMsgSize-msgOverhead)
+	if resp == nil {
+		return nil
+	}
+	msg := appendInt(nil, tagInteger, 1)
+	msg = appendTLV(msg, tagOctetString, community)
+	return appendTLV(nil, tagSequence, append(msg, resp.Marshal()...))
+}
+
+func (c *Command) v3(b []byte) []byte {
+	m, err := parseV3(b)
+	if err != nil || m.flags&(flagAuth|flagPriv) == flagPriv {
+		return nil
+	}
+	var reqID int32
+	if m.flags&flagPriv == 0 {
+		if pdu, err := parseScopedPDU(m.data); err == nil {
+			reqID = pdu.RequestID
+		}
+	}
+	report := func(oid Oid, user *User, flags byte) []byte {
+		c.stats[oid.String()]++
+		if m.flags&flagReportable == 0 {
+			return nil
+		}
+		pdu := &PDU{
+			Type:      tagReport,
+			RequestID: reqID,
+			Varbinds: []Varbind{{
+				oid, Counter32(c.stats[oid.String()]),
+			}},
+		}
+		return c.v3Response(m, user, flags, pdu)
+	}
+	if !bytes.Equal(m.engineID, c.engineID) {
+		return report(UsmStatsUnknownEngineIDs, nil, 0)
+	}
+	user, found := c.users[string(m.user)]
+	if !found {
+		return report(UsmStatsUnknownUserNames, nil, 0)
+	}
+	if m.flags&(flagAuth|flagPriv) != user.flags() {
+		return report(UsmStatsUnsupportedSecLevels, nil, 0)
+	}
+	if m.flags&flagAuth != 0 {
+		if len(m.authParams) != authParamsLen {
+			return report(UsmStatsWrongDigests, nil, 0)
+		}
+		msg := append([]byte{}, b...)
+		copy(msg[m.authOffset:m.authOffset+authParamsLen],
+			make([]byte, authParamsLen))
+		if !hmac.Equal(m.authParams, user.digest(msg)) {
+			return report(UsmStatsWrongDigests, nil, 0)
+		}
+		if d := m.time - c.engineTime(); m.boots != c.boots ||
+			d > timeWindow || d < -timeWindow {
+			return report(UsmStatsNotInTimeWindows, user, flagAuth)
+		}
+	}
+	data := m.data
+	if m.flags&flagPriv != 0 {
+		octets, _, err := parseOctets(data)
+		if err != nil {
+			return report(UsmStatsDecryptionErrors, nil, 0)
+		}
+		data = append([]byte{}, octets...)
+		err = user.crypt(data, m.boots, m.time, m.privParams, false)
+		if err != nil {
+			return report(UsmStatsDecryptionErrors, nil, 0)
+		}
+	}
+	pdu, err := parseScopedPDU(data)
+	if err != nil {
+		if m.flags&flagPriv != 0 {
+			return report(UsmStatsDecryptionErrors, nil, 0)
+		}
+		return nil
+	}
+	max := m.maxSize
+	if max > maxMsgSize {
+		max = maxMsgSize
+	}
+	resp := c.respond(pdu, int(max)-msgOverhead)
+	if resp == nil {
+		return nil
+	}
+	return c.v3Response(m, user, m.flags&(flagAuth|flagPriv), resp)
+}
+
+// v3Response returns the message of the PDU with the flags' security.
+func (c *Command) v3Response(req *v3Message, user *User, flags byte,
+	pdu *PDU) []byte {
+	m := &v3Message{
+		msgID:    req.msgID,
+		maxSize:  maxMsgSize,
+		flags:    flags,
+		engineID: c.engineID,
+		boots:    c.boots,
+		time:     c.engineTime(),
+		user:     req.user,
+		data:     scopedPDU(c.engineID, pdu),
+	}
+	if flags&flagPriv != 0 {
+		c.salt++
+		m.privParams = make([]byte, 8)
+		binary.BigEndian.PutUint64(m.privParams, c.salt)
+		user.crypt(m.data, m.boots, m.time, m.privParams, true)
+		m.data = appendTLV(nil, tagOctetString, m.data)
+	}
+	b := m.marshal()
+	if flags&flagAuth != 0 {
+		if x, err := parseV3(b); err == nil {
+			copy(b[x.authOffset:], user.digest(b))
+		}
+	}
+	return b
+}
+
+func (c *Command) engineTime() int64 {
+	return int64(time.Since(c.start) / time.Second)
+}
+
+// respond to the request PDU, if it is one, with a response no larger
+// than max.
+func (c *Command) respond(req *PDU, max int) *PDU {
+	resp := &PDU{Type: tagResponse, RequestID: req.RequestID}
+	t := c.getTable()
+	next := func(oid Oid) Varbind {
+		if e, ok := t.Next(oid); ok {
+			return Varbind{e.Oid, e.Value}
+		}
+		return Varbind{oid, EndOfMibView}
+	}
+	switch req.Type {
+	case tagGetRequest:
+		for _, vb := range req.Varbinds {
+			resp.Varbinds = append(resp.Varbinds,
+				Varbind{vb.Oid, t.Get(vb.Oid)})
+		}
+	case tagGetNextRequest:
+		for _, vb := range req.Varbinds {
+			resp.Varbinds = append(resp.Varbinds, next(vb.Oid))
+		}
+	case tagGetBulkRequest:
+		return bulk(req, resp, next, max)
+	case tagSetRequest:
+		resp.ErrorStatus, resp.ErrorIndex = notWritable, 1
+		resp.Varbinds = req.Varbinds
+		return resp
+	default:
+		return nil
+	}
+	if len(resp.Marshal()) > max {
+		resp.ErrorStatus, resp.Varbinds = tooBig, nil
+	}
+	return resp
+}
+
+// bulk responds to the GetBulkRequest with as many repetitions as fit.
+func bulk(req, resp *PDU, next func(Oid) Varbind, max int) *PDU {
+	nonRepeaters, repetitions := req.ErrorStatus, req.ErrorIndex
+	if nonRepeaters < 0 {
+		nonRepeaters = 0
+	} else if nonRepeaters > len(req.Varbinds) {
+		nonRepeaters = len(req.Varbinds)
+	}
+	size := 0
+	add := func(vb Varbind) bool {
+		n := len(appendTLV(nil, tagSequence,
+			appendValue(appendOid(nil, vb.Oid), vb.Value)))
+		if size+n > max {
+			return false
+		}
+		size += n
+		resp.Varbinds = append(resp.Varbinds, vb)
+		return true
+	}
+	for _, vb := range req.Varbinds[:nonRepeaters] {
+		if !add(next(vb.Oid)) {
+			resp.ErrorStatus, resp.Varbinds = tooBig, nil
+			return resp
+		}
+	}
+	repeaters := req.Varbinds[nonRepeaters:]
+	last := make([]Oid, len(repeaters))
+	for i, vb := range repeaters {
+		last[i] = vb.Oid
+	}
+	for r := 0; r < repetitions && len(repeaters) > 0; r++ {
+		end := true
+		for i := range repeaters {
+			vb := next(last[i])
+			if !add(vb) {
+				return resp
+			}
+			last[i] = vb.Oid
+			if vb.Value != EndOfMibView {
+				end = false
+			}
+		}
+		if end {
+			break
+		}
+	}
+	return resp
+}
+
+// getTable returns the table built at most a second ago.
+func (c *Command) getTable() Table {
+	if c.table != nil && time.Since(c.tableAt) < time.Second {
+		return c.table
+	}
+	fields, err := redis.Hgetall(redis.DefaultHash, "")
+	if err != nil {
+		fields = make(map[string]string)
+	}
+	version := buildinfo.New().Version()
+	sys := SystemInfo{
+		Descr:    strings.TrimSpace("goes " + fields["machine"] + " " + version),
+		Contact:  c.Contact,
+		Location: c.Location,
+		UpTime:   uint32(time.Since(c.start) / (10 * time.Millisecond)),
+	}
+	sys.Name, _ = os.Hostname()
+	sensors := Sensors(fields)
+	c.table = Build(sys, interfaces(fields),
+		Entities(fields, c.Chassis, sys.Descr, version, sensors))
+	c.tableAt = time.Now()
+	return c.table
+}
+
+// counters of the kernel's statistics by published name
+var statistics = map[string]string{
+	"rx-packets": "rx_packets",
+	"tx-packets": "tx_packets",
+	"rx-bytes":   "rx_bytes",
+	"tx-bytes":   "tx_bytes",
+	"rx-errors":  "rx_errors",
+	"tx-errors":  "tx_errors",
+	"rx-dropped": "rx_dropped",
+	"tx-dropped": "tx_dropped",
+	"multicast":  "multicast",
+}
+
+// interfaces returns those of the kernel with the published counters and
+// state, if any.
+func interfaces(fields map[string]string) []*Interface {
+	ifs, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var list []*Interface
+	for _, ifi := range ifs {
+		dir := filepath.Join("/sys/class/net", ifi.Name)
+		sysfs := func(name string) string {
+			b, _ := ioutil.ReadFile(filepath.Join(dir, name))
+			return strings.TrimSpace(string(b))
+		}
+		x := &Interface{
+			Index:        ifi.Index,
+			Name:         ifi.Name,
+			Type:         1,
+			Mtu:          ifi.MTU,
+			HardwareAddr: ifi.HardwareAddr,
+			Admin:        ifi.Flags&net.FlagUp != 0,
+			Alias:        sysfs("ifalias"),
+			Counters:     make(map[string]uint64),
+		}
+		switch sysfs("type") {
+		case "1":
+			x.Type = 6
+		case "772":
+			x.Type = 24
+		}
+		if speed, err := strconv.ParseUint(sysfs("speed"), 10, 32); err == nil {
+			x.Speed = speed
+		}
+		state, found := fields[ifi.Name+".state"]
+		if !found {
+			state = sysfs("operstate")
+		}
+		x.Oper = OperStatus(strings.ToLower(state))
+		for name, stat := range statistics {
+			s, found := fields[ifi.Name+"."+name]
+			if !found {
+				s = sysfs(filepath.Join("statistics", stat))
+			}
+			x.Counters[name], _ = strconv.ParseUint(s, 10, 64)
+		}
+		list = append(list, x)
+	}
+	return list
+}
@@ -0,0 +1,132 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package snmpd
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPDU(t *testing.T) {
+	pdu := &PDU{
+		Type:      tagGetNextRequest,
+		RequestID: -12345,
+		Varbinds: []Varbind{
+			{IfEntry.Append(10, 1), nil},
+			{MustParseOid("1.3.6.1.4.1.4294967295"), nil},
+		},
+	}
+	x, err := ParsePDU(pdu.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Type != pdu.Type || x.RequestID != pdu.RequestID ||
+		len(x.Varbinds) != len(pdu.Varbinds) {
+		t.Fatalf("got %+v", x)
+	}
+	for i, vb := range x.Varbinds {
+		if vb.Oid.Compare(pdu.Varbinds[i].Oid) != 0 {
+			t.Errorf("got %v, want %v", vb.Oid, pdu.Varbinds[i].Oid)
+		}
+	}
+	for _, tc := range []struct {
+		v    interface{}
+		want string
+	}{
+		{127, "02017f"},
+		{128, "02020080"},
+		{-129, "0202ff7f"},
+		{Counter32(0xffffffff), "410500ffffffff"},
+		{Counter64(0), "460100"},
+	} {
+		if got := hex.EncodeToString(appendValue(nil, tc.v)); got != tc.want {
+			t.Errorf("%v: got %s, want %s", tc.v, got, tc.want)
+		}
+	}
+}
+
+func TestTable(t *testing.T) {
+	fields := map[string]string{
+		"sensor.cpu.temp":             "47.5",
+		"sensor.cpu.temp.units":       "C",
+		"sensor.board.temp":           "30",
+		"eeprom.chassis.present":      "true",
+		"eeprom.chassis.SerialNumber": "S123",
+		"eeprom.psu1.present":         "true",
+		"eeprom.psu2.present":         "false",
+	}
+	ifs := []*Interface{
+		{Index: 3, Name: "eth-1-1", Type: 6, Admin: true, Oper: 1,
+			Counters: map[string]uint64{"rx-bytes": 1 << 32}},
+	}
+	entities := Entities(fields, "chassis", "goes", "v1", Sensors(fields))
+	if len(entities) != 3 || entities[0].SerialNum != "S123" ||
+		entities[1].Class != classPowerSupply ||
+		entities[2].Sensor == nil {
+		t.Fatalf("got %d entities", len(entities))
+	}
+	tbl := Build(SystemInfo{Descr: "goes"}, ifs, entities)
+	if v := tbl.Get(IfEntry.Append(10, 3)); v != Counter32(0) {
+		t.Errorf("ifInOctets got %v", v)
+	}
+	if v := tbl.Get(IfXEntry.Append(6, 3)); v != Counter64(1<<32) {
+		t.Errorf("ifHCInOctets got %v", v)
+	}
+	if v := tbl.Get(IfEntry.Append(10, 4)); v != NoSuchInstance {
+		t.Errorf("got %v, want NoSuchInstance", v)
+	}
+	if v := tbl.Get(EntPhySensor.Append(4, 3)); v != 475 {
+		t.Errorf("entPhySensorValue got %v", v)
+	}
+	if e, ok := tbl.Next(System); !ok || e.Value != "goes" {
+		t.Errorf("next of system got %v", e)
+	}
+	if _, ok := tbl.Next(EntPhySensor.Append(6, 3)); ok {
+		t.Error("expected end of MIB view")
+	}
+	req := &PDU{
+		Type:        tagGetBulkRequest,
+		ErrorStatus: 1,
+		ErrorIndex:  100,
+		Varbinds: []Varbind{
+			{IfNumber, nil},
+			{EntPhySensor.Append(5), nil},
+		},
+	}
+	next := func(oid Oid) Varbind {
+		if e, ok := tbl.Next(oid); ok {
+			return Varbind{e.Oid, e.Value}
+		}
+		return Varbind{oid, EndOfMibView}
+	}
+	resp := bulk(req, &PDU{}, next, 1000)
+	if len(resp.Varbinds) != 4 ||
+		resp.Varbinds[3].Value != EndOfMibView {
+		t.Errorf("got %+v", resp.Varbinds)
+	}
+}
+
+// RFC 3414 A.3
+func TestLocalizeKey(t *testing.T) {
+	engineID, _ := hex.DecodeString("000000000000000000000002")
+	for _, tc := range []struct {
+		name string
+		want string
+	}{
+		{"md5", "526f5eed9fcce26f8964c2930787d82b"},
+		{"sha", "6695febc9288e36282235fc7151f128497b38f3f"},
+	} {
+		h := md5.New
+		if tc.name == "sha" {
+			h = sha1.New
+		}
+		key := LocalizeKey(h, PasswordToKey(h, "maplesyrup"), engineID)
+		if got := hex.EncodeToString(key); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package snmpd
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// msgFlags of an SNMPv3 message
+const (
+	flagAuth       = 1
+	flagPriv       = 2
+	flagReportable = 4
+)
+
+// usmStats counters reported to the manager
+var (
+	UsmStatsUnsupportedSecLevels = MustParseOid("1.3.6.1.6.3.15.1.1.1.0")
+	UsmStatsNotInTimeWindows     = MustParseOid("1.3.6.1.6.3.15.1.1.2.0")
+	UsmStatsUnknownUserNames     = MustParseOid("1.3.6.1.6.3.15.1.1.3.0")
+	UsmStatsUnknownEngineIDs     = MustParseOid("1.3.6.1.6.3.15.1.1.4.0")
+	UsmStatsWrongDigests         = MustParseOid("1.3.6.1.6.3.15.1.1.5.0")
+	UsmStatsDecryptionErrors     = MustParseOid("1.3.6.1.6.3.15.1.1.6.0")
+)
+
+// the length of the truncated HMAC-MD5-96 and HMAC-SHA-96 digests
+const authParamsLen = 12
+
+// the tolerance of engine time in seconds
+const timeWindow = 150
+
+// User of the user-based security model
+type User struct {
+	Name string
+	// Auth is md5, sha, or empty without authentication
+	Auth string
+	// Priv is aes or empty without privacy
+	Priv string
+
+	hash    func() hash.Hash
+	authKey []byte
+	privKey []byte
+}
+
+// NewUser returns the user with keys localized to the engine from the
+// passwords of at least 8 characters.
+func NewUser(name, auth, authPassword, priv, privPassword string,
+	engineID []byte) (*User, error) {
+	u := &User{Name: name, Auth: auth, Priv: priv}
+	switch auth {
+	case "":
+		if len(priv) > 0 {
+			return nil, fmt.Errorf("%s: privacy requires authentication",
+				name)
+		}
+		return u, nil
+	case "md5":
+		u.hash = md5.New
+	case "sha":
+		u.hash = sha1.New
+	default:
+		return nil, fmt.Errorf("%s: %q: unsupported auth", name, auth)
+	}
+	if len(authPassword) < 8 {
+		return nil, fmt.Errorf("%s: auth password too short", name)
+	}
+	u.authKey = LocalizeKey(u.hash, PasswordToKey(u.hash, authPassword),
+		engineID)
+	switch priv {
+	case "":
+	case "aes":
+		if len(privPassword) < 8 {
+			return nil, fmt.Errorf("%s: priv password too short", name)
+		}
+		u.privKey = LocalizeKey(u.hash,
+			PasswordToKey(u.hash, privPassword), engineID)[:16]
+	default:
+		return nil, fmt.Errorf("%s: %q: unsupported priv", name, priv)
+	}
+	return u, nil
+}
+
+// PasswordToKey hashes a megabyte of the repeated password, RFC 3414 A.2.
+func PasswordToKey(h func() hash.Hash, password string) []byte {
+	const n = 1048576
+	x := h()
+	buf := make([]byte, 64)
+	pw := []byte(password)
+	for i, j := 0, 0; i < n; i += len(buf) {
+		for k := range buf {
+			buf[k] = pw[j%len(pw)]
+			j++
+		}
+		x.Write(buf)
+	}
+	return x.Sum(nil)
+}
+
+// LocalizeKey to the engine, RFC 3414 2.6.
+func LocalizeKey(h func() hash.Hash, key, engineID []byte) []byte {
+	x := h()
+	x.Write(key)
+	x.Write(engineID)
+	x.Write(key)
+	return x.Sum(nil)
+}
+
+func (u *User) flags() byte {
+	var flags byte
+	if u.hash != nil {
+		flags |= flagAuth
+	}
+	if u.privKey != nil {
+		flags |= flagPriv
+	}
+	return flags
+}
+
+// digest of the whole message with zeroed authentication parameters
+func (u *User) digest(msg []byte) []byte {
+	mac := hmac.New(u.hash, u.authKey)
+	mac.Write(msg)
+	return mac.Sum(nil)[:authParamsLen]
+}
+
+// crypt the AES-128-CFB data in place, RFC 3826.
+func (u *User) crypt(data []byte, boots, time int64, salt []byte,
+	encrypt bool) error {
+	if len(salt) != 8 {
+		return errors.New("invalid privacy parameters")
+	}
+	block, err := aes.NewCipher(u.privKey)
+	if err != nil {
+		return err
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint32(iv[0:], uint32(boots))
+	binary.BigEndian.PutUint32(iv[4:], uint32(time))
+	copy(iv[8:], salt)
+	if encrypt {
+		cipher.NewCFBEncrypter(block, iv).XORKeyStream(data, data)
+	} else {
+		cipher.NewCFBDecrypter(block, iv).XORKeyStream(data, data)
+	}
+	return nil
+}
+
+// v3Message is a parsed SNMPv3 message with the USM security parameters.
+type v3Message struct {
+	msgID, maxSize int64
+	flags          byte
+	engineID       []byte
+	boots, time    int64
+	user           []byte
+	authParams     []byte
+	privParams     []byte
+	// authOffset is the index of authParams in the message
+	authOffset int
+	// data is the scopedPDU or, with privacy, the encrypted scopedPDU
+	data []byte
+}
+
+func parseV3(b []byte) (*v3Message, error) {
+	m := new(v3Message)
+	content, _, err := parseExpected(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	var version, model int64
+	var global, flags, sec, usm []byte
+	if version, content, err = parseInt(content); err != nil {
+		return nil, err
+	} else if version != 3 {
+		return nil, fmt.Errorf("version %d: unsupported", version)
+	}
+	if global, content, err = parseExpected(content, tagSequence); err != nil {
+		return nil, err
+	}
+	if m.msgID, global, err = parseInt(global); err != nil {
+		return nil, err
+	}
+	if m.maxSize, global, err = parseInt(global); err != nil {
+		return nil, err
+	}
+	if flags, global, err = parseOctets(global); err != nil {
+		return nil, err
+	} else if len(flags) != 1 {
+		return nil, errBer
+	}
+	m.flags = flags[0]
+	if model, _, err = parseInt(global); err != nil {
+		return nil, err
+	} else if model != 3 {
+		return nil, fmt.Errorf("security model %d: unsupported", model)
+	}
+	if sec, m.data, err = parseOctets(content); err != nil {
+		return nil, err
+	}
+	if usm, _, err = parseExpected(sec, tagSequence); err != nil {
+		return nil, err
+	}
+	if m.engineID, usm, err = parseOctets(usm); err != nil {
+		return nil, err
+	}
+	if m.boots, usm, err = parseInt(usm); err != nil {
+		return nil, err
+	}
+	if m.time, usm, err = parseInt(usm); err != nil {
+		return nil, err
+	}
+	if m.user, usm, err = parseOctets(usm); err != nil {
+		return nil, err
+	}
+	if m.authParams, usm, err = parseOctets(usm); err != nil {
+		return nil, err
+	}
+	if m.privParams, _, err = parseOctets(usm); err != nil {
+		return nil, err
+	}
+	// the parameters are a subslice of the message
+	m.authOffset = cap(b) - cap(m.authParams)
+	return m, nil
+}
+
+// marshal the message with zeroed authentication parameters, if any.
+func (m *v3Message) marshal() []byte {
+	global := appendInt(nil, tagInteger, m.msgID)
+	global = appendInt(global, tagInteger, m.maxSize)
+	global = appendTLV(global, tagOctetString, []byte{m.flags})
+	global = appendInt(global, tagInteger, 3)
+	usm := appendTLV(nil, tagOctetString, m.engineID)
+	usm = appendInt(usm, tagInteger, m.boots)
+	usm = appendInt(usm, tagInteger, m.time)
+	usm = appendTLV(usm, tagOctetString, m.user)
+	if m.flags&flagAuth != 0 {
+		usm = appendTLV(usm, tagOctetString, make([]byte, authParamsLen))
+	} else {
+		usm = appendTLV(usm, tagOctetString, nil)
+	}
+	usm = appendTLV(usm, tagOctetString, m.privParams)
+	b := appendInt(nil, tagInteger, 3)
+	b = appendTLV(b, tagSequence, global)
+	b = appendTLV(b, tagOctetString, appendTLV(nil, tagSequence, usm))
+	b = append(b, m.data...)
+	return appendTLV(nil, tagSequence, b)
+}
+
+// scopedPDU returns the TLV of the context engine ID, empty context name,
+// and PDU.
+func scopedPDU(engineID []byte, pdu *PDU) []byte {
+	b := appendTLV(nil, tagOctetString, engineID)
+	b = appendTLV(b, tagOctetString, nil)
+	return appendTLV(nil, tagSequence, append(b, pdu.Marshal()...))
+}
+
+// parseScopedPDU returns the PDU of the scopedPDU TLV.
+func parseScopedPDU(b []byte) (*PDU, error) {
+	content, _, err := parseExpected(b, tagSequence)
+	if err != nil {
+		return nil, err
+	}
+	if _, content, err = parseOctets(content); err != nil {
+		return nil, err
+	}
+	if _, content, err = parseOctets(content); err != nil {
+		return nil, err
+	}
+	return ParsePDU(content)
+}
@@ -20,7 +20,7 @@ type Command struct {
 func (*Command) String() string { return "source" }
 
 func (*Command) Usage() string {
-	return "source [-x] FILE"
+	return "source [-x] [-i] FILE"
 }
 
 func (*Command) Apropos() lang.Alt {
@@ -33,7 +33,13 @@ func (*Command) Man() lang.Alt {
 	return lang.Alt{
 		lang.EnUS: `
 DESCRIPTION
-	This is equivalent to 'cli [-x] URL'.`,
+	This is equivalent to 'cli [-x] [-i] URL'. The URL may be a file or
+	that of http, https, tftp, scp, sftp, or s3, e.g.:
+		source scp://server/etc/goes/cfg
+
+	With -i, an error of the script prompts for commands, run with its
+	variables, until "resume" or "abort"; see the RECOVERY section of
+	'man cli'.`,
 	}
 }
 
@@ -42,18 +48,23 @@ func (c *Command) Goes(g *goes.Goes) { c.g = g }
 func (*Command) Kind() cmd.Kind { return cmd.DontFork | cmd.CantPipe }
 
 func (c *Command) Main(args ...string) error {
-	flag, args := flags.New(args, "-x")
+	flag, args := flags.New(args, "-x", "-i")
 	if len(args) == 0 {
 		return fmt.Errorf("FILE: missing")
 	}
 	if len(args) > 1 {
 		return fmt.Errorf("%v: unexpected", args[1:])
 	}
-	if flag.ByName["-x"] {
-		args = []string{"cli", "-x", args[0]}
-	} else {
-		args = []string{"cli", args[0]}
+	script := args[0]
+	args = []string{"cli"}
+	for _, t := range []string{"-x", "-i"} {
+		if flag.ByName[t] {
+			args = append(args, t)
+		}
 	}
-	c.g.Catline = nil // Reset the input source
+	args = append(args, script)
+	// the cli reads the script rather than the current input
+	defer func(t goes.Terminal) { c.g.Terminal = t }(c.g.Terminal)
+	c.g.Terminal = nil
 	return c.g.Main(args...)
 }
@@ -6,10 +6,13 @@
 package sshd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"os"
+	"strings"
 	"syscall"
 	"unsafe"
 
@@ -19,6 +22,7 @@ import (
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/handoff"
 	"github.com/platinasystems/goes/external/log"
 	"github.com/platinasystems/goes/internal/prog"
 	"github.com/platinasystems/goes/lang"
@@ -27,6 +31,8 @@ import (
 	gossh "golang.org/x/crypto/ssh"
 )
 
+var logger = log.New("sshd")
+
 type Command struct {
 	g        *goes.Goes
 	done     chan struct{}
@@ -86,9 +92,13 @@ func (c *Command) Main(args ...string) (err error) {
 			cmdline = []string{"cli"}
 		}
 		cmd := prog.Command(cmdline...)
+		env := []string{"USER=" + s.User(), "SSH_CLIENT=" + sshClient(s)}
+		log.Audit.Note("session", "user", s.User(),
+			"remote", s.RemoteAddr(), "command", strings.Join(cmdline, " "))
 		ptyReq, winCh, isPty := s.Pty()
 		if isPty {
 			cmd.Env = append(cmd.Env, fmt.Sprintf("TERM=%s", ptyReq.Term))
+			cmd.Env = append(cmd.Env, env...)
 			f, err := pty.Start(cmd)
 			if err != nil {
 				panic(err)
@@ -112,16 +122,17 @@ func (c *Command) Main(args ...string) (err error) {
 			}()
 			io.Copy(s, f) // stdout
 		} else {
+			cmd.Env = append(os.Environ(), env...)
 			cmd.Stdin = s // blocks exit - do not know why
 			cmd.Stdout = s
 			cmd.Stderr = s.Stderr()
 			err := cmd.Start()
 			if err != nil {
-				fmt.Printf("cmd.Start() returns %s\n", err)
+				logger.Err("start", "user", s.User(), "err", err)
 				s.Exit(1)
 			}
 			err = cmd.Wait()
-			log.Print("sshd wait exited ", err)
+			logger.Info("exited", "user", s.User(), "err", err)
 			if err == nil {
 				s.Exit(0)
 			} else {
@@ -135,12 +146,12 @@ func (c *Command) Main(args ...string) (err error) {
 		authKeys, err := ioutil.ReadFile("/etc/goes/sshd/authorized_keys")
 		if err != nil {
 			if !os.IsNotExist(err) {
-				fmt.Printf("Error reading authorized keys: %s\n", err)
+				logger.Err("authorized keys", "err", err)
 				return c.FailSafe
 			}
 			authKeys, err = ioutil.ReadFile("/etc/goes/sshd/authorized_keys.default")
 			if err != nil {
-				fmt.Printf("Error reading authorized keys.default: %s\n", err)
+				logger.Err("default authorized keys", "err", err)
 				return c.FailSafe
 			}
 		}
@@ -148,7 +159,7 @@ func (c *Command) Main(args ...string) (err error) {
 		for len(authKeys) > 0 {
 			authKey, _, _, rest, err := gossh.ParseAuthorizedKey(authKeys)
 			if err != nil {
-				fmt.Printf("Error parsing authorized_keys: %s\n", err)
+				logger.Err("authorized keys", "err", err)
 				return false
 			}
 			if ssh.KeysEqual(authKey, key) {
@@ -164,17 +175,49 @@ func (c *Command) Main(args ...string) (err error) {
 		return err
 	}
 
+	in, err := handoff.Take("sshd")
+	if err != nil {
+		log.Print("daemon", "err", err)
+	}
+	ln, err := in.Listen("tcp", srv.Addr)
+	in.Close()
+	if err != nil {
+		return err
+	}
+
 	goes.WG.Add(1)
 	go func() {
 		defer goes.WG.Done()
-		_ = srv.ListenAndServe()
+		_ = srv.Serve(ln)
 	}()
 
+	// A successor takes the listener and leaves the sessions to this
+	// instance that exits once these are done.
+	handedoff := make(chan struct{})
+	offer, err := handoff.NewOffer("sshd",
+		func() (map[string]net.Listener, []byte) {
+			return map[string]net.Listener{srv.Addr: ln}, nil
+		}, func() { close(handedoff) })
+	if err != nil {
+		log.Print("daemon", "err", err)
+	} else {
+		defer offer.Close()
+	}
+
 	for {
 		select {
 		case <-goes.Stop:
 			_ = srv.Close()
 			return nil
+		case <-handedoff:
+			return srv.Shutdown(context.Background())
 		}
 	}
 }
+
+// sshClient returns the SSH_CLIENT value of the session, "IP PORT PORT".
+func sshClient(s ssh.Session) string {
+	host, port, _ := net.SplitHostPort(s.RemoteAddr().String())
+	_, lport, _ := net.SplitHostPort(s.LocalAddr().String())
+	return strings.Join([]string{host, port, lport}, " ")
+}
@@ -0,0 +1,226 @@
+// Copyright 2016-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package start
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/internal/prog"
+)
+
+// InitEntry is an inittab like command run by start as PID 1 after the
+// start script.
+type InitEntry struct {
+	// Action is one of,
+	//	once	start without waiting for exit
+	//	wait	start and wait for exit before the next entry
+	//	respawn	start and restart after exit until shutdown
+	Action string
+
+	// Command is the goes or external command line.
+	Command []string
+}
+
+var pid1Mounts = []struct {
+	dir, dev, fstype string
+	mode             os.FileMode
+}{
+	{"/proc", "proc", "proc", 0555},
+	{"/sys", "sysfs", "sysfs", 0555},
+	{"/dev", "devtmpfs", "devtmpfs", 0755},
+	{"/dev/pts", "devpts", "devpts", 0755},
+	{"/run", "tmpfs", "tmpfs", 0755},
+}
+
+// pid1 mounts the virtual filesystems that aren't already, sets the
+// hostname, and has the kernel signal ctrl-alt-del to this init.
+func (c *Command) pid1() {
+	for _, mnt := range pid1Mounts {
+		if mounted(mnt.dir) {
+			continue
+		}
+		if err := os.MkdirAll(mnt.dir, mnt.mode); err != nil {
+			fmt.Fprintln(os.Stderr, mnt.dir, ":", err)
+			continue
+		}
+		err := syscall.Mount(mnt.dev, mnt.dir, mnt.fstype, 0, "")
+		if err != nil && err != syscall.EBUSY {
+			fmt.Fprintln(os.Stderr, "mount", mnt.dir, ":", err)
+		}
+	}
+	if name := c.hostname(); len(name) > 0 {
+		if err := syscall.Sethostname([]byte(name)); err != nil {
+			fmt.Fprintln(os.Stderr, "hostname:", err)
+		}
+	}
+	// SIGINT on ctrl-alt-del rather than an immediate reboot
+	syscall.Reboot(syscall.LINUX_REBOOT_CMD_CAD_OFF)
+}
+
+func mounted(dir string) bool {
+	f, err := os.Open("/proc/mounts")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scan := bufio.NewScanner(f)
+	for scan.Scan() {
+		if fields := strings.Fields(scan.Text()); len(fields) > 1 &&
+			fields[1] == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// hostname returns start.hostname of the machine configuration, that from
+// the machine's Hostname hook, e.g. derived from its EEPROM, or the content
+// of /etc/hostname.
+func (c *Command) hostname() string {
+	if s := machine.Default().String("start.hostname", ""); len(s) > 0 {
+		return s
+	}
+	if c.Hostname != nil {
+		if s, err := c.Hostname(); err != nil {
+			fmt.Fprintln(os.Stderr, "hostname:", err)
+		} else if len(s) > 0 {
+			return s
+		}
+	}
+	b, err := ioutil.ReadFile("/etc/hostname")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// inittab runs the Inittab entries until allClosing.
+func (c *Command) inittab(allClosing <-chan struct{}) {
+	for _, entry := range c.Inittab {
+		if len(entry.Command) == 0 {
+			continue
+		}
+		switch entry.Action {
+		case "wait":
+			if err := c.initCommand(entry.Command).Run(); err != nil {
+				fmt.Fprintln(os.Stderr, entry.Command, ":", err)
+			}
+		case "once":
+			if err := c.initCommand(entry.Command).Start(); err != nil {
+				fmt.Fprintln(os.Stderr, entry.Command, ":", err)
+			}
+		case "respawn":
+			go c.respawn(entry.Command, allClosing)
+		default:
+			fmt.Fprintln(os.Stderr, entry.Command, ":", entry.Action,
+				": unknown action")
+		}
+	}
+}
+
+func (c *Command) respawn(args []string, allClosing <-chan struct{}) {
+	for {
+		x := c.initCommand(args)
+		started := time.Now()
+		if err := x.Start(); err != nil {
+			fmt.Fprintln(os.Stderr, args, ":", err)
+		} else {
+			done := make(chan struct{})
+			go func() {
+				x.Wait()
+				close(done)
+			}()
+			select {
+			case <-allClosing:
+				x.Process.Kill()
+				return
+			case <-done:
+			}
+		}
+		// throttle those that exit immediately
+		delay := time.Second - time.Since(started)
+		if delay < 0 {
+			delay = 0
+		}
+		select {
+		case <-allClosing:
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *Command) initCommand(args []string) *exec.Cmd {
+	var x *exec.Cmd
+	if _, found := c.g.ByName[args[0]]; found {
+		x = prog.Command(args...)
+	} else {
+		x = exec.Command(args[0], args[1:]...)
+	}
+	x.Dir = "/"
+	x.Env = prog.DaemonEnv()
+	x.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return x
+}
+
+// configureInittab overrides the machine's Inittab with start.inittab of the
+// machine configuration file, e.g.
+//
+//	start:
+//	  inittab:
+//	    - action: respawn
+//	      command: /usr/sbin/crond -f
+func (c *Command) configureInittab(cfg *machine.Config) error {
+	if !cfg.Has("start.inittab") {
+		return nil
+	}
+	var entries []InitEntry
+	for _, i := range cfg.Keys("start.inittab") {
+		prefix := "start.inittab." + i + "."
+		entry := InitEntry{
+			Action:  cfg.String(prefix+"action", "once"),
+			Command: cfg.Strings(prefix+"command", nil),
+		}
+		if len(entry.Command) == 0 {
+			return fmt.Errorf("start.inittab.%s: missing command", i)
+		}
+		entries = append(entries, entry)
+	}
+	c.Inittab = entries
+	return nil
+}
+
+// shutdown, as PID 1 without -no-shutdown, after a stop per the received
+// signal,
+//
+//	SIGUSR1	halt
+//	SIGUSR2	power off
+//	SIGINT	reboot, e.g. ctrl-alt-del
+//	SIGTERM	reboot
+func (c *Command) shutdown(sig os.Signal) error {
+	if os.Getpid() != 1 {
+		return nil
+	}
+	how := syscall.LINUX_REBOOT_CMD_RESTART
+	switch sig {
+	case syscall.SIGUSR1:
+		how = syscall.LINUX_REBOOT_CMD_HALT
+	case syscall.SIGUSR2:
+		how = syscall.LINUX_REBOOT_CMD_POWER_OFF
+	}
+	syscall.Sync()
+	if err := c.g.Main("umount", "-a"); err != nil {
+		fmt.Fprintln(os.Stderr, "umount:", err)
+	}
+	return syscall.Reboot(how)
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 	"unsafe"
@@ -19,6 +20,8 @@ import (
 	"github.com/ramr/go-reaper"
 
 	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/internal/assert"
 	"github.com/platinasystems/goes/internal/prog"
@@ -47,12 +50,21 @@ type Command struct {
 
 	// Gettys is the list of ttys to start getty on
 	Gettys []TtyCon
+
+	// As PID 1, start runs these after the start script.
+	Inittab []InitEntry
+
+	// As PID 1, start sets the hostname from start.hostname of the
+	// machine configuration file, this Hostname hook, e.g. from the
+	// EEPROM, or /etc/hostname.
+	Hostname func() (string, error)
 }
 
 func (*Command) String() string { return "start" }
 
 func (*Command) Usage() string {
-	return "start [-start=URL] [-init=URL] [REDIS OPTIONS]..."
+	return "start [-dry-run] [-no-shutdown] [-start=URL] [-init=URL] " +
+		"[REDIS OPTIONS]..."
 }
 
 func (*Command) Apropos() lang.Alt {
@@ -68,15 +80,55 @@ DESCRIPTION
 	Start a redis server followed by the machine and its embedded daemons.
 
 OPTIONS
+	-dry-run, -n
+		Print the steps, daemons, dependencies, environment, and
+		sockets of the start without running anything.
+
+	-no-shutdown
+		As PID 1, return after stopping all daemons on SIGTERM rather
+		than shutting down, e.g. for slashinit to exec /init.
+
 	-init URL
 		Specifies the URL of the machine's configuration script that's
 		sourced immediately before start of all daemons.
-		default: /etc/goes/init
+		default: start.init of /etc/goes/machine.yaml or /etc/goes/init
 
 	-start URL
 		Specifies the URL of the machine's configuration script that's
 		sourced immediately after start of all daemons.
-		default: /etc/goes/start
+		default: start.start of /etc/goes/machine.yaml or
+		/etc/goes/start
+
+FILES
+	/etc/goes/machine.yaml
+		The machine configuration file that may override the
+		compiled in redisd devices, daemon restart policies and
+		dependencies, log capture, and start gettys, e.g.
+
+		redisd:
+		  devs: [eth0]
+		daemons:
+		  vnetd:
+		    restart: on-failure
+		    after: [redisd]
+		start:
+		  hostname: sw1
+		  gettys:
+		    - tty: /dev/ttyS0
+		      baud: 115200
+		  inittab:
+		    - action: respawn
+		      command: /usr/sbin/crond -f
+
+PID 1
+	As /sbin/init, start also mounts /proc, /sys, /dev, /dev/pts, and /run;
+	sets the hostname; reaps orphans; runs the inittab after the start
+	script; and stops all daemons before,
+		SIGTERM, SIGINT (ctrl-alt-del)	reboot
+		SIGUSR1				halt
+		SIGUSR2				power off
+	Otherwise, and with -no-shutdown, start returns after SIGTERM stops all
+	daemons.
 
 SEE ALSO
 	redisd`,
@@ -85,19 +137,120 @@ SEE ALSO
 
 func (c *Command) Goes(g *goes.Goes) { c.g = g }
 
+// plan prints what start would do, in order, without doing it.
+func (c *Command) plan(init, start string, args []string) error {
+	step := 0
+	printf := func(format string, a ...interface{}) {
+		step++
+		fmt.Printf("%d. "+format+"\n", append([]interface{}{step},
+			a...)...)
+	}
+	if len(init) > 0 {
+		printf("source %s", init)
+	}
+	if c.Hook != nil {
+		printf("run machine hook")
+	}
+	printf("start goes-daemons %s", strings.Join(args, " "))
+	daemons := prog.Command(append([]string{"goes-daemons", "-plan"},
+		args...)...)
+	daemons.Stdout = os.Stdout
+	daemons.Stderr = os.Stderr
+	if err := daemons.Run(); err != nil {
+		return err
+	}
+	if c.ConfGpioHook != nil {
+		printf("run machine gpio hook")
+	}
+	if len(start) > 0 {
+		if c.ConfHook != nil {
+			printf("run machine configuration hook")
+		}
+		printf("source %s", start)
+	}
+	for _, getty := range c.Gettys {
+		printf("getty %s@%d (if PID 1)", getty.Tty, getty.Baud)
+	}
+	for _, entry := range c.Inittab {
+		printf("%s %s (if PID 1)", entry.Action,
+			strings.Join(entry.Command, " "))
+	}
+	return nil
+}
+
+// configure overrides the machine's compiled in gettys with those of the
+// machine configuration file, e.g.
+//
+//	start:
+//	  gettys:
+//	    - tty: /dev/ttyS0
+//	      baud: 115200
+func (c *Command) configure(cfg *machine.Config) error {
+	if !cfg.Has("start.gettys") {
+		return nil
+	}
+	var gettys []TtyCon
+	for _, i := range cfg.Keys("start.gettys") {
+		prefix := "start.gettys." + i + "."
+		baud, err := cfg.Int(prefix+"baud", 115200)
+		if err != nil {
+			return err
+		}
+		tty := cfg.String(prefix+"tty", "")
+		if len(tty) == 0 {
+			return fmt.Errorf("start.gettys.%s: missing tty", i)
+		}
+		gettys = append(gettys, TtyCon{Tty: tty, Baud: baud})
+	}
+	c.Gettys = gettys
+	return nil
+}
+
 func (c *Command) Main(args ...string) error {
-	parm, args := parms.New(args, "-start", "-stop", "init")
+	flag, args := flags.New(args, []string{"-dry-run", "--dry-run", "-n"},
+		"-no-shutdown")
+	parm, args := parms.New(args, "-start", "-stop", "-init")
 
 	err := assert.Root()
 	if err != nil {
 		return err
 	}
+	if xerr := machine.Err(); xerr != nil {
+		fmt.Fprintln(os.Stderr, xerr)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if err = c.configureInittab(machine.Default()); err != nil {
+		return err
+	}
 	init := parm.ByName["-init"]
+	if len(init) == 0 {
+		init = machine.Default().String("start.init", "")
+	}
 	if len(init) == 0 {
 		if _, xerr := os.Stat("/etc/goes/init"); xerr == nil {
 			init = "/etc/goes/init"
 		}
 	}
+	start := parm.ByName["-start"]
+	if len(start) == 0 {
+		start = machine.Default().String("start.start", "")
+	}
+	if len(start) == 0 {
+		if _, xerr := os.Stat("/etc/goes/start"); xerr == nil {
+			start = "/etc/goes/start"
+		}
+	}
+
+	if flag.ByName["-dry-run"] {
+		return c.plan(init, start, args)
+	}
+
+	if os.Getpid() == 1 {
+		c.pid1()
+	}
+
 	if len(init) > 0 {
 		err = c.g.Main("source", init)
 		if err != nil {
@@ -126,13 +279,6 @@ func (c *Command) Main(args ...string) error {
 		return err
 	}
 
-	start := parm.ByName["-start"]
-	if len(start) == 0 {
-		if _, xerr := os.Stat("/etc/goes/start"); xerr == nil {
-			start = "/etc/goes/start"
-		}
-	}
-
 	if c.ConfGpioHook != nil {
 		if err = c.ConfGpioHook(); err != nil {
 			return err
@@ -244,13 +390,23 @@ func (c *Command) Main(args ...string) error {
 		}(getty)
 	}
 
+	c.inittab(allClosing)
+
+	// only the machine's init shuts down, not that of slashinit, which
+	// returns to exec /init
+	shutdown := os.Getpid() == 1 && !flag.ByName["-no-shutdown"]
 	csig := make(chan os.Signal, 1)
-	signal.Notify(csig, syscall.SIGTERM)
+	if shutdown {
+		signal.Notify(csig, syscall.SIGTERM, syscall.SIGINT,
+			syscall.SIGUSR1, syscall.SIGUSR2)
+	} else {
+		signal.Notify(csig, syscall.SIGTERM)
+	}
 
 	for {
 		select {
-		case <-csig:
-			fmt.Fprintln(os.Stderr, "Got a signal")
+		case sig := <-csig:
+			fmt.Fprintln(os.Stderr, "Got a signal:", sig)
 			err := c.g.Main("stop")
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error in stop command: %s\n",
@@ -258,7 +414,11 @@ func (c *Command) Main(args ...string) error {
 			}
 			close(allClosing)
 			time.Sleep(100 * time.Millisecond)
-			return nil
+			if !shutdown {
+				return nil
+			}
+			// PID 1 mustn't exit
+			return c.shutdown(sig)
 		}
 	}
 }
@@ -144,6 +144,36 @@ func checkDaemons() error {
 	return err
 }
 
+// checkSupervised reports those daemons that the supervisor hasn't
+// published as running.
+func checkSupervised() error {
+	fields, err := redis.Hkeys(redis.DefaultHash)
+	if err != nil {
+		return err
+	}
+	var errstrings []string
+	for _, field := range fields {
+		if !strings.HasPrefix(field, "goes.daemon.") ||
+			!strings.HasSuffix(field, ".state") {
+			continue
+		}
+		state, err := redis.Hget(redis.DefaultHash, field)
+		if err != nil {
+			return err
+		}
+		if state != "running" {
+			name := strings.TrimSuffix(strings.TrimPrefix(field,
+				"goes.daemon."), ".state")
+			errstrings = append(errstrings,
+				fmt.Sprintf("%s daemon %s", name, state))
+		}
+	}
+	if len(errstrings) > 0 {
+		err = fmt.Errorf(strings.Join(errstrings, "\n"))
+	}
+	return err
+}
+
 func checkRedis() error {
 	s, err := redis.Hget("platina-mk1", "redis.ready")
 	if err != nil {
@@ -182,6 +212,7 @@ func (Command) Main(args ...string) error {
 	}{
 		{"PCI", checkForChip},
 		{"Check daemons", checkDaemons},
+		{"Check supervised", checkSupervised},
 		{"Check Redis", checkRedis},
 		{"Check vnet", checkVnetdHung},
 	} {
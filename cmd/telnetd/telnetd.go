@@ -8,7 +8,6 @@ package telnetd
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"net"
 	"os"
@@ -17,11 +16,14 @@ import (
 
 	"github.com/creack/pty"
 	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/external/log"
 	"github.com/platinasystems/goes/internal/telnet/command"
 	"github.com/platinasystems/goes/internal/telnet/option"
 	"github.com/platinasystems/goes/lang"
 )
 
+var logger = log.New("telnetd")
+
 type Command struct{}
 
 func (Command) String() string { return "telnetd" }
@@ -80,7 +82,7 @@ func (Command) Main(args ...string) error {
 			})
 		tty.Close()
 		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
+			logger.Err("start", "remote", conn.RemoteAddr(), "err", err)
 			pts.Close()
 			continue
 		}
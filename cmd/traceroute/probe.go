@@ -0,0 +1,253 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package traceroute
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	protoIcmp   = 1
+	protoUdp    = 17
+	protoIcmpv6 = 58
+)
+
+type prober struct {
+	dst *net.IPAddr
+	v6  bool
+	// icmp is true for ECHO_REQUEST probes; otherwise, UDP.
+	icmp bool
+	// raw ICMP socket of the responses, and ECHO_REQUEST probes
+	conn *icmp.PacketConn
+	// UDP socket of the probes
+	udp   net.PacketConn
+	sport int
+	// destination port of the first UDP probe
+	port int
+	id   int
+	seq  int
+	buf  []byte
+}
+
+type response struct {
+	Probe
+	reached bool
+}
+
+func newProber(dst *net.IPAddr, useIcmp bool, port int) (*prober, error) {
+	var err error
+	p := &prober{
+		dst:  dst,
+		v6:   dst.IP.To4() == nil,
+		icmp: useIcmp,
+		port: port,
+		id:   os.Getpid() & 0xffff,
+		buf:  make([]byte, 1500),
+	}
+	raw, udp := "ip4:icmp", "udp4"
+	if p.v6 {
+		raw, udp = "ip6:ipv6-icmp", "udp6"
+	}
+	if p.conn, err = icmp.ListenPacket(raw, ""); err != nil {
+		return nil, err
+	}
+	if !p.icmp {
+		if p.udp, err = net.ListenPacket(udp, ""); err != nil {
+			p.conn.Close()
+			return nil, err
+		}
+		p.sport = p.udp.LocalAddr().(*net.UDPAddr).Port
+	}
+	return p, nil
+}
+
+func (p *prober) close() {
+	p.conn.Close()
+	if p.udp != nil {
+		p.udp.Close()
+	}
+}
+
+// probe sends the next probe with the given TTL and returns its response;
+// the Addr of the returned Probe is empty if it timed out.
+func (p *prober) probe(ttl int, wait time.Duration) (response, error) {
+	var r response
+	seq := p.seq
+	p.seq++
+	if err := p.send(ttl, seq); err != nil {
+		return r, err
+	}
+	sent := time.Now()
+	deadline := sent.Add(wait)
+	if err := p.conn.SetReadDeadline(deadline); err != nil {
+		return r, err
+	}
+	for {
+		n, from, err := p.conn.ReadFrom(p.buf)
+		at := time.Now()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				return r, nil
+			}
+			return r, err
+		}
+		proto := protoIcmp
+		if p.v6 {
+			proto = protoIcmpv6
+		}
+		m, err := icmp.ParseMessage(proto, p.buf[:n])
+		if err != nil {
+			continue
+		}
+		if !p.match(m, seq, &r) {
+			continue
+		}
+		if ipa, ok := from.(*net.IPAddr); ok {
+			r.Addr = ipa.IP.String()
+		} else {
+			r.Addr = from.String()
+		}
+		r.Rtt = float64(at.Sub(sent)) / float64(time.Millisecond)
+		return r, nil
+	}
+}
+
+func (p *prober) send(ttl, seq int) error {
+	if !p.icmp {
+		if p.v6 {
+			ipv6.NewPacketConn(p.udp).SetHopLimit(ttl)
+		} else {
+			ipv4.NewPacketConn(p.udp).SetTTL(ttl)
+		}
+		_, err := p.udp.WriteTo(make([]byte, 32), &net.UDPAddr{
+			IP:   p.dst.IP,
+			Port: (p.port + seq) & 0xffff,
+			Zone: p.dst.Zone,
+		})
+		return err
+	}
+	var typ icmp.Type = ipv4.ICMPTypeEcho
+	if p.v6 {
+		typ = ipv6.ICMPTypeEchoRequest
+		p.conn.IPv6PacketConn().SetHopLimit(ttl)
+	} else {
+		p.conn.IPv4PacketConn().SetTTL(ttl)
+	}
+	b, err := (&icmp.Message{
+		Type: typ,
+		Body: &icmp.Echo{
+			ID:   p.id,
+			Seq:  seq & 0xffff,
+			Data: make([]byte, 32),
+		},
+	}).Marshal(nil)
+	if err != nil {
+		return err
+	}
+	_, err = p.conn.WriteTo(b, p.dst)
+	return err
+}
+
+// match returns true if the message is a response to the probe and sets
+// whether it's from the destination or annotates unreachable responses.
+func (p *prober) match(m *icmp.Message, seq int, r *response) bool {
+	var data []byte
+	switch body := m.Body.(type) {
+	case *icmp.Echo:
+		if !p.icmp || body.ID != p.id || body.Seq != seq&0xffff {
+			return false
+		}
+		if m.Type != ipv4.ICMPTypeEchoReply &&
+			m.Type != ipv6.ICMPTypeEchoReply {
+			return false
+		}
+		r.reached = true
+		return true
+	case *icmp.TimeExceeded:
+		data = body.Data
+	case *icmp.DstUnreach:
+		data = body.Data
+		r.Note, r.reached = unreachable(m, p.icmp)
+	default:
+		return false
+	}
+	if !p.quoted(data, seq) {
+		r.Note, r.reached = "", false
+		return false
+	}
+	return true
+}
+
+// quoted returns true if the datagram quoted by an ICMP error is that of
+// the probe.
+func (p *prober) quoted(data []byte, seq int) bool {
+	var proto int
+	var l4 []byte
+	if p.v6 {
+		// assumes no extension headers
+		if len(data) < 40 {
+			return false
+		}
+		proto, l4 = int(data[6]), data[40:]
+	} else {
+		if len(data) < 20 {
+			return false
+		}
+		ihl := int(data[0]&0xf) << 2
+		if len(data) < ihl {
+			return false
+		}
+		proto, l4 = int(data[9]), data[ihl:]
+	}
+	if len(l4) < 8 {
+		return false
+	}
+	if p.icmp {
+		if proto != protoIcmp && proto != protoIcmpv6 {
+			return false
+		}
+		return int(binary.BigEndian.Uint16(l4[4:])) == p.id &&
+			int(binary.BigEndian.Uint16(l4[6:])) == seq&0xffff
+	}
+	return proto == protoUdp &&
+		int(binary.BigEndian.Uint16(l4[0:])) == p.sport &&
+		int(binary.BigEndian.Uint16(l4[2:])) == (p.port+seq)&0xffff
+}
+
+// unreachable returns the note of a DESTINATION UNREACHABLE or, if it's
+// the PORT UNREACHABLE of a UDP probe, that the destination was reached.
+func unreachable(m *icmp.Message, useIcmp bool) (note string, reached bool) {
+	if m.Type == ipv6.ICMPTypeDestinationUnreachable {
+		switch m.Code {
+		case 0:
+			return "!N", false
+		case 1:
+			return "!X", false
+		case 4:
+			return "", !useIcmp
+		}
+		return "!H", false
+	}
+	switch m.Code {
+	case 0:
+		return "!N", false
+	case 1:
+		return "!H", false
+	case 2:
+		return "!P", false
+	case 3:
+		return "", !useIcmp
+	case 9, 10, 13:
+		return "!X", false
+	}
+	return "!H", false
+}
@@ -0,0 +1,61 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package traceroute
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+func TestMatch(t *testing.T) {
+	p := &prober{
+		dst:   &net.IPAddr{IP: net.ParseIP("192.0.2.1")},
+		sport: 40000,
+		port:  33434,
+	}
+	// quoted IPv4 header and UDP header of the third probe
+	quote := []byte{
+		0x45, 0, 0, 60, 0, 0, 0, 0, 1, protoUdp, 0, 0,
+		10, 0, 0, 2, 192, 0, 2, 1,
+		0x9c, 0x40, 0x82, 0x9c, 0, 40, 0, 0,
+	}
+	for _, x := range []struct {
+		m       *icmp.Message
+		seq     int
+		match   bool
+		reached bool
+		note    string
+	}{
+		{&icmp.Message{
+			Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quote},
+		}, 2, true, false, ""},
+		{&icmp.Message{
+			Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quote},
+		}, 1, false, false, ""},
+		{&icmp.Message{
+			Type: ipv4.ICMPTypeDestinationUnreachable,
+			Code: 3,
+			Body: &icmp.DstUnreach{Data: quote},
+		}, 2, true, true, ""},
+		{&icmp.Message{
+			Type: ipv4.ICMPTypeDestinationUnreachable,
+			Code: 1,
+			Body: &icmp.DstUnreach{Data: quote},
+		}, 2, true, false, "!H"},
+	} {
+		var r response
+		match := p.match(x.m, x.seq, &r)
+		if match != x.match || r.reached != x.reached ||
+			r.Note != x.note {
+			t.Errorf("%v code %d seq %d: %v %v %q", x.m.Type, x.m.Code,
+				x.seq, match, r.reached, r.Note)
+		}
+	}
+}
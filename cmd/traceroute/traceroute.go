@@ -0,0 +1,236 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package traceroute
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+// Trace of the route to a destination
+type Trace struct {
+	Destination string `json:"destination"`
+	Address     string `json:"address"`
+	Protocol    string `json:"protocol"`
+	Hops        []Hop  `json:"hops"`
+	// Reached is true if the last hop is the destination.
+	Reached bool `json:"reached"`
+}
+
+type Hop struct {
+	Ttl    int     `json:"ttl"`
+	Probes []Probe `json:"probes"`
+}
+
+// Probe response; the Addr of a timed out probe is empty.
+type Probe struct {
+	Addr string  `json:"addr,omitempty"`
+	Rtt  float64 `json:"rtt_ms,omitempty"`
+	// Note of unreachable responses, e.g. "!H", "!N", "!P", or "!X"
+	Note string `json:"note,omitempty"`
+}
+
+func (Command) String() string { return "traceroute" }
+
+func (Command) Usage() string {
+	return `traceroute [-4 | -6] [-I] [-f FIRST] [-m MAX] [-q NQUERIES] [-w WAIT]
+	[-p PORT] [-json] DESTINATION`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "print the route packets trace to network host",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Trace the route to the DESTINATION by sending UDP datagrams, or with
+	-I, ICMP ECHO_REQUEST, with increasing TTL, or IPv6 hop limit, then
+	print the address and round trip time of each probe's ICMP TIME
+	EXCEEDED or, from the destination, PORT UNREACHABLE or ECHO_REPLY.
+
+	Timed out probes are printed as "*" and unreachable responses other
+	than those of the destination are annotated with !N (network), !H
+	(host), !P (protocol), or !X (administratively prohibited).
+
+	traceroute receives responses through a raw ICMP socket so it must
+	be run with CAP_NET_RAW.
+
+OPTIONS
+	-4, -6	resolve DESTINATION to an IPv4 or IPv6 address
+	-I	probe with ICMP ECHO_REQUEST instead of UDP
+	-f FIRST
+		initial TTL, default: 1
+	-m MAX	maximum TTL, default: 30
+	-q NQUERIES
+		probes per hop, default: 3
+	-w WAIT	seconds, or duration, to wait for each response, default: 5
+	-p PORT	the UDP destination port of the first probe that's
+		incremented for each subsequent probe, default: 33434
+	-json	print the trace as JSON
+
+SEE ALSO
+	ping`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-f", "-m", "-q", "-w", "-p")
+	flag, args := flags.New(args, "-4", "-6", "-I", "-json")
+	if n := len(args); n == 0 {
+		return fmt.Errorf("DESTINATION: missing")
+	} else if n > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	first, err := uintParm(parm.ByName, "-f", 1, 255)
+	if err != nil {
+		return err
+	}
+	max, err := uintParm(parm.ByName, "-m", 30, 255)
+	if err != nil {
+		return err
+	}
+	if first > max {
+		return fmt.Errorf("-f: %d exceeds -m %d", first, max)
+	}
+	nqueries, err := uintParm(parm.ByName, "-q", 3, 10)
+	if err != nil {
+		return err
+	}
+	port, err := uintParm(parm.ByName, "-p", 33434, 65535)
+	if err != nil {
+		return err
+	}
+	wait := 5 * time.Second
+	if s := parm.ByName["-w"]; len(s) > 0 {
+		if wait, err = time.ParseDuration(s); err != nil {
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil || f <= 0 {
+				return fmt.Errorf("-w: %q invalid", s)
+			}
+			wait = time.Duration(f * float64(time.Second))
+		}
+	}
+	network := "ip"
+	if flag.ByName["-4"] {
+		network = "ip4"
+	} else if flag.ByName["-6"] {
+		network = "ip6"
+	}
+
+	dest := args[0]
+	dst, err := net.ResolveIPAddr(network, dest)
+	if err != nil {
+		return err
+	}
+	p, err := newProber(dst, flag.ByName["-I"], port)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+
+	js := flag.ByName["-json"]
+	trace := &Trace{
+		Destination: dest,
+		Address:     dst.String(),
+		Protocol:    "udp",
+		Hops:        []Hop{},
+	}
+	if p.icmp {
+		trace.Protocol = "icmp"
+	}
+	if !js {
+		fmt.Printf("traceroute to %s (%s), %d hops max\n", dest,
+			trace.Address, max)
+	}
+	for ttl := first; ttl <= max && !trace.Reached; ttl++ {
+		hop := Hop{Ttl: ttl}
+		unreachable := false
+		if !js {
+			fmt.Printf("%2d ", ttl)
+		}
+		for q := 0; q < nqueries; q++ {
+			r, err := p.probe(ttl, wait)
+			if err != nil {
+				if !js {
+					fmt.Println()
+				}
+				return err
+			}
+			if r.reached {
+				trace.Reached = true
+			}
+			if len(r.Note) > 0 {
+				unreachable = true
+			}
+			if !js {
+				printProbe(hop.Probes, r.Probe)
+			}
+			hop.Probes = append(hop.Probes, r.Probe)
+		}
+		if !js {
+			fmt.Println()
+		}
+		trace.Hops = append(trace.Hops, hop)
+		if unreachable {
+			break
+		}
+	}
+	if js {
+		b, err := json.MarshalIndent(trace, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	}
+	return nil
+}
+
+// printProbe prints the address, if it differs from that of the prior
+// response, then the round trip time of the probe.
+func printProbe(prior []Probe, pr Probe) {
+	if len(pr.Addr) == 0 {
+		fmt.Print(" *")
+		return
+	}
+	prev := ""
+	for _, x := range prior {
+		if len(x.Addr) > 0 {
+			prev = x.Addr
+		}
+	}
+	if pr.Addr != prev {
+		fmt.Print(" ", pr.Addr)
+	}
+	fmt.Printf("  %.3f ms", math.Round(pr.Rtt*1000)/1000)
+	if len(pr.Note) > 0 {
+		fmt.Print(" ", pr.Note)
+	}
+}
+
+func uintParm(byName parms.ByName, name string, def, max int) (int, error) {
+	s := byName[name]
+	if len(s) == 0 {
+		return def, nil
+	}
+	u, err := strconv.ParseUint(s, 0, 32)
+	if err != nil || u == 0 || u > uint64(max) {
+		return def, fmt.Errorf("%s: %q invalid", name, s)
+	}
+	return int(u), nil
+}
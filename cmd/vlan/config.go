@@ -0,0 +1,159 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package vlan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+// Prefix of the redis settable fields,
+//
+//	vlan.VID.tagged: PORT...
+//	vlan.VID.untagged: PORT...
+//	vlan.stp.PORT: disabled|listening|learning|forwarding|blocking
+//
+// and the field published by vland,
+//
+//	vlan.bridge: BRIDGE
+const Prefix = "vlan."
+
+const BridgeField = Prefix + "bridge"
+
+// Config of VLAN membership and port STP state
+type Config struct {
+	Vlans map[uint16]*Vlan `json:"vlans"`
+	// STP state by port
+	Stp map[string]string `json:"stp,omitempty"`
+}
+
+// Vlan members
+type Vlan struct {
+	Tagged   []string `json:"tagged,omitempty"`
+	Untagged []string `json:"untagged,omitempty"`
+}
+
+// Parse the vlan.* fields, less vlan.bridge, returning an error if any are
+// invalid or if a port is an untagged member of more than one VLAN.
+func Parse(fields map[string]string) (*Config, error) {
+	c := &Config{
+		Vlans: make(map[uint16]*Vlan),
+		Stp:   make(map[string]string),
+	}
+	for field, value := range fields {
+		if err := c.set(field, value); err != nil {
+			return nil, err
+		}
+	}
+	untagged := make(map[string]uint16)
+	for _, vid := range c.Vids() {
+		for _, port := range c.Vlans[vid].Untagged {
+			if other, found := untagged[port]; found {
+				return nil, fmt.Errorf("%s: untagged in vlan %d and %d",
+					port, other, vid)
+			}
+			untagged[port] = vid
+		}
+	}
+	return c, nil
+}
+
+func (c *Config) set(field, value string) error {
+	if field == BridgeField {
+		return nil
+	}
+	if !strings.HasPrefix(field, Prefix) {
+		return fmt.Errorf("%s: not a vlan field", field)
+	}
+	s := field[len(Prefix):]
+	if strings.HasPrefix(s, "stp.") {
+		port := s[len("stp."):]
+		if len(port) == 0 {
+			return fmt.Errorf("%s: missing port", field)
+		}
+		if len(value) == 0 {
+			return nil
+		}
+		if _, found := rtnl.BrStateByName[value]; !found {
+			return fmt.Errorf("%s: %q: invalid state", field, value)
+		}
+		c.Stp[port] = value
+		return nil
+	}
+	dot := strings.Index(s, ".")
+	if dot < 0 {
+		return fmt.Errorf("%s: invalid", field)
+	}
+	vid, err := ParseVid(s[:dot])
+	if err != nil {
+		return fmt.Errorf("%s: %v", field, err)
+	}
+	ports := strings.Fields(value)
+	if len(ports) == 0 {
+		return nil
+	}
+	v, found := c.Vlans[vid]
+	if !found {
+		v = new(Vlan)
+		c.Vlans[vid] = v
+	}
+	switch s[dot+1:] {
+	case "tagged":
+		v.Tagged = ports
+	case "untagged":
+		v.Untagged = ports
+	default:
+		return fmt.Errorf("%s: invalid", field)
+	}
+	return nil
+}
+
+// ParseVid returns the VLAN ID of the string or an error if it's not in
+// the range of 1 through 4094.
+func ParseVid(s string) (uint16, error) {
+	u, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || u < 1 || u > 4094 {
+		return 0, fmt.Errorf("%q: invalid vlan id", s)
+	}
+	return uint16(u), nil
+}
+
+// Vids returns the sorted VLAN IDs.
+func (c *Config) Vids() []uint16 {
+	vids := make([]uint16, 0, len(c.Vlans))
+	for vid := range c.Vlans {
+		vids = append(vids, vid)
+	}
+	sort.Slice(vids, func(i, j int) bool { return vids[i] < vids[j] })
+	return vids
+}
+
+// Ports returns the VLANs of each member port with their
+// rtnl.BRIDGE_VLAN_INFO_* flags; an untagged member is also the PVID.
+func (c *Config) Ports() map[string]map[uint16]uint16 {
+	ports := make(map[string]map[uint16]uint16)
+	add := func(port string, vid, flags uint16) {
+		m, found := ports[port]
+		if !found {
+			m = make(map[uint16]uint16)
+			ports[port] = m
+		}
+		m[vid] = flags
+	}
+	for vid, v := range c.Vlans {
+		for _, port := range v.Tagged {
+			add(port, vid, 0)
+		}
+		for _, port := range v.Untagged {
+			add(port, vid, rtnl.BRIDGE_VLAN_INFO_PVID|
+				rtnl.BRIDGE_VLAN_INFO_UNTAGGED)
+		}
+	}
+	return ports
+}
@@ -0,0 +1,56 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package vlan
+
+import (
+	"testing"
+
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+func TestParse(t *testing.T) {
+	c, err := Parse(map[string]string{
+		"vlan.bridge":       "br0",
+		"vlan.100.untagged": "eth-1-1",
+		"vlan.100.tagged":   "eth-2-1 eth-3-1",
+		"vlan.200.tagged":   "eth-1-1",
+		"vlan.300.tagged":   "",
+		"vlan.stp.eth-2-1":  "blocking",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vids := c.Vids(); len(vids) != 2 || vids[0] != 100 || vids[1] != 200 {
+		t.Errorf("vids: %v", vids)
+	}
+	ports := c.Ports()
+	pvid := rtnl.BRIDGE_VLAN_INFO_PVID | rtnl.BRIDGE_VLAN_INFO_UNTAGGED
+	if flags, found := ports["eth-1-1"][100]; !found || flags != pvid {
+		t.Errorf("eth-1-1: vlan 100: %#x", flags)
+	}
+	if flags, found := ports["eth-1-1"][200]; !found || flags != 0 {
+		t.Errorf("eth-1-1: vlan 200: %#x", flags)
+	}
+	if len(ports["eth-3-1"]) != 1 {
+		t.Errorf("eth-3-1: %v", ports["eth-3-1"])
+	}
+	if c.Stp["eth-2-1"] != "blocking" {
+		t.Errorf("eth-2-1: stp: %q", c.Stp["eth-2-1"])
+	}
+	for _, fields := range []map[string]string{
+		{"vlan.0.tagged": "eth-1-1"},
+		{"vlan.4095.tagged": "eth-1-1"},
+		{"vlan.100.members": "eth-1-1"},
+		{"vlan.stp.eth-1-1": "on"},
+		{
+			"vlan.100.untagged": "eth-1-1",
+			"vlan.200.untagged": "eth-1-1",
+		},
+	} {
+		if _, err = Parse(fields); err == nil {
+			t.Errorf("%v: expected error", fields)
+		}
+	}
+}
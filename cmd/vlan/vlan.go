@@ -0,0 +1,303 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package vlan provides the schema of the redis settable vlan.* fields kept
+// and applied by vland along with a command to show and change these.
+package vlan
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "vlan" }
+
+func (Command) Usage() string {
+	return `vlan [-json] [show] [VID]...
+vlan add VID [tagged|untagged] PORT...
+vlan del VID [PORT]...
+vlan stp PORT disabled|listening|learning|forwarding|blocking|default`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or change VLAN membership",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	vlan [-json] [show] [VID]...
+		print the members of all, or the given, VLANs and the STP
+		state of the ports that have one
+
+	vlan add VID [tagged|untagged] PORT...
+		add tagged, by default, or untagged members; an untagged
+		member has this VID as its PVID so it's removed as an
+		untagged member of any other VLAN
+
+	vlan del VID [PORT]...
+		remove the given, or all, members of the VLAN
+
+	vlan stp PORT STATE
+		set the bridge port's STP state or, with "default", leave
+		it to the bridge
+
+	These are wrappers of the redis settable fields kept by vland,
+		hset platina vlan.VID.tagged "PORT..."
+		hset platina vlan.VID.untagged "PORT..."
+		hset platina vlan.stp.PORT STATE
+
+SEE ALSO
+	vland`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return add(args[1:]...)
+		case "del", "delete":
+			return del(args[1:]...)
+		case "stp":
+			return stp(args[1:]...)
+		}
+	}
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		vlans := make(map[uint16]*Vlan)
+		for _, arg := range args {
+			vid, err := ParseVid(arg)
+			if err != nil {
+				return err
+			}
+			if v, found := c.Vlans[vid]; found {
+				vlans[vid] = v
+			}
+		}
+		c.Vlans = vlans
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(c, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	if s, _ := redis.Hget(redis.DefaultHash, BridgeField); len(s) > 0 {
+		fmt.Println("bridge:", s)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "VLAN\tUNTAGGED\tTAGGED")
+	for _, vid := range c.Vids() {
+		v := c.Vlans[vid]
+		fmt.Fprintf(w, "%d\t%s\t%s\n", vid,
+			strings.Join(v.Untagged, " "),
+			strings.Join(v.Tagged, " "))
+	}
+	if len(c.Stp) > 0 && len(args) == 0 {
+		var ports []string
+		for port := range c.Stp {
+			ports = append(ports, port)
+		}
+		sort.Strings(ports)
+		fmt.Fprintln(w, "\nPORT\tSTP")
+		for _, port := range ports {
+			fmt.Fprintf(w, "%s\t%s\n", port, c.Stp[port])
+		}
+	}
+	return w.Flush()
+}
+
+// Get the configuration published by vland.
+func Get() (*Config, error) {
+	fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(fields)
+}
+
+func add(args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("VID PORT: missing")
+	}
+	vid, err := ParseVid(args[0])
+	if err != nil {
+		return err
+	}
+	untagged := false
+	switch args[1] {
+	case "tagged":
+		args = args[1:]
+	case "untagged":
+		untagged = true
+		args = args[1:]
+	}
+	ports := args[1:]
+	if len(ports) == 0 {
+		return fmt.Errorf("PORT: missing")
+	}
+	c, err := Get()
+	if err != nil {
+		return err
+	}
+	if untagged {
+		// first move the PVID of these ports from other VLANs
+		for _, other := range c.Vids() {
+			v := c.Vlans[other]
+			if other == vid || !has(v.Untagged, ports) {
+				continue
+			}
+			field := fmt.Sprint(Prefix, other, ".untagged")
+			if err = hset(field, without(v.Untagged, ports)); err != nil {
+				return err
+			}
+		}
+	}
+	v, found := c.Vlans[vid]
+	if !found {
+		v = new(Vlan)
+	}
+	tagged := without(v.Tagged, ports)
+	if untagged {
+		v.Untagged = append(without(v.Untagged, ports), ports...)
+	} else {
+		tagged = append(tagged, ports...)
+		v.Untagged = without(v.Untagged, ports)
+	}
+	if err = hset(fmt.Sprint(Prefix, vid, ".untagged"), v.Untagged); err != nil {
+		return err
+	}
+	return hset(fmt.Sprint(Prefix, vid, ".tagged"), tagged)
+}
+
+func del(args ...string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("VID: missing")
+	}
+	vid, err := ParseVid(args[0])
+	if err != nil {
+		return err
+	}
+	ports := args[1:]
+	v := new(Vlan)
+	if len(ports) > 0 {
+		c, err := Get()
+		if err != nil {
+			return err
+		}
+		if x, found := c.Vlans[vid]; found {
+			v.Tagged = without(x.Tagged, ports)
+			v.Untagged = without(x.Untagged, ports)
+		}
+	}
+	if err = hset(fmt.Sprint(Prefix, vid, ".untagged"), v.Untagged); err != nil {
+		return err
+	}
+	return hset(fmt.Sprint(Prefix, vid, ".tagged"), v.Tagged)
+}
+
+func stp(args ...string) error {
+	switch len(args) {
+	case 0:
+		return fmt.Errorf("PORT STATE: missing")
+	case 1:
+		return fmt.Errorf("STATE: missing")
+	case 2:
+	default:
+		return fmt.Errorf("%v: unexpected", args[2:])
+	}
+	state := args[1]
+	if state == "default" {
+		state = ""
+	} else if _, found := rtnl.BrStateByName[state]; !found {
+		return fmt.Errorf("%s: invalid state", state)
+	}
+	_, err := redis.Hset(redis.DefaultHash, Prefix+"stp."+args[0], state)
+	return err
+}
+
+func hset(field string, ports []string) error {
+	_, err := redis.Hset(redis.DefaultHash, field, strings.Join(ports, " "))
+	return err
+}
+
+// has returns true if the list has any of the ports.
+func has(list, ports []string) bool {
+	for _, port := range ports {
+		for _, s := range list {
+			if s == port {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// without returns the list less the ports.
+func without(list, ports []string) []string {
+	var ret []string
+	for _, s := range list {
+		if !has([]string{s}, ports) {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(args) <= 1 {
+		return complete.Prefixed(last, "-json", "show", "add", "del",
+			"stp")
+	}
+	switch args[0] {
+	case "add", "del", "delete":
+		switch {
+		case len(args) == 2:
+			return complete.Vid(last)
+		case len(args) == 3 && args[0] == "add":
+			return append(complete.Prefixed(last, "tagged",
+				"untagged"), complete.IfName(last)...)
+		}
+		return complete.IfName(last)
+	case "stp":
+		switch len(args) {
+		case 2:
+			return complete.IfName(last)
+		case 3:
+			states := []string{"default"}
+			for state := range rtnl.BrStateByName {
+				states = append(states, state)
+			}
+			return complete.Prefixed(last, states...)
+		}
+		return nil
+	}
+	return complete.Vid(last)
+}
@@ -0,0 +1,534 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package vland provides a daemon that keeps the redis settable vlan.*
+// fields and reconciles the VLAN membership and STP state of the bridge
+// ports with these.
+package vland
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"path/filepath"
+	"strings"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/vlan"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct {
+	// Bridge of the VLAN ports, default: br0; this may be overridden
+	// by vland.bridge of the machine configuration.
+	Bridge string
+
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	cfg      *vlan.Config
+	hset     chan hset
+	// link state of the last reconcile by name
+	links map[string]linkState
+}
+
+// Vland is the RPC handler of the redis settable vlan.* fields.
+type Vland struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+type linkState struct {
+	index, master int32
+	up            bool
+}
+
+// only these flags are reconciled
+const vlanFlags = rtnl.BRIDGE_VLAN_INFO_PVID | rtnl.BRIDGE_VLAN_INFO_UNTAGGED
+
+func (*Command) String() string { return "vland" }
+
+func (*Command) Usage() string { return "vland" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "VLAN bridge daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Keep the redis settable fields,
+		vlan.VID.tagged: PORT...
+		vlan.VID.untagged: PORT...
+		vlan.stp.PORT: disabled|listening|learning|forwarding|blocking
+	in /etc/goes/persist/vlan then reconcile the VLAN filtering bridge
+	with these on start, on each change, and whenever a member port is
+	added, joins another master, or comes up.
+
+	vland creates the bridge if it doesn't exist, enables its VLAN
+	filtering, and adds the member ports. The untagged member of a VLAN
+	also has it as its PVID. Each port of the bridge has exactly the
+	configured VLANs, so a port without any forwards nothing.
+
+	Other than disabled, a port's STP state only holds if the bridge
+	runs user space STP; otherwise the kernel returns it to forwarding.
+
+	A field is kept even if it can't be applied now, e.g. because the
+	port doesn't exist; such errors are returned to hset and logged.
+
+	vland also publishes the bridge name as vlan.bridge.
+
+FILES
+	/etc/goes/persist/vlan
+	/etc/goes/machine.yaml
+		vland:
+		  bridge: br0
+
+SEE ALSO
+	vlan`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	c.Bridge = machine.Default().String("vland.bridge", c.Bridge)
+	if len(c.Bridge) == 0 {
+		c.Bridge = "br0"
+	}
+	if c.settings, err = persist.Load("vlan"); err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	for _, field := range c.settings.Fields() {
+		fields[field] = c.settings.Get(field)
+	}
+	if c.cfg, err = vlan.Parse(fields); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", vlan.Prefix)
+	c.pub.Print(vlan.BridgeField, ": ", c.Bridge)
+	for field, value := range fields {
+		c.pub.Print(field, ": ", value)
+	}
+
+	c.hset = make(chan hset)
+	rpc.Register(&Vland{c.hset})
+	srvr, err := atsock.NewRpcServer("vland")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", vlan.Prefix)
+	if err = redis.Assign(key, "vland", "Vland"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	// subscribe before reconciling to not miss intervening changes
+	sub, err := nl.NewSock(nl.NETLINK_ROUTE, 16, rtnl.RTNLGRP_LINK.Bit())
+	if err != nil {
+		return err
+	}
+	defer sub.Close()
+
+	c.links = make(map[string]linkState)
+	c.reconcile()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+		case b, opened := <-sub.RxCh:
+			if !opened {
+				return sub.Err
+			}
+			changed := false
+			for len(b) >= nl.SizeofHdr {
+				var msg []byte
+				if msg, b, err = nl.Pop(b); err != nil {
+					log.Print("daemon", "err", err)
+					break
+				}
+				if c.changed(msg) {
+					changed = true
+				}
+			}
+			if changed {
+				c.reconcile()
+			}
+		}
+	}
+}
+
+func (vland *Vland) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	vland.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+// set validates the field with the others before saving, publishing, and
+// applying it.
+func (c *Command) set(field, value string) error {
+	value = strings.Join(strings.Fields(value), " ")
+	if field == vlan.BridgeField {
+		return fmt.Errorf("%s: read only", field)
+	}
+	fields := map[string]string{field: value}
+	for _, x := range c.settings.Fields() {
+		if x != field {
+			fields[x] = c.settings.Get(x)
+		}
+	}
+	cfg, err := vlan.Parse(fields)
+	if err != nil {
+		return err
+	}
+	if err = c.settings.Set(field, value); err != nil {
+		return err
+	}
+	c.cfg = cfg
+	if len(value) > 0 {
+		c.pub.Print(field, ": ", value)
+	} else {
+		c.pub.Print("delete: ", field)
+	}
+	return c.reconcile()
+}
+
+// changed returns true if the RTM_NEWLINK or RTM_DELLINK message is that of
+// the bridge or a configured port with a new index, master, or carrier.
+func (c *Command) changed(msg []byte) bool {
+	var ifla rtnl.Ifla
+	h := nl.HdrPtr(msg)
+	if h == nil || (h.Type != rtnl.RTM_NEWLINK && h.Type != rtnl.RTM_DELLINK) {
+		return false
+	}
+	ifinfo := rtnl.IfInfoMsgPtr(msg)
+	if ifinfo == nil || ifinfo.Family == rtnl.AF_BRIDGE {
+		return false
+	}
+	ifla.Write(msg)
+	if len(ifla[rtnl.IFLA_IFNAME]) == 0 {
+		return false
+	}
+	name := nl.Kstring(ifla[rtnl.IFLA_IFNAME])
+	_, isPort := c.cfg.Ports()[name]
+	if _, hasStp := c.cfg.Stp[name]; name != c.Bridge && !isPort && !hasStp {
+		return false
+	}
+	if h.Type == rtnl.RTM_DELLINK {
+		delete(c.links, name)
+		return name == c.Bridge
+	}
+	var ls linkState
+	ls.index = ifinfo.Index
+	if len(ifla[rtnl.IFLA_MASTER]) > 0 {
+		ls.master = nl.Int32(ifla[rtnl.IFLA_MASTER])
+	}
+	ls.up = ifinfo.Flags&rtnl.IFF_LOWER_UP != 0
+	return c.links[name] != ls
+}
+
+// reconcile the bridge with the configuration, logging then returning the
+// first error.
+func (c *Command) reconcile() error {
+	var first error
+	fail := func(err error) {
+		log.Print("daemon", "err", err)
+		if first == nil {
+			first = err
+		}
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		fail(err)
+		return first
+	}
+	defer sock.Close()
+	sr := nl.NewSockReceiver(sock)
+
+	br, err := c.bridge(sr)
+	if err != nil {
+		fail(fmt.Errorf("%s: %v", c.Bridge, err))
+		return first
+	}
+	ports := c.cfg.Ports()
+	for name := range ports {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			// reconciled when added
+			continue
+		}
+		if err = c.enslave(sr, name, int32(ifi.Index), br); err != nil {
+			fail(fmt.Errorf("%s: %v", name, err))
+		}
+	}
+	current, err := c.dump(sr, br)
+	if err != nil {
+		fail(err)
+		return first
+	}
+	for name, port := range current {
+		want := ports[name]
+		// first add or change then delete to not lose the PVID
+		for vid, flags := range want {
+			if have, found := port.vlans[vid]; found &&
+				have&vlanFlags == flags {
+				continue
+			}
+			err = c.vlan(sr, rtnl.RTM_SETLINK, port.index, vid, flags)
+			if err != nil {
+				fail(fmt.Errorf("%s: vlan %d: %v", name, vid, err))
+			}
+		}
+		for vid := range port.vlans {
+			if _, found := want[vid]; found {
+				continue
+			}
+			err = c.vlan(sr, rtnl.RTM_DELLINK, port.index, vid, 0)
+			if err != nil {
+				fail(fmt.Errorf("%s: vlan %d: %v", name, vid, err))
+			}
+		}
+		if s, found := c.cfg.Stp[name]; found {
+			state := rtnl.BrStateByName[s]
+			if state != port.state {
+				if err = c.stp(sr, port.index, state); err != nil {
+					fail(fmt.Errorf("%s: stp %s: %v", name, s, err))
+				}
+			}
+		}
+	}
+	c.links = make(map[string]linkState)
+	for name := range ports {
+		c.links[name] = c.linkState(name)
+	}
+	for name := range c.cfg.Stp {
+		c.links[name] = c.linkState(name)
+	}
+	c.links[c.Bridge] = c.linkState(c.Bridge)
+	return first
+}
+
+func (c *Command) linkState(name string) linkState {
+	var ls linkState
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return ls
+	}
+	ls.index = int32(ifi.Index)
+	ls.up = ifi.Flags&net.FlagUp != 0 && c.carrier(name)
+	if s, err := filepath.EvalSymlinks(filepath.Join("/sys/class/net",
+		name, "master")); err == nil {
+		if m, err := net.InterfaceByName(filepath.Base(s)); err == nil {
+			ls.master = int32(m.Index)
+		}
+	}
+	return ls
+}
+
+func (*Command) carrier(name string) bool {
+	b, err := ioutil.ReadFile(filepath.Join("/sys/class/net", name,
+		"carrier"))
+	return err == nil && strings.TrimSpace(string(b)) == "1"
+}
+
+// bridge returns the index of the VLAN filtering bridge, first creating it
+// if it doesn't exist.
+func (c *Command) bridge(sr *nl.SockReceiver) (int32, error) {
+	ifi, err := net.InterfaceByName(c.Bridge)
+	if err == nil {
+		fn := filepath.Join("/sys/class/net", c.Bridge,
+			"bridge/vlan_filtering")
+		b, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return 0, fmt.Errorf("not a bridge")
+		}
+		if strings.TrimSpace(string(b)) != "1" {
+			err = c.request(sr, rtnl.RTM_NEWLINK, 0, rtnl.IfInfoMsg{
+				Family: rtnl.AF_UNSPEC,
+				Index:  int32(ifi.Index),
+			}, c.filtering())
+			if err != nil {
+				return 0, err
+			}
+			log.Print("daemon", "info", c.Bridge,
+				": enabled vlan filtering")
+		}
+		return int32(ifi.Index), nil
+	}
+	err = c.request(sr, rtnl.RTM_NEWLINK,
+		nl.NLM_F_CREATE|nl.NLM_F_EXCL,
+		rtnl.IfInfoMsg{
+			Family: rtnl.AF_UNSPEC,
+			Flags:  rtnl.IFF_UP,
+			Change: rtnl.IFF_UP,
+		},
+		nl.Attr{Type: rtnl.IFLA_IFNAME,
+			Value: nl.KstringAttr(c.Bridge)},
+		c.filtering())
+	if err != nil {
+		return 0, err
+	}
+	if ifi, err = net.InterfaceByName(c.Bridge); err != nil {
+		return 0, err
+	}
+	log.Print("daemon", "info", c.Bridge, ": created")
+	return int32(ifi.Index), nil
+}
+
+func (*Command) filtering() nl.Attr {
+	return nl.Attr{Type: rtnl.IFLA_LINKINFO,
+		Value: nl.Attrs{
+			nl.Attr{Type: rtnl.IFLA_INFO_KIND,
+				Value: nl.KstringAttr("bridge")},
+			nl.Attr{Type: rtnl.IFLA_INFO_DATA,
+				Value: nl.Attr{Type: rtnl.IFLA_BR_VLAN_FILTERING,
+					Value: nl.Uint8Attr(1)}},
+		},
+	}
+}
+
+func (c *Command) enslave(sr *nl.SockReceiver, name string, index,
+	br int32) error {
+	if c.linkState(name).master == br {
+		return nil
+	}
+	return c.request(sr, rtnl.RTM_SETLINK, 0, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+		Index:  index,
+	}, nl.Attr{Type: rtnl.IFLA_MASTER, Value: nl.Int32Attr(br)})
+}
+
+func (c *Command) vlan(sr *nl.SockReceiver, t uint16, index int32,
+	vid, flags uint16) error {
+	return c.request(sr, t, 0, rtnl.IfInfoMsg{
+		Family: rtnl.AF_BRIDGE,
+		Index:  index,
+	}, nl.Attr{Type: rtnl.IFLA_AF_SPEC,
+		Value: nl.Attr{Type: rtnl.IFLA_BRIDGE_VLAN_INFO,
+			Value: rtnl.BridgeVlanInfo{Flags: flags, Vid: vid}}})
+}
+
+func (c *Command) stp(sr *nl.SockReceiver, index int32, state uint8) error {
+	// a flat, rather than nested, IFLA_PROTINFO is the port state
+	return c.request(sr, rtnl.RTM_SETLINK, 0, rtnl.IfInfoMsg{
+		Family: rtnl.AF_BRIDGE,
+		Index:  index,
+	}, nl.Attr{Type: rtnl.IFLA_PROTINFO, Value: nl.Uint8Attr(state)})
+}
+
+func (*Command) request(sr *nl.SockReceiver, t, flags uint16,
+	msg rtnl.IfInfoMsg, attrs ...nl.Attr) error {
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  t,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK | flags,
+	}, msg, attrs...)
+	if err != nil {
+		return err
+	}
+	return sr.UntilDone(req, nl.DoNothing)
+}
+
+type bridgePort struct {
+	index int32
+	state uint8
+	vlans map[uint16]uint16
+}
+
+// dump the ports of the bridge by name with their VLANs and STP state.
+func (c *Command) dump(sr *nl.SockReceiver, br int32) (map[string]*bridgePort, error) {
+	ports := make(map[string]*bridgePort)
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_GETLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_BRIDGE,
+	}, nl.Attr{Type: rtnl.IFLA_EXT_MASK,
+		Value: rtnl.RTEXT_FILTER_BRVLAN})
+	if err != nil {
+		return nil, err
+	}
+	err = sr.UntilDone(req, func(b []byte) {
+		var ifla rtnl.Ifla
+		if nl.HdrPtr(b).Type != rtnl.RTM_NEWLINK {
+			return
+		}
+		msg := rtnl.IfInfoMsgPtr(b)
+		if msg == nil || msg.Index == br {
+			return
+		}
+		ifla.Write(b)
+		if len(ifla[rtnl.IFLA_MASTER]) == 0 ||
+			nl.Int32(ifla[rtnl.IFLA_MASTER]) != br ||
+			len(ifla[rtnl.IFLA_IFNAME]) == 0 {
+			return
+		}
+		port := &bridgePort{
+			index: msg.Index,
+			vlans: make(map[uint16]uint16),
+		}
+		ports[nl.Kstring(ifla[rtnl.IFLA_IFNAME])] = port
+		// Ifla drops these if flagged as nested
+		i := nl.NLMSG.Align(nl.SizeofHdr + rtnl.SizeofIfInfoMsg)
+		nl.ForEachAttr(b[i:], func(t uint16, v []byte) {
+			switch t &^ nlaFlags {
+			case rtnl.IFLA_PROTINFO:
+				nl.ForEachAttr(v, func(t uint16, v []byte) {
+					if t&^nlaFlags == rtnl.IFLA_BRPORT_STATE &&
+						len(v) > 0 {
+						port.state = v[0]
+					}
+				})
+			case rtnl.IFLA_AF_SPEC:
+				nl.ForEachAttr(v, func(t uint16, v []byte) {
+					if t&^nlaFlags != rtnl.IFLA_BRIDGE_VLAN_INFO {
+						return
+					}
+					info := rtnl.BridgeVlanInfoPtr(v)
+					if info != nil {
+						port.vlans[info.Vid] = info.Flags
+					}
+				})
+			}
+		})
+	})
+	return ports, err
+}
+
+// NLA_F_NESTED and NLA_F_NET_BYTEORDER
+const nlaFlags = 3 << 14
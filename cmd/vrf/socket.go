@@ -0,0 +1,54 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package vrf
+
+import (
+	"context"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Control returns a net.Dialer or net.ListenConfig Control function that
+// binds the socket to the named VRF, or any, device before its connect or
+// bind so that it uses the VRF's table. It returns nil with an empty name.
+func Control(name string) func(network, address string,
+	c syscall.RawConn) error {
+	if len(name) == 0 {
+		return nil
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		cerr := c.Control(func(fd uintptr) {
+			err = syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET,
+				syscall.SO_BINDTODEVICE, name)
+		})
+		if cerr != nil {
+			return cerr
+		}
+		if err != nil {
+			return &net.OpError{
+				Op:  "SO_BINDTODEVICE",
+				Net: network,
+				Err: err,
+			}
+		}
+		return nil
+	}
+}
+
+// Dialer of connections within the named VRF.
+func Dialer(name string, timeout time.Duration) *net.Dialer {
+	return &net.Dialer{
+		Timeout: timeout,
+		Control: Control(name),
+	}
+}
+
+// ListenPacket is net.ListenPacket within the named VRF.
+func ListenPacket(name, network, address string) (net.PacketConn, error) {
+	lc := net.ListenConfig{Control: Control(name)}
+	return lc.ListenPacket(context.Background(), network, address)
+}
@@ -0,0 +1,432 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package vrf provides a command to show, add, and bind interfaces to
+// Virtual Routing and Forwarding devices, along with the socket options of
+// commands that isolate their traffic within a VRF, e.g. that of the
+// management port.
+package vrf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+	"github.com/platinasystems/goes/lang"
+)
+
+type Command struct{}
+
+// Vrf device of the current network namespace
+type Vrf struct {
+	Name    string   `json:"name"`
+	Table   uint32   `json:"table"`
+	Up      bool     `json:"up"`
+	Members []string `json:"members"`
+
+	index int32
+}
+
+func (Command) String() string { return "vrf" }
+
+func (Command) Usage() string {
+	return `vrf [-json] [show] [NAME]...
+vrf add NAME table TABLE
+vrf del NAME
+vrf bind NAME IFNAME...
+vrf unbind IFNAME...`
+}
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or change Virtual Routing and Forwarding devices",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	vrf [-json] [show] [NAME]...
+		print the table, state, and enslaved interfaces of all, or
+		the given, VRF devices
+
+	vrf add NAME table TABLE
+		create and bring up a VRF device of the routing table, 1
+		through 4294967295 less those of local, main, and default
+
+	vrf del NAME
+		remove the VRF device, releasing its interfaces to the
+		default table
+
+	vrf bind NAME IFNAME...
+		enslave the interfaces to the VRF so that their addresses
+		and connected routes move to its table
+
+	vrf unbind IFNAME...
+		release the interfaces to the default table
+
+	The ping, wget, and clock commands have a -vrf NAME option, and
+	clockd the clock.vrf configuration, to bind their sockets to the VRF
+	device with SO_BINDTODEVICE so that these use its table instead of
+	the default. Other commands may be run with the table of another
+	network namespace with "ip netns exec".
+
+	nld publishes each VRF's table and routes as,
+		vrf.NAME.table: TABLE
+		vrf.NAME.inet.route: PREFIX[@GATEWAY]...
+		vrf.NAME.inet6.route: PREFIX[@GATEWAY]...
+	and IFNAME.vrf: NAME of its interfaces.
+
+EXAMPLES
+	vrf add mgmt table 10
+	vrf bind mgmt eth0
+	ip route add default via 192.168.0.1 table 10
+	ping -vrf mgmt 192.168.0.1
+
+SEE ALSO
+	ip link add type vrf, ip netns exec, nld`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add":
+			return add(args[1:]...)
+		case "del", "delete":
+			return del(args[1:]...)
+		case "bind":
+			return bind(args[1:]...)
+		case "unbind":
+			return unbind(args[1:]...)
+		}
+	}
+	flag, args := flags.New(args, "-json")
+	if len(args) > 0 && args[0] == "show" {
+		args = args[1:]
+	}
+	vrfs, err := Get()
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		var selected []*Vrf
+		for _, name := range args {
+			v := find(vrfs, name)
+			if v == nil {
+				return fmt.Errorf("%s: not found", name)
+			}
+			selected = append(selected, v)
+		}
+		vrfs = selected
+	}
+	if flag.ByName["-json"] {
+		b, err := json.MarshalIndent(vrfs, "", "\t")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "VRF\tTABLE\tSTATE\tMEMBERS")
+	for _, v := range vrfs {
+		state := "down"
+		if v.Up {
+			state = "up"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", v.Name, v.Table, state,
+			strings.Join(v.Members, " "))
+	}
+	return w.Flush()
+}
+
+// Get the VRF devices, sorted by name, and their enslaved interfaces.
+func Get() ([]*Vrf, error) {
+	sock, err := nl.NewSock()
+	if err != nil {
+		return nil, err
+	}
+	defer sock.Close()
+	return get(nl.NewSockReceiver(sock))
+}
+
+func get(sr *nl.SockReceiver) ([]*Vrf, error) {
+	var vrfs []*Vrf
+	byIndex := make(map[int32]*Vrf)
+	masters := make(map[string]int32)
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_GETLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_DUMP,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = sr.UntilDone(req, func(b []byte) {
+		var ifla rtnl.Ifla
+		msg := rtnl.IfInfoMsgPtr(b)
+		if nl.HdrPtr(b).Type != rtnl.RTM_NEWLINK || msg == nil {
+			return
+		}
+		ifla.Write(b)
+		name := nl.Kstring(ifla[rtnl.IFLA_IFNAME])
+		if val := ifla[rtnl.IFLA_MASTER]; len(val) > 0 {
+			masters[name] = nl.Int32(val)
+		}
+		table, ok := Table(ifla[rtnl.IFLA_LINKINFO])
+		if !ok {
+			return
+		}
+		v := &Vrf{
+			Name:    name,
+			Table:   table,
+			Up:      msg.Flags&rtnl.IFF_UP == rtnl.IFF_UP,
+			Members: []string{},
+			index:   msg.Index,
+		}
+		vrfs = append(vrfs, v)
+		byIndex[v.index] = v
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name, index := range masters {
+		if v, found := byIndex[index]; found {
+			v.Members = append(v.Members, name)
+		}
+	}
+	for _, v := range vrfs {
+		sort.Strings(v.Members)
+	}
+	sort.Slice(vrfs, func(i, j int) bool {
+		return vrfs[i].Name < vrfs[j].Name
+	})
+	return vrfs, nil
+}
+
+// Table returns the routing table of an IFLA_LINKINFO of kind "vrf".
+func Table(linkinfo []byte) (table uint32, ok bool) {
+	nl.ForEachAttr(linkinfo, func(t uint16, v []byte) {
+		switch t {
+		case rtnl.IFLA_INFO_KIND:
+			ok = nl.Kstring(v) == "vrf"
+		case rtnl.IFLA_INFO_DATA:
+			nl.ForEachAttr(v, func(t uint16, v []byte) {
+				if t == rtnl.IFLA_VRF_TABLE && len(v) >= 4 {
+					table = nl.Uint32(v)
+				}
+			})
+		}
+	})
+	return
+}
+
+func find(vrfs []*Vrf, name string) *Vrf {
+	for _, v := range vrfs {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+func add(args ...string) error {
+	if len(args) < 3 || args[1] != "table" {
+		return fmt.Errorf("NAME table TABLE: missing")
+	}
+	if len(args) > 3 {
+		return fmt.Errorf("%v: unexpected", args[3:])
+	}
+	name := args[0]
+	var table uint32
+	if _, err := fmt.Sscan(args[2], &table); err != nil ||
+		table == rtnl.RT_TABLE_UNSPEC ||
+		table == rtnl.RT_TABLE_DEFAULT ||
+		table == rtnl.RT_TABLE_MAIN ||
+		table == rtnl.RT_TABLE_LOCAL {
+		return fmt.Errorf("%s: invalid table", args[2])
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	sr := nl.NewSockReceiver(sock)
+	vrfs, err := get(sr)
+	if err != nil {
+		return err
+	}
+	for _, v := range vrfs {
+		if v.Table == table {
+			return fmt.Errorf("table %d: in use by %s", table, v.Name)
+		}
+	}
+	req, err := nl.NewMessage(nl.Hdr{
+		Type: rtnl.RTM_NEWLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK |
+			nl.NLM_F_CREATE | nl.NLM_F_EXCL,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+		Flags:  rtnl.IFF_UP,
+		Change: rtnl.IFF_UP,
+	}, nl.Attr{Type: rtnl.IFLA_IFNAME,
+		Value: nl.KstringAttr(name),
+	}, nl.Attr{Type: rtnl.IFLA_LINKINFO,
+		Value: nl.Attrs{
+			nl.Attr{Type: rtnl.IFLA_INFO_KIND,
+				Value: nl.KstringAttr("vrf")},
+			nl.Attr{Type: rtnl.IFLA_INFO_DATA,
+				Value: nl.Attr{Type: rtnl.IFLA_VRF_TABLE,
+					Value: nl.Uint32Attr(table)}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if err = sr.UntilDone(req, nl.DoNothing); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+	return nil
+}
+
+func del(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("NAME: missing")
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	sr := nl.NewSockReceiver(sock)
+	vrfs, err := get(sr)
+	if err != nil {
+		return err
+	}
+	v := find(vrfs, args[0])
+	if v == nil {
+		return fmt.Errorf("%s: not found", args[0])
+	}
+	req, err := nl.NewMessage(nl.Hdr{
+		Type:  rtnl.RTM_DELLINK,
+		Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK,
+	}, rtnl.IfInfoMsg{
+		Family: rtnl.AF_UNSPEC,
+		Index:  v.index,
+	})
+	if err != nil {
+		return err
+	}
+	return sr.UntilDone(req, nl.DoNothing)
+}
+
+func bind(args ...string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("NAME IFNAME: missing")
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	sr := nl.NewSockReceiver(sock)
+	vrfs, err := get(sr)
+	if err != nil {
+		return err
+	}
+	v := find(vrfs, args[0])
+	if v == nil {
+		return fmt.Errorf("%s: not found", args[0])
+	}
+	return master(sr, v.index, args[1:]...)
+}
+
+func unbind(args ...string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("IFNAME: missing")
+	}
+	sock, err := nl.NewSock()
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+	return master(nl.NewSockReceiver(sock), 0, args...)
+}
+
+// master sets, or with index 0 clears, the master of the interfaces.
+func master(sr *nl.SockReceiver, index int32, names ...string) error {
+	if err := rtnl.MakeIfMaps(sr); err != nil {
+		return err
+	}
+	for _, name := range names {
+		ifindex, found := rtnl.If.IndexByName[name]
+		if !found {
+			return fmt.Errorf("%s: not found", name)
+		}
+		req, err := nl.NewMessage(nl.Hdr{
+			Type:  rtnl.RTM_SETLINK,
+			Flags: nl.NLM_F_REQUEST | nl.NLM_F_ACK,
+		}, rtnl.IfInfoMsg{
+			Family: rtnl.AF_UNSPEC,
+			Index:  ifindex,
+		}, nl.Attr{Type: rtnl.IFLA_MASTER, Value: nl.Int32Attr(index)})
+		if err != nil {
+			return err
+		}
+		if err = sr.UntilDone(req, nl.DoNothing); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(args) <= 1 {
+		return complete.Prefixed(last, "-json", "show", "add", "del",
+			"bind", "unbind")
+	}
+	switch args[0] {
+	case "add":
+		if len(args) == 3 {
+			return complete.Prefixed(last, "table")
+		}
+		return nil
+	case "bind":
+		if len(args) == 2 {
+			return CompleteName(last)
+		}
+		return complete.IfName(last)
+	case "unbind":
+		return complete.IfName(last)
+	}
+	return CompleteName(last)
+}
+
+// CompleteName returns the sorted names of the VRF devices that begin
+// with s.
+func CompleteName(s string) []string {
+	vrfs, err := Get()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, v := range vrfs {
+		names = append(names, v.Name)
+	}
+	return complete.Prefixed(s, names...)
+}
@@ -0,0 +1,32 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package vrf
+
+import (
+	"testing"
+
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+func TestTable(t *testing.T) {
+	linkinfo := []byte{
+		// IFLA_INFO_KIND "vrf"
+		8, 0, byte(rtnl.IFLA_INFO_KIND), 0, 'v', 'r', 'f', 0,
+		// IFLA_INFO_DATA, IFLA_VRF_TABLE 10
+		12, 0, byte(rtnl.IFLA_INFO_DATA), 0,
+		8, 0, byte(rtnl.IFLA_VRF_TABLE), 0, 10, 0, 0, 0,
+	}
+	if table, ok := Table(linkinfo); !ok || table != 10 {
+		t.Error("vrf:", table, ok)
+	}
+	bond := []byte{
+		// IFLA_INFO_KIND "bond"
+		9, 0, byte(rtnl.IFLA_INFO_KIND), 0, 'b', 'o', 'n', 'd', 0,
+		0, 0, 0,
+	}
+	if _, ok := Table(bond); ok {
+		t.Error("bond: ok")
+	}
+}
@@ -2,31 +2,100 @@
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
-// Watchdog is only run by an embedded machine's /init, not by
-// /usr/bin/goes start
+// Package watchdog provides a daemon that periodically writes the kernel
+// watchdog, optionally only while the machine is healthy, so that the
+// hardware reboots a hung or failing system.
 package watchdog
 
 import (
 	"fmt"
 	"io"
 	"os"
-	"strconv"
+	"syscall"
 	"time"
+	"unsafe"
 
 	"github.com/platinasystems/goes"
 	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/daemons"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
 	"github.com/platinasystems/goes/lang"
 	"github.com/platinasystems/gpio"
 )
 
+const DevWatchdog = "/dev/watchdog"
+
+var logger = log.New("watchdog")
+
+// linux/watchdog.h
+const (
+	wdiocSetTimeout = 0xc0045706
+	wdiocGetTimeout = 0x80045707
+)
+
+// Conditions that may be required to write the watchdog.
+const (
+	// redisd is responsive and ready
+	Redisd = "redisd"
+	// vnet.ready is true
+	Vnet = "vnet"
+	// no supervised daemon is crash looping
+	Daemons = "daemons"
+)
+
 type Command struct {
+	// GpioPin, if set, is toggled with each watchdog write.
 	GpioPin string
+
+	// Dev is the watchdog device, default: DevWatchdog
+	Dev string
+
+	// Timeout, if non-zero, is set on the watchdog device.
+	Timeout time.Duration
+
+	// Interval between health checks and writes, default: 30s
+	Interval time.Duration
+
+	// Grace after start before the required conditions must hold,
+	// default: 5m.
+	Grace time.Duration
+
+	// Require these conditions to write the watchdog; without any, it's
+	// written unconditionally.
+	Require []string
+
+	// A daemon with Loops or more restarts within Window is crash
+	// looping, default: 3 in 5m.
+	Loops  int
+	Window time.Duration
+
+	// Disarm the watchdog with the magic 'V' on stop; otherwise, the
+	// machine reboots after Timeout unless another writes it.
+	MagicClose bool
+
+	started time.Time
+	healthy bool
+	// restarts history by daemon name
+	history map[string][]sample
+	pub     *publisher.Publisher
+}
+
+type sample struct {
+	t        time.Time
+	restarts int
 }
 
 func (*Command) String() string { return "watchdog" }
 
-func (*Command) Usage() string { return "watchdog [OPTION]... [DEVICE]" }
+func (*Command) Usage() string {
+	return "watchdog [-T TIMEOUT] [-t INTERVAL] [-magic-close] [DEVICE]"
+}
 
 func (*Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -38,44 +107,68 @@ func (*Command) Man() lang.Alt {
 	return lang.Alt{
 		lang.EnUS: `
 DESCRIPTION
-	Periodically write to the watchdog device (default /dev/watchdog).
+	Periodically write to the watchdog device (default /dev/watchdog)
+	while the required health conditions, if any, hold:
+
+	redisd	  redisd is responsive and ready
+	vnet	  vnet.ready is true
+	daemons	  no supervised daemon is restarting in a loop
+
+	These conditions aren't required during a grace period after start.
+	Once a condition fails, watchdog stops writing and the hardware
+	reboots the machine after its timeout.
 
 OPTIONS
-	-T TIMEOUT	Reboot after TIMEOUT seconds without a watchdog write
-			(default 60)
-	-t FREQUENCY	Write frequency in seconds
-			(default 30)`,
+	-T TIMEOUT	set the device's timeout (e.g. 60 or 60s); default,
+			that of the device
+	-t INTERVAL	check and write interval (default 30s)
+	-magic-close	disarm the watchdog when stopped
+
+FILES
+	/etc/goes/machine.yaml
+		watchdog:
+		  dev: /dev/watchdog
+		  timeout: 60s
+		  interval: 10s
+		  grace: 5m
+		  require: [redisd, daemons]
+		  loops: 3
+		  window: 5m
+		  magic-close: true
+
+SEE ALSO
+	daemons`,
 	}
 }
 
 func (*Command) Kind() cmd.Kind { return cmd.Daemon }
 
 func (c *Command) Main(args ...string) error {
+	flag, args := flags.New(args, "-magic-close")
 	parm, args := parms.New(args, "-T", "-t")
-	for k, v := range map[string]string{
-		"-T": "60",
-		"-t": "30",
-	} {
-		if len(parm.ByName[k]) == 0 {
-			parm.ByName[k] = v
-		}
-	}
-
-	period, err := strconv.ParseInt(parm.ByName["-t"], 0, 0)
+	err := c.configure(machine.Default())
 	if err != nil {
-		return fmt.Errorf("%s: invalid Period: ", err)
-	} else if period <= 0 {
-		return fmt.Errorf("%v: invalid period", period)
+		return err
 	}
-	freq := time.Duration(period) * time.Second
-
-	fn := "/dev/watchdog"
-	if n := len(args); n > 0 {
-		fn = args[0]
-	} else if n > 1 {
+	if c.Timeout, err = parm.Duration("-T", c.Timeout); err != nil {
+		return err
+	}
+	if c.Interval, err = parm.Duration("-t", c.Interval); err != nil {
+		return err
+	}
+	if flag.ByName["-magic-close"] {
+		c.MagicClose = true
+	}
+	switch len(args) {
+	case 0:
+	case 1:
+		c.Dev = args[0]
+	default:
 		return fmt.Errorf("%v: unexpected", args[1:])
 	}
-	f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+	c.setDefaults()
+
+	f, err := os.OpenFile(c.Dev, os.O_WRONLY, 0)
 	if err != nil {
 		// Only an error if system has watchdog.
 		if !os.IsNotExist(err) {
@@ -83,31 +176,208 @@ func (c *Command) Main(args ...string) error {
 		}
 		return nil
 	}
-	defer f.Close()
+	defer func() {
+		if c.MagicClose {
+			f.Write([]byte{'V'})
+		}
+		f.Close()
+	}()
+
+	if c.Timeout > 0 {
+		t := int32(c.Timeout / time.Second)
+		if err = ioctl(f, wdiocSetTimeout, &t); err != nil {
+			logger.Err("set timeout", "dev", c.Dev,
+				"timeout", c.Timeout, "err", err)
+		}
+	}
+	var t int32
+	if ioctl(f, wdiocGetTimeout, &t) == nil {
+		logger.Info("timeout", "dev", c.Dev,
+			"timeout", time.Duration(t)*time.Second)
+	}
 
-	ticker := time.NewTicker(time.Duration(freq))
+	if len(c.Require) > 0 {
+		if pub, err := publisher.New(); err == nil {
+			c.pub = pub
+			defer pub.Close()
+		}
+	}
+
+	ticker := time.NewTicker(c.Interval)
 	defer ticker.Stop()
 
+	c.start(time.Now())
 	for {
+		if err = c.kick(f, time.Now(), c.check()); err != nil {
+			return err
+		}
 		select {
 		case <-goes.Stop:
 			return nil
 		case <-ticker.C:
-			if len(c.GpioPin) > 0 {
-				pin, found := gpio.FindPin(c.GpioPin)
-				t, err := pin.Value()
-				if found && err == nil {
-					pin.SetValue(!t)
-				}
-			}
+		}
+	}
+}
+
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	c.Dev = cfg.String("watchdog.dev", c.Dev)
+	if c.Timeout, err = cfg.Duration("watchdog.timeout",
+		c.Timeout); err != nil {
+		return
+	}
+	if c.Interval, err = cfg.Duration("watchdog.interval",
+		c.Interval); err != nil {
+		return
+	}
+	if c.Grace, err = cfg.Duration("watchdog.grace", c.Grace); err != nil {
+		return
+	}
+	c.Require = cfg.Strings("watchdog.require", c.Require)
+	if c.Loops, err = cfg.Int("watchdog.loops", c.Loops); err != nil {
+		return
+	}
+	if c.Window, err = cfg.Duration("watchdog.window",
+		c.Window); err != nil {
+		return
+	}
+	c.MagicClose, err = cfg.Bool("watchdog.magic-close", c.MagicClose)
+	return
+}
+
+func (c *Command) setDefaults() {
+	if len(c.Dev) == 0 {
+		c.Dev = DevWatchdog
+	}
+	if c.Interval <= 0 {
+		c.Interval = 30 * time.Second
+	}
+	if c.Grace == 0 {
+		c.Grace = 5 * time.Minute
+	}
+	if c.Loops <= 0 {
+		c.Loops = 3
+	}
+	if c.Window <= 0 {
+		c.Window = 5 * time.Minute
+	}
+}
 
-			n, err := f.Write([]byte{0})
-			if err != nil {
-				return err
+func (c *Command) start(now time.Time) {
+	c.started = now
+	c.healthy = true
+	c.history = make(map[string][]sample)
+}
+
+// kick writes the watchdog unless the health check failed after the grace
+// period; the first write after a failure resumes the countdown.
+func (c *Command) kick(w io.Writer, now time.Time, err error) error {
+	if err != nil && now.Sub(c.started) < c.Grace {
+		err = nil
+	}
+	if (err == nil) != c.healthy {
+		c.healthy = err == nil
+		if c.healthy {
+			logger.Note("kick resumed", "dev", c.Dev)
+			c.publish("true")
+		} else {
+			logger.Err("kick stopped", "dev", c.Dev, "err", err)
+			c.publish(err)
+		}
+	}
+	if !c.healthy {
+		return nil
+	}
+	if len(c.GpioPin) > 0 {
+		pin, found := gpio.FindPin(c.GpioPin)
+		t, err := pin.Value()
+		if found && err == nil {
+			pin.SetValue(!t)
+		}
+	}
+	n, err := w.Write([]byte{0})
+	if err != nil {
+		return err
+	}
+	if n != 1 {
+		return io.ErrShortWrite
+	}
+	return nil
+}
+
+func (c *Command) publish(v interface{}) {
+	if c.pub != nil {
+		c.pub.Print("watchdog.healthy: ", v)
+	}
+}
+
+// check returns the first failed condition.
+func (c *Command) check() error {
+	for _, cond := range c.Require {
+		var err error
+		switch cond {
+		case Redisd:
+			s, xerr := redis.Hget(redis.DefaultHash, "redis.ready")
+			if xerr != nil {
+				err = fmt.Errorf("redisd: %v", xerr)
+			} else if s != "true" {
+				err = fmt.Errorf("redisd: not ready")
 			}
-			if n != 1 {
-				return io.ErrShortWrite
+		case Vnet:
+			s, xerr := redis.Hget(redis.DefaultHash, "vnet.ready")
+			if xerr != nil || s != "true" {
+				err = fmt.Errorf("vnet: not ready")
 			}
+		case Daemons:
+			var info []daemons.Info
+			info, err = daemonsInfo()
+			if err == nil {
+				err = c.looping(time.Now(), info)
+			}
+		default:
+			err = fmt.Errorf("%s: unknown condition", cond)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func daemonsInfo() ([]daemons.Info, error) {
+	cl, err := atsock.NewRpcClient(daemons.Sockname())
+	if err != nil {
+		return nil, fmt.Errorf("daemons: %v", err)
+	}
+	defer cl.Close()
+	var info []daemons.Info
+	if err = cl.Call("Daemons.Info", struct{}{}, &info); err != nil {
+		return nil, fmt.Errorf("daemons: %v", err)
+	}
+	return info, nil
+}
+
+// looping returns an error naming a daemon that has restarted Loops times
+// within the Window.
+func (c *Command) looping(now time.Time, info []daemons.Info) error {
+	for _, x := range info {
+		h := append(c.history[x.Name], sample{now, x.Restarts})
+		for len(h) > 1 && now.Sub(h[0].t) > c.Window {
+			h = h[1:]
+		}
+		c.history[x.Name] = h
+		if x.Restarts-h[0].restarts >= c.Loops {
+			return fmt.Errorf("%s: %d restarts within %v", x.Name,
+				x.Restarts-h[0].restarts, c.Window)
 		}
 	}
+	return nil
+}
+
+func ioctl(f *os.File, req uintptr, t *int32) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req,
+		uintptr(unsafe.Pointer(t)))
+	if e != 0 {
+		return e
+	}
+	return nil
 }
@@ -0,0 +1,84 @@
+// Copyright © 2015-2016 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package watchdog
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/platinasystems/goes/cmd/daemons"
+)
+
+func TestKick(t *testing.T) {
+	c := &Command{Grace: time.Minute}
+	c.setDefaults()
+	t0 := time.Unix(1600000000, 0)
+	c.start(t0)
+	unhealthy := errors.New("redisd: not ready")
+	dev := new(bytes.Buffer)
+	for i, x := range []struct {
+		after time.Duration
+		err   error
+		write bool
+	}{
+		{0, nil, true},
+		{30 * time.Second, unhealthy, true}, // within grace
+		{2 * time.Minute, nil, true},
+		{3 * time.Minute, unhealthy, false}, // let it timeout
+		{4 * time.Minute, unhealthy, false},
+		{5 * time.Minute, nil, true}, // resume
+	} {
+		dev.Reset()
+		if err := c.kick(dev, t0.Add(x.after), x.err); err != nil {
+			t.Fatal(err)
+		}
+		if wrote := dev.Len() > 0; wrote != x.write {
+			t.Errorf("%d: after %v with %v, wrote %v", i, x.after,
+				x.err, wrote)
+		}
+	}
+}
+
+type shortWriter struct{}
+
+func (shortWriter) Write(b []byte) (int, error) { return 0, nil }
+
+func TestKickShortWrite(t *testing.T) {
+	c := &Command{}
+	c.setDefaults()
+	c.start(time.Now())
+	if err := c.kick(shortWriter{}, time.Now(), nil); err == nil {
+		t.Error("expected short write error")
+	}
+}
+
+func TestLooping(t *testing.T) {
+	c := &Command{Loops: 3, Window: 5 * time.Minute}
+	c.start(time.Unix(1600000000, 0))
+	t0 := c.started
+	for i, x := range []struct {
+		after    time.Duration
+		restarts int
+		looping  bool
+	}{
+		{0, 0, false},
+		{time.Minute, 1, false},
+		{2 * time.Minute, 2, false},
+		{3 * time.Minute, 3, true},
+		// the earlier restarts are outside the window
+		{9 * time.Minute, 4, false},
+		{10 * time.Minute, 4, false},
+	} {
+		err := c.looping(t0.Add(x.after), []daemons.Info{
+			{Name: "vnetd", Restarts: x.restarts},
+		})
+		if (err != nil) != x.looping {
+			t.Errorf("%d: %d restarts after %v: %v", i,
+				x.restarts, x.after, err)
+		}
+	}
+}
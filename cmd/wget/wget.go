@@ -6,8 +6,12 @@ package wget
 
 import (
 	"fmt"
+	"net/http"
+	"os"
 
 	"github.com/cavaliercoder/grab"
+	"github.com/platinasystems/goes/cmd/vrf"
+	"github.com/platinasystems/goes/external/parms"
 	"github.com/platinasystems/goes/lang"
 	"github.com/platinasystems/url"
 )
@@ -16,7 +20,7 @@ type Command struct{}
 
 func (Command) String() string { return "wget" }
 
-func (Command) Usage() string { return "wget URL..." }
+func (Command) Usage() string { return "wget [-vrf NAME] URL..." }
 
 func (Command) Apropos() lang.Alt {
 	return lang.Alt{
@@ -24,7 +28,20 @@ func (Command) Apropos() lang.Alt {
 	}
 }
 
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Download each URL to the current directory.
+
+OPTIONS
+	-vrf NAME
+		download through the routing table of the VRF device`,
+	}
+}
+
 func (Command) Main(args ...string) error {
+	parm, args := parms.New(args, "-vrf")
 	// validate command args
 	if len(args) < 1 {
 		return fmt.Errorf("URL: missing")
@@ -39,6 +56,10 @@ func (Command) Main(args ...string) error {
 		reqs = append(reqs, req)
 	}
 
+	if name := parm.ByName["-vrf"]; len(name) > 0 {
+		return fetchVrf(name, reqs)
+	}
+
 	successes, err := url.FetchReqs(0, reqs)
 	if successes == 0 && err != nil {
 		return err
@@ -47,3 +68,36 @@ func (Command) Main(args ...string) error {
 	fmt.Printf("%d files successfully downloaded.\n", successes)
 	return nil
 }
+
+// fetchVrf is url.FetchReqs, less the progress and serially, with a client
+// dialing within the VRF.
+func fetchVrf(name string, reqs []*grab.Request) error {
+	var firstErr error
+	successes := 0
+	client := grab.NewClient()
+	client.UserAgent = "Platina Go-ES"
+	client.HTTPClient.Transport = &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: vrf.Dialer(name, 0).DialContext,
+	}
+	fmt.Printf("Downloading %d files...\n", len(reqs))
+	for _, req := range reqs {
+		resp, err := client.Do(req)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error downloading %s: %v\n",
+				req.URL(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		fmt.Printf("Finished %s %d bytes\n", resp.Filename,
+			resp.BytesTransferred())
+		successes++
+	}
+	if successes == 0 && firstErr != nil {
+		return firstErr
+	}
+	fmt.Printf("%d files successfully downloaded.\n", successes)
+	return nil
+}
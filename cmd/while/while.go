@@ -62,7 +62,7 @@ func (c Command) Block(g *goes.Goes, ls shellutils.List) (*shellutils.List, func
 		*curList = append(*curList, runfun)
 		ls = *nextls
 		for len(ls.Cmds) == 0 {
-			newls, err := shellutils.Parse("while>", g.Catline)
+			newls, err := shellutils.Parse("while>", g)
 			if err != nil {
 				return nil, nil, err
 			}
@@ -112,14 +112,14 @@ func (c Command) makeBlockFunc(g *goes.Goes, whileList, doList []func(stdin io.R
 	runfun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
 		for {
 			err := runList(whileList, stdin, stdout, stderr)
-			if (err == nil && g.Status == nil) != c.IsUntil {
+			if (err == nil && g.Status() == nil) != c.IsUntil {
 				err = runList(doList, stdin, stdout, stderr)
 				if err != nil {
 					fmt.Fprintln(stderr, err)
 				}
-				if g.Status != nil {
-					if g.Status.Error() == "signal: interrupt" {
-						return g.Status
+				if status := g.Status(); status != nil {
+					if status.Error() == "signal: interrupt" {
+						return status
 					}
 				}
 			} else {
@@ -0,0 +1,118 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package ztp provides the command to show and switch the zero touch
+// provisioning of ztpd.
+package ztp
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/complete"
+	"github.com/platinasystems/goes/lang"
+)
+
+// Prefix of the fields published by ztpd,
+//
+//	ztp.state: STATE
+//	ztp.url: URL of the last attempt
+//	ztp.error: ERROR of the last attempt
+//	ztp.completed: RFC3339 URL
+//
+// and the redis settable field that it keeps,
+//
+//	ztp.disable: true|false
+const Prefix = "ztp."
+
+const (
+	DisableField   = Prefix + "disable"
+	StateField     = Prefix + "state"
+	URLField       = Prefix + "url"
+	ErrorField     = Prefix + "error"
+	CompletedField = Prefix + "completed"
+)
+
+// States of ztpd
+const (
+	StateDisabled  = "disabled"
+	StateSkipped   = "skipped"
+	StateFetching  = "fetching"
+	StateApplying  = "applying"
+	StateFailed    = "failed"
+	StateCompleted = "completed"
+)
+
+type Command struct{}
+
+func (Command) String() string { return "ztp" }
+
+func (Command) Usage() string { return "ztp [status|enable|disable]" }
+
+func (Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "show or switch zero touch provisioning",
+	}
+}
+
+func (Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	Print the status of ztpd or enable or disable its provisioning of a
+	machine on first boot, i.e. a machine without a startup
+	configuration or a record of a prior completion.
+
+	The status is one of,
+		disabled	by the machine configuration or this command
+		skipped		the machine has a startup configuration
+		fetching	trying the provisioning URLs
+		applying	the verified config or script
+		failed		and will retry
+		completed	the recorded time and URL of ztp.completed
+
+SEE ALSO
+	ztpd, dhcpcd, config apply`,
+	}
+}
+
+func (Command) Main(args ...string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	if len(args) == 0 {
+		args = []string{"status"}
+	}
+	switch args[0] {
+	case "status":
+		fields, err := redis.Hgetall(redis.DefaultHash, Prefix)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Print(name, ": ", fields[name], "\n")
+		}
+	case "enable", "disable":
+		_, err := redis.Hset(redis.DefaultHash, DisableField,
+			fmt.Sprint(args[0] == "disable"))
+		return err
+	default:
+		return fmt.Errorf("%s: unknown", args[0])
+	}
+	return nil
+}
+
+func (Command) Complete(args ...string) []string {
+	last, _ := complete.Last(args)
+	if len(args) <= 1 {
+		return complete.Prefixed(last, "status", "enable", "disable")
+	}
+	return nil
+}
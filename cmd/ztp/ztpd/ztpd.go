@@ -0,0 +1,446 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package ztpd provides a daemon that provisions a machine on first boot
+// with a verified config apply file or goes script from a URL of the
+// machine configuration, the DHCP lease, or a well-known bootd.
+package ztpd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/rpc"
+	neturl "net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/config"
+	"github.com/platinasystems/goes/cmd/ztp"
+	"github.com/platinasystems/goes/external/atsock"
+	"github.com/platinasystems/goes/external/imgverify"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/external/redis/publisher"
+	"github.com/platinasystems/goes/external/redis/rpc/args"
+	"github.com/platinasystems/goes/external/redis/rpc/reply"
+	"github.com/platinasystems/goes/internal/persist"
+	"github.com/platinasystems/goes/internal/prog"
+	"github.com/platinasystems/goes/internal/url"
+	"github.com/platinasystems/goes/lang"
+)
+
+var logger = log.New("ztpd")
+
+// DefaultBootd are the well-known URLs tried after those of the machine
+// configuration and DHCP.
+var DefaultBootd = []string{"http://bootd/goes/ztp.yaml"}
+
+type Command struct {
+	// Bootd URLs; default, DefaultBootd
+	Bootd []string
+
+	// Retry interval after a failed attempt, default: 1m
+	Retry time.Duration
+
+	url      string
+	disabled bool
+	insecure bool
+
+	pub      *publisher.Publisher
+	settings *persist.Settings
+	hset     chan hset
+}
+
+// Ztpd is the RPC handler of the redis settable ztp.disable field.
+type Ztpd struct {
+	hset chan<- hset
+}
+
+type hset struct {
+	field, value string
+	err          chan error
+}
+
+func (*Command) String() string { return "ztpd" }
+
+func (*Command) Usage() string { return "ztpd" }
+
+func (*Command) Apropos() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: "zero touch provisioning daemon",
+	}
+}
+
+func (*Command) Man() lang.Alt {
+	return lang.Alt{
+		lang.EnUS: `
+DESCRIPTION
+	On first boot, i.e. without a startup configuration or a recorded
+	completion, fetch a provisioning file from the first of these URLs
+	that has one,
+		ztp.url of the machine configuration
+		dhcpcd.IFNAME.url, DHCP option 114
+		dhcpcd.IFNAME.bootfile if a URL, DHCP option 67
+		tftp://SERVER/BOOTFILE of DHCP options 66 and 67
+		ztp.bootd of the machine configuration, default
+		http://bootd/goes/ztp.yaml
+
+	then verify its sha256 manifest, URL.sha256 or the SHA256SUMS of
+	the same directory, and that's signature by a pinned image key, as
+	does "image verify". A file named *.yaml or *.yml is applied with
+	"config apply"; others are run as goes scripts. A failed attempt
+	is retried each ztp.retry, default 1m.
+
+	On completion, ztpd records the time and URL in
+	/etc/goes/persist/ztp and won't provision again, even after a
+	reboot, until these are removed, e.g. by factory reset.
+
+	ztpd publishes its status as ztp.* fields; the redis settable
+	ztp.disable field stops, or resumes, provisioning.
+
+FILES
+	/etc/goes/machine.yaml
+		ztp:
+		  disable: false
+		  url: http://10.0.0.1/ztp/leaf1.yaml
+		  bootd: [http://bootd/goes/ztp.yaml]
+		  retry: 1m
+		  insecure: false
+	/etc/goes/persist/ztp
+
+SEE ALSO
+	ztp, dhcpcd, config apply, image keys`,
+	}
+}
+
+func (*Command) Kind() cmd.Kind { return cmd.Daemon }
+
+func (c *Command) Main(args ...string) error {
+	var err error
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	if err = c.configure(machine.Default()); err != nil {
+		return err
+	}
+	if c.settings, err = persist.Load("ztp"); err != nil {
+		return err
+	}
+	if c.pub, err = publisher.New(); err != nil {
+		return err
+	}
+	defer c.pub.Close()
+
+	c.pub.Print("delete: ", ztp.Prefix)
+	for _, field := range c.settings.Fields() {
+		c.pub.Print(field, ": ", c.settings.Get(field))
+	}
+
+	c.hset = make(chan hset)
+	rpc.Register(&Ztpd{c.hset})
+	srvr, err := atsock.NewRpcServer("ztpd")
+	if err != nil {
+		return err
+	}
+	defer srvr.Close()
+	key := fmt.Sprint(redis.DefaultHash, ":", ztp.Prefix)
+	if err = redis.Assign(key, "ztpd", "Ztpd"); err != nil {
+		return err
+	}
+	defer redis.Unassign(key)
+
+	var (
+		done    chan attempted
+		attempt = time.NewTimer(0)
+	)
+	if !c.pending() {
+		attempt.Stop()
+	}
+	defer attempt.Stop()
+	for {
+		select {
+		case <-goes.Stop:
+			return nil
+		case h := <-c.hset:
+			h.err <- c.set(h.field, h.value)
+			if done == nil && c.pending() {
+				attempt.Reset(0)
+			}
+		case <-attempt.C:
+			if !c.pending() {
+				break
+			}
+			done = make(chan attempted, 1)
+			goes.WG.Add(1)
+			go func(done chan<- attempted) {
+				defer goes.WG.Done()
+				u, err := c.provision()
+				done <- attempted{u, err}
+			}(done)
+		case a := <-done:
+			done = nil
+			if a.err == nil {
+				a.err = c.completed(a.url)
+			}
+			if a.err == nil {
+				continue
+			}
+			logger.Err("provision", "err", a.err)
+			c.pub.Print(ztp.StateField, ": ", ztp.StateFailed)
+			c.pub.Print(ztp.ErrorField, ": ", a.err)
+			if c.pending() {
+				attempt.Reset(c.Retry)
+			}
+		}
+	}
+}
+
+type attempted struct {
+	url string
+	err error
+}
+
+func (ztpd *Ztpd) Hset(args args.Hset, reply *reply.Hset) error {
+	h := hset{args.Field, string(args.Value), make(chan error, 1)}
+	ztpd.hset <- h
+	err := <-h.err
+	if err == nil {
+		*reply = 1
+	}
+	return err
+}
+
+func (c *Command) configure(cfg *machine.Config) (err error) {
+	if c.disabled, err = cfg.Bool("ztp.disable", false); err != nil {
+		return
+	}
+	if c.insecure, err = cfg.Bool("ztp.insecure", false); err != nil {
+		return
+	}
+	if c.Retry, err = cfg.Duration("ztp.retry", c.Retry); err != nil {
+		return
+	}
+	if c.Retry <= 0 {
+		c.Retry = time.Minute
+	}
+	if len(c.Bootd) == 0 {
+		c.Bootd = DefaultBootd
+	}
+	c.url = cfg.String("ztp.url", "")
+	c.Bootd = cfg.Strings("ztp.bootd", c.Bootd)
+	return
+}
+
+// set the ztp.disable field then save and publish it.
+func (c *Command) set(field, value string) error {
+	if field != ztp.DisableField {
+		return fmt.Errorf("%s: isn't settable", field)
+	}
+	value = strings.TrimSpace(value)
+	switch value {
+	case "", "false", "true":
+	default:
+		return fmt.Errorf("%s: %q: should be true or false", field,
+			value)
+	}
+	if err := c.settings.Set(field, value); err != nil {
+		return err
+	}
+	if len(value) > 0 {
+		c.pub.Print(field, ": ", value)
+	} else {
+		c.pub.Print("delete: ", field)
+	}
+	return nil
+}
+
+// pending returns true if the machine is yet to be provisioned; otherwise,
+// it publishes the reason.
+func (c *Command) pending() bool {
+	state := ""
+	if c.disabled || c.settings.Get(ztp.DisableField) == "true" {
+		state = ztp.StateDisabled
+	} else if len(c.settings.Get(ztp.CompletedField)) > 0 {
+		state = ztp.StateCompleted
+	} else if startup, err := config.Startup(); err != nil {
+		logger.Err("startup", "err", err)
+		state = ztp.StateSkipped
+	} else if len(startup) > 0 {
+		state = ztp.StateSkipped
+	}
+	if len(state) > 0 {
+		c.pub.Print(ztp.StateField, ": ", state)
+		return false
+	}
+	return true
+}
+
+// provision the machine from the first URL with a file, returning that URL.
+func (c *Command) provision() (string, error) {
+	c.pub.Print(ztp.StateField, ": ", ztp.StateFetching)
+	dhcp, err := redis.Hgetall(redis.DefaultHash, "dhcpcd.")
+	if err != nil {
+		logger.Debug("dhcpcd", "err", err)
+	}
+	dir, err := ioutil.TempDir("", "ztp")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	var fn, u string
+	var errs []string
+	for _, u = range candidates(c.url, dhcp, c.Bootd) {
+		c.pub.Print(ztp.URLField, ": ", u)
+		if fn, err = fetch(dir, u); err == nil {
+			break
+		}
+		logger.Info("fetch", "url", u, "err", err)
+		errs = append(errs, err.Error())
+	}
+	if len(fn) == 0 {
+		return "", fmt.Errorf("no provisioning file: %s",
+			strings.Join(errs, "; "))
+	}
+	fetchManifest(dir, u, fn)
+	if err = imgverify.Check(fn, c.insecure); err != nil {
+		return "", err
+	}
+	c.pub.Print(ztp.StateField, ": ", ztp.StateApplying)
+	args := []string{"source", fn}
+	if isApplyFile(fn) {
+		args = []string{"config", "apply", fn}
+	}
+	out, err := exec.Command(prog.Name(), args...).CombinedOutput()
+	for _, line := range strings.Split(strings.TrimSpace(string(out)),
+		"\n") {
+		if len(line) > 0 {
+			logger.Info(args[0], "url", u, "out", line)
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %v", u, err)
+	}
+	return u, nil
+}
+
+// completed records and publishes the provisioning by the url.
+func (c *Command) completed(u string) error {
+	completed := fmt.Sprint(time.Now().Format(time.RFC3339), " ", u)
+	if err := c.settings.Set(ztp.CompletedField, completed); err != nil {
+		return err
+	}
+	log.Audit.Note("provisioned", "url", u)
+	c.pub.Print("delete: ", ztp.ErrorField)
+	c.pub.Print(ztp.CompletedField, ": ", completed)
+	c.pub.Print(ztp.StateField, ": ", ztp.StateCompleted)
+	return nil
+}
+
+// candidates returns the provisioning URLs in the order tried: that of the
+// machine configuration, those of each interface's DHCP lease, then bootd.
+func candidates(u string, dhcp map[string]string, bootd []string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	add := func(u string) {
+		if len(u) > 0 && !seen[u] {
+			seen[u] = true
+			urls = append(urls, u)
+		}
+	}
+	add(u)
+	var ifnames []string
+	for field := range dhcp {
+		if strings.HasSuffix(field, ".url") ||
+			strings.HasSuffix(field, ".bootfile") {
+			s := strings.TrimPrefix(field, "dhcpcd.")
+			ifnames = append(ifnames, s[:strings.LastIndex(s, ".")])
+		}
+	}
+	sort.Strings(ifnames)
+	for _, ifname := range ifnames {
+		prefix := "dhcpcd." + ifname + "."
+		add(dhcp[prefix+"url"])
+		bootfile, server := dhcp[prefix+"bootfile"], dhcp[prefix+"server"]
+		switch {
+		case strings.Contains(bootfile, "://"):
+			add(bootfile)
+		case len(bootfile) > 0 && len(server) > 0:
+			add("tftp://" + server + "/" + strings.TrimPrefix(bootfile,
+				"/"))
+		}
+	}
+	for _, u := range bootd {
+		add(u)
+	}
+	return urls
+}
+
+func isApplyFile(fn string) bool {
+	ext := filepath.Ext(fn)
+	return ext == ".yaml" || ext == ".yml"
+}
+
+// fetch the url to the directory, returning the local file name.
+func fetch(dir, u string) (string, error) {
+	name := "ztp"
+	if pu, err := neturl.Parse(u); err == nil {
+		if base := path.Base(pu.Path); base != "/" && base != "." {
+			name = base
+		}
+	}
+	r, err := url.Open(u)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	fn := filepath.Join(dir, name)
+	w, err := os.Create(fn)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(w, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return fn, nil
+}
+
+// fetchManifest of the url, URL.sha256 or the directory's SHA256SUMS, and
+// its signature; those missing fail the verification that follows.
+func fetchManifest(dir, u, fn string) {
+	i := strings.LastIndex(u, "/")
+	for _, manifest := range []string{
+		u + ".sha256",
+		u[:i+1] + "SHA256SUMS",
+	} {
+		local, err := fetch(dir, manifest)
+		if err != nil {
+			continue
+		}
+		if manifest == u+".sha256" {
+			// as named by imgverify
+			os.Rename(local, fn+".sha256")
+			local = fn + ".sha256"
+		}
+		for _, ext := range []string{".minisig", ".asc", ".sig"} {
+			if sig, err := fetch(dir, manifest+ext); err == nil {
+				os.Rename(sig, local+ext)
+				return
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ztpd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCandidates(t *testing.T) {
+	got := candidates("http://10.0.0.1/leaf1.yaml", map[string]string{
+		"dhcpcd.eth1.server":   "10.0.0.2",
+		"dhcpcd.eth1.bootfile": "/ztp/leaf1.goes",
+		"dhcpcd.eth0.url":      "http://10.0.0.3/ztp.yaml",
+		"dhcpcd.eth0.bootfile": "http://10.0.0.1/leaf1.yaml",
+	}, DefaultBootd)
+	want := []string{
+		"http://10.0.0.1/leaf1.yaml",
+		"http://10.0.0.3/ztp.yaml",
+		"tftp://10.0.0.2/ztp/leaf1.goes",
+		"http://bootd/goes/ztp.yaml",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+	if !isApplyFile("/tmp/ztp1/leaf1.yaml") || isApplyFile("/tmp/ztp") {
+		t.Error("isApplyFile")
+	}
+}
@@ -11,7 +11,10 @@ import (
 	"strings"
 )
 
-type completer interface {
+// Completer is a command that completes its arguments, e.g. with the
+// names of interfaces, VLANs, and namespaces of the live system; the last
+// argument is the prefix of those returned, or empty for all.
+type Completer interface {
 	Complete(...string) []string
 }
 
@@ -20,7 +23,7 @@ func (g *Goes) Complete(args ...string) (completions []string) {
 	if n == 0 || len(args[0]) == 0 {
 		completions = g.Names()
 	} else if v, found := g.ByName[args[0]]; found {
-		if method, found := v.(completer); found {
+		if method, found := v.(Completer); found {
 			completions = method.Complete(args[1:]...)
 		} else {
 			completions, _ = filepath.Glob(args[n-1] + "*")
@@ -0,0 +1,135 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"sort"
+	"sync"
+)
+
+// Context is the variables, functions, and status of the pipelines run by
+// a goes. Its methods may be used by concurrent pipelines, e.g. those of
+// background pipe waits and here document readers; a background job should
+// instead run with a Clone so that its assignments don't change those of
+// the cli.
+type Context struct {
+	mutex     sync.Mutex
+	env       map[string]string
+	functions map[string]Function
+	status    error
+}
+
+// Context returns the execution context of the goes.
+func (g *Goes) Context() *Context {
+	g.ctxInit.Do(func() {
+		if g.ctx == nil {
+			g.ctx = new(Context)
+		}
+	})
+	return g.ctx
+}
+
+// WithContext has the goes run its pipelines with the given context, e.g.
+// that cloned for a background job; this must be before it runs any.
+func (g *Goes) WithContext(ctx *Context) {
+	g.ctxInit.Do(func() {})
+	g.ctx = ctx
+}
+
+// Clone returns a copy of the context.
+func (ctx *Context) Clone() *Context {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	clone := &Context{
+		env:       make(map[string]string, len(ctx.env)),
+		functions: make(map[string]Function, len(ctx.functions)),
+		status:    ctx.status,
+	}
+	for k, v := range ctx.env {
+		clone.env[k] = v
+	}
+	for k, f := range ctx.functions {
+		clone.functions[k] = f
+	}
+	return clone
+}
+
+// LookupEnv returns the value of the variable set by the cli and true, or
+// "" and false if it isn't.
+func (ctx *Context) LookupEnv(k string) (string, bool) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	v, found := ctx.env[k]
+	return v, found
+}
+
+// Setenv sets a variable of the cli.
+func (ctx *Context) Setenv(k, v string) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	if ctx.env == nil {
+		ctx.env = make(map[string]string)
+	}
+	ctx.env[k] = v
+}
+
+// Unsetenv removes a variable of the cli.
+func (ctx *Context) Unsetenv(k string) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	delete(ctx.env, k)
+}
+
+// Env returns the sorted NAME=VALUE of each variable of the cli.
+func (ctx *Context) Env() []string {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	list := make([]string, 0, len(ctx.env))
+	for k, v := range ctx.env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Function returns the named function and true, or false if it isn't
+// defined.
+func (ctx *Context) Function(name string) (Function, bool) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	f, found := ctx.functions[name]
+	return f, found
+}
+
+// SetFunction defines, or redefines, the function of its name.
+func (ctx *Context) SetFunction(f Function) {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	if ctx.functions == nil {
+		ctx.functions = make(map[string]Function)
+	}
+	ctx.functions[f.Name] = f
+}
+
+// Status returns the error, or nil, of the last command.
+func (ctx *Context) Status() error {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	return ctx.status
+}
+
+// SetStatus records the error, or nil, of a command and returns it.
+func (ctx *Context) SetStatus(err error) error {
+	ctx.mutex.Lock()
+	defer ctx.mutex.Unlock()
+	ctx.status = err
+	return err
+}
+
+// Status returns that of the goes context.
+func (g *Goes) Status() error { return g.Context().Status() }
+
+// SetStatus records then returns that of the goes context.
+func (g *Goes) SetStatus(err error) error { return g.Context().SetStatus(err) }
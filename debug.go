@@ -0,0 +1,198 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"net/rpc"
+	"os"
+	"path/filepath"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/goes/external/atsock"
+)
+
+// DebugSocket returns the atsock name of the daemon's Debug RPC server.
+func DebugSocket(daemon string) string { return "debug." + daemon }
+
+// DefaultDebugAddr is the listening address of the pprof and expvar server
+// started without one; port 0 is any available.
+const DefaultDebugAddr = "127.0.0.1:0"
+
+// DefaultProfileDir is that of the profiles written without a file name.
+var DefaultProfileDir = "/tmp"
+
+// Debug is the RPC handler of each daemon that starts and stops a loopback
+// HTTP server of /debug/pprof/ and /debug/vars, or writes a profile, on
+// demand, e.g.
+//
+//	goes debug vnetd start
+//	goes debug vnetd profile heap
+type Debug struct {
+	daemon string
+	mutex  sync.Mutex
+	srv    *http.Server
+	addr   string
+}
+
+// DebugProfile are the arguments of the Debug.Profile RPC.
+type DebugProfile struct {
+	// Name is cpu or a runtime/pprof profile, e.g. heap, goroutine,
+	// allocs, block, mutex, or threadcreate.
+	Name string
+	// File of the profile; the default is DefaultProfileDir/
+	// DAEMON-NAME-UNIXTIME.pprof
+	File string
+	// Seconds of the cpu profile, default 30
+	Seconds int
+}
+
+// Start the server at the given loopback address, or the default, and reply
+// with the listening address.
+func (d *Debug) Start(addr string, reply *string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.srv != nil {
+		*reply = d.addr
+		return nil
+	}
+	if len(addr) == 0 {
+		addr = DefaultDebugAddr
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host != "localhost" &&
+		(ip == nil || !ip.IsLoopback()) {
+		return fmt.Errorf("%s: not a loopback address", addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	d.srv = &http.Server{Handler: mux}
+	d.addr = ln.Addr().String()
+	go d.srv.Serve(ln)
+	*reply = d.addr
+	return nil
+}
+
+// Stop the server, if any.
+func (d *Debug) Stop(_ struct{}, reply *struct{}) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.srv == nil {
+		return nil
+	}
+	err := d.srv.Close()
+	d.srv = nil
+	d.addr = ""
+	return err
+}
+
+// Status replies with the listening address of the server or "" if stopped.
+func (d *Debug) Status(_ struct{}, reply *string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	*reply = d.addr
+	return nil
+}
+
+// Profile writes the named profile to a file and replies with its name.
+func (d *Debug) Profile(args DebugProfile, reply *string) error {
+	if len(args.Name) == 0 {
+		args.Name = "heap"
+	}
+	var p *rpprof.Profile
+	if args.Name != "cpu" {
+		if p = rpprof.Lookup(args.Name); p == nil {
+			return fmt.Errorf("%s: unknown profile", args.Name)
+		}
+	}
+	fn := args.File
+	if len(fn) == 0 {
+		fn = filepath.Join(DefaultProfileDir, fmt.Sprintf(
+			"%s-%s-%d.pprof", d.daemon, args.Name,
+			time.Now().Unix()))
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if p != nil {
+		if args.Name == "heap" {
+			runtime.GC()
+		}
+		err = p.WriteTo(f, 0)
+	} else {
+		seconds := args.Seconds
+		if seconds <= 0 {
+			seconds = 30
+		}
+		if err = rpprof.StartCPUProfile(f); err == nil {
+			select {
+			case <-Stop:
+			case <-time.After(time.Duration(seconds) *
+				time.Second):
+			}
+			rpprof.StopCPUProfile()
+		}
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(fn)
+		return err
+	}
+	*reply = fn
+	return nil
+}
+
+// debugServer serves the daemon's Debug RPC until stopped.
+func debugServer(daemon string, stop <-chan struct{}) error {
+	d := &Debug{daemon: daemon}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("Debug", d); err != nil {
+		return err
+	}
+	ln, err := atsock.Listen(DebugSocket(daemon))
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-stop
+		ln.Close()
+		d.Stop(struct{}{}, &struct{}{})
+	}()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			select {
+			case <-stop:
+				return nil
+			default:
+			}
+			return err
+		}
+		go srv.ServeConn(conn)
+	}
+}
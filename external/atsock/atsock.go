@@ -8,9 +8,12 @@ package atsock
 import (
 	"net"
 	"net/rpc"
+
+	"github.com/platinasystems/goes/external/trace"
 )
 
 type RpcServer struct {
+	name  string
 	ln    net.Listener
 	conns []net.Conn
 }
@@ -50,6 +53,9 @@ func NewRpcClient(name string) (*rpc.Client, error) {
 	if err != nil {
 		return nil, err
 	}
+	if trace.Enabled() {
+		return rpc.NewClientWithCodec(newClientCodec(name, conn)), nil
+	}
 	return rpc.NewClient(conn), nil
 }
 
@@ -59,7 +65,7 @@ func NewRpcServer(name string) (*RpcServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	srvr := &RpcServer{ln: ln}
+	srvr := &RpcServer{name: name, ln: ln}
 	go srvr.listen()
 	return srvr, err
 }
@@ -82,6 +88,10 @@ func (srvr *RpcServer) listen() {
 			break
 		}
 		srvr.conns = append(srvr.conns, conn)
-		go rpc.ServeConn(conn)
+		if trace.Enabled() {
+			go rpc.ServeCodec(newServerCodec(srvr.name, conn))
+		} else {
+			go rpc.ServeConn(conn)
+		}
 	}
 }
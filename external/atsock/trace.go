@@ -0,0 +1,165 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package atsock
+
+import (
+	"bufio"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net/rpc"
+	"sync"
+
+	"github.com/platinasystems/goes/external/trace"
+)
+
+// These are the gob codecs of net/rpc with a span of each call; the wire
+// format is unchanged so traced clients and servers work with untraced.
+
+type spans struct {
+	mutex sync.Mutex
+	m     map[uint64]*trace.Span
+}
+
+func (p *spans) start(seq uint64, span *trace.Span) {
+	if span == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.m == nil {
+		p.m = make(map[uint64]*trace.Span)
+	}
+	p.m[seq] = span
+}
+
+func (p *spans) end(seq uint64, err error) {
+	p.mutex.Lock()
+	span := p.m[seq]
+	delete(p.m, seq)
+	p.mutex.Unlock()
+	span.End(err)
+}
+
+type clientCodec struct {
+	name   string
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	spans  spans
+}
+
+func newClientCodec(name string, conn io.ReadWriteCloser) rpc.ClientCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &clientCodec{
+		name:   name,
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+	}
+}
+
+func (c *clientCodec) WriteRequest(r *rpc.Request, body interface{}) (err error) {
+	c.spans.start(r.Seq, trace.StartKind(nil, trace.Client,
+		r.ServiceMethod, "rpc.system", "goes", "rpc.socket", c.name))
+	defer func() {
+		if err != nil {
+			c.spans.end(r.Seq, err)
+		}
+	}()
+	if err = c.enc.Encode(r); err != nil {
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	err := c.dec.Decode(r)
+	if err == nil {
+		var rerr error
+		if len(r.Error) > 0 {
+			rerr = errors.New(r.Error)
+		}
+		c.spans.end(r.Seq, rerr)
+	}
+	return err
+}
+
+func (c *clientCodec) ReadResponseBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *clientCodec) Close() error {
+	return c.rwc.Close()
+}
+
+type serverCodec struct {
+	name   string
+	rwc    io.ReadWriteCloser
+	dec    *gob.Decoder
+	enc    *gob.Encoder
+	encBuf *bufio.Writer
+	closed bool
+	spans  spans
+}
+
+func newServerCodec(name string, conn io.ReadWriteCloser) rpc.ServerCodec {
+	encBuf := bufio.NewWriter(conn)
+	return &serverCodec{
+		name:   name,
+		rwc:    conn,
+		dec:    gob.NewDecoder(conn),
+		enc:    gob.NewEncoder(encBuf),
+		encBuf: encBuf,
+	}
+}
+
+func (c *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	err := c.dec.Decode(r)
+	if err == nil {
+		c.spans.start(r.Seq, trace.StartKind(nil, trace.Server,
+			r.ServiceMethod, "rpc.system", "goes",
+			"rpc.socket", c.name))
+	}
+	return err
+}
+
+func (c *serverCodec) ReadRequestBody(body interface{}) error {
+	return c.dec.Decode(body)
+}
+
+func (c *serverCodec) WriteResponse(r *rpc.Response, body interface{}) (err error) {
+	var rerr error
+	if len(r.Error) > 0 {
+		rerr = errors.New(r.Error)
+	}
+	c.spans.end(r.Seq, rerr)
+	if err = c.enc.Encode(r); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return
+	}
+	if err = c.enc.Encode(body); err != nil {
+		if c.encBuf.Flush() == nil {
+			c.Close()
+		}
+		return
+	}
+	return c.encBuf.Flush()
+}
+
+func (c *serverCodec) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	return c.rwc.Close()
+}
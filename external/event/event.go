@@ -0,0 +1,172 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package event provides the goes event bus: link flaps, daemon restarts,
+// threshold alarms, boot, etc. These are posted to eventd that numbers and
+// publishes each to the ring buffer of the redis "event" hash then
+// dispatches these to webhooks. Any program may then get the history or
+// subscribe to the published events.
+//
+//	event.Post("link.down", "warn", "eth-1-1 down", "link", "eth-1-1")
+//
+//	sub, err := event.Subscribe()
+//	...
+//	for e := range sub.C {
+//		...
+//	}
+package event
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/goes/external/log"
+)
+
+// Socket is the abstract unix datagram socket of eventd.
+const Socket = "@eventd"
+
+// Key is the published redis hash of the event ring buffer. Its fields are
+// the ring slot numbers with JSON values and "seq", that of the last event.
+const Key = "event"
+
+// Event of the bus. Severity is a log level name: emerg, alert, crit, err,
+// warn, note, info, or debug.
+type Event struct {
+	Seq      uint64            `json:"seq,omitempty"`
+	Time     time.Time         `json:"time"`
+	Kind     string            `json:"kind"`
+	Severity string            `json:"severity"`
+	Source   string            `json:"source"`
+	Msg      string            `json:"msg,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+var poster struct {
+	sync.Mutex
+	conn net.Conn
+}
+
+// New returns an event of this daemon or program with the alternating keys and
+// values of fields.
+func New(kind, severity, msg string, kv ...interface{}) *Event {
+	e := &Event{
+		Time:     time.Now(),
+		Kind:     kind,
+		Severity: severity,
+		Source:   log.Subsys(),
+		Msg:      msg,
+	}
+	if len(e.Source) == 0 {
+		e.Source = filepath.Base(os.Args[0])
+	}
+	for i := 0; i < len(kv); i += 2 {
+		if e.Fields == nil {
+			e.Fields = make(map[string]string)
+		}
+		v := "(MISSING)"
+		if i+1 < len(kv) {
+			v = fmt.Sprint(kv[i+1])
+		}
+		e.Fields[fmt.Sprint(kv[i])] = v
+	}
+	return e
+}
+
+// Post a new event to eventd; this fails if eventd isn't running.
+func Post(kind, severity, msg string, kv ...interface{}) error {
+	return New(kind, severity, msg, kv...).Post()
+}
+
+// Post the event to eventd.
+func (e *Event) Post() error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	poster.Lock()
+	defer poster.Unlock()
+	if poster.conn == nil {
+		poster.conn, err = net.Dial("unixgram", Socket)
+		if err != nil {
+			poster.conn = nil
+			return err
+		}
+	}
+	if _, err = poster.conn.Write(b); err != nil {
+		poster.conn.Close()
+		poster.conn = nil
+	}
+	return err
+}
+
+// Encode the event as a published value. The publisher separates the key,
+// field, and value with ": " so this is escaped within JSON strings.
+func (e *Event) Encode() ([]byte, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Replace(b, []byte(": "), []byte(`:\u0020`), -1), nil
+}
+
+// Decode the published event.
+func Decode(s string) (*Event, error) {
+	e := new(Event)
+	if err := json.Unmarshal([]byte(s), e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Parse the ring buffer of the published "event" hash, sorted by sequence.
+func Parse(m map[string]string) []*Event {
+	events := make([]*Event, 0, len(m))
+	for field, v := range m {
+		if _, err := strconv.ParseUint(field, 10, 64); err != nil {
+			continue
+		}
+		if e, err := Decode(v); err == nil {
+			events = append(events, e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Seq < events[j].Seq
+	})
+	return events
+}
+
+// String formats the event as a line of: TIME SEVERITY KIND SOURCE: MSG
+// followed by the sorted KEY=VALUE fields.
+func (e *Event) String() string {
+	var sb strings.Builder
+	sb.WriteString(e.Time.Format("2006-01-02T15:04:05.000Z07:00"))
+	fmt.Fprint(&sb, " ", e.Severity, " ", e.Kind, " ", e.Source, ":")
+	if len(e.Msg) > 0 {
+		sb.WriteString(" ")
+		sb.WriteString(e.Msg)
+	}
+	keys := make([]string, 0, len(e.Fields))
+	for k := range e.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		v := e.Fields[k]
+		if strings.ContainsAny(v, " \t\"") {
+			v = strconv.Quote(v)
+		}
+		fmt.Fprint(&sb, " ", k, "=", v)
+	}
+	return sb.String()
+}
@@ -0,0 +1,73 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package event
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestEncode(t *testing.T) {
+	e := New("link.down", "warn", "eth-1-1: down", "link", "eth-1-1")
+	e.Seq = 7
+	b, err := e.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte(": ")) {
+		t.Fatalf("unescaped: %s", b)
+	}
+	x, err := Decode(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.Msg != e.Msg || x.Seq != 7 || x.Fields["link"] != "eth-1-1" {
+		t.Errorf("%+v", x)
+	}
+}
+
+func TestParse(t *testing.T) {
+	m := map[string]string{"seq": "3"}
+	for slot, seq := range []string{"3", "1", "2"} {
+		m[string('0'+rune(slot))] = `{"seq":` + seq + `,"kind":"k"}`
+	}
+	events := Parse(m)
+	if len(events) != 3 {
+		t.Fatal(len(events))
+	}
+	for i, e := range events {
+		if e.Seq != uint64(i+1) {
+			t.Error(i, e.Seq)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	now := time.Now()
+	e := &Event{
+		Time:     now,
+		Kind:     "daemon.restart",
+		Severity: "warn",
+		Source:   "daemons",
+	}
+	for _, x := range []struct {
+		f     Filter
+		match bool
+	}{
+		{Filter{}, true},
+		{Filter{Kinds: []string{"link.", "daemon."}}, true},
+		{Filter{Kinds: []string{"link."}}, false},
+		{Filter{Sources: []string{"nld"}}, false},
+		{Filter{Severity: "warn"}, true},
+		{Filter{Severity: "err"}, false},
+		{Filter{Since: now.Add(-time.Minute)}, true},
+		{Filter{Since: now.Add(time.Minute)}, false},
+	} {
+		if got := x.f.Match(e); got != x.match {
+			t.Errorf("%+v: got %v", x.f, got)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package event
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+)
+
+// Filter of events; the zero Filter matches all.
+type Filter struct {
+	// Kinds are prefixes of the matching event kinds, e.g. "link."
+	Kinds []string
+	// Sources are the names of the matching daemons or programs
+	Sources []string
+	// Severity is the lowest matching severity, e.g. "warn" matches
+	// warn, err, crit, alert, and emerg.
+	Severity string
+	// Since is the earliest matching time
+	Since time.Time
+}
+
+// Match returns true if the event passes the filter.
+func (f *Filter) Match(e *Event) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Kinds) > 0 && !func() bool {
+		for _, prefix := range f.Kinds {
+			if strings.HasPrefix(e.Kind, prefix) {
+				return true
+			}
+		}
+		return false
+	}() {
+		return false
+	}
+	if len(f.Sources) > 0 && !func() bool {
+		for _, source := range f.Sources {
+			if e.Source == source {
+				return true
+			}
+		}
+		return false
+	}() {
+		return false
+	}
+	if len(f.Severity) > 0 {
+		min, err := log.ParseLevel(f.Severity)
+		if err != nil {
+			return false
+		}
+		if pri(e.Severity) > min {
+			return false
+		}
+	}
+	return f.Since.IsZero() || !e.Time.Before(f.Since)
+}
+
+// an unknown severity is treated as info
+func pri(severity string) syslog.Priority {
+	if p, err := log.ParseLevel(severity); err == nil {
+		return p
+	}
+	return syslog.LOG_INFO
+}
+
+// History returns the published events that pass the filter.
+func History(f *Filter) ([]*Event, error) {
+	m, err := redis.Hgetall(Key, "")
+	if err != nil {
+		return nil, err
+	}
+	all := Parse(m)
+	events := all[:0]
+	for _, e := range all {
+		if f.Match(e) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Subscription to published events
+type Subscription struct {
+	// C receives the events that pass the filter; it's closed on
+	// unsubscribe or loss of the redis connection.
+	C <-chan *Event
+	// Err is that of the closed connection, if any
+	Err error
+
+	psc redigo.PubSubConn
+}
+
+// Subscribe to the published events that pass the filter.
+func Subscribe(f *Filter) (*Subscription, error) {
+	psc, err := redis.Subscribe(Key)
+	if err != nil {
+		return nil, err
+	}
+	c := make(chan *Event, 64)
+	sub := &Subscription{C: c, psc: psc}
+	go func() {
+		defer close(c)
+		for {
+			switch t := psc.Receive().(type) {
+			case redigo.Message:
+				// SLOT: JSON
+				s := string(t.Data)
+				i := strings.Index(s, ": ")
+				if i < 0 {
+					continue
+				}
+				e, err := Decode(s[i+2:])
+				if err != nil || !f.Match(e) {
+					continue
+				}
+				c <- e
+			case error:
+				sub.Err = t
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// Close the subscription.
+func (sub *Subscription) Close() error {
+	return sub.psc.Close()
+}
+
+// ParseSince returns the time of a duration ago, e.g. "10m", or of an
+// RFC 3339 time.
+func ParseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return t, fmt.Errorf("%q: neither duration nor time", s)
+	}
+	return t, nil
+}
@@ -5,6 +5,8 @@
 // Package flags parses boolean options from command arguments.
 package flags
 
+import "strings"
+
 type Flags struct {
 	ByName  ByName
 	aliases map[string]string
@@ -51,6 +53,11 @@ type Aliases map[string]string
 //	flag.ByName("-b") == false
 //	flag.ByName("-c") == true
 //	args == []string{}
+//
+// A flag or alias of more than one letter may also be given as a GNU style
+// long option, e.g. "--color" for "-color", and a cluster of single
+// letters may include aliases, e.g. "-av" for "-a" and "-verbose" aliased
+// as "-v".
 func New(args []string, flags ...interface{}) (*Flags, []string) {
 	p := &Flags{
 		ByName:  make(ByName),
@@ -84,24 +91,18 @@ func (p *Flags) Parse(args []string) []string {
 	copy(a, args)
 
 	for i := 0; i < len(a); {
-		if k, found := p.aliases[a[i]]; found {
+		if k, found := p.lookup(a[i]); found {
 			p.ByName[k] = true
 			if i < len(a)-1 {
 				copy(a[i:], a[i+1:])
 			}
 			a = a[:len(a)-1]
-		} else if _, found := p.ByName[a[i]]; found {
-			p.ByName[a[i]] = true
-			if i < len(a)-1 {
-				copy(a[i:], a[i+1:])
-			}
-			a = a[:len(a)-1]
-		} else if len(a[i]) > 0 && a[i][0] == '-' {
+		} else if len(a[i]) > 1 && a[i][0] == '-' && a[i][1] != '-' {
 			var set []string
 			for _, c := range a[i][1:] {
 				s := string([]rune{'-', c})
-				if _, found := p.ByName[s]; found {
-					set = append(set, s)
+				if k, found := p.lookup(s); found {
+					set = append(set, k)
 				} else {
 					set = set[:0]
 					break
@@ -125,6 +126,26 @@ func (p *Flags) Parse(args []string) []string {
 	return a
 }
 
+// lookup returns the flag of the argument or its alias; a GNU style long
+// option, "--NAME", is also that of "-NAME" and vice versa.
+func (p *Flags) lookup(arg string) (string, bool) {
+	names := []string{arg}
+	if strings.HasPrefix(arg, "--") && len(arg) > 3 {
+		names = append(names, arg[1:])
+	} else if strings.HasPrefix(arg, "-") && len(arg) > 2 {
+		names = append(names, "-"+arg)
+	}
+	for _, name := range names {
+		if k, found := p.aliases[name]; found {
+			return k, true
+		}
+		if _, found := p.ByName[name]; found {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // Reset all flags.
 func (p *Flags) Reset() {
 	for k := range p.ByName {
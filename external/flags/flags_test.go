@@ -107,3 +107,25 @@ func TestLnVerboSe(t *testing.T) {
 		t.Error("wrong:", args)
 	}
 }
+
+func TestLongOption(t *testing.T) {
+	cmd := []string{"ln", "--verbose", "--force", "TARGET", "NAME"}
+	p, args := New(cmd, []string{"-v", "-verbose"}, "-force")
+	if !p.ByName["-v"] || !p.ByName["-force"] {
+		t.Error("wrong:", p.ByName)
+	}
+	if !reflect.DeepEqual(args, []string{"ln", "TARGET", "NAME"}) {
+		t.Error("wrong:", args)
+	}
+}
+
+func TestClusterAlias(t *testing.T) {
+	cmd := []string{"ls", "-lv", "--l"}
+	p, args := New(cmd, "-l", []string{"-verbose", "-v"})
+	if !p.ByName["-l"] || !p.ByName["-verbose"] {
+		t.Error("wrong:", p.ByName)
+	}
+	if !reflect.DeepEqual(args, []string{"ls", "--l"}) {
+		t.Error("wrong:", args)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package gpiod provides GPIO line access through the Linux character
+// device, /dev/gpiochipN, rather than the deprecated sysfs interface.
+package gpiod
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// linux/gpio.h, v1 ABI
+const (
+	getChipInfoIoctl   = 0x8044b401
+	getLineInfoIoctl   = 0xc048b402
+	getLineHandleIoctl = 0xc16cb403
+	getLineEventIoctl  = 0xc030b404
+	getLineValuesIoctl = 0xc040b408
+	setLineValuesIoctl = 0xc040b409
+
+	handlesMax = 64
+
+	requestAsIs      = 0
+	requestInput     = 1 << 0
+	requestOutput    = 1 << 1
+	requestActiveLow = 1 << 2
+
+	lineKernel    = 1 << 0
+	lineIsOut     = 1 << 1
+	lineActiveLow = 1 << 2
+)
+
+// Edge of line events
+type Edge uint32
+
+const (
+	Rising Edge = 1 << iota
+	Falling
+	Both = Rising | Falling
+)
+
+func (edge Edge) String() string {
+	switch edge {
+	case Rising:
+		return "rising"
+	case Falling:
+		return "falling"
+	case Both:
+		return "both"
+	}
+	return fmt.Sprint("edge(", uint32(edge), ")")
+}
+
+// Consumer labels the lines requested by this package.
+var Consumer = "goes"
+
+// Pin is a named chip line, e.g.
+//
+//	Pin{Chip: "gpiochip0", Line: 12, ActiveLow: true}
+type Pin struct {
+	// Chip is either the device name, e.g. "gpiochip0", its path, or its
+	// label, e.g. "pca9535".
+	Chip      string
+	Line      uint32
+	ActiveLow bool
+}
+
+// ParsePin parses "CHIP LINE [active-low]"
+func ParsePin(s string) (Pin, error) {
+	var pin Pin
+	fields := strings.Fields(s)
+	if len(fields) < 2 || len(fields) > 3 {
+		return pin, fmt.Errorf("%q isn't CHIP LINE [active-low]", s)
+	}
+	pin.Chip = fields[0]
+	if _, err := fmt.Sscan(fields[1], &pin.Line); err != nil {
+		return pin, fmt.Errorf("%s: invalid line", fields[1])
+	}
+	if len(fields) == 3 {
+		if fields[2] != "active-low" {
+			return pin, fmt.Errorf("%s: unexpected", fields[2])
+		}
+		pin.ActiveLow = true
+	}
+	return pin, nil
+}
+
+func (pin Pin) String() string {
+	s := fmt.Sprint(pin.Chip, " ", pin.Line)
+	if pin.ActiveLow {
+		s += " active-low"
+	}
+	return s
+}
+
+// Info describes the line's current use.
+type Info struct {
+	Name, Consumer string
+	Out, ActiveLow bool
+	// Kernel is true if the line is in use, e.g. another's request
+	Kernel bool
+}
+
+func (pin Pin) Info() (info Info, err error) {
+	var x struct {
+		offset, flags uint32
+		name          [32]byte
+		consumer      [32]byte
+	}
+	x.offset = pin.Line
+	err = pin.ioctl(getLineInfoIoctl, unsafe.Pointer(&x))
+	if err != nil {
+		return
+	}
+	info.Name = cstring(x.name[:])
+	info.Consumer = cstring(x.consumer[:])
+	info.Out = x.flags&lineIsOut != 0
+	info.ActiveLow = x.flags&lineActiveLow != 0
+	info.Kernel = x.flags&lineKernel != 0
+	return
+}
+
+// Value returns the logical, i.e. per ActiveLow, value of the line without
+// changing its direction.
+func (pin Pin) Value() (bool, error) {
+	fd, err := pin.handle(requestAsIs, false)
+	if err != nil {
+		return false, err
+	}
+	defer syscall.Close(fd)
+	var data [handlesMax]uint8
+	err = ioctl(fd, getLineValuesIoctl, unsafe.Pointer(&data))
+	return data[0] != 0, err
+}
+
+// SetValue drives the logical value out the line. Most drivers retain the
+// direction and value after release.
+func (pin Pin) SetValue(v bool) error {
+	fd, err := pin.handle(requestOutput, v)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+// SetInput releases the line as an input.
+func (pin Pin) SetInput() error {
+	fd, err := pin.handle(requestInput, false)
+	if err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+// Poll waits for the given Edge or the timeout, if non-zero, and returns
+// the event edge and time.
+func (pin Pin) Poll(edge Edge, timeout time.Duration) (Edge, time.Time, error) {
+	var x struct {
+		offset, handleflags, eventflags uint32
+		label                           [32]byte
+		fd                              int32
+	}
+	x.offset = pin.Line
+	x.handleflags = requestInput
+	if pin.ActiveLow {
+		x.handleflags |= requestActiveLow
+	}
+	x.eventflags = uint32(edge)
+	copy(x.label[:len(x.label)-1], Consumer)
+	if err := pin.ioctl(getLineEventIoctl, unsafe.Pointer(&x)); err != nil {
+		return 0, time.Time{}, err
+	}
+	// non-blocking for the runtime poller and read deadline
+	syscall.SetNonblock(int(x.fd), true)
+	f := os.NewFile(uintptr(x.fd), pin.String())
+	defer f.Close()
+	if timeout > 0 {
+		f.SetReadDeadline(time.Now().Add(timeout))
+	}
+	var buf [16]byte
+	if _, err := f.Read(buf[:]); err != nil {
+		return 0, time.Time{}, err
+	}
+	ns := binary.LittleEndian.Uint64(buf[:8])
+	id := binary.LittleEndian.Uint32(buf[8:12])
+	return Edge(id), time.Unix(0, int64(ns)), nil
+}
+
+func (pin Pin) handle(flags uint32, v bool) (int, error) {
+	var x struct {
+		offsets  [handlesMax]uint32
+		flags    uint32
+		defaults [handlesMax]uint8
+		label    [32]byte
+		lines    uint32
+		fd       int32
+	}
+	x.offsets[0] = pin.Line
+	x.flags = flags
+	if pin.ActiveLow {
+		x.flags |= requestActiveLow
+	}
+	if v {
+		x.defaults[0] = 1
+	}
+	copy(x.label[:len(x.label)-1], Consumer)
+	x.lines = 1
+	if err := pin.ioctl(getLineHandleIoctl, unsafe.Pointer(&x)); err != nil {
+		return -1, err
+	}
+	return int(x.fd), nil
+}
+
+func (pin Pin) ioctl(req uintptr, arg unsafe.Pointer) error {
+	fn, err := chip(pin.Chip)
+	if err != nil {
+		return err
+	}
+	fd, err := syscall.Open(fn, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("%s: %v", fn, err)
+	}
+	defer syscall.Close(fd)
+	if err = ioctl(fd, req, arg); err != nil {
+		return fmt.Errorf("%s: line %d: %v", fn, pin.Line, err)
+	}
+	return nil
+}
+
+// chip returns the device path of the named or labeled gpiochip.
+func chip(name string) (string, error) {
+	if strings.HasPrefix(name, "/") {
+		return name, nil
+	}
+	if strings.HasPrefix(name, "gpiochip") {
+		return filepath.Join("/dev", name), nil
+	}
+	devs, _ := filepath.Glob("/dev/gpiochip*")
+	for _, fn := range devs {
+		var x struct {
+			name, label [32]byte
+			lines       uint32
+		}
+		fd, err := syscall.Open(fn, syscall.O_RDONLY|syscall.O_CLOEXEC, 0)
+		if err != nil {
+			continue
+		}
+		err = ioctl(fd, getChipInfoIoctl, unsafe.Pointer(&x))
+		syscall.Close(fd)
+		if err == nil && cstring(x.label[:]) == name {
+			return fn, nil
+		}
+	}
+	return "", fmt.Errorf("%s: gpiochip not found", name)
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req,
+		uintptr(arg))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+func cstring(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,27 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package gpiod
+
+import "testing"
+
+func TestParsePin(t *testing.T) {
+	for s, want := range map[string]Pin{
+		"gpiochip0 12":         {Chip: "gpiochip0", Line: 12},
+		"pca9535 3 active-low": {Chip: "pca9535", Line: 3, ActiveLow: true},
+		"/dev/gpiochip1 0x1f":  {Chip: "/dev/gpiochip1", Line: 31},
+	} {
+		pin, err := ParsePin(s)
+		if err != nil {
+			t.Error(s, ": ", err)
+		} else if pin != want {
+			t.Errorf("%q: %+v, want %+v", s, pin, want)
+		}
+	}
+	for _, s := range []string{"", "gpiochip0", "gpiochip0 x", "a 1 high"} {
+		if _, err := ParsePin(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
@@ -0,0 +1,326 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package handoff passes the listening sockets and state of a running daemon
+// to its successor, e.g. that of a newly installed goes, through an abstract
+// socket named "@handoff.NAME". The successor serves the inherited sockets
+// so clients may connect throughout the upgrade.
+//
+//	in, err := handoff.Take("sshd")
+//	...
+//	ln, err := in.Listen("tcp", ":22")
+//	in.Close()
+//	...
+//	offer, err := handoff.NewOffer("sshd", func() (map[string]net.Listener,
+//		[]byte) {
+//		return map[string]net.Listener{":22": ln}, nil
+//	}, handoff.Terminate)
+package handoff
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/platinasystems/goes/external/atsock"
+)
+
+// Timeout of a handoff exchange.
+var Timeout = 10 * time.Second
+
+func sockname(name string) string { return "handoff." + name }
+
+type header struct {
+	Addrs []string
+	State []byte
+}
+
+// Offered is true if a daemon of the given name is ready to handoff.
+func Offered(name string) bool {
+	conn, err := atsock.Dial(sockname(name))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Inheritance is what's taken from a predecessor.
+type Inheritance struct {
+	State []byte
+	files map[string]*os.File
+	// conn to the predecessor remains open until it closes its Offer or
+	// exits
+	conn *net.UnixConn
+}
+
+// Take the listeners and state of the named predecessor. This returns a nil
+// Inheritance, and error, if there isn't a predecessor.
+func Take(name string) (*Inheritance, error) {
+	conn, err := atsock.Dial(sockname(name))
+	if err != nil {
+		return nil, nil
+	}
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		conn.Close()
+		return nil, fmt.Errorf("handoff: %T: unexpected", conn)
+	}
+	// the successor keeps the connection to Wait for the predecessor
+	kept := false
+	defer func() {
+		if !kept {
+			uc.Close()
+		}
+	}()
+	uc.SetDeadline(time.Now().Add(Timeout))
+	b := []byte{'T'}
+	if _, err = uc.Write(b); err != nil {
+		return nil, fmt.Errorf("handoff: %v", err)
+	}
+	oob := make([]byte, syscall.CmsgSpace(64*4))
+	_, oobn, _, _, err := uc.ReadMsgUnix(b, oob)
+	if err != nil {
+		return nil, fmt.Errorf("handoff: %v", err)
+	}
+	var fds []int
+	scms, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return nil, fmt.Errorf("handoff: %v", err)
+	}
+	for _, scm := range scms {
+		rights, err := syscall.ParseUnixRights(&scm)
+		if err != nil {
+			return nil, fmt.Errorf("handoff: %v", err)
+		}
+		fds = append(fds, rights...)
+	}
+	closeAll := func() {
+		for _, fd := range fds {
+			syscall.Close(fd)
+		}
+	}
+	var h header
+	if err = gob.NewDecoder(uc).Decode(&h); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("handoff: %v", err)
+	}
+	if len(h.Addrs) != len(fds) {
+		closeAll()
+		return nil, fmt.Errorf("handoff: %d of %d sockets",
+			len(fds), len(h.Addrs))
+	}
+	in := &Inheritance{
+		State: h.State,
+		files: make(map[string]*os.File),
+		conn:  uc,
+	}
+	for i, addr := range h.Addrs {
+		syscall.CloseOnExec(fds[i])
+		in.files[addr] = os.NewFile(uintptr(fds[i]), addr)
+	}
+	// acknowledge receipt so the predecessor may close its copies
+	if _, err = uc.Write(b); err != nil {
+		in.Close()
+		return nil, fmt.Errorf("handoff: %v", err)
+	}
+	uc.SetDeadline(time.Time{})
+	kept = true
+	return in, nil
+}
+
+// Wait for the predecessor to close its Offer or exit, e.g. to release
+// sockets that it didn't handoff, or Timeout.
+func (in *Inheritance) Wait() error {
+	if in == nil || in.conn == nil {
+		return nil
+	}
+	in.conn.SetReadDeadline(time.Now().Add(Timeout))
+	b := make([]byte, 1)
+	for {
+		if _, err := in.conn.Read(b); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("handoff: predecessor: %v", err)
+		}
+	}
+}
+
+// Listen returns the inherited listener of the given address or a new one
+// if there isn't such an inheritance.
+func (in *Inheritance) Listen(network, addr string) (net.Listener, error) {
+	if in != nil {
+		if f, found := in.files[addr]; found {
+			delete(in.files, addr)
+			defer f.Close()
+			return net.FileListener(f)
+		}
+	}
+	return net.Listen(network, addr)
+}
+
+// Close the inherited sockets that weren't taken by Listen.
+func (in *Inheritance) Close() error {
+	if in != nil {
+		for addr, f := range in.files {
+			f.Close()
+			delete(in.files, addr)
+		}
+		if in.conn != nil {
+			in.conn.Close()
+			in.conn = nil
+		}
+	}
+	return nil
+}
+
+// Offer serves a successor the listeners and state returned by a function.
+// Upon handoff, it closes these listeners and runs its done function. The
+// successor may Wait for the Close of the Offer, or exit of its process.
+type Offer struct {
+	mutex  sync.Mutex
+	name   string
+	ln     net.Listener
+	get    func() (map[string]net.Listener, []byte)
+	done   func()
+	closed bool
+	// taken is the connection of the successor after handoff
+	taken *net.UnixConn
+}
+
+func NewOffer(name string, get func() (map[string]net.Listener, []byte),
+	done func()) (*Offer, error) {
+	ln, err := atsock.Listen(sockname(name))
+	if err != nil {
+		return nil, err
+	}
+	o := &Offer{
+		name: name,
+		ln:   ln,
+		get:  get,
+		done: done,
+	}
+	go o.serve(ln)
+	return o, nil
+}
+
+func (o *Offer) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.closed = true
+	if o.taken != nil {
+		o.taken.Close()
+		o.taken = nil
+	}
+	return o.ln.Close()
+}
+
+func (o *Offer) serve(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		uc, ok := conn.(*net.UnixConn)
+		if !ok {
+			conn.Close()
+			continue
+		}
+		// Offered probes close without a take request
+		b := make([]byte, 1)
+		uc.SetReadDeadline(time.Now().Add(Timeout))
+		if _, err = uc.Read(b); err != nil || b[0] != 'T' {
+			uc.Close()
+			continue
+		}
+		// free the name for the successor's offer before handoff
+		ln.Close()
+		if err = o.handoff(uc); err == nil {
+			return
+		}
+		uc.Close()
+		fmt.Fprintln(os.Stderr, "handoff:", err)
+		o.mutex.Lock()
+		if !o.closed {
+			ln, err = atsock.Listen(sockname(o.name))
+			if err == nil {
+				o.ln = ln
+			}
+		}
+		o.mutex.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (o *Offer) handoff(uc *net.UnixConn) error {
+	type filer interface {
+		File() (*os.File, error)
+	}
+	uc.SetDeadline(time.Now().Add(Timeout))
+	lns, state := o.get()
+	h := header{State: state}
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	var fds []int
+	for addr, ln := range lns {
+		x, ok := ln.(filer)
+		if !ok {
+			return fmt.Errorf("%s: %T: can't handoff", addr, ln)
+		}
+		f, err := x.File()
+		if err != nil {
+			return fmt.Errorf("%s: %v", addr, err)
+		}
+		files = append(files, f)
+		fds = append(fds, int(f.Fd()))
+		h.Addrs = append(h.Addrs, addr)
+	}
+	b := []byte{'H'}
+	var oob []byte
+	if len(fds) > 0 {
+		oob = syscall.UnixRights(fds...)
+	}
+	_, _, err := uc.WriteMsgUnix(b, oob, nil)
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(uc).Encode(&h); err != nil {
+		return err
+	}
+	if _, err = uc.Read(b); err != nil {
+		return fmt.Errorf("unacknowledged: %v", err)
+	}
+	for _, ln := range lns {
+		ln.Close()
+	}
+	// hold the successor's connection until Close or exit
+	o.mutex.Lock()
+	if o.closed {
+		uc.Close()
+	} else {
+		o.taken = uc
+	}
+	o.mutex.Unlock()
+	if o.done != nil {
+		o.done()
+	}
+	return nil
+}
+
+// Terminate is an Offer done function that has the daemon stop itself as
+// though it had received SIGTERM from goes-daemons.
+func Terminate() {
+	syscall.Kill(os.Getpid(), syscall.SIGTERM)
+}
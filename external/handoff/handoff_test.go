@@ -0,0 +1,109 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package handoff
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHandoff(t *testing.T) {
+	name := fmt.Sprint("test.", os.Getpid())
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	addr := ln.Addr().String()
+	done := make(chan struct{})
+	offer, err := NewOffer(name, func() (map[string]net.Listener,
+		[]byte) {
+		return map[string]net.Listener{addr: ln}, []byte("state")
+	}, func() { close(done) })
+	if err != nil {
+		t.Skip(err)
+	}
+	defer offer.Close()
+	if !Offered(name) {
+		t.Fatal("not offered")
+	}
+
+	in, err := Take(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in == nil {
+		t.Fatal("nothing taken")
+	}
+	defer in.Close()
+	if s := string(in.State); s != "state" {
+		t.Error("wrong state:", s)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("predecessor wasn't done")
+	}
+	nln, err := in.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nln.Close()
+	if s := nln.Addr().String(); s != addr {
+		t.Error("wrong address:", s)
+	}
+	go func() {
+		if conn, err := net.Dial("tcp", addr); err == nil {
+			conn.Close()
+		}
+	}()
+	conn, err := nln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	if in, err = Take(name); in != nil || err != nil {
+		t.Error("taken twice:", err)
+	}
+}
+
+func TestWait(t *testing.T) {
+	name := fmt.Sprint("test.wait.", os.Getpid())
+	offer, err := NewOffer(name, func() (map[string]net.Listener,
+		[]byte) {
+		return nil, []byte("state")
+	}, nil)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer offer.Close()
+	in, err := Take(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in == nil {
+		t.Fatal("nothing taken")
+	}
+	defer in.Close()
+	waited := make(chan error, 1)
+	go func() { waited <- in.Wait() }()
+	select {
+	case err = <-waited:
+		t.Fatal("didn't wait for the predecessor:", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	offer.Close()
+	select {
+	case err = <-waited:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("still waiting after the predecessor closed its offer")
+	}
+}
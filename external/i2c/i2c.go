@@ -38,6 +38,11 @@ type Bus struct {
 	fd int
 
 	features FeatureFlag
+
+	// sim is true if the bus is that of a simulated machine, i.e. one with
+	// a machine.SimEnv fixture; address is the last selected slave.
+	sim     bool
+	address int
 }
 
 func New(index, address int) (*Bus, error) {
@@ -50,6 +55,9 @@ func New(index, address int) (*Bus, error) {
 }
 
 func (b *Bus) Open(index int) (err error) {
+	if simulated, _ := simulated(); simulated {
+		return b.simOpen(index)
+	}
 	path := fmt.Sprintf("/dev/i2c-%d", index)
 	fd, err := syscall.Open(path, syscall.O_RDWR, 0)
 	if err != nil {
@@ -74,10 +82,29 @@ func (b *Bus) Open(index int) (err error) {
 }
 
 func (b *Bus) Close() (err error) {
+	if b.sim {
+		return
+	}
 	err = syscall.Close(b.fd)
 	return
 }
 
+// Lock the bus from other processes, e.g. i2cd and the i2c command, that
+// also Lock it through their own Open.
+func (b *Bus) Lock() error {
+	if b.sim {
+		return nil
+	}
+	return chk("lock", syscall.Flock(b.fd, syscall.LOCK_EX))
+}
+
+func (b *Bus) Unlock() error {
+	if b.sim {
+		return nil
+	}
+	return chk("unlock", syscall.Flock(b.fd, syscall.LOCK_UN))
+}
+
 // Do calls function f with given bus and slave device selected.
 func Do(index, slave int, f func(bus *Bus) error) (err error) {
 	var bus Bus
@@ -92,6 +119,12 @@ func Do(index, slave int, f func(bus *Bus) error) (err error) {
 }
 
 func ioctlInt(b *Bus, op IoctlOp, arg int) (err error) {
+	if op == I2C_SLAVE || op == I2C_SLAVE_FORCE {
+		b.address = arg
+	}
+	if b.sim {
+		return
+	}
 	_, _, e := syscall.RawSyscall(syscall.SYS_IOCTL, uintptr(b.fd), uintptr(op), uintptr(arg))
 	if e != 0 {
 		err = e
@@ -101,7 +134,7 @@ func ioctlInt(b *Bus, op IoctlOp, arg int) (err error) {
 
 func chk(tag string, err error) error {
 	if err != nil {
-		err = fmt.Errorf("%s: %s", tag, err)
+		err = fmt.Errorf("%s: %w", tag, err)
 	}
 	return err
 }
@@ -120,6 +153,9 @@ func (b *Bus) Set7BitAddressing() error {
 }
 
 func (b *Bus) GetFeatures() (mask FeatureFlag, err error) {
+	if b.sim {
+		return b.features, nil
+	}
 	var flags [1]uintptr
 	_, _, e := syscall.RawSyscall(syscall.SYS_IOCTL, uintptr(b.fd), uintptr(I2C_FUNCS), uintptr(unsafe.Pointer(&flags[0])))
 	if e != 0 {
@@ -244,6 +280,9 @@ func (b *Bus) ReadWrite(rw RW, command uint8, size SMBusSize, data *SMBusData) (
 	if data == nil {
 		data = &zero
 	}
+	if b.sim {
+		return b.simReadWrite(rw, command, size, data)
+	}
 
 	type smbus_cmd struct {
 		// 0 => write, 1 => read
@@ -295,6 +334,24 @@ func (bus *Bus) ReadBlock(offset, n int, delay time.Duration) ([]byte, error) {
 	return buf, err
 }
 
+// WriteBlock writes buf to the two byte addressed device starting at
+// offset, delaying after each byte for its write cycle.
+func (bus *Bus) WriteBlock(offset int, buf []byte, delay time.Duration) error {
+	// FIXME this should use I2CBlockData pages
+	for _, b := range buf {
+		var data SMBusData
+		data[0] = uint8(offset & 0x00ff)
+		data[1] = b
+		err := bus.Do(Write, uint8(offset>>8), WordData, &data)
+		if err != nil {
+			return err
+		}
+		time.Sleep(delay)
+		offset++
+	}
+	return nil
+}
+
 func (b *Bus) Write(cmd uint8, size SMBusSize, data *SMBusData) (err error) {
 	return b.Do(Write, cmd, size, data)
 }
@@ -332,6 +389,9 @@ func (b *Bus) Send(messages []Message) (err error) {
 	if l > len(ms) {
 		return fmt.Errorf("too many messages: max %d", len(ms))
 	}
+	if b.sim {
+		return b.simSend(messages)
+	}
 
 	for i := 0; i < l; i++ {
 		ms[i].address = messages[i].Address
@@ -0,0 +1,256 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2c
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/platinasystems/goes/external/machine"
+)
+
+// simDevice is a mock of a simulated machine's i2c device configured by
+// its fixture, e.g.
+//
+//	i2c:
+//	  0:
+//	    0x51:
+//	      type: eeprom
+//	      file: eeprom.bin
+//	      size: 8192
+//	    0x2f:
+//	      data: "01 02 03"
+//
+// An "eeprom" device is a two byte addressed memory like that read and
+// written by Bus.ReadBlock and Bus.WriteBlock; others are 256 byte
+// registers. Memory not given by the file or hex data reads 0xff. The
+// file is rewritten with each change so that, like the hardware, it
+// persists from one command to the next.
+type simDevice struct {
+	mutex  sync.Mutex
+	eeprom bool
+	mem    []byte
+	ptr    int
+	file   string
+}
+
+var sim struct {
+	once    sync.Once
+	enabled bool
+	devices map[[2]int]*simDevice
+	err     error
+}
+
+// simulated returns true if the machine is simulated along with the error,
+// if any, of its fixture's i2c section.
+func simulated() (bool, error) {
+	sim.once.Do(func() {
+		cfg, err := machine.Sim()
+		if cfg == nil {
+			return
+		}
+		sim.enabled = true
+		sim.devices = make(map[[2]int]*simDevice)
+		if err != nil {
+			sim.err = err
+			return
+		}
+		sim.err = loadSim(cfg)
+	})
+	return sim.enabled, sim.err
+}
+
+func loadSim(cfg *machine.Config) error {
+	for _, bus := range cfg.Keys("i2c") {
+		index, err := strconv.Atoi(bus)
+		if err != nil {
+			return fmt.Errorf("i2c.%s: invalid bus", bus)
+		}
+		for _, addr := range cfg.Keys("i2c." + bus) {
+			address, err := strconv.ParseUint(addr, 0, 10)
+			if err != nil {
+				return fmt.Errorf("i2c.%s.%s: invalid address",
+					bus, addr)
+			}
+			prefix := "i2c." + bus + "." + addr + "."
+			d := &simDevice{
+				eeprom: cfg.String(prefix+"type", "") == "eeprom",
+			}
+			size := 256
+			if d.eeprom {
+				size = 8192
+			}
+			if size, err = cfg.Int(prefix+"size", size); err != nil {
+				return err
+			}
+			var b []byte
+			if fn := cfg.String(prefix+"file", ""); len(fn) > 0 {
+				d.file = machine.SimPath(fn)
+				b, err = ioutil.ReadFile(d.file)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			for _, s := range strings.Fields(cfg.String(prefix+"data",
+				"")) {
+				u, err := strconv.ParseUint(s, 16, 8)
+				if err != nil {
+					return fmt.Errorf("%sdata: %q isn't hex",
+						prefix, s)
+				}
+				b = append(b, byte(u))
+			}
+			if size < len(b) {
+				size = len(b)
+			}
+			d.mem = make([]byte, size)
+			for i := range d.mem {
+				d.mem[i] = 0xff
+			}
+			copy(d.mem, b)
+			sim.devices[[2]int{index, int(address)}] = d
+		}
+	}
+	return nil
+}
+
+// simOpen is Open of a simulated bus; like the hardware, this fails if the
+// bus has no devices.
+func (b *Bus) simOpen(index int) error {
+	if sim.err != nil {
+		return sim.err
+	}
+	for k := range sim.devices {
+		if k[0] == index {
+			b.index = index
+			b.fd = -1
+			b.sim = true
+			b.features = ^FeatureFlag(0)
+			return nil
+		}
+	}
+	return fmt.Errorf("open /dev/i2c-%d: %v", index, syscall.ENOENT)
+}
+
+func (b *Bus) simDevice() (*simDevice, error) {
+	d := sim.devices[[2]int{b.index, b.address}]
+	if d == nil {
+		// an absent device doesn't acknowledge its address
+		return nil, syscall.ENXIO
+	}
+	return d, nil
+}
+
+func (d *simDevice) at(i int) *byte { return &d.mem[i%len(d.mem)] }
+
+func (d *simDevice) save() error {
+	if len(d.file) == 0 {
+		return nil
+	}
+	return ioutil.WriteFile(d.file, d.mem, 0644)
+}
+
+func (b *Bus) simReadWrite(rw RW, command uint8, size SMBusSize,
+	data *SMBusData) error {
+	d, err := b.simDevice()
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	c := int(command)
+	switch {
+	case size == Quick:
+		return nil
+	case size == Byte && rw == Read:
+		data[0] = *d.at(d.ptr)
+		d.ptr++
+	case size == Byte:
+		d.ptr = c
+	case d.eeprom && size == ByteData && rw == Write:
+		// select the two byte address of the next read
+		d.ptr = c<<8 | int(data[0])
+	case d.eeprom && size == WordData && rw == Write:
+		offset := c<<8 | int(data[0])
+		*d.at(offset) = data[1]
+		d.ptr = offset + 1
+		return d.save()
+	case size == ByteData && rw == Read:
+		data[0] = *d.at(c)
+	case size == ByteData:
+		*d.at(c) = data[0]
+		return d.save()
+	case size == WordData && rw == Read:
+		data[0], data[1] = *d.at(c), *d.at(c + 1)
+	case size == WordData:
+		*d.at(c), *d.at(c + 1) = data[0], data[1]
+		return d.save()
+	case size == BlockData || size == I2CBlockData:
+		n := int(data[0])
+		if n == 0 || n > SMBusMax {
+			n = SMBusMax
+		}
+		if rw == Read {
+			data[0] = byte(n)
+			for i := 0; i < n; i++ {
+				data[1+i] = *d.at(c + i)
+			}
+			return nil
+		}
+		for i := 0; i < n; i++ {
+			*d.at(c + i) = data[1+i]
+		}
+		return d.save()
+	default:
+		return syscall.EOPNOTSUPP
+	}
+	return nil
+}
+
+// simSend is Send of a simulated bus where each write message addresses the
+// device, with two bytes for an eeprom, followed by any data to write and
+// each read message continues from the last address.
+func (b *Bus) simSend(messages []Message) error {
+	for _, m := range messages {
+		d := sim.devices[[2]int{b.index, int(m.Address)}]
+		if d == nil {
+			return syscall.ENXIO
+		}
+		d.mutex.Lock()
+		data := m.Data
+		if m.Flags&ReadData != 0 {
+			for i := range data {
+				data[i] = *d.at(d.ptr)
+				d.ptr++
+			}
+		} else {
+			if d.eeprom && len(data) > 1 {
+				d.ptr = int(data[0])<<8 | int(data[1])
+				data = data[2:]
+			} else if len(data) > 0 {
+				d.ptr = int(data[0])
+				data = data[1:]
+			}
+			for _, x := range data {
+				*d.at(d.ptr) = x
+				d.ptr++
+			}
+		}
+		var err error
+		if m.Flags&ReadData == 0 && len(data) > 0 {
+			err = d.save()
+		}
+		d.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
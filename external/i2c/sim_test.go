@@ -0,0 +1,75 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package i2c
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/platinasystems/goes/external/machine"
+)
+
+func TestSim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i2c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fixture := filepath.Join(dir, "fixture.yaml")
+	ioutil.WriteFile(fixture, []byte(`
+i2c:
+  0:
+    0x51:
+      type: eeprom
+      file: eeprom.bin
+      size: 16
+    0x2f:
+      data: "01 02 03"
+`), 0644)
+	os.Setenv(machine.SimEnv, fixture)
+	defer os.Unsetenv(machine.SimEnv)
+
+	bus, err := New(0, 0x51)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+	if err = bus.WriteBlock(2, []byte("abc"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := bus.ReadBlock(1, 5, 0); err != nil {
+		t.Fatal(err)
+	} else if want := []byte{0xff, 'a', 'b', 'c', 0xff}; !bytes.Equal(b, want) {
+		t.Errorf("got % x, want % x", b, want)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "eeprom.bin")); len(b) != 16 {
+		t.Error("eeprom file has", len(b), "bytes")
+	}
+
+	err = Do(0, 0x2f, func(bus *Bus) error {
+		var data SMBusData
+		if err := bus.Read(1, WordData, &data); err != nil {
+			return err
+		}
+		if data[0] != 2 || data[1] != 3 {
+			t.Errorf("register 1: % x", data[:2])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Do(0, 0x50, func(bus *Bus) error {
+		return bus.Read(0, ByteData, nil)
+	}); err == nil {
+		t.Error("read absent device")
+	}
+	if _, err = New(1, 0x51); err == nil {
+		t.Error("opened absent bus")
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package imgverify verifies images before these are flashed by their sha256
+// manifest and its detached minisign or OpenPGP signature by a pinned key.
+//
+// The manifest of DIR/IMAGE is DIR/IMAGE.sha256 or DIR/SHA256SUMS, as
+// written by sha256sum, and its signature is MANIFEST.minisig, MANIFEST.asc
+// (armored OpenPGP), or MANIFEST.sig (binary OpenPGP), e.g.
+//
+//	sha256sum coreboot-platina-mk1.rom >SHA256SUMS
+//	minisign -S -m SHA256SUMS
+package imgverify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/platinasystems/goes/external/log"
+	"golang.org/x/crypto/openpgp"
+)
+
+var logger = log.New("imgverify")
+
+// Manifest returns the sha256 manifest of the image, if any.
+func Manifest(image string) (string, error) {
+	for _, fn := range []string{
+		image + ".sha256",
+		filepath.Join(filepath.Dir(image), "SHA256SUMS"),
+	} {
+		if _, err := os.Stat(fn); err == nil {
+			return fn, nil
+		}
+	}
+	return "", fmt.Errorf("%s: sha256 manifest: missing", image)
+}
+
+// Verify the image by its manifest and that's signature by a pinned key,
+// returning the signer.
+func Verify(image string) (*Key, error) {
+	manifest, err := Manifest(image)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(manifest)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := Keys()
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no pinned keys", KeysDir)
+	}
+	signer, err := verifySignature(manifest, data, keys)
+	if err != nil {
+		return nil, err
+	}
+	want, err := lookup(data, filepath.Base(image))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", manifest, err)
+	}
+	got, err := sum(image)
+	if err != nil {
+		return nil, err
+	}
+	if got != want {
+		return nil, fmt.Errorf("%s: sha256 %s mismatch of %s", image, got,
+			manifest)
+	}
+	return signer, nil
+}
+
+// Check verifies the image unless insecure, an explicit override of
+// development builds that's logged to the audit facility.
+func Check(image string, insecure bool) error {
+	k, err := Verify(image)
+	if err == nil {
+		logger.Info("verified", "image", image, "key", k.Name,
+			"id", k.ID)
+		return nil
+	}
+	if !insecure {
+		return err
+	}
+	log.Audit.Warn("unverified image", "image", image, "user",
+		os.Getenv("USER"), "err", err)
+	return nil
+}
+
+func verifySignature(manifest string, data []byte, keys []*Key) (*Key, error) {
+	if b, err := ioutil.ReadFile(manifest + ".minisig"); err == nil {
+		sig, err := parseMinisignSig(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s.minisig: %v", manifest, err)
+		}
+		for _, k := range keys {
+			if k.minisign != nil && k.minisign.id == sig.id {
+				if err = k.minisign.verify(data, sig); err != nil {
+					return nil, fmt.Errorf("%s.minisig: %v",
+						manifest, err)
+				}
+				return k, nil
+			}
+		}
+		return nil, fmt.Errorf("%s.minisig: key %s: isn't pinned",
+			manifest, minisignID(sig.id))
+	}
+	var ring openpgp.EntityList
+	for _, k := range keys {
+		ring = append(ring, k.pgp...)
+	}
+	for _, ext := range []string{".asc", ".sig"} {
+		b, err := ioutil.ReadFile(manifest + ext)
+		if err != nil {
+			continue
+		}
+		check := openpgp.CheckDetachedSignature
+		if ext == ".asc" {
+			check = openpgp.CheckArmoredDetachedSignature
+		}
+		e, err := check(ring, bytes.NewReader(data), bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("%s%s: %v", manifest, ext, err)
+		}
+		for _, k := range keys {
+			for _, ke := range k.pgp {
+				if ke.PrimaryKey.KeyId == e.PrimaryKey.KeyId {
+					return k, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: signature: missing", manifest)
+}
+
+// lookup returns the sha256 of the named file in the manifest; a single
+// unnamed sum is that of IMAGE.sha256.
+func lookup(manifest []byte, name string) (string, error) {
+	scan := bufio.NewScanner(bytes.NewReader(manifest))
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		switch {
+		case len(f) == 1:
+			return strings.ToLower(f[0]), nil
+		case len(f) == 2 && strings.TrimPrefix(f[1], "*") == name:
+			return strings.ToLower(f[0]), nil
+		}
+	}
+	return "", fmt.Errorf("%s: not listed", name)
+}
+
+func sum(fn string) (string, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package imgverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imgverify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	KeysDir = filepath.Join(dir, "keys")
+	write := func(fn string, b []byte) string {
+		fn = filepath.Join(dir, fn)
+		if err := ioutil.WriteFile(fn, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+	image := []byte("coreboot image")
+	h := sha256.Sum256(image)
+	manifest := []byte(hex.EncodeToString(h[:]) + "  coreboot.rom\n")
+	fn := write("coreboot.rom", image)
+	write("SHA256SUMS", manifest)
+
+	if _, err = Verify(fn); err == nil {
+		t.Fatal("verified without keys")
+	}
+	if err = Check(fn, true); err != nil {
+		t.Fatal("insecure:", err)
+	}
+
+	// minisign
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	id := make([]byte, 8)
+	binary.LittleEndian.PutUint64(id, 0x1122334455667788)
+	b64 := base64.StdEncoding.EncodeToString
+	write("minisign.pub", []byte("untrusted comment: minisign public key\n"+
+		b64(append(append([]byte("Ed"), id...), pub...))+"\n"))
+	if _, err = AddKey("", filepath.Join(dir, "minisign.pub")); err != nil {
+		t.Fatal(err)
+	}
+	hashed := blake2b.Sum512(manifest)
+	sig := ed25519.Sign(priv, hashed[:])
+	trusted := "timestamp:1600000000\tfile:SHA256SUMS"
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...),
+		trusted...))
+	write("SHA256SUMS.minisig", []byte("untrusted comment: signature\n"+
+		b64(append(append([]byte("ED"), id...), sig...))+"\n"+
+		"trusted comment: "+trusted+"\n"+b64(global)+"\n"))
+	k, err := Verify(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k.Name != "minisign" || k.ID != "1122334455667788" {
+		t.Errorf("signer: %+v", k)
+	}
+	write("coreboot.rom", []byte("tampered image"))
+	if _, err = Verify(fn); err == nil {
+		t.Error("verified tampered image")
+	}
+	write("coreboot.rom", image)
+	write("SHA256SUMS.minisig", []byte("untrusted comment: signature\n"+
+		b64(append(append([]byte("ED"), id...), sig...))+"\n"+
+		"trusted comment: forged\n"+b64(global)+"\n"))
+	if _, err = Verify(fn); err == nil {
+		t.Error("verified forged trusted comment")
+	}
+	os.Remove(filepath.Join(dir, "SHA256SUMS.minisig"))
+
+	// openpgp
+	e, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	w, _ := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	e.Serialize(w)
+	w.Close()
+	write("release.asc", buf.Bytes())
+	if _, err = AddKey("", filepath.Join(dir, "release.asc")); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err = openpgp.ArmoredDetachSign(buf, e, bytes.NewReader(manifest),
+		nil); err != nil {
+		t.Fatal(err)
+	}
+	write("SHA256SUMS.asc", buf.Bytes())
+	if k, err = Verify(fn); err != nil {
+		t.Fatal(err)
+	}
+	if k.Name != "release" || k.Kind != OpenPGP {
+		t.Errorf("signer: %+v", k)
+	}
+	if err = RemoveKey("release"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Verify(fn); err == nil {
+		t.Error("verified by unpinned key")
+	}
+	if keys, _ := Keys(); len(keys) != 1 || keys[0].Name != "minisign" {
+		t.Errorf("keys: %v", keys)
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package imgverify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// KeysDir has the pinned public keys of image signers as NAME.pub minisign
+// or NAME.asc armored OpenPGP files.
+var KeysDir = "/etc/goes/keys"
+
+// Key kinds by file extension
+const (
+	Minisign = "minisign"
+	OpenPGP  = "openpgp"
+)
+
+var kindByExt = map[string]string{
+	".pub": Minisign,
+	".asc": OpenPGP,
+}
+
+// Key is a pinned public key.
+type Key struct {
+	Name, Kind, ID string
+
+	minisign *minisignKey
+	pgp      openpgp.EntityList
+}
+
+// ParseKey of the given kind; Name is left to the caller.
+func ParseKey(kind string, b []byte) (*Key, error) {
+	k := &Key{Kind: kind}
+	switch kind {
+	case Minisign:
+		mk, err := parseMinisignKey(b)
+		if err != nil {
+			return nil, err
+		}
+		k.minisign = mk
+		k.ID = minisignID(mk.id)
+	case OpenPGP:
+		el, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("openpgp public key: %v", err)
+		}
+		var ids []string
+		for _, e := range el {
+			ids = append(ids, e.PrimaryKey.KeyIdString())
+		}
+		k.pgp = el
+		k.ID = strings.Join(ids, ",")
+	default:
+		return nil, fmt.Errorf("%s: unknown key kind", kind)
+	}
+	return k, nil
+}
+
+// Keys returns the pinned keys sorted by name.
+func Keys() ([]*Key, error) {
+	fis, err := ioutil.ReadDir(KeysDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var keys []*Key
+	for _, fi := range fis {
+		ext := filepath.Ext(fi.Name())
+		kind, found := kindByExt[ext]
+		if !found || fi.IsDir() {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(KeysDir, fi.Name()))
+		if err != nil {
+			return nil, err
+		}
+		k, err := ParseKey(kind, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fi.Name(), err)
+		}
+		k.Name = strings.TrimSuffix(fi.Name(), ext)
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys, nil
+}
+
+// AddKey pins the public key file as the named key; the kind is that of the
+// file extension, .pub (minisign) or .asc (OpenPGP).
+func AddKey(name, fn string) (*Key, error) {
+	ext := filepath.Ext(fn)
+	kind, found := kindByExt[ext]
+	if !found {
+		return nil, fmt.Errorf("%s: neither .pub (minisign) nor .asc (openpgp)",
+			fn)
+	}
+	if len(name) == 0 {
+		name = strings.TrimSuffix(filepath.Base(fn), ext)
+	}
+	if strings.ContainsAny(name, "/ ") || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("%q: invalid key name", name)
+	}
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	k, err := ParseKey(kind, b)
+	if err != nil {
+		return nil, err
+	}
+	k.Name = name
+	if err = os.MkdirAll(KeysDir, 0755); err != nil {
+		return nil, err
+	}
+	for e := range kindByExt {
+		if _, err = os.Stat(filepath.Join(KeysDir, name+e)); err == nil {
+			return nil, fmt.Errorf("%s: already pinned", name)
+		}
+	}
+	return k, ioutil.WriteFile(filepath.Join(KeysDir, name+ext), b, 0644)
+}
+
+// RemoveKey unpins the named key.
+func RemoveKey(name string) error {
+	for ext := range kindByExt {
+		fn := filepath.Join(KeysDir, name+ext)
+		if _, err := os.Stat(fn); err == nil {
+			return os.Remove(fn)
+		}
+	}
+	return fmt.Errorf("%s: not found", name)
+}
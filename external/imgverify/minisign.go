@@ -0,0 +1,120 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package imgverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// minisign public key and signature algorithms; the latter is legacy
+// (Ed) or prehashed with BLAKE2b-512 (ED).
+const (
+	minisignAlg       = "Ed"
+	minisignHashedAlg = "ED"
+)
+
+type minisignKey struct {
+	id  uint64
+	key ed25519.PublicKey
+}
+
+type minisignSig struct {
+	alg     string
+	id      uint64
+	sig     []byte
+	trusted string
+	global  []byte
+}
+
+// minisignID formats a key ID as minisign does.
+func minisignID(id uint64) string { return fmt.Sprintf("%016X", id) }
+
+// payloads returns the non-comment lines of a minisign file.
+func payloads(b []byte) []string {
+	var lines []string
+	for _, s := range strings.Split(string(b), "\n") {
+		s = strings.TrimSpace(s)
+		if len(s) > 0 && !strings.HasPrefix(s, "untrusted comment:") {
+			lines = append(lines, s)
+		}
+	}
+	return lines
+}
+
+func parseMinisignKey(b []byte) (*minisignKey, error) {
+	lines := payloads(b)
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("minisign public key: missing")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("minisign public key: %v", err)
+	}
+	if len(raw) != 2+8+ed25519.PublicKeySize ||
+		string(raw[:2]) != minisignAlg {
+		return nil, fmt.Errorf("minisign public key: invalid")
+	}
+	return &minisignKey{
+		id:  binary.LittleEndian.Uint64(raw[2:10]),
+		key: ed25519.PublicKey(raw[10:]),
+	}, nil
+}
+
+func parseMinisignSig(b []byte) (*minisignSig, error) {
+	lines := payloads(b)
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "trusted comment: ") {
+		return nil, fmt.Errorf("minisign signature: invalid")
+	}
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return nil, fmt.Errorf("minisign signature: %v", err)
+	}
+	if len(raw) != 2+8+ed25519.SignatureSize {
+		return nil, fmt.Errorf("minisign signature: invalid")
+	}
+	global, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return nil, fmt.Errorf("minisign signature: invalid global")
+	}
+	sig := &minisignSig{
+		alg:     string(raw[:2]),
+		id:      binary.LittleEndian.Uint64(raw[2:10]),
+		sig:     raw[10:],
+		trusted: strings.TrimPrefix(lines[1], "trusted comment: "),
+		global:  global,
+	}
+	if sig.alg != minisignAlg && sig.alg != minisignHashedAlg {
+		return nil, fmt.Errorf("minisign signature: %q: unknown algorithm",
+			sig.alg)
+	}
+	return sig, nil
+}
+
+// verify the data and trusted comment of the signature.
+func (k *minisignKey) verify(data []byte, sig *minisignSig) error {
+	if sig.id != k.id {
+		return fmt.Errorf("minisign key %s: not the signer", minisignID(k.id))
+	}
+	msg := data
+	if sig.alg == minisignHashedAlg {
+		h := blake2b.Sum512(data)
+		msg = h[:]
+	}
+	if !ed25519.Verify(k.key, msg, sig.sig) {
+		return fmt.Errorf("minisign signature: mismatch")
+	}
+	global := bytes.Join([][]byte{sig.sig, []byte(sig.trusted)}, nil)
+	if !ed25519.Verify(k.key, global, sig.global) {
+		return fmt.Errorf("minisign trusted comment: mismatch")
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ipmi
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// linux/ipmi.h
+const (
+	systemInterfaceAddrType = 0x0c
+	bmcChannel              = 0xf
+	responseRecvType        = 1
+	maxMsgLength            = 272
+	iocMagic                = 'i'
+	iocRead                 = 2
+	iocReadWrite            = 3
+)
+
+type systemInterfaceAddr struct {
+	addrType int32
+	channel  int16
+	lun      uint8
+}
+
+type msg struct {
+	netfn, cmd uint8
+	dataLen    uint16
+	data       *byte
+}
+
+type req struct {
+	addr    *systemInterfaceAddr
+	addrLen uint32
+	msgid   int
+	msg     msg
+}
+
+type recv struct {
+	recvType int32
+	addr     *systemInterfaceAddr
+	addrLen  uint32
+	msgid    int
+	msg      msg
+}
+
+var (
+	sendCommandIoctl     = ioc(iocRead, 13, unsafe.Sizeof(req{}))
+	receiveMsgTruncIoctl = ioc(iocReadWrite, 11, unsafe.Sizeof(recv{}))
+)
+
+// ioc encodes the request like the kernel's _IOR and _IOWR macros.
+func ioc(dir, nr, size uintptr) uintptr {
+	return dir<<30 | size<<16 | iocMagic<<8 | nr
+}
+
+// Dev is a Conn through the kernel's ipmi_devintf.
+type Dev struct {
+	fd    int
+	msgid int
+}
+
+// Device is the default system interface.
+var Device = "/dev/ipmi0"
+
+// Open the named, or default, system interface device.
+func Open(name string) (*Dev, error) {
+	if len(name) == 0 {
+		name = Device
+	}
+	fd, err := syscall.Open(name, syscall.O_RDWR|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &Dev{fd: fd}, nil
+}
+
+func (d *Dev) Close() error {
+	return syscall.Close(d.fd)
+}
+
+func (d *Dev) Do(netfn, cmd uint8, data ...byte) ([]byte, error) {
+	addr := systemInterfaceAddr{
+		addrType: systemInterfaceAddrType,
+		channel:  bmcChannel,
+	}
+	d.msgid++
+	r := req{
+		addr:    &addr,
+		addrLen: uint32(unsafe.Sizeof(addr)),
+		msgid:   d.msgid,
+		msg: msg{
+			netfn:   netfn,
+			cmd:     cmd,
+			dataLen: uint16(len(data)),
+		},
+	}
+	if len(data) > 0 {
+		r.msg.data = &data[0]
+	}
+	if err := ioctl(d.fd, sendCommandIoctl, unsafe.Pointer(&r)); err != nil {
+		return nil, fmt.Errorf("send: %v", err)
+	}
+	deadline := time.Now().Add(Timeout)
+	for {
+		if err := d.wait(time.Until(deadline)); err != nil {
+			return nil, err
+		}
+		var rspAddr systemInterfaceAddr
+		buf := make([]byte, maxMsgLength)
+		rsp := recv{
+			addr:    &rspAddr,
+			addrLen: uint32(unsafe.Sizeof(rspAddr)),
+			msg: msg{
+				dataLen: uint16(len(buf)),
+				data:    &buf[0],
+			},
+		}
+		err := ioctl(d.fd, receiveMsgTruncIoctl, unsafe.Pointer(&rsp))
+		if err != nil && err != syscall.EMSGSIZE {
+			return nil, fmt.Errorf("receive: %v", err)
+		}
+		// skip events and stale responses of timed out requests
+		if rsp.recvType != responseRecvType || rsp.msgid != d.msgid {
+			continue
+		}
+		return complete(buf[:rsp.msg.dataLen])
+	}
+}
+
+// wait for a received message
+func (d *Dev) wait(timeout time.Duration) error {
+	if timeout <= 0 {
+		return fmt.Errorf("timeout")
+	}
+	for {
+		var fds syscall.FdSet
+		nbits := 8 * int(unsafe.Sizeof(fds.Bits[0]))
+		fds.Bits[d.fd/nbits] |= 1 << uint(d.fd%nbits)
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+		n, err := syscall.Select(d.fd+1, &fds, nil, nil, &tv)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("timeout")
+		}
+		return nil
+	}
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req,
+		uintptr(arg))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package ipmi provides BMC requests through either the kernel's system
+// interface, /dev/ipmi0, or IPMI v2.0 RMCP+ LAN sessions.
+package ipmi
+
+import (
+	"fmt"
+	"time"
+)
+
+// Network functions
+const (
+	NetFnChassis = 0x00
+	NetFnSensor  = 0x04
+	NetFnApp     = 0x06
+	NetFnStorage = 0x0a
+)
+
+// Commands
+const (
+	CmdGetChassisStatus  = 0x01
+	CmdChassisControl    = 0x02
+	CmdGetSensorReading  = 0x2d
+	CmdGetDeviceId       = 0x01
+	CmdSetSessionPriv    = 0x3b
+	CmdCloseSession      = 0x3c
+	CmdReserveSdr        = 0x22
+	CmdGetSdr            = 0x23
+	CmdGetSelInfo        = 0x40
+	CmdReserveSel        = 0x42
+	CmdGetSelEntry       = 0x43
+	CmdClearSel          = 0x47
+	bmcAddr              = 0x20
+	remoteConsoleAddr    = 0x81
+	privilegeAdmin       = 0x04
+	completionOk         = 0x00
+	completionBadReserve = 0xc5
+)
+
+// Timeout of each request
+var Timeout = 5 * time.Second
+
+// Conn is a BMC requester.
+type Conn interface {
+	// Do the request and return its response data without the
+	// completion code, which if not zero, is returned as an Error.
+	Do(netfn, cmd uint8, data ...byte) ([]byte, error)
+	Close() error
+}
+
+// Error is a non-zero completion code.
+type Error uint8
+
+var completions = map[Error]string{
+	0xc0: "node busy",
+	0xc1: "invalid command",
+	0xc2: "invalid for LUN",
+	0xc3: "timeout",
+	0xc4: "out of space",
+	0xc5: "reservation canceled",
+	0xc6: "request data truncated",
+	0xc7: "request data length invalid",
+	0xc8: "request data field length limit exceeded",
+	0xc9: "parameter out of range",
+	0xca: "cannot return requested number of bytes",
+	0xcb: "requested sensor, data, or record not present",
+	0xcc: "invalid data field",
+	0xcd: "command illegal for sensor or record type",
+	0xce: "command response could not be provided",
+	0xcf: "cannot execute duplicated request",
+	0xd0: "SDR repository in update mode",
+	0xd1: "device in firmware update mode",
+	0xd2: "BMC initialization in progress",
+	0xd3: "destination unavailable",
+	0xd4: "insufficient privilege level",
+	0xd5: "not supported in present state",
+	0xd6: "sub-function disabled",
+	0xff: "unspecified error",
+}
+
+func (e Error) Error() string {
+	if s, found := completions[e]; found {
+		return s
+	}
+	return fmt.Sprintf("completion code %#02x", uint8(e))
+}
+
+// complete returns the data of a response with its leading completion code.
+func complete(rsp []byte) ([]byte, error) {
+	if len(rsp) == 0 {
+		return nil, fmt.Errorf("empty response")
+	}
+	if rsp[0] != completionOk {
+		return nil, Error(rsp[0])
+	}
+	return rsp[1:], nil
+}
+
+// DeviceId is the decoded Get Device ID response.
+type DeviceId struct {
+	Id, Revision    uint8
+	Firmware        string
+	IpmiVersion     string
+	ManufacturerId  uint32
+	ProductId       uint16
+	DeviceAvailable bool
+}
+
+func GetDeviceId(c Conn) (*DeviceId, error) {
+	b, err := c.Do(NetFnApp, CmdGetDeviceId)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 11 {
+		return nil, fmt.Errorf("device id: %d byte response", len(b))
+	}
+	return &DeviceId{
+		Id:              b[0],
+		Revision:        b[1] & 0xf,
+		Firmware:        fmt.Sprintf("%d.%02x", b[2]&0x7f, b[3]),
+		IpmiVersion:     fmt.Sprintf("%d.%d", b[4]&0xf, b[4]>>4),
+		ManufacturerId:  uint32(b[6]) | uint32(b[7])<<8 | uint32(b[8]&0xf)<<16,
+		ProductId:       uint16(b[9]) | uint16(b[10])<<8,
+		DeviceAvailable: b[2]&0x80 == 0,
+	}, nil
+}
+
+// Chassis controls
+const (
+	PowerOff   = 0x00
+	PowerOn    = 0x01
+	PowerCycle = 0x02
+	HardReset  = 0x03
+	SoftOff    = 0x05
+)
+
+// PowerControls by name
+var PowerControls = map[string]uint8{
+	"off":   PowerOff,
+	"on":    PowerOn,
+	"cycle": PowerCycle,
+	"reset": HardReset,
+	"soft":  SoftOff,
+}
+
+// PowerIsOn returns the chassis power state.
+func PowerIsOn(c Conn) (bool, error) {
+	b, err := c.Do(NetFnChassis, CmdGetChassisStatus)
+	if err != nil {
+		return false, err
+	}
+	if len(b) < 1 {
+		return false, fmt.Errorf("chassis status: empty response")
+	}
+	return b[0]&1 != 0, nil
+}
+
+func ChassisControl(c Conn, control uint8) error {
+	_, err := c.Do(NetFnChassis, CmdChassisControl, control)
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ipmi
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	rec := make([]byte, 64)
+	rec[3] = FullSensorRecord
+	rec[4] = byte(len(rec) - 5)
+	rec[5] = bmcAddr
+	rec[20] = 0    // unsigned
+	rec[21] = 4    // Volts
+	rec[24] = 0x4e // M = 78
+	rec[29] = 0xd0 // R exp -3, B exp 0
+	rec[47] = 0xc0 | 4
+	copy(rec[48:], "P12V")
+	s, err := decodeSensor(rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "P12V" || s.Unit != "Volts" || !s.Analog {
+		t.Fatalf("%+v", s)
+	}
+	if v := s.Convert(154); math.Abs(v-12.012) > 1e-9 {
+		t.Error("convert", v)
+	}
+}
+
+func TestSelEntry(t *testing.T) {
+	e := DecodeSelEntry([]byte{
+		0x12, 0x00, 0x02,
+		0x00, 0x00, 0x00, 0x5f, // 2020-09-01
+		0x20, 0x00, 0x04,
+		0x01, 0x30, 0x81,
+		0x59, 0x00, 0x00,
+	})
+	if e.Id != 0x12 || e.SensorType != 0x01 || e.Sensor != 0x30 ||
+		!e.Deassert || e.EventType != 1 || e.Time.Unix() != 0x5f000000 {
+		t.Errorf("%+v", e)
+	}
+}
+
+func TestLanPayload(t *testing.T) {
+	l := &Lan{
+		sidm: 0x12345679,
+		k1:   bytes.Repeat([]byte{1}, 20),
+		k2:   bytes.Repeat([]byte{2}, 20),
+	}
+	msg := []byte{remoteConsoleAddr, (NetFnApp + 1) << 2, 0, bmcAddr, 4,
+		CmdGetDeviceId, 0, 0x20}
+	payload, err := l.encrypt(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt := rmcpHeader()
+	pkt = append(pkt, authTypeRmcpPlus,
+		payloadIpmi|payloadEncrypted|payloadAuthentic)
+	pkt = append(pkt, le32(l.sidm)...)
+	pkt = append(pkt, le32(1)...)
+	pkt = append(pkt, le16(uint16(len(payload)))...)
+	pkt = append(pkt, payload...)
+	pkt = l.sign(pkt)
+	if (len(pkt)-4-authCodeLen)%4 != 0 {
+		t.Error("unaligned integrity pad")
+	}
+	got, err := l.open(pkt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, msg) {
+		t.Errorf("%x vs. %x", got, msg)
+	}
+	pkt[20] ^= 1
+	if _, err = l.open(pkt); err == nil {
+		t.Error("accepted corrupt packet")
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ipmi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+// RMCP+ payload types
+const (
+	payloadIpmi        = 0x00
+	payloadOpenSession = 0x10
+	payloadOpenRsp     = 0x11
+	payloadRakp1       = 0x12
+	payloadRakp2       = 0x13
+	payloadRakp3       = 0x14
+	payloadRakp4       = 0x15
+	payloadEncrypted   = 0x80
+	payloadAuthentic   = 0x40
+	authTypeRmcpPlus   = 0x06
+	rmcpClassIpmi      = 0x07
+	integrityPad       = 0xff
+	authCodeLen        = 12
+	retries            = 3
+)
+
+// Lan is an IPMI v2.0 RMCP+ session with cipher suite 3, i.e.
+// RAKP-HMAC-SHA1 authentication, HMAC-SHA1-96 integrity, and AES-CBC-128
+// confidentiality.
+type Lan struct {
+	conn net.Conn
+
+	// console and managed system session IDs
+	sidm, sidc uint32
+	seq        uint32
+	rqSeq      uint8
+
+	k1, k2 []byte
+}
+
+// Dial the BMC at HOST[:PORT], default port 623, and activate an
+// administrator session of the given user.
+func Dial(host, user, password string) (*Lan, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "623")
+	}
+	if len(user) > 16 {
+		return nil, fmt.Errorf("%s: user name exceeds 16 characters", user)
+	}
+	if len(password) > 20 {
+		return nil, fmt.Errorf("password exceeds 20 characters")
+	}
+	conn, err := net.Dial("udp", host)
+	if err != nil {
+		return nil, err
+	}
+	l := &Lan{conn: conn}
+	if err = l.activate(user, password); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %v", host, err)
+	}
+	return l, nil
+}
+
+func (l *Lan) Close() error {
+	var sid [4]byte
+	binary.LittleEndian.PutUint32(sid[:], l.sidc)
+	l.Do(NetFnApp, CmdCloseSession, sid[:]...)
+	return l.conn.Close()
+}
+
+func (l *Lan) activate(user, password string) error {
+	var rm [16]byte
+	if _, err := rand.Read(rm[:4]); err != nil {
+		return err
+	}
+	// the console's session ID must be non-zero
+	l.sidm = binary.LittleEndian.Uint32(rm[:4]) | 1
+
+	req := []byte{0, privilegeAdmin, 0, 0}
+	req = append(req, le32(l.sidm)...)
+	req = append(req,
+		0, 0, 0, 8, 1, 0, 0, 0, // RAKP-HMAC-SHA1
+		1, 0, 0, 8, 1, 0, 0, 0, // HMAC-SHA1-96
+		2, 0, 0, 8, 1, 0, 0, 0) // AES-CBC-128
+	rsp, err := l.exchange(payloadOpenSession, payloadOpenRsp, req)
+	if err != nil {
+		return fmt.Errorf("open session: %v", err)
+	}
+	if len(rsp) >= 2 && rsp[1] != 0 {
+		return fmt.Errorf("open session: %s", rmcpStatus(rsp[1]))
+	}
+	if len(rsp) < 12 {
+		return fmt.Errorf("open session: %d byte response", len(rsp))
+	}
+	l.sidc = binary.LittleEndian.Uint32(rsp[8:12])
+
+	if _, err := rand.Read(rm[:]); err != nil {
+		return err
+	}
+	// administrator by name only lookup
+	role := byte(privilegeAdmin | 0x10)
+	req = []byte{0, 0, 0, 0}
+	req = append(req, le32(l.sidc)...)
+	req = append(req, rm[:]...)
+	req = append(req, role, 0, 0, byte(len(user)))
+	req = append(req, user...)
+	rsp, err = l.exchange(payloadRakp1, payloadRakp2, req)
+	if err != nil {
+		return fmt.Errorf("rakp 1: %v", err)
+	}
+	if len(rsp) >= 2 && rsp[1] != 0 {
+		return fmt.Errorf("rakp 2: %s", rmcpStatus(rsp[1]))
+	}
+	if len(rsp) < 60 {
+		return fmt.Errorf("rakp 2: %d byte response", len(rsp))
+	}
+	rc := rsp[8:24]
+	guid := rsp[24:40]
+	kuid := make([]byte, 20)
+	copy(kuid, password)
+
+	mac := hmacSha1(kuid, le32(l.sidm), le32(l.sidc), rm[:], rc, guid,
+		[]byte{role, byte(len(user))}, []byte(user))
+	if !hmac.Equal(mac, rsp[40:60]) {
+		return fmt.Errorf("rakp 2: invalid user or password")
+	}
+	sik := hmacSha1(kuid, rm[:], rc, []byte{role, byte(len(user))},
+		[]byte(user))
+	l.k1 = hmacSha1(sik, bytes.Repeat([]byte{1}, 20))
+	l.k2 = hmacSha1(sik, bytes.Repeat([]byte{2}, 20))
+
+	req = []byte{0, 0, 0, 0}
+	req = append(req, le32(l.sidc)...)
+	req = append(req, hmacSha1(kuid, rc, le32(l.sidm),
+		[]byte{role, byte(len(user))}, []byte(user))...)
+	rsp, err = l.exchange(payloadRakp3, payloadRakp4, req)
+	if err != nil {
+		return fmt.Errorf("rakp 3: %v", err)
+	}
+	if len(rsp) >= 2 && rsp[1] != 0 {
+		return fmt.Errorf("rakp 4: %s", rmcpStatus(rsp[1]))
+	}
+	if len(rsp) < 8+authCodeLen {
+		return fmt.Errorf("rakp 4: %d byte response", len(rsp))
+	}
+	icv := hmacSha1(sik, rm[:], le32(l.sidc), guid)[:authCodeLen]
+	if !hmac.Equal(icv, rsp[8:8+authCodeLen]) {
+		return fmt.Errorf("rakp 4: integrity check mismatch")
+	}
+	l.seq = 1
+	_, err = l.Do(NetFnApp, CmdSetSessionPriv, privilegeAdmin)
+	return err
+}
+
+// exchange an unauthenticated session setup payload
+func (l *Lan) exchange(typ, rsptyp uint8, payload []byte) ([]byte, error) {
+	pkt := rmcpHeader()
+	pkt = append(pkt, authTypeRmcpPlus, typ)
+	pkt = append(pkt, make([]byte, 8)...)
+	pkt = append(pkt, le16(uint16(len(payload)))...)
+	pkt = append(pkt, payload...)
+	return l.roundtrip(pkt, func(b []byte) ([]byte, bool, error) {
+		if len(b) < 16 || b[4] != authTypeRmcpPlus || b[5]&0x3f != rsptyp {
+			return nil, false, nil
+		}
+		n := int(binary.LittleEndian.Uint16(b[14:16]))
+		if len(b) < 16+n {
+			return nil, false, nil
+		}
+		return b[16 : 16+n], true, nil
+	})
+}
+
+func (l *Lan) Do(netfn, cmd uint8, data ...byte) ([]byte, error) {
+	l.rqSeq = (l.rqSeq + 1) & 0x3f
+	msg := []byte{bmcAddr, netfn << 2}
+	msg = append(msg, checksum(msg))
+	msg = append(msg, remoteConsoleAddr, l.rqSeq<<2, cmd)
+	msg = append(msg, data...)
+	msg = append(msg, checksum(msg[3:]))
+
+	payload, err := l.encrypt(msg)
+	if err != nil {
+		return nil, err
+	}
+	pkt := rmcpHeader()
+	pkt = append(pkt, authTypeRmcpPlus,
+		payloadIpmi|payloadEncrypted|payloadAuthentic)
+	pkt = append(pkt, le32(l.sidc)...)
+	pkt = append(pkt, le32(l.seq)...)
+	l.seq++
+	pkt = append(pkt, le16(uint16(len(payload)))...)
+	pkt = append(pkt, payload...)
+	pkt = l.sign(pkt)
+
+	rqSeq := l.rqSeq
+	return l.roundtrip(pkt, func(b []byte) ([]byte, bool, error) {
+		rsp, err := l.open(b)
+		// skip the stale responses of retried requests
+		if err != nil || len(rsp) < 8 || rsp[4]>>2 != rqSeq ||
+			rsp[5] != cmd || rsp[1]>>2 != netfn+1 {
+			return nil, false, nil
+		}
+		data, err := complete(rsp[6 : len(rsp)-1])
+		return data, true, err
+	})
+}
+
+// roundtrip sends the packet and returns the first response accepted by
+// the parser, retrying on timeout.
+func (l *Lan) roundtrip(pkt []byte,
+	parse func([]byte) ([]byte, bool, error)) ([]byte, error) {
+	buf := make([]byte, 1024)
+	for try := 0; try < retries; try++ {
+		if _, err := l.conn.Write(pkt); err != nil {
+			return nil, err
+		}
+		l.conn.SetReadDeadline(time.Now().Add(Timeout / retries))
+		for {
+			n, err := l.conn.Read(buf)
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if rsp, ok, err := parse(buf[:n]); ok {
+				return rsp, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("timeout")
+}
+
+// sign appends the integrity pad, pad length, next header, and the
+// HMAC-SHA1-96 of the session header through next header.
+func (l *Lan) sign(pkt []byte) []byte {
+	// pad to a multiple of 4 from the auth type through next header
+	npad := (4 - (len(pkt)-4+2)%4) % 4
+	for i := 0; i < npad; i++ {
+		pkt = append(pkt, integrityPad)
+	}
+	pkt = append(pkt, byte(npad), rmcpClassIpmi)
+	return append(pkt, hmacSha1(l.k1, pkt[4:])[:authCodeLen]...)
+}
+
+// open verifies and decrypts an authenticated, encrypted IPMI payload.
+func (l *Lan) open(pkt []byte) ([]byte, error) {
+	if len(pkt) < 16+authCodeLen ||
+		pkt[5] != payloadIpmi|payloadEncrypted|payloadAuthentic {
+		return nil, fmt.Errorf("unexpected payload")
+	}
+	if binary.LittleEndian.Uint32(pkt[6:10]) != l.sidm {
+		return nil, fmt.Errorf("unexpected session")
+	}
+	end := len(pkt) - authCodeLen
+	mac := hmacSha1(l.k1, pkt[4:end])[:authCodeLen]
+	if !hmac.Equal(mac, pkt[end:]) {
+		return nil, fmt.Errorf("integrity check mismatch")
+	}
+	n := int(binary.LittleEndian.Uint16(pkt[14:16]))
+	if 16+n > end {
+		return nil, fmt.Errorf("truncated payload")
+	}
+	return l.decrypt(pkt[16 : 16+n])
+}
+
+// encrypt the payload with AES-CBC-128 as IV then cipher text of the
+// payload, pad bytes 1, 2, ..., and pad length.
+func (l *Lan) encrypt(payload []byte) ([]byte, error) {
+	block, err := aes.NewCipher(l.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	text := append([]byte{}, payload...)
+	npad := (aes.BlockSize - (len(text)+1)%aes.BlockSize) % aes.BlockSize
+	for i := 1; i <= npad; i++ {
+		text = append(text, byte(i))
+	}
+	text = append(text, byte(npad))
+	out := make([]byte, aes.BlockSize+len(text))
+	if _, err = rand.Read(out[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, out[:aes.BlockSize]).
+		CryptBlocks(out[aes.BlockSize:], text)
+	return out, nil
+}
+
+func (l *Lan) decrypt(payload []byte) ([]byte, error) {
+	if len(payload) < 2*aes.BlockSize || len(payload)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%d byte encrypted payload", len(payload))
+	}
+	block, err := aes.NewCipher(l.k2[:16])
+	if err != nil {
+		return nil, err
+	}
+	text := make([]byte, len(payload)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, payload[:aes.BlockSize]).
+		CryptBlocks(text, payload[aes.BlockSize:])
+	npad := int(text[len(text)-1])
+	if npad >= len(text) {
+		return nil, fmt.Errorf("invalid confidentiality pad")
+	}
+	return text[:len(text)-1-npad], nil
+}
+
+func rmcpHeader() []byte {
+	return []byte{0x06, 0x00, 0xff, rmcpClassIpmi}
+}
+
+func hmacSha1(key []byte, data ...[]byte) []byte {
+	h := hmac.New(sha1.New, key)
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// checksum is the two's complement of the byte sum
+func checksum(b []byte) byte {
+	var sum byte
+	for _, c := range b {
+		sum += c
+	}
+	return -sum
+}
+
+func le16(u uint16) []byte {
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, u)
+	return b
+}
+
+func le32(u uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, u)
+	return b
+}
+
+var rmcpStatuses = map[uint8]string{
+	0x01: "insufficient resources to create a session",
+	0x02: "invalid session ID",
+	0x03: "invalid payload type",
+	0x04: "invalid authentication algorithm",
+	0x05: "invalid integrity algorithm",
+	0x06: "no matching authentication payload",
+	0x07: "no matching integrity payload",
+	0x08: "inactive session ID",
+	0x09: "invalid role",
+	0x0a: "unauthorized role or privilege level requested",
+	0x0b: "insufficient resources to create a session at the requested role",
+	0x0c: "invalid name length",
+	0x0d: "unauthorized name",
+	0x0e: "unauthorized GUID",
+	0x0f: "invalid integrity check value",
+	0x10: "invalid confidentiality algorithm",
+	0x11: "no cipher suite match with proposed security algorithms",
+	0x12: "illegal or unrecognized parameter",
+}
+
+func rmcpStatus(code uint8) string {
+	if s, found := rmcpStatuses[code]; found {
+		return s
+	}
+	return fmt.Sprintf("status %#02x", code)
+}
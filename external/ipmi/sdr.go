@@ -0,0 +1,317 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ipmi
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// SDR record types
+const (
+	FullSensorRecord    = 0x01
+	CompactSensorRecord = 0x02
+)
+
+// sdrChunk is the number of record bytes per Get SDR; some BMCs can't
+// return whole records.
+const sdrChunk = 16
+
+// Sensor is the decoded full or compact sensor data record.
+type Sensor struct {
+	Name   string
+	Number uint8
+	Owner  uint8
+	Type   uint8
+	// Threshold sensors have an event/reading type of 1.
+	EventType uint8
+	Unit      string
+
+	// Analog is true of full records with a linear, or linearizable,
+	// conversion of raw readings.
+	Analog bool
+
+	format        uint8
+	m, b          int
+	rexp, bexp    int
+	linearization uint8
+}
+
+// Reading of a sensor
+type Reading struct {
+	Value float64
+	// Raw reading of non-analog sensors
+	Raw uint8
+	// Status is "ok" or the most severe crossed threshold, e.g. "ucr"
+	// for upper critical, of threshold sensors; otherwise, the hex
+	// discrete state.
+	Status string
+}
+
+var units = map[uint8]string{
+	1:  "degrees C",
+	2:  "degrees F",
+	3:  "degrees K",
+	4:  "Volts",
+	5:  "Amps",
+	6:  "Watts",
+	7:  "Joules",
+	18: "RPM",
+	19: "Hz",
+	20: "microseconds",
+	21: "milliseconds",
+	22: "seconds",
+	66: "percent",
+}
+
+// SensorTypes names
+var SensorTypes = map[uint8]string{
+	0x01: "Temperature",
+	0x02: "Voltage",
+	0x03: "Current",
+	0x04: "Fan",
+	0x05: "Physical Security",
+	0x06: "Platform Security",
+	0x07: "Processor",
+	0x08: "Power Supply",
+	0x09: "Power Unit",
+	0x0b: "Other Units",
+	0x0c: "Memory",
+	0x0d: "Drive Slot",
+	0x0f: "System Firmware Progress",
+	0x10: "Event Logging Disabled",
+	0x11: "Watchdog 1",
+	0x12: "System Event",
+	0x13: "Critical Interrupt",
+	0x14: "Button/Switch",
+	0x19: "Chip Set",
+	0x1b: "Cable/Interconnect",
+	0x1d: "System Boot Initiated",
+	0x1f: "OS Boot",
+	0x20: "OS Stop",
+	0x21: "Slot/Connector",
+	0x22: "System ACPI Power State",
+	0x23: "Watchdog 2",
+	0x25: "Entity Presence",
+	0x28: "Management Subsystem Health",
+	0x29: "Battery",
+	0x2b: "Version Change",
+	0x2c: "FRU State",
+}
+
+// Sensors reads the full and compact sensor records of the SDR repository.
+func Sensors(c Conn) ([]Sensor, error) {
+	var sensors []Sensor
+	for id := uint16(0); id != 0xffff; {
+		next, rec, err := sdr(c, id)
+		if err != nil {
+			return sensors, fmt.Errorf("sdr %#04x: %v", id, err)
+		}
+		if s, err := decodeSensor(rec); err == nil {
+			sensors = append(sensors, s)
+		}
+		if next == id {
+			break
+		}
+		id = next
+	}
+	return sensors, nil
+}
+
+// sdr returns the next record ID and the record of the given ID.
+func sdr(c Conn, id uint16) (uint16, []byte, error) {
+	var rec []byte
+	var next uint16
+	for try := 0; ; try++ {
+		b, err := c.Do(NetFnStorage, CmdReserveSdr)
+		if err != nil {
+			return 0, nil, fmt.Errorf("reserve: %v", err)
+		}
+		if len(b) < 2 {
+			return 0, nil, fmt.Errorf("reserve: empty response")
+		}
+		reservation := []byte{b[0], b[1]}
+		rec = rec[:0]
+		length := 5
+		for len(rec) < length {
+			n := length - len(rec)
+			if len(rec) == 0 {
+				// the header
+				n = 5
+			} else if n > sdrChunk {
+				n = sdrChunk
+			}
+			data := append(reservation, byte(id), byte(id>>8),
+				byte(len(rec)), byte(n))
+			b, err = c.Do(NetFnStorage, CmdGetSdr, data...)
+			if err != nil {
+				break
+			}
+			if len(b) < 3 {
+				err = fmt.Errorf("empty response")
+				break
+			}
+			next = uint16(b[0]) | uint16(b[1])<<8
+			rec = append(rec, b[2:]...)
+			if len(rec) >= 5 {
+				length = 5 + int(rec[4])
+			}
+		}
+		if err == Error(completionBadReserve) && try < retries {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		return next, rec[:length], nil
+	}
+}
+
+func decodeSensor(rec []byte) (Sensor, error) {
+	var s Sensor
+	if len(rec) < 5 {
+		return s, fmt.Errorf("truncated")
+	}
+	var idOffset int
+	switch rec[3] {
+	case FullSensorRecord:
+		idOffset = 47
+	case CompactSensorRecord:
+		idOffset = 31
+	default:
+		return s, fmt.Errorf("%#02x: not a sensor record", rec[3])
+	}
+	if len(rec) < idOffset+1 {
+		return s, fmt.Errorf("truncated")
+	}
+	s.Owner = rec[5]
+	s.Number = rec[7]
+	s.Type = rec[12]
+	s.EventType = rec[13]
+	s.format = rec[20] >> 6
+	if u, found := units[rec[21]]; found {
+		s.Unit = u
+	}
+	n := int(rec[idOffset] & 0x1f)
+	if idOffset+1+n > len(rec) {
+		n = len(rec) - idOffset - 1
+	}
+	s.Name = strings.TrimRight(string(rec[idOffset+1:idOffset+1+n]), "\x00 ")
+	if rec[3] != FullSensorRecord || s.format == 3 {
+		return s, nil
+	}
+	s.linearization = rec[23] & 0x7f
+	s.m = signExtend(int(rec[24])|int(rec[25]>>6)<<8, 10)
+	s.b = signExtend(int(rec[26])|int(rec[27]>>6)<<8, 10)
+	s.rexp = signExtend(int(rec[29]>>4), 4)
+	s.bexp = signExtend(int(rec[29]&0xf), 4)
+	s.Analog = s.linearization <= 0x0b
+	return s, nil
+}
+
+// Convert the raw reading per the record's format and linearization.
+func (s *Sensor) Convert(raw uint8) float64 {
+	var x int
+	switch s.format {
+	case 1:
+		// one's complement
+		x = int(int8(raw))
+		if x < 0 {
+			x++
+		}
+	case 2:
+		x = int(int8(raw))
+	default:
+		x = int(raw)
+	}
+	y := (float64(s.m)*float64(x) + float64(s.b)*math.Pow10(s.bexp)) *
+		math.Pow10(s.rexp)
+	switch s.linearization {
+	case 1:
+		y = math.Log(y)
+	case 2:
+		y = math.Log10(y)
+	case 3:
+		y = math.Log2(y)
+	case 4:
+		y = math.Exp(y)
+	case 5:
+		y = math.Pow(10, y)
+	case 6:
+		y = math.Exp2(y)
+	case 7:
+		y = 1 / y
+	case 8:
+		y = y * y
+	case 9:
+		y = y * y * y
+	case 10:
+		y = math.Sqrt(y)
+	case 11:
+		y = math.Cbrt(y)
+	}
+	return y
+}
+
+// Read the sensor; BMCs only respond for their own, i.e. owner 0x20,
+// sensors, so those of satellite controllers return an error.
+func (s *Sensor) Read(c Conn) (*Reading, error) {
+	if s.Owner != bmcAddr {
+		return nil, fmt.Errorf("%s: owner %#02x: not a BMC sensor",
+			s.Name, s.Owner)
+	}
+	b, err := c.Do(NetFnSensor, CmdGetSensorReading, s.Number)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.Name, err)
+	}
+	if len(b) < 2 {
+		return nil, fmt.Errorf("%s: %d byte reading", s.Name, len(b))
+	}
+	if b[1]&(1<<5) != 0 {
+		return nil, fmt.Errorf("%s: reading unavailable", s.Name)
+	}
+	r := &Reading{Raw: b[0], Status: "ok"}
+	if s.Analog {
+		r.Value = s.Convert(b[0])
+	} else {
+		r.Value = float64(b[0])
+	}
+	if len(b) < 3 {
+		return r, nil
+	}
+	if s.EventType == 0x01 {
+		for _, x := range []struct {
+			bit    uint
+			status string
+		}{
+			{5, "unr"},
+			{2, "lnr"},
+			{4, "ucr"},
+			{1, "lcr"},
+			{3, "unc"},
+			{0, "lnc"},
+		} {
+			if b[2]&(1<<x.bit) != 0 {
+				r.Status = x.status
+				break
+			}
+		}
+	} else {
+		state := uint16(b[2])
+		if len(b) > 3 {
+			state |= uint16(b[3]&0x7f) << 8
+		}
+		r.Status = fmt.Sprintf("%#04x", state)
+	}
+	return r, nil
+}
+
+func signExtend(v, bits int) int {
+	if v&(1<<uint(bits-1)) != 0 {
+		v -= 1 << uint(bits)
+	}
+	return v
+}
@@ -0,0 +1,143 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package ipmi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// SelInfo is the decoded Get SEL Info response.
+type SelInfo struct {
+	Entries   uint16
+	Free      uint16
+	LastAdd   time.Time
+	LastErase time.Time
+}
+
+// SelEntry is a decoded system event log record.
+type SelEntry struct {
+	Id   uint16
+	Type uint8
+	Time time.Time
+	// the remaining fields are those of system event records, type 2
+	Generator  uint16
+	SensorType uint8
+	Sensor     uint8
+	Deassert   bool
+	EventType  uint8
+	Data       [3]byte
+}
+
+func GetSelInfo(c Conn) (*SelInfo, error) {
+	b, err := c.Do(NetFnStorage, CmdGetSelInfo)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 13 {
+		return nil, fmt.Errorf("sel info: %d byte response", len(b))
+	}
+	return &SelInfo{
+		Entries:   binary.LittleEndian.Uint16(b[1:3]),
+		Free:      binary.LittleEndian.Uint16(b[3:5]),
+		LastAdd:   selTime(b[5:9]),
+		LastErase: selTime(b[9:13]),
+	}, nil
+}
+
+// Sel reads all system event log entries.
+func Sel(c Conn) ([]SelEntry, error) {
+	var entries []SelEntry
+	for id := uint16(0); id != 0xffff; {
+		b, err := c.Do(NetFnStorage, CmdGetSelEntry, 0, 0,
+			byte(id), byte(id>>8), 0, 0xff)
+		if err == Error(0xcb) && len(entries) == 0 {
+			// empty
+			return nil, nil
+		}
+		if err != nil {
+			return entries, fmt.Errorf("sel %#04x: %v", id, err)
+		}
+		if len(b) < 2+16 {
+			return entries, fmt.Errorf("sel %#04x: %d byte response",
+				id, len(b))
+		}
+		next := binary.LittleEndian.Uint16(b[:2])
+		entries = append(entries, DecodeSelEntry(b[2:18]))
+		if next == id {
+			break
+		}
+		id = next
+	}
+	return entries, nil
+}
+
+// DecodeSelEntry decodes a 16 byte SEL record.
+func DecodeSelEntry(b []byte) SelEntry {
+	e := SelEntry{
+		Id:   binary.LittleEndian.Uint16(b[0:2]),
+		Type: b[2],
+	}
+	if e.Type >= 0xe0 {
+		// non-timestamped OEM
+		return e
+	}
+	e.Time = selTime(b[3:7])
+	if e.Type != 0x02 {
+		return e
+	}
+	e.Generator = binary.LittleEndian.Uint16(b[7:9])
+	e.SensorType = b[10]
+	e.Sensor = b[11]
+	e.Deassert = b[12]&0x80 != 0
+	e.EventType = b[12] & 0x7f
+	copy(e.Data[:], b[13:16])
+	return e
+}
+
+func (e SelEntry) String() string {
+	t := "pre-init"
+	if !e.Time.IsZero() {
+		t = e.Time.UTC().Format(time.RFC3339)
+	}
+	if e.Type != 0x02 {
+		return fmt.Sprintf("%s OEM record %#02x", t, e.Type)
+	}
+	name, found := SensorTypes[e.SensorType]
+	if !found {
+		name = fmt.Sprintf("sensor type %#02x", e.SensorType)
+	}
+	dir := "asserted"
+	if e.Deassert {
+		dir = "deasserted"
+	}
+	return fmt.Sprintf("%s %s #%#02x event %#02x %s data %x", t, name,
+		e.Sensor, e.Data[0]&0xf, dir, e.Data[:])
+}
+
+// ClearSel erases the system event log.
+func ClearSel(c Conn) error {
+	b, err := c.Do(NetFnStorage, CmdReserveSel)
+	if err != nil {
+		return fmt.Errorf("reserve: %v", err)
+	}
+	if len(b) < 2 {
+		return fmt.Errorf("reserve: empty response")
+	}
+	_, err = c.Do(NetFnStorage, CmdClearSel, b[0], b[1], 'C', 'L', 'R',
+		0xaa)
+	return err
+}
+
+// selTime returns the zero value for the unspecified and pre-init, i.e.
+// before 0x20000000, timestamps.
+func selTime(b []byte) time.Time {
+	ts := binary.LittleEndian.Uint32(b)
+	if ts == 0xffffffff || ts <= 0x20000000 {
+		return time.Time{}
+	}
+	return time.Unix(int64(ts), 0)
+}
@@ -0,0 +1,404 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package led provides named system, fan, PSU, and port LEDs driven through
+// either the /sys/class/leds interface or gpiod pins, per machine.
+package led
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/platinasystems/goes/external/gpiod"
+	"github.com/platinasystems/goes/external/machine"
+)
+
+// Colors of multi-color LEDs; single color LEDs use On.
+const (
+	On    = "on"
+	Green = "green"
+	Amber = "amber"
+	Red   = "red"
+	Blue  = "blue"
+)
+
+// State of an LED. The zero value is off.
+type State struct {
+	Color string
+	Blink bool
+}
+
+var Off State
+
+// ParseState parses "off", "COLOR", or "blink-COLOR".
+func ParseState(s string) (State, error) {
+	var state State
+	if s == "off" {
+		return state, nil
+	}
+	if strings.HasPrefix(s, "blink-") {
+		state.Blink = true
+		s = strings.TrimPrefix(s, "blink-")
+	} else if s == "blink" {
+		state.Blink = true
+		s = On
+	}
+	if len(s) == 0 || strings.ContainsAny(s, " \t.") {
+		return state, fmt.Errorf("%q: invalid state", s)
+	}
+	state.Color = s
+	return state, nil
+}
+
+func (state State) String() string {
+	if len(state.Color) == 0 {
+		return "off"
+	}
+	if state.Blink {
+		return "blink-" + state.Color
+	}
+	return state.Color
+}
+
+// Led drivers
+type Led interface {
+	// Colors lists those supported by Set.
+	Colors() []string
+	Get() (State, error)
+	Set(State) error
+}
+
+// Netdever are those LEDs that the kernel may drive with the link and
+// activity of the given net device.
+type Netdever interface {
+	Netdev(dev string) error
+}
+
+// Root of the sysfs LED class
+var Root = "/sys/class/leds"
+
+// Sysfs maps the colors of an LED to their /sys/class/leds entry, e.g.
+//
+//	led.Sysfs{led.Green: "system:green", led.Amber: "system:amber"}
+type Sysfs map[string]string
+
+func (led Sysfs) Colors() []string { return colors(led) }
+
+func (led Sysfs) Get() (State, error) {
+	for _, color := range led.Colors() {
+		dir := filepath.Join(Root, led[color])
+		if trigger(dir) == "timer" {
+			return State{color, true}, nil
+		}
+		if i, err := readInt(filepath.Join(dir, "brightness")); err != nil {
+			return Off, err
+		} else if i > 0 {
+			return State{color, false}, nil
+		}
+	}
+	return Off, nil
+}
+
+func (led Sysfs) Set(state State) error {
+	if err := supported(led, state); err != nil {
+		return err
+	}
+	// turn off the others first so that it's never multi-colored
+	for _, color := range led.Colors() {
+		if color != state.Color {
+			if err := sysfsOff(filepath.Join(Root, led[color])); err != nil {
+				return err
+			}
+		}
+	}
+	if len(state.Color) == 0 {
+		return nil
+	}
+	dir := filepath.Join(Root, led[state.Color])
+	if state.Blink {
+		return write(dir, "trigger", "timer")
+	}
+	if err := write(dir, "trigger", "none"); err != nil {
+		return err
+	}
+	max, err := readInt(filepath.Join(dir, "max_brightness"))
+	if err != nil {
+		max = 1
+	}
+	return write(dir, "brightness", strconv.Itoa(max))
+}
+
+// Netdev has the kernel drive the LED's green, or only, color with the link
+// and activity of the given net device.
+func (led Sysfs) Netdev(dev string) error {
+	color := Green
+	if _, found := led[color]; !found {
+		colors := led.Colors()
+		if len(colors) == 0 {
+			return fmt.Errorf("no colors")
+		}
+		color = colors[0]
+	}
+	if err := led.Set(Off); err != nil {
+		return err
+	}
+	dir := filepath.Join(Root, led[color])
+	if err := write(dir, "trigger", "netdev"); err != nil {
+		return err
+	}
+	for _, x := range [][2]string{
+		{"device_name", dev},
+		{"link", "1"},
+		{"rx", "1"},
+		{"tx", "1"},
+	} {
+		if err := write(dir, x[0], x[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gpio maps the colors of an LED to their pins. These can't blink.
+type Gpio map[string]gpiod.Pin
+
+func (led Gpio) Colors() []string { return colors(led) }
+
+func (led Gpio) Get() (State, error) {
+	for _, color := range led.Colors() {
+		if v, err := led[color].Value(); err != nil {
+			return Off, err
+		} else if v {
+			return State{color, false}, nil
+		}
+	}
+	return Off, nil
+}
+
+func (led Gpio) Set(state State) error {
+	if err := supported(led, state); err != nil {
+		return err
+	}
+	if state.Blink {
+		return fmt.Errorf("%s: unsupported by gpio", state)
+	}
+	for _, color := range led.Colors() {
+		if color != state.Color {
+			if err := led[color].SetValue(false); err != nil {
+				return err
+			}
+		}
+	}
+	if len(state.Color) == 0 {
+		return nil
+	}
+	return led[state.Color].SetValue(true)
+}
+
+var machineLeds struct {
+	sync.Mutex
+	leds  map[string]Led
+	ports map[string]string
+}
+
+// Register the named LED of the machine. The machine configuration file
+// may override these, e.g.
+//
+//	led:
+//	  leds:
+//	    system:
+//	      sysfs:
+//	        green: system:green
+//	        amber: system:amber
+//	    fan:
+//	      gpio:
+//	        green: pca9535 4
+//	        amber: pca9535 5
+func Register(name string, led Led) {
+	machineLeds.Lock()
+	defer machineLeds.Unlock()
+	if machineLeds.leds == nil {
+		machineLeds.leds = make(map[string]Led)
+	}
+	machineLeds.leds[name] = led
+}
+
+// RegisterPort of the named net device with the named LED; the machine
+// configuration file may override these, e.g.
+//
+//	led:
+//	  ports:
+//	    eth-1-1: port1
+func RegisterPort(dev, name string) {
+	machineLeds.Lock()
+	defer machineLeds.Unlock()
+	if machineLeds.ports == nil {
+		machineLeds.ports = make(map[string]string)
+	}
+	machineLeds.ports[dev] = name
+}
+
+// Leds returns the machine's registered and configured LEDs.
+func Leds() (map[string]Led, error) {
+	cfg := machine.Default()
+	machineLeds.Lock()
+	defer machineLeds.Unlock()
+	leds := make(map[string]Led)
+	for name, led := range machineLeds.leds {
+		leds[name] = led
+	}
+	for _, name := range cfg.Keys("led.leds") {
+		prefix := "led.leds." + name + "."
+		switch {
+		case cfg.Has(prefix + "sysfs"):
+			led := make(Sysfs)
+			for _, color := range cfg.Keys(prefix + "sysfs") {
+				led[color] = cfg.String(prefix+"sysfs."+color, "")
+			}
+			leds[name] = led
+		case cfg.Has(prefix + "gpio"):
+			led := make(Gpio)
+			for _, color := range cfg.Keys(prefix + "gpio") {
+				s := cfg.String(prefix+"gpio."+color, "")
+				pin, err := gpiod.ParsePin(s)
+				if err != nil {
+					return nil, fmt.Errorf("%sgpio.%s: %v",
+						prefix, color, err)
+				}
+				led[color] = pin
+			}
+			leds[name] = led
+		default:
+			return nil, fmt.Errorf("led.leds.%s: missing sysfs or gpio",
+				name)
+		}
+		if len(leds[name].Colors()) == 0 {
+			return nil, fmt.Errorf("led.leds.%s: no colors", name)
+		}
+	}
+	return leds, nil
+}
+
+// Ports returns the machine's LED names by net device.
+func Ports() map[string]string {
+	cfg := machine.Default()
+	machineLeds.Lock()
+	defer machineLeds.Unlock()
+	ports := make(map[string]string)
+	for dev, name := range machineLeds.ports {
+		ports[dev] = name
+	}
+	for _, dev := range cfg.Keys("led.ports") {
+		ports[dev] = cfg.String("led.ports."+dev, "")
+	}
+	return ports
+}
+
+// Set the named LED; it's an error if the machine doesn't have it.
+func Set(name string, state State) error {
+	leds, err := Leds()
+	if err != nil {
+		return err
+	}
+	led, found := leds[name]
+	if !found {
+		return fmt.Errorf("%s: no such LED", name)
+	}
+	return led.Set(state)
+}
+
+// Health is the daemons' hook to show the health of the named LED's
+// subsystem as green if ok, otherwise, amber; single color LEDs are on if ok,
+// otherwise, off. It ignores those that the machine doesn't have.
+func Health(name string, ok bool) error {
+	leds, err := Leds()
+	if err != nil {
+		return err
+	}
+	led, found := leds[name]
+	if !found {
+		return nil
+	}
+	state := State{Color: Green}
+	if !ok {
+		state.Color = Amber
+	}
+	if supported(led, state) != nil {
+		state = Off
+		if ok {
+			state.Color = On
+		}
+		if supported(led, state) != nil {
+			return nil
+		}
+	}
+	return led.Set(state)
+}
+
+func colors(m interface{}) []string {
+	var l []string
+	switch t := m.(type) {
+	case Sysfs:
+		for color := range t {
+			l = append(l, color)
+		}
+	case Gpio:
+		for color := range t {
+			l = append(l, color)
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+func supported(led Led, state State) error {
+	if len(state.Color) == 0 {
+		return nil
+	}
+	for _, color := range led.Colors() {
+		if color == state.Color {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: unsupported color", state.Color)
+}
+
+// trigger returns the bracketed, current trigger of the sysfs LED.
+func trigger(dir string) string {
+	b, err := ioutil.ReadFile(filepath.Join(dir, "trigger"))
+	if err != nil {
+		return ""
+	}
+	s := string(b)
+	if i := strings.Index(s, "["); i >= 0 {
+		if j := strings.Index(s[i:], "]"); j > 0 {
+			return s[i+1 : i+j]
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+func sysfsOff(dir string) error {
+	if err := write(dir, "trigger", "none"); err != nil {
+		return err
+	}
+	return write(dir, "brightness", "0")
+}
+
+func readInt(fn string) (int, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+func write(dir, attr, s string) error {
+	return ioutil.WriteFile(filepath.Join(dir, attr), []byte(s), 0644)
+}
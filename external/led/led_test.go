@@ -0,0 +1,67 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package led
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseState(t *testing.T) {
+	for s, want := range map[string]State{
+		"off":         Off,
+		"green":       {Green, false},
+		"blink-amber": {Amber, true},
+		"blink":       {On, true},
+	} {
+		state, err := ParseState(s)
+		if err != nil {
+			t.Error(s, ":", err)
+		} else if state != want {
+			t.Errorf("%s: %v vs. %v", s, state, want)
+		} else if state.String() != s && s != "blink" {
+			t.Errorf("%s: %q string", s, state)
+		}
+	}
+	if _, err := ParseState(""); err == nil {
+		t.Error("empty state parsed")
+	}
+}
+
+func TestSysfs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "led")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(root string) { Root = root }(Root)
+	Root = dir
+	led := Sysfs{Green: "sys:green", Amber: "sys:amber"}
+	for _, name := range led {
+		os.Mkdir(filepath.Join(dir, name), 0755)
+		ioutil.WriteFile(filepath.Join(dir, name, "max_brightness"),
+			[]byte("255\n"), 0644)
+	}
+	for _, state := range []State{
+		{Green, false},
+		{Amber, false},
+		{Green, true},
+		Off,
+	} {
+		if err = led.Set(state); err != nil {
+			t.Fatal(state, ":", err)
+		}
+		if got, err := led.Get(); err != nil {
+			t.Fatal(err)
+		} else if got != state {
+			t.Errorf("%v vs. %v", got, state)
+		}
+	}
+	if err = led.Set(State{Red, false}); err == nil {
+		t.Error("set unsupported color")
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build !windows
+
+// Package syslog has the priorities of log/syslog, or their equivalent
+// where that isn't available, e.g. windows.
+package syslog
+
+import "log/syslog"
+
+type Priority = syslog.Priority
+
+const (
+	LOG_EMERG   = syslog.LOG_EMERG
+	LOG_ALERT   = syslog.LOG_ALERT
+	LOG_CRIT    = syslog.LOG_CRIT
+	LOG_ERR     = syslog.LOG_ERR
+	LOG_WARNING = syslog.LOG_WARNING
+	LOG_NOTICE  = syslog.LOG_NOTICE
+	LOG_INFO    = syslog.LOG_INFO
+	LOG_DEBUG   = syslog.LOG_DEBUG
+
+	LOG_KERN     = syslog.LOG_KERN
+	LOG_USER     = syslog.LOG_USER
+	LOG_MAIL     = syslog.LOG_MAIL
+	LOG_DAEMON   = syslog.LOG_DAEMON
+	LOG_AUTH     = syslog.LOG_AUTH
+	LOG_SYSLOG   = syslog.LOG_SYSLOG
+	LOG_LPR      = syslog.LOG_LPR
+	LOG_NEWS     = syslog.LOG_NEWS
+	LOG_UUCP     = syslog.LOG_UUCP
+	LOG_CRON     = syslog.LOG_CRON
+	LOG_AUTHPRIV = syslog.LOG_AUTHPRIV
+	LOG_FTP      = syslog.LOG_FTP
+	LOG_LOCAL0   = syslog.LOG_LOCAL0
+	LOG_LOCAL1   = syslog.LOG_LOCAL1
+	LOG_LOCAL2   = syslog.LOG_LOCAL2
+	LOG_LOCAL3   = syslog.LOG_LOCAL3
+	LOG_LOCAL4   = syslog.LOG_LOCAL4
+	LOG_LOCAL5   = syslog.LOG_LOCAL5
+	LOG_LOCAL6   = syslog.LOG_LOCAL6
+	LOG_LOCAL7   = syslog.LOG_LOCAL7
+)
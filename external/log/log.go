@@ -11,7 +11,6 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"log/syslog"
 	"net"
 	"os"
 	"path/filepath"
@@ -20,6 +19,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/platinasystems/goes/external/log/internal/syslog"
 )
 
 const DevKmsg = "/dev/kmsg"
@@ -178,6 +179,11 @@ func LinesFrom(rc io.ReadCloser, id, priority string) {
 	}
 	scan := bufio.NewScanner(rc)
 	for scan.Scan() {
+		ship(pri|syslog.LOG_DAEMON, id, &Record{
+			Time:     time.Now(),
+			Priority: pri,
+			Msg:      scan.Text(),
+		})
 		log(pri|syslog.LOG_DAEMON, id, scan.Text())
 	}
 }
@@ -190,7 +196,7 @@ func LinesFrom(rc io.ReadCloser, id, priority string) {
 //	Print("err", ...)
 func Print(args ...interface{}) {
 	pri, fac, a := logArgs(args...)
-	log(pri|fac, id(), fmt.Sprint(a...))
+	emit(pri|fac, Subsys(), fmt.Sprint(a...), nil)
 }
 
 // The default level is: Debug, User. Upto the first two arguments may preceed
@@ -211,7 +217,7 @@ func Printf(args ...interface{}) {
 		return
 	}
 	a = a[1:]
-	log(pri|fac, id(), fmt.Sprintf(format, a...))
+	emit(pri|fac, Subsys(), fmt.Sprintf(format, a...), nil)
 }
 
 var cache struct {
@@ -6,6 +6,7 @@ package log
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"testing"
 	"time"
@@ -103,3 +104,37 @@ func (s want) results(t *testing.T) {
 		os.Stdout.WriteString(got)
 	}
 }
+
+func TestLogger(t *testing.T) {
+	defer expect(`
+<30>log.test[6789]: port up ifname=eth-1-1 speed=100g
+<27>log.test[6789]: lag member="eth-1-1 eth-2-1" err="no such device"
+<28>log.test[6789]: fand warning
+`[1:]).results(t)
+	defer ResetLevel("")
+	defer ResetLevel("vnetd")
+
+	l := New("vnetd").With("ifname", "eth-1-1")
+	l.Info("port up", "speed", "100g")
+	SetLevel("", PriorityByName["warn"])
+	SetLevel("vnetd", PriorityByName["err"])
+	l.Warn("dropped by the vnetd level")
+	New("lagd").Err("lag", "member", "eth-1-1 eth-2-1",
+		"err", errors.New("no such device"))
+	New("fand").Info("dropped by the default level")
+	New("fand").Warn("fand warning")
+}
+
+func TestJSONEncoder(t *testing.T) {
+	r := &Record{
+		Time:     time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Priority: PriorityByName["info"],
+		Subsys:   "vnetd",
+		Msg:      "multi-\nline",
+		Fields:   []interface{}{"n", 3, "d", time.Second, "odd"},
+	}
+	want := `{"time":"2020-01-02T03:04:05Z","level":"info","subsys":"vnetd","msg":"multi-\nline","n":3,"d":"1s","odd":null}`
+	if got := JSONEncoder(r); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
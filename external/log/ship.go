@@ -0,0 +1,81 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/goes/external/log/internal/syslog"
+)
+
+// ShipSocket is the abstract unix datagram socket of logshipd that forwards
+// the records to remote syslog servers.
+const ShipSocket = "@logshipd"
+
+// Shipped is the JSON datagram of a record sent to logshipd.
+type Shipped struct {
+	// Priority includes the facility
+	Priority syslog.Priority `json:"pri"`
+	Time     time.Time       `json:"time"`
+	// ID is PROG[PID]
+	ID     string `json:"id"`
+	Subsys string `json:"subsys,omitempty"`
+	Msg    string `json:"msg"`
+	// Fields are alternating keys and printed values
+	Fields []string `json:"fields,omitempty"`
+}
+
+// without logshipd, retry its socket at this interval
+const shipRetry = 5 * time.Second
+
+var shipper struct {
+	sync.Mutex
+	conn  net.Conn
+	retry time.Time
+}
+
+// ship the record to logshipd, if it's running.
+func ship(pri syslog.Priority, id string, r *Record) {
+	if tee.exclusive {
+		return
+	}
+	x := Shipped{
+		Priority: pri,
+		Time:     r.Time,
+		ID:       id,
+		Subsys:   r.Subsys,
+		Msg:      r.Msg,
+	}
+	for i := 0; i < len(r.Fields); i += 2 {
+		key, v := field(r.Fields, i)
+		x.Fields = append(x.Fields, key, fmt.Sprint(v))
+	}
+	b, err := json.Marshal(&x)
+	if err != nil {
+		return
+	}
+	shipper.Lock()
+	defer shipper.Unlock()
+	if shipper.conn == nil {
+		if time.Now().Before(shipper.retry) {
+			return
+		}
+		shipper.conn, err = net.Dial("unixgram", ShipSocket)
+		if err != nil {
+			shipper.conn = nil
+			shipper.retry = time.Now().Add(shipRetry)
+			return
+		}
+	}
+	if _, err = shipper.conn.Write(b); err != nil {
+		shipper.conn.Close()
+		shipper.conn = nil
+		shipper.retry = time.Now().Add(shipRetry)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/platinasystems/goes/external/log/internal/syslog"
+)
+
+// Record of a structured log message
+type Record struct {
+	Time     time.Time
+	Priority syslog.Priority
+	Subsys   string
+	Msg      string
+	// Fields are alternating keys and values
+	Fields []interface{}
+}
+
+// Encoder formats the record as one or more lines.
+type Encoder func(*Record) string
+
+// Encoders by name; the default is text.
+var Encoders = map[string]Encoder{
+	"text": TextEncoder,
+	"json": JSONEncoder,
+}
+
+// Logger of a subsystem, e.g. a daemon, with levelled methods of a message
+// and alternating keys and values like this,
+//
+//	logger := log.New("vnetd")
+//	logger.Info("port up", "ifname", "eth-1-1", "speed", "100g")
+//
+// The facility of these messages is "daemon".
+type Logger struct {
+	subsys   string
+	facility syslog.Priority
+	fields   []interface{}
+}
+
+var config = struct {
+	sync.RWMutex
+	subsys  string
+	encoder Encoder
+	format  string
+	levels  map[string]syslog.Priority
+}{
+	encoder: TextEncoder,
+	format:  "text",
+	levels:  make(map[string]syslog.Priority),
+}
+
+// Audit logs the notices of commands and sessions with the "priv" facility.
+var Audit = &Logger{subsys: "audit", facility: syslog.LOG_AUTHPRIV}
+
+// New returns a logger of the subsystem.
+func New(subsys string) *Logger {
+	return &Logger{subsys: subsys, facility: syslog.LOG_DAEMON}
+}
+
+// With returns a copy of the logger that adds the given keys and values to
+// each message.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	x := *l
+	x.fields = append(append([]interface{}{}, l.fields...), kv...)
+	return &x
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) {
+	l.Log(syslog.LOG_DEBUG, msg, kv...)
+}
+
+func (l *Logger) Info(msg string, kv ...interface{}) {
+	l.Log(syslog.LOG_INFO, msg, kv...)
+}
+
+func (l *Logger) Note(msg string, kv ...interface{}) {
+	l.Log(syslog.LOG_NOTICE, msg, kv...)
+}
+
+func (l *Logger) Warn(msg string, kv ...interface{}) {
+	l.Log(syslog.LOG_WARNING, msg, kv...)
+}
+
+func (l *Logger) Err(msg string, kv ...interface{}) {
+	l.Log(syslog.LOG_ERR, msg, kv...)
+}
+
+func (l *Logger) Crit(msg string, kv ...interface{}) {
+	l.Log(syslog.LOG_CRIT, msg, kv...)
+}
+
+// Enabled returns true if messages of the priority aren't filtered by the
+// level of the logger's subsystem.
+func (l *Logger) Enabled(pri syslog.Priority) bool {
+	return pri&PriorityMask <= Level(l.subsys)
+}
+
+// Log the message at the given priority.
+func (l *Logger) Log(pri syslog.Priority, msg string, kv ...interface{}) {
+	fields := l.fields
+	if len(kv) > 0 {
+		fields = append(append([]interface{}{}, fields...), kv...)
+	}
+	emit(pri&PriorityMask|l.facility, l.subsys, msg, fields)
+}
+
+// SetSubsys sets the subsystem of the unstructured Print and Printf
+// messages; this is the daemon name in daemons.
+func SetSubsys(subsys string) {
+	config.Lock()
+	defer config.Unlock()
+	config.subsys = subsys
+}
+
+// Subsys returns the subsystem of Print and Printf.
+func Subsys() string {
+	config.RLock()
+	defer config.RUnlock()
+	return config.subsys
+}
+
+// SetLevel of the subsystem, or with "", the default of all subsystems.
+// Messages of lower priority, i.e. greater value, are dropped. The default
+// is debug, so all are logged.
+func SetLevel(subsys string, pri syslog.Priority) {
+	config.Lock()
+	defer config.Unlock()
+	config.levels[subsys] = pri & PriorityMask
+}
+
+// ResetLevel of the subsystem to the default.
+func ResetLevel(subsys string) {
+	config.Lock()
+	defer config.Unlock()
+	delete(config.levels, subsys)
+}
+
+// Level returns that of the subsystem or the default.
+func Level(subsys string) syslog.Priority {
+	config.RLock()
+	defer config.RUnlock()
+	if pri, found := config.levels[subsys]; found {
+		return pri
+	}
+	if pri, found := config.levels[""]; found {
+		return pri
+	}
+	return syslog.LOG_DEBUG
+}
+
+// ParseLevel returns the priority of the name, e.g. "info".
+func ParseLevel(name string) (syslog.Priority, error) {
+	if pri, found := PriorityByName[name]; found {
+		return pri, nil
+	}
+	return 0, fmt.Errorf("%q: unknown level", name)
+}
+
+// SetFormat of the encoder by name, text or json.
+func SetFormat(name string) error {
+	encoder, found := Encoders[name]
+	if !found {
+		return fmt.Errorf("%q: unknown format", name)
+	}
+	config.Lock()
+	defer config.Unlock()
+	config.encoder = encoder
+	config.format = name
+	return nil
+}
+
+// Format returns the name of the current encoder.
+func Format() string {
+	config.RLock()
+	defer config.RUnlock()
+	return config.format
+}
+
+func emit(pri syslog.Priority, subsys, msg string, fields []interface{}) {
+	if pri&PriorityMask > Level(subsys) {
+		return
+	}
+	config.RLock()
+	encoder := config.encoder
+	config.RUnlock()
+	r := &Record{
+		Time:     time.Now(),
+		Priority: pri & PriorityMask,
+		Subsys:   subsys,
+		Msg:      msg,
+		Fields:   fields,
+	}
+	ship(pri, id(), r)
+	log(pri, id(), encoder(r))
+}
+
+// TextEncoder formats the message followed by key=value pairs with quoted
+// values as necessary.
+func TextEncoder(r *Record) string {
+	if len(r.Fields) == 0 {
+		return r.Msg
+	}
+	buf := new(strings.Builder)
+	buf.WriteString(r.Msg)
+	for i := 0; i < len(r.Fields); i += 2 {
+		key, v := field(r.Fields, i)
+		s := fmt.Sprint(v)
+		if len(s) == 0 || strings.ContainsAny(s, " \t\n\"=") {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprint(buf, " ", key, "=", s)
+	}
+	return buf.String()
+}
+
+// JSONEncoder formats an object of the time, level, subsys, msg, then the
+// fields in order.
+func JSONEncoder(r *Record) string {
+	buf := new(strings.Builder)
+	add := func(key string, v interface{}) {
+		switch t := v.(type) {
+		case error:
+			v = t.Error()
+		case fmt.Stringer:
+			v = t.String()
+		case json.Marshaler, nil, bool, string,
+			int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64,
+			float32, float64, []string:
+		default:
+			v = fmt.Sprint(t)
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			b, _ = json.Marshal(fmt.Sprint(v))
+		}
+		if buf.Len() == 0 {
+			buf.WriteString("{")
+		} else {
+			buf.WriteString(",")
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteString(":")
+		buf.Write(b)
+	}
+	add("time", r.Time.Format(time.RFC3339Nano))
+	add("level", LogPriorityByValue[r.Priority])
+	if len(r.Subsys) > 0 {
+		add("subsys", r.Subsys)
+	}
+	add("msg", r.Msg)
+	for i := 0; i < len(r.Fields); i += 2 {
+		add(field(r.Fields, i))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// field returns the i'th key and value; a missing value is null and a
+// key that isn't a string is printed.
+func field(kv []interface{}, i int) (string, interface{}) {
+	key, ok := kv[i].(string)
+	if !ok {
+		key = fmt.Sprint(kv[i])
+	}
+	if i+1 < len(kv) {
+		return key, kv[i+1]
+	}
+	return key, nil
+}
@@ -0,0 +1,424 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package machine loads the field configuration of a goes machine from
+// /etc/goes/machine.yaml. Machines compile in their defaults then have each
+// command and daemon override these with the loaded configuration, e.g.
+//
+//	redisd:
+//	  devs: [eth0, eth1]
+//	  port: 6379
+//	daemons:
+//	  vnetd:
+//	    restart: always
+//	    after: [redisd]
+//	start:
+//	  gettys:
+//	    - tty: /dev/ttyS0
+//	      baud: 115200
+//
+// The file is a subset of YAML with indented maps, "- " prefaced or
+// bracketed lists, quoted or plain scalars, and '#' prefaced comments.
+// Values are addressed by dot separated paths, e.g.
+//
+//	machine.Default().Strings("redisd.devs")
+//	machine.Default().String("start.gettys.0.tty")
+package machine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const EtcGoesMachine = "/etc/goes/machine.yaml"
+
+// Config is a tree of map[string]interface{}, []interface{}, and string
+// nodes.
+type Config struct {
+	root interface{}
+}
+
+var defaultConfig struct {
+	once sync.Once
+	cfg  *Config
+	err  error
+}
+
+// Default returns the configuration loaded from EtcGoesMachine, or an empty
+// configuration if the file doesn't exist or has errors.
+func Default() *Config {
+	defaultConfig.once.Do(func() {
+		defaultConfig.cfg, defaultConfig.err = Load(EtcGoesMachine)
+		if defaultConfig.err != nil {
+			defaultConfig.cfg = &Config{}
+			if os.IsNotExist(defaultConfig.err) {
+				defaultConfig.err = nil
+			}
+		}
+	})
+	return defaultConfig.cfg
+}
+
+// Err returns the error, if any, of the Default load.
+func Err() error {
+	Default()
+	return defaultConfig.err
+}
+
+// Load the configuration from the named file.
+func Load(fn string) (*Config, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cfg, err := Parse(f)
+	if err != nil {
+		err = fmt.Errorf("%s:%v", fn, err)
+	}
+	return cfg, err
+}
+
+type line struct {
+	n      int
+	indent int
+	text   string
+}
+
+// Parse the configuration from the given reader.
+func Parse(r io.Reader) (*Config, error) {
+	var lines []line
+	scan := bufio.NewScanner(r)
+	for n := 1; scan.Scan(); n++ {
+		s := strings.TrimRight(uncomment(scan.Text()), " \t")
+		t := strings.TrimLeft(s, " ")
+		if len(t) == 0 {
+			continue
+		}
+		if strings.HasPrefix(t, "\t") {
+			return nil, fmt.Errorf("%d: tab indentation", n)
+		}
+		if t == "---" {
+			continue
+		}
+		lines = append(lines, line{n, len(s) - len(t), t})
+	}
+	if err := scan.Err(); err != nil {
+		return nil, err
+	}
+	p := &parser{lines: lines}
+	if len(lines) == 0 {
+		return &Config{root: map[string]interface{}{}}, nil
+	}
+	root, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.i < len(p.lines) {
+		return nil, fmt.Errorf("%d: unexpected indentation",
+			p.lines[p.i].n)
+	}
+	return &Config{root: root}, nil
+}
+
+type parser struct {
+	lines []line
+	i     int
+}
+
+func (p *parser) block(indent int) (interface{}, error) {
+	if strings.HasPrefix(p.lines[p.i].text, "-") &&
+		(len(p.lines[p.i].text) == 1 || p.lines[p.i].text[1] == ' ') {
+		return p.list(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *parser) list(indent int) (interface{}, error) {
+	var l []interface{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		ln := &p.lines[p.i]
+		if !strings.HasPrefix(ln.text, "-") {
+			return nil, fmt.Errorf("%d: expected list item", ln.n)
+		}
+		item := strings.TrimLeft(ln.text[1:], " ")
+		if len(item) == 0 {
+			p.i++
+			if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+				v, err := p.block(p.lines[p.i].indent)
+				if err != nil {
+					return nil, err
+				}
+				l = append(l, v)
+			} else {
+				l = append(l, "")
+			}
+			continue
+		}
+		if _, _, isKey := splitKey(item); isKey {
+			// reparse the item as the first line of an indented map
+			ln.indent += len(ln.text) - len(item)
+			ln.text = item
+			v, err := p.mapping(ln.indent)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, v)
+			continue
+		}
+		l = append(l, scalar(item))
+		p.i++
+	}
+	return l, nil
+}
+
+func (p *parser) mapping(indent int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		ln := p.lines[p.i]
+		k, v, isKey := splitKey(ln.text)
+		if !isKey {
+			return nil, fmt.Errorf("%d: expected KEY: VALUE", ln.n)
+		}
+		if _, found := m[k]; found {
+			return nil, fmt.Errorf("%d: duplicate %q", ln.n, k)
+		}
+		p.i++
+		switch {
+		case len(v) > 0:
+			m[k] = value(v)
+		case p.i < len(p.lines) && p.lines[p.i].indent > indent:
+			child, err := p.block(p.lines[p.i].indent)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = child
+		case p.i < len(p.lines) && p.lines[p.i].indent == indent &&
+			strings.HasPrefix(p.lines[p.i].text, "- "):
+			// a list may have the same indentation as its key
+			child, err := p.list(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = child
+		default:
+			m[k] = ""
+		}
+	}
+	return m, nil
+}
+
+// splitKey returns the KEY and VALUE of a "KEY: VALUE" or "KEY:" line.
+func splitKey(s string) (k, v string, isKey bool) {
+	if len(s) == 0 || s[0] == '"' || s[0] == '\'' || s[0] == '[' {
+		return
+	}
+	i := strings.Index(s, ": ")
+	if i < 0 {
+		if !strings.HasSuffix(s, ":") {
+			return
+		}
+		i = len(s) - 1
+	}
+	k = strings.TrimSpace(s[:i])
+	v = strings.TrimSpace(s[i+1:])
+	return k, v, len(k) > 0
+}
+
+func value(s string) interface{} {
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		var l []interface{}
+		for _, item := range strings.Split(s[1:len(s)-1], ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				l = append(l, scalar(item))
+			}
+		}
+		return l
+	}
+	return scalar(s)
+}
+
+func scalar(s string) string {
+	if len(s) > 1 {
+		switch s[0] {
+		case '"':
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		case '\'':
+			if s[len(s)-1] == '\'' {
+				return strings.Replace(s[1:len(s)-1], "''", "'",
+					-1)
+			}
+		}
+	}
+	return s
+}
+
+// uncomment strips a trailing, whitespace prefaced '#' comment that isn't
+// within quotes.
+func uncomment(s string) string {
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '[' ||
+				s[i-1] == ',' {
+				quote = r
+			}
+		case r == '#':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '\t' {
+				return s[:i]
+			}
+		}
+	}
+	return s
+}
+
+// Lookup returns the node at the dot separated path.
+func (c *Config) Lookup(path string) (interface{}, bool) {
+	if c == nil || c.root == nil {
+		return nil, false
+	}
+	node := c.root
+	if len(path) == 0 {
+		return node, true
+	}
+	for _, k := range strings.Split(path, ".") {
+		switch t := node.(type) {
+		case map[string]interface{}:
+			v, found := t[k]
+			if !found {
+				return nil, false
+			}
+			node = v
+		case []interface{}:
+			i, err := strconv.Atoi(k)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false
+			}
+			node = t[i]
+		default:
+			return nil, false
+		}
+	}
+	return node, true
+}
+
+// Has returns true if the configuration includes the given path.
+func (c *Config) Has(path string) bool {
+	_, found := c.Lookup(path)
+	return found
+}
+
+// Keys returns the sorted keys of a map or the indices of a list.
+func (c *Config) Keys(path string) []string {
+	node, _ := c.Lookup(path)
+	var keys []string
+	switch t := node.(type) {
+	case map[string]interface{}:
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	case []interface{}:
+		for i := range t {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	}
+	return keys
+}
+
+// String returns the scalar at path or def if absent.
+func (c *Config) String(path, def string) string {
+	if s, ok := c.scalar(path); ok {
+		return s
+	}
+	return def
+}
+
+// Strings returns the list of scalars at path, a scalar split by white
+// space, or def if absent.
+func (c *Config) Strings(path string, def []string) []string {
+	node, found := c.Lookup(path)
+	if !found {
+		return def
+	}
+	switch t := node.(type) {
+	case string:
+		return strings.Fields(t)
+	case []interface{}:
+		l := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				l = append(l, s)
+			}
+		}
+		return l
+	}
+	return def
+}
+
+// Int returns the integer at path, def if absent, or an error if invalid.
+func (c *Config) Int(path string, def int) (int, error) {
+	s, ok := c.scalar(path)
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(s, 0, 0)
+	if err != nil {
+		return def, fmt.Errorf("%s: %q isn't an integer", path, s)
+	}
+	return int(i), nil
+}
+
+// Bool returns the boolean at path, def if absent, or an error if invalid.
+func (c *Config) Bool(path string, def bool) (bool, error) {
+	s, ok := c.scalar(path)
+	if !ok {
+		return def, nil
+	}
+	switch strings.ToLower(s) {
+	case "true", "yes", "on", "1":
+		return true, nil
+	case "false", "no", "off", "0":
+		return false, nil
+	}
+	return def, fmt.Errorf("%s: %q isn't a boolean", path, s)
+}
+
+// Duration returns the time.ParseDuration of the scalar at path, def if
+// absent, or an error if invalid.
+func (c *Config) Duration(path string, def time.Duration) (time.Duration,
+	error) {
+	s, ok := c.scalar(path)
+	if !ok {
+		return def, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def, fmt.Errorf("%s: %q isn't a duration", path, s)
+	}
+	return d, nil
+}
+
+func (c *Config) scalar(path string) (string, bool) {
+	node, found := c.Lookup(path)
+	if !found {
+		return "", false
+	}
+	s, ok := node.(string)
+	return s, ok
+}
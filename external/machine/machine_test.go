@@ -0,0 +1,82 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package machine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const example = `
+# example machine configuration
+redisd:
+  devs: [eth0, "eth1"]   # listening interfaces
+  port: 6380
+daemons:
+  vnetd:
+    restart: always
+    backoff: 2s
+    after:
+      - redisd
+start:
+  gettys:
+  - tty: /dev/ttyS0
+    baud: 115200
+  - tty: /dev/ttyS1
+    baud: 9600
+hostname: 'sw#1'
+`
+
+func TestParse(t *testing.T) {
+	cfg, err := Parse(strings.NewReader(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Strings("redisd.devs", nil); !reflect.DeepEqual(got,
+		[]string{"eth0", "eth1"}) {
+		t.Error("wrong devs:", got)
+	}
+	if got, err := cfg.Int("redisd.port", 6379); err != nil || got != 6380 {
+		t.Error("wrong port:", got, err)
+	}
+	if got := cfg.String("daemons.vnetd.restart", ""); got != "always" {
+		t.Error("wrong restart:", got)
+	}
+	if got, err := cfg.Duration("daemons.vnetd.backoff", 0); err != nil ||
+		got != 2*time.Second {
+		t.Error("wrong backoff:", got, err)
+	}
+	if got := cfg.Strings("daemons.vnetd.after", nil); !reflect.DeepEqual(
+		got, []string{"redisd"}) {
+		t.Error("wrong after:", got)
+	}
+	if got := cfg.Keys("start.gettys"); !reflect.DeepEqual(got,
+		[]string{"0", "1"}) {
+		t.Error("wrong gettys:", got)
+	}
+	if got := cfg.String("start.gettys.1.tty", ""); got != "/dev/ttyS1" {
+		t.Error("wrong tty:", got)
+	}
+	if got := cfg.String("hostname", ""); got != "sw#1" {
+		t.Error("wrong hostname:", got)
+	}
+	if got := cfg.String("missing.key", "default"); got != "default" {
+		t.Error("wrong default:", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, s := range []string{
+		"a: 1\na: 2\n",
+		"a:\n  b: 1\n c: 2\n",
+		"just a scalar\n",
+	} {
+		if _, err := Parse(strings.NewReader(s)); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
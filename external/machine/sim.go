@@ -0,0 +1,59 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package machine
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// SimEnv names the fixture file of a simulated machine, e.g. that given to
+// "goes -sim FIXTURE", that its commands and daemons inherit. The fixture
+// has the same format as the machine configuration, e.g.
+//
+//	i2c:
+//	  0:
+//	    0x51:
+//	      type: eeprom
+//	      file: eeprom.bin
+//	    0x2f:
+//	      data: "01 02 03"
+//	hwmon:
+//	  coretemp:
+//	    temp1_input: 45000
+//	    temp1_label: Core 0
+//
+// Each layer backed by a mock provider, e.g. i2c, reads its section.
+const SimEnv = "GOES_SIM"
+
+var simConfig struct {
+	once sync.Once
+	cfg  *Config
+	err  error
+}
+
+// Sim returns the fixture of a simulated machine, or nil if SimEnv isn't
+// set, along with the error, if any, of its load.
+func Sim() (*Config, error) {
+	simConfig.once.Do(func() {
+		if fn := os.Getenv(SimEnv); len(fn) > 0 {
+			simConfig.cfg, simConfig.err = Load(fn)
+			if simConfig.err != nil {
+				simConfig.cfg = &Config{}
+			}
+		}
+	})
+	return simConfig.cfg, simConfig.err
+}
+
+// SimPath returns the named file of a fixture relative to its directory,
+// unless absolute.
+func SimPath(fn string) string {
+	if filepath.IsAbs(fn) {
+		return fn
+	}
+	return filepath.Join(filepath.Dir(os.Getenv(SimEnv)), fn)
+}
@@ -43,6 +43,9 @@ type Aliases map[string]string
 //	parm.ByName["-b"] == ""
 //	parm.ByName["-c"] == "blue"
 //	args == []string{}
+//
+// A parameter or alias of more than one letter may also be given as a GNU
+// style long option, e.g. "--color=blue" or "--color blue" for "-color".
 func New(args []string, parms ...interface{}) (*Parms, []string) {
 	p := &Parms{
 		ByName:  make(ByName),
@@ -74,7 +77,8 @@ func (p *Parms) More(args []string, parms ...interface{}) []string {
 func (p *Parms) Parse(args []string) []string {
 	for i := 0; i < len(args); {
 		if eq := strings.Index(args[i], "="); eq > 0 {
-			if p.Set(args[i][:eq], args[i][eq+1:]) == nil {
+			k, found := p.lookup(args[i][:eq])
+			if found && p.Set(k, args[i][eq+1:]) == nil {
 				if i < len(args)-1 {
 					copy(args[i:], args[i+1:])
 				}
@@ -83,11 +87,8 @@ func (p *Parms) Parse(args []string) []string {
 				i++
 			}
 		} else if i < len(args)-1 {
-			k, found := p.aliases[args[i]]
-			if !found {
-				k = args[i]
-			}
-			if p.Set(k, args[i+1]) == nil {
+			k, found := p.lookup(args[i])
+			if found && p.Set(k, args[i+1]) == nil {
 				copy(args[i:], args[i+2:])
 				args = args[:len(args)-2]
 			} else {
@@ -100,6 +101,26 @@ func (p *Parms) Parse(args []string) []string {
 	return args
 }
 
+// lookup returns the parameter of the name or its alias; a GNU style long
+// option, "--NAME", is also that of "-NAME" and vice versa.
+func (p *Parms) lookup(name string) (string, bool) {
+	names := []string{name}
+	if strings.HasPrefix(name, "--") && len(name) > 3 {
+		names = append(names, name[1:])
+	} else if strings.HasPrefix(name, "-") && len(name) > 2 {
+		names = append(names, "-"+name)
+	}
+	for _, name := range names {
+		if k, found := p.aliases[name]; found {
+			return k, true
+		}
+		if _, found := p.ByName[name]; found {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 // Set will concatenate a non empty parmeter.
 func (p *Parms) Set(name, value string) error {
 	cur, found := p.ByName[name]
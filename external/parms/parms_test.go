@@ -149,3 +149,46 @@ func TestKexecBug(t *testing.T) {
 		t.Error("wrong:", args)
 	}
 }
+
+func TestLongOption(t *testing.T) {
+	cmd := []string{"foo", "--level=5", "--color", "blue", "-colour=red"}
+	p, args := New(cmd, "-level", []string{"-c", "-color", "-colour"})
+	if !reflect.DeepEqual(p.ByName, ByName{
+		"-level": "5",
+		"-c":     "blue red",
+	}) {
+		t.Error("wrong:", p.ByName)
+	}
+	if !reflect.DeepEqual(args, []string{"foo"}) {
+		t.Error("wrong:", args)
+	}
+}
+
+func TestTyped(t *testing.T) {
+	p, _ := New([]string{"-count=abc", "-n=0x10", "-t=1.5", "-ip=10.0.0.1",
+		"-mac=bogus", "-mode=fast"},
+		"-count", "-n", "-t", "-ip", "-mac", "-mode", "-empty")
+	if _, err := p.Int("-count", 1); err == nil ||
+		err.Error() != "-count: 'abc' is not an integer" {
+		t.Error("wrong:", err)
+	}
+	if n, err := p.Uint("-n", 0); err != nil || n != 16 {
+		t.Error("wrong:", n, err)
+	}
+	if d, err := p.Duration("-t", 0); err != nil || d.Seconds() != 1.5 {
+		t.Error("wrong:", d, err)
+	}
+	if ip, err := p.IP("-ip"); err != nil || ip.String() != "10.0.0.1" {
+		t.Error("wrong:", ip, err)
+	}
+	if _, err := p.MAC("-mac"); err == nil {
+		t.Error("wrong: no error")
+	}
+	if _, err := p.OneOf("-mode", "slow", "medium"); err == nil ||
+		err.Error() != "-mode: 'fast' is not one of slow, medium" {
+		t.Error("wrong:", err)
+	}
+	if i, err := p.Int("-empty", 7); err != nil || i != 7 {
+		t.Error("wrong:", i, err)
+	}
+}
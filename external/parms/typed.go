@@ -0,0 +1,111 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package parms
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error of a parameter value that isn't of its type, e.g.
+//
+//	-count: 'abc' is not an integer
+type Error struct {
+	Name, Value, Want string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: '%s' is not %s", err.Name, err.Value, err.Want)
+}
+
+// Int returns the signed integer of the named parameter, or def if empty.
+// Like strconv.ParseInt with base 0, the value may have a 0x, 0o, or 0b
+// prefix.
+func (p *Parms) Int(name string, def int) (int, error) {
+	s := p.ByName[name]
+	if len(s) == 0 {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(s, 0, 0)
+	if err != nil {
+		return def, &Error{name, s, "an integer"}
+	}
+	return int(i), nil
+}
+
+// Uint returns the unsigned integer of the named parameter, or def if empty.
+func (p *Parms) Uint(name string, def uint64) (uint64, error) {
+	s := p.ByName[name]
+	if len(s) == 0 {
+		return def, nil
+	}
+	u, err := strconv.ParseUint(s, 0, 64)
+	if err != nil {
+		return def, &Error{name, s, "an unsigned integer"}
+	}
+	return u, nil
+}
+
+// Duration returns the time.Duration of the named parameter, or def if
+// empty; a value without units, e.g. "30", is of seconds.
+func (p *Parms) Duration(name string, def time.Duration) (time.Duration,
+	error) {
+	s := p.ByName[name]
+	if len(s) == 0 {
+		return def, nil
+	}
+	if sec, err := strconv.ParseFloat(s, 64); err == nil {
+		return time.Duration(sec * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return def, &Error{name, s, "a duration"}
+	}
+	return d, nil
+}
+
+// IP returns the address of the named parameter, or nil if empty.
+func (p *Parms) IP(name string) (net.IP, error) {
+	s := p.ByName[name]
+	if len(s) == 0 {
+		return nil, nil
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, &Error{name, s, "an IP address"}
+	}
+	return ip, nil
+}
+
+// MAC returns the hardware address of the named parameter, or nil if empty.
+func (p *Parms) MAC(name string) (net.HardwareAddr, error) {
+	s := p.ByName[name]
+	if len(s) == 0 {
+		return nil, nil
+	}
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		return nil, &Error{name, s, "a MAC address"}
+	}
+	return mac, nil
+}
+
+// OneOf returns the named parameter if it's one of the given values, or the
+// first of these if empty.
+func (p *Parms) OneOf(name string, values ...string) (string, error) {
+	s := p.ByName[name]
+	if len(s) == 0 && len(values) > 0 {
+		return values[0], nil
+	}
+	for _, v := range values {
+		if s == v {
+			return s, nil
+		}
+	}
+	return "", &Error{name, s, "one of " + strings.Join(values, ", ")}
+}
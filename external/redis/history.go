@@ -0,0 +1,67 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package redis
+
+import (
+	"strconv"
+	"time"
+)
+
+// Sample of a field's history retained by redisd.
+type Sample struct {
+	Time  time.Time
+	Value float64
+}
+
+// HistoryFields returns the default hash fields sampled by redisd.
+func HistoryFields() (fields []string, err error) {
+	conn, err := Connect()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	ret, err := conn.Do("HISTORY")
+	if ret != nil && err == nil {
+		vs := ret.([]interface{})
+		fields = make([]string, 0, len(vs))
+		for _, v := range vs {
+			fields = append(fields, vstring(v))
+		}
+	}
+	return
+}
+
+// History returns the samples of the field for the given duration, or all
+// those retained if zero, oldest first.
+func History(field string, d time.Duration) (samples []Sample, err error) {
+	conn, err := Connect()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	args := []interface{}{field}
+	if d > 0 {
+		args = append(args, d.String())
+	}
+	ret, err := conn.Do("HISTORY", args...)
+	if ret == nil || err != nil {
+		return
+	}
+	vs := ret.([]interface{})
+	samples = make([]Sample, 0, len(vs)/2)
+	for i := 0; i+1 < len(vs); i += 2 {
+		var x Sample
+		x.Time, err = time.Parse(time.RFC3339Nano, vstring(vs[i]))
+		if err != nil {
+			return
+		}
+		x.Value, err = strconv.ParseFloat(vstring(vs[i+1]), 64)
+		if err != nil {
+			return
+		}
+		samples = append(samples, x)
+	}
+	return
+}
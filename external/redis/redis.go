@@ -10,6 +10,7 @@ import (
 	"io"
 	"net"
 	"net/rpc"
+	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -24,6 +25,11 @@ const rdtimeout = 10 * time.Second
 const wrtimeout = 500 * time.Millisecond
 
 var DefaultHash string
+
+// Addr, if set, e.g. from $GOES_REDIS, is the HOST[:PORT] of a remote redisd
+// rather than the local abstract socket; this is how the client build of an
+// operator's workstation reaches a switch.
+var Addr = os.Getenv("GOES_REDIS")
 var keyRe *regexp.Regexp
 var empty = struct{}{}
 
@@ -103,6 +109,13 @@ func NewRedisRegAtSock() (*rpc.Client, error) {
 }
 
 func NewRedisdAtSock() (net.Conn, error) {
+	if len(Addr) > 0 {
+		addr := Addr
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "6379")
+		}
+		return net.DialTimeout("tcp", addr, rdtimeout)
+	}
 	return atsock.Dial("redisd")
 }
 
@@ -197,6 +210,32 @@ func Hget(key, field string) (s string, err error) {
 	return
 }
 
+// Hgetall returns the fields of the hash that have the given prefix, or all
+// fields if the prefix is empty.
+func Hgetall(key, prefix string) (m map[string]string, err error) {
+	if len(key) == 0 {
+		key = DefaultHash
+	}
+	conn, err := Connect()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	ret, err := conn.Do("HGETALL", key)
+	if err != nil {
+		return
+	}
+	m = make(map[string]string)
+	vs, _ := ret.([]interface{})
+	for i := 0; i+1 < len(vs); i += 2 {
+		field := vstring(vs[i])
+		if strings.HasPrefix(field, prefix) {
+			m[field] = vstring(vs[i+1])
+		}
+	}
+	return
+}
+
 func Hkeys(key string) (keys []string, err error) {
 	if len(key) == 0 {
 		key = DefaultHash
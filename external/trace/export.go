@@ -0,0 +1,230 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// spans ended beyond this many pending are dropped
+	maxQueue = 2048
+	// export at this many pending spans or interval, whichever is first
+	maxBatch = 512
+	interval = 5 * time.Second
+	timeout  = 5 * time.Second
+)
+
+var exporter struct {
+	sync.Mutex
+	once    sync.Once
+	pending []*Span
+	dropped int
+	wake    chan struct{}
+	client  http.Client
+}
+
+func queue(s *Span) {
+	exporter.once.Do(func() {
+		exporter.wake = make(chan struct{}, 1)
+		exporter.client.Timeout = timeout
+		go export()
+	})
+	exporter.Lock()
+	if len(exporter.pending) >= maxQueue {
+		exporter.dropped++
+		exporter.Unlock()
+		return
+	}
+	exporter.pending = append(exporter.pending, s)
+	n := len(exporter.pending)
+	exporter.Unlock()
+	if n >= maxBatch {
+		select {
+		case exporter.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func export() {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+		case <-exporter.wake:
+		}
+		Flush()
+	}
+}
+
+// Flush exports the pending spans; commands call this before exit.
+func Flush() error {
+	exporter.Lock()
+	spans := exporter.pending
+	exporter.pending = nil
+	dropped := exporter.dropped
+	exporter.dropped = 0
+	exporter.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+	b, err := Encode(spans, dropped)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", config.endpoint,
+		bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range config.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := exporter.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", config.endpoint, resp.Status)
+	}
+	return nil
+}
+
+type attr struct {
+	Key   string `json:"key"`
+	Value value  `json:"value"`
+}
+
+type value struct {
+	String *string  `json:"stringValue,omitempty"`
+	Bool   *bool    `json:"boolValue,omitempty"`
+	Int    *string  `json:"intValue,omitempty"`
+	Double *float64 `json:"doubleValue,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type span struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId,omitempty"`
+	Name         string `json:"name"`
+	Kind         Kind   `json:"kind"`
+	Start        string `json:"startTimeUnixNano"`
+	End          string `json:"endTimeUnixNano"`
+	Attributes   []attr `json:"attributes,omitempty"`
+	Status       status `json:"status"`
+}
+
+type scopeSpans struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type resourceSpans struct {
+	Resource struct {
+		Attributes []attr `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+// Encode the spans as an OTLP ExportTraceServiceRequest in JSON.
+func Encode(spans []*Span, dropped int) ([]byte, error) {
+	var rs resourceSpans
+	hostname, _ := os.Hostname()
+	rs.Resource.Attributes = attrs("service.name", config.service,
+		"host.name", hostname,
+		"process.pid", os.Getpid(),
+		"process.executable.name", filepath.Base(os.Args[0]))
+	if dropped > 0 {
+		rs.Resource.Attributes = append(rs.Resource.Attributes,
+			attrs("goes.trace.dropped", dropped)...)
+	}
+	ss := scopeSpans{Spans: make([]span, 0, len(spans))}
+	ss.Scope.Name = "github.com/platinasystems/goes"
+	for _, s := range spans {
+		s.mutex.Lock()
+		x := span{
+			TraceID: hex.EncodeToString(s.traceID[:]),
+			SpanID:  hex.EncodeToString(s.id[:]),
+			Name:    s.name,
+			Kind:    s.kind,
+			Start:   strconv.FormatInt(s.start.UnixNano(), 10),
+			End:     strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		if s.parentID != [8]byte{} {
+			x.ParentSpanID = hex.EncodeToString(s.parentID[:])
+		}
+		x.Attributes = attrs(s.attrs...)
+		if s.err != nil {
+			x.Status = status{Code: 2, Message: s.err.Error()}
+		}
+		s.mutex.Unlock()
+		ss.Spans = append(ss.Spans, x)
+	}
+	rs.ScopeSpans = []scopeSpans{ss}
+	return json.Marshal(struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}{[]resourceSpans{rs}})
+}
+
+func attrs(kv ...interface{}) []attr {
+	var l []attr
+	for i := 0; i < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		var v interface{} = "(MISSING)"
+		if i+1 < len(kv) {
+			v = kv[i+1]
+		}
+		var x value
+		switch t := v.(type) {
+		case string:
+			x.String = &t
+		case bool:
+			x.Bool = &t
+		case int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64:
+			s := fmt.Sprint(t)
+			x.Int = &s
+		case float32:
+			f := float64(t)
+			x.Double = &f
+		case float64:
+			x.Double = &t
+		case error:
+			s := t.Error()
+			x.String = &s
+		default:
+			s := fmt.Sprint(t)
+			x.String = &s
+		}
+		l = append(l, attr{key, x})
+	}
+	return l
+}
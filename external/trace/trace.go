@@ -0,0 +1,231 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package trace provides OpenTelemetry spans exported with OTLP/HTTP JSON to
+// the collector of these standard environment variables,
+//
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT	e.g. http://collector:4318/v1/traces
+//	OTEL_EXPORTER_OTLP_ENDPOINT		e.g. http://collector:4318
+//	OTEL_EXPORTER_OTLP_HEADERS		e.g. authorization=Bearer TOKEN
+//	OTEL_SERVICE_NAME			default: goes
+//	OTEL_TRACES_SAMPLER_ARG			root sampling ratio, default: 1
+//
+// Without an endpoint, Start returns a nil Span and the methods of a nil Span
+// do nothing. A process started with a TRACEPARENT, the W3C trace context
+// of its parent span, continues that trace.
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	mrand "math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Env is the variable of the W3C trace context inherited by child processes.
+const Env = "TRACEPARENT"
+
+// Kind of span
+type Kind int
+
+const (
+	Internal Kind = 1
+	Server   Kind = 2
+	Client   Kind = 3
+)
+
+// Span of a traced operation
+type Span struct {
+	mutex    sync.Mutex
+	name     string
+	kind     Kind
+	traceID  [16]byte
+	id       [8]byte
+	parentID [8]byte
+	start    time.Time
+	end      time.Time
+	attrs    []interface{}
+	err      error
+	ended    bool
+}
+
+type remote struct {
+	traceID [16]byte
+	id      [8]byte
+	sampled bool
+}
+
+var config struct {
+	once     sync.Once
+	endpoint string
+	headers  map[string]string
+	service  string
+	ratio    float64
+	parent   *remote
+}
+
+func configure() {
+	config.once.Do(func() {
+		config.endpoint = os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+		if len(config.endpoint) == 0 {
+			s := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+			if len(s) > 0 {
+				config.endpoint = strings.TrimSuffix(s, "/") +
+					"/v1/traces"
+			}
+		}
+		config.headers = make(map[string]string)
+		for _, s := range strings.Split(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"), ",") {
+			if i := strings.Index(s, "="); i > 0 {
+				config.headers[strings.TrimSpace(s[:i])] =
+					strings.TrimSpace(s[i+1:])
+			}
+		}
+		config.service = os.Getenv("OTEL_SERVICE_NAME")
+		if len(config.service) == 0 {
+			config.service = "goes"
+		}
+		config.ratio = 1
+		if s := os.Getenv("OTEL_TRACES_SAMPLER_ARG"); len(s) > 0 {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				config.ratio = f
+			}
+		}
+		config.parent = parseTraceparent(os.Getenv(Env))
+	})
+}
+
+var root struct {
+	sync.Mutex
+	span *Span
+}
+
+// SetRoot sets the parent of spans started without one, e.g. those of RPC
+// clients, to that of the process' command; this returns the previous root.
+func SetRoot(s *Span) *Span {
+	root.Lock()
+	defer root.Unlock()
+	prev := root.span
+	root.span = s
+	return prev
+}
+
+// Enabled returns true if spans are exported.
+func Enabled() bool {
+	configure()
+	return len(config.endpoint) > 0
+}
+
+// parseTraceparent returns the remote parent of the W3C trace context,
+// VERSION-TRACEID-SPANID-FLAGS, or nil if invalid.
+func parseTraceparent(s string) *remote {
+	parts := strings.Split(s, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 ||
+		len(parts[3]) != 2 {
+		return nil
+	}
+	p := new(remote)
+	if _, err := hex.Decode(p.traceID[:], []byte(parts[1])); err != nil {
+		return nil
+	}
+	if _, err := hex.Decode(p.id[:], []byte(parts[2])); err != nil {
+		return nil
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || p.traceID == [16]byte{} || p.id == [8]byte{} {
+		return nil
+	}
+	p.sampled = flags&1 != 0
+	return p
+}
+
+// Start an internal span of the parent, or with a nil parent, that of the
+// root, the process' TRACEPARENT, or a new trace. The optional arguments are
+// alternating attribute keys and values.
+func Start(parent *Span, name string, kv ...interface{}) *Span {
+	return StartKind(parent, Internal, name, kv...)
+}
+
+// StartKind starts a span of the given kind.
+func StartKind(parent *Span, kind Kind, name string,
+	kv ...interface{}) *Span {
+	if !Enabled() {
+		return nil
+	}
+	s := &Span{
+		name:  name,
+		kind:  kind,
+		start: time.Now(),
+		attrs: kv,
+	}
+	if parent == nil {
+		root.Lock()
+		parent = root.span
+		root.Unlock()
+	}
+	switch {
+	case parent != nil:
+		s.traceID, s.parentID = parent.traceID, parent.id
+	case config.parent != nil:
+		if !config.parent.sampled {
+			return nil
+		}
+		s.traceID, s.parentID = config.parent.traceID, config.parent.id
+	default:
+		if config.ratio < 1 && mrand.Float64() >= config.ratio {
+			return nil
+		}
+		rand.Read(s.traceID[:])
+	}
+	rand.Read(s.id[:])
+	return s
+}
+
+// Set attributes of alternating keys and values.
+func (s *Span) Set(kv ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.attrs = append(s.attrs, kv...)
+}
+
+// End the span, with an error status if err isn't nil, then queue it for
+// export.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	if s.ended {
+		s.mutex.Unlock()
+		return
+	}
+	s.ended, s.end, s.err = true, time.Now(), err
+	s.mutex.Unlock()
+	queue(s)
+}
+
+// Traceparent returns the W3C trace context of the span.
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%x-%x-01", s.traceID, s.id)
+}
+
+// Environ returns os.Environ with the TRACEPARENT of the span, if any.
+func (s *Span) Environ() []string {
+	env := os.Environ()
+	if s != nil {
+		env = append(env, Env+"="+s.Traceparent())
+	}
+	return env
+}
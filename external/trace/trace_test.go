@@ -0,0 +1,82 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package trace
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTraceparent(t *testing.T) {
+	const s = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	p := parseTraceparent(s)
+	if p == nil || !p.sampled {
+		t.Fatal("not parsed")
+	}
+	x := &Span{traceID: p.traceID, id: p.id}
+	if got := x.Traceparent(); got != s {
+		t.Error("got", got)
+	}
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bx-01",
+	} {
+		if parseTraceparent(bad) != nil {
+			t.Error("parsed", bad)
+		}
+	}
+	if p = parseTraceparent(strings.TrimSuffix(s, "1") + "0"); p.sampled {
+		t.Error("sampled")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	var nilSpan *Span
+	nilSpan.Set("ignored", true)
+	nilSpan.End(nil)
+	s := &Span{
+		name:     "ip",
+		kind:     Client,
+		traceID:  [16]byte{1},
+		id:       [8]byte{2},
+		parentID: [8]byte{3},
+		start:    time.Unix(1, 0),
+		end:      time.Unix(2, 0),
+		attrs:    []interface{}{"args", "link show", "n", 3},
+		err:      errors.New("failed"),
+	}
+	b, err := Encode([]*Span{s}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var x struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []span
+			}
+		}
+	}
+	if err = json.Unmarshal(b, &x); err != nil {
+		t.Fatal(err)
+	}
+	got := x.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if got.TraceID != "01000000000000000000000000000000" ||
+		got.SpanID != "0200000000000000" ||
+		got.ParentSpanID != "0300000000000000" ||
+		got.Kind != Client ||
+		got.Start != "1000000000" ||
+		got.Status.Code != 2 || got.Status.Message != "failed" {
+		t.Errorf("%+v", got)
+	}
+	if len(got.Attributes) != 2 || *got.Attributes[0].Value.String !=
+		"link show" || *got.Attributes[1].Value.Int != "3" {
+		t.Errorf("%s", b)
+	}
+}
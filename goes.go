@@ -2,8 +2,6 @@
 // Use of this source code is governed by the GPL-2 license described in the
 // LICENSE file.
 
-// +build linux
-
 // Package goes, combined with a compatibly configured Linux kernel, provides a
 // monolithic embedded system.
 package goes
@@ -17,18 +15,23 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"unicode/utf8"
 
 	"github.com/platinasystems/goes/cmd"
 	"github.com/platinasystems/goes/external/flags"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/machine"
 	"github.com/platinasystems/goes/external/parms"
+	"github.com/platinasystems/goes/external/trace"
 	"github.com/platinasystems/goes/internal/prog"
 	"github.com/platinasystems/goes/internal/shellutils"
+	"github.com/platinasystems/goes/internal/url"
 	"github.com/platinasystems/goes/lang"
-	"github.com/platinasystems/url"
 )
 
 const (
@@ -58,19 +61,52 @@ type Goes struct {
 
 	ByName map[string]cmd.Cmd
 
-	Catline io.ReadWriter
+	// Terminal, if set, is the input of the cli and the continuation
+	// lines of its blocks and here documents; otherwise, these are read
+	// from the goes standard input.
+	Terminal Terminal
 
-	Status    error
 	Verbosity int
 
+	// Stats, if set, has the cli report the resource usage of each of
+	// its command lines.
+	Stats bool
+
 	cache  cache
 	parent *Goes
 
-	EnvMap map[string]string
+	ctx     *Context
+	ctxInit sync.Once
+
+	pipe     *PipeTerminal
+	pipeInit sync.Once
 
-	FunctionMap map[string]Function
+	// Stdin, Stdout, Stderr, and Environ, if set, are used rather than
+	// those of the process by the cli, pipelines, redirections, and
+	// forked commands of this and its sub-goes, e.g. when embedded in
+	// another program. Commands that run in this process, e.g. those of
+	// the DontFork kind, still use the process's standard I/O.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	Environ        []string
 
 	inTest bool
+
+	// program name given to Run rather than os.Args[0]
+	argv0 string
+
+	stop     chan struct{}
+	stopInit sync.Once
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	// span of the running command or pipeline
+	span *trace.Span
+
+	// background jobs of the cli and, in the goes of one, that job
+	table     *jobTable
+	tableInit sync.Once
+	job       *Job
 }
 
 type Function struct {
@@ -80,8 +116,8 @@ type Function struct {
 }
 
 /*
-All goes go-routines should add them selves to the WG WaitGroup and quit on
-Stop like this,
+The go-routines of daemons should add them selves to the WG WaitGroup and quit
+on Stop like this,
 
 	goes.WG.Add(1)
 	go func() {
@@ -95,12 +131,136 @@ Stop like this,
 			}
 		}
 	}
+
+Stop is the Done channel of the goes running the process's daemon; others
+should instead use the Go, Done, and Wait methods of their goes.
 */
 var (
 	Stop chan struct{}
 	WG   sync.WaitGroup
 )
 
+// Run is like Main but with the given arguments, standard I/O, and
+// environment rather than those of the process; nil stdio or env are that
+// of the process.
+func (g *Goes) Run(argv []string, stdin io.Reader, stdout, stderr io.Writer,
+	env []string) error {
+	if len(argv) > 0 {
+		g.argv0 = argv[0]
+	}
+	g.Stdin, g.Stdout, g.Stderr, g.Environ = stdin, stdout, stderr, env
+	return g.Main(argv...)
+}
+
+// Done returns a channel that's closed when the goes, or that of its root,
+// is stopped by Stop, SIGTERM, or, in the foreground, interrupt.
+func (g *Goes) Done() <-chan struct{} { return g.done() }
+
+func (g *Goes) done() chan struct{} {
+	r := g.root()
+	r.stopInit.Do(func() { r.stop = make(chan struct{}) })
+	return r.stop
+}
+
+// Stop closes the Done channel of the goes and its sub-goes.
+func (g *Goes) Stop() {
+	stop := g.done()
+	r := g.root()
+	r.stopOnce.Do(func() { close(stop) })
+}
+
+// Go runs f in a go-routine that Wait waits for.
+func (g *Goes) Go(f func()) {
+	r := g.root()
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		f()
+	}()
+}
+
+// Wait for the go-routines of Go.
+func (g *Goes) Wait() { g.root().wg.Wait() }
+
+// Getenv returns the value of the variable set by the cli, or that of the
+// Environ, or process, environment.
+func (g *Goes) Getenv(k string) string { return g.getenv(g.Context(), k) }
+
+func (g *Goes) getenv(ctx *Context, k string) string {
+	if v, def := ctx.LookupEnv(k); def {
+		return v
+	}
+	if env := g.environ(); env != nil {
+		for i := len(env) - 1; i >= 0; i-- {
+			if strings.HasPrefix(env[i], k+"=") {
+				return env[i][len(k)+1:]
+			}
+		}
+		return ""
+	}
+	return os.Getenv(k)
+}
+
+func (g *Goes) root() *Goes {
+	for g.parent != nil {
+		g = g.parent
+	}
+	return g
+}
+
+// environ returns a copy of the first Environ of this or a parent goes, or
+// nil if none has one.
+func (g *Goes) environ() []string {
+	for p := g; p != nil; p = p.parent {
+		if p.Environ != nil {
+			return append([]string{}, p.Environ...)
+		}
+	}
+	return nil
+}
+
+func (g *Goes) arg0() string {
+	for p := g; p != nil; p = p.parent {
+		if len(p.argv0) > 0 {
+			return p.argv0
+		}
+	}
+	return os.Args[0]
+}
+
+func (g *Goes) stdin() io.Reader {
+	for p := g; p != nil; p = p.parent {
+		if p.Stdin != nil {
+			return p.Stdin
+		}
+	}
+	return os.Stdin
+}
+
+func (g *Goes) stdout() io.Writer {
+	for p := g; p != nil; p = p.parent {
+		if p.Stdout != nil {
+			return p.Stdout
+		}
+	}
+	return os.Stdout
+}
+
+func (g *Goes) stderr() io.Writer {
+	for p := g; p != nil; p = p.parent {
+		if p.Stderr != nil {
+			return p.Stderr
+		}
+	}
+	return os.Stderr
+}
+
+// Stdio returns the standard I/O of the goes, or that of its parent, or the
+// process, e.g. for the cli.
+func (g *Goes) Stdio() (io.Reader, io.Writer, io.Writer) {
+	return g.stdin(), g.stdout(), g.stderr()
+}
+
 func (g *Goes) ProcessPipeline(ls shellutils.List) (*shellutils.List, *shellutils.Word, func(io.Reader, io.Writer, io.Writer) error, error) {
 	var (
 		closers []io.Closer
@@ -108,6 +268,7 @@ func (g *Goes) ProcessPipeline(ls shellutils.List) (*shellutils.List, *shellutil
 	)
 	isLast := false
 	pipeline := make([]func(io.Reader, io.Writer, io.Writer) error, 0)
+	command := pipelineString(ls)
 	for len(ls.Cmds) != 0 && !isLast {
 		cl := ls.Cmds[0]
 		term = cl.Term
@@ -148,37 +309,53 @@ func (g *Goes) ProcessPipeline(ls shellutils.List) (*shellutils.List, *shellutil
 	}
 
 	pipefun, err := g.MakePipefun(pipeline, &closers)
+	if err == nil && trace.Enabled() {
+		run := pipefun
+		pipefun = func(stdin io.Reader, stdout, stderr io.Writer) error {
+			parent := g.currentSpan()
+			g.span = trace.Start(parent, "pipeline", "command", command)
+			err := run(stdin, stdout, stderr)
+			g.span.End(err)
+			g.span = parent
+			return err
+		}
+	}
 	return &ls, &term, pipefun, err
 }
 
-func (g *Goes) isStdinRedirected(stdin io.Reader) bool {
-	if f, ok := stdin.(*os.File); ok {
-		if f == os.Stdin {
-			return false
+// pipelineString returns the commands of the list upto the first
+// terminator other than "|".
+func pipelineString(ls shellutils.List) string {
+	for i, cl := range ls.Cmds {
+		if cl.Term.String() != "|" {
+			ls.Cmds = ls.Cmds[:i+1]
+			break
 		}
-		return true
 	}
-	return true
+	return ls.String()
 }
 
-func (g *Goes) isStdoutRedirected(stdout io.Writer) bool {
-	if f, ok := stdout.(*os.File); ok {
-		if f == os.Stdout {
-			return false
+// currentSpan returns the span of the innermost running command or pipeline
+// of this or a parent goes.
+func (g *Goes) currentSpan() *trace.Span {
+	for p := g; p != nil; p = p.parent {
+		if p.span != nil {
+			return p.span
 		}
-		return true
 	}
-	return true
+	return nil
+}
+
+func (g *Goes) isStdinRedirected(stdin io.Reader) bool {
+	return stdin != g.stdin()
+}
+
+func (g *Goes) isStdoutRedirected(stdout io.Writer) bool {
+	return stdout != g.stdout()
 }
 
 func (g *Goes) isStderrRedirected(stderr io.Writer) bool {
-	if f, ok := stderr.(*os.File); ok {
-		if f == os.Stderr {
-			return false
-		}
-		return true
-	}
-	return true
+	return stderr != g.stderr()
 }
 
 func (g *Goes) isRedirected(stdin io.Reader, stdout io.Writer, stderr io.Writer) bool {
@@ -187,41 +364,39 @@ func (g *Goes) isRedirected(stdin io.Reader, stdout io.Writer, stderr io.Writer)
 }
 
 func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error, error) {
+	// the pipeline keeps the context in which it was made
+	ctx := g.Context()
+	job := g.job
 	runfun := func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error {
-		envMap, args := cl.Slice(func(k string) string {
-			v, def := g.EnvMap[k]
-			if def {
-				return v
-			}
-			return os.Getenv(k)
+		envMap, args, err := cl.Slice(func(k string) string {
+			return g.getenv(ctx, k)
 		})
+		if err != nil {
+			return ctx.SetStatus(err)
+		}
 		// Add to our context environment if this command only set variables
 		if len(args) == 0 {
 			if len(envMap) != 0 {
-				if g.EnvMap == nil {
-					g.EnvMap = envMap
-				} else {
-					for k, v := range envMap {
-						g.EnvMap[k] = v
-					}
+				for k, v := range envMap {
+					ctx.Setenv(k, v)
 				}
-				g.Status = nil // Successfully set variables
+				ctx.SetStatus(nil) // Successfully set variables
 			}
 			return nil
 		}
 		name := args[0]
 		// check for function invocation
 
-		if f, x := g.FunctionMap[name]; x {
+		if f, x := ctx.Function(name); x {
 			return f.RunFun(stdin, stdout, stderr)
 		}
 		// check for built in command
 		if v := g.ByName[name]; v != nil {
 			k := cmd.WhatKind(v)
-			if k.IsDaemon() {
+			if k.IsDaemon() && !isForeground(args[1:]) {
 				return fmt.Errorf(
-					"use `goes-daemons start %s`",
-					name)
+					"use `goes-daemons start %s` or `%s -fg`",
+					name, name)
 			}
 			if g.isRedirected(stdin, stdout, stderr) {
 				if k.IsCantPipe() {
@@ -229,7 +404,7 @@ func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func
 				}
 			}
 			if k.IsDontFork() || g.inTest ||
-				name == os.Args[0] {
+				name == g.arg0() {
 				if method, found := v.(goeser); found {
 					method.Goes(g)
 				}
@@ -265,16 +440,11 @@ func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func
 				}
 				in = r
 				*closers = append(*closers, r)
-				WG.Add(1)
-				go func(w io.WriteCloser, lbl string) {
-					defer WG.Done()
+				g.Go(func() {
 					defer w.Close()
 					prompt := "<<" + fn + " "
 					for {
-						g.Catline.Write([]byte(prompt))
-						buf := make([]byte, 1024)
-						n, err := g.Catline.Read(buf)
-						s := string(buf[0:n])
+						s, err := g.ReadLine(prompt)
 						if err != nil || s == lbl {
 							break
 						}
@@ -283,7 +453,7 @@ func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func
 						}
 						fmt.Fprintln(w, s)
 					}
-				}(w, lbl)
+				})
 			}
 		}
 		out := stdout
@@ -309,14 +479,14 @@ func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func
 				if err != nil {
 					return err
 				}
-				out = io.MultiWriter(os.Stdout, wc)
+				out = io.MultiWriter(g.stdout(), wc)
 				*closers = append(*closers, wc)
 			} else if fn := oparm.ByName[">>"]; len(fn) > 0 {
 				wc, err := url.Append(fn)
 				if err != nil {
 					return err
 				}
-				out = io.MultiWriter(os.Stdout, wc)
+				out = io.MultiWriter(g.stdout(), wc)
 				*closers = append(*closers, wc)
 			}
 		}
@@ -332,7 +502,9 @@ func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func
 		}
 		x := g.Fork(args...)
 		if len(envStr) != 0 {
-			x.Env = os.Environ()
+			if x.Env == nil {
+				x.Env = os.Environ()
+			}
 			for _, s := range envStr {
 				x.Env = append(x.Env, s)
 			}
@@ -340,40 +512,43 @@ func (g *Goes) ProcessCommand(cl shellutils.Cmdline, closers *[]io.Closer) (func
 		x.Stdin = in
 		x.Stdout = out
 		x.Stderr = stderr
+		if job != nil {
+			setpgid(x)
+		}
 
 		if err := x.Start(); err != nil {
 			err = fmt.Errorf("child: %v: %v", x.Args, err)
 			return err
 		}
+		if job != nil {
+			job.started(x.Process)
+		}
 		if !g.isStdoutRedirected(stdout) { // fixme not a pipe
-			err := x.Wait()
-			g.Status = err
+			err := ctx.SetStatus(x.Wait())
 			if err != nil &&
 				err.Error() != "exit status 1" {
-				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(g.stderr(), err)
 			}
 		} else {
-			WG.Add(1)
-			go func(x *exec.Cmd) {
-				defer WG.Done()
+			g.Go(func() {
 				err := x.Wait()
 				if err != nil &&
 					err.Error() != "exit status 1" {
-					fmt.Fprintln(os.Stderr, err)
+					fmt.Fprintln(g.stderr(), err)
 				}
-				if x.Stdout != os.Stdout {
+				if x.Stdout != g.stdout() {
 					m, found := x.Stdout.(io.Closer)
 					if found {
 						m.Close()
 					}
 				}
-				if x.Stdin != os.Stdin {
+				if x.Stdin != g.stdin() {
 					m, found := x.Stdin.(io.Closer)
 					if found {
 						m.Close()
 					}
 				}
-			}(x)
+			})
 		}
 		return nil
 	}
@@ -434,15 +609,28 @@ func (g *Goes) Goes(parent *Goes) {
 // given args.
 func (g *Goes) Fork(args ...string) *exec.Cmd {
 	if g.Verbosity >= VerboseDebug {
-		fmt.Printf("F*$=%v %v\n", g.Status, args)
+		fmt.Printf("F*$=%v %v\n", g.Status(), args)
 	}
 	a := append(g.Path(), args...)
 	x := prog.Command(a...)
+	atomic.AddUint64(&forked, 1)
+	x.Env = g.environ()
+	if span := g.currentSpan(); span != nil {
+		// continue the trace in the child
+		if x.Env == nil {
+			x.Env = os.Environ()
+		}
+		x.Env = append(x.Env, trace.Env+"="+span.Traceparent())
+	}
 	return x
 }
 
 // Run a command in the current context.
 //
+// The args may begin with the program name, e.g. os.Args; if this is the
+// link of a command name, like a busybox applet, the program runs that
+// command.
+//
 // If len(args) == 1 and args[0] doesn't match a mapped command, this will run
 // the "cli".
 //
@@ -452,9 +640,10 @@ func (g *Goes) Fork(args ...string) *exec.Cmd {
 // Similarly for "-apropos", "-complete", "-man", and "-usage".
 //
 // If the command is a daemon, this fork exec's itself twice to disassociate
-// the daemon from the tty and initiating process.
+// the daemon from the tty and initiating process; unless run with "-fg" to
+// remain in the foreground with debug verbosity, stderr logging, and stop on
+// interrupt.
 func (g *Goes) Main(args ...string) error {
-	Stop = make(chan struct{})
 	if strings.HasSuffix(os.Args[0], ".test") {
 		g.inTest = true
 	} else if len(args) > 0 {
@@ -467,6 +656,9 @@ func (g *Goes) Main(args ...string) error {
 	}
 	if len(args) > 0 {
 		base := filepath.Base(args[0])
+		if runtime.GOOS == "windows" {
+			base = strings.TrimSuffix(base, ".exe")
+		}
 		switch {
 		case g.NAME == "goes-installer":
 			if len(args) == 1 {
@@ -479,8 +671,32 @@ func (g *Goes) Main(args ...string) error {
 			fallthrough
 		case base == "goes":
 			args = args[1:]
+		case args[0] == g.arg0():
+			// e.g. /usr/bin/ls linked to goes by install-links
+			if _, found := g.ByName[base]; found {
+				args[0] = base
+			}
 		}
 	}
+	if len(args) > 1 && args[0] == "-sim" {
+		// e.g. goes -sim ci/fixture.yaml goes-daemons
+		fn, err := filepath.Abs(args[1])
+		if err != nil {
+			return g.SetStatus(err)
+		}
+		os.Setenv(machine.SimEnv, fn)
+		args = args[2:]
+	}
+	if len(args) > 0 && args[0] == "-stats" {
+		// e.g. goes -stats show version
+		g.Stats = true
+		args = args[1:]
+		name := strings.Join(args, " ")
+		if len(name) == 0 {
+			name = "cli"
+		}
+		defer g.ReportStats(g.stderr(), name, GetRusage())
+	}
 
 	var v cmd.Cmd
 	var k cmd.Kind
@@ -496,7 +712,9 @@ func (g *Goes) Main(args ...string) error {
 		if clifound {
 			cli.(goeser).Goes(g)
 		}
-		cliFlags, cliArgs := flags.New(args, "-debug", "-f", "-no-liner", "-x")
+		cliFlags, cliArgs := flags.New(args, "-debug", "-f", "-i",
+			"-no-liner", "-x")
+		cliParms, cliArgs := parms.New(cliArgs, "-remote")
 		if cliFlags.ByName["-debug"] && g.Verbosity < VerboseDebug {
 			g.Verbosity = VerboseDebug
 		}
@@ -508,15 +726,15 @@ func (g *Goes) Main(args ...string) error {
 				if cliFlags.ByName["-x"] {
 					cliArgs = append(cliArgs, "-x")
 				}
-				g.Status = cli.Main(cliArgs...)
-				return g.Status
+				if host := cliParms.ByName["-remote"]; len(host) > 0 {
+					cliArgs = append(cliArgs, "-remote", host)
+				}
+				return g.SetStatus(cli.Main(cliArgs...))
 			} else if def, found := g.ByName[""]; found {
-				g.Status = def.Main()
-				return g.Status
+				return g.SetStatus(def.Main())
 			}
-			fmt.Println(Usage(g))
-			g.Status = nil
-			return nil
+			fmt.Fprintln(g.stdout(), Usage(g))
+			return g.SetStatus(nil)
 		} else if n == 1 {
 			// only check for script if args[0] isn't a command
 			buf, err := ioutil.ReadFile(cliArgs[0])
@@ -524,16 +742,14 @@ func (g *Goes) Main(args ...string) error {
 				bytes.HasPrefix(buf, []byte("#!/usr/bin/goes"))) {
 				// e.g. /usr/bin/goes SCRIPT
 				if cli == nil {
-					g.Status = fmt.Errorf("has no cli")
-					return g.Status
+					return g.SetStatus(fmt.Errorf("has no cli"))
 				}
-				for _, t := range []string{"-f", "-x"} {
+				for _, t := range []string{"-f", "-i", "-x"} {
 					if cliFlags.ByName[t] {
 						cliArgs = append(cliArgs, t)
 					}
 				}
-				g.Status = cli.Main(cliArgs...)
-				return g.Status
+				return g.SetStatus(cli.Main(cliArgs...))
 			}
 			args = cliArgs
 		} else {
@@ -541,16 +757,14 @@ func (g *Goes) Main(args ...string) error {
 		}
 	}
 	if builtin, found := g.Builtins()[args[0]]; found {
-		g.Status = builtin(args[1:]...)
-		return g.Status
+		return g.SetStatus(builtin(args[1:]...))
 	} else if len(args) == 1 && strings.HasPrefix(args[0], "-") {
 		arg0 := strings.TrimLeft(args[0], "-")
 		if arg0 == "apropos" {
-			fmt.Println(g.Apropos())
+			fmt.Fprintln(g.stdout(), g.Apropos())
 			return nil
 		} else if builtin, found := g.Builtins()[arg0]; found {
-			g.Status = builtin()
-			return g.Status
+			return g.SetStatus(builtin())
 		}
 	}
 
@@ -559,15 +773,13 @@ func (g *Goes) Main(args ...string) error {
 	}
 
 	if g.Verbosity >= VerboseDebug {
-		fmt.Printf("$=%v %v\n", g.Status, args)
+		fmt.Printf("$=%v %v\n", g.Status(), args)
 	}
 
 	if !found {
 		if v, found = g.ByName[""]; !found {
-			g.Status =
-				fmt.Errorf("%s: ambiguous or missing command",
-					args[0])
-			return g.Status
+			return g.SetStatus(fmt.Errorf(
+				"%s: ambiguous or missing command", args[0]))
 		}
 		// e.g. ip -s add [default "show"]
 		args = append([]string{""}, args...)
@@ -576,45 +788,108 @@ func (g *Goes) Main(args ...string) error {
 	}
 
 	if k.IsDaemon() {
-		sig := make(chan os.Signal)
+		fg := isForeground(args[1:])
+		if fg {
+			// debug without the supervisor, e.g. goes redisd -fg
+			_, dargs := flags.New(args[1:], "-fg")
+			args = append(args[:1], dargs...)
+			if g.Verbosity < VerboseDebug {
+				g.Verbosity = VerboseDebug
+			}
+			log.Tee(os.Stderr)
+			fmt.Fprintln(os.Stderr, args[0], "pid", os.Getpid(),
+				"in foreground; interrupt to stop")
+		}
+		logger := log.New(args[0])
+		log.SetSubsys(args[0])
+		Stop = g.done()
+		sig := make(chan os.Signal, 1)
 		quit := make(chan struct{})
+		WG.Add(1)
+		go func() {
+			defer WG.Done()
+			g.logConfig(args[0], quit)
+		}()
+		WG.Add(1)
+		go func() {
+			defer WG.Done()
+			if err := debugServer(args[0], quit); err != nil {
+				logger.Err("debug", "err", err)
+			}
+		}()
 		signal.Notify(sig, syscall.SIGTERM)
+		if fg {
+			signal.Notify(sig, os.Interrupt)
+		}
+		// SIGHUP reloads a cmd.Reloader and is otherwise ignored
+		// rather than terminating the daemon, e.g. with a bare
+		// `daemon reload`
+		reloader, isReloader := v.(cmd.Reloader)
+		signal.Notify(sig, syscall.SIGHUP)
 		WG.Add(1)
 		go func() {
 			defer WG.Done()
-			select {
-			case <-quit:
-			case t := <-sig:
-				fmt.Println(t)
-				if t == syscall.SIGTERM {
-					close(Stop)
-					method, found := v.(io.Closer)
-					if found {
-						method.Close()
+			for {
+				select {
+				case <-quit:
+					return
+				case t := <-sig:
+					logger.Info("signal", "signal", t)
+					if t == syscall.SIGHUP {
+						if !isReloader {
+							logger.Info("reload",
+								"ignored", "not a reloader")
+							continue
+						}
+						err := reloader.Reload()
+						if err != nil {
+							logger.Err("reload",
+								"err", err)
+						}
+						continue
 					}
+					if t == syscall.SIGTERM || t == os.Interrupt {
+						g.Stop()
+						method, found := v.(io.Closer)
+						if found {
+							method.Close()
+						}
+					}
+					return
 				}
 			}
 		}()
 		err := v.Main(args[1:]...)
 		close(quit)
 		WG.Wait()
+		g.Wait()
 		signal.Stop(sig)
 		return err
 	}
 
+	name := args[0]
+	if len(name) == 0 {
+		if method, found := v.(akaer); found {
+			name = fmt.Sprint("(", method.Aka(), ")")
+		}
+	}
+	parent := g.currentSpan()
+	g.span = trace.Start(parent,
+		strings.TrimSpace(strings.Join(g.Path(), " ")+" "+name),
+		"args", strings.Join(args[1:], " "))
+	if parent == nil {
+		defer trace.SetRoot(trace.SetRoot(g.span))
+	}
 	err := v.Main(args[1:]...)
+	g.span.End(err)
+	g.span = parent
+	if parent == nil {
+		trace.Flush()
+	}
 	if err != nil && !k.IsDaemon() {
-		name := args[0]
-		if len(name) == 0 {
-			if method, found := v.(akaer); found {
-				name = fmt.Sprint("(", method.Aka(), ")")
-			}
-		}
 		err = fmt.Errorf("%s: %w", name, err)
 	}
-	g.Status = err
-
-	return err
+	return g.SetStatus(err)
 }
 
 // shift the first unambiguous longest prefix match command to args[0], so,
@@ -701,7 +976,7 @@ func (g *Goes) ensureTerminated(ls shellutils.List) (*shellutils.List, error) {
 				return &ls, nil
 			}
 		}
-		newls, err := shellutils.Parse(fmt.Sprintf("%s>>", term), g.Catline)
+		newls, err := shellutils.Parse(fmt.Sprintf("%s>>", term), g)
 		if err != nil {
 			return nil, err
 		}
@@ -717,23 +992,30 @@ type piperun struct {
 }
 
 func (g *Goes) ProcessList(ls shellutils.List) (*shellutils.List, *shellutils.Word, func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error, error) {
+	newls, err := g.ensureTerminated(ls)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if listTerm(*newls) == "&" {
+		return g.background(*newls)
+	}
+	return g.processList(*newls)
+}
+
+func (g *Goes) processList(ls shellutils.List) (*shellutils.List, *shellutils.Word, func(stdin io.Reader, stdout io.Writer, stderr io.Writer) error, error) {
 	var (
 		pipeline []piperun
 		term     shellutils.Word
 	)
 
-	newls, err := g.ensureTerminated(ls)
-	if err != nil {
-		return nil, nil, nil, err
-	}
-	ls = *newls
 	for len(ls.Cmds) != 0 {
-		nextls, term, runner, err := g.ProcessPipeline(ls)
+		nextls, t, runner, err := g.ProcessPipeline(ls)
 		if err != nil {
 			return nil, nil, nil, err
 		}
 		ls = *nextls
-		pipeline = append(pipeline, piperun{f: runner, t: *term})
+		term = *t
+		pipeline = append(pipeline, piperun{f: runner, t: term})
 		if term.String() != "&&" && term.String() != "||" {
 			break
 		}
@@ -753,11 +1035,11 @@ func (g *Goes) MakeListFunc(pipeline []piperun) (func(stdin io.Reader, stdout io
 			if !skipNext {
 				err = runfun.f(stdin, stdout, stderr)
 				if err != nil {
-					g.Status = err
+					g.SetStatus(err)
 				}
 				skipNext = false
 			}
-			if g.Status != nil {
+			if g.Status() != nil {
 				if term.String() == "&&" {
 					skipNext = true
 				}
@@ -772,3 +1054,13 @@ func (g *Goes) MakeListFunc(pipeline []piperun) (func(stdin io.Reader, stdout io
 	}
 	return listfun, nil
 }
+
+// isForeground is true if daemon args include "-fg".
+func isForeground(args []string) bool {
+	for _, arg := range args {
+		if arg == "-fg" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build linux
+
+package goes_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/platinasystems/goes"
+	"github.com/platinasystems/goes/cmd"
+	"github.com/platinasystems/goes/cmd/cli"
+	"github.com/platinasystems/goes/lang"
+)
+
+type record struct {
+	g    *goes.Goes
+	args []string
+}
+
+func (*record) String() string      { return "record" }
+func (*record) Usage() string       { return "record [ARG]..." }
+func (*record) Apropos() lang.Alt   { return lang.Alt{lang.EnUS: "record args"} }
+func (*record) Kind() cmd.Kind      { return cmd.DontFork }
+func (r *record) Goes(g *goes.Goes) { r.g = g }
+
+func (r *record) Main(args ...string) error {
+	r.args = append(r.args, args...)
+	return nil
+}
+
+func TestRunUsage(t *testing.T) {
+	t.Parallel()
+	for _, name := range []string{"goes-a", "goes-b"} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			g := &goes.Goes{
+				NAME:   name,
+				ByName: map[string]cmd.Cmd{"record": &record{}},
+			}
+			var stdout bytes.Buffer
+			err := g.Run([]string{name}, nil, &stdout, &stdout, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(stdout.String(), "usage:") {
+				t.Errorf("usage %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	t.Parallel()
+	r := &record{}
+	g := &goes.Goes{
+		NAME: "goes",
+		ByName: map[string]cmd.Cmd{
+			"cli":    &cli.Command{},
+			"record": r,
+		},
+	}
+	script := "X=hello\nrecord $X $WHERE\n"
+	var stdout bytes.Buffer
+	err := g.Run([]string{"goes", "-"}, strings.NewReader(script),
+		&stdout, &stdout, []string{"WHERE=embedded"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.args, " "); got != "hello embedded" {
+		t.Errorf("got %q", got)
+	}
+	if s := g.Getenv("WHERE"); s != "embedded" {
+		t.Errorf("WHERE=%q", s)
+	}
+}
+
+func TestStop(t *testing.T) {
+	t.Parallel()
+	a, b := &goes.Goes{NAME: "a"}, &goes.Goes{NAME: "b"}
+	sub := &goes.Goes{NAME: "sub"}
+	sub.Goes(a)
+	ran := make(chan struct{})
+	a.Go(func() {
+		<-sub.Done()
+		close(ran)
+	})
+	a.Stop()
+	a.Stop()
+	a.Wait()
+	select {
+	case <-ran:
+	default:
+		t.Error("sub-goes didn't stop with its parent")
+	}
+	select {
+	case <-b.Done():
+		t.Error("stopped another goes")
+	default:
+	}
+}
+
+func TestContext(t *testing.T) {
+	t.Parallel()
+	g := &goes.Goes{NAME: "goes"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			k := fmt.Sprint("V", i)
+			g.Context().Setenv(k, k)
+			g.SetStatus(fmt.Errorf("%s", k))
+			if s := g.Getenv(k); s != k {
+				t.Errorf("%s=%q", k, s)
+			}
+			g.Context().SetFunction(goes.Function{Name: k})
+		}(i)
+	}
+	wg.Wait()
+	if n := len(g.Context().Env()); n != 8 {
+		t.Errorf("%d variables", n)
+	}
+	job := g.Context().Clone()
+	job.Setenv("V0", "job")
+	job.SetStatus(nil)
+	if s := g.Getenv("V0"); s != "V0" {
+		t.Errorf("clone changed V0 to %q", s)
+	}
+	if g.Status() == nil {
+		t.Error("clone changed status")
+	}
+	if _, found := job.Function("V7"); !found {
+		t.Error("clone is missing function")
+	}
+}
+
+func TestPipeTerminal(t *testing.T) {
+	t.Parallel()
+	g := &goes.Goes{
+		NAME:     "goes",
+		Terminal: goes.NewPipeTerminal(strings.NewReader("a\nb\n"), nil).Named("t"),
+	}
+	for _, want := range []string{"a", "b"} {
+		if s, err := g.ReadLine("> "); err != nil {
+			t.Fatal(err)
+		} else if s != want {
+			t.Errorf("got %q, want %q", s, want)
+		}
+	}
+	if name, lineno := g.Position(); name != "t" || lineno != 2 {
+		t.Errorf("position %s:%d", name, lineno)
+	}
+	if _, err := g.ReadLine("> "); err != io.EOF {
+		t.Error("expected EOF, got", err)
+	}
+}
+
+func TestVerbosity(t *testing.T) {
+	t.Parallel()
+	g := &goes.Goes{NAME: "goes"}
+	var stdout bytes.Buffer
+	for _, argv := range [][]string{
+		{"goes", "verbosity", "debug"},
+		{"goes", "verbosity"},
+	} {
+		if err := g.Run(argv, nil, &stdout, &stdout, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g.Verbosity != goes.VerboseDebug {
+		t.Error("verbosity", g.Verbosity)
+	}
+	if s := stdout.String(); s != "debug\n" {
+		t.Errorf("got %q", s)
+	}
+	if err := g.Run([]string{"goes", "verbosity", "loud"}, nil,
+		&stdout, &stdout, nil); err == nil {
+		t.Error("set unknown verbosity")
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+	g := &goes.Goes{
+		NAME:   "goes",
+		ByName: map[string]cmd.Cmd{"cli": &cli.Command{}},
+	}
+	var stdout, stderr bytes.Buffer
+	err := g.Run([]string{"goes", "-stats", "-"}, strings.NewReader("X=1\n"),
+		&stdout, &stderr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"stats: X=1: real ", "stats: -: real "} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("%q missing %q", stderr.String(), want)
+		}
+	}
+	for _, argv := range [][]string{
+		{"goes", "stats", "off"},
+		{"goes", "stats"},
+	} {
+		if err = g.Run(argv, nil, &stdout, &stdout, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s := stdout.String(); s != "off\n" {
+		t.Errorf("got %q", s)
+	}
+}
+
+func TestJobs(t *testing.T) {
+	t.Parallel()
+	g := &goes.Goes{
+		NAME:   "goes",
+		ByName: map[string]cmd.Cmd{"cli": &cli.Command{}},
+	}
+	var stdout, stderr bytes.Buffer
+	err := g.Run([]string{"goes", "-"},
+		strings.NewReader("X=1\nX=2 &\nfg %1\njobs\n"),
+		&stdout, &stderr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stderr.String(); s != "[1] X=2\n" {
+		t.Errorf("stderr %q", s)
+	}
+	if s := stdout.String(); s != "X=2\n" {
+		t.Errorf("stdout %q", s)
+	}
+	// the job's assignment is that of its clone of the context
+	if s := g.Getenv("X"); s != "1" {
+		t.Errorf("X=%q", s)
+	}
+	if err = g.Run([]string{"goes", "fg"}, nil, &stdout, &stderr,
+		nil); err == nil || !strings.Contains(err.Error(), "no jobs") {
+		t.Error("fg without jobs:", err)
+	}
+}
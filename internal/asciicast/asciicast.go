@@ -0,0 +1,206 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package asciicast writes and reads terminal sessions recorded in the
+// asciinema v2 format, i.e. a JSON header line followed by a JSON line for
+// each event of the form:
+//
+//	[SECONDS, "o", "DATA"]
+//
+// Where SECONDS is the time since the start of the recording and "o", "i",
+// or "r" denotes output, input, or a resize to "COLSxROWS".
+package asciicast
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const Version = 2
+
+const (
+	Output = "o"
+	Input  = "i"
+	Resize = "r"
+)
+
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+type Event struct {
+	Time float64
+	Type string
+	Data string
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode([]interface{}{e.Time, e.Type, e.Data})
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), err
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var v []interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if len(v) != 3 {
+		return fmt.Errorf("%s: invalid event", b)
+	}
+	var ok [3]bool
+	e.Time, ok[0] = v[0].(float64)
+	e.Type, ok[1] = v[1].(string)
+	e.Data, ok[2] = v[2].(string)
+	if !ok[0] || !ok[1] || !ok[2] {
+		return fmt.Errorf("%s: invalid event", b)
+	}
+	return nil
+}
+
+// Writer records events timed from its creation.
+type Writer struct {
+	mutex sync.Mutex
+	w     io.Writer
+	start time.Time
+	err   error
+}
+
+// NewWriter writes the header, with a default version, size, and
+// timestamp, then returns a Writer of its events.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	start := time.Now()
+	if h.Version == 0 {
+		h.Version = Version
+	}
+	if h.Width == 0 || h.Height == 0 {
+		h.Width, h.Height = 80, 24
+	}
+	if h.Timestamp == 0 {
+		h.Timestamp = start.Unix()
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(append(b, '\n')); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, start: start}, nil
+}
+
+// Event records the data of the given type; this returns the first error
+// of the underlying writer for all subsequent events.
+func (w *Writer) Event(typ string, data []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	t := time.Since(w.start).Seconds()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	err := enc.Encode(Event{
+		Time: math.Round(t*1e6) / 1e6,
+		Type: typ,
+		Data: string(data),
+	})
+	if err == nil {
+		_, err = w.w.Write(buf.Bytes())
+	}
+	w.err = err
+	return err
+}
+
+// Output returns an io.Writer that records its data as output events.
+func (w *Writer) Output() io.Writer { return &eventWriter{w: w, typ: Output} }
+
+// Input returns an io.Writer that records its data as input events.
+func (w *Writer) Input() io.Writer { return &eventWriter{w: w, typ: Input} }
+
+// eventWriter holds a trailing, incomplete UTF-8 sequence for the next
+// write since JSON strings would otherwise replace it.
+type eventWriter struct {
+	w       *Writer
+	typ     string
+	partial []byte
+}
+
+func (ew *eventWriter) Write(p []byte) (int, error) {
+	b := append(ew.partial, p...)
+	n := len(b)
+	for i := n - 1; i >= 0 && i >= n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				n = i
+			}
+			break
+		}
+	}
+	ew.partial = append([]byte(nil), b[n:]...)
+	if n == 0 {
+		return len(p), nil
+	}
+	if err := ew.w.Event(ew.typ, b[:n]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Reader returns the events of a recording after its Header.
+type Reader struct {
+	Header
+	scanner *bufio.Scanner
+}
+
+func NewReader(r io.Reader) (*Reader, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.ErrUnexpectedEOF
+	}
+	cr := &Reader{scanner: scanner}
+	if err := json.Unmarshal(scanner.Bytes(), &cr.Header); err != nil {
+		return nil, fmt.Errorf("header: %v", err)
+	}
+	if cr.Version != Version {
+		return nil, fmt.Errorf("version %d: unsupported", cr.Version)
+	}
+	return cr, nil
+}
+
+// Next returns the next event or io.EOF at the end of the recording.
+func (r *Reader) Next() (Event, error) {
+	var e Event
+	for r.scanner.Scan() {
+		b := r.scanner.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+		err := json.Unmarshal(b, &e)
+		return e, err
+	}
+	if err := r.scanner.Err(); err != nil {
+		return e, err
+	}
+	return e, io.EOF
+}
@@ -0,0 +1,50 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package asciicast
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, Header{Title: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := w.Output()
+	// split the 3 byte UTF-8 encoding of '€' across writes
+	euro := []byte("€")
+	out.Write([]byte("goes> "))
+	w.Input().Write([]byte("echo\r"))
+	out.Write(euro[:1])
+	out.Write(euro[1:])
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Title != "test" || r.Width != 80 || r.Height != 24 {
+		t.Errorf("header %+v", r.Header)
+	}
+	for _, want := range []Event{
+		{Type: Output, Data: "goes> "},
+		{Type: Input, Data: "echo\r"},
+		{Type: Output, Data: "€"},
+	} {
+		e, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Type != want.Type || e.Data != want.Data {
+			t.Errorf("got %+v, want %+v", e, want)
+		}
+	}
+	if _, err = r.Next(); err != io.EOF {
+		t.Error("expected EOF, got", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package complete provides argument completions from the live state of
+// the system, i.e. interface names from netlink, VLAN IDs published by
+// vland, and network namespaces, for the Complete method of a
+// goes.Completer command.
+package complete
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/platinasystems/goes/external/redis"
+	"github.com/platinasystems/goes/internal/netns"
+	"github.com/platinasystems/goes/internal/nl"
+	"github.com/platinasystems/goes/internal/nl/rtnl"
+)
+
+// Last returns the last and next to last arguments, if any.
+func Last(args []string) (last, prev string) {
+	if n := len(args); n > 0 {
+		last = args[n-1]
+		if n > 1 {
+			prev = args[n-2]
+		}
+	}
+	return
+}
+
+// Prefixed returns the sorted words that begin with s.
+func Prefixed(s string, words ...string) []string {
+	var list []string
+	for _, word := range words {
+		if strings.HasPrefix(word, s) {
+			list = append(list, word)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// IfName returns the sorted names of the interfaces of the current network
+// namespace that begin with s.
+func IfName(s string) []string {
+	sock, err := nl.NewSock()
+	if err != nil {
+		return nil
+	}
+	defer sock.Close()
+	if err = rtnl.MakeIfMaps(nl.NewSockReceiver(sock)); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(rtnl.If.IndexByName))
+	for name := range rtnl.If.IndexByName {
+		names = append(names, name)
+	}
+	return Prefixed(s, names...)
+}
+
+// Vid returns the sorted VLAN IDs of the vlan.VID.* fields that begin
+// with s.
+func Vid(s string) []string {
+	fields, err := redis.Hgetall(redis.DefaultHash, "vlan.")
+	if err != nil {
+		return nil
+	}
+	found := make(map[string]bool)
+	for field := range fields {
+		vid := strings.SplitN(field, ".", 3)[1]
+		if len(vid) > 0 && strings.Trim(vid, "0123456789") == "" {
+			found[vid] = true
+		}
+	}
+	vids := make([]string, 0, len(found))
+	for vid := range found {
+		vids = append(vids, vid)
+	}
+	list := Prefixed(s, vids...)
+	sort.Slice(list, func(i, j int) bool {
+		return len(list[i]) < len(list[j]) ||
+			len(list[i]) == len(list[j]) && list[i] < list[j]
+	})
+	return list
+}
+
+// Netns returns the sorted names of the network namespaces that begin
+// with s.
+func Netns(s string) []string {
+	return netns.CompleteName(s)
+}
@@ -0,0 +1,79 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package rtnl
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// IFLA_AF_SPEC attributes of AF_BRIDGE
+const (
+	IFLA_BRIDGE_FLAGS uint16 = iota
+	IFLA_BRIDGE_MODE
+	IFLA_BRIDGE_VLAN_INFO
+	IFLA_BRIDGE_VLAN_TUNNEL_INFO
+)
+
+const (
+	BRIDGE_FLAGS_MASTER uint16 = 1 << iota
+	BRIDGE_FLAGS_SELF
+)
+
+const (
+	BRIDGE_VLAN_INFO_MASTER uint16 = 1 << iota
+	BRIDGE_VLAN_INFO_PVID
+	BRIDGE_VLAN_INFO_UNTAGGED
+	BRIDGE_VLAN_INFO_RANGE_BEGIN
+	BRIDGE_VLAN_INFO_RANGE_END
+	BRIDGE_VLAN_INFO_BRENTRY
+)
+
+// bridge port STP states
+const (
+	BR_STATE_DISABLED uint8 = iota
+	BR_STATE_LISTENING
+	BR_STATE_LEARNING
+	BR_STATE_FORWARDING
+	BR_STATE_BLOCKING
+)
+
+var BrStateByName = map[string]uint8{
+	"disabled":   BR_STATE_DISABLED,
+	"listening":  BR_STATE_LISTENING,
+	"learning":   BR_STATE_LEARNING,
+	"forwarding": BR_STATE_FORWARDING,
+	"blocking":   BR_STATE_BLOCKING,
+}
+
+var BrStateName = map[uint8]string{
+	BR_STATE_DISABLED:   "disabled",
+	BR_STATE_LISTENING:  "listening",
+	BR_STATE_LEARNING:   "learning",
+	BR_STATE_FORWARDING: "forwarding",
+	BR_STATE_BLOCKING:   "blocking",
+}
+
+const SizeofBridgeVlanInfo = 4
+
+type BridgeVlanInfo struct {
+	Flags uint16
+	Vid   uint16
+}
+
+func BridgeVlanInfoPtr(b []byte) *BridgeVlanInfo {
+	if len(b) < SizeofBridgeVlanInfo {
+		return nil
+	}
+	return (*BridgeVlanInfo)(unsafe.Pointer(&b[0]))
+}
+
+func (v BridgeVlanInfo) Read(b []byte) (int, error) {
+	if len(b) < SizeofBridgeVlanInfo {
+		return 0, syscall.EOVERFLOW
+	}
+	*(*BridgeVlanInfo)(unsafe.Pointer(&b[0])) = v
+	return SizeofBridgeVlanInfo, nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package persist saves the runtime settings of a daemon, i.e. its redis
+// settable fields, to a file of "FIELD: VALUE" lines so that the daemon may
+// restore and reconcile these on restart.
+package persist
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dir of the settings files.
+var Dir = "/etc/goes/persist"
+
+type Settings struct {
+	fn string
+	m  map[string]string
+}
+
+// Load the named settings, e.g. "vlan" from /etc/goes/persist/vlan; a
+// missing file has no settings.
+func Load(name string) (*Settings, error) {
+	s := &Settings{
+		fn: filepath.Join(Dir, name),
+		m:  make(map[string]string),
+	}
+	f, err := os.Open(s.fn)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return s, err
+	}
+	defer f.Close()
+	scan := bufio.NewScanner(f)
+	for line := 1; scan.Scan(); line++ {
+		t := strings.TrimSpace(scan.Text())
+		if len(t) == 0 || t[0] == '#' {
+			continue
+		}
+		// fields may have colons, e.g. route.2001:db8::/32, so the
+		// field ends with the colon before the first space
+		i := strings.IndexAny(t, " \t")
+		if i < 0 {
+			i = len(t)
+		}
+		if i <= 1 || t[i-1] != ':' {
+			return s, fmt.Errorf("%s:%d: invalid", s.fn, line)
+		}
+		s.m[t[:i-1]] = strings.TrimSpace(t[i:])
+	}
+	return s, scan.Err()
+}
+
+// Fields returns the sorted names of the settings.
+func (s *Settings) Fields() []string {
+	fields := make([]string, 0, len(s.m))
+	for field := range s.m {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Get returns the value of the field or "" if unset.
+func (s *Settings) Get(field string) string { return s.m[field] }
+
+// Set the field, or delete it with an empty value, then save all settings.
+func (s *Settings) Set(field, value string) error {
+	value = strings.TrimSpace(value)
+	if len(field) == 0 || strings.ContainsAny(field, " \t\n") ||
+		strings.HasSuffix(field, ":") {
+		return fmt.Errorf("%q: invalid field", field)
+	}
+	if strings.ContainsAny(value, "\n") {
+		return fmt.Errorf("%s: multi-line value", field)
+	}
+	if s.m[field] == value {
+		return nil
+	}
+	if len(value) > 0 {
+		s.m[field] = value
+	} else {
+		delete(s.m, field)
+	}
+	return s.save()
+}
+
+// save to a temporary file then rename that so that a crash won't leave
+// partial settings.
+func (s *Settings) save() error {
+	if err := os.MkdirAll(filepath.Dir(s.fn), 0755); err != nil {
+		return err
+	}
+	tmp := s.fn + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, field := range s.Fields() {
+		fmt.Fprint(w, field, ": ", s.m[field], "\n")
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, s.fn)
+}
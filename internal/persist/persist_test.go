@@ -0,0 +1,57 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package persist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "persist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	Dir = filepath.Join(dir, "etc")
+
+	s, err := Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []struct{ field, value string }{
+		{"test.b", "hello world"},
+		{"test.a", " 1 "},
+		{"test.c", "deleted"},
+		{"test.c", ""},
+		{"test.2001:db8::/32", "via fe80::1 dev eth0"},
+	} {
+		if err = s.Set(x.field, x.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Set("test d", "x"); err == nil {
+		t.Error("expected invalid field")
+	}
+	if s, err = Load("test"); err != nil {
+		t.Fatal(err)
+	}
+	fields := s.Fields()
+	if len(fields) != 3 || fields[0] != "test.2001:db8::/32" ||
+		fields[1] != "test.a" || fields[2] != "test.b" {
+		t.Fatalf("fields: %v", fields)
+	}
+	if v := s.Get("test.a"); v != "1" {
+		t.Errorf("test.a: %q", v)
+	}
+	if v := s.Get("test.b"); v != "hello world" {
+		t.Errorf("test.b: %q", v)
+	}
+	if v := s.Get("test.2001:db8::/32"); v != "via fe80::1 dev eth0" {
+		t.Errorf("test.2001:db8::/32: %q", v)
+	}
+}
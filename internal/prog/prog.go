@@ -32,7 +32,10 @@ func Name() string {
 		var err error
 		name, err = os.Readlink("/proc/self/exe")
 		if err != nil {
-			name = a
+			// e.g. darwin or windows
+			if name, err = os.Executable(); err != nil {
+				name = a
+			}
 		}
 	}
 	return name
@@ -0,0 +1,123 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build ignore
+
+// This generates licenses.go from the license file of each module that is
+// linked into a goes command,
+//
+//	go generate ./internal/sbom
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// classes are matched in order with the license text; those with any
+// unmatched "!" prefixed strings are skipped.
+var classes = []struct {
+	id      string
+	matches []string
+}{
+	{"Apache-2.0", []string{"Apache License", "Version 2.0"}},
+	{"MPL-2.0", []string{"Mozilla Public License", "2.0"}},
+	{"LGPL-2.1-or-later", []string{"GNU LESSER GENERAL PUBLIC", "2.1",
+		"any later version"}},
+	{"GPL-2.0-or-later", []string{"GNU General Public License",
+		"version 2", "any later version"}},
+	{"GPL-2.0-only", []string{"GNU GENERAL PUBLIC LICENSE", "Version 2"}},
+	{"MIT", []string{"Permission is hereby granted, free of charge"}},
+	{"BSD-3-Clause", []string{"Redistribution and use in source and binary",
+		"Neither the name"}},
+	{"BSD-2-Clause", []string{"Redistribution and use in source and binary"}},
+	{"ISC", []string{"Permission to use, copy, modify, and"}},
+}
+
+func main() {
+	out, err := exec.Command("go", "list", "-deps", "-f",
+		"{{with .Module}}{{.Path}} {{.Dir}}{{end}}", "../../...").Output()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	licenses := make(map[string]string)
+	scan := bufio.NewScanner(bytes.NewReader(out))
+	for scan.Scan() {
+		f := strings.Fields(scan.Text())
+		if len(f) == 2 {
+			licenses[f[0]] = classify(f[1])
+		}
+	}
+	paths := make([]string, 0, len(licenses))
+	for path := range licenses {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	buf := new(bytes.Buffer)
+	fmt.Fprint(buf, `// Code generated by "go run gen.go"; DO NOT EDIT.
+
+package sbom
+
+// Licenses are the SPDX identifiers of each module's license.
+var Licenses = map[string]string{
+`)
+	for _, path := range paths {
+		fmt.Fprintf(buf, "\t%q: %q,\n", path, licenses[path])
+	}
+	fmt.Fprintln(buf, "}")
+	b, err := format.Source(buf.Bytes())
+	if err == nil {
+		err = ioutil.WriteFile("licenses.go", b, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func classify(dir string) string {
+	for _, pattern := range []string{"LICEN[CS]E*", "COPYING*"} {
+		fns, _ := filepath.Glob(filepath.Join(dir, pattern))
+		for _, fn := range fns {
+			b, err := ioutil.ReadFile(fn)
+			if err != nil {
+				continue
+			}
+			text := strings.Join(strings.Fields(string(b)), " ")
+		classes:
+			for _, class := range classes {
+				for _, s := range class.matches {
+					if !strings.Contains(text, s) {
+						continue classes
+					}
+				}
+				return class.id
+			}
+		}
+	}
+	// without a license file, the Platina Systems modules have a header
+	// that refers to the GPL-2 license of goes
+	fns, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, fn := range fns {
+		b, err := ioutil.ReadFile(fn)
+		if err == nil && bytes.Contains(b, []byte(gpl2Header)) {
+			return "GPL-2.0-or-later"
+		}
+	}
+	return NoAssertion
+}
+
+const gpl2Header = "Use of this source code is governed by the GPL-2 license"
+
+// NoAssertion is the SPDX identifier of an unknown license.
+const NoAssertion = "NOASSERTION"
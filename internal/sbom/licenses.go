@@ -0,0 +1,47 @@
+// Code generated by "go run gen.go"; DO NOT EDIT.
+
+package sbom
+
+// Licenses are the SPDX identifiers of each module's license.
+var Licenses = map[string]string{
+	"github.com/anmitsu/go-shlex":               "MIT",
+	"github.com/cavaliercoder/go-cpio":          "BSD-3-Clause",
+	"github.com/cavaliercoder/grab":             "MIT",
+	"github.com/creack/pty":                     "MIT",
+	"github.com/d2g/dhcp4":                      "BSD-3-Clause",
+	"github.com/d2g/dhcp4client":                "MPL-2.0",
+	"github.com/garyburd/redigo":                "Apache-2.0",
+	"github.com/gliderlabs/ssh":                 "BSD-3-Clause",
+	"github.com/golang/protobuf":                "BSD-3-Clause",
+	"github.com/jpillora/backoff":               "MIT",
+	"github.com/mattn/go-isatty":                "MIT",
+	"github.com/openconfig/gnmi":                "Apache-2.0",
+	"github.com/paypal/gatt":                    "BSD-3-Clause",
+	"github.com/pkg/term":                       "BSD-2-Clause",
+	"github.com/platinasystems/fdt":             "NOASSERTION",
+	"github.com/platinasystems/go-redis-server": "Apache-2.0",
+	"github.com/platinasystems/goes":            "GPL-2.0-or-later",
+	"github.com/platinasystems/gpio":            "GPL-2.0-or-later",
+	"github.com/platinasystems/ioport":          "GPL-2.0-or-later",
+	"github.com/platinasystems/ldp":             "GPL-2.0-or-later",
+	"github.com/platinasystems/liner":           "MIT",
+	"github.com/platinasystems/loopback":        "GPL-2.0-or-later",
+	"github.com/platinasystems/memio":           "GPL-2.0-or-later",
+	"github.com/platinasystems/nvram":           "GPL-2.0-or-later",
+	"github.com/platinasystems/ssh_key_helper":  "GPL-2.0-or-later",
+	"github.com/platinasystems/term":            "BSD-2-Clause",
+	"github.com/platinasystems/tftp":            "GPL-2.0-or-later",
+	"github.com/platinasystems/ubi":             "GPL-2.0-or-later",
+	"github.com/platinasystems/url":             "GPL-2.0-or-later",
+	"github.com/ramr/go-reaper":                 "MIT",
+	"github.com/satori/go.uuid":                 "MIT",
+	"github.com/satori/uuid":                    "MIT",
+	"github.com/ulikunitz/xz":                   "BSD-2-Clause",
+	"golang.org/x/crypto":                       "BSD-3-Clause",
+	"golang.org/x/net":                          "BSD-3-Clause",
+	"golang.org/x/sys":                          "BSD-3-Clause",
+	"golang.org/x/text":                         "BSD-3-Clause",
+	"google.golang.org/genproto":                "Apache-2.0",
+	"google.golang.org/grpc":                    "Apache-2.0",
+	"google.golang.org/protobuf":                "BSD-3-Clause",
+}
@@ -0,0 +1,278 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package sbom provides a software bill of materials of the modules linked
+// into the running program; their versions and hashes are those of its
+// runtime/debug.BuildInfo and their licenses those found by "go generate"
+// when the program was built.
+package sbom
+
+//go:generate go run gen.go
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/satori/uuid"
+)
+
+// NoAssertion is the SPDX identifier of an unknown license.
+const NoAssertion = "NOASSERTION"
+
+// Devel is the version of a main module built from its source tree.
+const Devel = "(devel)"
+
+// Module of the bill; Sum is that of go.sum, i.e. "h1:BASE64".
+type Module struct {
+	Path    string
+	Version string
+	Sum     string
+	License string
+}
+
+// BOM is the bill of materials of Modules, the first being the main module.
+type BOM struct {
+	Name    string
+	Created time.Time
+	Modules []Module
+}
+
+// New returns the bill of materials of the running program; this has no
+// modules if it wasn't built with module support.
+func New(name string) *BOM {
+	bom := &BOM{Name: name, Created: time.Now().UTC()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return bom
+	}
+	bom.Modules = append(bom.Modules, module(&bi.Main))
+	for _, dep := range bi.Deps {
+		bom.Modules = append(bom.Modules, module(dep))
+	}
+	return bom
+}
+
+func module(m *debug.Module) Module {
+	mod := Module{
+		Path:    m.Path,
+		Version: m.Version,
+		Sum:     m.Sum,
+		License: license(m.Path),
+	}
+	if r := m.Replace; r != nil {
+		mod.Path, mod.Version, mod.Sum = r.Path, r.Version, r.Sum
+		if lic := license(r.Path); lic != NoAssertion {
+			mod.License = lic
+		}
+	}
+	return mod
+}
+
+func license(path string) string {
+	if lic, found := Licenses[path]; found {
+		return lic
+	}
+	return NoAssertion
+}
+
+// Sha256 returns the hex encoded hash of the module's go.sum "h1:" sum, or
+// "" if it has none.
+func (m Module) Sha256() string {
+	if !strings.HasPrefix(m.Sum, "h1:") {
+		return ""
+	}
+	b, err := base64.StdEncoding.DecodeString(m.Sum[len("h1:"):])
+	if err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Purl returns the module's package URL, e.g.
+//
+//	pkg:golang/golang.org/x/sys@v0.0.0-20190412213103-97732733099d
+func (m Module) Purl() string {
+	s := "pkg:golang/" + m.Path
+	if len(m.Version) > 0 && m.Version != Devel {
+		s += "@" + m.Version
+	}
+	return s
+}
+
+// Text writes a table of the modules.
+func (bom *BOM) Text(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "MODULE\tVERSION\tLICENSE\tSUM")
+	for _, m := range bom.Modules {
+		fmt.Fprint(tw, m.Path, "\t", m.Version, "\t", m.License, "\t",
+			m.Sum, "\n")
+	}
+	return tw.Flush()
+}
+
+// SPDX writes the bill as an SPDX 2.2 JSON document.
+func (bom *BOM) SPDX(w io.Writer) error {
+	type checksum struct {
+		Algorithm string `json:"algorithm"`
+		Value     string `json:"checksumValue"`
+	}
+	type ref struct {
+		Category string `json:"referenceCategory"`
+		Type     string `json:"referenceType"`
+		Locator  string `json:"referenceLocator"`
+	}
+	type pkg struct {
+		Name             string     `json:"name"`
+		ID               string     `json:"SPDXID"`
+		Version          string     `json:"versionInfo,omitempty"`
+		Download         string     `json:"downloadLocation"`
+		FilesAnalyzed    bool       `json:"filesAnalyzed"`
+		LicenseConcluded string     `json:"licenseConcluded"`
+		LicenseDeclared  string     `json:"licenseDeclared"`
+		Copyright        string     `json:"copyrightText"`
+		Checksums        []checksum `json:"checksums,omitempty"`
+		Refs             []ref      `json:"externalRefs"`
+	}
+	type relationship struct {
+		Element string `json:"spdxElementId"`
+		Type    string `json:"relationshipType"`
+		Related string `json:"relatedSpdxElement"`
+	}
+	doc := struct {
+		Version      string `json:"spdxVersion"`
+		DataLicense  string `json:"dataLicense"`
+		ID           string `json:"SPDXID"`
+		Name         string `json:"name"`
+		Namespace    string `json:"documentNamespace"`
+		CreationInfo struct {
+			Created  string   `json:"created"`
+			Creators []string `json:"creators"`
+		} `json:"creationInfo"`
+		Packages      []pkg          `json:"packages"`
+		Relationships []relationship `json:"relationships"`
+	}{
+		Version:     "SPDX-2.2",
+		DataLicense: "CC0-1.0",
+		ID:          "SPDXRef-DOCUMENT",
+		Name:        bom.Name,
+		Namespace: "https://github.com/platinasystems/goes/spdx/" +
+			bom.Name + "-" + uuid.NewV4().String(),
+	}
+	doc.CreationInfo.Created = bom.Created.Format(time.RFC3339)
+	doc.CreationInfo.Creators = []string{"Tool: " + bom.Name}
+	for i, m := range bom.Modules {
+		p := pkg{
+			Name:             m.Path,
+			ID:               fmt.Sprint("SPDXRef-Package-", i),
+			Version:          m.Version,
+			Download:         NoAssertion,
+			LicenseConcluded: m.License,
+			LicenseDeclared:  m.License,
+			Copyright:        NoAssertion,
+			Refs: []ref{{
+				Category: "PACKAGE_MANAGER",
+				Type:     "purl",
+				Locator:  m.Purl(),
+			}},
+		}
+		if sum := m.Sha256(); len(sum) > 0 {
+			p.Checksums = []checksum{{"SHA256", sum}}
+		}
+		doc.Packages = append(doc.Packages, p)
+		if i == 0 {
+			doc.Relationships = append(doc.Relationships,
+				relationship{doc.ID, "DESCRIBES", p.ID})
+		} else {
+			doc.Relationships = append(doc.Relationships,
+				relationship{doc.Packages[0].ID, "DEPENDS_ON",
+					p.ID})
+		}
+	}
+	return encode(w, doc)
+}
+
+// CycloneDX writes the bill as a CycloneDX 1.2 JSON document.
+func (bom *BOM) CycloneDX(w io.Writer) error {
+	type hash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+	type license struct {
+		License struct {
+			ID string `json:"id"`
+		} `json:"license"`
+	}
+	type component struct {
+		Type     string    `json:"type"`
+		Ref      string    `json:"bom-ref"`
+		Name     string    `json:"name"`
+		Version  string    `json:"version,omitempty"`
+		Purl     string    `json:"purl"`
+		Hashes   []hash    `json:"hashes,omitempty"`
+		Licenses []license `json:"licenses,omitempty"`
+	}
+	type tool struct {
+		Name string `json:"name"`
+	}
+	doc := struct {
+		Format   string `json:"bomFormat"`
+		Spec     string `json:"specVersion"`
+		Serial   string `json:"serialNumber"`
+		Version  int    `json:"version"`
+		Metadata struct {
+			Timestamp string     `json:"timestamp"`
+			Tools     []tool     `json:"tools"`
+			Component *component `json:"component,omitempty"`
+		} `json:"metadata"`
+		Components []component `json:"components"`
+	}{
+		Format:     "CycloneDX",
+		Spec:       "1.2",
+		Serial:     "urn:uuid:" + uuid.NewV4().String(),
+		Version:    1,
+		Components: []component{},
+	}
+	doc.Metadata.Timestamp = bom.Created.Format(time.RFC3339)
+	doc.Metadata.Tools = []tool{{bom.Name}}
+	for i, m := range bom.Modules {
+		c := component{
+			Type: "library",
+			Ref:  m.Purl(),
+			Name: m.Path,
+			Purl: m.Purl(),
+		}
+		if m.Version != Devel {
+			c.Version = m.Version
+		}
+		if sum := m.Sha256(); len(sum) > 0 {
+			c.Hashes = []hash{{"SHA-256", sum}}
+		}
+		// CycloneDX 1.2 license IDs must be those of SPDX
+		if m.License != NoAssertion {
+			var lic license
+			lic.License.ID = m.License
+			c.Licenses = []license{lic}
+		}
+		if i == 0 {
+			c.Type = "application"
+			doc.Metadata.Component = &c
+		} else {
+			doc.Components = append(doc.Components, c)
+		}
+	}
+	return encode(w, doc)
+}
+
+func encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
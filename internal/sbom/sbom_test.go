@@ -0,0 +1,50 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBOM(t *testing.T) {
+	bom := &BOM{
+		Name:    "goes",
+		Created: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		Modules: []Module{
+			{Path: "github.com/platinasystems/goes", Version: Devel,
+				License: "GPL-2.0-or-later"},
+			{Path: "golang.org/x/sys",
+				Version: "v0.0.0-20190412213103-97732733099d",
+				Sum:     "h1:42mYnZkXPZmiGwbbiE9hbyUYXIF3+nTUfQ/YR3zBkZ0=",
+				License: "BSD-3-Clause"},
+		},
+	}
+	if s := bom.Modules[1].Sha256(); len(s) != 64 {
+		t.Error("sha256:", s)
+	}
+	if s := bom.Modules[0].Purl(); s != "pkg:golang/github.com/platinasystems/goes" {
+		t.Error("purl:", s)
+	}
+	for name, write := range map[string]func(*strings.Builder) error{
+		"spdx":      func(b *strings.Builder) error { return bom.SPDX(b) },
+		"cyclonedx": func(b *strings.Builder) error { return bom.CycloneDX(b) },
+	} {
+		buf := new(strings.Builder)
+		if err := write(buf); err != nil {
+			t.Fatal(name, err)
+		}
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(buf.String()), &v); err != nil {
+			t.Fatal(name, err)
+		}
+		if !strings.Contains(buf.String(), `"BSD-3-Clause"`) ||
+			!strings.Contains(buf.String(), bom.Modules[1].Sha256()) {
+			t.Error(name, buf)
+		}
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright © 2017-2021 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package shellutils
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var ErrDivideByZero = errors.New("division by zero")
+
+// Arith returns the 64 bit integer value of an arithmetic expansion, e.g.
+// the "PORT * 4 + 1" of $((PORT * 4 + 1)). The expression has these
+// operators of C, from highest to lowest precedence,
+//
+//	( )
+//	- + ! ~		unary
+//	**		exponent
+//	* / %
+//	+ -
+//	<< >>
+//	< <= > >=
+//	== !=
+//	&
+//	^
+//	|
+//	&&
+//	||
+//	? :
+//
+// and decimal, 0x hex, or 0 prefaced octal numbers. A NAME, $NAME, or
+// ${NAME} has the integer value of the variable or 0 if it's unset or empty.
+func Arith(expr string, getenv func(string) string) (int64, error) {
+	p := &arith{s: expr, getenv: getenv}
+	p.next()
+	v, err := p.ternary()
+	if err == nil && p.tok != "" {
+		err = fmt.Errorf("unexpected %q", p.tok)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("$((%s)): %w", expr, err)
+	}
+	return v, nil
+}
+
+// checkArith returns the syntax error, if any, of the expression.
+func checkArith(expr string) error {
+	p := &arith{s: expr, skip: 1}
+	p.next()
+	_, err := p.ternary()
+	if err == nil && p.tok != "" {
+		err = fmt.Errorf("unexpected %q", p.tok)
+	}
+	return err
+}
+
+type arith struct {
+	s      string
+	tok    string
+	getenv func(string) string
+	// skip > 0 parses without evaluation, e.g. that of the short-circuit
+	// of && and ||
+	skip int
+}
+
+var arithOps = []string{
+	"**", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "&", "^", "|", "!", "~", "?", ":",
+	"(", ")",
+}
+
+// next token of the expression, or "" at its end.
+func (p *arith) next() {
+	p.s = strings.TrimLeftFunc(p.s, unicode.IsSpace)
+	if len(p.s) == 0 {
+		p.tok = ""
+		return
+	}
+	n := 0
+	switch c := p.s[0]; {
+	case c == '$' && len(p.s) > 1 && p.s[1] == '{':
+		if n = strings.IndexByte(p.s, '}') + 1; n == 0 {
+			n = len(p.s)
+		}
+	case c == '$' || c == '_' || isAlnum(c):
+		for n = 1; n < len(p.s) && (p.s[n] == '_' || isAlnum(p.s[n])); n++ {
+		}
+	default:
+		n = 1
+		for _, op := range arithOps {
+			if strings.HasPrefix(p.s, op) {
+				n = len(op)
+				break
+			}
+		}
+	}
+	p.tok, p.s = p.s[:n], p.s[n:]
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z'
+}
+
+func (p *arith) ternary() (int64, error) {
+	cond, err := p.binary(0)
+	if err != nil || p.tok != "?" {
+		return cond, err
+	}
+	p.next()
+	if cond == 0 {
+		p.skip++
+	}
+	a, err := p.ternary()
+	if cond == 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+	if p.tok != ":" {
+		return 0, errors.New("missing ':'")
+	}
+	p.next()
+	if cond != 0 {
+		p.skip++
+	}
+	b, err := p.ternary()
+	if cond != 0 {
+		p.skip--
+		return a, err
+	}
+	return b, err
+}
+
+// arithLevels are the binary operators from lowest to highest precedence.
+var arithLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *arith) binary(level int) (int64, error) {
+	if level == len(arithLevels) {
+		return p.exponent()
+	}
+	a, err := p.binary(level + 1)
+	for err == nil && has(arithLevels[level], p.tok) {
+		op := p.tok
+		p.next()
+		// short-circuit
+		skips := op == "&&" && a == 0 || op == "||" && a != 0
+		if skips {
+			p.skip++
+		}
+		var b int64
+		b, err = p.binary(level + 1)
+		if skips {
+			p.skip--
+		}
+		if err == nil {
+			a, err = p.apply(op, a, b)
+		}
+	}
+	return a, err
+}
+
+// exponent is right associative, e.g. 2**3**2 is 2**9.
+func (p *arith) exponent() (int64, error) {
+	a, err := p.unary()
+	if err != nil || p.tok != "**" {
+		return a, err
+	}
+	p.next()
+	b, err := p.exponent()
+	if err != nil {
+		return 0, err
+	}
+	return p.apply("**", a, b)
+}
+
+func (p *arith) unary() (int64, error) {
+	switch op := p.tok; op {
+	case "-", "+", "!", "~":
+		p.next()
+		v, err := p.unary()
+		switch op {
+		case "-":
+			v = -v
+		case "!":
+			v = bool64(v == 0)
+		case "~":
+			v = ^v
+		}
+		return v, err
+	}
+	return p.primary()
+}
+
+func (p *arith) primary() (int64, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return 0, errors.New("missing operand")
+	case tok == "(":
+		p.next()
+		v, err := p.ternary()
+		if err != nil {
+			return 0, err
+		}
+		if p.tok != ")" {
+			return 0, errors.New("missing ')'")
+		}
+		p.next()
+		return v, nil
+	case tok[0] >= '0' && tok[0] <= '9':
+		p.next()
+		v, err := strconv.ParseInt(tok, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s: invalid number", tok)
+		}
+		return v, nil
+	case tok[0] == '$' || tok[0] == '_' || isAlnum(tok[0]):
+		p.next()
+		name := strings.TrimPrefix(tok, "$")
+		if strings.HasPrefix(name, "{") {
+			if !strings.HasSuffix(name, "}") {
+				return 0, errors.New("missing '}'")
+			}
+			name = name[1 : len(name)-1]
+		}
+		if len(name) == 0 {
+			return 0, fmt.Errorf("unexpected %q", tok)
+		}
+		if p.skip > 0 {
+			return 0, nil
+		}
+		s := strings.TrimSpace(p.getenv(name))
+		if len(s) == 0 {
+			return 0, nil
+		}
+		v, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s=%q: not an integer", name, s)
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("unexpected %q", tok)
+}
+
+func (p *arith) apply(op string, a, b int64) (int64, error) {
+	if p.skip > 0 {
+		return 0, nil
+	}
+	switch op {
+	case "||":
+		return bool64(a != 0 || b != 0), nil
+	case "&&":
+		return bool64(a != 0 && b != 0), nil
+	case "|":
+		return a | b, nil
+	case "^":
+		return a ^ b, nil
+	case "&":
+		return a & b, nil
+	case "==":
+		return bool64(a == b), nil
+	case "!=":
+		return bool64(a != b), nil
+	case "<":
+		return bool64(a < b), nil
+	case "<=":
+		return bool64(a <= b), nil
+	case ">":
+		return bool64(a > b), nil
+	case ">=":
+		return bool64(a >= b), nil
+	case "<<", ">>":
+		if b < 0 {
+			return 0, errors.New("negative shift")
+		}
+		if op == "<<" {
+			return a << uint64(b), nil
+		}
+		return a >> uint64(b), nil
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/", "%":
+		if b == 0 {
+			return 0, ErrDivideByZero
+		}
+		if op == "/" {
+			return a / b, nil
+		}
+		return a % b, nil
+	case "**":
+		if b < 0 {
+			return 0, errors.New("negative exponent")
+		}
+		v := int64(1)
+		for ; b > 0; b >>= 1 {
+			if b&1 != 0 {
+				v *= a
+			}
+			a *= a
+		}
+		return v, nil
+	}
+	return 0, fmt.Errorf("unexpected %q", op)
+}
+
+func bool64(t bool) int64 {
+	if t {
+		return 1
+	}
+	return 0
+}
+
+func has(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
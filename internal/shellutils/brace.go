@@ -0,0 +1,167 @@
+// Copyright © 2017-2021 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package shellutils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxBraceSequence is the largest {X..Y} that is expanded; others remain
+// as is.
+const MaxBraceSequence = 1 << 16
+
+// braceDepth returns the number of unclosed braces of the word being
+// parsed; unquoted ',' and '}' are brace tokens only within these.
+func (w *Word) braceDepth() int {
+	depth := 0
+	for _, t := range w.Tokens {
+		if t.T == TokenBrace {
+			switch t.V {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+		}
+	}
+	return depth
+}
+
+// braces returns the words of the brace expansion of this, e.g.
+//
+//	eth-{0..1}-{1,2}
+//
+// expands to
+//
+//	eth-0-1 eth-0-2 eth-1-1 eth-1-2
+//
+// The braces of a group without a comma or valid sequence remain as is.
+func (w *Word) braces() []Word {
+	toks := w.Tokens
+	for i, t := range toks {
+		if t.T != TokenBrace || t.V != "{" {
+			continue
+		}
+		var commas []int
+		depth, end := 0, -1
+		for j := i + 1; j < len(toks) && end < 0; j++ {
+			if toks[j].T != TokenBrace {
+				continue
+			}
+			switch toks[j].V {
+			case "{":
+				depth++
+			case "}":
+				if depth == 0 {
+					end = j
+				}
+				depth--
+			case ",":
+				if depth == 0 {
+					commas = append(commas, j)
+				}
+			}
+		}
+		if end < 0 {
+			continue
+		}
+		var alts [][]Token
+		if len(commas) > 0 {
+			start := i + 1
+			for _, j := range append(commas, end) {
+				alts = append(alts, toks[start:j])
+				start = j + 1
+			}
+		} else if seq := sequence(toks[i+1 : end]); seq != nil {
+			for _, s := range seq {
+				alts = append(alts, []Token{{V: s, T: TokenLiteral}})
+			}
+		} else {
+			continue
+		}
+		var words []Word
+		for _, alt := range alts {
+			x := Word{Tokens: make([]Token, 0, len(toks))}
+			x.Tokens = append(x.Tokens, toks[:i]...)
+			x.Tokens = append(x.Tokens, alt...)
+			x.Tokens = append(x.Tokens, toks[end+1:]...)
+			words = append(words, x.braces()...)
+		}
+		return words
+	}
+	return []Word{*w}
+}
+
+// sequence returns the expansion of {X..Y[..INCR]}, where X and Y are
+// integers or letters, or nil if it isn't one. Integers with leading zeros
+// are padded to the same width.
+func sequence(toks []Token) []string {
+	if len(toks) != 1 || toks[0].T != TokenLiteral {
+		return nil
+	}
+	parts := strings.Split(toks[0].V, "..")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil
+	}
+	incr := 1
+	if len(parts) == 3 {
+		n, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil
+		}
+		if n < 0 {
+			n = -n
+		}
+		if n > 0 {
+			incr = n
+		}
+	}
+	x, xerr := strconv.Atoi(parts[0])
+	y, yerr := strconv.Atoi(parts[1])
+	if xerr != nil || yerr != nil {
+		if !isLetter(parts[0]) || !isLetter(parts[1]) {
+			return nil
+		}
+		x, y = int(parts[0][0]), int(parts[1][0])
+	}
+	n := y - x
+	if n < 0 {
+		n = -n
+	}
+	if n/incr >= MaxBraceSequence {
+		return nil
+	}
+	if y < x {
+		incr = -incr
+	}
+	width := 0
+	if isPadded(parts[0]) || isPadded(parts[1]) {
+		width = len(parts[0])
+		if len(parts[1]) > width {
+			width = len(parts[1])
+		}
+	}
+	var list []string
+	for i := x; incr > 0 && i <= y || incr < 0 && i >= y; i += incr {
+		if xerr != nil {
+			list = append(list, string(rune(i)))
+		} else {
+			list = append(list, fmt.Sprintf("%0*d", width, i))
+		}
+	}
+	return list
+}
+
+func isLetter(s string) bool {
+	return len(s) == 1 && (s[0] >= 'a' && s[0] <= 'z' ||
+		s[0] >= 'A' && s[0] <= 'Z')
+}
+
+func isPadded(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
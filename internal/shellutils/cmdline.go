@@ -7,6 +7,8 @@ package shellutils
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 // Cmdline is a slice of Words which may be variable setting, a command,
@@ -27,54 +29,117 @@ func (c *Cmdline) add(w *Word) {
 
 // Slice takes a parsed command line and returns a
 // map of the environment variables declared in the command,
-// and a slice of the command and its arguments as strings
-func (c *Cmdline) Slice(getenv func(string) string) (map[string]string, []string) {
+// and a slice of the command and its arguments as strings,
+// or the error of an arithmetic expansion. Each argument is
+// brace expanded, then, a leading ~ or ~USER is replaced by the
+// home directory, and an argument with
+// unquoted glob characters is replaced by the sorted pathnames
+// that it matches, if any.
+func (c *Cmdline) Slice(getenv func(string) string) (map[string]string, []string, error) {
 	envmap := make(map[string]string)
 	Cmdline := make([]string, 0)
 
+	var words []Word
+	assigns := true
 	for _, w := range c.Cmds {
+		// the leading assignments aren't brace expanded
+		if assigns = assigns && w.isEnvset(); assigns {
+			words = append(words, w)
+		} else {
+			words = append(words, w.braces()...)
+		}
+	}
+	for _, w := range words {
 		s := ""
+		// the glob pattern of the word with its other text escaped
+		pattern := ""
+		isGlob := false
 		isEnvset := false
 		envsetOffset := 0
 		for _, t := range w.Tokens {
+			v := t.V
 			switch t.T {
-			case TokenLiteral:
-				s += t.V
+			case TokenLiteral, TokenBrace:
 			case TokenEnvget:
-				s += getenv(t.V)
+				v = getenv(t.V)
+			case TokenTilde:
+				v = tilde(t.V, getenv)
+			case TokenArith:
+				i, err := Arith(t.V, getenv)
+				if err != nil {
+					return nil, nil, err
+				}
+				v = strconv.FormatInt(i, 10)
 			case TokenEnvset:
 				if !isEnvset {
 					isEnvset = true
 					envsetOffset = len(s)
 				}
-				s += t.V
 			case TokenGlob:
-				match, err := filepath.Glob(t.V)
-				if match == nil || err != nil {
-					s += t.V
-					continue
-				}
-				s += match[0]
-				if len(match) == 1 {
-					continue
-				}
-				Cmdline = append(Cmdline, s)
-				match = match[1:]
-				if len(match) > 1 {
-					Cmdline = append(Cmdline,
-						match[:len(match)-1]...)
-					match = match[len(match)-1:]
-				}
-				s = match[0]
+				isGlob = true
+				s += v
+				pattern += globPattern(v)
+				continue
 			default:
 				panic(fmt.Errorf("Unknown Token %v", t))
 			}
+			s += v
+			pattern += globEscape(v)
 		}
 		if len(Cmdline) == 0 && isEnvset && envsetOffset != 0 {
 			envmap[s[0:envsetOffset]] = s[envsetOffset+1:]
+		} else if match, err := filepath.Glob(pattern); isGlob &&
+			err == nil && len(match) > 0 {
+			Cmdline = append(Cmdline, match...)
 		} else {
 			Cmdline = append(Cmdline, s)
 		}
 	}
-	return envmap, Cmdline
+	return envmap, Cmdline, nil
+}
+
+// isEnvset returns true if the word has an unquoted '=' after its first
+// character, e.g. NAME=VALUE.
+func (w *Word) isEnvset() bool {
+	for i, t := range w.Tokens {
+		if t.T == TokenEnvset {
+			return i > 0
+		}
+	}
+	return false
+}
+
+// globEscape returns the text with its glob characters escaped.
+func globEscape(s string) string {
+	if !strings.ContainsAny(s, `*?[\`) {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// globPattern returns the filepath.Match pattern of a glob token, i.e. with
+// the [!...] negation and leading ']' of a shell bracket expression as
+// [^...] and '\]'.
+func globPattern(s string) string {
+	if !strings.HasPrefix(s, "[") {
+		return s
+	}
+	s = s[1:]
+	p := "["
+	if strings.HasPrefix(s, "!") || strings.HasPrefix(s, "^") {
+		p += "^"
+		s = s[1:]
+	}
+	if strings.HasPrefix(s, "]") {
+		p += `\]`
+		s = s[1:]
+	}
+	return p + s
 }
@@ -0,0 +1,59 @@
+// Copyright © 2017-2021 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package shellutils
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Positioner is an optional interface of the Parse input that returns the
+// name of its source, e.g. "script.goes", and the number of the line last
+// read. Without it, the lines are numbered from the start of each Parse.
+type Positioner interface {
+	Position() (name string, line int)
+}
+
+// SyntaxError is a parse error at the Line and Col, both from 1, of the
+// named source; its Error() is that of GNU compilers, e.g.
+//
+//	script.goes:14:7: unexpected ')'
+//		echo a)
+//		      ^
+type SyntaxError struct {
+	Name string
+	Line int
+	Col  int
+	// Text of the line
+	Text string
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	buf := new(strings.Builder)
+	if len(e.Name) > 0 {
+		fmt.Fprint(buf, e.Name, ":")
+	}
+	fmt.Fprint(buf, e.Line, ":", e.Col, ": ", e.Err)
+	if text := strings.TrimRight(e.Text, "\r\n"); len(text) > 0 {
+		fmt.Fprint(buf, "\n\t", text, "\n\t")
+		// keep the tabs of the line so that the caret lines up
+		for i, r := range []rune(text) {
+			if i >= e.Col-1 {
+				break
+			}
+			if unicode.IsSpace(r) {
+				buf.WriteRune(r)
+			} else {
+				buf.WriteRune(' ')
+			}
+		}
+		buf.WriteRune('^')
+	}
+	return buf.String()
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
@@ -3,9 +3,12 @@
 // LICENSE file.
 package shellutils
 
+import "strings"
+
 // List is a slice of pipelines. The pipelines were concatenated via
 // unconditional execution operators (; and &) or conditional
-// execution operators (|| and &&).
+// execution operators (|| and &&). A list terminated by & is run in the
+// background.
 type List struct {
 	Cmds []Cmdline
 }
@@ -17,3 +20,17 @@ func (ls *List) add(cl *Cmdline) {
 	ls.Cmds = append(ls.Cmds, *cl)
 	*cl = Cmdline{}
 }
+
+// String returns the words and terminators of the list's command lines.
+func (ls *List) String() string {
+	var words []string
+	for _, cl := range ls.Cmds {
+		for _, w := range cl.Cmds {
+			words = append(words, w.String())
+		}
+		if s := cl.Term.String(); len(s) > 0 {
+			words = append(words, s)
+		}
+	}
+	return strings.Join(words, " ")
+}
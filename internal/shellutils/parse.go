@@ -5,6 +5,7 @@ package shellutils
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"unicode"
@@ -13,12 +14,10 @@ import (
 
 var ErrMissingEndQuote = errors.New("Unexpected EOF while looking for matching quote")
 
-func srcin(i io.ReadWriter, prompt string) (s string, err error) {
-	i.Write([]byte(prompt))
-	buf := make([]byte, 1024)
-	n, err := i.Read(buf)
-	s = string(buf[0:n])
-	return
+// LineReader returns the next line of command input, without its newline,
+// after printing the prompt, if interactive.
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
 }
 
 // break up string into Lists, Pipelines, and command lines
@@ -26,10 +25,43 @@ func srcin(i io.ReadWriter, prompt string) (s string, err error) {
 // a Pipeline is a slice of commandlines []Cmdline{}
 // a command line is a set of arguments and a terminator
 
-// Parse calls the srcin function for command input as strings, and
-// return a pointer to a parsed command List, or an error
-func Parse(prompt string, i io.ReadWriter) (*List, error) {
-	s, err := srcin(i, prompt)
+// Parse reads command input from the LineReader and returns a pointer to a
+// parsed command List, or an error. Errors of the input syntax are a
+// *SyntaxError of the offending line and column.
+func Parse(prompt string, i LineReader) (*List, error) {
+	var (
+		name, line string
+		lineno     int
+		depth      int
+		open       *SyntaxError
+	)
+	positioner, _ := i.(Positioner)
+	read := func(prompt string) (string, error) {
+		s, err := i.ReadLine(prompt)
+		line = s
+		if positioner != nil {
+			name, lineno = positioner.Position()
+		} else {
+			lineno++
+		}
+		return s, err
+	}
+	// at returns the syntax error of the rune before the rest of line
+	at := func(rest string, err error) *SyntaxError {
+		return &SyntaxError{
+			Name: name,
+			Line: lineno,
+			Col:  utf8.RuneCountInString(line[:len(line)-len(rest)]),
+			Text: line,
+			Err:  err,
+		}
+	}
+	unexpected := func(rest, token string) *SyntaxError {
+		err := at(rest, fmt.Errorf("unexpected '%s'", token))
+		err.Col -= utf8.RuneCountInString(token) - 1
+		return err
+	}
+	s, err := read(prompt)
 	if err != nil {
 		return nil, err
 	}
@@ -68,8 +100,24 @@ processRune:
 				s = s[1:]
 				w.addLiteral(string(r))
 			}
-			if w.String() == ";" || w.String() == "&&" ||
-				w.String() == "||" {
+			switch r {
+			case '(':
+				if depth == 0 {
+					open = unexpected(s, w.String())
+					open.Err = errors.New("missing ')'")
+				}
+				depth += len(w.String())
+			case ')':
+				if depth < len(w.String()) {
+					return nil, unexpected(s, w.String())
+				}
+				depth -= len(w.String())
+			}
+			if w.String() == ";" || w.String() == "&" ||
+				w.String() == "&&" || w.String() == "||" {
+				if len(c.Cmds) == 0 {
+					return nil, unexpected(s, w.String())
+				}
 				c.Term = w
 				w = Word{}
 				cl.add(&c)
@@ -94,6 +142,9 @@ processRune:
 					}
 				}
 			}
+			if len(c.Cmds) == 0 {
+				return nil, unexpected(s, w.String())
+			}
 			c.Term = w
 			cl.add(&c)
 			w = Word{}
@@ -107,9 +158,10 @@ processRune:
 		}
 
 		if r == '$' && len(s) > 0 {
+			rest := s
 			s, err = w.parseEnv(s)
 			if err != nil {
-				return nil, err
+				return nil, at(rest, err)
 			}
 			continue
 		}
@@ -134,6 +186,7 @@ processRune:
 		}
 
 		if r == '\'' {
+			quote := at(s, ErrMissingEndQuote)
 			for {
 				for len(s) > 0 {
 					r, wid := utf8.DecodeRuneInString(s)
@@ -144,16 +197,17 @@ processRune:
 					w.addLiteral(string(r))
 				}
 				w.addLiteral("\n")
-				s, err = srcin(i, "> ")
+				s, err = read("> ")
 				if err != nil {
 					if err == io.EOF {
-						return nil, ErrMissingEndQuote
+						return nil, quote
 					}
 					return nil, err
 				}
 			}
 		}
 		if r == '"' {
+			quote := at(s, ErrMissingEndQuote)
 			for {
 				for len(s) > 0 {
 					r, wid := utf8.DecodeRuneInString(s)
@@ -163,18 +217,19 @@ processRune:
 					}
 
 					if r == '$' && len(s) > 0 {
+						rest := s
 						s, err = w.parseEnv(s)
 						if err != nil {
-							return nil, err
+							return nil, at(rest, err)
 						}
 						continue
 					}
 					if r == '\\' {
 						if len(s) == 0 {
-							s, err = srcin(i, "> ")
+							s, err = read("> ")
 							if err != nil {
 								if err == io.EOF {
-									return nil, ErrMissingEndQuote
+									return nil, quote
 								}
 								return nil, err
 							}
@@ -189,10 +244,10 @@ processRune:
 					w.addLiteral(string(r))
 				}
 				w.addLiteral("\n")
-				s, err = srcin(i, "> ")
+				s, err = read("> ")
 				if err != nil {
 					if err == io.EOF {
-						return nil, ErrMissingEndQuote
+						return nil, quote
 					}
 					return nil, err
 				}
@@ -205,28 +260,42 @@ processRune:
 				w.addLiteral(string(r))
 				continue
 			}
-			s, err = srcin(i, "... ")
+			s, err = read("... ")
 			if err != nil {
 				return nil, err
 			}
 			continue
 		}
-		if r == '*' || r == '?' || r == '[' {
-			glob := string(r)
-			for len(s) > 0 {
-				r, wid := utf8.DecodeRuneInString(s)
-				if unicode.IsSpace(r) ||
-					strings.ContainsRune("|&;()<>", r) {
-					break
-				}
-				s = s[wid:]
-				glob = glob + string(r)
+		if r == '*' || r == '?' {
+			w.add(string(r), TokenGlob)
+			continue
+		}
+		if r == '[' {
+			if n := bracketLen(s); n > 0 {
+				w.add("["+s[:n], TokenGlob)
+				s = s[n:]
+			} else {
+				w.addLiteral("[")
+			}
+			continue
+		}
+		if r == '~' && (len(w.Tokens) == 0 ||
+			w.Tokens[len(w.Tokens)-1].T == TokenEnvset) {
+			if n := tildeLen(s); n >= 0 {
+				w.add(s[:n], TokenTilde)
+				s = s[n:]
+				continue
 			}
-			w.add(glob, TokenGlob)
+		}
+		if r == '{' || (r == ',' || r == '}') && w.braceDepth() > 0 {
+			w.add(string(r), TokenBrace)
 			continue
 		}
 		w.addLiteral(string(r))
 	}
+	if depth > 0 {
+		return nil, open
+	}
 	if len(w.Tokens) != 0 {
 		c.add(&w)
 	}
@@ -235,3 +304,26 @@ processRune:
 	}
 	return &cl, nil
 }
+
+// bracketLen returns the length of the rest of an unquoted bracket
+// expression through its closing ']', e.g. "a-z]" of "[a-z]", or 0 if it
+// isn't one, e.g. the test command, "[".
+func bracketLen(s string) int {
+	i := 0
+	if i < len(s) && (s[i] == '!' || s[i] == '^') {
+		i++
+	}
+	if i < len(s) && s[i] == ']' {
+		i++
+	}
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ']':
+			return i + 1
+		case c == ' ' || c == '\t' ||
+			strings.IndexByte("|&;()<>'\"\\$", c) >= 0:
+			return 0
+		}
+	}
+	return 0
+}
@@ -7,7 +7,11 @@ package shellutils
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"os/user"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -17,21 +21,13 @@ type ts struct {
 	script []string
 }
 
-func (t *ts) Write(p []byte) (n int, err error) {
-	return n, nil
-}
-
-func (t *ts) Read(p []byte) (n int, err error) {
+func (t *ts) ReadLine(prompt string) (string, error) {
 	if t.line >= len(t.script) {
-		return 0, errors.New("parser asked for too much input")
+		return "", errors.New("parser asked for too much input")
 	}
 	s := t.script[t.line]
 	t.line += 1
-	n = copy(p, []byte(s))
-	if len(s) > len(p) {
-		err = errors.New("input too long")
-	}
-	return
+	return s, nil
 }
 
 func testSlice(script []string) (*List, error) {
@@ -51,7 +47,7 @@ func testSlice(script []string) (*List, error) {
 
 func (ls *List) print() {
 	for _, sl := range ls.Cmds {
-		_, cmdline := sl.Slice(os.Getenv)
+		_, cmdline, _ := sl.Slice(os.Getenv)
 		term := sl.Term.String()
 		if term == "" {
 			term = "\n"
@@ -123,3 +119,227 @@ func TestDoublequote(t *testing.T) {
 
 	cmd.print()
 }
+
+type named struct {
+	ts
+}
+
+func (t *named) Position() (string, int) { return "script.goes", 13 + t.line }
+
+func (t *named) ReadLine(prompt string) (string, error) {
+	if t.line >= len(t.script) {
+		return "", io.EOF
+	}
+	return t.ts.ReadLine(prompt)
+}
+
+func TestSyntaxError(t *testing.T) {
+	for _, x := range []struct {
+		script []string
+		want   string
+	}{
+		{[]string{"echo a)"}, "script.goes:14:7: unexpected ')'"},
+		{[]string{"ls | || wc"}, "script.goes:14:6: unexpected '||'"},
+		{[]string{"\t[ ( -f x ]"}, "script.goes:14:4: missing ')'"},
+		{[]string{"echo ${a b}"}, "script.goes:14:6: Unexpected ` '"},
+		{[]string{"echo 'a", "b"}, "script.goes:14:6: " +
+			ErrMissingEndQuote.Error()},
+	} {
+		_, err := Parse(">", &named{ts{script: x.script}})
+		serr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: %v", x.script, err)
+			continue
+		}
+		if s := strings.SplitN(serr.Error(), "\n", 2)[0]; s != x.want {
+			t.Errorf("%q: %q", x.script, s)
+		}
+	}
+	_, err := Parse(">", &ts{script: []string{"echo a)"}})
+	want := "1:7: unexpected ')'\n\techo a)\n\t      ^"
+	if err == nil || err.Error() != want {
+		t.Errorf("%q", err)
+	}
+	_, err = Parse(">", &named{ts{script: []string{"echo '"}}})
+	if !errors.Is(err, ErrMissingEndQuote) {
+		t.Error(err)
+	}
+	if _, err = testSlice([]string{"[ ( -f x ) ]"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBackground(t *testing.T) {
+	ls, err := testSlice([]string{"sleep 5 | wc & echo a&&echo b &"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var terms []string
+	for _, cl := range ls.Cmds {
+		terms = append(terms, cl.Term.String())
+	}
+	if s := strings.Join(terms, " "); s != "| & && &" {
+		t.Error(s)
+	}
+	if _, err = testSlice([]string{"& echo"}); err == nil {
+		t.Error("expected error of leading '&'")
+	}
+}
+
+func TestArith(t *testing.T) {
+	env := map[string]string{"PORT": "3", "HEX": "0x10", "BAD": "x"}
+	getenv := func(k string) string { return env[k] }
+	for _, x := range []struct {
+		expr string
+		want int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"PORT * 4 + 1", 13},
+		{"$PORT * ${HEX}", 48},
+		{"UNSET + 1", 1},
+		{"-2 ** 2", 4},
+		{"2 ** 3 ** 2", 512},
+		{"7 / 2 + 7 % 2", 4},
+		{"1 << 4 | 1", 17},
+		{"!0 && ~0 == -1", 1},
+		{"PORT > 2 ? PORT - 2 : 0", 1},
+		{"0 && 1 / 0", 0},
+		{"010 + 0x0f", 23},
+	} {
+		v, err := Arith(x.expr, getenv)
+		if err != nil || v != x.want {
+			t.Errorf("%q: %d %v", x.expr, v, err)
+		}
+	}
+	for _, expr := range []string{"1 / 0", "BAD + 1", "1 +", "(1", "1 2"} {
+		if _, err := Arith(expr, getenv); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+	ls, err := testSlice([]string{`echo $((PORT+1)) "eth-$((PORT / 2))-$(( (PORT % 2) * 2 ))"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, args, err := ls.Cmds[0].Slice(getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(args, " "); s != "echo 4 eth-1-2" {
+		t.Error(s)
+	}
+	if _, err = testSlice([]string{"echo $((1 +))"}); err == nil {
+		t.Error("expected syntax error")
+	}
+}
+
+func TestGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "glob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, fn := range []string{"a.old", "b.old", "c.log", "a b.old", "[x]"} {
+		ioutil.WriteFile(filepath.Join(dir, fn), nil, 0644)
+	}
+	getenv := func(k string) string {
+		if k == "D" {
+			return dir
+		}
+		return ""
+	}
+	for _, x := range []struct {
+		line string
+		want []string
+	}{
+		{"rm $D/*.old", []string{"rm", dir + "/a b.old", dir + "/a.old",
+			dir + "/b.old"}},
+		{"ls $D/?.old", []string{"ls", dir + "/a.old", dir + "/b.old"}},
+		{"ls $D/[!ab].*", []string{"ls", dir + "/c.log"}},
+		{"ls $D/[ab]' '*", []string{"ls", dir + "/a b.old"}},
+		{"ls $D/'*'.old \"$D/*\" $D/\\*", []string{"ls", dir + "/*.old",
+			dir + "/*", dir + "/*"}},
+		{"ls $D/*.none", []string{"ls", dir + "/*.none"}},
+		{"[ -f $D/[[]x] ]", []string{"[", "-f", dir + "/[x]", "]"}},
+		{"X=*.old", nil},
+	} {
+		ls, err := testSlice([]string{x.line})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, args, err := ls.Cmds[0].Slice(getenv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(args) != fmt.Sprint(x.want) {
+			t.Errorf("%s: %q", x.line, args)
+		}
+	}
+}
+
+func TestBraces(t *testing.T) {
+	for _, x := range []struct {
+		line, want string
+	}{
+		{"echo eth-{0..1}-{1,2}", "echo eth-0-1 eth-0-2 eth-1-1 eth-1-2"},
+		{"echo {3..1} {a..c} {0..10..5} {08..10}", "echo 3 2 1 a b c 0 5 10 08 09 10"},
+		{"echo a{b,c{d,e}}f", "echo abf acdf acef"},
+		{"echo {a,$X}.{b,'c,d'}", "echo a.b a.c,d x.b x.c,d"},
+		{"echo '{a,b}' \\{a,b} {a} {} {a..} a,b", "echo {a,b} {a,b} {a} {} {a..} a,b"},
+		{"echo {x{a,b}", "echo {xa {xb"},
+		{"X={a,b} echo {1..2}", "echo 1 2"},
+	} {
+		ls, err := testSlice([]string{x.line})
+		if err != nil {
+			t.Fatal(err)
+		}
+		env, args, err := ls.Cmds[0].Slice(func(string) string {
+			return "x"
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := strings.Join(args, " "); s != x.want {
+			t.Errorf("%s: %q", x.line, s)
+		}
+		if x, found := env["X"]; found && x != "{a,b}" {
+			t.Error("X:", x)
+		}
+	}
+}
+
+func TestTilde(t *testing.T) {
+	getenv := func(k string) string {
+		if k == "HOME" {
+			return "/home/me"
+		}
+		return ""
+	}
+	want := "ls /home/me /home/me/bin a~ ~ ~/x '~' ~nosuchuser/x ~\"x\""
+	if u, err := user.Lookup("root"); err == nil {
+		want += " " + u.HomeDir + "/.ssh"
+	} else {
+		want += " ~root/.ssh"
+	}
+	ls, err := testSlice([]string{
+		"ls ~ ~/bin a~ '~' \"~/x\" \\'~\\' ~nosuchuser/x ~'\"x\"' ~root/.ssh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, args, err := ls.Cmds[0].Slice(getenv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(args, " "); s != want {
+		t.Errorf("%q", s)
+	}
+	ls, err = testSlice([]string{"P=~/bin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _, _ := ls.Cmds[0].Slice(getenv)
+	if s := env["P"]; s != "/home/me/bin" {
+		t.Error("P:", s)
+	}
+}
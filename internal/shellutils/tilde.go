@@ -0,0 +1,54 @@
+// Copyright © 2017-2021 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package shellutils
+
+import (
+	"os/user"
+	"strings"
+)
+
+// tildeLen returns the length of the login name following an unquoted '~'
+// at the start of a word or assignment value, or -1 if its prefix isn't a
+// login name, e.g. that of ~"x".
+func tildeLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '_' || c == '-' || c == '.' || isAlnum(c):
+		case c == '/' || c == ' ' || c == '\t' ||
+			strings.IndexByte("|&;()<>", c) >= 0:
+			return i
+		default:
+			return -1
+		}
+	}
+	return len(s)
+}
+
+// home returns $HOME, or the home directory of the current or named user,
+// with true; or false if there isn't one.
+func home(name string, getenv func(string) string) (string, bool) {
+	if len(name) == 0 {
+		if dir := getenv("HOME"); len(dir) > 0 {
+			return dir, true
+		}
+		if u, err := user.Current(); err == nil && len(u.HomeDir) > 0 {
+			return u.HomeDir, true
+		}
+		return "", false
+	}
+	if u, err := user.Lookup(name); err == nil && len(u.HomeDir) > 0 {
+		return u.HomeDir, true
+	}
+	return "", false
+}
+
+// tilde returns the expansion of a TokenTilde or, if it doesn't have a
+// home, the token as is.
+func tilde(name string, getenv func(string) string) string {
+	if dir, found := home(name, getenv); found {
+		return dir
+	}
+	return "~" + name
+}
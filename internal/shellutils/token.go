@@ -12,6 +12,11 @@ package shellutils
 // tokenEnvset is the operator to set an environment variable. The string is
 // the assignment operator, i.e. =. This is represented as a token to prevent
 // quoted = characters to be interpreted as setting environment variables
+// tokenArith is the expression of an arithmetic expansion, $((expr)), that's
+// replaced by its value
+// tokenBrace is an unquoted {, comma, or } of a brace expansion
+// tokenTilde is the login name, or "" for that of the user, of an unquoted
+// ~ prefix that's replaced by the home directory
 type Tokentype int
 
 const (
@@ -19,6 +24,9 @@ const (
 	TokenEnvget
 	TokenEnvset
 	TokenGlob
+	TokenArith
+	TokenBrace
+	TokenTilde
 )
 
 // Token is a type and a string value. During parsing, we convert
@@ -7,6 +7,7 @@ package shellutils
 import (
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"unicode"
@@ -44,6 +45,9 @@ func (w *Word) addLiteral(s string) {
 }
 
 func (w *Word) parseEnv(s string) (string, error) {
+	if strings.HasPrefix(s, "((") {
+		return w.parseArith(s[2:])
+	}
 	envvar := ""
 	if s[0] == '{' {
 		s = s[1:]
@@ -74,44 +78,83 @@ func (w *Word) parseEnv(s string) (string, error) {
 	return s, nil
 }
 
+// parseArith adds the expression of an arithmetic expansion up to its
+// closing "))".
+func (w *Word) parseArith(s string) (string, error) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == ')' {
+				if err := checkArith(s[:i]); err != nil {
+					return "", err
+				}
+				w.add(s[:i], TokenArith)
+				return s[i+2:], nil
+			}
+			return "", errors.New("missing '))'")
+		}
+	}
+	return "", errors.New("missing '))'")
+}
+
 func (w *Word) String() string {
 	s := ""
 	for _, t := range w.Tokens {
-		s += t.V
+		if t.T == TokenArith {
+			s += "$((" + t.V + "))"
+		} else if t.T == TokenTilde {
+			s += "~" + t.V
+		} else {
+			s += t.V
+		}
 	}
 	return s
 }
 
-// Expand converts a word into a slice of strings doing glob expansion
-func (w *Word) Expand() (str []string) {
-	s := ""
+// Expand converts a word into a slice of strings doing brace and glob
+// expansion
+func (w *Word) Expand() []string {
+	var str []string
+	for _, x := range w.braces() {
+		str = append(str, x.expand()...)
+	}
+	return str
+}
+
+func (w *Word) expand() []string {
+	s, pattern, isGlob := "", "", false
 	for _, t := range w.Tokens {
 		switch t.T {
-		case TokenLiteral, TokenEnvget, TokenEnvset:
+		case TokenLiteral, TokenEnvget, TokenEnvset, TokenBrace:
 			s += t.V
-
+			pattern += globEscape(t.V)
+		case TokenArith:
+			s += "$((" + t.V + "))"
+			pattern += globEscape("$((" + t.V + "))")
+		case TokenTilde:
+			dir := tilde(t.V, os.Getenv)
+			s += dir
+			pattern += globEscape(dir)
 		case TokenGlob:
-			match, err := filepath.Glob(t.V)
-			if match == nil || err != nil {
-				s += t.V
-				continue
-			}
-			s += match[0]
-			if len(match) == 1 {
-				continue
-			}
-			str = append(str, s)
-			match = match[1:]
-			if len(match) > 1 {
-				str = append(str,
-					match[:len(match)-1]...)
-				match = match[len(match)-1:]
-			}
-			s = match[0]
+			isGlob = true
+			s += t.V
+			pattern += globPattern(t.V)
 		default:
 			panic(fmt.Errorf("Unknown Token %v", t))
 		}
 	}
-	str = append(str, s)
-	return
+	if isGlob {
+		if match, err := filepath.Glob(pattern); err == nil &&
+			len(match) > 0 {
+			return match
+		}
+	}
+	return []string{s}
 }
@@ -0,0 +1,229 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type s3Credentials struct {
+	id, secret, token string
+}
+
+type s3Writer struct {
+	u   *neturl.URL
+	buf bytes.Buffer
+}
+
+func s3Open(u *neturl.URL) (io.ReadCloser, error) {
+	resp, err := s3Do(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// s3Create buffers the object to PUT on Close.
+func s3Create(u *neturl.URL) (io.WriteCloser, error) {
+	if len(strings.TrimPrefix(u.Path, "/")) == 0 {
+		return nil, fmt.Errorf("%s: missing key", u)
+	}
+	return &s3Writer{u: u}, nil
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *s3Writer) Close() error {
+	resp, err := s3Do(http.MethodPut, w.u, w.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func s3Do(method string, u *neturl.URL, body []byte) (*http.Response,
+	error) {
+	if len(u.Host) == 0 {
+		return nil, fmt.Errorf("%s: missing bucket", u)
+	}
+	region := os.Getenv("AWS_REGION")
+	if len(region) == 0 {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+	endpoint, err := s3Endpoint(u, region, os.Getenv("AWS_ENDPOINT_URL"))
+	if err != nil {
+		return nil, err
+	}
+	endpoint.RawPath = s3Escape(endpoint.Path, false)
+	req, err := http.NewRequest(method, endpoint.String(),
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	cred := s3Credentials{
+		id:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secret: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:  os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if len(cred.id) > 0 && len(cred.secret) > 0 {
+		s3Sign(req, cred, region, body, time.Now())
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		if s := s3ErrorCode(msg); len(s) > 0 {
+			return nil, fmt.Errorf("%s: %s", u, s)
+		}
+		return nil, fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+// s3Endpoint of s3://BUCKET/KEY is, by default, the virtual host,
+//
+//	https://BUCKET.s3.REGION.amazonaws.com/KEY
+//
+// or with a given endpoint, e.g. that of a minio server, the path style,
+//
+//	ENDPOINT/BUCKET/KEY
+func s3Endpoint(u *neturl.URL, region, endpoint string) (*neturl.URL,
+	error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if len(endpoint) == 0 {
+		return &neturl.URL{
+			Scheme: "https",
+			Host:   u.Host + ".s3." + region + ".amazonaws.com",
+			Path:   "/" + key,
+		}, nil
+	}
+	ep, err := neturl.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	ep.Path = strings.TrimSuffix(ep.Path, "/") + "/" + u.Host + "/" + key
+	return ep, nil
+}
+
+// s3Sign adds the AWS signature version 4 authorization of the request.
+func s3Sign(req *http.Request, cred s3Credentials, region string,
+	body []byte, t time.Time) {
+	t = t.UTC()
+	amzdate := t.Format("20060102T150405Z")
+	date := amzdate[:8]
+	payload := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzdate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payload[:]))
+	if len(cred.token) > 0 {
+		req.Header.Set("X-Amz-Security-Token", cred.token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(
+			strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonical strings.Builder
+	for _, name := range names {
+		fmt.Fprint(&canonical, name, ":", headers[name], "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		s3Escape(req.URL.Path, false),
+		s3Query(req.URL.Query()),
+		canonical.String(),
+		signed,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(request))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzdate + "\n" + scope + "\n" +
+		hex.EncodeToString(sum[:])
+
+	key := []byte("AWS4" + cred.secret)
+	for _, s := range []string{date, region, "s3", "aws4_request"} {
+		key = s3Hmac(key, s)
+	}
+	req.Header.Set("Authorization", fmt.Sprint(
+		"AWS4-HMAC-SHA256 Credential=", cred.id, "/", scope,
+		",SignedHeaders=", signed,
+		",Signature=", hex.EncodeToString(s3Hmac(key, stringToSign))))
+}
+
+func s3Hmac(key []byte, s string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(s))
+	return h.Sum(nil)
+}
+
+// s3Escape percent encodes all but the unreserved characters of RFC 3986
+// and, unless also escaping these, the path separators.
+func s3Escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z',
+			'0' <= c && c <= '9', c == '-', c == '.', c == '_',
+			c == '~', c == '/' && !slash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func s3Query(q neturl.Values) string {
+	var list []string
+	for name, values := range q {
+		for _, v := range values {
+			list = append(list, s3Escape(name, true)+"="+
+				s3Escape(v, true))
+		}
+	}
+	sort.Strings(list)
+	return strings.Join(list, "&")
+}
+
+// s3ErrorCode returns the Code of an S3 XML error response.
+func s3ErrorCode(b []byte) string {
+	const start, end = "<Code>", "</Code>"
+	i := bytes.Index(b, []byte(start))
+	if i < 0 {
+		return ""
+	}
+	b = b[i+len(start):]
+	if i = bytes.Index(b, []byte(end)); i < 0 {
+		return ""
+	}
+	return string(b[:i])
+}
@@ -0,0 +1,195 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	neturl "net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type scpReader struct {
+	client  *ssh.Client
+	session *ssh.Session
+	w       io.WriteCloser
+	r       *bufio.Reader
+	io.Reader
+}
+
+type scpWriter struct {
+	u   *neturl.URL
+	buf bytes.Buffer
+}
+
+// scpOpen runs "scp -f PATH" on the remote host then, after each of its
+// messages, is sent zero to continue; the first of these is the file's
+// mode, size, and name, then is the content and a final status.
+func scpOpen(u *neturl.URL) (io.ReadCloser, error) {
+	client, session, w, r, err := scpStart(u, "-f")
+	if err != nil {
+		return nil, err
+	}
+	rd := &scpReader{
+		client:  client,
+		session: session,
+		w:       w,
+		r:       r,
+	}
+	fail := func(err error) (io.ReadCloser, error) {
+		rd.end()
+		return nil, fmt.Errorf("%s: %v", redacted(u), err)
+	}
+	if _, err = w.Write([]byte{0}); err != nil {
+		return fail(err)
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return fail(err)
+	}
+	switch line[0] {
+	case 'C':
+	case 1, 2:
+		return fail(fmt.Errorf("%s", strings.TrimSpace(line[1:])))
+	default:
+		return fail(fmt.Errorf("%q: unexpected", line))
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) < 3 {
+		return fail(fmt.Errorf("%q: unexpected", line))
+	}
+	size, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return fail(err)
+	}
+	if _, err = w.Write([]byte{0}); err != nil {
+		return fail(err)
+	}
+	rd.Reader = io.LimitReader(r, size)
+	return rd, nil
+}
+
+func (rd *scpReader) Close() error {
+	// skip anything unread then acknowledge the final status
+	io.Copy(ioutil.Discard, rd.Reader)
+	status, err := rd.r.ReadByte()
+	if err == nil {
+		if status != 0 {
+			msg, _ := rd.r.ReadString('\n')
+			err = fmt.Errorf("%s", strings.TrimSpace(msg))
+		} else {
+			rd.w.Write([]byte{0})
+		}
+	}
+	rd.end()
+	return err
+}
+
+func (rd *scpReader) end() {
+	rd.w.Close()
+	rd.session.Close()
+	rd.client.Close()
+}
+
+// scpCreate buffers the content to send on Close with "scp -t PATH".
+func scpCreate(u *neturl.URL) (io.WriteCloser, error) {
+	if len(u.Path) == 0 || strings.HasSuffix(u.Path, "/") {
+		return nil, fmt.Errorf("%s: missing file name", redacted(u))
+	}
+	return &scpWriter{u: u}, nil
+}
+
+func (wr *scpWriter) Write(p []byte) (int, error) { return wr.buf.Write(p) }
+
+func (wr *scpWriter) Close() error {
+	client, session, w, r, err := scpStart(wr.u, "-t")
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	defer session.Close()
+	ack := func() error {
+		status, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if status != 0 {
+			msg, _ := r.ReadString('\n')
+			return fmt.Errorf("%s", strings.TrimSpace(msg))
+		}
+		return nil
+	}
+	err = ack()
+	if err == nil {
+		_, err = fmt.Fprintf(w, "C0644 %d %s\n", wr.buf.Len(),
+			path.Base(wr.u.Path))
+	}
+	if err == nil {
+		err = ack()
+	}
+	if err == nil {
+		_, err = w.Write(append(wr.buf.Bytes(), 0))
+	}
+	if err == nil {
+		err = ack()
+	}
+	w.Close()
+	if err == nil {
+		err = session.Wait()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", redacted(wr.u), err)
+	}
+	return nil
+}
+
+func scpStart(u *neturl.URL, mode string) (*ssh.Client, *ssh.Session,
+	io.WriteCloser, *bufio.Reader, error) {
+	client, err := SSHDial(u)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		client.Close()
+		return nil, nil, nil, nil, err
+	}
+	w, err := session.StdinPipe()
+	if err == nil {
+		var r io.Reader
+		r, err = session.StdoutPipe()
+		if err == nil {
+			err = session.Start("scp " + mode + " " +
+				shellQuote(scpPath(u)))
+			if err == nil {
+				return client, session, w, bufio.NewReader(r),
+					nil
+			}
+		}
+	}
+	session.Close()
+	client.Close()
+	return nil, nil, nil, nil, err
+}
+
+// scpPath is that of the URL or, if it begins with "/~/", relative to the
+// remote user's home.
+func scpPath(u *neturl.URL) string {
+	if strings.HasPrefix(u.Path, "/~/") {
+		return u.Path[3:]
+	}
+	return u.Path
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,288 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	neturl "net/url"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// the SFTP version 3 packets and flags used by this client of the ssh
+// "sftp" subsystem; it has one request outstanding at a time.
+const (
+	sftpFxpInit    = 1
+	sftpFxpVersion = 2
+	sftpFxpOpen    = 3
+	sftpFxpClose   = 4
+	sftpFxpRead    = 5
+	sftpFxpWrite   = 6
+	sftpFxpStatus  = 101
+	sftpFxpHandle  = 102
+	sftpFxpData    = 103
+
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagAppend = 0x04
+	sftpFlagCreat  = 0x08
+	sftpFlagTrunc  = 0x10
+
+	sftpOk  = 0
+	sftpEOF = 1
+
+	sftpChunk = 32 << 10
+)
+
+type sftpFile struct {
+	u       *neturl.URL
+	client  *ssh.Client
+	session *ssh.Session
+	w       io.WriteCloser
+	r       io.Reader
+	id      uint32
+	handle  []byte
+	offset  uint64
+}
+
+// sftpStatusError is the message of a failed request's STATUS reply.
+type sftpStatusError struct {
+	code uint32
+	msg  string
+}
+
+func (err *sftpStatusError) Error() string {
+	if len(err.msg) > 0 {
+		return err.msg
+	}
+	return fmt.Sprint("sftp status ", err.code)
+}
+
+func sftpOpen(u *neturl.URL) (io.ReadCloser, error) {
+	f, err := sftpStart(u, sftpFlagRead)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func sftpCreate(u *neturl.URL, isAppend bool) (io.WriteCloser, error) {
+	flags := uint32(sftpFlagWrite | sftpFlagCreat | sftpFlagTrunc)
+	if isAppend {
+		flags = sftpFlagWrite | sftpFlagCreat | sftpFlagAppend
+	}
+	f, err := sftpStart(u, flags)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func sftpStart(u *neturl.URL, flags uint32) (*sftpFile, error) {
+	client, err := SSHDial(u)
+	if err != nil {
+		return nil, err
+	}
+	f := &sftpFile{u: u, client: client}
+	fail := func(err error) (*sftpFile, error) {
+		f.end()
+		return nil, fmt.Errorf("%s: %v", redacted(u), err)
+	}
+	if f.session, err = client.NewSession(); err != nil {
+		return fail(err)
+	}
+	if f.w, err = f.session.StdinPipe(); err != nil {
+		return fail(err)
+	}
+	if f.r, err = f.session.StdoutPipe(); err != nil {
+		return fail(err)
+	}
+	if err = f.session.RequestSubsystem("sftp"); err != nil {
+		return fail(err)
+	}
+	if err = f.send(sftpFxpInit, uint32(3)); err != nil {
+		return fail(err)
+	}
+	t, _, err := f.recv()
+	if err != nil {
+		return fail(err)
+	}
+	if t != sftpFxpVersion {
+		return fail(fmt.Errorf("unexpected packet %d", t))
+	}
+	f.id++
+	err = f.send(sftpFxpOpen, f.id, []byte(scpPath(u)), flags, uint32(0))
+	if err != nil {
+		return fail(err)
+	}
+	t, b, err := f.reply()
+	if err != nil {
+		return fail(err)
+	}
+	if t != sftpFxpHandle {
+		return fail(fmt.Errorf("unexpected packet %d", t))
+	}
+	if f.handle, _, err = sftpString(b); err != nil {
+		return fail(err)
+	}
+	return f, nil
+}
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	if len(p) > sftpChunk {
+		p = p[:sftpChunk]
+	}
+	f.id++
+	err := f.send(sftpFxpRead, f.id, f.handle, f.offset, uint32(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	t, b, err := f.reply()
+	if err != nil {
+		if serr, ok := err.(*sftpStatusError); ok &&
+			serr.code == sftpEOF {
+			err = io.EOF
+		}
+		return 0, err
+	}
+	if t != sftpFxpData {
+		return 0, fmt.Errorf("%s: unexpected packet %d", redacted(f.u), t)
+	}
+	data, _, err := sftpString(b)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	f.offset += uint64(n)
+	return n, nil
+}
+
+func (f *sftpFile) Write(p []byte) (int, error) {
+	var n int
+	for n < len(p) {
+		chunk := p[n:]
+		if len(chunk) > sftpChunk {
+			chunk = chunk[:sftpChunk]
+		}
+		f.id++
+		err := f.send(sftpFxpWrite, f.id, f.handle, f.offset, chunk)
+		if err == nil {
+			_, _, err = f.reply()
+		}
+		if err != nil {
+			return n, fmt.Errorf("%s: %v", redacted(f.u), err)
+		}
+		n += len(chunk)
+		f.offset += uint64(len(chunk))
+	}
+	return n, nil
+}
+
+func (f *sftpFile) Close() error {
+	f.id++
+	err := f.send(sftpFxpClose, f.id, f.handle)
+	if err == nil {
+		_, _, err = f.reply()
+	}
+	f.end()
+	if err != nil {
+		return fmt.Errorf("%s: %v", redacted(f.u), err)
+	}
+	return nil
+}
+
+func (f *sftpFile) end() {
+	if f.w != nil {
+		f.w.Close()
+	}
+	if f.session != nil {
+		f.session.Close()
+	}
+	f.client.Close()
+}
+
+// send a packet of the given type with these uint32, uint64, and []byte
+// (string) fields.
+func (f *sftpFile) send(t byte, fields ...interface{}) error {
+	b := []byte{0, 0, 0, 0, t}
+	for _, field := range fields {
+		switch v := field.(type) {
+		case uint32:
+			b = sftpAppend32(b, v)
+		case uint64:
+			b = sftpAppend32(b, uint32(v>>32))
+			b = sftpAppend32(b, uint32(v))
+		case []byte:
+			b = sftpAppend32(b, uint32(len(v)))
+			b = append(b, v...)
+		default:
+			panic(fmt.Errorf("%T: unsupported", v))
+		}
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err := f.w.Write(b)
+	return err
+}
+
+func (f *sftpFile) recv() (byte, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(f.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n < 1 || n > 1<<20 {
+		return 0, nil, fmt.Errorf("%d: invalid packet length", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(f.r, b); err != nil {
+		return 0, nil, err
+	}
+	return b[0], b[1:], nil
+}
+
+// reply returns the type and, after its request id, the content of the
+// response; it returns an error of a STATUS other than OK.
+func (f *sftpFile) reply() (byte, []byte, error) {
+	t, b, err := f.recv()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(b) < 4 {
+		return 0, nil, fmt.Errorf("truncated packet")
+	}
+	if id := binary.BigEndian.Uint32(b); id != f.id {
+		return 0, nil, fmt.Errorf("%d: unexpected request id", id)
+	}
+	b = b[4:]
+	if t == sftpFxpStatus {
+		if len(b) < 4 {
+			return 0, nil, fmt.Errorf("truncated packet")
+		}
+		code := binary.BigEndian.Uint32(b)
+		if code == sftpOk {
+			return t, nil, nil
+		}
+		msg, _, _ := sftpString(b[4:])
+		return 0, nil, &sftpStatusError{code, string(msg)}
+	}
+	return t, b, nil
+}
+
+func sftpAppend32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func sftpString(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, fmt.Errorf("truncated string")
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return nil, nil, fmt.Errorf("truncated string")
+	}
+	return b[4 : 4+n], b[4+n:], nil
+}
@@ -0,0 +1,123 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// KeyFiles are the private keys tried by the scp and sftp schemes; those
+// beginning with "~/" are relative to $HOME.
+var KeyFiles = []string{
+	"~/.ssh/id_ed25519",
+	"~/.ssh/id_ecdsa",
+	"~/.ssh/id_rsa",
+}
+
+// KnownHosts are the files of host keys trusted by the scp and sftp
+// schemes; there's no connection to hosts that aren't in one of these.
+var KnownHosts = []string{
+	"~/.ssh/known_hosts",
+	"/etc/ssh/ssh_known_hosts",
+}
+
+func home(fn string) string {
+	if len(fn) > 2 && fn[:2] == "~/" {
+		if dir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(dir, fn[2:])
+		}
+	}
+	return fn
+}
+
+// SSHDial connects to the [USER[:PASSWORD]@]HOST[:PORT] of the URL with its
+// password, the ssh-agent, or KeyFiles, and a host of KnownHosts.
+func SSHDial(u *neturl.URL) (*ssh.Client, error) {
+	if len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("%s: missing host", u)
+	}
+	addr := u.Host
+	if len(u.Port()) == 0 {
+		addr = net.JoinHostPort(u.Hostname(), "22")
+	}
+	user := u.User.Username()
+	if len(user) == 0 {
+		user = os.Getenv("USER")
+	}
+	if len(user) == 0 {
+		user = "root"
+	}
+	hostKeyCallback, err := sshKnownHosts()
+	if err != nil {
+		return nil, err
+	}
+	config := &ssh.ClientConfig{
+		User:            user,
+		HostKeyCallback: hostKeyCallback,
+	}
+	if pw, set := u.User.Password(); set {
+		config.Auth = append(config.Auth, ssh.Password(pw))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); len(sock) > 0 {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			defer conn.Close()
+			signers, err := agent.NewClient(conn).Signers()
+			if err == nil && len(signers) > 0 {
+				config.Auth = append(config.Auth,
+					ssh.PublicKeys(signers...))
+			}
+		}
+	}
+	var signers []ssh.Signer
+	for _, fn := range KeyFiles {
+		b, err := ioutil.ReadFile(home(fn))
+		if err != nil {
+			continue
+		}
+		if signer, err := ssh.ParsePrivateKey(b); err == nil {
+			signers = append(signers, signer)
+		}
+	}
+	if len(signers) > 0 {
+		config.Auth = append(config.Auth, ssh.PublicKeys(signers...))
+	}
+	if len(config.Auth) == 0 {
+		return nil, fmt.Errorf("%s: no password, agent, or key", u.Host)
+	}
+	return ssh.Dial("tcp", addr, config)
+}
+
+func sshKnownHosts() (ssh.HostKeyCallback, error) {
+	var files []string
+	for _, fn := range KnownHosts {
+		fn = home(fn)
+		if _, err := os.Stat(fn); err == nil {
+			files = append(files, fn)
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no known hosts")
+	}
+	return knownhosts.New(files...)
+}
+
+// redacted returns the URL without its password for messages.
+func redacted(u *neturl.URL) string {
+	if _, set := u.User.Password(); set {
+		c := *u
+		c.User = neturl.UserPassword(u.User.Username(), "xxxxx")
+		return c.String()
+	}
+	return u.String()
+}
@@ -0,0 +1,146 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package url
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	neturl "net/url"
+	"time"
+)
+
+// the RFC 1350 opcodes and limits of the tftp writer; the reader is that of
+// github.com/platinasystems/tftp.
+const (
+	tftpWRQ   = 2
+	tftpDATA  = 3
+	tftpACK   = 4
+	tftpERROR = 5
+
+	tftpBlockSize = 512
+	tftpRetries   = 5
+)
+
+// TftpTimeout is the time to wait for each acknowledgement of a tftp write
+// before retransmitting.
+var TftpTimeout = 2 * time.Second
+
+type tftpWriter struct {
+	u     *neturl.URL
+	conn  *net.UDPConn
+	peer  *net.UDPAddr
+	block uint16
+	buf   []byte
+	err   error
+}
+
+// tftpCreate sends the write request and waits for its acknowledgement
+// from the server's transfer port, where it then sends each 512 byte block.
+func tftpCreate(u *neturl.URL) (io.WriteCloser, error) {
+	port := u.Port()
+	if len(port) == 0 {
+		port = "69"
+	}
+	server, err := net.ResolveUDPAddr("udp",
+		net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	w := &tftpWriter{u: u, conn: conn, peer: server}
+	wrq := []byte{0, tftpWRQ}
+	wrq = append(wrq, u.Path...)
+	wrq = append(wrq, 0)
+	wrq = append(wrq, "octet"...)
+	wrq = append(wrq, 0)
+	if err = w.exchange(wrq, true); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %v", u, err)
+	}
+	return w, nil
+}
+
+func (w *tftpWriter) Write(p []byte) (int, error) {
+	if w.buf == nil {
+		w.buf = make([]byte, 0, tftpBlockSize)
+	}
+	var n int
+	for n < len(p) && w.err == nil {
+		i := copy(w.buf[len(w.buf):cap(w.buf)], p[n:])
+		w.buf, n = w.buf[:len(w.buf)+i], n+i
+		if len(w.buf) == tftpBlockSize {
+			w.err = w.send()
+		}
+	}
+	return n, w.err
+}
+
+// Close sends the remaining, short, and possibly empty, final block.
+func (w *tftpWriter) Close() error {
+	if w.err == nil {
+		w.err = w.send()
+	}
+	w.conn.Close()
+	return w.err
+}
+
+func (w *tftpWriter) send() error {
+	w.block++
+	data := make([]byte, 4, 4+len(w.buf))
+	data[1] = tftpDATA
+	binary.BigEndian.PutUint16(data[2:], w.block)
+	data = append(data, w.buf...)
+	w.buf = w.buf[:0]
+	if err := w.exchange(data, false); err != nil {
+		return fmt.Errorf("%s: %v", w.u, err)
+	}
+	return nil
+}
+
+// exchange sends the packet until it's acknowledged; the first, that of the
+// request, is answered from the server's transfer port.
+func (w *tftpWriter) exchange(packet []byte, first bool) error {
+	var b [tftpBlockSize + 4]byte
+	for try := 0; try < tftpRetries; try++ {
+		if _, err := w.conn.WriteToUDP(packet, w.peer); err != nil {
+			return err
+		}
+		deadline := time.Now().Add(TftpTimeout)
+		for {
+			w.conn.SetReadDeadline(deadline)
+			n, from, err := w.conn.ReadFromUDP(b[:])
+			if err != nil {
+				if ne, ok := err.(net.Error); ok && ne.Timeout() {
+					break
+				}
+				return err
+			}
+			if !from.IP.Equal(w.peer.IP) ||
+				(!first && from.Port != w.peer.Port) || n < 4 {
+				continue
+			}
+			switch binary.BigEndian.Uint16(b[:]) {
+			case tftpACK:
+				if binary.BigEndian.Uint16(b[2:]) != w.block {
+					continue
+				}
+				w.peer = from
+				return nil
+			case tftpERROR:
+				msg := b[4:n]
+				if i := len(msg) - 1; i >= 0 && msg[i] == 0 {
+					msg = msg[:i]
+				}
+				return fmt.Errorf("%s", msg)
+			}
+		}
+	}
+	return fmt.Errorf("timeout")
+}
@@ -0,0 +1,91 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// Package url extends github.com/platinasystems/url, which opens files and
+// http or tftp URLs, and creates files and http URLs, with these schemes,
+//
+//	scp://[USER[:PASSWORD]@]HOST[:PORT]/PATH
+//	sftp://[USER[:PASSWORD]@]HOST[:PORT]/PATH
+//	s3://BUCKET/KEY
+//	tftp://HOST[:PORT]/PATH
+//
+// The scp and sftp URLs authenticate with the URL's password, the keys of
+// the ssh agent at SSH_AUTH_SOCK, and those of KeyFiles; the host key must
+// be in one of KnownHosts. The s3 URLs are signed with the credentials of
+// the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+// environment variables, if set, for the AWS_REGION, or AWS_DEFAULT_REGION,
+// with the endpoint of AWS_ENDPOINT_URL, if set, e.g. that of a minio
+// server. The tftp URLs may also be created, but not appended.
+package url
+
+import (
+	"fmt"
+	"io"
+	neturl "net/url"
+
+	purl "github.com/platinasystems/url"
+)
+
+// FilePathFromUrl returns the file path if the argument isn't a URL or is
+// that of a file; otherwise the parsed URL.
+func FilePathFromUrl(path string) (string, *neturl.URL, error) {
+	return purl.FilePathFromUrl(path)
+}
+
+// Open returns a reader of the file or URL.
+func Open(path string) (io.ReadCloser, error) {
+	fp, u, err := FilePathFromUrl(path)
+	if err != nil || len(fp) > 0 {
+		return purl.Open(path)
+	}
+	return OpenUrl(u)
+}
+
+// OpenUrl returns a reader of the URL.
+func OpenUrl(u *neturl.URL) (io.ReadCloser, error) {
+	switch u.Scheme {
+	case "scp":
+		return scpOpen(u)
+	case "sftp":
+		return sftpOpen(u)
+	case "s3":
+		return s3Open(u)
+	}
+	return purl.OpenUrl(u)
+}
+
+// Create returns a writer that truncates or creates the file or URL; those
+// of http, scp, and s3 are buffered and sent on Close.
+func Create(path string) (io.WriteCloser, error) { return create(path, false) }
+
+// Append returns a writer that extends the file or URL; the scp, s3, and
+// tftp schemes can't append.
+func Append(path string) (io.WriteCloser, error) { return create(path, true) }
+
+func create(path string, isAppend bool) (io.WriteCloser, error) {
+	u, err := neturl.Parse(path)
+	if err != nil || (u.Scheme == "" && u.Host == "") {
+		u = &neturl.URL{}
+	}
+	switch u.Scheme {
+	case "sftp":
+		return sftpCreate(u, isAppend)
+	case "scp", "s3", "tftp":
+		if isAppend {
+			return nil, fmt.Errorf("%s: can't append", u.Scheme)
+		}
+	}
+	switch u.Scheme {
+	case "scp":
+		return scpCreate(u)
+	case "s3":
+		return s3Create(u)
+	case "tftp":
+		return tftpCreate(u)
+	}
+	if isAppend {
+		return purl.Append(path)
+	}
+	return purl.Create(path)
+}
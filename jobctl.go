@@ -0,0 +1,49 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build !windows
+
+package goes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+var sigCont os.Signal = syscall.SIGCONT
+
+// setpgid has the command start in its own process group, e.g. that of a
+// background job.
+func setpgid(x *exec.Cmd) {
+	if x.SysProcAttr == nil {
+		x.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	x.SysProcAttr.Setpgid = true
+}
+
+func killGroup(p *os.Process, sig os.Signal) error {
+	if t, ok := sig.(syscall.Signal); ok {
+		return syscall.Kill(-p.Pid, t)
+	}
+	return p.Signal(sig)
+}
+
+// isStopped returns true if the process state of /proc/PID/stat is T.
+func isStopped(p *os.Process) bool {
+	b, err := ioutil.ReadFile(fmt.Sprint("/proc/", p.Pid, "/stat"))
+	if err != nil {
+		return false
+	}
+	// PID (COMM) STATE ...
+	s := string(b)
+	if i := strings.LastIndex(s, ")"); i > 0 {
+		fields := strings.Fields(s[i+1:])
+		return len(fields) > 0 && fields[0] == "T"
+	}
+	return false
+}
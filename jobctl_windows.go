@@ -0,0 +1,26 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build windows
+
+package goes
+
+import (
+	"os"
+	"os/exec"
+)
+
+// this has no SIGCONT, so, jobs can't be stopped and continued
+var sigCont os.Signal
+
+func setpgid(x *exec.Cmd) {}
+
+func killGroup(p *os.Process, sig os.Signal) error {
+	if sig == nil {
+		return nil
+	}
+	return p.Signal(sig)
+}
+
+func isStopped(p *os.Process) bool { return false }
@@ -0,0 +1,306 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/platinasystems/goes/internal/shellutils"
+)
+
+// Job is a command list run in the background, i.e. with a trailing '&'.
+// It runs with a clone of the cli context, so its assignments don't change
+// those of the cli, and its forked commands are in their own process group,
+// so interrupts of the cli's foreground commands don't stop it.
+type Job struct {
+	ID      int
+	Command string
+
+	mutex sync.Mutex
+	procs []*os.Process
+	done  chan struct{}
+	err   error
+}
+
+type jobTable struct {
+	sync.Mutex
+	list []*Job
+}
+
+func (g *Goes) jobTable() *jobTable {
+	r := g.root()
+	r.tableInit.Do(func() {
+		if r.table == nil {
+			r.table = new(jobTable)
+		}
+	})
+	return r.table
+}
+
+// background returns the list processed by a goes with a clone of this
+// context and a runner that starts it as a job.
+func (g *Goes) background(ls shellutils.List) (*shellutils.List,
+	*shellutils.Word, func(io.Reader, io.Writer, io.Writer) error, error) {
+	job := &Job{
+		Command: listString(ls),
+		done:    make(chan struct{}),
+	}
+	jg := &Goes{
+		NAME:      g.NAME,
+		USAGE:     g.USAGE,
+		APROPOS:   g.APROPOS,
+		MAN:       g.MAN,
+		ByName:    g.ByName,
+		Terminal:  g.Terminal,
+		Verbosity: g.Verbosity,
+		parent:    g.parent,
+		Stdin:     g.Stdin,
+		Stdout:    g.Stdout,
+		Stderr:    g.Stderr,
+		Environ:   g.Environ,
+		inTest:    g.inTest,
+		argv0:     g.argv0,
+		table:     g.jobTable(),
+		job:       job,
+	}
+	jg.WithContext(g.Context().Clone())
+	nextls, term, runner, err := jg.processList(ls)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	start := func(stdin io.Reader, stdout, stderr io.Writer) error {
+		table := g.jobTable()
+		table.add(job)
+		fmt.Fprintf(stderr, "[%d] %s\n", job.ID, job.Command)
+		go func() {
+			err := runner(stdin, stdout, stderr)
+			job.mutex.Lock()
+			job.err = err
+			job.mutex.Unlock()
+			close(job.done)
+		}()
+		return g.SetStatus(nil)
+	}
+	return nextls, term, start, nil
+}
+
+// listTerm returns the terminator of the list's first command list, i.e.
+// that following its last pipeline of && or ||.
+func listTerm(ls shellutils.List) string {
+	for _, cl := range ls.Cmds {
+		switch s := cl.Term.String(); s {
+		case "|", "&&", "||":
+		default:
+			return s
+		}
+	}
+	return ""
+}
+
+// listString returns the command list up to its terminator.
+func listString(ls shellutils.List) string {
+	for i, cl := range ls.Cmds {
+		switch cl.Term.String() {
+		case "|", "&&", "||":
+		default:
+			ls.Cmds = append([]shellutils.Cmdline{}, ls.Cmds[:i+1]...)
+			ls.Cmds[i].Term = shellutils.Word{}
+			return ls.String()
+		}
+	}
+	return ls.String()
+}
+
+func (t *jobTable) add(job *Job) {
+	t.Lock()
+	defer t.Unlock()
+	job.ID = 1
+	if n := len(t.list); n > 0 {
+		job.ID = t.list[n-1].ID + 1
+	}
+	t.list = append(t.list, job)
+}
+
+func (t *jobTable) remove(job *Job) {
+	t.Lock()
+	defer t.Unlock()
+	for i, x := range t.list {
+		if x == job {
+			t.list = append(t.list[:i], t.list[i+1:]...)
+			break
+		}
+	}
+}
+
+func (t *jobTable) all() []*Job {
+	t.Lock()
+	defer t.Unlock()
+	return append([]*Job(nil), t.list...)
+}
+
+// find returns the job of the given %N or N, or the latest without.
+func (t *jobTable) find(args []string) (*Job, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("%v: unexpected", args[1:])
+	}
+	list := t.all()
+	if len(list) == 0 {
+		return nil, fmt.Errorf("no jobs")
+	}
+	if len(args) == 0 {
+		return list[len(list)-1], nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "%"))
+	if err == nil {
+		for _, job := range list {
+			if job.ID == id {
+				return job, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: no such job", args[0])
+}
+
+// started adds a forked command of the job.
+func (job *Job) started(p *os.Process) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	job.procs = append(job.procs, p)
+}
+
+// signal the process groups of the job's forked commands.
+func (job *Job) signal(sig os.Signal) {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	for _, p := range job.procs {
+		killGroup(p, sig)
+	}
+}
+
+// State returns Running, Stopped, Done, or Exit with the job's error.
+func (job *Job) State() string {
+	select {
+	case <-job.done:
+		job.mutex.Lock()
+		defer job.mutex.Unlock()
+		if job.err != nil {
+			return fmt.Sprint("Exit: ", job.err)
+		}
+		return "Done"
+	default:
+	}
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	for _, p := range job.procs {
+		if isStopped(p) {
+			return "Stopped"
+		}
+	}
+	return "Running"
+}
+
+func (job *Job) isDone() bool {
+	select {
+	case <-job.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (job *Job) pids() []string {
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	var pids []string
+	for _, p := range job.procs {
+		pids = append(pids, strconv.Itoa(p.Pid))
+	}
+	return pids
+}
+
+// ReportJobs prints and forgets the background jobs that have finished
+// since the last report, e.g. before an interactive prompt.
+func (g *Goes) ReportJobs(w io.Writer) {
+	table := g.jobTable()
+	for _, job := range table.all() {
+		if job.isDone() {
+			fmt.Fprintf(w, "[%d] %s\t%s\n", job.ID, job.State(),
+				job.Command)
+			table.remove(job)
+		}
+	}
+}
+
+// jobs [-l]
+func (g *Goes) jobs(args ...string) error {
+	long := len(args) > 0 && args[0] == "-l"
+	if long {
+		args = args[1:]
+	}
+	if len(args) > 0 {
+		return fmt.Errorf("%v: unexpected", args)
+	}
+	table := g.jobTable()
+	for _, job := range table.all() {
+		fmt.Fprintf(g.stdout(), "[%d] %s\t%s", job.ID, job.State(),
+			job.Command)
+		if long {
+			if pids := job.pids(); len(pids) > 0 {
+				fmt.Fprint(g.stdout(), "\t", strings.Join(pids, " "))
+			}
+		}
+		fmt.Fprintln(g.stdout())
+		if job.isDone() {
+			table.remove(job)
+		}
+	}
+	return nil
+}
+
+// fg [%N] continues the job, if stopped, then waits for it with interrupts
+// forwarded to its commands; this returns the job's error.
+func (g *Goes) fg(args ...string) error {
+	table := g.jobTable()
+	job, err := table.find(args)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(g.stdout(), job.Command)
+	job.signal(sigCont)
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	for !job.isDone() {
+		select {
+		case <-job.done:
+		case t := <-sig:
+			job.signal(t)
+		}
+	}
+	table.remove(job)
+	job.mutex.Lock()
+	defer job.mutex.Unlock()
+	return job.err
+}
+
+// bg [%N] continues the stopped job in the background.
+func (g *Goes) bg(args ...string) error {
+	job, err := g.jobTable().find(args)
+	if err != nil {
+		return err
+	}
+	if job.isDone() {
+		return fmt.Errorf("%%%d: already done", job.ID)
+	}
+	job.signal(sigCont)
+	fmt.Fprintf(g.stdout(), "[%d] %s &\n", job.ID, job.Command)
+	return nil
+}
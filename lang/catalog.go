@@ -0,0 +1,294 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lang
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LocaleDir has the gettext style message catalogs of each locale,
+//
+//	LocaleDir/LOCALE/LC_MESSAGES/Domain.mo
+//	LocaleDir/LOCALE/LC_MESSAGES/Domain.po
+//
+// Where LOCALE is tried as given, e.g. "fr_FR.UTF-8", then without its
+// codeset and modifier, "fr_FR", then as just the language, "fr". The msgid
+// of each message is its EnUS text.
+var LocaleDir = "/usr/share/goes/locale"
+
+// Domain of the message catalogs
+var Domain = "goes"
+
+var catalogs struct {
+	sync.Mutex
+	// by locale, nil if it doesn't have a catalog
+	m map[string]map[string]string
+}
+
+// Locale returns the language of messages; that of Lang, if set, otherwise
+// the LC_ALL, LC_MESSAGES, or LANG environment variable.
+func Locale() string {
+	if len(Lang) > 0 {
+		return Lang
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if s := os.Getenv(name); len(s) > 0 {
+			return s
+		}
+	}
+	return ""
+}
+
+// Gettext returns the translation of msgid in the Locale catalog, or msgid
+// if there isn't one.
+func Gettext(msgid string) string {
+	if s, found := translate(Locale(), msgid); found {
+		return s
+	}
+	return msgid
+}
+
+// Reload the catalogs on next use, e.g. after these are installed.
+func Reload() {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	catalogs.m = nil
+}
+
+// Available returns the locales of LocaleDir that have a catalog.
+func Available() []string {
+	var list []string
+	dirs, _ := ioutil.ReadDir(LocaleDir)
+	for _, fi := range dirs {
+		for _, ext := range []string{".mo", ".po"} {
+			_, err := os.Stat(catalogFn(fi.Name(), ext))
+			if err == nil {
+				list = append(list, fi.Name())
+				break
+			}
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// HasCatalog returns true if the locale, or one of its fallbacks, has a
+// catalog.
+func HasCatalog(locale string) bool {
+	return len(locale) > 0 && catalog(locale) != nil
+}
+
+func translate(locale, msgid string) (string, bool) {
+	if len(locale) == 0 || len(msgid) == 0 {
+		return "", false
+	}
+	s, found := catalog(locale)[msgid]
+	return s, found && len(s) > 0
+}
+
+func catalog(locale string) map[string]string {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+	m, found := catalogs.m[locale]
+	if !found {
+		m = load(locale)
+		if catalogs.m == nil {
+			catalogs.m = make(map[string]map[string]string)
+		}
+		catalogs.m[locale] = m
+	}
+	return m
+}
+
+// load the first catalog of the locale's fallbacks; it logs malformed
+// catalogs to stderr rather than failing the text that would use these.
+func load(locale string) map[string]string {
+	for _, name := range fallbacks(locale) {
+		for _, x := range []struct {
+			ext   string
+			parse func(io.Reader) (map[string]string, error)
+		}{
+			{".mo", ParseMo},
+			{".po", ParsePo},
+		} {
+			fn := catalogFn(name, x.ext)
+			f, err := os.Open(fn)
+			if err != nil {
+				continue
+			}
+			m, err := x.parse(f)
+			f.Close()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", fn, err)
+				continue
+			}
+			return m
+		}
+	}
+	return nil
+}
+
+func catalogFn(locale, ext string) string {
+	return filepath.Join(LocaleDir, locale, "LC_MESSAGES", Domain+ext)
+}
+
+// fallbacks of LL_CC.CODESET@MODIFIER are the same, LL_CC, and LL.
+func fallbacks(locale string) []string {
+	list := []string{locale}
+	s := locale
+	if i := strings.IndexAny(s, ".@"); i > 0 {
+		s = s[:i]
+		list = append(list, s)
+	}
+	if i := strings.Index(s, "_"); i > 0 {
+		list = append(list, s[:i])
+	}
+	return list
+}
+
+// ParseMo returns the messages of a compiled gettext catalog. Only the
+// singular forms are used, and messages with a context are skipped.
+func ParseMo(r io.Reader) (map[string]string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 20 {
+		return nil, fmt.Errorf("truncated")
+	}
+	var bo binary.ByteOrder
+	switch binary.LittleEndian.Uint32(b) {
+	case 0x950412de:
+		bo = binary.LittleEndian
+	case 0xde120495:
+		bo = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a catalog")
+	}
+	n := int(bo.Uint32(b[8:]))
+	orig, trans := int(bo.Uint32(b[12:])), int(bo.Uint32(b[16:]))
+	str := func(table, i int) (string, error) {
+		at := table + 8*i
+		if at < 0 || at+8 > len(b) {
+			return "", fmt.Errorf("truncated")
+		}
+		l, o := int(bo.Uint32(b[at:])), int(bo.Uint32(b[at+4:]))
+		if o < 0 || l < 0 || o+l > len(b) {
+			return "", fmt.Errorf("truncated")
+		}
+		s := string(b[o : o+l])
+		if i := strings.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+		return s, nil
+	}
+	m := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		id, err := str(orig, i)
+		if err != nil {
+			return nil, err
+		}
+		s, err := str(trans, i)
+		if err != nil {
+			return nil, err
+		}
+		if len(id) > 0 && !strings.Contains(id, "\x04") {
+			m[id] = s
+		}
+	}
+	return m, nil
+}
+
+// ParsePo returns the messages of a gettext source catalog. Like ParseMo,
+// this only uses singular forms and skips those with a context; it also
+// skips the messages flagged fuzzy.
+func ParsePo(r io.Reader) (map[string]string, error) {
+	m := make(map[string]string)
+	var (
+		id, str    *strings.Builder
+		cur        *strings.Builder
+		skip       = new(strings.Builder)
+		ctx, fuzzy bool
+		lineno     int
+	)
+	flush := func() {
+		if id != nil && str != nil && !ctx && !fuzzy &&
+			id.Len() > 0 {
+			m[id.String()] = str.String()
+		}
+		id, str, cur, ctx, fuzzy = nil, nil, nil, false, false
+	}
+	scan := bufio.NewScanner(r)
+	scan.Buffer(nil, 1<<20)
+	for scan.Scan() {
+		lineno++
+		t := strings.TrimSpace(scan.Text())
+		keyword := t
+		if i := strings.IndexAny(t, " \t"); i > 0 {
+			keyword = t[:i]
+		}
+		switch {
+		case len(t) == 0:
+			continue
+		case strings.HasPrefix(t, "#,"):
+			if str != nil {
+				flush()
+			}
+			fuzzy = strings.Contains(t, "fuzzy")
+			continue
+		case t[0] == '#':
+			continue
+		case t[0] == '"':
+			if cur == nil {
+				return nil, fmt.Errorf("%d: unexpected string",
+					lineno)
+			}
+		case keyword == "msgctxt":
+			if str != nil {
+				flush()
+			}
+			ctx, cur = true, skip
+		case keyword == "msgid":
+			if str != nil {
+				flush()
+			}
+			id = new(strings.Builder)
+			cur = id
+		case keyword == "msgid_plural":
+			cur = skip
+		case keyword == "msgstr" || keyword == "msgstr[0]":
+			str = new(strings.Builder)
+			cur = str
+		case strings.HasPrefix(keyword, "msgstr["):
+			cur = skip
+		default:
+			return nil, fmt.Errorf("%d: %s: unknown", lineno, keyword)
+		}
+		q := strings.TrimSpace(t[len(keyword):])
+		if t[0] == '"' {
+			q = t
+		}
+		if len(q) == 0 {
+			continue
+		}
+		s, err := strconv.Unquote(q)
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", lineno, err)
+		}
+		cur.WriteString(s)
+	}
+	flush()
+	return m, scan.Err()
+}
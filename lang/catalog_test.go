@@ -0,0 +1,104 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package lang
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const po = `# French
+msgid ""
+msgstr ""
+"Content-Type: text/plain; charset=UTF-8\n"
+
+msgid "reboot system"
+msgstr "redémarrer le "
+"système"
+
+#, fuzzy
+msgid "print version"
+msgstr "afficher la version"
+
+msgctxt "menu"
+msgid "quit"
+msgstr "quitter"
+
+msgid "file"
+msgid_plural "files"
+msgstr[0] "fichier"
+msgstr[1] "fichiers"
+`
+
+func mo(msgs ...string) []byte {
+	n := len(msgs) / 2
+	buf := new(bytes.Buffer)
+	hdr := []uint32{0x950412de, 0, uint32(n), 28, uint32(28 + 8*n), 0, 0}
+	binary.Write(buf, binary.LittleEndian, hdr)
+	o := 28 + 16*n
+	var strs []byte
+	for _, table := range []int{0, 1} {
+		for i := 0; i < n; i++ {
+			s := msgs[2*i+table]
+			binary.Write(buf, binary.LittleEndian,
+				[]uint32{uint32(len(s)), uint32(o + len(strs))})
+			strs = append(append(strs, s...), 0)
+		}
+	}
+	buf.Write(strs)
+	return buf.Bytes()
+}
+
+func TestCatalog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lang")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(dir string) { LocaleDir = dir }(LocaleDir)
+	LocaleDir = dir
+	defer func(lang string) { Lang = lang }(Lang)
+	defer Reload()
+	for fn, b := range map[string][]byte{
+		"fr/LC_MESSAGES/goes.po":    []byte(po),
+		"de_DE/LC_MESSAGES/goes.mo": mo("reboot system", "System neu starten"),
+	} {
+		fn = filepath.Join(dir, fn)
+		os.MkdirAll(filepath.Dir(fn), 0755)
+		if err = ioutil.WriteFile(fn, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m, err := ParsePo(bytes.NewReader([]byte(po)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m) != 2 || m["file"] != "fichier" {
+		t.Errorf("po: %q", m)
+	}
+	alt := Alt{EnUS: "reboot system", JaJP: "再起動"}
+	for lang, want := range map[string]string{
+		"fr_FR.UTF-8": "redémarrer le système",
+		"de_DE.UTF-8": "System neu starten",
+		JaJP:          "再起動",
+		"it_IT.UTF-8": "reboot system",
+	} {
+		Lang = lang
+		if s := alt.String(); s != want {
+			t.Errorf("%s: %q != %q", lang, s, want)
+		}
+	}
+	Lang = "fr_FR.UTF-8"
+	if s := Gettext("print version"); s != "print version" {
+		t.Error("fuzzy:", s)
+	}
+	if list := Available(); len(list) != 2 || !HasCatalog("fr_CA") {
+		t.Error("available:", list)
+	}
+}
@@ -4,8 +4,11 @@
 
 // Package lang provides text in alternative languages.
 //
-// The language precedence is the value of the "LANG" environment variable
-// followed by a configurable default; then the goes default, en_US.UTF-8.
+// The language precedence is that of Lang, if set, or the "LC_ALL",
+// "LC_MESSAGES", or "LANG" environment variable followed by a configurable
+// default; then the goes default, en_US.UTF-8. Text that isn't compiled in
+// for a language is looked up by its en_US.UTF-8 text in the message catalog
+// of that language, if installed in LocaleDir.
 //
 // Use this build ldflag to configure the default,
 //
@@ -16,8 +19,6 @@
 // go test -tags french -v
 package lang
 
-import "os"
-
 const (
 	AaDJ  = "aa_DJ.UTF-8"
 	AfZA  = "af_ZA.UTF-8"
@@ -171,6 +172,7 @@ const (
 var (
 	Default = EnUS
 
+	// Lang, if set, overrides the language of the environment.
 	Lang string
 )
 
@@ -178,13 +180,16 @@ type Alt map[string]string
 
 // If available, this returns text in the prefered language.
 func (m Alt) String() string {
-	if len(Lang) == 0 {
-		Lang = os.Getenv("LANG")
-	}
-	for _, lang := range []string{Lang, Default, EnUS} {
+	for _, lang := range []string{Locale(), Default, EnUS} {
 		if s, found := m[lang]; found {
 			return s
 		}
+		if lang == EnUS {
+			continue
+		}
+		if s, found := translate(lang, m[EnUS]); found {
+			return s
+		}
 	}
 	return ""
 }
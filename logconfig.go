@@ -0,0 +1,97 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"strings"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+)
+
+// LogFieldPrefix of the published "log.format" and "log.level[.SUBSYS]"
+// fields of the default hash applied by each daemon, e.g.
+//
+//	goes log level vnetd debug
+const LogFieldPrefix = "log."
+
+// logConfig applies the published log and verbosity fields, then those
+// changed, until stopped. This retries every few seconds until redisd is
+// available.
+func (g *Goes) logConfig(daemon string, stop <-chan struct{}) {
+	v := &daemonVerbosity{g: g, daemon: daemon, initial: g.Verbosity}
+	for {
+		psc, err := redis.Subscribe(redis.DefaultHash)
+		if err == nil {
+			fields, _ := redis.Hgetall(redis.DefaultHash,
+				LogFieldPrefix)
+			for field, value := range fields {
+				applyLogField(field, value)
+			}
+			fields, _ = redis.Hgetall(redis.DefaultHash,
+				VerbosityField)
+			for field, value := range fields {
+				v.apply(field, value)
+			}
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-stop:
+				case <-done:
+				}
+				psc.Close()
+			}()
+			receiveLogFields(psc, v)
+			close(done)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func receiveLogFields(psc redigo.PubSubConn, v *daemonVerbosity) {
+	for {
+		switch t := psc.Receive().(type) {
+		case redigo.Message:
+			s := string(t.Data)
+			i := strings.Index(s, ": ")
+			if i <= 0 {
+				continue
+			}
+			field, value := s[:i], s[i+2:]
+			if strings.HasPrefix(field, LogFieldPrefix) {
+				applyLogField(field, value)
+			} else if strings.HasPrefix(field, VerbosityField) {
+				v.apply(field, value)
+			}
+		case error:
+			return
+		}
+	}
+}
+
+func applyLogField(field, value string) {
+	switch {
+	case field == "log.format":
+		if err := log.SetFormat(value); err != nil {
+			log.Print("daemon", "err", field, ": ", err)
+		}
+	case field == "log.level" || strings.HasPrefix(field, "log.level."):
+		subsys := strings.TrimPrefix(strings.TrimPrefix(field,
+			"log.level"), ".")
+		if value == "default" {
+			log.ResetLevel(subsys)
+		} else if pri, err := log.ParseLevel(value); err == nil {
+			log.SetLevel(subsys, pri)
+		} else {
+			log.Print("daemon", "err", field, ": ", err)
+		}
+	}
+}
@@ -36,8 +36,64 @@ OPTIONS
 	-d	debug block handling
 	-x	print command trace
 	-f	don't terminate script on error
+	-i	recover from a SCRIPT error with commands from stdin, see the
+		RECOVERY section of 'man cli'
 	-	execute standard input script
 	SCRIPT	execute named script file
+	-remote HOST
+		run the interactive cli with the goes sshd of HOST
+	-stats	report the resource usage of the COMMAND, SCRIPT, or
+		cli session and, with the cli, that of each command line
+	-sim FIXTURE
+		simulate the machine's hardware with the FIXTURE file, see
+		SIMULATION
+
+VERBOSITY
+	The verbosity builtin shows or sets the LEVEL of this goes, e.g. that
+	of the interactive cli, rather than restart it with -debug.
+
+	With DAEMON, it instead publishes the goes.verbosity.DAEMON field, or
+	goes.verbosity with "all", that the running daemons apply; this also
+	sets the log level of each to "debug", or "info" for the others. The
+	"default" LEVEL clears these.
+		goes verbosity debug vnetd
+		goes verbosity default all
+
+STATS
+	The stats builtin shows or sets whether the cli reports the wall
+	time, user and system CPU time, largest resident set, and number of
+	forked commands after each command line, e.g.:
+		goes> stats on
+		goes> show version
+		...
+		stats: show version: real 52.3ms user 8ms sys 12ms maxrss 12288KiB forked 1
+
+	The CPU time and resident set are those of this goes and the
+	commands it has waited for, so a background job or the later stages
+	of a pipeline may not be counted.
+
+JOBS
+	The jobs, fg, and bg builtins list, wait for, and continue the
+	command lists that the cli runs in the background with a trailing
+	'&'; see "man cli".
+
+SIMULATION
+	With -sim, the i2c devices, e.g. eeproms, and hwmon sensors are mocks
+	configured by the FIXTURE sections of the same format as the machine
+	configuration, e.g.:
+		i2c:
+		  0:
+		    0x51:
+		      type: eeprom
+		      file: eeprom.bin
+		hwmon:
+		  coretemp:
+		    temp1_input: 45000
+
+	The GOES_SIM variable names the FIXTURE for the commands and daemons
+	started from this goes, e.g. "goes -sim ci/fixture.yaml goes-daemons"
+	runs the supervised daemons in a container or VM without switch
+	hardware.
 
 SEE ALSO
 	goes apropos [COMMAND], goes man COMMAND`,
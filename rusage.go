@@ -0,0 +1,32 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build !windows
+
+package goes
+
+import (
+	"runtime"
+	"syscall"
+	"time"
+)
+
+func (u *Rusage) get() {
+	for _, who := range []int{syscall.RUSAGE_SELF, syscall.RUSAGE_CHILDREN} {
+		var ru syscall.Rusage
+		if syscall.Getrusage(who, &ru) != nil {
+			continue
+		}
+		u.User += time.Duration(ru.Utime.Nano())
+		u.Sys += time.Duration(ru.Stime.Nano())
+		rss := int64(ru.Maxrss)
+		if runtime.GOOS == "darwin" {
+			// bytes rather than kilobytes
+			rss /= 1024
+		}
+		if rss > u.MaxRSS {
+			u.MaxRSS = rss
+		}
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+// +build windows
+
+package goes
+
+// get leaves all but the wall time and fork count zero since windows
+// doesn't have getrusage.
+func (*Rusage) get() {}
@@ -0,0 +1,80 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// forked is the number of commands started by Fork.
+var forked uint64
+
+// Rusage is the resource usage of the process and its waited for children.
+type Rusage struct {
+	Time      time.Time
+	User, Sys time.Duration
+	// MaxRSS is the largest resident set, in kilobytes, of the process
+	// or any one child.
+	MaxRSS int64
+	Forked uint64
+}
+
+// GetRusage returns the resource usage up to now.
+func GetRusage() Rusage {
+	u := Rusage{
+		Time:   time.Now(),
+		Forked: atomic.LoadUint64(&forked),
+	}
+	u.get()
+	return u
+}
+
+// Since returns the usage from that of start, except MaxRSS, a high water
+// mark, e.g.
+//
+//	real 12.1ms user 4ms sys 8ms maxrss 10240KiB forked 1
+func (u Rusage) Since(start Rusage) string {
+	return fmt.Sprint("real ", u.Time.Sub(start.Time).Round(time.Microsecond),
+		" user ", (u.User - start.User).Round(time.Microsecond),
+		" sys ", (u.Sys - start.Sys).Round(time.Microsecond),
+		" maxrss ", u.MaxRSS, "KiB",
+		" forked ", u.Forked-start.Forked)
+}
+
+// ReportStats prints the usage since start of the named command or
+// script, if Stats is set.
+func (g *Goes) ReportStats(w io.Writer, name string, start Rusage) {
+	if g.Stats {
+		fmt.Fprint(w, "stats: ", name, ": ", GetRusage().Since(start), "\n")
+	}
+}
+
+// stats is the builtin that shows or sets whether resource usage is reported
+// after each cli command line.
+func (g *Goes) stats(args ...string) error {
+	if len(args) == 0 {
+		if g.Stats {
+			fmt.Fprintln(g.stdout(), "on")
+		} else {
+			fmt.Fprintln(g.stdout(), "off")
+		}
+		return nil
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%v: unexpected", args[1:])
+	}
+	switch args[0] {
+	case "on":
+		g.Stats = true
+	case "off":
+		g.Stats = false
+	default:
+		return fmt.Errorf("%s: neither on nor off", args[0])
+	}
+	return nil
+}
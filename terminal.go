@@ -0,0 +1,103 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Terminal is the command input of the cli and the continuation lines of
+// its blocks and here documents.
+type Terminal interface {
+	// ReadLine returns the next line, without its newline, after
+	// printing the prompt, if interactive.
+	ReadLine(prompt string) (string, error)
+	// AddHistory records an interactive command line for recall.
+	AddHistory(line string)
+	// SetCompleter replaces the function that returns the completions
+	// of an interactive line.
+	SetCompleter(func(line string) []string)
+	// Raw returns the terminal's reader and writer for commands that
+	// bypass line editing, e.g. an editor or pager.
+	Raw() (io.Reader, io.Writer)
+}
+
+// PipeTerminal is the Terminal of a script, pipe, or other reader that
+// doesn't support editing, history, or completion.
+type PipeTerminal struct {
+	r       io.Reader
+	w       io.Writer
+	scanner *bufio.Scanner
+	name    string
+	lineno  int
+}
+
+// NewPipeTerminal returns a Terminal that reads lines from r and writes
+// prompts to w, unless nil.
+func NewPipeTerminal(r io.Reader, w io.Writer) *PipeTerminal {
+	return &PipeTerminal{r: r, w: w, scanner: bufio.NewScanner(r)}
+}
+
+// Named sets the script name reported with syntax errors and returns the
+// terminal.
+func (t *PipeTerminal) Named(name string) *PipeTerminal {
+	t.name = name
+	return t
+}
+
+func (t *PipeTerminal) ReadLine(prompt string) (string, error) {
+	if t.w != nil {
+		fmt.Fprint(t.w, prompt)
+	}
+	if t.scanner.Scan() {
+		t.lineno++
+		return t.scanner.Text(), nil
+	}
+	err := t.scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	return "", err
+}
+
+func (*PipeTerminal) AddHistory(string) {}
+
+func (*PipeTerminal) SetCompleter(func(string) []string) {}
+
+func (t *PipeTerminal) Raw() (io.Reader, io.Writer) { return t.r, t.w }
+
+// Position returns the script name, if any, and number of the line last
+// read for the errors of shellutils.Parse.
+func (t *PipeTerminal) Position() (string, int) { return t.name, t.lineno }
+
+// ReadLine returns the next line of the goes Terminal or, without one, its
+// standard input; so, the goes itself may be given to shellutils.Parse for
+// the continuation lines of a block.
+func (g *Goes) ReadLine(prompt string) (string, error) {
+	return g.terminal().ReadLine(prompt)
+}
+
+// Position returns that of the goes Terminal, if it has one, for the errors
+// of shellutils.Parse.
+func (g *Goes) Position() (string, int) {
+	if p, ok := g.terminal().(interface {
+		Position() (string, int)
+	}); ok {
+		return p.Position()
+	}
+	return "", 0
+}
+
+func (g *Goes) terminal() Terminal {
+	if g.Terminal != nil {
+		return g.Terminal
+	}
+	g.pipeInit.Do(func() {
+		g.pipe = NewPipeTerminal(g.stdin(), g.stdout())
+	})
+	return g.pipe
+}
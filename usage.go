@@ -25,8 +25,16 @@ func (g *Goes) Usage() string {
 	goes COMMAND -[-]HELPER [ ARGS ]...
 	goes HELPER [ COMMAND ] [ ARGS ]...
 	goes [ -d ] [ -x ] [[ -f ][ - | SCRIPT ]]
+	goes [ -x ] -i SCRIPT
+	goes -remote [USER@]HOST[:PORT]
+	goes -stats [ COMMAND [ ARGS ]... | SCRIPT ]
+	goes -sim FIXTURE [ COMMAND [ ARGS ]... | SCRIPT ]
+	goes stats [ on | off ]
+	goes { jobs [ -l ] | fg [ %N ] | bg [ %N ] }
+	goes verbosity [ LEVEL [ DAEMON | all ]... ]
 
-	HELPER := { apropos | complete | help | man | usage }`
+	HELPER := { apropos | complete | help | man | usage }
+	LEVEL := { quiet | verify | debug | default }`
 	}
 	return usage
 }
@@ -0,0 +1,121 @@
+// Copyright © 2015-2020 Platina Systems, Inc. All rights reserved.
+// Use of this source code is governed by the GPL-2 license described in the
+// LICENSE file.
+
+package goes
+
+import (
+	"fmt"
+
+	"github.com/platinasystems/goes/external/log"
+	"github.com/platinasystems/goes/external/redis"
+)
+
+// VerbosityField of the default hash is the published verbosity of all
+// daemons; that of VerbosityField.DAEMON, e.g. "goes.verbosity.vnetd",
+// overrides it for the one. Each daemon applies these as these change.
+const VerbosityField = "goes.verbosity"
+
+// VerbosityNames are those of VerboseQuiet, VerboseVerify, and VerboseDebug.
+var VerbosityNames = []string{"quiet", "verify", "debug"}
+
+// VerbosityName returns the name of the verbosity level.
+func VerbosityName(verbosity int) string {
+	if verbosity < VerboseQuiet {
+		verbosity = VerboseQuiet
+	} else if verbosity > VerboseDebug {
+		verbosity = VerboseDebug
+	}
+	return VerbosityNames[verbosity]
+}
+
+// ParseVerbosity returns the level of the verbosity name.
+func ParseVerbosity(s string) (int, error) {
+	for i, name := range VerbosityNames {
+		if s == name {
+			return i, nil
+		}
+	}
+	return VerboseQuiet, fmt.Errorf("%q: unknown verbosity", s)
+}
+
+// verbosity is the builtin that shows or sets that of this goes or
+// publishes that of daemons and their log level, e.g.
+//
+//	verbosity debug
+//	verbosity debug vnetd
+//	verbosity default all
+func (g *Goes) verbosity(args ...string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(g.stdout(), VerbosityName(g.Verbosity))
+		return nil
+	}
+	if args[0] != "default" {
+		v, err := ParseVerbosity(args[0])
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			g.Verbosity = v
+			return nil
+		}
+	} else if len(args) == 1 {
+		return fmt.Errorf("DAEMON: missing")
+	}
+	level := "info"
+	switch args[0] {
+	case "debug", "default":
+		level = args[0]
+	}
+	for _, daemon := range args[1:] {
+		field, logField := VerbosityField, "log.level"
+		if daemon != "all" {
+			field += "." + daemon
+			logField += "." + daemon
+		}
+		if _, err := redis.Hset(redis.DefaultHash, field,
+			args[0]); err != nil {
+			return err
+		}
+		if _, err := redis.Hset(redis.DefaultHash, logField,
+			level); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// daemonVerbosity applies the published verbosity of all daemons, or that
+// of the one, to its goes; with neither, it's that of the daemon's start.
+type daemonVerbosity struct {
+	g         *Goes
+	daemon    string
+	initial   int
+	all, this string
+}
+
+func (v *daemonVerbosity) apply(field, value string) {
+	var p *string
+	switch field {
+	case VerbosityField:
+		p = &v.all
+	case VerbosityField + "." + v.daemon:
+		p = &v.this
+	default:
+		return
+	}
+	if value == "default" {
+		value = ""
+	} else if _, err := ParseVerbosity(value); err != nil {
+		log.Print("daemon", "err", field, ": ", err)
+		return
+	}
+	*p = value
+	verbosity := v.initial
+	if len(v.this) > 0 {
+		verbosity, _ = ParseVerbosity(v.this)
+	} else if len(v.all) > 0 {
+		verbosity, _ = ParseVerbosity(v.all)
+	}
+	v.g.Verbosity = verbosity
+}